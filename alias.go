@@ -0,0 +1,157 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// Aliases maps logical table names to physical ones, for blue/green table
+// swaps and per-environment naming. With WithAliases, a Client resolves its
+// table (WithTable) on every call, so a Swap takes effect on the next call.
+// Names without an alias are used as is.
+//
+//	aliases, err := libdy.TableAliases(ctx, svc, "config", "pk:aliases", "", time.Minute)
+//	client := libdy.New(svc, libdy.WithTable("orders"), libdy.WithAliases(aliases))
+//	...
+//	err = aliases.Swap(ctx, "orders", "orders-green") // cut over, everywhere
+type Aliases struct {
+	mu     sync.RWMutex
+	m      map[string]string
+	loaded time.Time
+
+	// The config item, if any.
+	svc     dynamodbiface.DynamoDBAPI
+	table   string
+	pk, sk  string
+	every   time.Duration
+	loading int32
+}
+
+// NewAliases returns Aliases with the fixed mapping m, logical to physical.
+func NewAliases(m map[string]string) *Aliases {
+	a := &Aliases{m: make(map[string]string, len(m))}
+	for k, v := range m {
+		a.m[k] = v
+	}
+
+	return a
+}
+
+// TableAliases returns Aliases kept in the config item at pk (and sk, if
+// set) in table: each string attribute other than the key is an alias,
+// named by the logical name. The item needn't exist yet; see Swap. It is
+// read now, then again in the background when a name is resolved more than
+// every after the last read; 0 means never. A failed background read keeps
+// the previous mapping.
+func TableAliases(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, pk, sk string, every time.Duration) (*Aliases, error) {
+	a := &Aliases{m: map[string]string{}, svc: svc, table: table, pk: pk, sk: sk, every: every}
+	if err := a.Refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// Refresh reads the config item again. It does nothing for NewAliases.
+func (a *Aliases) Refresh(ctx context.Context) error {
+	if a.svc == nil {
+		return nil
+	}
+
+	o := options{table: a.table, consistent: true}
+	key, err := a.key(ctx, o)
+	if err != nil {
+		return fmt.Errorf("Aliases refresh failed: %w", err)
+	}
+
+	item, err := getItem(ctx, a.svc, key, o)
+	if err != nil && !errors.Is(err, ErrItemNotFound) {
+		return fmt.Errorf("Aliases refresh failed: %w", err)
+	}
+
+	m := map[string]string{}
+	for k, v := range item {
+		if _, ok := key[k]; ok || v.S == nil {
+			continue
+		}
+
+		m[k] = aws.StringValue(v.S)
+	}
+
+	a.mu.Lock()
+	a.m, a.loaded = m, time.Now()
+	a.mu.Unlock()
+	return nil
+}
+
+// Resolve returns the physical table for name, or name itself if it has no
+// alias. A nil Aliases resolves every name to itself.
+func (a *Aliases) Resolve(name string) string {
+	if a == nil {
+		return name
+	}
+
+	a.mu.RLock()
+	physical, ok := a.m[name]
+	stale := a.every > 0 && time.Since(a.loaded) > a.every
+	a.mu.RUnlock()
+	if stale && atomic.CompareAndSwapInt32(&a.loading, 0, 1) {
+		go func() {
+			defer atomic.StoreInt32(&a.loading, 0)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			a.Refresh(ctx) // on failure, the previous mapping stays
+		}()
+	}
+
+	if !ok {
+		return name
+	}
+
+	return physical
+}
+
+// Swap points the logical name to the physical table, in the config item
+// too for TableAliases, so other processes follow on their next refresh.
+func (a *Aliases) Swap(ctx context.Context, logical, physical string) error {
+	if a.svc != nil {
+		o := options{table: a.table}
+		key, err := a.key(ctx, o)
+		if err != nil {
+			return fmt.Errorf("Aliases swap failed: %w", err)
+		}
+
+		u := NewUpdate().Set(logical, &dynamodb.AttributeValue{S: aws.String(physical)})
+		if _, err := updateItem(ctx, a.svc, key, u, o); err != nil {
+			return fmt.Errorf("Aliases swap failed: %w", err)
+		}
+	}
+
+	a.mu.Lock()
+	a.m[logical] = physical
+	a.mu.Unlock()
+	return nil
+}
+
+// key returns the key of the config item.
+func (a *Aliases) key(ctx context.Context, o options) (map[string]*dynamodb.AttributeValue, error) {
+	kp, ks, err := New(a.svc).keys(ctx, o, a.pk, a.sk)
+	if err != nil {
+		return nil, err
+	}
+
+	return keyMap(kp, ks), nil
+}
+
+// WithAliases resolves the table of each call through a (see Aliases).
+func WithAliases(a *Aliases) Option {
+	return func(o *options) { o.aliases = a }
+}
@@ -0,0 +1,101 @@
+package libdy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Authorizer decides what principal may see of item, read from table, for
+// row- and field-level access control: it returns the item to return,
+// possibly with attributes stripped, or nil to drop it. It must not modify
+// item, and should fail closed, dropping items for a nil principal (a
+// context without one).
+//
+//	libdy.WithAuthorizer(func(principal interface{}, table string, item map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+//		user, _ := principal.(*User)
+//		switch {
+//		case user == nil || aws.StringValue(item["tenant"].S) != user.Tenant:
+//			return nil, nil
+//		case !user.Admin:
+//			return libdy.StripAttrs(item, "ssn", "salary"), nil
+//		}
+//
+//		return item, nil
+//	})
+type Authorizer func(principal interface{}, table string, item map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error)
+
+// WithAuthorizer makes reads pass their items through a, with the principal
+// of the read's context (see ContextWithPrincipal), after the read
+// pipeline: GetItem, Query, Scan, and the like, their Pages and iterators,
+// ParallelScan, BatchGetItems, TransactGetItems (where dropped items are
+// nil), Coalescer, SampleItems, and PartiQL reads (checked against the
+// Client's table). Set it on the Client to enforce it everywhere.
+func WithAuthorizer(a Authorizer) Option {
+	return func(o *options) { o.authorizer = a }
+}
+
+type principalKey struct{}
+
+// ContextWithPrincipal returns a copy of ctx carrying principal, the
+// identity reads are authorized for (see WithAuthorizer).
+func ContextWithPrincipal(ctx context.Context, principal interface{}) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal of ctx, or nil.
+func PrincipalFromContext(ctx context.Context) interface{} {
+	return ctx.Value(principalKey{})
+}
+
+// StripAttrs returns a copy of item without attrs, for Authorizers.
+func StripAttrs(item map[string]*dynamodb.AttributeValue, attrs ...string) map[string]*dynamodb.AttributeValue {
+	ret := make(map[string]*dynamodb.AttributeValue, len(item))
+	for k, v := range item {
+		ret[k] = v
+	}
+
+	for _, a := range attrs {
+		delete(ret, a)
+	}
+
+	return ret
+}
+
+// authorize passes the items read from table through the authorizer of o,
+// dropping the items it denies.
+func (o options) authorize(ctx context.Context, table string, items []map[string]*dynamodb.AttributeValue) ([]map[string]*dynamodb.AttributeValue, error) {
+	if o.authorizer == nil {
+		return items, nil
+	}
+
+	principal := PrincipalFromContext(ctx)
+	ret := make([]map[string]*dynamodb.AttributeValue, 0, len(items))
+	for _, item := range items {
+		item, err := o.authorizer(principal, table, item)
+		if err != nil {
+			return nil, fmt.Errorf("authorize failed: %w", err)
+		}
+
+		if item != nil {
+			ret = append(ret, item)
+		}
+	}
+
+	return ret, nil
+}
+
+// authorizeItem is authorize for a single item, which is nil if denied.
+func (o options) authorizeItem(ctx context.Context, table string, item map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+	if o.authorizer == nil || item == nil {
+		return item, nil
+	}
+
+	item, err := o.authorizer(PrincipalFromContext(ctx), table, item)
+	if err != nil {
+		return nil, fmt.Errorf("authorize failed: %w", err)
+	}
+
+	return item, nil
+}
@@ -0,0 +1,235 @@
+package libdy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// backupPoll is how often the backup and PITR waiters check the status.
+const backupPoll = 5 * time.Second
+
+// controlCall runs the control plane call fn, retrying the errors for which
+// retriable returns non-nil (see controlRetriable). name is the operation
+// name for errors.
+func controlCall(ctx context.Context, name string, fn func() error, retriable func(error) error) error {
+	start := time.Now()
+	var rerr error
+
+	// Our retriable function.
+	op := func(ctx context.Context) error {
+		rerr = fn()
+		return retriable(rerr)
+	}
+
+	err := retry(ctx, options{}, name, op)
+	if (err != nil || rerr != nil) && ctx.Err() != nil {
+		return fmt.Errorf("%s canceled after %v: %w", name, time.Since(start), ctx.Err())
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s failed after %v: %w", name, time.Since(start), err)
+	}
+
+	if rerr != nil {
+		return fmt.Errorf("%s failed: %w", name, awsErr(rerr))
+	}
+
+	return nil
+}
+
+func CreateBackup(svc dynamodbiface.DynamoDBAPI, table, name string) (*dynamodb.BackupDetails, error) {
+	return CreateBackupWithContext(context.Background(), svc, table, name)
+}
+
+// CreateBackupWithContext starts an on-demand backup of table, e.g. before a
+// risky migration. An empty name defaults to the table name and the UTC time,
+// like "orders-20240102T150405Z". The backup is usable once AVAILABLE; see
+// WaitForBackupWithContext.
+func CreateBackupWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, name string) (*dynamodb.BackupDetails, error) {
+	if name == "" {
+		name = table + "-" + time.Now().UTC().Format("20060102T150405Z")
+	}
+
+	var res *dynamodb.CreateBackupOutput
+	err := controlCall(ctx, "CreateBackup", func() error {
+		var err error
+		res, err = svc.CreateBackupWithContext(ctx, &dynamodb.CreateBackupInput{
+			TableName:  aws.String(table),
+			BackupName: aws.String(name),
+		})
+
+		return err
+	}, controlRetriable)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res.BackupDetails, nil
+}
+
+func ListBackups(svc dynamodbiface.DynamoDBAPI, table string) ([]*dynamodb.BackupSummary, error) {
+	return ListBackupsWithContext(context.Background(), svc, table)
+}
+
+// ListBackupsWithContext returns the backups of table, all pages, or those
+// of all tables if table is empty.
+func ListBackupsWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string) ([]*dynamodb.BackupSummary, error) {
+	input := &dynamodb.ListBackupsInput{}
+	if table != "" {
+		input.TableName = aws.String(table)
+	}
+
+	var ret []*dynamodb.BackupSummary
+	for {
+		res, err := svc.ListBackupsWithContext(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("ListBackups failed: %w", awsErr(err))
+		}
+
+		ret = append(ret, res.BackupSummaries...)
+		if res.LastEvaluatedBackupArn == nil {
+			return ret, nil
+		}
+
+		input.ExclusiveStartBackupArn = res.LastEvaluatedBackupArn
+	}
+}
+
+func WaitForBackup(svc dynamodbiface.DynamoDBAPI, backupARN string) (*dynamodb.BackupDetails, error) {
+	return WaitForBackupWithContext(context.Background(), svc, backupARN)
+}
+
+// WaitForBackupWithContext waits until the backup is AVAILABLE, or until ctx
+// is done. A deleted backup fails.
+func WaitForBackupWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, backupARN string) (*dynamodb.BackupDetails, error) {
+	t := time.NewTicker(backupPoll)
+	defer t.Stop()
+	for {
+		res, err := svc.DescribeBackupWithContext(ctx, &dynamodb.DescribeBackupInput{BackupArn: aws.String(backupARN)})
+		if err != nil {
+			return nil, fmt.Errorf("DescribeBackup failed: %w", awsErr(err))
+		}
+
+		d := res.BackupDescription.BackupDetails
+		switch aws.StringValue(d.BackupStatus) {
+		case dynamodb.BackupStatusAvailable:
+			return d, nil
+		case dynamodb.BackupStatusDeleted:
+			return nil, fmt.Errorf("WaitForBackup failed: backup %s deleted", backupARN)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("WaitForBackup canceled: %w", ctx.Err())
+		case <-t.C:
+		}
+	}
+}
+
+func RestoreTableFromBackup(svc dynamodbiface.DynamoDBAPI, backupARN, table string) (*dynamodb.TableDescription, error) {
+	return RestoreTableFromBackupWithContext(context.Background(), svc, backupARN, table)
+}
+
+// RestoreTableFromBackupWithContext restores the backup to a new table,
+// which must not exist yet. The table is usable once ACTIVE; see
+// WaitForTableActiveWithContext.
+func RestoreTableFromBackupWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, backupARN, table string) (*dynamodb.TableDescription, error) {
+	var res *dynamodb.RestoreTableFromBackupOutput
+	err := controlCall(ctx, "RestoreTableFromBackup", func() error {
+		var err error
+		res, err = svc.RestoreTableFromBackupWithContext(ctx, &dynamodb.RestoreTableFromBackupInput{
+			BackupArn:       aws.String(backupARN),
+			TargetTableName: aws.String(table),
+		})
+
+		return err
+	}, controlRetriable)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res.TableDescription, nil
+}
+
+func EnablePointInTimeRecovery(svc dynamodbiface.DynamoDBAPI, table string) error {
+	return EnablePointInTimeRecoveryWithContext(context.Background(), svc, table)
+}
+
+// EnablePointInTimeRecoveryWithContext turns on point-in-time recovery
+// (continuous backups) for table. It takes a while to take effect; see
+// WaitForPointInTimeRecoveryWithContext.
+func EnablePointInTimeRecoveryWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string) error {
+	return setPointInTimeRecovery(ctx, svc, table, true)
+}
+
+func DisablePointInTimeRecovery(svc dynamodbiface.DynamoDBAPI, table string) error {
+	return DisablePointInTimeRecoveryWithContext(context.Background(), svc, table)
+}
+
+// DisablePointInTimeRecoveryWithContext turns off point-in-time recovery for
+// table.
+func DisablePointInTimeRecoveryWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string) error {
+	return setPointInTimeRecovery(ctx, svc, table, false)
+}
+
+func setPointInTimeRecovery(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, on bool) error {
+	return controlCall(ctx, "UpdateContinuousBackups", func() error {
+		_, err := svc.UpdateContinuousBackupsWithContext(ctx, &dynamodb.UpdateContinuousBackupsInput{
+			TableName: aws.String(table),
+			PointInTimeRecoverySpecification: &dynamodb.PointInTimeRecoverySpecification{
+				PointInTimeRecoveryEnabled: aws.Bool(on),
+			},
+		})
+
+		return err
+	}, func(err error) error {
+		// Continuous backups are unavailable for a while after the table is
+		// created or the setting changed.
+		if ErrorCode(err) == dynamodb.ErrCodeContinuousBackupsUnavailableException {
+			return err
+		}
+
+		return controlRetriable(err)
+	})
+}
+
+func WaitForPointInTimeRecovery(svc dynamodbiface.DynamoDBAPI, table string, enabled bool) error {
+	return WaitForPointInTimeRecoveryWithContext(context.Background(), svc, table, enabled)
+}
+
+// WaitForPointInTimeRecoveryWithContext waits until point-in-time recovery
+// for table is enabled (or disabled), or until ctx is done.
+func WaitForPointInTimeRecoveryWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, enabled bool) error {
+	want := dynamodb.PointInTimeRecoveryStatusDisabled
+	if enabled {
+		want = dynamodb.PointInTimeRecoveryStatusEnabled
+	}
+
+	t := time.NewTicker(backupPoll)
+	defer t.Stop()
+	for {
+		res, err := svc.DescribeContinuousBackupsWithContext(ctx, &dynamodb.DescribeContinuousBackupsInput{TableName: aws.String(table)})
+		if err != nil {
+			return fmt.Errorf("DescribeContinuousBackups failed: %w", awsErr(err))
+		}
+
+		d := res.ContinuousBackupsDescription
+		if d != nil && d.PointInTimeRecoveryDescription != nil &&
+			aws.StringValue(d.PointInTimeRecoveryDescription.PointInTimeRecoveryStatus) == want {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("WaitForPointInTimeRecovery canceled: %w", ctx.Err())
+		case <-t.C:
+		}
+	}
+}
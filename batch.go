@@ -0,0 +1,307 @@
+package libdy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/cenkalti/backoff"
+)
+
+// BatchWriteItem accepts at most 25 requests per call.
+const batchWriteMax = 25
+
+// WriteFailure is a single write request that did not land.
+type WriteFailure struct {
+	Request *dynamodb.WriteRequest
+	Err     error
+}
+
+// BatchWriteError is returned by the batch write helpers when some of the
+// requests could not be applied. Everything not listed in Failures landed.
+type BatchWriteError struct {
+	Total    int
+	Failures []WriteFailure
+}
+
+func (e *BatchWriteError) Error() string {
+	return fmt.Sprintf("%d of %d batch writes failed, first: %v", len(e.Failures), e.Total, e.Failures[0].Err)
+}
+
+func (e *BatchWriteError) Unwrap() error { return e.Failures[0].Err }
+
+func BatchPutItems(svc dynamodbiface.DynamoDBAPI, table string, items []map[string]*dynamodb.AttributeValue, opts ...Option) error {
+	return BatchPutItemsWithContext(context.Background(), svc, table, items, opts...)
+}
+
+// BatchPutItemsWithContext writes items in chunks of 25, retrying throttled
+// calls and unprocessed items with backoff. On partial failure, the returned
+// error is a *BatchWriteError. Writes to the same item collapse to the last
+// one, as DynamoDB rejects batches that write an item twice.
+func BatchPutItemsWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, items []map[string]*dynamodb.AttributeValue, opts ...Option) error {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	items = o.deriveAll(items)
+	if err := o.schemas.checkAll(table, items); err != nil {
+		return fmt.Errorf("BatchPutItems failed: %w", err)
+	}
+
+	items, err := o.encodeAll(ctx, svc, items)
+	if err != nil {
+		return fmt.Errorf("BatchPutItems failed: %w", err)
+	}
+
+	return batchWrite(ctx, svc, table, putRequests(items), o)
+}
+
+func putRequests(items []map[string]*dynamodb.AttributeValue) []*dynamodb.WriteRequest {
+	reqs := make([]*dynamodb.WriteRequest, len(items))
+	for i, item := range items {
+		reqs[i] = &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: item}}
+	}
+
+	return reqs
+}
+
+func BatchDeleteItems(svc dynamodbiface.DynamoDBAPI, table string, keys []map[string]*dynamodb.AttributeValue, opts ...Option) error {
+	return BatchDeleteItemsWithContext(context.Background(), svc, table, keys, opts...)
+}
+
+// BatchDeleteItemsWithContext deletes the items with the given primary keys
+// in chunks of 25. See BatchPutItemsWithContext.
+func BatchDeleteItemsWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, keys []map[string]*dynamodb.AttributeValue, opts ...Option) error {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return batchWrite(ctx, svc, table, deleteRequests(keys), o)
+}
+
+func deleteRequests(keys []map[string]*dynamodb.AttributeValue) []*dynamodb.WriteRequest {
+	reqs := make([]*dynamodb.WriteRequest, len(keys))
+	for i, key := range keys {
+		reqs[i] = &dynamodb.WriteRequest{DeleteRequest: &dynamodb.DeleteRequest{Key: key}}
+	}
+
+	return reqs
+}
+
+func batchWrite(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, reqs []*dynamodb.WriteRequest, o options) error {
+	total := len(reqs)
+	if len(reqs) > 1 {
+		o.table = table
+		attrs, _ := New(svc).keyAttrs(ctx, o) // without them, don't collapse
+		reqs = collapse(reqs, attrs)
+	}
+
+	var failed []WriteFailure
+	o.meter = o.costMeter()
+	for i := 0; i < len(reqs); i += batchWriteMax {
+		if o.meter.exceeded() {
+			err := o.meter.err("BatchWriteItem", table)
+			err.Written = i
+			if len(failed) == 0 {
+				return err
+			}
+
+			failed = append(failed, writeFailures(reqs[i:], err)...)
+			break
+		}
+
+		end := i + batchWriteMax
+		if end > len(reqs) {
+			end = len(reqs)
+		}
+
+		failed = append(failed, batchWriteChunk(ctx, svc, table, reqs[i:end], o)...)
+	}
+
+	if len(failed) > 0 {
+		return &BatchWriteError{Total: total, Failures: failed}
+	}
+
+	return nil
+}
+
+// collapse returns reqs with only the last write to each item, per the key
+// attributes attrs; with none, it returns reqs.
+func collapse(reqs []*dynamodb.WriteRequest, attrs []string) []*dynamodb.WriteRequest {
+	if len(attrs) == 0 {
+		return reqs
+	}
+
+	id := func(i int) string {
+		key := keyOf(writeItem(reqs[i]), attrs)
+		for _, v := range key {
+			if v == nil {
+				return fmt.Sprint("\x00", i) // left for DynamoDB to reject
+			}
+		}
+
+		return keyID(key)
+	}
+
+	last := map[string]int{}
+	for i := range reqs {
+		last[id(i)] = i
+	}
+
+	if len(last) == len(reqs) {
+		return reqs
+	}
+
+	ret := make([]*dynamodb.WriteRequest, 0, len(last))
+	for i, req := range reqs {
+		if last[id(i)] == i {
+			ret = append(ret, req)
+		}
+	}
+
+	return ret
+}
+
+// batchWriteChunk writes up to 25 requests, resubmitting unprocessed items
+// with exponential backoff until they all land or the backoff gives up.
+func batchWriteChunk(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, reqs []*dynamodb.WriteRequest, o options) []WriteFailure {
+	start := time.Now()
+	pending := reqs
+	b := o.backOff(ctx)
+	attempts := 0
+	for {
+		attempts++
+		var rerr, err error
+		var res *dynamodb.BatchWriteItemOutput
+		if err = o.writeLimit.wait(ctx); err != nil {
+			err = fmt.Errorf("BatchWriteItem canceled after %v: %w", time.Since(start), err)
+			return writeFailures(pending, err)
+		}
+
+		reqStart := time.Now()
+
+		// Our retriable, backoff-able function.
+		op := func(ctx context.Context) error {
+			res, err = svc.BatchWriteItemWithContext(ctx, &dynamodb.BatchWriteItemInput{
+				RequestItems:           map[string][]*dynamodb.WriteRequest{table: pending},
+				ReturnConsumedCapacity: o.returnCapacity(),
+			})
+
+			rerr = err
+			return o.retriable(err)
+		}
+
+		tries, err := retryN(ctx, o, "BatchWriteItem", op)
+		o.observe(ctx, "BatchWriteItem", table, reqStart, tries, res, err, rerr)
+		switch {
+		case err != nil:
+			err = fmt.Errorf("BatchWriteItem failed after %v: %w", time.Since(start), err)
+		case rerr != nil:
+			err = fmt.Errorf("BatchWriteItem failed: %w", awsErr(rerr))
+		}
+
+		if err != nil {
+			return writeFailures(pending, err)
+		}
+
+		units := capacityUnits(res.ConsumedCapacity...)
+		o.writeLimit.consume(units)
+		o.meter.add(units)
+		o.reportCapacity(table, "BatchWriteItem", units, 1)
+		pending = res.UnprocessedItems[table]
+		if len(pending) == 0 {
+			return nil
+		}
+
+		next := b.NextBackOff()
+		if next != backoff.Stop && ctx.Err() == nil {
+			select {
+			case <-ctx.Done():
+			case <-o.after(next):
+			}
+		}
+
+		if ctx.Err() != nil {
+			err = fmt.Errorf("BatchWriteItem canceled after %v: %w", time.Since(start), ctx.Err())
+			return writeFailures(pending, err)
+		}
+
+		if next == backoff.Stop {
+			err = &RetryError{
+				Err:       fmt.Errorf("%d unprocessed item(s)", len(pending)),
+				Attempts:  attempts,
+				Truncated: b.truncated,
+			}
+
+			err = fmt.Errorf("BatchWriteItem failed after %v: %w", time.Since(start), err)
+			return writeFailures(pending, err)
+		}
+	}
+}
+
+func writeFailures(reqs []*dynamodb.WriteRequest, err error) []WriteFailure {
+	ret := make([]WriteFailure, len(reqs))
+	for i, r := range reqs {
+		ret[i] = WriteFailure{Request: r, Err: err}
+	}
+
+	return ret
+}
+
+// Item returns the item (for puts) or key (for deletes) of the failed request.
+func (f WriteFailure) Item() map[string]*dynamodb.AttributeValue {
+	switch {
+	case f.Request == nil:
+		return nil
+	case f.Request.PutRequest != nil:
+		return f.Request.PutRequest.Item
+	case f.Request.DeleteRequest != nil:
+		return f.Request.DeleteRequest.Key
+	}
+
+	return nil
+}
+
+func (c *Client) BatchPutItems(ctx context.Context, items []map[string]*dynamodb.AttributeValue, opts ...Option) error {
+	o, err := c.apply(opts)
+	if err != nil {
+		return err
+	}
+
+	items = o.deriveAll(items)
+	if err := o.schemas.checkAll(o.table, items); err != nil {
+		return fmt.Errorf("BatchPutItems failed: %w", err)
+	}
+
+	defer func() {
+		for _, item := range items {
+			o.cache.invalidate(o.table, item)
+		}
+	}()
+
+	items, err = o.encodeAll(ctx, c.svc, items)
+	if err != nil {
+		return fmt.Errorf("BatchPutItems failed: %w", err)
+	}
+
+	return batchWrite(ctx, c.svc, o.table, putRequests(items), o)
+}
+
+func (c *Client) BatchDeleteItems(ctx context.Context, keys []map[string]*dynamodb.AttributeValue, opts ...Option) error {
+	o, err := c.apply(opts)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		for _, key := range keys {
+			o.cache.invalidate(o.table, key)
+		}
+	}()
+
+	return batchWrite(ctx, c.svc, o.table, deleteRequests(keys), o)
+}
@@ -0,0 +1,114 @@
+package libdy_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/flowerinthenight/libdy"
+	"github.com/flowerinthenight/libdy/libdytest"
+)
+
+// partial is a Fake processing only the first n requests of each
+// BatchWriteItem call, returning the others unprocessed.
+type partial struct {
+	*libdytest.Fake
+	n     int
+	calls int
+}
+
+func (p *partial) BatchWriteItemWithContext(ctx aws.Context, in *dynamodb.BatchWriteItemInput, opts ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
+	p.calls++
+	head := map[string][]*dynamodb.WriteRequest{}
+	unprocessed := map[string][]*dynamodb.WriteRequest{}
+	for table, reqs := range in.RequestItems {
+		n := min(p.n, len(reqs))
+		head[table] = reqs[:n]
+		if n < len(reqs) {
+			unprocessed[table] = reqs[n:]
+		}
+	}
+
+	if _, err := p.Fake.BatchWriteItemWithContext(ctx, &dynamodb.BatchWriteItemInput{RequestItems: head}, opts...); err != nil {
+		return nil, err
+	}
+
+	return &dynamodb.BatchWriteItemOutput{UnprocessedItems: unprocessed}, nil
+}
+
+func numbered(n int) []map[string]*dynamodb.AttributeValue {
+	items := make([]map[string]*dynamodb.AttributeValue, n)
+	for i := range items {
+		items[i] = map[string]*dynamodb.AttributeValue{"id": {S: aws.String(fmt.Sprint(i))}}
+	}
+
+	return items
+}
+
+func TestBatchWrite(t *testing.T) {
+	ctx := context.Background()
+	svc := &partial{Fake: libdytest.SetupFake(t, libdy.TableDef{Name: "t", PK: "id"}), n: 10}
+	c := libdy.New(svc, libdy.WithTable("t"), libdy.WithRetrier(libdy.ConstantBackoff(time.Millisecond, 5)))
+
+	// Chunks of 25, each resubmitting its unprocessed items: 25 + 15 + 5,
+	// twice, and 10.
+	if err := c.BatchPutItems(ctx, numbered(60)); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(svc.Items("t")); n != 60 || svc.calls != 7 {
+		t.Errorf("%d items in %d calls, want 60 in 7", n, svc.calls)
+	}
+
+	keys := numbered(60)
+	err := libdy.BatchDeleteItemsWithContext(ctx, svc, "t", keys[:30], libdy.WithRetrier(libdy.ConstantBackoff(time.Millisecond, 5)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(svc.Items("t")); n != 30 {
+		t.Errorf("%d items after deleting 30, want 30", n)
+	}
+
+	// Items still unprocessed when the backoff gives up fail alone.
+	var bwe *libdy.BatchWriteError
+	var re *libdy.RetryError
+	err = c.BatchPutItems(ctx, numbered(25), libdy.WithRetrier(libdy.ConstantBackoff(time.Millisecond, 1)))
+	if !errors.As(err, &bwe) || bwe.Total != 25 || len(bwe.Failures) != 5 || !errors.As(err, &re) {
+		t.Fatalf("BatchPutItems past the retries: %v, want 5 of 25 failed", err)
+	}
+
+	if got := aws.StringValue(bwe.Failures[0].Item()["id"].S); got != "20" {
+		t.Errorf("first failure %s, want 20", got)
+	}
+}
+
+func TestBatchWriteDuplicates(t *testing.T) {
+	ctx := context.Background()
+	f := libdytest.SetupFake(t, libdy.TableDef{Name: "t", PK: "id"})
+	c := libdy.New(f, libdy.WithTable("t"))
+	item := func(id, v string) map[string]*dynamodb.AttributeValue {
+		return map[string]*dynamodb.AttributeValue{"id": {S: aws.String(id)}, "v": {S: aws.String(v)}}
+	}
+
+	// Writes to the same item collapse to the last one.
+	if err := c.BatchPutItems(ctx, append(numbered(30), item("1", "a"), item("1", "b"))); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := c.GetItem(ctx, "id:1", "")
+	if err != nil || aws.StringValue(got["v"].S) != "b" || len(f.Items("t")) != 30 {
+		t.Errorf("1 = %v, %v, want the last of its writes", got, err)
+	}
+
+	// An item without its key is left for DynamoDB to reject.
+	err = c.BatchPutItems(ctx, []map[string]*dynamodb.AttributeValue{item("2", "a"), {"v": {S: aws.String("a")}}})
+	if !errors.Is(err, libdy.ErrInvalidRequest) {
+		t.Errorf("BatchPutItems of an item without its key: %v, want ErrInvalidRequest", err)
+	}
+}
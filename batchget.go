@@ -0,0 +1,200 @@
+package libdy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/cenkalti/backoff"
+)
+
+// BatchGetItem accepts at most 100 keys per call.
+const batchGetMax = 100
+
+// WithConsistentRead requests strongly consistent reads. Queries and scans of
+// secondary indexes, which may not support them, stay eventually consistent.
+func WithConsistentRead() Option {
+	return func(o *options) { o.consistent = true }
+}
+
+// WithProjection limits reads (GetItem, BatchGetItems, queries, and scans) to
+// the given top-level attributes. Names are aliased automatically, so
+// reserved words like "name" or "status" are fine.
+func WithProjection(attrs ...string) Option {
+	return func(o *options) { o.projection = attrs }
+}
+
+// projection returns the ProjectionExpression and ExpressionAttributeNames
+// for attrs, or nils if attrs is empty.
+func projection(attrs []string) (*string, map[string]*string) {
+	if len(attrs) == 0 {
+		return nil, nil
+	}
+
+	names := make(map[string]*string, len(attrs))
+	parts := make([]string, len(attrs))
+	for i, a := range attrs {
+		alias := fmt.Sprintf("#p%d", i)
+		names[alias] = aws.String(a)
+		parts[i] = alias
+	}
+
+	return aws.String(strings.Join(parts, ", ")), names
+}
+
+// applyProjection adds the projection of o, if any, to a Query or Scan
+// input.
+func (o options) applyProjection(expr **string, names *map[string]*string) {
+	proj, pnames := projection(o.projection)
+	if proj == nil {
+		return
+	}
+
+	*expr = proj
+	for k, v := range pnames {
+		if *names == nil {
+			*names = map[string]*string{}
+		}
+
+		(*names)[k] = v
+	}
+}
+
+func BatchGetItems(svc dynamodbiface.DynamoDBAPI, table string, keys []map[string]*dynamodb.AttributeValue, opts ...Option) ([]map[string]*dynamodb.AttributeValue, error) {
+	return BatchGetItemsWithContext(context.Background(), svc, table, keys, opts...)
+}
+
+// BatchGetItemsWithContext fetches the items with the given primary keys, in
+// chunks of 100, retrying throttled calls and unprocessed keys with backoff.
+// Items that don't exist are simply absent from the result, which is not in
+// any particular order. Supports WithConsistentRead and WithProjection.
+func BatchGetItemsWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, keys []map[string]*dynamodb.AttributeValue, opts ...Option) ([]map[string]*dynamodb.AttributeValue, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return batchGet(ctx, svc, table, keys, o)
+}
+
+func batchGet(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, keys []map[string]*dynamodb.AttributeValue, o options) ([]map[string]*dynamodb.AttributeValue, error) {
+	ret := []map[string]*dynamodb.AttributeValue{}
+	proj, names := projection(o.projection)
+	for i := 0; i < len(keys); i += batchGetMax {
+		end := i + batchGetMax
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		ka := &dynamodb.KeysAndAttributes{
+			Keys:                     keys[i:end],
+			ProjectionExpression:     proj,
+			ExpressionAttributeNames: names,
+		}
+
+		if o.consistent {
+			ka.ConsistentRead = aws.Bool(true)
+		}
+
+		items, err := batchGetChunk(ctx, svc, table, ka, o)
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, items...)
+	}
+
+	return ret, nil
+}
+
+// batchGetChunk reads up to 100 keys, resubmitting unprocessed keys with
+// exponential backoff until they are all read or the backoff gives up.
+func batchGetChunk(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, ka *dynamodb.KeysAndAttributes, o options) ([]map[string]*dynamodb.AttributeValue, error) {
+	start := time.Now()
+	ret := []map[string]*dynamodb.AttributeValue{}
+	pending := ka
+	b := o.backOff(ctx)
+	attempts := 0
+	for {
+		attempts++
+		var rerr, err error
+		var res *dynamodb.BatchGetItemOutput
+
+		reqStart := time.Now()
+
+		// Our retriable, backoff-able function.
+		op := func(ctx context.Context) error {
+			res, err = svc.BatchGetItemWithContext(ctx, &dynamodb.BatchGetItemInput{
+				RequestItems:           map[string]*dynamodb.KeysAndAttributes{table: pending},
+				ReturnConsumedCapacity: o.returnCapacity(),
+			})
+
+			rerr = err
+			return o.retriable(err)
+		}
+
+		tries, err := retryN(ctx, o, "BatchGetItem", op)
+		o.observe(ctx, "BatchGetItem", table, reqStart, tries, res, err, rerr)
+		if (err != nil || rerr != nil) && ctx.Err() != nil {
+			return nil, fmt.Errorf("BatchGetItem canceled after %v: %w", time.Since(start), ctx.Err())
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("BatchGetItem failed after %v: %w", time.Since(start), err)
+		}
+
+		if rerr != nil {
+			return nil, fmt.Errorf("BatchGetItem failed: %w", awsErr(rerr))
+		}
+
+		ret = append(ret, res.Responses[table]...)
+		pending = res.UnprocessedKeys[table]
+		if pending == nil || len(pending.Keys) == 0 {
+			return ret, nil
+		}
+
+		next := b.NextBackOff()
+		if next != backoff.Stop && ctx.Err() == nil {
+			select {
+			case <-ctx.Done():
+			case <-o.after(next):
+			}
+		}
+
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("BatchGetItem canceled after %v: %w", time.Since(start), ctx.Err())
+		}
+
+		if next == backoff.Stop {
+			err = &RetryError{
+				Err:       fmt.Errorf("%d unprocessed key(s)", len(pending.Keys)),
+				Attempts:  attempts,
+				Truncated: b.truncated,
+			}
+
+			return nil, fmt.Errorf("BatchGetItem failed after %v: %w", time.Since(start), err)
+		}
+	}
+}
+
+func (c *Client) BatchGetItems(ctx context.Context, keys []map[string]*dynamodb.AttributeValue, opts ...Option) ([]map[string]*dynamodb.AttributeValue, error) {
+	o, err := c.apply(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := batchGet(ctx, c.reader(o), o.table, keys, o)
+	if err != nil {
+		return nil, err
+	}
+
+	if items, err = o.read(ctx, items); err != nil {
+		return nil, err
+	}
+
+	return o.authorize(ctx, o.table, items)
+}
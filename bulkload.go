@@ -0,0 +1,132 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// BulkLoadConfig configures a BulkLoad.
+type BulkLoadConfig struct {
+	Workers int     // concurrent BatchWriteItem calls; the default is 4
+	WCU     float64 // write capacity units per second to stay under; 0 means no limit
+
+	// Progress, if set, is called after each batch with the items written
+	// and failed so far. It may be called concurrently.
+	Progress func(written, failed int64)
+
+	// OnFailed, if set, is called with the writes that failed for good, as
+	// they fail. It may be called concurrently.
+	OnFailed func(failed []WriteFailure)
+}
+
+// BulkLoadStats summarizes a BulkLoad.
+type BulkLoadStats struct {
+	Written  int64
+	Failed   int64
+	Duration time.Duration
+}
+
+func BulkLoad(svc dynamodbiface.DynamoDBAPI, table string, items <-chan map[string]*dynamodb.AttributeValue, cfg BulkLoadConfig, opts ...Option) (*BulkLoadStats, error) {
+	return BulkLoadWithContext(context.Background(), svc, table, items, cfg, opts...)
+}
+
+// BulkLoadWithContext is Client.BulkLoad for table.
+func BulkLoadWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, items <-chan map[string]*dynamodb.AttributeValue, cfg BulkLoadConfig, opts ...Option) (*BulkLoadStats, error) {
+	return New(svc, WithTable(table)).BulkLoad(ctx, items, cfg, opts...)
+}
+
+// BulkLoad writes the items received from items into the Client's table
+// until it is closed, for one-off data loads: items are batched as with a
+// Writer, and written by cfg.Workers at once, within cfg.WCU (or the limit
+// of WithWriteCapacity in opts). It returns once everything received is
+// written, with a summary; if any writes failed, the error is a
+// *BatchWriteError listing them. If ctx is done first, BulkLoad stops
+// receiving and returns ctx.Err(), with what was written so far.
+//
+//	items := make(chan map[string]*dynamodb.AttributeValue)
+//	go func() {
+//		defer close(items)
+//		for _, rec := range records {
+//			items <- toItem(rec)
+//		}
+//	}()
+//
+//	stats, err := client.BulkLoad(ctx, items, libdy.BulkLoadConfig{
+//		Workers:  16,
+//		WCU:      500,
+//		Progress: func(written, failed int64) { log.Println(written, failed) },
+//	})
+func (c *Client) BulkLoad(ctx context.Context, items <-chan map[string]*dynamodb.AttributeValue, cfg BulkLoadConfig, opts ...Option) (*BulkLoadStats, error) {
+	start := time.Now()
+	stats := &BulkLoadStats{}
+	if cfg.WCU > 0 {
+		opts = append(opts[:len(opts):len(opts)], WithWriteCapacity(NewCapacityLimiter(cfg.WCU)))
+	}
+
+	progress := func() {
+		if cfg.Progress != nil {
+			cfg.Progress(atomic.LoadInt64(&stats.Written), atomic.LoadInt64(&stats.Failed))
+		}
+	}
+
+	wcfg := WriterConfig{
+		Concurrency: cfg.Workers,
+		OnError: func(failed []WriteFailure) {
+			atomic.AddInt64(&stats.Failed, int64(len(failed)))
+			if cfg.OnFailed != nil {
+				cfg.OnFailed(failed)
+			}
+
+			progress()
+		},
+	}
+
+	w := c.newWriter(wcfg, func(n int64) {
+		atomic.AddInt64(&stats.Written, n)
+		progress()
+	}, opts)
+
+	err := func() error {
+		for {
+			select {
+			case item, ok := <-items:
+				if !ok {
+					return nil
+				}
+
+				if err := w.Put(ctx, item); err != nil {
+					return err
+				}
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}()
+
+	if cerr := w.Close(ctx); err == nil {
+		err = cerr
+	}
+
+	// After ctx is done, writes may still land in the background.
+	stats = &BulkLoadStats{
+		Written:  atomic.LoadInt64(&stats.Written),
+		Failed:   atomic.LoadInt64(&stats.Failed),
+		Duration: time.Since(start),
+	}
+
+	var berr *BatchWriteError
+	switch {
+	case err == nil:
+		return stats, nil
+	case errors.As(err, &berr):
+		return stats, err
+	}
+
+	return stats, fmt.Errorf("BulkLoad failed: %w", err)
+}
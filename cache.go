@@ -0,0 +1,240 @@
+package libdy
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// ItemCache caches the items read by Client.GetItem (see WithItemCache).
+// Items younger than ttl are served from the cache. With stale-while-
+// revalidate (maxStale > 0), items up to ttl+maxStale old are still served
+// immediately while a single background read refreshes them; older ones are
+// read synchronously. Consistent reads bypass the cache, and PutItem,
+// DeleteItem, UpdateItem, the batch writes, and the transactions through the
+// Client evict the items they write. When full, the least recently read
+// item is evicted.
+//
+// Hits and misses are reported through WithMetrics as MetricCacheHits and
+// MetricCacheMisses, and counted in Stats.
+type ItemCache struct {
+	ttl      time.Duration
+	maxStale time.Duration
+	size     int
+	mu       sync.Mutex
+	entries  map[string]*list.Element // of *cacheEntry
+	lru      *list.List               // most recently read first
+	keys     map[string][]string      // key attribute names per table
+	stats    CacheStats
+}
+
+type cacheEntry struct {
+	id         string
+	item       map[string]*dynamodb.AttributeValue // nil if not found
+	at         time.Time
+	refreshing bool
+}
+
+// CacheStats are the counters of an ItemCache.
+type CacheStats struct {
+	Hits      int64 // reads served from the cache, stale ones included
+	Misses    int64 // reads that went to DynamoDB
+	Evictions int64 // items evicted to make room
+	Size      int   // items cached
+}
+
+// NewItemCache returns a cache of up to size items (default 10000).
+func NewItemCache(ttl, maxStale time.Duration, size int) *ItemCache {
+	if size <= 0 {
+		size = 10000
+	}
+
+	return &ItemCache{
+		ttl:      ttl,
+		maxStale: maxStale,
+		size:     size,
+		entries:  map[string]*list.Element{},
+		lru:      list.New(),
+		keys:     map[string][]string{},
+	}
+}
+
+// Stats returns the counters of c.
+func (c *ItemCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ret := c.stats
+	ret.Size = c.lru.Len()
+	return ret
+}
+
+// WithItemCache makes Client.GetItem read through c.
+func WithItemCache(c *ItemCache) Option {
+	return func(o *options) { o.cache = c }
+}
+
+// cacheID returns the cache key of the item with the given key in table.
+func cacheID(table string, key map[string]*dynamodb.AttributeValue) string {
+	names := make([]string, 0, len(key))
+	for k := range key {
+		names = append(names, k)
+	}
+
+	sort.Strings(names)
+	id := []string{table}
+	for _, k := range names {
+		id = append(id, k, key[k].String())
+	}
+
+	return strings.Join(id, "\x00")
+}
+
+// get returns the item with the given key, reading it with read if it isn't
+// cached, or is too stale to serve, and whether it was served from c.
+func (c *ItemCache) get(ctx context.Context, table string, key map[string]*dynamodb.AttributeValue, read func(context.Context) (map[string]*dynamodb.AttributeValue, error)) (map[string]*dynamodb.AttributeValue, bool, error) {
+	id := cacheID(table, key)
+	c.mu.Lock()
+	if el, ok := c.entries[id]; ok {
+		e := el.Value.(*cacheEntry)
+		age := time.Since(e.at)
+		switch {
+		case age < c.ttl:
+			c.lru.MoveToFront(el)
+			c.stats.Hits++
+			c.mu.Unlock()
+			return e.item, true, nil
+		case age < c.ttl+c.maxStale:
+			if !e.refreshing {
+				e.refreshing = true
+				go c.refresh(context.WithoutCancel(ctx), table, id, key, read)
+			}
+
+			c.lru.MoveToFront(el)
+			c.stats.Hits++
+			c.mu.Unlock()
+			return e.item, true, nil
+		}
+	}
+
+	c.stats.Misses++
+	c.mu.Unlock()
+	item, err := c.refresh(ctx, table, id, key, read)
+	return item, false, err
+}
+
+func (c *ItemCache) refresh(ctx context.Context, table, id string, key map[string]*dynamodb.AttributeValue, read func(context.Context) (map[string]*dynamodb.AttributeValue, error)) (map[string]*dynamodb.AttributeValue, error) {
+	item, err := read(ctx)
+	if errors.Is(err, ErrItemNotFound) {
+		item, err = nil, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[id]
+	if err != nil {
+		if ok {
+			el.Value.(*cacheEntry).refreshing = false // let the next read retry
+		}
+
+		return nil, err
+	}
+
+	if _, ok := c.keys[table]; !ok {
+		for k := range key {
+			c.keys[table] = append(c.keys[table], k)
+		}
+	}
+
+	e := &cacheEntry{id: id, item: item, at: time.Now()}
+	if ok {
+		el.Value = e
+		c.lru.MoveToFront(el)
+		return item, nil
+	}
+
+	if c.lru.Len() >= c.size {
+		c.evictLRU()
+	}
+
+	c.entries[id] = c.lru.PushFront(e)
+	return item, nil
+}
+
+func (c *ItemCache) evictLRU() {
+	el := c.lru.Back()
+	if el == nil {
+		return
+	}
+
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*cacheEntry).id)
+	c.stats.Evictions++
+}
+
+// invalidate evicts the item with the key of item (a key or a full item).
+// A nil cache does nothing.
+func (c *ItemCache) invalidate(table string, item map[string]*dynamodb.AttributeValue) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names, ok := c.keys[table]
+	if !ok {
+		return // nothing cached for table
+	}
+
+	key := map[string]*dynamodb.AttributeValue{}
+	for _, k := range names {
+		if v, ok := item[k]; ok {
+			key[k] = v
+		}
+	}
+
+	id := cacheID(table, key)
+	if el, ok := c.entries[id]; ok {
+		c.lru.Remove(el)
+		delete(c.entries, id)
+	}
+}
+
+// invalidateTx evicts the items written by ops.
+func (c *ItemCache) invalidateTx(ops []TxOp) {
+	if c == nil {
+		return
+	}
+
+	for _, op := range ops {
+		switch w := op.item; {
+		case w == nil:
+		case w.Put != nil:
+			c.invalidate(aws.StringValue(w.Put.TableName), w.Put.Item)
+		case w.Update != nil:
+			c.invalidate(aws.StringValue(w.Update.TableName), w.Update.Key)
+		case w.Delete != nil:
+			c.invalidate(aws.StringValue(w.Delete.TableName), w.Delete.Key)
+		}
+	}
+}
+
+// copyItem returns a shallow copy of item.
+func copyItem(item map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	if item == nil {
+		return nil
+	}
+
+	ret := make(map[string]*dynamodb.AttributeValue, len(item))
+	for k, v := range item {
+		ret[k] = v
+	}
+
+	return ret
+}
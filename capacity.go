@@ -0,0 +1,142 @@
+package libdy
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// CapacityLimiter keeps the operations sharing it under a budget of capacity
+// units per second, based on the capacity DynamoDB reports as consumed
+// (ReturnConsumedCapacity) rather than on item counts. An operation waits
+// while the budget is overdrawn, then runs and is charged its actual cost,
+// so a large page can briefly exceed the budget but is paid back before the
+// next request. Bursts are capped at one second of budget.
+type CapacityLimiter struct {
+	rate   float64
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewCapacityLimiter returns a limiter allowing units capacity units per
+// second.
+func NewCapacityLimiter(units float64) *CapacityLimiter {
+	return &CapacityLimiter{rate: units, tokens: units, last: time.Now()}
+}
+
+// WithReadCapacity limits the reads of Query, Scan, and GetItem (including
+// iterators and ParallelScan) to the budget of l, e.g. for a background job
+// that must not starve production traffic:
+//
+//	rcu := libdy.NewCapacityLimiter(100)
+//	items, err := client.ParallelScan(ctx, 8, libdy.WithReadCapacity(rcu))
+func WithReadCapacity(l *CapacityLimiter) Option {
+	return func(o *options) { o.readLimit = l }
+}
+
+// WithWriteCapacity limits the writes of PutItem, UpdateItem, DeleteItem,
+// and the batch writes to the budget of l.
+func WithWriteCapacity(l *CapacityLimiter) Option {
+	return func(o *options) { o.writeLimit = l }
+}
+
+// refill adds the budget accrued since the last call. l.mu must be held.
+func (l *CapacityLimiter) refill() {
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+
+	l.last = now
+}
+
+// wait blocks until the budget is no longer overdrawn. A nil limiter never
+// waits.
+func (l *CapacityLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	for {
+		l.mu.Lock()
+		l.refill()
+		if l.tokens > 0 {
+			l.mu.Unlock()
+			return nil
+		}
+
+		d := time.Duration(-l.tokens / l.rate * float64(time.Second))
+		l.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d + time.Millisecond):
+		}
+	}
+}
+
+// consume charges units to the budget.
+func (l *CapacityLimiter) consume(units float64) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+	l.tokens -= units
+}
+
+// Capacity is the capacity consumed by a call (see WithConsumedCapacity).
+type Capacity struct {
+	Table string
+	Op    string  // the DynamoDB API, e.g. GetItem, Query, ExecuteStatement
+	Units float64 // read or write capacity units
+	Pages int     // requests, for Query and Scan
+}
+
+// WithConsumedCapacity requests the consumed capacity of every operation and
+// reports it to fn, once per call: a Query or Scan reports the sum over its
+// pages (also in Result.ConsumedCapacity), while page iterators and
+// ParallelScan report each page. fn may be called concurrently.
+//
+//	libdy.WithConsumedCapacity(func(c libdy.Capacity) {
+//		log.Printf("%s on %s: %.1f units", c.Op, c.Table, c.Units)
+//	})
+func WithConsumedCapacity(fn func(Capacity)) Option {
+	return func(o *options) { o.onCapacity = fn }
+}
+
+// returnCapacity returns the ReturnConsumedCapacity setting for o.
+func (o options) returnCapacity() *string {
+	if o.onCapacity == nil && o.onOp == nil &&
+		o.readLimit == nil && o.writeLimit == nil && (o.cost == nil || o.cost.Units == 0) {
+		return nil
+	}
+
+	return aws.String(dynamodb.ReturnConsumedCapacityTotal)
+}
+
+// capacityUnits returns the total units of cc.
+func capacityUnits(cc ...*dynamodb.ConsumedCapacity) float64 {
+	var units float64
+	for _, c := range cc {
+		if c != nil {
+			units += aws.Float64Value(c.CapacityUnits)
+		}
+	}
+
+	return units
+}
+
+// reportCapacity reports the capacity of a call to the observer of o.
+func (o options) reportCapacity(table, op string, units float64, pages int) {
+	if o.onCapacity != nil {
+		o.onCapacity(Capacity{Table: table, Op: op, Units: units, Pages: pages})
+	}
+}
@@ -0,0 +1,179 @@
+package libdy
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// CapturedRequest is a request recorded by a Recorder.
+type CapturedRequest struct {
+	Operation string      // e.g. "Query"
+	Input     interface{} // e.g. *dynamodb.QueryInput
+}
+
+// JSON returns the input of r as sent on the wire, in DynamoDB JSON, e.g.
+// for golden tests of the expressions built by libdy. The body is built by
+// the SDK, as for a request, which is never sent.
+func (r CapturedRequest) JSON() ([]byte, error) {
+	svc, err := wireClient()
+	if err != nil {
+		return nil, fmt.Errorf("JSON failed: %w", err)
+	}
+
+	req := svc.NewRequest(&request.Operation{Name: r.Operation, HTTPMethod: "POST", HTTPPath: "/"}, r.Input, nil)
+	req.Handlers.Validate.Clear() // the input as recorded, valid or not
+	if err := req.Build(); err != nil {
+		return nil, fmt.Errorf("JSON failed: %w", err)
+	}
+
+	return io.ReadAll(req.GetBody())
+}
+
+// wireClient returns the DynamoDB client building the bodies of
+// CapturedRequest.JSON.
+var wireClient = sync.OnceValues(func() (*dynamodb.DynamoDB, error) {
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.AnonymousCredentials,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return dynamodb.New(sess), nil
+})
+
+// Recorder is a dynamodbiface.DynamoDBAPI recording the item, query, scan,
+// batch, transaction, and PartiQL requests sent through it, to debug the
+// inputs libdy builds:
+//
+//	rec := libdy.NewRecorder(nil) // dry run
+//	client := libdy.New(rec, libdy.WithTable("orders"))
+//	client.Query(ctx, "customer:c1", "", libdy.WithFilter(filter))
+//	b, _ := rec.Requests()[0].JSON()
+//
+// With a service, requests are sent to it after being recorded. Without one,
+// it's a dry run: nothing is sent, and the requests succeed with empty
+// outputs, so reads find no items. Other operations always go to the
+// service, so they can't be called in a dry run.
+type Recorder struct {
+	dynamodbiface.DynamoDBAPI // nil in a dry run
+
+	mu       sync.Mutex
+	requests []CapturedRequest
+}
+
+// NewRecorder returns a Recorder sending the requests to svc, or none if
+// svc is nil.
+func NewRecorder(svc dynamodbiface.DynamoDBAPI) *Recorder {
+	return &Recorder{DynamoDBAPI: svc}
+}
+
+// Requests returns the requests recorded so far, in order.
+func (r *Recorder) Requests() []CapturedRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]CapturedRequest(nil), r.requests...)
+}
+
+// Reset forgets the requests recorded so far.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = nil
+}
+
+// capture records the request op with in, then sends it with call, unless
+// r is a dry run.
+func capture[In, Out any](r *Recorder, op string, in *In, call func(svc dynamodbiface.DynamoDBAPI) (*Out, error)) (*Out, error) {
+	cp := *in // as pages reuse the input
+	r.mu.Lock()
+	r.requests = append(r.requests, CapturedRequest{Operation: op, Input: &cp})
+	r.mu.Unlock()
+	if r.DynamoDBAPI == nil {
+		return new(Out), nil
+	}
+
+	return call(r.DynamoDBAPI)
+}
+
+func (r *Recorder) GetItemWithContext(ctx aws.Context, in *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	return capture(r, "GetItem", in, func(svc dynamodbiface.DynamoDBAPI) (*dynamodb.GetItemOutput, error) {
+		return svc.GetItemWithContext(ctx, in, opts...)
+	})
+}
+
+func (r *Recorder) PutItemWithContext(ctx aws.Context, in *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	return capture(r, "PutItem", in, func(svc dynamodbiface.DynamoDBAPI) (*dynamodb.PutItemOutput, error) {
+		return svc.PutItemWithContext(ctx, in, opts...)
+	})
+}
+
+func (r *Recorder) UpdateItemWithContext(ctx aws.Context, in *dynamodb.UpdateItemInput, opts ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	return capture(r, "UpdateItem", in, func(svc dynamodbiface.DynamoDBAPI) (*dynamodb.UpdateItemOutput, error) {
+		return svc.UpdateItemWithContext(ctx, in, opts...)
+	})
+}
+
+func (r *Recorder) DeleteItemWithContext(ctx aws.Context, in *dynamodb.DeleteItemInput, opts ...request.Option) (*dynamodb.DeleteItemOutput, error) {
+	return capture(r, "DeleteItem", in, func(svc dynamodbiface.DynamoDBAPI) (*dynamodb.DeleteItemOutput, error) {
+		return svc.DeleteItemWithContext(ctx, in, opts...)
+	})
+}
+
+func (r *Recorder) QueryWithContext(ctx aws.Context, in *dynamodb.QueryInput, opts ...request.Option) (*dynamodb.QueryOutput, error) {
+	return capture(r, "Query", in, func(svc dynamodbiface.DynamoDBAPI) (*dynamodb.QueryOutput, error) {
+		return svc.QueryWithContext(ctx, in, opts...)
+	})
+}
+
+func (r *Recorder) ScanWithContext(ctx aws.Context, in *dynamodb.ScanInput, opts ...request.Option) (*dynamodb.ScanOutput, error) {
+	return capture(r, "Scan", in, func(svc dynamodbiface.DynamoDBAPI) (*dynamodb.ScanOutput, error) {
+		return svc.ScanWithContext(ctx, in, opts...)
+	})
+}
+
+func (r *Recorder) BatchGetItemWithContext(ctx aws.Context, in *dynamodb.BatchGetItemInput, opts ...request.Option) (*dynamodb.BatchGetItemOutput, error) {
+	return capture(r, "BatchGetItem", in, func(svc dynamodbiface.DynamoDBAPI) (*dynamodb.BatchGetItemOutput, error) {
+		return svc.BatchGetItemWithContext(ctx, in, opts...)
+	})
+}
+
+func (r *Recorder) BatchWriteItemWithContext(ctx aws.Context, in *dynamodb.BatchWriteItemInput, opts ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
+	return capture(r, "BatchWriteItem", in, func(svc dynamodbiface.DynamoDBAPI) (*dynamodb.BatchWriteItemOutput, error) {
+		return svc.BatchWriteItemWithContext(ctx, in, opts...)
+	})
+}
+
+func (r *Recorder) TransactGetItemsWithContext(ctx aws.Context, in *dynamodb.TransactGetItemsInput, opts ...request.Option) (*dynamodb.TransactGetItemsOutput, error) {
+	return capture(r, "TransactGetItems", in, func(svc dynamodbiface.DynamoDBAPI) (*dynamodb.TransactGetItemsOutput, error) {
+		return svc.TransactGetItemsWithContext(ctx, in, opts...)
+	})
+}
+
+func (r *Recorder) TransactWriteItemsWithContext(ctx aws.Context, in *dynamodb.TransactWriteItemsInput, opts ...request.Option) (*dynamodb.TransactWriteItemsOutput, error) {
+	return capture(r, "TransactWriteItems", in, func(svc dynamodbiface.DynamoDBAPI) (*dynamodb.TransactWriteItemsOutput, error) {
+		return svc.TransactWriteItemsWithContext(ctx, in, opts...)
+	})
+}
+
+func (r *Recorder) ExecuteStatementWithContext(ctx aws.Context, in *dynamodb.ExecuteStatementInput, opts ...request.Option) (*dynamodb.ExecuteStatementOutput, error) {
+	return capture(r, "ExecuteStatement", in, func(svc dynamodbiface.DynamoDBAPI) (*dynamodb.ExecuteStatementOutput, error) {
+		return svc.ExecuteStatementWithContext(ctx, in, opts...)
+	})
+}
+
+func (r *Recorder) BatchExecuteStatementWithContext(ctx aws.Context, in *dynamodb.BatchExecuteStatementInput, opts ...request.Option) (*dynamodb.BatchExecuteStatementOutput, error) {
+	return capture(r, "BatchExecuteStatement", in, func(svc dynamodbiface.DynamoDBAPI) (*dynamodb.BatchExecuteStatementOutput, error) {
+		return svc.BatchExecuteStatementWithContext(ctx, in, opts...)
+	})
+}
@@ -0,0 +1,45 @@
+package libdy_test
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/flowerinthenight/libdy"
+)
+
+func TestCapturedJSON(t *testing.T) {
+	rec := libdy.NewRecorder(nil)
+	c := libdy.New(rec, libdy.WithTable("orders"))
+	if _, err := c.GetItem(context.Background(), "customer:c1", "at:2024"); err == nil {
+		t.Fatal("GetItem in a dry run found an item")
+	}
+
+	reqs := rec.Requests()
+	if len(reqs) != 1 || reqs[0].Operation != "GetItem" {
+		t.Fatalf("requests %+v, want one GetItem", reqs)
+	}
+
+	b, err := reqs[0].JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{
+		"TableName": "orders",
+		"Key": map[string]interface{}{
+			"customer": map[string]interface{}{"S": "c1"},
+			"at":       map[string]interface{}{"S": "2024"},
+		},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("JSON = %s, want %v", b, want)
+	}
+}
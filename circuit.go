@@ -0,0 +1,172 @@
+package libdy
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrCircuitOpen matches (with errors.Is) requests failed fast because
+	// the CircuitBreaker of their table is open.
+	ErrCircuitOpen = errors.New("libdy: circuit open")
+)
+
+// CircuitState is the state of a CircuitBreaker on a table.
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"    // requests go through
+	CircuitOpen     CircuitState = "open"      // requests fail fast
+	CircuitHalfOpen CircuitState = "half_open" // probe requests go through
+)
+
+// CircuitBreaker fails requests to a table fast, with ErrCircuitOpen, once
+// it has seen a number of consecutive server errors or timeouts from it, as
+// during a regional outage, instead of having every caller wait out its
+// retries. After a cooldown, it lets a probe request through: if it
+// succeeds, the circuit closes again; if not, it stays open for another
+// cooldown. Throttling and client errors don't count, and neither does a
+// successful request from another Client sharing it.
+//
+//	breaker := libdy.NewCircuitBreaker(10, 30*time.Second)
+//	client := libdy.New(svc, libdy.WithCircuitBreaker(breaker))
+//
+// Opening the circuit is reported as EventCircuitOpen (see WithEvents).
+type CircuitBreaker struct {
+	failures int
+	cooldown time.Duration
+	clock    Clock
+	mu       sync.Mutex
+	tables   map[string]*circuit
+}
+
+type circuit struct {
+	failures int
+	until    time.Time // end of the cooldown, if open
+	probing  bool
+}
+
+// NewCircuitBreaker returns a breaker opening the circuit of a table after
+// failures consecutive failed attempts, for cooldown.
+func NewCircuitBreaker(failures int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		failures: failures,
+		cooldown: cooldown,
+		tables:   map[string]*circuit{},
+	}
+}
+
+// WithClock sets the time source of the cooldowns of b, SystemClock by
+// default, and returns b. A breaker is shared by Clients, so it doesn't
+// follow theirs.
+func (b *CircuitBreaker) WithClock(c Clock) *CircuitBreaker {
+	b.clock = c
+	return b
+}
+
+func (b *CircuitBreaker) now() time.Time {
+	if b.clock == nil {
+		return time.Now()
+	}
+
+	return b.clock.Now()
+}
+
+// WithCircuitBreaker guards the requests of the Client with b.
+func WithCircuitBreaker(b *CircuitBreaker) Option {
+	return func(o *options) { o.breaker = b }
+}
+
+// State returns the state of the circuit of table.
+func (b *CircuitBreaker) State(table string) CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.tables[table]
+	switch {
+	case !ok || c.until.IsZero():
+		return CircuitClosed
+	case c.probing || !b.now().Before(c.until):
+		return CircuitHalfOpen
+	}
+
+	return CircuitOpen
+}
+
+// allow reports whether a request to table may go, and whether it's a
+// probe. A nil CircuitBreaker allows all.
+func (b *CircuitBreaker) allow(table string) (probe bool, err error) {
+	if b == nil {
+		return false, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.tables[table]
+	if !ok || c.until.IsZero() {
+		return false, nil
+	}
+
+	if c.probing || b.now().Before(c.until) {
+		return false, fmt.Errorf("table %s: %w", table, ErrCircuitOpen)
+	}
+
+	c.probing = true
+	return true, nil
+}
+
+// release gives back the probe granted by allow to a request that didn't
+// go, so the next request may probe instead.
+func (b *CircuitBreaker) release(table string, probe bool) {
+	if b == nil || !probe {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c, ok := b.tables[table]; ok {
+		c.probing = false
+	}
+}
+
+// record records the result of a request to table, err being nil unless
+// retryable, and reports whether it opened the circuit, and after how many
+// consecutive failures.
+func (b *CircuitBreaker) record(table string, probe bool, err error) (opened bool, failures int) {
+	if b == nil {
+		return false, 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.tables[table]
+	if !ok {
+		c = &circuit{}
+		b.tables[table] = c
+	}
+
+	if probe {
+		c.probing = false
+	}
+
+	if !isOutage(err) {
+		if probe || c.until.IsZero() {
+			c.failures, c.until = 0, time.Time{}
+		}
+
+		return false, c.failures
+	}
+
+	c.failures++
+	switch {
+	case probe:
+		c.until = b.now().Add(b.cooldown)
+		return false, c.failures // still open
+	case c.until.IsZero() && c.failures >= b.failures:
+		c.until = b.now().Add(b.cooldown)
+		return true, c.failures
+	}
+
+	return false, c.failures
+}
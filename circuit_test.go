@@ -0,0 +1,129 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// gate is a RetryGate not retrying, whose Before fails with err.
+type gate struct{ err error }
+
+func (g *gate) Next(RetryState) (time.Duration, bool) { return 0, false }
+func (g *gate) Before(context.Context) error          { return g.err }
+func (g *gate) After(error)                           {}
+
+func TestCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	b := NewCircuitBreaker(2, 0)
+	g := &gate{}
+	o, err := New(nil, WithTable("t"), WithCircuitBreaker(b), WithRetrier(g)).apply(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outage := awserr.New(dynamodb.ErrCodeInternalServerError, "down", nil)
+	fail := func(context.Context) error { return outage }
+	ok := func(context.Context) error { return nil }
+	for i := 0; i < 2; i++ {
+		if _, err := retryN(ctx, o, "op", fail); errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("attempt %d: %v, want the request to go", i, err)
+		}
+	}
+
+	if got := b.State("t"); got != CircuitHalfOpen { // the cooldown is 0
+		t.Fatalf("State = %s, want %s", got, CircuitHalfOpen)
+	}
+
+	// A probe the gate holds back doesn't keep the circuit probing.
+	g.err = errors.New("gated")
+	if _, err := retryN(ctx, o, "op", ok); !errors.Is(err, g.err) {
+		t.Fatalf("gated probe: %v, want %v", err, g.err)
+	}
+
+	g.err = nil
+	if _, err := retryN(ctx, o, "op", ok); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+
+	if got := b.State("t"); got != CircuitClosed {
+		t.Errorf("State = %s, want %s", got, CircuitClosed)
+	}
+}
+
+func TestCircuitBreakerRace(t *testing.T) {
+	b := NewCircuitBreaker(1, time.Hour)
+	o, err := New(nil, WithTable("t"), WithCircuitBreaker(b), WithRetrier(&gate{})).apply(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outage := awserr.New(dynamodb.ErrCodeInternalServerError, "down", nil)
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			retryN(context.Background(), o, "op", func(context.Context) error { return outage })
+		}()
+	}
+
+	for i := 0; i < 4; i++ {
+		<-done
+	}
+
+	if got := b.State("t"); got != CircuitOpen {
+		t.Errorf("State = %s, want %s", got, CircuitOpen)
+	}
+}
+
+func TestCircuitBreakerClock(t *testing.T) {
+	clock := NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	b := NewCircuitBreaker(1, time.Minute).WithClock(clock)
+	b.record("t", false, awserr.New(dynamodb.ErrCodeInternalServerError, "down", nil))
+	if got := b.State("t"); got != CircuitOpen {
+		t.Fatalf("State = %s, want %s", got, CircuitOpen)
+	}
+
+	if _, err := b.allow("t"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow = %v, want ErrCircuitOpen", err)
+	}
+
+	clock.Advance(time.Minute)
+	if got := b.State("t"); got != CircuitHalfOpen {
+		t.Errorf("State after the cooldown = %s, want %s", got, CircuitHalfOpen)
+	}
+
+	if probe, err := b.allow("t"); !probe || err != nil {
+		t.Errorf("allow after the cooldown = %v, %v, want a probe", probe, err)
+	}
+}
+
+func TestRetryBreaker(t *testing.T) {
+	b := NewCircuitBreaker(2, time.Hour)
+	o, err := New(nil, WithTable("t"), WithCircuitBreaker(b), fast(5)).apply(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The breaker opens after the second failure, cutting the retries short.
+	calls := 0
+	n, err := retryN(context.Background(), o, "op", failing(10, &calls))
+	var rerr *RetryError
+	if !errors.Is(err, ErrCircuitOpen) || errors.As(err, &rerr) {
+		t.Fatalf("err = %v, want ErrCircuitOpen, not retried", err)
+	}
+
+	if n != 2 || calls != 2 {
+		t.Errorf("%d attempts, %d calls, want 2", n, calls)
+	}
+
+	// Later operations are refused without an attempt until the cooldown.
+	n, err = retryN(context.Background(), o, "op", failing(0, &calls))
+	if !errors.Is(err, ErrCircuitOpen) || n != 0 || calls != 2 {
+		t.Errorf("open circuit: %d attempts, %d calls, %v, want none and ErrCircuitOpen", n, calls, err)
+	}
+}
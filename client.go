@@ -0,0 +1,362 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+var (
+	ErrNoTable = errors.New("libdy: no table specified")
+)
+
+type options struct {
+	table        string
+	limit        int64
+	pipeline     Pipeline
+	redact       *RedactPolicy
+	svc          svcConfig
+	budget       time.Duration
+	startKey     map[string]*dynamodb.AttributeValue
+	hedge        time.Duration
+	prefetch     bool
+	consistent   bool
+	projection   []string
+	metrics      Metrics
+	condition    *Condition
+	returnValues string
+	oldOnFail    bool // ReturnValuesOnConditionCheckFailure
+	partitions   *partitionLimiter
+	concurrency  int
+	forceDelete  bool
+	pageSize     int64
+	maxItems     int64
+	retry        *RetryPolicy
+	retrier      Retrier
+	callTimeout  time.Duration
+	opTimeout    time.Duration
+	hotKeys      *HotKeys
+	retryable    func(error) bool
+	logger       Logger
+	dryRun       bool
+	scanGuard    *scanGuard
+	scanAck      bool
+	sortKey      *SortKeyCondition
+	cache        *ItemCache
+	filter       *Condition
+	filterErr    error
+	rate         float64
+	derived      []derived
+	schemas      *Schemas
+	readLimit    *CapacityLimiter
+	writeLimit   *CapacityLimiter
+	converters   *Converters
+	latencies    *Latencies
+	onCapacity   func(Capacity)
+	forward      *bool
+	storm        *RetryStorm
+	breaker      *CircuitBreaker
+	index        string
+	events       *Events
+	onOp         func(Operation)
+	onRetry      func(RetryAttempt)
+	pageNum      int
+	aliases      *Aliases
+	snapshot     *snapshot
+	counters     *counterLimits
+	mask         MaskProfile
+	cost         *CostBudget
+	meter        *costMeter // of the batch write in progress
+	authorizer   Authorizer
+	clock        Clock
+	ids          IDGen
+	mustExist    bool
+	fieldNames   func(string) string
+	timeLayout   string
+	encryption   *Encryption
+	offload      *Offload
+	compression  *Compression
+	dax          dynamodbiface.DynamoDBAPI
+	segment      int
+	segments     int // of a parallel scan, per WithSegment
+	progress     func(n int64)
+	sizeCheck    *sizeCheck
+	tries        int // of Mutate and Upsert, per WithConflictTries
+	empty        *EmptyValues
+	until        *readUntil
+	keySchema    *TableSchema
+	retries      *int // of the read in progress, for Result.Retries
+	defaults     *TableDefaults
+}
+
+// Option configures a Client. All options can be set on the Client itself
+// (as defaults) or passed to an individual call to override the defaults.
+type Option func(*options)
+
+// WithTable sets the table name to operate on.
+func WithTable(table string) Option {
+	return func(o *options) { o.table = table }
+}
+
+// WithLimit sets the maximum number of items to return from a read. It is
+// also used as the page size, and the last page is not truncated; see
+// WithMaxItems and WithPageSize for the separate, exact behaviors.
+func WithLimit(limit int64) Option {
+	return func(o *options) { o.limit = limit }
+}
+
+// WithPipeline appends stages to the read result pipeline. Stages set on the
+// Client run before stages set per call.
+func WithPipeline(stages ...Transform) Option {
+	return func(o *options) { o.pipeline = o.pipeline.Then(stages...) }
+}
+
+// WithRedactPolicy sets the policy used by Debug to hide sensitive attributes.
+func WithRedactPolicy(p *RedactPolicy) Option {
+	return func(o *options) { o.redact = p }
+}
+
+// Client is a DynamoDB service handle bundled with default options.
+type Client struct {
+	svc     dynamodbiface.DynamoDBAPI
+	opts    options
+	schemas *schemaCache
+}
+
+// New returns a Client for svc with opts as the defaults of every call. svc
+// is usually a *dynamodb.DynamoDB, but any dynamodbiface.DynamoDBAPI works,
+// such as a mock in tests.
+//
+//	c := libdy.New(svc,
+//		libdy.WithTable("mytable"),
+//		libdy.WithRetryPolicy(libdy.RetryPolicy{MaxRetries: 5}),
+//		libdy.WithLogger(log.Default()))
+func New(svc dynamodbiface.DynamoDBAPI, opts ...Option) *Client {
+	c := &Client{svc: svc, schemas: schemasOf(svc)}
+	for _, opt := range opts {
+		opt(&c.opts)
+	}
+
+	return c
+}
+
+// apply returns a copy of the Client defaults with the table defaults and the
+// per-call options applied.
+func (c *Client) apply(opts []Option) (options, error) {
+	o := c.decoding(opts)
+	o.table = o.aliases.Resolve(o.table)
+	if o.table == "" {
+		return o, ErrNoTable
+	}
+
+	return o, nil
+}
+
+func (o options) limits() []int64 {
+	if o.limit > 0 {
+		return []int64{o.limit}
+	}
+
+	return nil
+}
+
+// Query reads the items under pk (and, optionally, the sk prefix, or the
+// WithSortKey condition), honoring the read options such as WithBudget and
+// WithStartKey.
+func (c *Client) Query(ctx context.Context, pk, sk string, opts ...Option) (*Result, error) {
+	o, err := c.apply(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	kp, ks, err := c.keys(ctx, o, pk, sk)
+	if err != nil {
+		return nil, fmt.Errorf("Query failed: %w", err)
+	}
+
+	return c.query(ctx, kp, ks, o)
+}
+
+func (c *Client) query(ctx context.Context, pk, sk Key, o options) (*Result, error) {
+	if o.until != nil {
+		return pollResult(ctx, o, func(ctx context.Context, o options) (*Result, error) {
+			return c.query(ctx, pk, sk, o)
+		})
+	}
+
+	o.hotKeys.observe(o.table, keyMap(pk, Key{}))
+	res, err := query(ctx, c.reader(o), o.table, o.queryInput(pk, sk), o)
+	if err != nil {
+		return nil, err
+	}
+
+	return o.finish(ctx, res)
+}
+
+// QueryIndex reads the items in the index whose key equals value (and,
+// optionally, whose sort key satisfies the WithSortKey condition). The read
+// options of Query, such as WithLimit, WithFilter, WithStartKey, and
+// WithScanIndexForward, apply as well.
+func (c *Client) QueryIndex(ctx context.Context, index, key, value string, opts ...Option) (*Result, error) {
+	o, err := c.apply(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := c.indexKey(ctx, o, index, key, value)
+	if err != nil {
+		return nil, fmt.Errorf("QueryIndex failed: %w", err)
+	}
+
+	return c.queryIndex(ctx, index, k, o)
+}
+
+func (c *Client) queryIndex(ctx context.Context, index string, key Key, o options) (*Result, error) {
+	if o.until != nil {
+		return pollResult(ctx, o, func(ctx context.Context, o options) (*Result, error) {
+			return c.queryIndex(ctx, index, key, o)
+		})
+	}
+
+	if o.hotKeys != nil {
+		o.hotKeys.Observe(o.table+"/"+index, key.Value)
+	}
+
+	res, err := query(ctx, c.reader(o), o.table, o.indexQueryInput(index, key), o)
+	if err != nil {
+		return nil, err
+	}
+
+	return o.finish(ctx, res)
+}
+
+// Scan reads all the items in the table.
+func (c *Client) Scan(ctx context.Context, opts ...Option) (*Result, error) {
+	o, err := c.apply(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := o.checkScan(ctx, c.svc); err != nil {
+		return nil, err
+	}
+
+	in := o.scanInput()
+	if o.limit > 0 {
+		in.Limit = aws.Int64(o.limit)
+	}
+
+	res, err := scan(ctx, c.reader(o), in, o)
+	if err != nil {
+		return nil, err
+	}
+
+	return o.finish(ctx, res)
+}
+
+// finish runs the codecs, the read pipeline, and the authorizer on res.
+func (o options) finish(ctx context.Context, res *Result) (*Result, error) {
+	items, err := o.read(ctx, res.Items)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Items, err = o.authorize(ctx, o.table, o.checkRead(items)); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (c *Client) GetItems(ctx context.Context, pk, sk string, opts ...Option) ([]map[string]*dynamodb.AttributeValue, error) {
+	res, err := c.Query(ctx, pk, sk, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Items, nil
+}
+
+func (c *Client) GetGsiItems(ctx context.Context, index, key, value string, opts ...Option) ([]map[string]*dynamodb.AttributeValue, error) {
+	res, err := c.QueryIndex(ctx, index, key, value, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Items, nil
+}
+
+func (c *Client) ScanItems(ctx context.Context, opts ...Option) ([]map[string]*dynamodb.AttributeValue, error) {
+	res, err := c.Scan(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Items, nil
+}
+
+func (c *Client) PutItem(ctx context.Context, item map[string]*dynamodb.AttributeValue, opts ...Option) error {
+	o, err := c.apply(opts)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.putItem(ctx, item, o)
+	return err
+}
+
+func (c *Client) putItem(ctx context.Context, item map[string]*dynamodb.AttributeValue, o options) (map[string]*dynamodb.AttributeValue, error) {
+	release, err := o.partitions.acquire(ctx, o.table, item)
+	if err != nil {
+		return nil, fmt.Errorf("PutItem canceled: %w", err)
+	}
+
+	defer release()
+	o.hotKeys.observe(o.table, item)
+	defer o.cache.invalidate(o.table, item)
+	return putItem(ctx, c.svc, item, o)
+}
+
+func (c *Client) DeleteItem(ctx context.Context, pk, sk string, opts ...Option) error {
+	o, err := c.apply(opts)
+	if err != nil {
+		return err
+	}
+
+	kp, ks, err := c.keys(ctx, o, pk, sk)
+	if err != nil {
+		return fmt.Errorf("DeleteItem failed: %w", err)
+	}
+
+	_, err = c.deleteItem(ctx, keyMap(kp, ks), o)
+	return err
+}
+
+func (c *Client) deleteItem(ctx context.Context, key map[string]*dynamodb.AttributeValue, o options) (map[string]*dynamodb.AttributeValue, error) {
+	release, err := o.partitions.acquire(ctx, o.table, key)
+	if err != nil {
+		return nil, fmt.Errorf("DeleteItem canceled: %w", err)
+	}
+
+	defer release()
+	o.hotKeys.observe(o.table, key)
+	defer o.cache.invalidate(o.table, key)
+	return deleteItem(ctx, c.svc, key, o)
+}
+
+// Debug returns a log-safe representation of items, with attributes matching
+// the Client's redaction policy hidden.
+func (c *Client) Debug(items ...map[string]*dynamodb.AttributeValue) string {
+	s := make([]string, len(items))
+	for i, item := range items {
+		s[i] = c.opts.redact.String(item)
+	}
+
+	return strings.Join(s, "\n")
+}
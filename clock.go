@@ -0,0 +1,148 @@
+package libdy
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Clock is the time source of libdy: the time stamps it writes (TTL
+// expiries, lock, shard lease, and idempotency times, journal entries) and
+// keeps (session writes, events), and its waits (retry backoff, lock
+// polling and keep-alives). Replace it with WithClock, e.g. with a
+// ManualClock in tests. CircuitBreaker, RetryStorm, and StreamReader take
+// theirs with their own WithClock. Elapsed-time measurements, such as latencies, cache ages,
+// and context deadlines, stay on the system clock.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// IDGen generates the unique IDs of libdy: lock owners and record version
+// numbers, idempotency owner tokens, and journal entry IDs. Replace it with
+// WithIDGen.
+type IDGen interface {
+	NewID() string
+}
+
+// IDGenFunc adapts a function to an IDGen.
+type IDGenFunc func() string
+
+func (f IDGenFunc) NewID() string { return f() }
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+var (
+	SystemClock Clock = systemClock{}       // the default Clock
+	RandomIDs   IDGen = IDGenFunc(randomID) // the default IDGen: 32 random hex digits
+)
+
+func randomID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// WithClock sets the time source of the Client, or of an operation.
+func WithClock(c Clock) Option {
+	return func(o *options) { o.clock = c }
+}
+
+// WithIDGen sets the ID generator of the Client, or of an operation.
+func WithIDGen(g IDGen) Option {
+	return func(o *options) { o.ids = g }
+}
+
+func (o options) now() time.Time {
+	if o.clock == nil {
+		return time.Now()
+	}
+
+	return o.clock.Now()
+}
+
+func (o options) after(d time.Duration) <-chan time.Time {
+	if o.clock == nil {
+		return time.After(d)
+	}
+
+	return o.clock.After(d)
+}
+
+func (o options) newID() string {
+	if o.ids == nil {
+		return randomID()
+	}
+
+	return o.ids.NewID()
+}
+
+type manualTimer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// ManualClock is a Clock that only moves when told to, for deterministic
+// tests: waits on it (After) fire once Advance or Set moves the time past
+// them.
+//
+//	clock := libdy.NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+//	client := libdy.New(svc, libdy.WithTable("t"), libdy.WithClock(clock))
+//	// ... put an item with a TTL of an hour ...
+//	clock.Advance(time.Hour)
+type ManualClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []manualTimer
+}
+
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *ManualClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+
+	c.timers = append(c.timers, manualTimer{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the clock forward by d.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+// Set moves the clock to now, firing the waits due by then. Moving it back
+// fires nothing.
+func (c *ManualClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+	timers := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at.After(now) {
+			timers = append(timers, t)
+			continue
+		}
+
+		t.ch <- now
+	}
+
+	c.timers = timers
+}
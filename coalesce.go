@@ -0,0 +1,306 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+var (
+	ErrCoalescerClosed = errors.New("libdy: coalescer closed")
+)
+
+type groupGet struct {
+	key  map[string]*dynamodb.AttributeValue
+	item map[string]*dynamodb.AttributeValue
+	err  error
+	done chan struct{} // closed when item and err are set
+}
+
+// getBatch is the read of a batch of keys, canceled once all its callers
+// have given up.
+type getBatch struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	waiting int  // callers waiting, under Coalescer.mu
+	flushed bool // no more callers join
+}
+
+// leave drops a caller of b, canceling the read if it was the last one and
+// the batch is complete. Called under Coalescer.mu.
+func (b *getBatch) leave() {
+	b.waiting--
+	if b.waiting == 0 && b.flushed {
+		b.cancel()
+	}
+}
+
+// Coalescer collects GetItem calls arriving within a small window, from any
+// number of goroutines, and reads them together as one BatchGetItem call (up
+// to 100 keys), cutting the request count of fan-out handlers that read
+// many single items at once. Each GetItem call still blocks until its own
+// item has been read; calls for the same key within a window share a read.
+//
+// Unlike Committer batches, which are written in order, batches are read
+// concurrently.
+type Coalescer struct {
+	c       *Client
+	o       options
+	err     error // of the options
+	window  time.Duration
+	mu      sync.Mutex
+	pending map[string]*groupGet
+	batch   *getBatch // of pending
+	timer   *time.Timer
+	wg      sync.WaitGroup
+	closed  bool
+}
+
+// Coalescer returns a Coalescer for the Client's table that waits up to
+// window for more reads before reading them. opts apply to the reads, e.g.
+// WithConsistentRead, WithProjection, or WithPipeline. Close it when done.
+func (c *Client) Coalescer(window time.Duration, opts ...Option) *Coalescer {
+	o, err := c.apply(opts)
+	return &Coalescer{
+		c:       c,
+		o:       o,
+		err:     err,
+		window:  window,
+		pending: map[string]*groupGet{},
+	}
+}
+
+// keyID returns the identity of key, by its sorted attributes.
+func keyID(key map[string]*dynamodb.AttributeValue) string {
+	names := keyAttrNames(key)
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, k := range names {
+		parts[i] = k + "=" + keyString(key[k])
+	}
+
+	return strings.Join(parts, "\x00")
+}
+
+// GetItem reads the item with the given key, as in Client.GetItem, as part
+// of the next batch, failing with ErrItemNotFound if it doesn't exist. A key
+// DynamoDB rejects fails its own callers only. If ctx is done first, GetItem
+// returns ctx.Err(), and the read goes on for the other callers of the
+// batch, if any; it's canceled when all of them have given up.
+func (g *Coalescer) GetItem(ctx context.Context, pk, sk string) (map[string]*dynamodb.AttributeValue, error) {
+	if g.err != nil {
+		return nil, g.err
+	}
+
+	kp, ks, err := g.c.keys(ctx, g.o, pk, sk)
+	if err != nil {
+		return nil, fmt.Errorf("GetItem failed: %w", err)
+	}
+
+	key := keyMap(kp, ks)
+	id := keyID(key)
+
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		return nil, ErrCoalescerClosed
+	}
+
+	if g.batch == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		g.batch = &getBatch{ctx: ctx, cancel: cancel}
+	}
+
+	b := g.batch
+	b.waiting++
+	r, ok := g.pending[id]
+	if !ok {
+		r = &groupGet{key: key, done: make(chan struct{})}
+		g.pending[id] = r
+		switch {
+		case len(g.pending) >= batchGetMax:
+			g.flushLocked()
+		case g.timer == nil:
+			g.timer = time.AfterFunc(g.window, g.Flush)
+		}
+	}
+
+	g.mu.Unlock()
+
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+		g.mu.Lock()
+		b.leave()
+		g.mu.Unlock()
+		return nil, ctx.Err()
+	}
+
+	g.mu.Lock()
+	b.leave()
+	g.mu.Unlock()
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	item, err := g.o.authorizeItem(ctx, g.o.table, r.item) // per caller
+	if err != nil {
+		return nil, err
+	}
+
+	if item == nil {
+		return nil, ErrItemNotFound
+	}
+
+	return item, nil
+}
+
+// Flush reads the pending keys now, without waiting for the window.
+func (g *Coalescer) Flush() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.flushLocked()
+}
+
+func (g *Coalescer) flushLocked() {
+	if g.timer != nil {
+		g.timer.Stop()
+		g.timer = nil
+	}
+
+	if len(g.pending) == 0 {
+		return
+	}
+
+	batch, b := g.pending, g.batch
+	g.pending, g.batch = map[string]*groupGet{}, nil
+	b.flushed = true
+	if b.waiting == 0 {
+		b.cancel() // all gone already
+	}
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer b.cancel()
+		g.read(b.ctx, batch)
+	}()
+}
+
+// Close reads the pending keys and waits for all batches to finish. Reads
+// after Close fail with ErrCoalescerClosed.
+func (g *Coalescer) Close() {
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		return
+	}
+
+	g.flushLocked()
+	g.closed = true
+	g.mu.Unlock()
+	g.wg.Wait()
+}
+
+// read reads batch, by key identity, with ctx, and completes its reads.
+func (g *Coalescer) read(ctx context.Context, batch map[string]*groupGet) {
+	defer func() {
+		for _, r := range batch {
+			close(r.done)
+		}
+	}()
+
+	// The key attributes are needed to match the items to the keys.
+	o := g.o
+	var extra []string
+	if len(o.projection) > 0 {
+		o.projection = o.projection[:len(o.projection):len(o.projection)]
+		for _, r := range batch {
+			for k := range r.key {
+				if !slices.Contains(o.projection, k) {
+					o.projection = append(o.projection, k)
+					extra = append(extra, k)
+				}
+			}
+
+			break
+		}
+	}
+
+	g.fetch(ctx, o, extra, batch)
+}
+
+// fetch reads batch with o, then drops the extra attributes of the items.
+// If DynamoDB rejects the request, e.g. for a key not of the key schema, it
+// reads the halves of batch apart, so only the callers of the invalid keys
+// fail.
+func (g *Coalescer) fetch(ctx context.Context, o options, extra []string, batch map[string]*groupGet) {
+	keys := make([]map[string]*dynamodb.AttributeValue, 0, len(batch))
+	for _, r := range batch {
+		keys = append(keys, r.key)
+	}
+
+	items, err := batchGet(ctx, g.c.reader(o), o.table, keys, o)
+	if errors.Is(err, ErrInvalidRequest) && len(batch) > 1 {
+		halves, i := [2]map[string]*groupGet{{}, {}}, 0
+		for id, r := range batch {
+			halves[i%2][id] = r
+			i++
+		}
+
+		g.fetch(ctx, o, extra, halves[0])
+		g.fetch(ctx, o, extra, halves[1])
+		return
+	}
+
+	if err != nil {
+		for _, r := range batch {
+			r.err = err
+		}
+
+		return
+	}
+
+	for _, item := range items {
+		key := keyOf(item, keyAttrNames(keys[0]))
+		r, ok := batch[keyID(key)]
+		if !ok {
+			continue
+		}
+
+		if len(extra) > 0 {
+			trimmed := make(map[string]*dynamodb.AttributeValue, len(item))
+			for k, v := range item {
+				trimmed[k] = v
+			}
+
+			for _, k := range extra {
+				delete(trimmed, k)
+			}
+
+			item = trimmed
+		}
+
+		if r.item, r.err = o.readItem(ctx, item); r.err != nil {
+			r.err = fmt.Errorf("GetItem failed: %w", r.err)
+		}
+	}
+}
+
+// keyAttrNames returns the attribute names of key.
+func keyAttrNames(key map[string]*dynamodb.AttributeValue) []string {
+	names := make([]string, 0, len(key))
+	for k := range key {
+		names = append(names, k)
+	}
+
+	return names
+}
@@ -0,0 +1,138 @@
+package libdy_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/flowerinthenight/libdy"
+	"github.com/flowerinthenight/libdy/libdytest"
+)
+
+// slowGets counts the BatchGetItem calls to a Fake, holding each until its
+// context is done if hang is set.
+type slowGets struct {
+	*libdytest.Fake
+	n    atomic.Int32
+	hang bool
+}
+
+func (s *slowGets) BatchGetItemWithContext(ctx aws.Context, in *dynamodb.BatchGetItemInput, opts ...request.Option) (*dynamodb.BatchGetItemOutput, error) {
+	s.n.Add(1)
+	if s.hang {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	return s.Fake.BatchGetItemWithContext(ctx, in, opts...)
+}
+
+func TestCoalescer(t *testing.T) {
+	ctx := context.Background()
+	svc := &slowGets{Fake: libdytest.SetupFake(t, libdy.TableDef{Name: "t", PK: "id"})}
+	c := libdy.New(svc, libdy.WithTable("t"))
+	for i := 0; i < 5; i++ {
+		if err := c.PutItem(ctx, map[string]*dynamodb.AttributeValue{"id": {S: aws.String(strconv.Itoa(i))}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	g := c.Coalescer(50 * time.Millisecond)
+	defer g.Close()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			item, err := g.GetItem(ctx, "id:"+strconv.Itoa(i%6), "")
+			switch {
+			case i%6 == 5:
+				if !errors.Is(err, libdy.ErrItemNotFound) {
+					t.Errorf("GetItem(5) = %v, want ErrItemNotFound", err)
+				}
+			case err != nil:
+				t.Error(err)
+			case aws.StringValue(item["id"].S) != strconv.Itoa(i%6):
+				t.Errorf("GetItem(%d) = %v", i%6, item)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	if got := svc.n.Load(); got != 1 {
+		t.Errorf("%d batches, want 1", got)
+	}
+}
+
+func TestCoalescerCancel(t *testing.T) {
+	svc := &slowGets{Fake: libdytest.SetupFake(t, libdy.TableDef{Name: "t", PK: "id"}), hang: true}
+	g := libdy.New(svc, libdy.WithTable("t")).Coalescer(time.Millisecond)
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), time.Duration(i+1)*20*time.Millisecond)
+			defer cancel()
+			if _, err := g.GetItem(ctx, "id:"+strconv.Itoa(i), ""); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("GetItem = %v, want context.DeadlineExceeded", err)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	done := make(chan struct{})
+	go func() {
+		g.Close() // waits for the batch read, canceled with its callers gone
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the batch read outlived its callers")
+	}
+}
+
+func TestCoalescerKeys(t *testing.T) {
+	ctx := context.Background()
+	svc := &slowGets{Fake: libdytest.SetupFake(t, libdy.TableDef{Name: "t", PK: "id:N"})}
+	c := libdy.New(svc, libdy.WithTable("t"))
+	for i := 0; i < 4; i++ {
+		if err := c.PutItem(ctx, map[string]*dynamodb.AttributeValue{"id": {N: aws.String(strconv.Itoa(i))}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Bare values are numbers, per the schema; "id:x" is a string, which
+	// the table rejects, failing its own read only.
+	g := c.Coalescer(50 * time.Millisecond)
+	defer g.Close()
+	var wg sync.WaitGroup
+	for _, k := range []string{"0", "1", "id:x", "2", "3"} {
+		wg.Add(1)
+		go func(k string) {
+			defer wg.Done()
+			item, err := g.GetItem(ctx, k, "")
+			switch {
+			case k == "id:x":
+				if !errors.Is(err, libdy.ErrInvalidRequest) {
+					t.Errorf("GetItem(%q) = %v, want ErrInvalidRequest", k, err)
+				}
+			case err != nil:
+				t.Errorf("GetItem(%q): %v", k, err)
+			case aws.StringValue(item["id"].N) != k:
+				t.Errorf("GetItem(%q) = %v", k, item)
+			}
+		}(k)
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,247 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+var (
+	ErrCommitterClosed = errors.New("libdy: committer closed")
+)
+
+type groupWrite struct {
+	req  *dynamodb.WriteRequest
+	done chan error
+}
+
+// Committer collects single-item writes arriving within a small window and
+// commits them together as one BatchWriteItem call (up to 25 items), cutting
+// the request count for workloads with many tiny concurrent writes. Each
+// Put/Delete call still blocks until its own write has landed or failed.
+//
+// Batches are committed one at a time, in order. Two writes to the same key
+// never share a batch (which DynamoDB would reject) when the key attributes
+// are given to NewCommitter.
+type Committer struct {
+	c       *Client
+	o       options
+	err     error // of the options
+	ctx     context.Context
+	window  time.Duration
+	keys    []string
+	mu      sync.Mutex
+	pending []*groupWrite
+	seen    map[string]bool
+	timer   *time.Timer
+	queue   [][]*groupWrite // flushed, to be committed by run
+	wake    chan struct{}   // tells run of a flush, or Close
+	done    chan struct{}
+	closed  bool
+}
+
+// NewCommitter returns a Committer for table that waits up to window for
+// more writes before committing. keyAttrs are the table's key attribute
+// names, used to keep writes to the same item apart. Close it when done.
+func NewCommitter(svc dynamodbiface.DynamoDBAPI, table string, window time.Duration, keyAttrs ...string) *Committer {
+	return New(svc, WithTable(table)).Committer(context.Background(), window, keyAttrs...)
+}
+
+// Committer returns a Committer for the Client's table, writing with its
+// options, e.g. WithRetryPolicy, WithTimeout, or WithEncryption. Batches are
+// committed with ctx, so canceling it fails the writes not committed yet.
+// See NewCommitter.
+func (c *Client) Committer(ctx context.Context, window time.Duration, keyAttrs ...string) *Committer {
+	o, err := c.apply(nil)
+	g := &Committer{
+		c:      c,
+		o:      o,
+		err:    err,
+		ctx:    ctx,
+		window: window,
+		keys:   keyAttrs,
+		seen:   map[string]bool{},
+		wake:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+
+	go g.run()
+	return g
+}
+
+// Put writes item as part of the next batch. If ctx is done first, Put
+// returns ctx.Err() but the write may still land.
+func (g *Committer) Put(ctx context.Context, item map[string]*dynamodb.AttributeValue) error {
+	if g.err != nil {
+		return g.err
+	}
+
+	stored, err := g.o.encode(ctx, g.c.svc, item)
+	if err != nil {
+		return fmt.Errorf("Put failed: %w", err)
+	}
+
+	return g.add(ctx, &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: stored}}, item)
+}
+
+// Delete deletes the item with the given key as part of the next batch.
+// Bare key values are named and typed per the table's key schema, as for
+// Client.DeleteItem.
+func (g *Committer) Delete(ctx context.Context, pk, sk string) error {
+	if g.err != nil {
+		return g.err
+	}
+
+	kp, ks, err := g.c.keys(ctx, g.o, pk, sk)
+	if err != nil {
+		return fmt.Errorf("Delete failed: %w", err)
+	}
+
+	key := keyMap(kp, ks)
+	return g.add(ctx, &dynamodb.WriteRequest{DeleteRequest: &dynamodb.DeleteRequest{Key: key}}, key)
+}
+
+// itemID returns the identity of item by the key attributes, or "" if
+// unknown.
+func (g *Committer) itemID(item map[string]*dynamodb.AttributeValue) string {
+	if len(g.keys) == 0 {
+		return ""
+	}
+
+	keys := append([]string{}, g.keys...)
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		if v, ok := item[k]; ok {
+			parts[i] = partitionValue(v)
+		}
+	}
+
+	return strings.Join(parts, "\x00")
+}
+
+func (g *Committer) add(ctx context.Context, req *dynamodb.WriteRequest, item map[string]*dynamodb.AttributeValue) error {
+	w := &groupWrite{req: req, done: make(chan error, 1)}
+	id := g.itemID(item)
+
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		return ErrCommitterClosed
+	}
+
+	if id != "" && g.seen[id] {
+		g.flushLocked()
+	}
+
+	g.pending = append(g.pending, w)
+	if id != "" {
+		g.seen[id] = true
+	}
+
+	switch {
+	case len(g.pending) >= batchWriteMax:
+		g.flushLocked()
+	case g.timer == nil:
+		g.timer = time.AfterFunc(g.window, g.Flush)
+	}
+
+	g.mu.Unlock()
+
+	select {
+	case err := <-w.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Flush commits the pending writes now, without waiting for the window.
+func (g *Committer) Flush() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.flushLocked()
+}
+
+// flushLocked queues the pending writes for run, without blocking.
+func (g *Committer) flushLocked() {
+	if g.timer != nil {
+		g.timer.Stop()
+		g.timer = nil
+	}
+
+	if len(g.pending) == 0 || g.closed {
+		return
+	}
+
+	g.queue = append(g.queue, g.pending)
+	g.pending = nil
+	g.seen = map[string]bool{}
+	g.signal()
+}
+
+// signal wakes run up, unless it's already due to wake up.
+func (g *Committer) signal() {
+	select {
+	case g.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Close commits the pending writes and waits for all batches to finish.
+// Writes after Close fail with ErrCommitterClosed.
+func (g *Committer) Close() {
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		return
+	}
+
+	g.flushLocked()
+	g.closed = true
+	g.signal()
+	g.mu.Unlock()
+	<-g.done
+}
+
+func (g *Committer) run() {
+	defer close(g.done)
+	for range g.wake {
+		g.mu.Lock()
+		queue, closed := g.queue, g.closed
+		g.queue = nil
+		g.mu.Unlock()
+		for _, batch := range queue {
+			g.commit(batch)
+		}
+
+		if closed {
+			return
+		}
+	}
+}
+
+// commit writes batch, and tells each write its outcome.
+func (g *Committer) commit(batch []*groupWrite) {
+	reqs := make([]*dynamodb.WriteRequest, len(batch))
+	for i, w := range batch {
+		reqs[i] = w.req
+	}
+
+	// Unprocessed items come back as copies, so match them by value.
+	errs := map[string]error{}
+	for _, f := range batchWriteChunk(g.ctx, g.c.svc, g.o.table, reqs, g.o) {
+		errs[f.Request.String()] = f.Err
+	}
+
+	for _, w := range batch {
+		w.done <- errs[w.req.String()]
+	}
+}
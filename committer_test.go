@@ -0,0 +1,101 @@
+package libdy_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/flowerinthenight/libdy"
+	"github.com/flowerinthenight/libdy/libdytest"
+)
+
+// batchCounter counts the BatchWriteItem calls to a Fake, failing those with
+// a done context as the SDK does.
+type batchCounter struct {
+	*libdytest.Fake
+	n atomic.Int32
+}
+
+func (b *batchCounter) BatchWriteItemWithContext(ctx aws.Context, in *dynamodb.BatchWriteItemInput, opts ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
+	b.n.Add(1)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return b.Fake.BatchWriteItemWithContext(ctx, in, opts...)
+}
+
+func TestCommitter(t *testing.T) {
+	ctx := context.Background()
+	svc := &batchCounter{Fake: libdytest.SetupFake(t, libdy.TableDef{Name: "t", PK: "id:N"})}
+	c := libdy.New(svc, libdy.WithTable("t"))
+	g := c.Committer(ctx, time.Hour, "id")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := g.Put(ctx, map[string]*dynamodb.AttributeValue{"id": {N: aws.String(strconv.Itoa(i))}}); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+
+	for svc.n.Load() == 0 { // the first 25
+		time.Sleep(time.Millisecond)
+	}
+
+	g.Flush()
+	wg.Wait()
+	if got := svc.n.Load(); got != 2 {
+		t.Errorf("%d batches, want 2", got)
+	}
+
+	if got := len(svc.Items("t")); got != 30 {
+		t.Fatalf("%d items, want 30", got)
+	}
+
+	// A bare number key is typed per the schema, and the same key twice
+	// splits the batch.
+	errs := make(chan error, 2)
+	go func() { errs <- g.Delete(ctx, "3", "") }()
+	go func() { errs <- g.Delete(ctx, "3", "") }()
+	for svc.n.Load() < 3 {
+		time.Sleep(time.Millisecond)
+	}
+
+	g.Close()
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("Delete: %v", err)
+		}
+	}
+
+	if got := len(svc.Items("t")); got != 29 {
+		t.Errorf("%d items, want 29", got)
+	}
+
+	if err := g.Put(ctx, map[string]*dynamodb.AttributeValue{"id": {N: aws.String("1")}}); !errors.Is(err, libdy.ErrCommitterClosed) {
+		t.Errorf("Put after Close: %v, want ErrCommitterClosed", err)
+	}
+}
+
+func TestCommitterContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	svc := &batchCounter{Fake: libdytest.SetupFake(t, libdy.TableDef{Name: "t", PK: "id"})}
+	g := libdy.New(svc, libdy.WithTable("t")).Committer(ctx, time.Millisecond)
+	defer g.Close()
+	err := g.Put(context.Background(), map[string]*dynamodb.AttributeValue{"id": {S: aws.String("a")}})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Put = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,220 @@
+package libdy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+const (
+	compressionMagic   = "\x00dyz" // prefixes compressed values, then the codec name
+	compressionMinSize = 1 << 10
+)
+
+// Codec is a compression algorithm for Compression. Its name is stored with
+// each value, so it must not change.
+type Codec interface {
+	Name() string
+	Compress(b []byte) ([]byte, error)
+	Decompress(b []byte) ([]byte, error)
+}
+
+type gzipCodec struct {
+	level int
+}
+
+// Gzip is the Codec of compress/gzip at level, e.g. gzip.BestSpeed.
+func Gzip(level int) Codec {
+	return gzipCodec{level: level}
+}
+
+func (gzipCodec) Name() string { return "gzip" }
+
+func (c gzipCodec) Compress(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, c.level)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (gzipCodec) Decompress(b []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// Compression compresses attributes on the client side, to shrink large
+// items, e.g. of JSON documents, and the capacity they consume. Each value
+// is stored as a binary attribute: a marker naming the codec, then the
+// compressed value.
+//
+//	comp := libdy.NewCompression(libdy.Gzip(gzip.DefaultCompression), "doc", "history")
+//	client := libdy.New(svc, libdy.WithTable("docs"), libdy.WithCompression(comp))
+//
+// zstd and other algorithms plug in as a Codec, e.g. wrapping the encoder
+// and decoder of github.com/klauspost/compress/zstd.
+//
+// Values under the minimum size, or that don't get smaller, are stored as
+// they are, and so are the values written by UpdateItem. Key attributes,
+// and attributes used in conditions, filters, or indexes, can't be
+// compressed.
+type Compression struct {
+	codec   Codec
+	codecs  map[string]Codec // by name, to decompress
+	attrs   map[string]bool
+	minSize int
+}
+
+// NewCompression returns a Compression of attrs with codec. Gzip values are
+// always decompressed, along with those of codec.
+func NewCompression(codec Codec, attrs ...string) *Compression {
+	c := &Compression{
+		codec:   codec,
+		codecs:  map[string]Codec{},
+		attrs:   map[string]bool{},
+		minSize: compressionMinSize,
+	}
+
+	c.WithCodecs(Gzip(gzip.DefaultCompression), codec)
+	for _, attr := range attrs {
+		c.attrs[attr] = true
+	}
+
+	return c
+}
+
+// WithCodecs adds the codecs c decompresses values of, e.g. one switched
+// from, and returns c.
+func (c *Compression) WithCodecs(codecs ...Codec) *Compression {
+	for _, codec := range codecs {
+		c.codecs[codec.Name()] = codec
+	}
+
+	return c
+}
+
+// WithMinSize sets the size in bytes under which c stores values as they
+// are, 1KB by default, and returns c.
+func (c *Compression) WithMinSize(n int) *Compression {
+	c.minSize = n
+	return c
+}
+
+// WithCompression compresses the attributes of c in the items written by
+// PutItem, a Writer, and the other puts listed by WithEncryption, before
+// any encryption, and decompresses them
+// in the items read, after any decryption, before the read pipeline.
+func WithCompression(c *Compression) Option {
+	return func(o *options) { o.compression = c }
+}
+
+// compress returns item with the attributes of c compressed. item itself is
+// not modified.
+func (c *Compression) compress(item map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+	var ret map[string]*dynamodb.AttributeValue
+	for attr := range c.attrs {
+		v, ok := item[attr]
+		if !ok {
+			continue
+		}
+
+		b, err := json.Marshal(typedJSON(v))
+		if err != nil {
+			return nil, err
+		}
+
+		if len(b) < c.minSize {
+			continue
+		}
+
+		z, err := c.codec.Compress(b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", attr, err)
+		}
+
+		name := c.codec.Name()
+		out := make([]byte, 0, len(compressionMagic)+1+len(name)+len(z))
+		out = append(out, compressionMagic...)
+		out = append(out, byte(len(name)))
+		out = append(out, name...)
+		out = append(out, z...)
+		if len(out) >= len(b) {
+			continue
+		}
+
+		if ret == nil {
+			ret = copyItem(item)
+		}
+
+		ret[attr] = &dynamodb.AttributeValue{B: out}
+	}
+
+	if ret == nil {
+		return item, nil
+	}
+
+	return ret, nil
+}
+
+// decompress is the decode stage of c. It decompresses every marked
+// value, so attributes dropped from c are still read.
+func (c *Compression) decompress(item map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+	var ret map[string]*dynamodb.AttributeValue
+	for attr, v := range item {
+		if !bytes.HasPrefix(v.B, []byte(compressionMagic)) {
+			continue
+		}
+
+		b := v.B[len(compressionMagic):]
+		if len(b) == 0 || len(b) < 1+int(b[0]) {
+			return nil, fmt.Errorf("decompress failed: %s: truncated value", attr)
+		}
+
+		name := string(b[1 : 1+b[0]])
+		codec, ok := c.codecs[name]
+		if !ok {
+			return nil, fmt.Errorf("decompress failed: %s: unknown codec %q", attr, name)
+		}
+
+		raw, err := codec.Decompress(b[1+b[0]:])
+		if err != nil {
+			return nil, fmt.Errorf("decompress failed: %s: %w", attr, err)
+		}
+
+		av, err := parseTypedJSON(raw)
+		if err != nil {
+			return nil, fmt.Errorf("decompress failed: %s: %w", attr, err)
+		}
+
+		if ret == nil {
+			ret = copyItem(item)
+		}
+
+		ret[attr] = av
+	}
+
+	if ret == nil {
+		return item, nil
+	}
+
+	return ret, nil
+}
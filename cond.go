@@ -0,0 +1,244 @@
+package libdy
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// CondValue is the Go types of the values of a Cond: strings, numbers,
+// booleans, and byte slices, and types based on them.
+type CondValue interface {
+	~string | ~[]byte | ~bool |
+		~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 |
+		~float32 | ~float64
+}
+
+// Cond is a condition expression, built with Eq, Ne, Lt, Le, Gt, Ge,
+// Between, In, AttributeExists, AttributeNotExists, BeginsWith, Contains,
+// And, Or, and Not, instead of by hand:
+//
+//	cond := libdy.And(
+//		libdy.Eq("status", "open"),
+//		libdy.Gt("amount", 100),
+//		libdy.Not(libdy.AttributeExists("deleted")))
+//
+//	err := client.PutItem(ctx, item, libdy.WithCondition(cond.Condition()))
+//	items, err := client.GetItems(ctx, "id:c1", "", libdy.WithFilter(cond.Condition()))
+//
+// Attribute names are always aliased, so reserved words are fine; dots
+// separate the names of a nested path, as in "address.city". The zero Cond
+// is no condition, skipped by And and Or.
+type Cond struct {
+	op     string // a comparator, BETWEEN, IN, AND, OR, NOT, or a function
+	attr   string
+	values []*dynamodb.AttributeValue
+	conds  []Cond
+}
+
+// condValue returns v as an attribute value.
+func condValue[T CondValue](v T) *dynamodb.AttributeValue {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String:
+		return &dynamodb.AttributeValue{S: aws.String(rv.String())}
+	case reflect.Bool:
+		return &dynamodb.AttributeValue{BOOL: aws.Bool(rv.Bool())}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(rv.Int(), 10))}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &dynamodb.AttributeValue{N: aws.String(strconv.FormatUint(rv.Uint(), 10))}
+	case reflect.Float32:
+		return &dynamodb.AttributeValue{N: aws.String(strconv.FormatFloat(rv.Float(), 'f', -1, 32))}
+	case reflect.Float64:
+		return &dynamodb.AttributeValue{N: aws.String(strconv.FormatFloat(rv.Float(), 'f', -1, 64))}
+	}
+
+	return &dynamodb.AttributeValue{B: rv.Bytes()}
+}
+
+func compare[T CondValue](op, attr string, vs ...T) Cond {
+	c := Cond{op: op, attr: attr}
+	for _, v := range vs {
+		c.values = append(c.values, condValue(v))
+	}
+
+	return c
+}
+
+func Eq[T CondValue](attr string, v T) Cond { return compare("=", attr, v) }
+func Ne[T CondValue](attr string, v T) Cond { return compare("<>", attr, v) }
+func Lt[T CondValue](attr string, v T) Cond { return compare("<", attr, v) }
+func Le[T CondValue](attr string, v T) Cond { return compare("<=", attr, v) }
+func Gt[T CondValue](attr string, v T) Cond { return compare(">", attr, v) }
+func Ge[T CondValue](attr string, v T) Cond { return compare(">=", attr, v) }
+
+// Between holds if attr is from lo to hi, inclusive.
+func Between[T CondValue](attr string, lo, hi T) Cond { return compare("BETWEEN", attr, lo, hi) }
+
+// In holds if attr equals one of vs, up to 100.
+func In[T CondValue](attr string, vs ...T) Cond { return compare("IN", attr, vs...) }
+
+// AttributeExists holds if the item has attr.
+func AttributeExists(attr string) Cond { return Cond{op: "attribute_exists", attr: attr} }
+
+// AttributeNotExists holds if the item doesn't have attr, or if there is no
+// item, as for "put if not exists".
+func AttributeNotExists(attr string) Cond { return Cond{op: "attribute_not_exists", attr: attr} }
+
+// BeginsWith holds if the string attr starts with prefix.
+func BeginsWith(attr, prefix string) Cond { return compare("begins_with", attr, prefix) }
+
+// Contains holds if the string attr contains v, or if the set or list attr
+// has the element v.
+func Contains[T CondValue](attr string, v T) Cond { return compare("contains", attr, v) }
+
+// logical returns the conds, other than zero ones, joined by op.
+func logical(op string, conds []Cond) Cond {
+	var c Cond
+	for _, cond := range conds {
+		if cond.op != "" {
+			c.conds = append(c.conds, cond)
+		}
+	}
+
+	switch len(c.conds) {
+	case 0:
+		return Cond{}
+	case 1:
+		return c.conds[0]
+	}
+
+	c.op = op
+	return c
+}
+
+// And holds if all conds hold.
+func And(conds ...Cond) Cond { return logical("AND", conds) }
+
+// Or holds if any of conds holds.
+func Or(conds ...Cond) Cond { return logical("OR", conds) }
+
+// Not holds if c doesn't.
+func Not(c Cond) Cond {
+	if c.op == "" {
+		return c
+	}
+
+	return Cond{op: "NOT", conds: []Cond{c}}
+}
+
+// IsZero reports whether c is no condition.
+func (c Cond) IsZero() bool { return c.op == "" }
+
+// Expression returns c as an expression with its attribute names and
+// values, e.g. for the KeyConditionExpression of a dynamodb.QueryInput. The
+// placeholders are #x0, :x0, and so on. The zero Cond is "" and nil maps.
+func (c Cond) Expression() (string, map[string]*string, map[string]*dynamodb.AttributeValue) {
+	if c.op == "" {
+		return "", nil, nil
+	}
+
+	b := condBuilder{names: map[string]*string{}, aliases: map[string]string{}}
+	expr := b.build(c)
+	if len(b.values) == 0 {
+		b.values = nil // rejected by DynamoDB if empty
+	}
+
+	return expr, b.names, b.values
+}
+
+// Condition returns c as a Condition, for WithCondition and WithFilter.
+func (c Cond) Condition() Condition {
+	expr, names, values := c.Expression()
+	return Condition{Expr: expr, Names: names, Values: values}
+}
+
+// String returns the expression of c.
+func (c Cond) String() string {
+	expr, _, _ := c.Expression()
+	return expr
+}
+
+type condBuilder struct {
+	names   map[string]*string
+	aliases map[string]string // by name
+	values  map[string]*dynamodb.AttributeValue
+}
+
+// path returns the aliased path of attr.
+func (b *condBuilder) path(attr string) string {
+	parts := strings.Split(attr, ".")
+	for i, part := range parts {
+		name, index := part, ""
+		if j := strings.IndexByte(part, '['); j > 0 {
+			name, index = part[:j], part[j:]
+		}
+
+		alias, ok := b.aliases[name]
+		if !ok {
+			alias = "#x" + strconv.Itoa(len(b.aliases))
+			b.aliases[name] = alias
+			b.names[alias] = aws.String(name)
+		}
+
+		parts[i] = alias + index
+	}
+
+	return strings.Join(parts, ".")
+}
+
+func (b *condBuilder) value(v *dynamodb.AttributeValue) string {
+	if b.values == nil {
+		b.values = map[string]*dynamodb.AttributeValue{}
+	}
+
+	p := ":x" + strconv.Itoa(len(b.values))
+	b.values[p] = v
+	return p
+}
+
+func (b *condBuilder) build(c Cond) string {
+	switch c.op {
+	case "AND", "OR":
+		parts := make([]string, len(c.conds))
+		for i, cond := range c.conds {
+			parts[i] = b.operand(cond)
+		}
+
+		return strings.Join(parts, " "+c.op+" ")
+	case "NOT":
+		return "NOT " + b.operand(c.conds[0])
+	case "attribute_exists", "attribute_not_exists":
+		return fmt.Sprintf("%s(%s)", c.op, b.path(c.attr))
+	case "begins_with", "contains":
+		return fmt.Sprintf("%s(%s, %s)", c.op, b.path(c.attr), b.value(c.values[0]))
+	case "BETWEEN":
+		return fmt.Sprintf("%s BETWEEN %s AND %s", b.path(c.attr), b.value(c.values[0]), b.value(c.values[1]))
+	case "IN":
+		vals := make([]string, len(c.values))
+		for i, v := range c.values {
+			vals[i] = b.value(v)
+		}
+
+		return fmt.Sprintf("%s IN (%s)", b.path(c.attr), strings.Join(vals, ", "))
+	}
+
+	return fmt.Sprintf("%s %s %s", b.path(c.attr), c.op, b.value(c.values[0]))
+}
+
+// operand returns c as an operand of AND, OR, or NOT, in parentheses if
+// compound.
+func (b *condBuilder) operand(c Cond) string {
+	s := b.build(c)
+	if c.op == "AND" || c.op == "OR" || c.op == "NOT" {
+		return "(" + s + ")"
+	}
+
+	return s
+}
@@ -0,0 +1,138 @@
+package libdy
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Condition guards a write: the write only lands if Expr holds for the
+// existing item.
+type Condition struct {
+	Label  string // metrics label; defaults to Expr
+	Expr   string
+	Names  map[string]*string
+	Values map[string]*dynamodb.AttributeValue
+}
+
+// WithCondition makes PutItem, DeleteItem, and UpdateItem conditional. They
+// fail with ErrConditionFailed if c doesn't hold. Rejected writes are counted
+// as MetricConditionFailed, labeled with the table and c.Label.
+func WithCondition(c Condition) Option {
+	return func(o *options) { o.condition = &c }
+}
+
+// IfNotExists holds if there is no item with the same key yet, for "put if
+// not exists". attr is any key attribute.
+func IfNotExists(attr string) Condition {
+	return Condition{
+		Label: "if_not_exists",
+		Expr:  "attribute_not_exists(#c0)",
+		Names: map[string]*string{"#c0": aws.String(attr)},
+	}
+}
+
+// IfExists holds if the item exists. attr is any key attribute.
+func IfExists(attr string) Condition {
+	return Condition{
+		Label: "if_exists",
+		Expr:  "attribute_exists(#c0)",
+		Names: map[string]*string{"#c0": aws.String(attr)},
+	}
+}
+
+// IfEquals holds if the existing item's attr equals v, e.g.
+// IfEquals("status", &dynamodb.AttributeValue{S: aws.String("pending")}).
+func IfEquals(attr string, v *dynamodb.AttributeValue) Condition {
+	return Condition{
+		Label:  "if_equals_" + attr,
+		Expr:   "#c0 = :c0",
+		Names:  map[string]*string{"#c0": aws.String(attr)},
+		Values: map[string]*dynamodb.AttributeValue{":c0": v},
+	}
+}
+
+// WithMustExist makes DeleteItem (and its variants) fail with
+// ErrItemNotFound if there is no item to delete, instead of succeeding
+// anyway, so callers can tell "deleted" from "was never there". It is and'ed
+// with WithCondition, if set: a delete rejected by that fails with
+// ErrConditionFailed, as usual.
+func WithMustExist() Option {
+	return func(o *options) { o.mustExist = true }
+}
+
+// existsCondition returns the condition of o, and'ed with the item with key
+// existing, per WithMustExist.
+func (o options) existsCondition(key map[string]*dynamodb.AttributeValue) *Condition {
+	if !o.mustExist {
+		return o.condition
+	}
+
+	names := keyAttrNames(key)
+	sort.Strings(names)
+	cond := Condition{
+		Label: "must_exist",
+		Expr:  "attribute_exists(#e0)",
+		Names: map[string]*string{"#e0": aws.String(names[0])},
+	}
+
+	if o.condition != nil {
+		cond = andCondition(*o.condition, cond)
+	}
+
+	return &cond
+}
+
+// missing reports whether the failed condition check err of a write per
+// WithMustExist was due to the item not existing. The check returns the
+// item when there is one.
+func (o options) missing(err error) bool {
+	if !o.mustExist || !IsConditionalCheckFailed(err) {
+		return false
+	}
+
+	var cerr *dynamodb.ConditionalCheckFailedException
+	return !errors.As(err, &cerr) || len(cerr.Item) == 0
+}
+
+func (c *Condition) label() string {
+	if c.Label != "" {
+		return c.Label
+	}
+
+	return c.Expr
+}
+
+// conditionFailed counts err if it is a failed condition check.
+func (o options) conditionFailed(err error) {
+	if o.condition == nil || !IsConditionalCheckFailed(err) {
+		return
+	}
+
+	o.count(MetricConditionFailed, map[string]string{
+		"table":     o.table,
+		"condition": o.condition.label(),
+	}, 1)
+}
+
+// conditionInput returns the condition expression, names, and values for a
+// write input, or nils if the write is unconditional.
+func (o options) conditionInput() (*string, map[string]*string, map[string]*dynamodb.AttributeValue) {
+	if o.condition == nil {
+		return nil, nil, nil
+	}
+
+	// Empty maps are rejected by DynamoDB, unlike nil ones.
+	names, values := o.condition.Names, o.condition.Values
+	if len(names) == 0 {
+		names = nil
+	}
+
+	if len(values) == 0 {
+		values = nil
+	}
+
+	return aws.String(o.condition.Expr), names, values
+}
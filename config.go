@@ -0,0 +1,247 @@
+package libdy
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// svcConfig holds the settings used by Open to build the underlying session.
+// These are construction-time only and are ignored when passed per call.
+type svcConfig struct {
+	region        string
+	endpoint      string
+	signingRegion string
+	proxy         string
+	httpClient    *http.Client
+	roleARN       string
+	externalID    string
+	tokenFile     string
+	roleSession   string
+	roleDuration  time.Duration
+	fips          bool
+	dualStack     bool
+	gzip          bool
+	opPolicy      *OpPolicy
+}
+
+// WithRegion sets the AWS region used by Open.
+func WithRegion(region string) Option {
+	return func(o *options) { o.svc.region = region }
+}
+
+// WithEndpoint routes all DynamoDB requests to a custom endpoint, such as a
+// VPC interface endpoint or a localstack gateway. Requests are still signed
+// with SigV4 for the "dynamodb" service.
+func WithEndpoint(endpoint string) Option {
+	return func(o *options) { o.svc.endpoint = endpoint }
+}
+
+// WithSigningRegion overrides the region used for SigV4 signing when it
+// differs from the region the endpoint is in. Defaults to WithRegion.
+func WithSigningRegion(region string) Option {
+	return func(o *options) { o.svc.signingRegion = region }
+}
+
+// WithProxy sends all requests through an HTTP(S) egress proxy.
+func WithProxy(proxyURL string) Option {
+	return func(o *options) { o.svc.proxy = proxyURL }
+}
+
+// WithHTTPClient sets the HTTP client used by Open. WithProxy, if set, is
+// applied on a copy of the client's transport.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) { o.svc.httpClient = client }
+}
+
+// WithAssumeRole makes Open assume roleARN through STS, using the ambient
+// credentials as the source. The temporary credentials are refreshed
+// automatically before they expire. externalID is optional.
+func WithAssumeRole(roleARN, externalID string) Option {
+	return func(o *options) {
+		o.svc.roleARN = roleARN
+		o.svc.externalID = externalID
+	}
+}
+
+// WithWebIdentity makes Open assume roleARN using the OIDC token read from
+// tokenFile (e.g. EKS service account tokens). The token file is re-read on
+// every refresh.
+func WithWebIdentity(roleARN, tokenFile string) Option {
+	return func(o *options) {
+		o.svc.roleARN = roleARN
+		o.svc.tokenFile = tokenFile
+	}
+}
+
+// WithRoleSessionName sets the session name used when assuming a role.
+func WithRoleSessionName(name string) Option {
+	return func(o *options) { o.svc.roleSession = name }
+}
+
+// WithRoleDuration sets the lifetime of assumed-role credentials.
+func WithRoleDuration(d time.Duration) Option {
+	return func(o *options) { o.svc.roleDuration = d }
+}
+
+// WithFIPS makes Open resolve the region's FIPS 140-2 DynamoDB endpoint, as
+// required in GovCloud and other regulated environments.
+func WithFIPS() Option {
+	return func(o *options) { o.svc.fips = true }
+}
+
+// WithDualStack makes Open resolve the region's dual-stack (IPv4 and IPv6)
+// DynamoDB endpoint, for IPv6-only VPCs.
+func WithDualStack() Option {
+	return func(o *options) { o.svc.dualStack = true }
+}
+
+func (sc svcConfig) awsConfig() (*aws.Config, error) {
+	cfg := aws.NewConfig()
+	if sc.region != "" {
+		cfg = cfg.WithRegion(sc.region)
+	}
+
+	if sc.fips {
+		cfg.UseFIPSEndpoint = endpoints.FIPSEndpointStateEnabled
+	}
+
+	if sc.dualStack {
+		cfg.UseDualStackEndpoint = endpoints.DualStackEndpointStateEnabled
+	}
+
+	if sc.endpoint != "" {
+		endpoint, sreg := sc.endpoint, sc.signingRegion
+		cfg = cfg.WithEndpointResolver(endpoints.ResolverFunc(
+			func(service, region string, opts ...func(*endpoints.Options)) (endpoints.ResolvedEndpoint, error) {
+				if service != dynamodb.EndpointsID {
+					return endpoints.DefaultResolver().EndpointFor(service, region, opts...)
+				}
+
+				signing := sreg
+				if signing == "" {
+					signing = region
+				}
+
+				return endpoints.ResolvedEndpoint{
+					URL:           endpoint,
+					SigningRegion: signing,
+					SigningName:   dynamodb.EndpointsID,
+					SigningMethod: "v4",
+				}, nil
+			},
+		))
+	}
+
+	client := sc.httpClient
+	if sc.proxy != "" {
+		u, err := url.Parse(sc.proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url: %w", err)
+		}
+
+		base := http.DefaultTransport.(*http.Transport)
+		if client == nil {
+			client = &http.Client{}
+		} else {
+			c := *client
+			client = &c
+			if t, ok := c.Transport.(*http.Transport); ok {
+				base = t
+			}
+		}
+
+		t := base.Clone()
+		t.Proxy = http.ProxyURL(u)
+		client.Transport = t
+	}
+
+	if client != nil {
+		cfg = cfg.WithHTTPClient(client)
+	}
+
+	return cfg, nil
+}
+
+func (sc svcConfig) session() (*session.Session, error) {
+	cfg, err := sc.awsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	sess, err := session.NewSession(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("NewSession failed: %w", err)
+	}
+
+	if sc.roleARN == "" {
+		return sess, nil
+	}
+
+	name := sc.roleSession
+	if name == "" {
+		name = fmt.Sprintf("libdy-%d", time.Now().UnixNano())
+	}
+
+	var creds *credentials.Credentials
+	if sc.tokenFile != "" {
+		creds = stscreds.NewWebIdentityCredentials(sess, sc.roleARN, name, sc.tokenFile)
+	} else {
+		creds = stscreds.NewCredentials(sess, sc.roleARN, func(p *stscreds.AssumeRoleProvider) {
+			p.RoleSessionName = name
+			p.ExpiryWindow = time.Minute // refresh a bit early
+			if sc.externalID != "" {
+				p.ExternalID = aws.String(sc.externalID)
+			}
+
+			if sc.roleDuration > 0 {
+				p.Duration = sc.roleDuration
+			}
+		})
+	}
+
+	return sess.Copy(&aws.Config{Credentials: creds}), nil
+}
+
+// Open is like New but builds the DynamoDB service handle itself from the
+// construction-time options (WithRegion, WithEndpoint, WithProxy, etc.).
+func Open(opts ...Option) (*Client, error) {
+	c := New(nil, opts...)
+	sess, err := c.opts.svc.session()
+	if err != nil {
+		return nil, err
+	}
+
+	svc := dynamodb.New(sess)
+	if c.opts.svc.gzip {
+		EnableGzip(svc)
+	}
+
+	if c.opts.svc.opPolicy != nil {
+		EnforceOpPolicy(svc, *c.opts.svc.opPolicy)
+	}
+
+	c.svc = svc
+
+	return c, nil
+}
+
+// OpenAssumeRole opens a Client whose requests use credentials obtained by
+// assuming roleARN, e.g. for cross-account table access.
+func OpenAssumeRole(roleARN, externalID string, opts ...Option) (*Client, error) {
+	return Open(append([]Option{WithAssumeRole(roleARN, externalID)}, opts...)...)
+}
+
+// OpenWebIdentity opens a Client whose requests use credentials obtained by
+// assuming roleARN with the web identity token in tokenFile.
+func OpenWebIdentity(roleARN, tokenFile string, opts ...Option) (*Client, error) {
+	return Open(append([]Option{WithWebIdentity(roleARN, tokenFile)}, opts...)...)
+}
@@ -0,0 +1,163 @@
+package libdy
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+type converter struct {
+	marshal   func(reflect.Value) (*dynamodb.AttributeValue, error)
+	unmarshal func(*dynamodb.AttributeValue) (reflect.Value, error)
+}
+
+// Converters is a registry of attribute converters for domain types (enums,
+// money, UUIDs, times with a custom precision), used by the typed helpers
+// (Query, QueryIndex, Scan, Get, and Put) and UnmarshalItems with
+// WithConverters:
+//
+//	conv := libdy.NewConverters()
+//	libdy.RegisterConverter(conv,
+//		func(s Status) (*dynamodb.AttributeValue, error) {
+//			return &dynamodb.AttributeValue{S: aws.String(s.String())}, nil
+//		},
+//		func(v *dynamodb.AttributeValue) (Status, error) {
+//			return ParseStatus(aws.StringValue(v.S))
+//		})
+//
+//	client := libdy.New(svc, libdy.WithTable("orders"), libdy.WithConverters(conv))
+//
+// Converters apply to the top-level fields of T, and to pointers to them; a
+// nil pointer is omitted. Other fields use dynamodbattribute as usual.
+type Converters struct {
+	mu sync.RWMutex
+	m  map[reflect.Type]converter
+}
+
+func NewConverters() *Converters {
+	return &Converters{m: map[reflect.Type]converter{}}
+}
+
+// RegisterConverter sets the converter of T in r, replacing any previous
+// one. A nil attribute from marshal omits the field.
+func RegisterConverter[T any](r *Converters, marshal func(T) (*dynamodb.AttributeValue, error), unmarshal func(*dynamodb.AttributeValue) (T, error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.m[reflect.TypeOf((*T)(nil)).Elem()] = converter{
+		marshal: func(v reflect.Value) (*dynamodb.AttributeValue, error) {
+			return marshal(v.Interface().(T))
+		},
+		unmarshal: func(av *dynamodb.AttributeValue) (reflect.Value, error) {
+			v, err := unmarshal(av)
+			return reflect.ValueOf(&v).Elem(), err
+		},
+	}
+}
+
+// WithConverters makes the typed helpers use the converters of r.
+func WithConverters(r *Converters) Option {
+	return func(o *options) { o.converters = r }
+}
+
+// convField is a top-level struct field with a converter.
+type convField struct {
+	index []int
+	attr  string
+	ptr   bool
+	conv  converter
+}
+
+// fields returns the fields of t with a converter.
+func (r *Converters) fields(t reflect.Type) []convField {
+	if r == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var ret []convField
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() {
+			continue
+		}
+
+		attr := f.Name
+		if tag := f.Tag.Get("dynamodbav"); tag != "" {
+			name, _, _ := strings.Cut(tag, ",")
+			if name == "-" {
+				continue
+			}
+
+			if name != "" {
+				attr = name
+			}
+		}
+
+		ft, ptr := f.Type, false
+		if ft.Kind() == reflect.Pointer {
+			ft, ptr = ft.Elem(), true
+		}
+
+		if conv, ok := r.m[ft]; ok {
+			ret = append(ret, convField{index: f.Index, attr: attr, ptr: ptr, conv: conv})
+		}
+	}
+
+	return ret
+}
+
+// lookup returns the converter of t in r, if any.
+func (r *Converters) lookup(t reflect.Type) (converter, bool) {
+	if r == nil {
+		return converter{}, false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	conv, ok := r.m[t]
+	return conv, ok
+}
+
+// marshal is dynamodbattribute.MarshalMap with the converters of r, if any.
+func (r *Converters) marshal(v interface{}) (map[string]*dynamodb.AttributeValue, error) {
+	item, err := dynamodbattribute.MarshalMap(v)
+	if err != nil {
+		return nil, fmt.Errorf("marshal failed: %w", err)
+	}
+
+	if r == nil {
+		return item, nil
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	for _, f := range r.fields(rv.Type()) {
+		fv, err := rv.FieldByIndexErr(f.index)
+		if err != nil { // nil embedded pointer
+			continue
+		}
+
+		delete(item, f.attr)
+		if f.ptr {
+			if fv.IsNil() {
+				continue
+			}
+
+			fv = fv.Elem()
+		}
+
+		av, err := f.conv.marshal(fv)
+		if err != nil {
+			return nil, fmt.Errorf("marshal failed: %s: %w", f.attr, err)
+		}
+
+		if av != nil {
+			item[f.attr] = av
+		}
+	}
+
+	return item, nil
+}
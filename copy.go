@@ -0,0 +1,97 @@
+package libdy
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// CopyConfig describes a CopyTable to another table, which may be in
+// another region or account: the destination is a Client of its own, e.g.
+// from Open with WithRegion or WithAssumeRole.
+type CopyConfig struct {
+	Dest     *Client
+	DestOpts []Option // write options, e.g. WithTable or WithWriteCapacity
+
+	// Remap maps each item to its copy, e.g. with new keys (see RenameAttrs).
+	// Returning a nil item skips it; a nil Remap copies items as is.
+	Remap Transform
+
+	Segments    int         // parallel scan segments; the default is 1
+	Checkpoints Checkpoints // optional, to resume an interrupted copy
+
+	// Progress, if set, is called after each page with the items read and
+	// written so far. It may be called concurrently.
+	Progress func(read, written int64)
+}
+
+// CopyTable copies all items of the Client's table to cfg.Dest with a
+// parallel scan and batch writes, as a Pipe (see Pipe for resuming with
+// cfg.Checkpoints, and for events). opts are read options: WithConcurrency
+// sets the number of segments read at once, and WithRateLimit or
+// WithReadCapacity pace the reads; pace the writes with WithWriteCapacity in
+// cfg.DestOpts. The destination table must exist; see EnsureTable.
+//
+//	stats, err := src.CopyTable(ctx, libdy.CopyConfig{
+//		Dest:        dst,
+//		DestOpts:    []libdy.Option{libdy.WithWriteCapacity(wcu)},
+//		Remap:       libdy.RenameAttrs(map[string]string{"id": "pk"}),
+//		Segments:    16,
+//		Checkpoints: libdy.FileCheckpoints("/var/tmp/copy.json"),
+//	}, libdy.WithConcurrency(8), libdy.WithReadCapacity(rcu))
+func (c *Client) CopyTable(ctx context.Context, cfg CopyConfig, opts ...Option) (*PipeStats, error) {
+	if cfg.Dest == nil {
+		return nil, fmt.Errorf("CopyTable failed: no destination")
+	}
+
+	var read, written int64
+	sink := TableSink(cfg.Dest, cfg.DestOpts...)
+	p := PipeConfig{
+		Segments:    cfg.Segments,
+		Transform:   cfg.Remap,
+		Sink:        sink,
+		Checkpoints: cfg.Checkpoints,
+	}
+
+	if cfg.Progress != nil {
+		p.Transform = func(item map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+			atomic.AddInt64(&read, 1)
+			if cfg.Remap == nil {
+				return item, nil
+			}
+
+			return cfg.Remap(item)
+		}
+
+		p.Sink = SinkFunc(func(ctx context.Context, items []map[string]*dynamodb.AttributeValue) error {
+			if err := sink.Write(ctx, items); err != nil {
+				return err
+			}
+
+			cfg.Progress(atomic.LoadInt64(&read), atomic.AddInt64(&written, int64(len(items))))
+			return nil
+		})
+	}
+
+	return c.Pipe(ctx, p, opts...)
+}
+
+// RenameAttrs returns a Transform renaming the attributes of each item per
+// renames, old name to new, e.g. to remap keys when copying to a table with
+// another key schema. Other attributes are kept as is.
+func RenameAttrs(renames map[string]string) Transform {
+	return func(item map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+		ret := make(map[string]*dynamodb.AttributeValue, len(item))
+		for k, v := range item {
+			if n, ok := renames[k]; ok {
+				k = n
+			}
+
+			ret[k] = v
+		}
+
+		return ret, nil
+	}
+}
@@ -0,0 +1,105 @@
+package libdy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+var (
+	// ErrBudgetExceeded matches (with errors.Is) a *BudgetExceededError.
+	ErrBudgetExceeded = errors.New("libdy: cost budget exceeded")
+)
+
+// CostBudget caps the cost of a single operation (see WithCostBudget). Zero
+// fields are not capped.
+type CostBudget struct {
+	Units float64 // consumed capacity units: RCUs for reads, WCUs for writes
+	Pages int     // requests: pages for reads, BatchWriteItem calls for writes
+}
+
+// BudgetExceededError is returned when an operation is aborted by its
+// CostBudget.
+type BudgetExceededError struct {
+	Op     string
+	Table  string
+	Budget CostBudget
+	Units  float64 // spent
+	Pages  int     // spent
+
+	// LastKey is the cursor to resume a read with WithStartKey, after the
+	// items read so far, which are dropped.
+	LastKey map[string]*dynamodb.AttributeValue
+
+	// Written is the number of requests of a batch write that were made,
+	// in order; resume with the rest.
+	Written int
+}
+
+func (e *BudgetExceededError) Error() string {
+	var budget []string
+	if e.Budget.Pages > 0 {
+		budget = append(budget, fmt.Sprintf("%d request(s)", e.Budget.Pages))
+	}
+
+	if e.Budget.Units > 0 {
+		budget = append(budget, fmt.Sprintf("%.1f unit(s)", e.Budget.Units))
+	}
+
+	return fmt.Sprintf("%s of %s aborted after %d request(s) and %.1f unit(s): budget is %s",
+		e.Op, e.Table, e.Pages, e.Units, strings.Join(budget, " and "))
+}
+
+func (e *BudgetExceededError) Is(target error) bool { return target == ErrBudgetExceeded }
+
+// WithCostBudget aborts paginated reads (Query, Scan, GetItems, their Pages
+// iterators, and so on) and batch writes with a *BudgetExceededError once
+// they have spent b and have more to do, so a runaway read triggered by bad
+// input fails fast instead of draining the table's capacity. The check is
+// made between requests, so the last request may overshoot b.Units; cap
+// the page size (WithPageSize) to bound it. A Pages iterator returns the
+// page that spent the budget, then stops with the error; a batch write
+// stops between its BatchWriteItem calls. Unprocessed items resubmitted
+// within a call count as requests too.
+func WithCostBudget(b CostBudget) Option {
+	return func(o *options) { o.cost = &b }
+}
+
+// costMeter tracks what one operation spent against its CostBudget. A nil
+// meter, without a budget, tracks nothing.
+type costMeter struct {
+	budget CostBudget
+	units  float64
+	pages  int
+}
+
+func (o options) costMeter() *costMeter {
+	if o.cost == nil {
+		return nil
+	}
+
+	return &costMeter{budget: *o.cost}
+}
+
+// add charges a request of units.
+func (m *costMeter) add(units float64) {
+	if m != nil {
+		m.units += units
+		m.pages++
+	}
+}
+
+// exceeded reports whether the budget is spent.
+func (m *costMeter) exceeded() bool {
+	if m == nil {
+		return false
+	}
+
+	return (m.budget.Units > 0 && m.units >= m.budget.Units) || (m.budget.Pages > 0 && m.pages >= m.budget.Pages)
+}
+
+func (m *costMeter) err(op, table string) *BudgetExceededError {
+	return &BudgetExceededError{Op: op, Table: table, Budget: m.budget, Units: m.units, Pages: m.pages}
+}
@@ -0,0 +1,102 @@
+package libdy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// CountResult is the outcome of a count query.
+type CountResult struct {
+	Count            int64   // items matching the query (and filter)
+	ScannedCount     int64   // items read, before the filter
+	ConsumedCapacity float64 // read capacity units, over all pages
+}
+
+func CountItems(svc dynamodbiface.DynamoDBAPI, table, pk, sk string, opts ...Option) (*CountResult, error) {
+	return CountItemsWithContext(context.Background(), svc, table, pk, sk, opts...)
+}
+
+// CountItemsWithContext is Client.Count for table.
+func CountItemsWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, pk, sk string, opts ...Option) (*CountResult, error) {
+	return New(svc, WithTable(table)).Count(ctx, pk, sk, opts...)
+}
+
+func CountGsiItems(svc dynamodbiface.DynamoDBAPI, table, index, key, value string, opts ...Option) (*CountResult, error) {
+	return CountGsiItemsWithContext(context.Background(), svc, table, index, key, value, opts...)
+}
+
+// CountGsiItemsWithContext is Client.CountIndex for table.
+func CountGsiItemsWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, index, key, value string, opts ...Option) (*CountResult, error) {
+	return New(svc, WithTable(table)).CountIndex(ctx, index, key, value, opts...)
+}
+
+// Count counts the items under pk (and, optionally, the sk prefix, or the
+// WithSortKey condition) without transferring them, paging through the
+// whole partition. With WithFilter, Count is the number of matching items.
+// WithLimit and WithProjection don't apply.
+func (c *Client) Count(ctx context.Context, pk, sk string, opts ...Option) (*CountResult, error) {
+	o, err := c.apply(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	kp, ks, err := c.keys(ctx, o, pk, sk)
+	if err != nil {
+		return nil, fmt.Errorf("Count failed: %w", err)
+	}
+
+	o.limit = 0
+	return count(ctx, c.svc, o.queryInput(kp, ks), o)
+}
+
+// CountIndex is Count for the items in the index whose key equals value.
+func (c *Client) CountIndex(ctx context.Context, index, key, value string, opts ...Option) (*CountResult, error) {
+	o, err := c.apply(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := c.indexKey(ctx, o, index, key, value)
+	if err != nil {
+		return nil, fmt.Errorf("CountIndex failed: %w", err)
+	}
+
+	o.limit = 0
+	return count(ctx, c.svc, o.indexQueryInput(index, k), o)
+}
+
+func count(ctx context.Context, svc dynamodbiface.DynamoDBAPI, in *dynamodb.QueryInput, o options) (*CountResult, error) {
+	in.Select = aws.String(dynamodb.SelectCount)
+	in.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityTotal)
+	in.ExclusiveStartKey = o.startKey
+	o.projection = nil // not allowed with COUNT
+	if o.pageSize > 0 {
+		in.Limit = aws.Int64(o.pageSize)
+	}
+
+	ret := &CountResult{}
+	pages := 0
+	for {
+		res, err := queryPage(ctx, svc, in, o)
+		if err != nil {
+			return nil, err
+		}
+
+		pages++
+		ret.Count += aws.Int64Value(res.Count)
+		ret.ScannedCount += aws.Int64Value(res.ScannedCount)
+		ret.ConsumedCapacity += capacityUnits(res.ConsumedCapacity)
+		if res.LastEvaluatedKey == nil {
+			break
+		}
+
+		in.ExclusiveStartKey = res.LastEvaluatedKey
+	}
+
+	o.reportCapacity(aws.StringValue(in.TableName), "Query", ret.ConsumedCapacity, pages)
+	return ret, nil
+}
@@ -0,0 +1,177 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+var (
+	ErrCounterLimit = errors.New("libdy: counter limit reached")
+)
+
+type counterLimits struct {
+	floor   int64
+	ceiling int64
+}
+
+// WithCounterLimits makes IncrementCounter and IncrementCounters fail with
+// ErrCounterLimit, changing nothing, if an increment would take a counter
+// above ceiling, or a decrement below floor, e.g. for quotas or stock
+// levels. Pass math.MinInt64 or math.MaxInt64 for no floor or ceiling.
+func WithCounterLimits(floor, ceiling int64) Option {
+	return func(o *options) { o.counters = &counterLimits{floor: floor, ceiling: ceiling} }
+}
+
+func IncrementCounter(svc dynamodbiface.DynamoDBAPI, table, pk, sk, field string, delta int64, opts ...Option) (int64, error) {
+	return IncrementCounterWithContext(context.Background(), svc, table, pk, sk, field, delta, opts...)
+}
+
+// IncrementCounterWithContext is Client.IncrementCounter for table.
+func IncrementCounterWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, pk, sk, field string, delta int64, opts ...Option) (int64, error) {
+	return New(svc, WithTable(table)).IncrementCounter(ctx, pk, sk, field, delta, opts...)
+}
+
+// IncrementCounter atomically adds delta (which may be negative) to the
+// number attribute field of the item with key pk and sk, and returns the new
+// value. A missing item or attribute counts as zero. Throttled requests are
+// retried per the retry policy, but other failures are not, as the increment
+// may have landed.
+func (c *Client) IncrementCounter(ctx context.Context, pk, sk, field string, delta int64, opts ...Option) (int64, error) {
+	ret, err := c.IncrementCounters(ctx, pk, sk, map[string]int64{field: delta}, opts...)
+	if err != nil {
+		return 0, err
+	}
+
+	return ret[field], nil
+}
+
+func IncrementCounters(svc dynamodbiface.DynamoDBAPI, table, pk, sk string, deltas map[string]int64, opts ...Option) (map[string]int64, error) {
+	return IncrementCountersWithContext(context.Background(), svc, table, pk, sk, deltas, opts...)
+}
+
+// IncrementCountersWithContext is Client.IncrementCounters for table.
+func IncrementCountersWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, pk, sk string, deltas map[string]int64, opts ...Option) (map[string]int64, error) {
+	return New(svc, WithTable(table)).IncrementCounters(ctx, pk, sk, deltas, opts...)
+}
+
+// IncrementCounters is IncrementCounter for several counters of the item at
+// once, by attribute name; all are incremented, or none. It returns their new
+// values.
+func (c *Client) IncrementCounters(ctx context.Context, pk, sk string, deltas map[string]int64, opts ...Option) (map[string]int64, error) {
+	o, err := c.apply(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]string, 0, len(deltas))
+	for f := range deltas {
+		fields = append(fields, f)
+	}
+
+	sort.Strings(fields)
+	u := NewUpdate()
+	for _, f := range fields {
+		u.Increment(f, deltas[f])
+	}
+
+	opts = append(opts[:len(opts):len(opts)], WithReturnValues(dynamodb.ReturnValueUpdatedNew))
+	limited := false
+	if o.counters != nil {
+		if cond, ok := o.counters.condition(fields, deltas); ok {
+			if o.condition != nil {
+				cond = andCondition(*o.condition, cond)
+			}
+
+			opts = append(opts, WithCondition(cond))
+			limited = o.condition == nil
+		}
+	}
+
+	item, err := c.UpdateItem(ctx, pk, sk, u, opts...)
+	if limited && errors.Is(err, ErrConditionFailed) {
+		return nil, fmt.Errorf("IncrementCounters failed: %w", ErrCounterLimit)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("IncrementCounters failed: %w", err)
+	}
+
+	ret := make(map[string]int64, len(fields))
+	for _, f := range fields {
+		if v := item[f]; v != nil {
+			ret[f], _ = strconv.ParseInt(aws.StringValue(v.N), 10, 64)
+		}
+	}
+
+	return ret, nil
+}
+
+// condition returns the condition that no counter of fields goes past l
+// when incremented by its delta, or false if none can.
+func (l *counterLimits) condition(fields []string, deltas map[string]int64) (Condition, bool) {
+	cond := Condition{
+		Label:  "counter_limits",
+		Names:  map[string]*string{},
+		Values: map[string]*dynamodb.AttributeValue{},
+	}
+
+	var exprs []string
+	for _, f := range fields {
+		d := deltas[f]
+		var op string
+		var limit int64 // the bound of the old value
+		switch {
+		case d > 0 && l.ceiling != math.MaxInt64:
+			op, limit = "<=", l.ceiling-d
+		case d < 0 && l.floor != math.MinInt64:
+			op, limit = ">=", l.floor-d
+		default:
+			continue
+		}
+
+		n, v := fmt.Sprintf("#k%d", len(exprs)), fmt.Sprintf(":k%d", len(exprs)) // apart from the update's #u, :u
+		cond.Names[n] = aws.String(f)
+		cond.Values[v] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(limit, 10))}
+		expr := n + " " + op + " " + v
+		if (op == "<=" && 0 <= limit) || (op == ">=" && 0 >= limit) {
+			expr = "(attribute_not_exists(" + n + ") OR " + expr + ")" // a missing counter is zero
+		}
+
+		exprs = append(exprs, expr)
+	}
+
+	cond.Expr = strings.Join(exprs, " AND ")
+	return cond, len(exprs) > 0
+}
+
+// andCondition returns the condition that a and b both hold. Their aliases
+// must not clash.
+func andCondition(a, b Condition) Condition {
+	ret := Condition{
+		Label:  a.label(),
+		Expr:   "(" + a.Expr + ") AND (" + b.Expr + ")",
+		Names:  map[string]*string{},
+		Values: map[string]*dynamodb.AttributeValue{},
+	}
+
+	for _, c := range []Condition{a, b} {
+		for k, v := range c.Names {
+			ret.Names[k] = v
+		}
+
+		for k, v := range c.Values {
+			ret.Values[k] = v
+		}
+	}
+
+	return ret
+}
@@ -0,0 +1,161 @@
+package libdy
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+var (
+	ErrInvalidCursor = errors.New("libdy: invalid cursor")
+)
+
+// cursorValue is a key attribute value; keys can only be S, N, or B.
+type cursorValue struct {
+	S *string `json:"S,omitempty"`
+	N *string `json:"N,omitempty"`
+	B []byte  `json:"B,omitempty"`
+}
+
+// EncodeCursor encodes a LastEvaluatedKey as an opaque, URL-safe string, for
+// handing out to API clients. An empty key encodes as "".
+func EncodeCursor(key map[string]*dynamodb.AttributeValue) (string, error) {
+	if len(key) == 0 {
+		return "", nil
+	}
+
+	m := make(map[string]cursorValue, len(key))
+	for k, v := range key {
+		m[k] = cursorValue{S: v.S, N: v.N, B: v.B}
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return "", fmt.Errorf("EncodeCursor failed: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// DecodeCursor is the inverse of EncodeCursor. "" decodes as a nil key, i.e.
+// the first page.
+func DecodeCursor(cursor string) (map[string]*dynamodb.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	var m map[string]cursorValue
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	key := make(map[string]*dynamodb.AttributeValue, len(m))
+	for k, v := range m {
+		key[k] = &dynamodb.AttributeValue{S: v.S, N: v.N, B: v.B}
+	}
+
+	return key, nil
+}
+
+// page fetches the first page of p, returning the items and the cursor of
+// the next page ("" if there is none).
+func page(ctx context.Context, p *Pages) ([]map[string]*dynamodb.AttributeValue, string, error) {
+	defer p.Close()
+	if !p.Next(ctx) {
+		if err := p.Err(); err != nil {
+			return nil, "", err
+		}
+
+		return []map[string]*dynamodb.AttributeValue{}, "", nil
+	}
+
+	next, err := EncodeCursor(p.LastKey())
+	if err != nil {
+		return nil, "", err
+	}
+
+	return p.Page(), next, nil
+}
+
+// cursorOption decodes cursor into a WithStartKey option.
+func cursorOption(cursor string, opts []Option) ([]Option, error) {
+	key, err := DecodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(opts, WithStartKey(key)), nil
+}
+
+// QueryPage reads one page of the items under pk (and, optionally, the sk
+// prefix), starting at cursor ("" for the first page). It returns the items
+// and the cursor of the next page, "" after the last one. Use WithLimit to
+// set the page size.
+func (c *Client) QueryPage(ctx context.Context, pk, sk, cursor string, opts ...Option) ([]map[string]*dynamodb.AttributeValue, string, error) {
+	opts, err := cursorOption(cursor, opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return page(ctx, c.QueryPages(pk, sk, opts...))
+}
+
+// QueryIndexPage is the paged counterpart of QueryIndex. See QueryPage.
+func (c *Client) QueryIndexPage(ctx context.Context, index, key, value, cursor string, opts ...Option) ([]map[string]*dynamodb.AttributeValue, string, error) {
+	opts, err := cursorOption(cursor, opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return page(ctx, c.QueryIndexPages(index, key, value, opts...))
+}
+
+// ScanPage is the paged counterpart of Scan. See QueryPage.
+func (c *Client) ScanPage(ctx context.Context, cursor string, opts ...Option) ([]map[string]*dynamodb.AttributeValue, string, error) {
+	opts, err := cursorOption(cursor, opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return page(ctx, c.ScanPages(opts...))
+}
+
+func GetItemsPage(svc dynamodbiface.DynamoDBAPI, table, pk, sk, cursor string, limit int64) ([]map[string]*dynamodb.AttributeValue, string, error) {
+	return GetItemsPageWithContext(context.Background(), svc, table, pk, sk, cursor, limit)
+}
+
+// GetItemsPageWithContext is the paged counterpart of GetItemsWithContext:
+// it reads up to limit items starting at cursor and returns the cursor of
+// the next page. See Client.QueryPage.
+func GetItemsPageWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, pk, sk, cursor string, limit int64) ([]map[string]*dynamodb.AttributeValue, string, error) {
+	return New(svc, WithTable(table), WithLimit(limit)).QueryPage(ctx, pk, sk, cursor)
+}
+
+func GetGsiItemsPage(svc dynamodbiface.DynamoDBAPI, table, index, key, value, cursor string, limit int64, opts ...Option) ([]map[string]*dynamodb.AttributeValue, string, error) {
+	return GetGsiItemsPageWithContext(context.Background(), svc, table, index, key, value, cursor, limit, opts...)
+}
+
+// GetGsiItemsPageWithContext is the paged counterpart of
+// GetGsiItemsWithContext. See Client.QueryPage.
+func GetGsiItemsPageWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, index, key, value, cursor string, limit int64, opts ...Option) ([]map[string]*dynamodb.AttributeValue, string, error) {
+	return New(svc, WithTable(table), WithLimit(limit)).QueryIndexPage(ctx, index, key, value, cursor, opts...)
+}
+
+func ScanItemsPage(svc dynamodbiface.DynamoDBAPI, table, cursor string, limit int64) ([]map[string]*dynamodb.AttributeValue, string, error) {
+	return ScanItemsPageWithContext(context.Background(), svc, table, cursor, limit)
+}
+
+// ScanItemsPageWithContext is the paged counterpart of ScanItemsWithContext.
+func ScanItemsPageWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, cursor string, limit int64) ([]map[string]*dynamodb.AttributeValue, string, error) {
+	return New(svc, WithTable(table), WithLimit(limit)).ScanPage(ctx, cursor)
+}
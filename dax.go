@@ -0,0 +1,30 @@
+package libdy
+
+import (
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// WithDAX sends the reads of the Client (GetItem, BatchGetItems, queries,
+// and scans) to dax, a DAX cluster client from github.com/aws/aws-dax-go,
+// which implements dynamodbiface.DynamoDBAPI, while writes and table
+// operations still go to DynamoDB. Consistent reads, which DAX doesn't
+// cache, go to DynamoDB too. Pass nil to read from DynamoDB again, e.g.
+// per call.
+//
+//	dax, err := daxclient.New(daxCfg)
+//	client := libdy.New(svc, libdy.WithTable("users"), libdy.WithDAX(dax))
+//
+// The package-level helpers take the service to use, so they read from DAX
+// when given dax itself.
+func WithDAX(dax dynamodbiface.DynamoDBAPI) Option {
+	return func(o *options) { o.dax = dax }
+}
+
+// reader returns the service the reads of o go to.
+func (c *Client) reader(o options) dynamodbiface.DynamoDBAPI {
+	if o.dax == nil || o.consistent {
+		return c.svc
+	}
+
+	return o.dax
+}
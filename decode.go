@@ -0,0 +1,365 @@
+package libdy
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// WithFieldNames sets how decoding maps the names of struct fields without
+// a dynamodbav (or json) tag name to attributes, e.g. strings.ToLower or a
+// snake_case function, instead of using the field names as they are.
+func WithFieldNames(fn func(field string) string) Option {
+	return func(o *options) { o.fieldNames = fn }
+}
+
+// WithTimeLayout makes decoding parse string attributes into time.Time
+// fields with layout, as time.Parse does, instead of as RFC 3339.
+func WithTimeLayout(layout string) Option {
+	return func(o *options) { o.timeLayout = layout }
+}
+
+// UnmarshalItem decodes item into out, a pointer to a struct or a map, as
+// dynamodbattribute.UnmarshalMap does, so callers don't need to import it.
+// Beyond dynamodbattribute, decoding:
+//
+//   - honors WithFieldNames, WithTimeLayout, and WithConverters in opts;
+//   - decodes number attributes into time.Time fields as Unix seconds;
+//   - decodes string, number, and binary sets into map[K]bool and
+//     map[K]struct{} fields, as well as slices.
+//
+// Fields of embedded structs are decoded as if they were fields of out.
+func UnmarshalItem(item map[string]*dynamodb.AttributeValue, out interface{}, opts ...Option) error {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o.unmarshal(item, out)
+}
+
+// UnmarshalItems decodes items into out, a pointer to a slice of structs or
+// maps, or of pointers to them, as UnmarshalItem does each item.
+//
+//	var orders []Order
+//	err := libdy.UnmarshalItems(res.Items, &orders, libdy.WithTimeLayout(time.DateOnly))
+func UnmarshalItems(items []map[string]*dynamodb.AttributeValue, out interface{}, opts ...Option) error {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o.unmarshalItems(items, out)
+}
+
+// Unmarshal decodes the items of r into out, a pointer to a slice, as
+// UnmarshalItems does.
+func (r *Result) Unmarshal(out interface{}, opts ...Option) error {
+	return UnmarshalItems(r.Items, out, opts...)
+}
+
+func (o options) unmarshalItems(items []map[string]*dynamodb.AttributeValue, out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("unmarshal failed: %T is not a pointer to a slice", out)
+	}
+
+	s := reflect.MakeSlice(rv.Elem().Type(), len(items), len(items))
+	for i, item := range items {
+		if err := o.unmarshal(item, s.Index(i).Addr().Interface()); err != nil {
+			return err
+		}
+	}
+
+	rv.Elem().Set(s)
+	return nil
+}
+
+// decodeField is a struct field decoded by libdy rather than
+// dynamodbattribute, or whose attribute is renamed for it.
+type decodeField struct {
+	index  []int
+	name   string
+	attr   string
+	decode func(av *dynamodb.AttributeValue, v reflect.Value) error // nil to rename only
+}
+
+// unmarshal decodes item into out, a non-nil pointer.
+func (o options) unmarshal(item map[string]*dynamodb.AttributeValue, out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("unmarshal failed: %T is not a non-nil pointer", out)
+	}
+
+	rv = rv.Elem()
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+
+		rv = rv.Elem()
+	}
+
+	fields := o.decodeFields(rv.Type(), item)
+	rest := item
+	if len(fields) > 0 {
+		rest = copyItem(item)
+		for _, f := range fields {
+			av := rest[f.attr]
+			delete(rest, f.attr) // dynamodbattribute may not decode it
+			if f.decode == nil && av != nil {
+				rest[f.name] = av
+			}
+		}
+	}
+
+	if err := dynamodbattribute.UnmarshalMap(rest, rv.Addr().Interface()); err != nil {
+		return fmt.Errorf("unmarshal failed: %w", err)
+	}
+
+	for _, f := range fields {
+		av := item[f.attr]
+		if f.decode == nil || av == nil {
+			continue
+		}
+
+		fv := allocField(rv, f.index)
+		if !fv.IsValid() { // in an unexported embedded pointer
+			continue
+		}
+
+		if fv.Kind() == reflect.Pointer {
+			p := reflect.New(fv.Type().Elem())
+			if err := f.decode(av, p.Elem()); err != nil {
+				return fmt.Errorf("unmarshal failed: %s: %w", f.attr, err)
+			}
+
+			fv.Set(p)
+			continue
+		}
+
+		if err := f.decode(av, fv); err != nil {
+			return fmt.Errorf("unmarshal failed: %s: %w", f.attr, err)
+		}
+	}
+
+	return nil
+}
+
+// decodeFields returns the fields of t that item needs decoded by libdy, or
+// renamed, if t is a struct.
+func (o options) decodeFields(t reflect.Type, item map[string]*dynamodb.AttributeValue) []decodeField {
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var ret []decodeField
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() {
+			continue
+		}
+
+		attr, tagged := fieldAttr(f)
+		if attr == "-" {
+			continue
+		}
+
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+
+		if f.Anonymous && ft.Kind() == reflect.Struct && !tagged {
+			continue // its fields are promoted
+		}
+
+		if !tagged && o.fieldNames != nil {
+			attr = o.fieldNames(f.Name)
+		}
+
+		attr = itemAttr(item, attr)
+		df := decodeField{index: f.Index, name: f.Name, attr: attr}
+		av := item[attr]
+		conv, ok := o.converters.lookup(ft)
+		switch {
+		case av == nil:
+			continue
+		case ok:
+			df.decode = func(av *dynamodb.AttributeValue, v reflect.Value) error {
+				cv, err := conv.unmarshal(av)
+				if err == nil {
+					v.Set(cv)
+				}
+
+				return err
+			}
+		case ft == timeType && (av.N != nil || (av.S != nil && o.timeLayout != "")):
+			df.decode = o.decodeTime
+		case isSetType(ft) && (av.SS != nil || av.NS != nil || av.BS != nil):
+			df.decode = decodeSet
+		case tagged || attr == f.Name:
+			continue // dynamodbattribute finds it
+		}
+
+		ret = append(ret, df)
+	}
+
+	return ret
+}
+
+// fieldAttr returns the attribute name of f per its dynamodbav, or json,
+// tag, and whether the tag set it.
+func fieldAttr(f reflect.StructField) (string, bool) {
+	for _, key := range []string{"dynamodbav", "json"} {
+		if tag := f.Tag.Get(key); tag != "" {
+			name, _, _ := strings.Cut(tag, ",")
+			if name != "" {
+				return name, true
+			}
+		}
+	}
+
+	return f.Name, false
+}
+
+// itemAttr returns the attribute of item named attr, or else one named so
+// up to case, as dynamodbattribute matches them.
+func itemAttr(item map[string]*dynamodb.AttributeValue, attr string) string {
+	if _, ok := item[attr]; ok {
+		return attr
+	}
+
+	for name := range item {
+		if strings.EqualFold(name, attr) {
+			return name
+		}
+	}
+
+	return attr
+}
+
+// allocField returns the field of v at index, allocating the embedded
+// pointers on the way. It returns the zero Value if it can't be set.
+func allocField(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}
+				}
+
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+
+			v = v.Elem()
+		}
+
+		v = v.Field(x)
+	}
+
+	if !v.CanSet() {
+		return reflect.Value{}
+	}
+
+	return v
+}
+
+// decodeTime decodes a number as Unix seconds, and a string per
+// o.timeLayout.
+func (o options) decodeTime(av *dynamodb.AttributeValue, v reflect.Value) error {
+	if av.N != nil {
+		f, err := strconv.ParseFloat(*av.N, 64)
+		if err != nil {
+			return err
+		}
+
+		sec, frac := math.Modf(f)
+		v.Set(reflect.ValueOf(time.Unix(int64(sec), int64(frac*1e9))))
+		return nil
+	}
+
+	t, err := time.Parse(o.timeLayout, *av.S)
+	if err != nil {
+		return err
+	}
+
+	v.Set(reflect.ValueOf(t))
+	return nil
+}
+
+// isSetType reports whether t is a map[K]bool or map[K]struct{}.
+func isSetType(t reflect.Type) bool {
+	if t.Kind() != reflect.Map {
+		return false
+	}
+
+	e := t.Elem()
+	return e.Kind() == reflect.Bool || (e.Kind() == reflect.Struct && e.NumField() == 0)
+}
+
+// decodeSet decodes a set into a map of set type.
+func decodeSet(av *dynamodb.AttributeValue, v reflect.Value) error {
+	elems := make([]string, 0, len(av.SS)+len(av.NS)+len(av.BS))
+	for _, s := range av.SS {
+		elems = append(elems, *s)
+	}
+
+	for _, n := range av.NS {
+		elems = append(elems, *n)
+	}
+
+	for _, b := range av.BS {
+		elems = append(elems, string(b))
+	}
+
+	t := v.Type()
+	in := reflect.New(t.Elem()).Elem()
+	if in.Kind() == reflect.Bool {
+		in.SetBool(true)
+	}
+
+	m := reflect.MakeMapWithSize(t, len(elems))
+	for _, s := range elems {
+		k := reflect.New(t.Key()).Elem()
+		switch k.Kind() {
+		case reflect.String:
+			k.SetString(s)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || k.OverflowInt(n) {
+				return fmt.Errorf("set element %q does not fit %v", s, k.Type())
+			}
+
+			k.SetInt(n)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n, err := strconv.ParseUint(s, 10, 64)
+			if err != nil || k.OverflowUint(n) {
+				return fmt.Errorf("set element %q does not fit %v", s, k.Type())
+			}
+
+			k.SetUint(n)
+		case reflect.Float32, reflect.Float64:
+			n, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return fmt.Errorf("set element %q does not fit %v", s, k.Type())
+			}
+
+			k.SetFloat(n)
+		default:
+			return fmt.Errorf("can't decode a set into %v", t)
+		}
+
+		m.SetMapIndex(k, in)
+	}
+
+	v.Set(m)
+	return nil
+}
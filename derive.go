@@ -0,0 +1,137 @@
+package libdy
+
+import (
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// DeriveFunc computes a derived attribute from the attributes of an item
+// being written. It returns nil when the attributes it depends on are not
+// part of the write, leaving the derived attribute alone.
+type DeriveFunc func(item map[string]*dynamodb.AttributeValue) *dynamodb.AttributeValue
+
+type derived struct {
+	attr string
+	fn   DeriveFunc
+	ttl  *TTL // instead of fn, for WithTTL
+}
+
+// value returns the derived attribute of item, timing TTLs by the clock of o.
+func (d derived) value(o options, item map[string]*dynamodb.AttributeValue) *dynamodb.AttributeValue {
+	if d.ttl == nil {
+		return d.fn(item)
+	}
+
+	if _, ok := item[d.attr]; ok {
+		return nil
+	}
+
+	return ExpiryValue(d.ttl.expiresAt(o.now()))
+}
+
+// WithDerived maintains attr as fn of each item written by PutItem,
+// BatchPutItems, and UpdateItem (where fn sees the attributes the update
+// sets), keeping computed index keys consistent with their sources:
+//
+//	client := libdy.New(svc, libdy.WithTable("users"),
+//		libdy.WithDerived("email_lc", libdy.Lower("email")),
+//		libdy.WithDerived("month", libdy.TimeBucket("created", time.RFC3339, "2006-01")))
+//
+// Options accumulate, so per-call WithDerived adds to the Client's.
+func WithDerived(attr string, fn DeriveFunc) Option {
+	return func(o *options) {
+		o.derived = append(o.derived[:len(o.derived):len(o.derived)], derived{attr: attr, fn: fn})
+	}
+}
+
+// Lower derives the lowercase of the string attribute src.
+func Lower(src string) DeriveFunc {
+	return func(item map[string]*dynamodb.AttributeValue) *dynamodb.AttributeValue {
+		v, ok := item[src]
+		if !ok || v.S == nil {
+			return nil
+		}
+
+		return &dynamodb.AttributeValue{S: aws.String(strings.ToLower(*v.S))}
+	}
+}
+
+// TimeBucket derives a time bucket, e.g. "2006-01" for months, from the
+// string attribute src holding a time in the given layout. Unparsable times
+// derive nothing.
+func TimeBucket(src, layout, bucket string) DeriveFunc {
+	return func(item map[string]*dynamodb.AttributeValue) *dynamodb.AttributeValue {
+		v, ok := item[src]
+		if !ok || v.S == nil {
+			return nil
+		}
+
+		t, err := time.Parse(layout, *v.S)
+		if err != nil {
+			return nil
+		}
+
+		return &dynamodb.AttributeValue{S: aws.String(t.UTC().Format(bucket))}
+	}
+}
+
+// derive returns item with the derived attributes of o set. item itself is
+// not modified.
+func (o options) derive(item map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	if len(o.derived) == 0 {
+		return item
+	}
+
+	ret := copyItem(item)
+	for _, d := range o.derived {
+		if v := d.value(o, item); v != nil {
+			ret[d.attr] = v
+		}
+	}
+
+	return ret
+}
+
+// deriveAll is derive for a batch of items.
+func (o options) deriveAll(items []map[string]*dynamodb.AttributeValue) []map[string]*dynamodb.AttributeValue {
+	if len(o.derived) == 0 {
+		return items
+	}
+
+	ret := make([]map[string]*dynamodb.AttributeValue, len(items))
+	for i, item := range items {
+		ret[i] = o.derive(item)
+	}
+
+	return ret
+}
+
+// deriveUpdate returns u with sets of the derived attributes of o computed
+// from the attributes u sets. u itself is not modified.
+func (o options) deriveUpdate(u *Update) *Update {
+	if len(o.derived) == 0 || u == nil || len(u.set) == 0 {
+		return u
+	}
+
+	set := map[string]*dynamodb.AttributeValue{}
+	for _, a := range u.set {
+		set[a.attr] = a.value
+	}
+
+	ret := *u
+	ret.set = u.set[:len(u.set):len(u.set)]
+	for _, d := range o.derived {
+		if _, ok := set[d.attr]; ok {
+			continue // set explicitly
+		}
+
+		if v := d.value(o, set); v != nil {
+			ret.set = append(ret.set, updateAction{d.attr, v})
+		}
+	}
+
+	return &ret
+}
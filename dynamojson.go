@@ -0,0 +1,135 @@
+package libdy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// JSONStyle is the JSON of ToDynamoJSON and FromDynamoJSON.
+type JSONStyle int
+
+const (
+	// TypedJSON is DynamoDB JSON, as in the API, the CLI, and ExportTable:
+	// {"id":{"S":"a"},"n":{"N":"1"}}. It round-trips all values.
+	TypedJSON JSONStyle = iota
+
+	// SimpleJSON is plain JSON: {"id":"a","n":1}. Binary values are base64
+	// strings, sets are arrays, and NULL is null, so they come back as
+	// strings, lists, and NULL; numbers keep their precision.
+	SimpleJSON
+)
+
+// ToDynamoJSON returns items as a JSON array in style, e.g. to return query
+// results from an HTTP handler, or to pipe them into jq.
+func ToDynamoJSON(items []map[string]*dynamodb.AttributeValue, style JSONStyle) ([]byte, error) {
+	out := make([]interface{}, len(items))
+	for i, item := range items {
+		m := make(map[string]interface{}, len(item))
+		for k, v := range item {
+			if style == SimpleJSON {
+				m[k] = simpleJSON(v)
+			} else {
+				m[k] = typedJSON(v)
+			}
+		}
+
+		out[i] = m
+	}
+
+	b, err := json.Marshal(out)
+	if err != nil {
+		return nil, fmt.Errorf("ToDynamoJSON failed: %w", err)
+	}
+
+	return b, nil
+}
+
+// FromDynamoJSON returns the items of b, a JSON array of objects or a
+// single object, in style.
+func FromDynamoJSON(b []byte, style JSONStyle) ([]map[string]*dynamodb.AttributeValue, error) {
+	b = bytes.TrimSpace(b)
+	if !bytes.HasPrefix(b, []byte("[")) {
+		b = append(append([]byte("["), b...), ']')
+	}
+
+	if style == SimpleJSON {
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		var objs []map[string]interface{}
+		if err := dec.Decode(&objs); err != nil {
+			return nil, fmt.Errorf("FromDynamoJSON failed: %w", err)
+		}
+
+		items := make([]map[string]*dynamodb.AttributeValue, len(objs))
+		for i, obj := range objs {
+			items[i] = jsonAV(obj).M
+		}
+
+		return items, nil
+	}
+
+	var objs []map[string]json.RawMessage
+	if err := json.Unmarshal(b, &objs); err != nil {
+		return nil, fmt.Errorf("FromDynamoJSON failed: %w", err)
+	}
+
+	items := make([]map[string]*dynamodb.AttributeValue, len(objs))
+	for i, obj := range objs {
+		items[i] = make(map[string]*dynamodb.AttributeValue, len(obj))
+		for k, raw := range obj {
+			v, err := parseTypedJSON(raw)
+			if err != nil {
+				return nil, fmt.Errorf("FromDynamoJSON failed: item %d: %s: %w", i+1, k, err)
+			}
+
+			items[i][k] = v
+		}
+	}
+
+	return items, nil
+}
+
+// simpleJSON returns v in plain JSON, for json.Marshal.
+func simpleJSON(v *dynamodb.AttributeValue) interface{} {
+	switch {
+	case v.S != nil:
+		return *v.S
+	case v.N != nil:
+		return json.Number(*v.N)
+	case v.B != nil:
+		return v.B
+	case v.BOOL != nil:
+		return *v.BOOL
+	case v.SS != nil:
+		return aws.StringValueSlice(v.SS)
+	case v.NS != nil:
+		ns := make([]json.Number, len(v.NS))
+		for i, n := range v.NS {
+			ns[i] = json.Number(aws.StringValue(n))
+		}
+
+		return ns
+	case v.BS != nil:
+		return v.BS
+	case v.M != nil:
+		m := make(map[string]interface{}, len(v.M))
+		for k, e := range v.M {
+			m[k] = simpleJSON(e)
+		}
+
+		return m
+	case v.L != nil:
+		l := make([]interface{}, len(v.L))
+		for i, e := range v.L {
+			l[i] = simpleJSON(e)
+		}
+
+		return l
+	}
+
+	return nil
+}
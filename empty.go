@@ -0,0 +1,147 @@
+package libdy
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// EmptyMode is how a kind of empty value is written (see EmptyValues).
+type EmptyMode int
+
+const (
+	EmptyDefault EmptyMode = iota // as dynamodbattribute writes it
+	EmptyOmit                     // leave the attribute out of the item
+	EmptyNull                     // a NULL attribute
+	EmptyString                   // an empty string attribute; for Strings only
+)
+
+// EmptyValues sets how the typed helpers (Put, UpdateFromStruct, and the
+// Repository writes) write the empty values of struct fields, instead of
+// as dynamodbattribute does, which writes an empty string, a nil pointer,
+// and an empty set as NULL, and a zero time.Time as "0001-01-01T00:00:00Z":
+//
+//	client := libdy.New(svc, libdy.WithTable("users"), libdy.WithEmptyValues(libdy.EmptyValues{
+//		Strings: libdy.EmptyString,
+//		Nil:     libdy.EmptyOmit,
+//		Times:   libdy.EmptyOmit,
+//	}))
+//
+// Like Converters, they apply to the top-level fields of T, except those
+// tagged omitempty, which are always left out, and those with a converter.
+// DynamoDB rejects empty key attributes, whatever the mode.
+type EmptyValues struct {
+	Strings EmptyMode // "" in string fields
+	Nil     EmptyMode // nil pointers, interfaces, maps, and slices
+	Sets    EmptyMode // empty fields tagged stringset, numberset, or binaryset
+	Times   EmptyMode // zero time.Time, or pointers to it
+}
+
+// WithEmptyValues sets how the typed helpers write empty values, per e.
+func WithEmptyValues(e EmptyValues) Option {
+	return func(o *options) { o.empty = &e }
+}
+
+// apply rewrites the attributes of item, marshaled from v, that hold empty
+// values, per e. A nil e does nothing.
+func (e *EmptyValues) apply(v interface{}, item map[string]*dynamodb.AttributeValue, conv *Converters) error {
+	if e == nil {
+		return nil
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	for _, f := range reflect.VisibleFields(rv.Type()) {
+		if !f.IsExported() {
+			continue
+		}
+
+		attr, tagged := fieldAttr(f)
+		opts := fieldTagOptions(f)
+		if attr == "-" || contains(opts, "omitempty") || (f.Anonymous && !tagged) {
+			continue
+		}
+
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+
+		if _, ok := conv.lookup(ft); ok {
+			continue
+		}
+
+		fv, err := rv.FieldByIndexErr(f.Index)
+		if err != nil { // nil embedded pointer
+			continue
+		}
+
+		ev := fv // the value pointed to, if any
+		if ev.Kind() == reflect.Pointer && !ev.IsNil() {
+			ev = ev.Elem()
+		}
+
+		kind, mode := "", EmptyDefault
+		switch {
+		case isSetField(opts) && (ev.Kind() == reflect.Slice || ev.Kind() == reflect.Map) && ev.Len() == 0:
+			kind, mode = "set", e.Sets
+		case isNil(fv):
+			kind, mode = "nil", e.Nil
+		case ev.Type() == timeType && ev.Interface().(time.Time).IsZero():
+			kind, mode = "time", e.Times
+		case ev.Kind() == reflect.String && ev.Len() == 0:
+			kind, mode = "string", e.Strings
+		}
+
+		switch mode {
+		case EmptyDefault:
+		case EmptyOmit:
+			delete(item, attr)
+		case EmptyNull:
+			item[attr] = &dynamodb.AttributeValue{NULL: aws.Bool(true)}
+		case EmptyString:
+			if kind != "string" {
+				return fmt.Errorf("marshal failed: %s: empty string for a %s value", attr, kind)
+			}
+
+			item[attr] = &dynamodb.AttributeValue{S: aws.String("")}
+		default:
+			return fmt.Errorf("marshal failed: %s: invalid empty mode %d", attr, mode)
+		}
+	}
+
+	return nil
+}
+
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+		return v.IsNil()
+	}
+
+	return false
+}
+
+func isSetField(opts []string) bool {
+	return contains(opts, "stringset") || contains(opts, "numberset") || contains(opts, "binaryset")
+}
+
+// fieldTagOptions returns the options of the dynamodbav, or json, tag of f,
+// such as omitempty.
+func fieldTagOptions(f reflect.StructField) []string {
+	for _, key := range []string{"dynamodbav", "json"} {
+		if tag := f.Tag.Get(key); tag != "" {
+			_, opts, _ := strings.Cut(tag, ",")
+			return strings.Split(opts, ",")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,392 @@
+package libdy
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+)
+
+const (
+	encryptionDesc    = "libdy_enc" // the default material description attribute
+	encryptionCache   = 1000        // data keys kept unwrapped
+	encryptionVersion = "1"
+)
+
+var (
+	ErrUpdateEncrypted = errors.New("libdy: update of an encrypted attribute")
+)
+
+// KeyProvider provides the data keys Encryption encrypts items with, each
+// wrapped by a master key that never leaves the provider.
+type KeyProvider interface {
+	// DataKey returns a new data key, and its wrapped form.
+	DataKey(ctx context.Context) (key, wrapped []byte, err error)
+
+	// Unwrap returns the data key of its wrapped form.
+	Unwrap(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+type aeadKeys struct {
+	aead cipher.AEAD
+}
+
+// AEADKeys is a KeyProvider wrapping data keys with a local cipher, e.g. an
+// AES-GCM one from a key kept in a secret store.
+func AEADKeys(aead cipher.AEAD) KeyProvider {
+	return aeadKeys{aead: aead}
+}
+
+func (p aeadKeys) DataKey(ctx context.Context) ([]byte, []byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, err
+	}
+
+	return key, seal(p.aead, key, nil), nil
+}
+
+func (p aeadKeys) Unwrap(ctx context.Context, wrapped []byte) ([]byte, error) {
+	return open(p.aead, wrapped, nil)
+}
+
+type kmsKeys struct {
+	svc   kmsiface.KMSAPI
+	keyID string
+}
+
+// KMSKeys is a KeyProvider generating data keys with the KMS key keyID.
+// Each item written costs a GenerateDataKey call; unwrapped keys are
+// cached, so reads of the same item don't each cost a Decrypt call.
+func KMSKeys(svc kmsiface.KMSAPI, keyID string) KeyProvider {
+	return kmsKeys{svc: svc, keyID: keyID}
+}
+
+func (p kmsKeys) DataKey(ctx context.Context) ([]byte, []byte, error) {
+	res, err := p.svc.GenerateDataKeyWithContext(ctx, &kms.GenerateDataKeyInput{
+		KeyId:   aws.String(p.keyID),
+		KeySpec: aws.String(kms.DataKeySpecAes256),
+	})
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("GenerateDataKey failed: %w", err)
+	}
+
+	return res.Plaintext, res.CiphertextBlob, nil
+}
+
+func (p kmsKeys) Unwrap(ctx context.Context, wrapped []byte) ([]byte, error) {
+	res, err := p.svc.DecryptWithContext(ctx, &kms.DecryptInput{
+		KeyId:          aws.String(p.keyID),
+		CiphertextBlob: wrapped,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("Decrypt failed: %w", err)
+	}
+
+	return res.Plaintext, nil
+}
+
+// Encryption encrypts attributes on the client side, so DynamoDB, its
+// backups, and its streams only see ciphertext. Each item is encrypted with
+// its own data key from a KeyProvider, with AES-GCM bound to the item key
+// and the attribute name, so encrypted values can't be moved between items
+// or attributes unnoticed. The wrapped data key and the names of the
+// encrypted attributes are kept in a material description attribute of the
+// item, which projections must include for reads to decrypt.
+//
+//	enc := libdy.NewEncryption(libdy.KMSKeys(kmsSvc, "alias/app"), "ssn", "card")
+//	client := libdy.New(svc, libdy.WithTable("users"), libdy.WithEncryption(enc))
+//
+// Key attributes, which DynamoDB must read, can't be encrypted, and neither
+// can attributes used in conditions, filters, or indexes. Items without a
+// material description, e.g. written before encryption was enabled, are
+// read as they are.
+type Encryption struct {
+	keys  KeyProvider
+	attrs []string
+	desc  string
+
+	mu       sync.Mutex
+	keyAttrs map[string][]string // key attribute names by table
+	cache    map[string][]byte   // unwrapped data keys by wrapped ones
+}
+
+// NewEncryption returns an Encryption of attrs with the data keys of keys.
+func NewEncryption(keys KeyProvider, attrs ...string) *Encryption {
+	attrs = append([]string(nil), attrs...)
+	sort.Strings(attrs)
+	return &Encryption{
+		keys:     keys,
+		attrs:    attrs,
+		desc:     encryptionDesc,
+		keyAttrs: map[string][]string{},
+		cache:    map[string][]byte{},
+	}
+}
+
+// WithDescAttr sets the material description attribute of e, "libdy_enc"
+// by default, and returns e.
+func (e *Encryption) WithDescAttr(attr string) *Encryption {
+	e.desc = attr
+	return e
+}
+
+// WithEncryption encrypts the attributes of e in the items written by
+// PutItem, BatchPutItems, a Writer or BulkLoad, and the puts of
+// TransactWriteItems (including the typed helpers and Repository), and
+// decrypts them in the items read, before the read pipeline. UpdateItem
+// fails with ErrUpdateEncrypted if it touches them.
+func WithEncryption(e *Encryption) Option {
+	return func(o *options) { o.encryption = e }
+}
+
+// tableKeys returns the key attributes of table, which the encryption of
+// its items is bound to.
+func (e *Encryption) tableKeys(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string) ([]string, error) {
+	e.mu.Lock()
+	keys, ok := e.keyAttrs[table]
+	e.mu.Unlock()
+	if ok {
+		return keys, nil
+	}
+
+	keys, err := tableKeyAttrs(ctx, svc, table)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	e.keyAttrs[table] = keys
+	e.mu.Unlock()
+	return keys, nil
+}
+
+// aad returns the associated data of attr in item: the item key, and the
+// attribute name.
+func aad(keys []string, item map[string]*dynamodb.AttributeValue, attr string) []byte {
+	m := map[string]interface{}{"a": attr}
+	k := map[string]interface{}{}
+	for _, name := range keys {
+		if v, ok := item[name]; ok {
+			k[name] = typedJSON(v)
+		}
+	}
+
+	m["k"] = k
+	b, _ := json.Marshal(m) // sorted keys, so deterministic
+	return b
+}
+
+// encrypt returns item with the attributes of e encrypted. item itself is
+// not modified.
+func (e *Encryption) encrypt(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, item map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+	var attrs []*string
+	for _, attr := range e.attrs {
+		if _, ok := item[attr]; ok {
+			attrs = append(attrs, aws.String(attr))
+		}
+	}
+
+	if len(attrs) == 0 {
+		return item, nil
+	}
+
+	keys, err := e.tableKeys(ctx, svc, table)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, attr := range attrs {
+		if slices.Contains(keys, *attr) {
+			return nil, fmt.Errorf("key attribute %s can't be encrypted", *attr)
+		}
+	}
+
+	key, wrapped, err := e.keys.DataKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := copyItem(item)
+	for _, attr := range attrs {
+		b, err := json.Marshal(typedJSON(item[*attr]))
+		if err != nil {
+			return nil, err
+		}
+
+		ret[*attr] = &dynamodb.AttributeValue{B: seal(aead, b, aad(keys, item, *attr))}
+	}
+
+	ret[e.desc] = &dynamodb.AttributeValue{M: map[string]*dynamodb.AttributeValue{
+		"v": {N: aws.String(encryptionVersion)},
+		"k": {B: wrapped},
+		"a": {SS: attrs},
+		"n": {SS: aws.StringSlice(keys)},
+	}}
+
+	return ret, nil
+}
+
+// decrypt is the decode stage of e. The names of the key attributes come
+// from the item's description, as decode doesn't know the table;
+// tampering with them fails decryption all the same.
+func (e *Encryption) decrypt(ctx context.Context, item map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+	desc, ok := item[e.desc]
+	if !ok || desc.M == nil {
+		return item, nil
+	}
+
+	v, wrapped, attrs, names := desc.M["v"], desc.M["k"], desc.M["a"], desc.M["n"]
+	if v == nil || wrapped == nil || attrs == nil || names == nil {
+		return nil, fmt.Errorf("decrypt failed: malformed %s", e.desc)
+	}
+
+	if aws.StringValue(v.N) != encryptionVersion {
+		return nil, fmt.Errorf("decrypt failed: unknown version %q", aws.StringValue(v.N))
+	}
+
+	keys := aws.StringValueSlice(names.SS)
+	key, err := e.unwrap(ctx, wrapped.B)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt failed: %w", err)
+	}
+
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt failed: %w", err)
+	}
+
+	ret := copyItem(item)
+	delete(ret, e.desc)
+	for _, attr := range attrs.SS {
+		v, ok := item[*attr]
+		if !ok {
+			continue // not projected
+		}
+
+		b, err := open(aead, v.B, aad(keys, item, *attr))
+		if err != nil {
+			return nil, fmt.Errorf("decrypt failed: %s: %w", *attr, err)
+		}
+
+		if ret[*attr], err = parseTypedJSON(b); err != nil {
+			return nil, fmt.Errorf("decrypt failed: %s: %w", *attr, err)
+		}
+	}
+
+	return ret, nil
+}
+
+// unwrap returns the data key of wrapped, from the cache if it can.
+func (e *Encryption) unwrap(ctx context.Context, wrapped []byte) ([]byte, error) {
+	e.mu.Lock()
+	key, ok := e.cache[string(wrapped)]
+	e.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	key, err := e.keys.Unwrap(ctx, wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	if len(e.cache) >= encryptionCache {
+		e.cache = map[string][]byte{}
+	}
+
+	e.cache[string(wrapped)] = key
+	e.mu.Unlock()
+	return key, nil
+}
+
+// checkUpdate fails if u touches an attribute of e.
+func (e *Encryption) checkUpdate(u *Update) error {
+	if e == nil || u == nil {
+		return nil
+	}
+
+	names := u.remove[:len(u.remove):len(u.remove)]
+	for _, actions := range [][]updateAction{u.set, u.add, u.del} {
+		for _, a := range actions {
+			names = append(names, a.attr)
+		}
+	}
+
+	for _, name := range names {
+		if name == e.desc || slices.Contains(e.attrs, name) {
+			return fmt.Errorf("%w: %s", ErrUpdateEncrypted, name)
+		}
+	}
+
+	return nil
+}
+
+// encrypt is Encryption.encrypt with the Encryption of o, if any.
+func (o options) encrypt(ctx context.Context, svc dynamodbiface.DynamoDBAPI, item map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+	if o.encryption == nil {
+		return item, nil
+	}
+
+	ret, err := o.encryption.encrypt(ctx, svc, o.table, item)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt failed: %w", err)
+	}
+
+	return ret, nil
+}
+
+// decrypt decrypts the attributes of item with the Encryption of o, if any,
+// unwrapping its data key with ctx.
+func (o options) decrypt(ctx context.Context, item map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+	if o.encryption == nil || item == nil {
+		return item, nil
+	}
+
+	return o.encryption.decrypt(ctx, item)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// seal returns a random nonce followed by the ciphertext of b.
+func seal(aead cipher.AEAD, b, ad []byte) []byte {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(b)+aead.Overhead())
+	rand.Read(nonce)
+	return aead.Seal(nonce, nonce, b, ad)
+}
+
+// open is the inverse of seal.
+func open(aead cipher.AEAD, b, ad []byte) ([]byte, error) {
+	if len(b) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	return aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], ad)
+}
@@ -0,0 +1,175 @@
+package libdy_test
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/flowerinthenight/libdy"
+	"github.com/flowerinthenight/libdy/libdytest"
+)
+
+// keys is a KeyProvider recording the ctxKey value of the contexts of the
+// unwraps.
+type keys struct {
+	libdy.KeyProvider
+	mu      sync.Mutex
+	unwraps []interface{}
+}
+
+func (k *keys) Unwrap(ctx context.Context, wrapped []byte) ([]byte, error) {
+	k.mu.Lock()
+	k.unwraps = append(k.unwraps, ctx.Value(ctxKey{}))
+	k.mu.Unlock()
+	return k.KeyProvider.Unwrap(ctx, wrapped)
+}
+
+func TestEncryption(t *testing.T) {
+	block, err := aes.NewCipher(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k := &keys{KeyProvider: libdy.AEADKeys(aead)}
+	f := libdytest.SetupFake(t, libdy.TableDef{Name: "t", PK: "id"})
+	c := libdy.New(f, libdy.WithTable("t"), libdy.WithEncryption(libdy.NewEncryption(k, "ssn")))
+	item := map[string]*dynamodb.AttributeValue{"id": {S: aws.String("a")}, "ssn": {S: aws.String("123")}}
+	if err := c.PutItem(context.Background(), item); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := libdy.New(f, libdy.WithTable("t")).GetItem(context.Background(), "id:a", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if raw["ssn"].B == nil {
+		t.Fatalf("ssn stored as %v, want it encrypted", raw["ssn"])
+	}
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "get")
+	got, err := c.GetItem(ctx, "id:a", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if aws.StringValue(got["ssn"].S) != "123" {
+		t.Errorf("ssn = %v, want 123", got["ssn"])
+	}
+
+	if len(k.unwraps) != 1 || k.unwraps[0] != "get" {
+		t.Errorf("Unwrap contexts = %v, want [get]", k.unwraps)
+	}
+}
+
+// txFake is a Fake applying the puts, updates, and deletes of
+// transactions, non-atomically and unconditionally.
+type txFake struct {
+	*libdytest.Fake
+}
+
+func (f txFake) TransactWriteItemsWithContext(ctx aws.Context, in *dynamodb.TransactWriteItemsInput, opts ...request.Option) (*dynamodb.TransactWriteItemsOutput, error) {
+	for _, item := range in.TransactItems {
+		var err error
+		switch {
+		case item.Put != nil:
+			_, err = f.PutItemWithContext(ctx, &dynamodb.PutItemInput{TableName: item.Put.TableName, Item: item.Put.Item}, opts...)
+		case item.Update != nil:
+			_, err = f.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+				TableName:                 item.Update.TableName,
+				Key:                       item.Update.Key,
+				UpdateExpression:          item.Update.UpdateExpression,
+				ExpressionAttributeNames:  item.Update.ExpressionAttributeNames,
+				ExpressionAttributeValues: item.Update.ExpressionAttributeValues,
+			}, opts...)
+		case item.Delete != nil:
+			_, err = f.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{TableName: item.Delete.TableName, Key: item.Delete.Key}, opts...)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+func TestEncryptedWrites(t *testing.T) {
+	block, err := aes.NewCipher(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	f := txFake{libdytest.SetupFake(t, libdy.TableDef{Name: "t", PK: "id"})}
+	enc := libdy.WithEncryption(libdy.NewEncryption(libdy.AEADKeys(aead), "ssn"))
+	c := libdy.New(f, libdy.WithTable("t"), enc)
+	item := func(id string) map[string]*dynamodb.AttributeValue {
+		return map[string]*dynamodb.AttributeValue{"id": {S: aws.String(id)}, "ssn": {S: aws.String("123")}}
+	}
+
+	for _, tc := range []struct {
+		id  string
+		put func(item map[string]*dynamodb.AttributeValue) error
+	}{
+		{"writer", func(item map[string]*dynamodb.AttributeValue) error {
+			w := c.Writer(libdy.WriterConfig{})
+			if err := w.Put(ctx, item); err != nil {
+				return err
+			}
+
+			return w.Close(ctx)
+		}},
+		{"bulk", func(item map[string]*dynamodb.AttributeValue) error {
+			items := make(chan map[string]*dynamodb.AttributeValue, 1)
+			items <- item
+			close(items)
+			_, err := c.BulkLoad(ctx, items, libdy.BulkLoadConfig{})
+			return err
+		}},
+		{"tx", func(item map[string]*dynamodb.AttributeValue) error {
+			return c.TransactWriteItems(ctx, []libdy.TxOp{libdy.TxPut("", item)})
+		}},
+		{"tx-all", func(item map[string]*dynamodb.AttributeValue) error {
+			return c.TransactWriteAll(ctx, []libdy.TxOp{libdy.TxPut("", item)}, nil)
+		}},
+		{"tx-func", func(item map[string]*dynamodb.AttributeValue) error {
+			return libdy.TransactWriteItemsWithContext(ctx, f, []libdy.TxOp{libdy.TxPut("t", item)}, enc)
+		}},
+		{"sync", func(item map[string]*dynamodb.AttributeValue) error {
+			_, err := libdy.SyncReferenceDataWithContext(ctx, f, "t", []map[string]*dynamodb.AttributeValue{item}, enc)
+			return err
+		}},
+	} {
+		if err := tc.put(item(tc.id)); err != nil {
+			t.Errorf("%s: %v", tc.id, err)
+			continue
+		}
+
+		raw, err := libdy.New(f, libdy.WithTable("t")).GetItem(ctx, "id:"+tc.id, "")
+		if err != nil || raw["ssn"].B == nil {
+			t.Errorf("%s: ssn stored as %v, %v, want it encrypted", tc.id, raw["ssn"], err)
+			continue
+		}
+
+		got, err := c.GetItem(ctx, "id:"+tc.id, "")
+		if err != nil || aws.StringValue(got["ssn"].S) != "123" {
+			t.Errorf("%s: ssn read as %v, %v, want 123", tc.id, got["ssn"], err)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package libdy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// indexPoll is how often SyncIndexes checks whether a new index is ACTIVE.
+const indexPoll = 5 * time.Second
+
+// EntityDef declares an entity type stored in a table, with the global
+// secondary indexes its access patterns need. Entities sharing a table may
+// declare the same index, as long as they define it the same way.
+type EntityDef struct {
+	Name    string
+	Indexes []IndexDef
+}
+
+// Entities is a registry of the entity types of each table, which keeps the
+// indexes of the tables in line with the code (see SyncIndexes). Register
+// them at startup:
+//
+//	entities := libdy.NewEntities()
+//	entities.Register("app", libdy.EntityDef{
+//		Name: "order",
+//		Indexes: []libdy.IndexDef{
+//			{Name: "by-customer", PK: "customer", SK: "created:N"},
+//		},
+//	})
+type Entities struct {
+	mu       sync.RWMutex
+	entities map[string]map[string]EntityDef
+}
+
+func NewEntities() *Entities {
+	return &Entities{entities: map[string]map[string]EntityDef{}}
+}
+
+// Register adds the entity type e to table, replacing any previous one of
+// the same name.
+func (r *Entities) Register(table string, e EntityDef) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.entities[table] == nil {
+		r.entities[table] = map[string]EntityDef{}
+	}
+
+	r.entities[table][e.Name] = e
+}
+
+// indexes returns the indexes declared for table, by name, failing if two
+// entities define an index differently.
+func (r *Entities) indexes(table string) (map[string]IndexDef, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.entities[table]))
+	for name := range r.entities[table] {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	ret := map[string]IndexDef{}
+	by := map[string]string{}
+	for _, name := range names {
+		for _, x := range r.entities[table][name].Indexes {
+			if x.Name == "" || x.PK == "" {
+				return nil, fmt.Errorf("invalid entity %s: index without name or PK", name)
+			}
+
+			if prev, ok := ret[x.Name]; ok && prev != x {
+				return nil, fmt.Errorf("index %s defined differently by entities %s and %s", x.Name, by[x.Name], name)
+			}
+
+			ret[x.Name] = x
+			by[x.Name] = name
+		}
+	}
+
+	return ret, nil
+}
+
+// IndexPlan lists the differences SyncIndexes found between the declared
+// indexes of a table and the ones it has.
+type IndexPlan struct {
+	Create  []IndexDef // declared but missing; created unless WithDryRun
+	Changed []string   // declared with another key schema; reported only
+	Extra   []string   // not declared by any entity; reported only
+}
+
+func SyncIndexes(svc dynamodbiface.DynamoDBAPI, table string, r *Entities, opts ...Option) (*IndexPlan, error) {
+	return SyncIndexesWithContext(context.Background(), svc, table, r, opts...)
+}
+
+// SyncIndexesWithContext compares the global secondary indexes declared by
+// the entities of table in r with the ones the table has, and creates the
+// missing ones, one at a time, waiting for each to be ACTIVE (which may take
+// a while on a large table; bound it with ctx). Indexes whose key schema
+// differs, and indexes no entity declares, are only reported, as replacing
+// or deleting an index may break readers still using it. On provisioned
+// tables, new indexes without RCU and WCU get the table's.
+//
+// Use WithDryRun to only compute the plan. On failure, the plan is returned
+// along with the error; indexes before the failing one were created.
+func SyncIndexesWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, r *Entities, opts ...Option) (*IndexPlan, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	declared, err := r.indexes(table)
+	if err != nil {
+		return nil, fmt.Errorf("SyncIndexes failed: %w", err)
+	}
+
+	t, err := DescribeTableWithContext(ctx, svc, table)
+	if err != nil {
+		return nil, fmt.Errorf("SyncIndexes failed: %w", err)
+	}
+
+	plan := &IndexPlan{}
+	existing := map[string]bool{}
+	for _, gsi := range t.GlobalSecondaryIndexes {
+		name := aws.StringValue(gsi.IndexName)
+		existing[name] = true
+		x, ok := declared[name]
+		switch {
+		case !ok:
+			plan.Extra = append(plan.Extra, name)
+		case !sameKeySchema(gsi.KeySchema, keySchema(x.PK, x.SK, map[string]string{})):
+			plan.Changed = append(plan.Changed, name)
+		}
+	}
+
+	names := make([]string, 0, len(declared))
+	for name := range declared {
+		if !existing[name] {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		plan.Create = append(plan.Create, declared[name])
+	}
+
+	if o.dryRun {
+		return plan, nil
+	}
+
+	for _, x := range plan.Create {
+		if err := createIndex(ctx, svc, t, x); err != nil {
+			return plan, fmt.Errorf("SyncIndexes failed: %w", err)
+		}
+	}
+
+	return plan, nil
+}
+
+// createIndex creates the index x of table t, and waits until it's ACTIVE.
+func createIndex(ctx context.Context, svc dynamodbiface.DynamoDBAPI, t *dynamodb.TableDescription, x IndexDef) error {
+	table := aws.StringValue(t.TableName)
+	defined := map[string]string{}
+	for _, a := range t.AttributeDefinitions {
+		defined[aws.StringValue(a.AttributeName)] = aws.StringValue(a.AttributeType)
+	}
+
+	attrs := map[string]string{}
+	gsi := &dynamodb.CreateGlobalSecondaryIndexAction{
+		IndexName:  aws.String(x.Name),
+		KeySchema:  keySchema(x.PK, x.SK, attrs),
+		Projection: &dynamodb.Projection{ProjectionType: aws.String(dynamodb.ProjectionTypeAll)},
+	}
+
+	// UpdateTable wants the definitions of all key attributes involved.
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	var defs []*dynamodb.AttributeDefinition
+	for _, name := range names {
+		typ := attrs[name]
+		if cur, ok := defined[name]; ok && cur != typ {
+			return fmt.Errorf("index %s: attribute %s is defined as %s, not %s", x.Name, name, cur, typ)
+		}
+
+		defs = append(defs, &dynamodb.AttributeDefinition{AttributeName: aws.String(name), AttributeType: aws.String(typ)})
+	}
+
+	if t.BillingModeSummary == nil || aws.StringValue(t.BillingModeSummary.BillingMode) != dynamodb.BillingModePayPerRequest {
+		rcu, wcu := x.RCU, x.WCU
+		if pt := t.ProvisionedThroughput; pt != nil && (rcu == 0 || wcu == 0) {
+			rcu, wcu = aws.Int64Value(pt.ReadCapacityUnits), aws.Int64Value(pt.WriteCapacityUnits)
+		}
+
+		gsi.ProvisionedThroughput = &dynamodb.ProvisionedThroughput{ReadCapacityUnits: aws.Int64(rcu), WriteCapacityUnits: aws.Int64(wcu)}
+	}
+
+	if _, err := CreateIndexWithContext(ctx, svc, table, gsi, defs...); err != nil {
+		return err
+	}
+
+	tick := time.NewTicker(indexPoll)
+	defer tick.Stop()
+	for {
+		t, err := DescribeTableWithContext(ctx, svc, table)
+		if err != nil {
+			return err
+		}
+
+		for _, gsi := range t.GlobalSecondaryIndexes {
+			if aws.StringValue(gsi.IndexName) == x.Name && aws.StringValue(gsi.IndexStatus) == dynamodb.IndexStatusActive {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for index %s canceled: %w", x.Name, ctx.Err())
+		case <-tick.C:
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+var (
+	// ErrConditionFailed matches (with errors.Is) writes rejected because
+	// their condition didn't hold.
+	ErrConditionFailed = errors.New("libdy: condition check failed")
+
+	// ErrThroughputExceeded matches requests throttled by DynamoDB, including
+	// those that stayed throttled until the retries gave up.
+	ErrThroughputExceeded = errors.New("libdy: throughput exceeded")
+
+	// ErrTableNotFound matches requests on a table (or index) that doesn't
+	// exist, or isn't active yet.
+	ErrTableNotFound = errors.New("libdy: table not found")
+
+	// ErrTransactionCanceled matches canceled transactions; errors.As with a
+	// *TxCanceledError gives the reasons.
+	ErrTransactionCanceled = errors.New("libdy: transaction canceled")
+
+	// ErrTransactionConflict matches requests that conflicted with an ongoing
+	// transaction on the same item.
+	ErrTransactionConflict = errors.New("libdy: transaction conflict")
+
+	// ErrInvalidRequest matches requests rejected by validation (see
+	// IsValidation), such as a bad expression or key schema mismatch.
+	ErrInvalidRequest = errors.New("libdy: invalid request")
+
+	// ErrItemTooLarge matches writes of items over the 400KB limit.
+	ErrItemTooLarge = errors.New("libdy: item too large")
+)
+
+// Error codes not exported by the v1 dynamodb package.
+const (
+	errCodeThrottling         = "ThrottlingException"
+	errCodeThrottlingShort    = "Throttling"
+	errCodeServiceUnavailable = "ServiceUnavailable"
+	errCodeValidation         = "ValidationException"
+)
+
+// ErrorCode returns the AWS error code in err's chain, for both AWS SDK v1
+// (awserr.Error) and v2 (smithy.APIError) errors, or "" if there is none.
+func ErrorCode(err error) string {
+	var v1 awserr.Error
+	if errors.As(err, &v1) {
+		return v1.Code()
+	}
+
+	var v2 interface{ ErrorCode() string }
+	if errors.As(err, &v2) {
+		return v2.ErrorCode()
+	}
+
+	return ""
+}
+
+// statusCode returns the HTTP status code in err's chain, or 0.
+func statusCode(err error) int {
+	var v1 awserr.RequestFailure
+	if errors.As(err, &v1) {
+		return v1.StatusCode()
+	}
+
+	var v2 interface{ HTTPStatusCode() int }
+	if errors.As(err, &v2) {
+		return v2.HTTPStatusCode()
+	}
+
+	return 0
+}
+
+// IsThrottle reports whether err is a throttling error, i.e. provisioned
+// throughput or request rate exceeded.
+func IsThrottle(err error) bool {
+	switch ErrorCode(err) {
+	case dynamodb.ErrCodeProvisionedThroughputExceededException,
+		dynamodb.ErrCodeRequestLimitExceeded,
+		errCodeThrottling,
+		errCodeThrottlingShort:
+		return true
+	}
+
+	return false
+}
+
+// IsConditionalCheckFailed reports whether err is a failed condition check
+// on a conditional write, including a transaction canceled by one.
+func IsConditionalCheckFailed(err error) bool {
+	var tce *TxCanceledError
+	if errors.As(err, &tce) && tce.Has(TxReasonConditionalCheckFailed) {
+		return true
+	}
+
+	return ErrorCode(err) == dynamodb.ErrCodeConditionalCheckFailedException
+}
+
+// IsValidation reports whether err is a request validation error. These are
+// caused by the request itself and are never worth retrying.
+func IsValidation(err error) bool {
+	switch ErrorCode(err) {
+	case errCodeValidation, request.InvalidParameterErrCode, request.ParamRequiredErrCode:
+		return true
+	}
+
+	return false
+}
+
+// IsTransient reports whether err is likely to go away on retry: throttling,
+// server-side errors, transaction conflicts, and network timeouts.
+// Cancellation and deadline errors are not transient.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if IsThrottle(err) {
+		return true
+	}
+
+	switch ErrorCode(err) {
+	case dynamodb.ErrCodeInternalServerError,
+		dynamodb.ErrCodeTransactionConflictException,
+		errCodeServiceUnavailable,
+		request.ErrCodeResponseTimeout,
+		request.ErrCodeRequestError:
+		return true
+	}
+
+	if sc := statusCode(err); sc >= 500 {
+		return true
+	}
+
+	var nerr net.Error
+	if errors.As(err, &nerr) && nerr.Timeout() {
+		return true
+	}
+
+	var aerr awserr.Error
+	if errors.As(err, &aerr) && aerr.OrigErr() != nil {
+		return IsTransient(aerr.OrigErr())
+	}
+
+	return false
+}
+
+// sentinel returns the Err* value matching the AWS error err, or nil.
+func sentinel(err error) error {
+	switch {
+	case IsConditionalCheckFailed(err):
+		return ErrConditionFailed
+	case IsThrottle(err):
+		return ErrThroughputExceeded
+	case IsValidation(err):
+		if strings.Contains(err.Error(), "Item size") {
+			return ErrItemTooLarge
+		}
+
+		return ErrInvalidRequest
+	}
+
+	switch ErrorCode(err) {
+	case dynamodb.ErrCodeResourceNotFoundException, dynamodb.ErrCodeTableNotFoundException:
+		return ErrTableNotFound
+	case dynamodb.ErrCodeTransactionConflictException:
+		return ErrTransactionConflict
+	}
+
+	return nil
+}
+
+// awsErr marks err with its sentinel (see sentinel), so callers can use
+// errors.Is instead of matching AWS error codes.
+func awsErr(err error) error {
+	if s := sentinel(err); s != nil && !errors.Is(err, s) {
+		return fmt.Errorf("%w: %w", s, err)
+	}
+
+	return err
+}
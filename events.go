@@ -0,0 +1,238 @@
+package libdy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+)
+
+// notifyTimeout bounds each delivery to a Notifier.
+const notifyTimeout = 10 * time.Second
+
+// EventType identifies an operational event.
+type EventType string
+
+const (
+	// EventThrottleStorm: a RetryStorm detected a storm and paused the table.
+	EventThrottleStorm EventType = "throttle_storm"
+
+	// EventCircuitOpen: the CircuitBreaker of a table opened, failing its
+	// requests fast, or the storms on a table kept recurring until the
+	// RetryStorm pause reached its cap, so the table is effectively shut off
+	// until the throttling stops.
+	EventCircuitOpen EventType = "circuit_open"
+
+	// EventBackfillProgress: a Pipe is running; sent at most once per the
+	// progress interval of the Events.
+	EventBackfillProgress EventType = "backfill_progress"
+
+	// EventMigrationComplete: a Pipe finished successfully.
+	EventMigrationComplete EventType = "migration_complete"
+)
+
+// Event is an operational event of a Client, for alerting.
+type Event struct {
+	Type    EventType     `json:"type"`
+	Table   string        `json:"table"`
+	Time    time.Time     `json:"time"`
+	Message string        `json:"message"`
+	Pause   time.Duration `json:"pause,omitempty"`   // storm events
+	Read    int64         `json:"read,omitempty"`    // Pipe events
+	Written int64         `json:"written,omitempty"` // Pipe events
+}
+
+// Notifier delivers events to an external system; see SNSNotifier and
+// WebhookNotifier.
+type Notifier interface {
+	Notify(ctx context.Context, e Event) error
+}
+
+// NotifierFunc adapts a function to a Notifier.
+type NotifierFunc func(ctx context.Context, e Event) error
+
+func (f NotifierFunc) Notify(ctx context.Context, e Event) error {
+	return f(ctx, e)
+}
+
+type eventHandler struct {
+	fn func(Event)
+	n  Notifier
+}
+
+// Events dispatches the operational events of the Clients sharing it (see
+// WithEvents) to handlers and notifiers, so long-running jobs integrate with
+// alerting:
+//
+//	events := libdy.NewEvents(time.Minute)
+//	events.Publish(libdy.SNSNotifier(snssvc, topic), libdy.EventCircuitOpen, libdy.EventMigrationComplete)
+//	events.OnThrottleStorm(func(e libdy.Event) { log.Println(e.Message) })
+//	client := libdy.New(svc, libdy.WithEvents(events), libdy.WithRetryStorm(storm))
+type Events struct {
+	mu       sync.Mutex
+	handlers map[EventType][]eventHandler
+	progress time.Duration
+}
+
+// NewEvents returns an Events sending BackfillProgress at most once per
+// progress (a minute if 0) for each Pipe.
+func NewEvents(progress time.Duration) *Events {
+	if progress <= 0 {
+		progress = time.Minute
+	}
+
+	return &Events{handlers: map[EventType][]eventHandler{}, progress: progress}
+}
+
+// WithEvents reports the Client's operational events to e.
+func WithEvents(e *Events) Option {
+	return func(o *options) { o.events = e }
+}
+
+// On calls fn for the events of type t. Handlers run synchronously on the
+// path that raised the event, so they must be quick.
+func (e *Events) On(t EventType, fn func(Event)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.handlers[t] = append(e.handlers[t], eventHandler{fn: fn})
+}
+
+func (e *Events) OnThrottleStorm(fn func(Event))     { e.On(EventThrottleStorm, fn) }
+func (e *Events) OnCircuitOpen(fn func(Event))       { e.On(EventCircuitOpen, fn) }
+func (e *Events) OnBackfillProgress(fn func(Event))  { e.On(EventBackfillProgress, fn) }
+func (e *Events) OnMigrationComplete(fn func(Event)) { e.On(EventMigrationComplete, fn) }
+
+// Publish sends the events of the given types (all types if none) to n, in
+// the background. Delivery failures are logged to the Client's logger.
+func (e *Events) Publish(n Notifier, types ...EventType) {
+	if len(types) == 0 {
+		types = []EventType{EventThrottleStorm, EventCircuitOpen, EventBackfillProgress, EventMigrationComplete}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, t := range types {
+		e.handlers[t] = append(e.handlers[t], eventHandler{n: n})
+	}
+}
+
+// emit dispatches ev. A nil Events does nothing.
+func (e *Events) emit(o options, ev Event) {
+	if e == nil {
+		return
+	}
+
+	if ev.Time.IsZero() {
+		ev.Time = o.now()
+	}
+
+	e.mu.Lock()
+	handlers := e.handlers[ev.Type]
+	e.mu.Unlock()
+	for _, h := range handlers {
+		if h.fn != nil {
+			h.fn(ev)
+			continue
+		}
+
+		go func(n Notifier) {
+			ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+			defer cancel()
+			if err := n.Notify(ctx, ev); err != nil {
+				o.logf("%s event notification failed: %v", ev.Type, err)
+			}
+		}(h.n)
+	}
+}
+
+// progressFunc returns a function emitting BackfillProgress for the stats of
+// a Pipe on table, at most once per progress interval.
+func (e *Events) progressFunc(o options, table string, stats *PipeStats) func() {
+	if e == nil {
+		return func() {}
+	}
+
+	var mu sync.Mutex
+	last := time.Now()
+	return func() {
+		mu.Lock()
+		if time.Since(last) < e.progress {
+			mu.Unlock()
+			return
+		}
+
+		last = time.Now()
+		mu.Unlock()
+		read, written := stats.snapshot()
+		e.emit(o, Event{
+			Type:    EventBackfillProgress,
+			Table:   table,
+			Message: fmt.Sprintf("pipe from %s: %d item(s) read, %d written", table, read, written),
+			Read:    read,
+			Written: written,
+		})
+	}
+}
+
+// SNSNotifier publishes events to an SNS topic as JSON, with the event type
+// as the subject.
+func SNSNotifier(svc snsiface.SNSAPI, topicARN string) Notifier {
+	return NotifierFunc(func(ctx context.Context, e Event) error {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+
+		_, err = svc.PublishWithContext(ctx, &sns.PublishInput{
+			TopicArn: aws.String(topicARN),
+			Subject:  aws.String("libdy: " + string(e.Type)),
+			Message:  aws.String(string(b)),
+		})
+
+		if err != nil {
+			return fmt.Errorf("Publish failed: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// WebhookNotifier POSTs events to url as JSON. A nil client uses
+// http.DefaultClient.
+func WebhookNotifier(url string, client *http.Client) Notifier {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return NotifierFunc(func(ctx context.Context, e Event) error {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+		if err != nil {
+			return err
+		}
+
+		req.Header.Set("Content-Type", "application/json")
+		res, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("webhook failed: %w", err)
+		}
+
+		res.Body.Close()
+		if res.StatusCode/100 != 2 {
+			return fmt.Errorf("webhook failed: %s", res.Status)
+		}
+
+		return nil
+	})
+}
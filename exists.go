@@ -0,0 +1,128 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+func Exists(svc dynamodbiface.DynamoDBAPI, table, pk, sk string, opts ...Option) (bool, error) {
+	return ExistsWithContext(context.Background(), svc, table, pk, sk, opts...)
+}
+
+// ExistsWithContext is Client.Exists for table.
+func ExistsWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, pk, sk string, opts ...Option) (bool, error) {
+	o := options{table: table}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	kp, ks, err := New(svc).keys(ctx, o, pk, sk)
+	if err != nil {
+		return false, fmt.Errorf("Exists failed: %w", err)
+	}
+
+	return exists(ctx, svc, keyMap(kp, ks), o)
+}
+
+// Exists reports whether there is an item with the given key, reading its
+// key attributes only. Supports WithConsistentRead and WithHedge; the item
+// cache and the read pipeline are bypassed.
+func (c *Client) Exists(ctx context.Context, pk, sk string, opts ...Option) (bool, error) {
+	o, err := c.apply(opts)
+	if err != nil {
+		return false, err
+	}
+
+	kp, ks, err := c.keys(ctx, o, pk, sk)
+	if err != nil {
+		return false, fmt.Errorf("Exists failed: %w", err)
+	}
+
+	return exists(ctx, c.reader(o), keyMap(kp, ks), o)
+}
+
+func exists(ctx context.Context, svc dynamodbiface.DynamoDBAPI, key map[string]*dynamodb.AttributeValue, o options) (bool, error) {
+	o.projection = keyAttrNames(key)
+	_, err := getItem(ctx, svc, key, o)
+	switch {
+	case errors.Is(err, ErrItemNotFound):
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+
+	return true, nil
+}
+
+// PutResult is the result of PutIfNotExists.
+type PutResult struct {
+	Created  bool                                // false if an item with the same key was there
+	Existing map[string]*dynamodb.AttributeValue // that item, if not Created
+}
+
+func PutIfNotExists(svc dynamodbiface.DynamoDBAPI, table, keyAttr string, item map[string]*dynamodb.AttributeValue, opts ...Option) (*PutResult, error) {
+	return PutIfNotExistsWithContext(context.Background(), svc, table, keyAttr, item, opts...)
+}
+
+// PutIfNotExistsWithContext is Client.PutIfNotExists for table.
+func PutIfNotExistsWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, keyAttr string, item map[string]*dynamodb.AttributeValue, opts ...Option) (*PutResult, error) {
+	o := options{table: table}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return putIfNotExists(ctx, keyAttr, o, func(o options) error {
+		_, err := putItem(ctx, svc, item, o)
+		return err
+	})
+}
+
+// PutIfNotExists writes item unless there is an item with the same key
+// already, for "create once" flows. keyAttr is any key attribute of the
+// table, e.g. its partition key. An existing item is no error: the result
+// tells it apart, with the item, read in the same request, decoded as
+// PutItem would. WithCondition is ignored.
+//
+//	res, err := client.PutIfNotExists(ctx, "id", item)
+//	if err == nil && !res.Created {
+//		log.Printf("already created at %s", aws.StringValue(res.Existing["created"].S))
+//	}
+func (c *Client) PutIfNotExists(ctx context.Context, keyAttr string, item map[string]*dynamodb.AttributeValue, opts ...Option) (*PutResult, error) {
+	o, err := c.apply(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return putIfNotExists(ctx, keyAttr, o, func(o options) error {
+		_, err := c.putItem(ctx, item, o)
+		return err
+	})
+}
+
+// putIfNotExists runs put with o made create-only, and tells the outcome.
+func putIfNotExists(ctx context.Context, keyAttr string, o options, put func(o options) error) (*PutResult, error) {
+	cond := IfNotExists(keyAttr)
+	o.condition, o.oldOnFail = &cond, true
+	err := put(o)
+	if err == nil {
+		return &PutResult{Created: true}, nil
+	}
+
+	if !errors.Is(err, ErrConditionFailed) {
+		return nil, err
+	}
+
+	res := &PutResult{}
+	var cerr *dynamodb.ConditionalCheckFailedException
+	if errors.As(err, &cerr) {
+		if res.Existing, err = o.decode(ctx, cerr.Item); err != nil {
+			return nil, fmt.Errorf("PutIfNotExists failed: %w", err)
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,168 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+var (
+	ErrExportTooSoon = errors.New("libdy: less than 15 minutes since the last export")
+	ErrNoExportStart = errors.New("libdy: no previous export and no start time")
+)
+
+// Incremental exports cover at least 15 minutes and at most 24 hours.
+const (
+	exportMinPeriod = 15 * time.Minute
+	exportMaxPeriod = 24 * time.Hour
+)
+
+// Attributes of the export metadata item.
+const (
+	exportTimeAttr = "lastExportTime"
+	exportArnAttr  = "lastExportArn"
+)
+
+// IncrementalExport describes a scheduled incremental export of a table to
+// S3. The end of the last exported period is kept in a metadata item, so each
+// call exports the items changed since the previous one.
+type IncrementalExport struct {
+	Table       string    // table name or ARN; needs point-in-time recovery
+	Bucket      string    // destination S3 bucket
+	Prefix      string    // optional S3 key prefix
+	ViewType    string    // dynamodb.ExportViewType*; defaults to NEW_AND_OLD_IMAGES
+	MetaTable   string    // table holding the metadata item
+	MetaKey     string    // partition key of the metadata item, as in GetItem
+	MetaSortKey string    // optional sort key of the metadata item
+	Since       time.Time // start of the first period, used when there is no metadata item yet
+}
+
+func ExportIncremental(svc dynamodbiface.DynamoDBAPI, e IncrementalExport) (*dynamodb.ExportDescription, error) {
+	return ExportIncrementalWithContext(context.Background(), svc, e)
+}
+
+// ExportIncrementalWithContext starts an incremental export of the items
+// changed since the previous export (or e.Since) up to now, capped at the
+// 24 hour maximum; call it again to catch up on longer gaps. It fails with
+// ErrExportTooSoon if the period would be shorter than 15 minutes. The
+// period is claimed in the metadata item with a conditional write before the
+// export starts, so concurrent schedulers don't export the same period
+// twice; the claim is rolled back if the export can't start. The export runs
+// asynchronously; poll DescribeExport with the returned ARN for completion.
+func ExportIncrementalWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, e IncrementalExport) (*dynamodb.ExportDescription, error) {
+	arn, err := tableARN(ctx, svc, e.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := options{table: e.MetaTable, consistent: true}
+	kp, ks, err := New(svc).keys(ctx, meta, e.MetaKey, e.MetaSortKey)
+	if err != nil {
+		return nil, err
+	}
+
+	metaKey := keyMap(kp, ks)
+	item, err := getItem(ctx, svc, metaKey, meta)
+	if err != nil && !errors.Is(err, ErrItemNotFound) {
+		return nil, err
+	}
+
+	from := e.Since
+	claim := IfNotExists(kp.Name)
+	rollback := NewUpdate().Remove(exportTimeAttr)
+	if v, ok := item[exportTimeAttr]; ok {
+		from, err = time.Parse(time.RFC3339Nano, aws.StringValue(v.S))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s in export metadata: %w", exportTimeAttr, err)
+		}
+
+		claim = IfEquals(exportTimeAttr, v)
+		rollback = NewUpdate().Set(exportTimeAttr, v)
+	}
+
+	if from.IsZero() {
+		return nil, ErrNoExportStart
+	}
+
+	to := time.Now().UTC().Truncate(time.Second)
+	if to.Sub(from) > exportMaxPeriod {
+		to = from.Add(exportMaxPeriod)
+	}
+
+	if to.Sub(from) < exportMinPeriod {
+		return nil, ErrExportTooSoon
+	}
+
+	toValue := &dynamodb.AttributeValue{S: aws.String(to.Format(time.RFC3339Nano))}
+	meta.condition = &claim
+	_, err = updateItem(ctx, svc, metaKey, NewUpdate().Set(exportTimeAttr, toValue), meta)
+	if err != nil {
+		return nil, fmt.Errorf("ExportIncremental failed to claim period: %w", err)
+	}
+
+	viewType := e.ViewType
+	if viewType == "" {
+		viewType = dynamodb.ExportViewTypeNewAndOldImages
+	}
+
+	input := &dynamodb.ExportTableToPointInTimeInput{
+		TableArn:   aws.String(arn),
+		S3Bucket:   aws.String(e.Bucket),
+		ExportType: aws.String(dynamodb.ExportTypeIncrementalExport),
+		IncrementalExportSpecification: &dynamodb.IncrementalExportSpecification{
+			ExportFromTime: aws.Time(from),
+			ExportToTime:   aws.Time(to),
+			ExportViewType: aws.String(viewType),
+		},
+	}
+
+	if e.Prefix != "" {
+		input.S3Prefix = aws.String(e.Prefix)
+	}
+
+	start := time.Now()
+	var rerr error
+	var res *dynamodb.ExportTableToPointInTimeOutput
+
+	// Our retriable function.
+	op := func(ctx context.Context) error {
+		res, err = svc.ExportTableToPointInTimeWithContext(ctx, input)
+		rerr = err
+		return controlRetriable(err)
+	}
+
+	err = retry(ctx, options{}, "ExportTableToPointInTime", op)
+	switch {
+	case (err != nil || rerr != nil) && ctx.Err() != nil:
+		err = fmt.Errorf("ExportTableToPointInTime canceled after %v: %w", time.Since(start), ctx.Err())
+	case err != nil:
+		err = fmt.Errorf("ExportTableToPointInTime failed after %v: %w", time.Since(start), err)
+	case rerr != nil:
+		err = fmt.Errorf("ExportTableToPointInTime failed: %w", awsErr(rerr))
+	}
+
+	if err != nil {
+		// Give the period back, unless someone else moved on already.
+		claimed := IfEquals(exportTimeAttr, toValue)
+		meta.condition = &claimed
+		if _, rberr := updateItem(context.WithoutCancel(ctx), svc, metaKey, rollback, meta); rberr != nil {
+			return nil, errors.Join(err, fmt.Errorf("rollback failed: %w", rberr))
+		}
+
+		return nil, err
+	}
+
+	desc := res.ExportDescription
+	meta.condition = nil
+	u := NewUpdate().Set(exportArnAttr, &dynamodb.AttributeValue{S: desc.ExportArn})
+	if _, err := updateItem(ctx, svc, metaKey, u, meta); err != nil {
+		return desc, fmt.Errorf("export started but recording its ARN failed: %w", err)
+	}
+
+	return desc, nil
+}
@@ -0,0 +1,296 @@
+package libdy
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// FailoverConfig configures a Failover.
+type FailoverConfig struct {
+	// Failures is the number of consecutive server errors or timeouts of
+	// the primary that fail over to the secondary. The default is 5.
+	Failures int
+
+	// CheckInterval is how often the primary is health checked while
+	// failed over, to fail back once it's healthy. The default is 30s.
+	CheckInterval time.Duration
+
+	// HealthCheck checks svc, the primary. The default calls
+	// DescribeLimits, which reads no table.
+	HealthCheck func(ctx context.Context, svc dynamodbiface.DynamoDBAPI) error
+
+	// OnFailover, if set, is called when requests switch from one service
+	// to the other, named per Primary and Secondary. Failing back to the
+	// primary calls it from a background health check.
+	OnFailover func(from, to string)
+
+	// Primary and Secondary name the services for OnFailover and Active,
+	// "primary" and "secondary" by default (the regions with OpenFailover).
+	Primary, Secondary string
+}
+
+// Failover is a dynamodbiface.DynamoDBAPI sending requests to a primary
+// service, and to a secondary one, e.g. in another region of a global
+// table, while the primary is failing: once it returns Failures server
+// errors or timeouts in a row, the request that tripped it is retried on the
+// secondary, and so are the next ones until a health check of the primary
+// succeeds. Throttling and client errors don't count.
+//
+//	svc := libdy.NewFailover(east, west, libdy.FailoverConfig{
+//		OnFailover: func(from, to string) { log.Printf("failover: %s -> %s", from, to) },
+//	})
+//	client := libdy.New(svc, libdy.WithTable("orders"))
+//
+// Item, query, scan, batch, transaction, PartiQL, and DescribeTable requests
+// fail over; other operations always go to the primary. Writes on the
+// secondary replicate back as global table writes do, last writer wins.
+type Failover struct {
+	dynamodbiface.DynamoDBAPI // the primary, for the operations not failed over
+
+	svcs     [2]dynamodbiface.DynamoDBAPI
+	names    [2]string
+	cfg      FailoverConfig
+	mu       sync.Mutex
+	active   int // index in svcs
+	failures int
+	checked  time.Time
+	checking bool
+}
+
+func NewFailover(primary, secondary dynamodbiface.DynamoDBAPI, cfg FailoverConfig) *Failover {
+	if cfg.Failures <= 0 {
+		cfg.Failures = 5
+	}
+
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = 30 * time.Second
+	}
+
+	if cfg.HealthCheck == nil {
+		cfg.HealthCheck = func(ctx context.Context, svc dynamodbiface.DynamoDBAPI) error {
+			_, err := svc.DescribeLimitsWithContext(ctx, &dynamodb.DescribeLimitsInput{})
+			return err
+		}
+	}
+
+	f := &Failover{
+		DynamoDBAPI: primary,
+		svcs:        [2]dynamodbiface.DynamoDBAPI{primary, secondary},
+		names:       [2]string{cfg.Primary, cfg.Secondary},
+		cfg:         cfg,
+	}
+
+	if f.names[0] == "" {
+		f.names[0] = "primary"
+	}
+
+	if f.names[1] == "" {
+		f.names[1] = "secondary"
+	}
+
+	return f
+}
+
+// OpenFailover opens a Client on the regions primary and secondary, as Open
+// does with opts and WithRegion, failing over per cfg (see Failover).
+func OpenFailover(primary, secondary string, cfg FailoverConfig, opts ...Option) (*Client, error) {
+	opts = opts[:len(opts):len(opts)]
+	c, err := Open(append(opts, WithRegion(primary))...)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := Open(append(opts, WithRegion(secondary))...)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Primary == "" {
+		cfg.Primary = primary
+	}
+
+	if cfg.Secondary == "" {
+		cfg.Secondary = secondary
+	}
+
+	c.svc = NewFailover(c.svc, s.svc, cfg)
+	return c, nil
+}
+
+// Active returns the name of the service requests go to.
+func (f *Failover) Active() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.names[f.active]
+}
+
+// isOutage reports whether err counts towards failing over.
+func isOutage(err error) bool {
+	if !IsTransient(err) || IsThrottle(err) {
+		return false
+	}
+
+	return ErrorCode(err) != dynamodb.ErrCodeTransactionConflictException
+}
+
+// current returns the index of the service to send a request to, starting a
+// health check of the primary if one is due.
+func (f *Failover) current() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.active == 1 && !f.checking && time.Since(f.checked) >= f.cfg.CheckInterval {
+		f.checking = true
+		go f.check()
+	}
+
+	return f.active
+}
+
+// observe records the result of a request to the primary, and reports
+// whether it failed over.
+func (f *Failover) observe(err error) bool {
+	f.mu.Lock()
+	if !isOutage(err) {
+		f.failures = 0
+		f.mu.Unlock()
+		return false
+	}
+
+	f.failures++
+	if f.failures < f.cfg.Failures || f.active == 1 {
+		active := f.active == 1
+		f.mu.Unlock()
+		return active
+	}
+
+	notify := f.switchTo(1)
+	f.mu.Unlock()
+	notify()
+	return true
+}
+
+// switchTo makes svcs[i] the active service, returning the func to call,
+// without f.mu, to notify OnFailover. f.mu must be held.
+func (f *Failover) switchTo(i int) func() {
+	from := f.active
+	f.active, f.failures, f.checked = i, 0, time.Now()
+	return func() {
+		if f.cfg.OnFailover != nil {
+			f.cfg.OnFailover(f.names[from], f.names[i])
+		}
+	}
+}
+
+// check health checks the primary, failing back to it if it's healthy.
+func (f *Failover) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := f.cfg.HealthCheck(ctx, f.svcs[0])
+
+	f.mu.Lock()
+	f.checking, f.checked = false, time.Now()
+	if err != nil || f.active == 0 {
+		f.mu.Unlock()
+		return
+	}
+
+	notify := f.switchTo(0)
+	f.mu.Unlock()
+	notify()
+}
+
+// failover calls call on the active service of f, and again on the secondary
+// if the primary fails over on it.
+func failover[T any](f *Failover, call func(svc dynamodbiface.DynamoDBAPI) (T, error)) (T, error) {
+	i := f.current()
+	v, err := call(f.svcs[i])
+	if i == 0 && f.observe(err) {
+		return call(f.svcs[1])
+	}
+
+	return v, err
+}
+
+func (f *Failover) GetItemWithContext(ctx aws.Context, in *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	return failover(f, func(svc dynamodbiface.DynamoDBAPI) (*dynamodb.GetItemOutput, error) {
+		return svc.GetItemWithContext(ctx, in, opts...)
+	})
+}
+
+func (f *Failover) PutItemWithContext(ctx aws.Context, in *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	return failover(f, func(svc dynamodbiface.DynamoDBAPI) (*dynamodb.PutItemOutput, error) {
+		return svc.PutItemWithContext(ctx, in, opts...)
+	})
+}
+
+func (f *Failover) UpdateItemWithContext(ctx aws.Context, in *dynamodb.UpdateItemInput, opts ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	return failover(f, func(svc dynamodbiface.DynamoDBAPI) (*dynamodb.UpdateItemOutput, error) {
+		return svc.UpdateItemWithContext(ctx, in, opts...)
+	})
+}
+
+func (f *Failover) DeleteItemWithContext(ctx aws.Context, in *dynamodb.DeleteItemInput, opts ...request.Option) (*dynamodb.DeleteItemOutput, error) {
+	return failover(f, func(svc dynamodbiface.DynamoDBAPI) (*dynamodb.DeleteItemOutput, error) {
+		return svc.DeleteItemWithContext(ctx, in, opts...)
+	})
+}
+
+func (f *Failover) QueryWithContext(ctx aws.Context, in *dynamodb.QueryInput, opts ...request.Option) (*dynamodb.QueryOutput, error) {
+	return failover(f, func(svc dynamodbiface.DynamoDBAPI) (*dynamodb.QueryOutput, error) {
+		return svc.QueryWithContext(ctx, in, opts...)
+	})
+}
+
+func (f *Failover) ScanWithContext(ctx aws.Context, in *dynamodb.ScanInput, opts ...request.Option) (*dynamodb.ScanOutput, error) {
+	return failover(f, func(svc dynamodbiface.DynamoDBAPI) (*dynamodb.ScanOutput, error) {
+		return svc.ScanWithContext(ctx, in, opts...)
+	})
+}
+
+func (f *Failover) BatchGetItemWithContext(ctx aws.Context, in *dynamodb.BatchGetItemInput, opts ...request.Option) (*dynamodb.BatchGetItemOutput, error) {
+	return failover(f, func(svc dynamodbiface.DynamoDBAPI) (*dynamodb.BatchGetItemOutput, error) {
+		return svc.BatchGetItemWithContext(ctx, in, opts...)
+	})
+}
+
+func (f *Failover) BatchWriteItemWithContext(ctx aws.Context, in *dynamodb.BatchWriteItemInput, opts ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
+	return failover(f, func(svc dynamodbiface.DynamoDBAPI) (*dynamodb.BatchWriteItemOutput, error) {
+		return svc.BatchWriteItemWithContext(ctx, in, opts...)
+	})
+}
+
+func (f *Failover) TransactGetItemsWithContext(ctx aws.Context, in *dynamodb.TransactGetItemsInput, opts ...request.Option) (*dynamodb.TransactGetItemsOutput, error) {
+	return failover(f, func(svc dynamodbiface.DynamoDBAPI) (*dynamodb.TransactGetItemsOutput, error) {
+		return svc.TransactGetItemsWithContext(ctx, in, opts...)
+	})
+}
+
+func (f *Failover) TransactWriteItemsWithContext(ctx aws.Context, in *dynamodb.TransactWriteItemsInput, opts ...request.Option) (*dynamodb.TransactWriteItemsOutput, error) {
+	return failover(f, func(svc dynamodbiface.DynamoDBAPI) (*dynamodb.TransactWriteItemsOutput, error) {
+		return svc.TransactWriteItemsWithContext(ctx, in, opts...)
+	})
+}
+
+func (f *Failover) ExecuteStatementWithContext(ctx aws.Context, in *dynamodb.ExecuteStatementInput, opts ...request.Option) (*dynamodb.ExecuteStatementOutput, error) {
+	return failover(f, func(svc dynamodbiface.DynamoDBAPI) (*dynamodb.ExecuteStatementOutput, error) {
+		return svc.ExecuteStatementWithContext(ctx, in, opts...)
+	})
+}
+
+func (f *Failover) BatchExecuteStatementWithContext(ctx aws.Context, in *dynamodb.BatchExecuteStatementInput, opts ...request.Option) (*dynamodb.BatchExecuteStatementOutput, error) {
+	return failover(f, func(svc dynamodbiface.DynamoDBAPI) (*dynamodb.BatchExecuteStatementOutput, error) {
+		return svc.BatchExecuteStatementWithContext(ctx, in, opts...)
+	})
+}
+
+func (f *Failover) DescribeTableWithContext(ctx aws.Context, in *dynamodb.DescribeTableInput, opts ...request.Option) (*dynamodb.DescribeTableOutput, error) {
+	return failover(f, func(svc dynamodbiface.DynamoDBAPI) (*dynamodb.DescribeTableOutput, error) {
+		return svc.DescribeTableWithContext(ctx, in, opts...)
+	})
+}
@@ -0,0 +1,76 @@
+package libdy
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+)
+
+// WithFilter sets a server-side filter on Query and Scan results, e.g.
+//
+//	libdy.WithFilter(libdy.Condition{
+//		Expr:   "#s = :s AND amount > :a",
+//		Names:  map[string]*string{"#s": aws.String("status")},
+//		Values: map[string]*dynamodb.AttributeValue{
+//			":s": {S: aws.String("active")},
+//			":a": {N: aws.String("100")},
+//		},
+//	})
+//
+// or, built with Cond, libdy.WithFilter(libdy.And(libdy.Eq("status", "active"),
+// libdy.Gt("amount", 100)).Condition()).
+//
+// Filtered out items still consume read capacity, and a page may come back
+// empty. The placeholders #pk, #sk, #v, :pk, :sk, :sk2, and :v are used by
+// libdy's key conditions.
+func WithFilter(c Condition) Option {
+	return func(o *options) { o.filter, o.filterErr = &c, nil }
+}
+
+// WithFilterCondition is WithFilter for a condition made with the SDK's
+// expression package:
+//
+//	libdy.WithFilterCondition(expression.Name("status").Equal(expression.Value("active")).
+//		And(expression.Name("amount").GreaterThan(expression.Value(100))))
+func WithFilterCondition(cond expression.ConditionBuilder) Option {
+	expr, err := expression.NewBuilder().WithFilter(cond).Build()
+	if err != nil {
+		err = fmt.Errorf("invalid filter: %w", err)
+		return func(o *options) { o.filter, o.filterErr = nil, err }
+	}
+
+	c := Condition{Expr: aws.StringValue(expr.Filter()), Names: expr.Names(), Values: expr.Values()}
+	return WithFilter(c)
+}
+
+// applyFilter adds the filter of o, if any, to a Query or Scan input.
+func (o options) applyFilter(expr **string, names *map[string]*string, values *map[string]*dynamodb.AttributeValue) error {
+	if o.filterErr != nil {
+		return o.filterErr
+	}
+
+	if o.filter == nil {
+		return nil
+	}
+
+	*expr = aws.String(o.filter.Expr)
+	for k, v := range o.filter.Names {
+		if *names == nil {
+			*names = map[string]*string{}
+		}
+
+		(*names)[k] = v
+	}
+
+	for k, v := range o.filter.Values {
+		if *values == nil {
+			*values = map[string]*dynamodb.AttributeValue{}
+		}
+
+		(*values)[k] = v
+	}
+
+	return nil
+}
@@ -0,0 +1,187 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+var (
+	ErrItemNotFound = errors.New("libdy: item not found")
+)
+
+func GetItem(svc dynamodbiface.DynamoDBAPI, table, pk, sk string, opts ...Option) (map[string]*dynamodb.AttributeValue, error) {
+	return GetItemWithContext(context.Background(), svc, table, pk, sk, opts...)
+}
+
+// GetItemWithContext reads the item with the given key using the GetItem
+// API, failing with ErrItemNotFound if there is none. Supports
+// WithConsistentRead, WithProjection, and WithHedge.
+func GetItemWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, pk, sk string, opts ...Option) (map[string]*dynamodb.AttributeValue, error) {
+	o := options{table: table}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	kp, ks, err := New(svc).keys(ctx, o, pk, sk)
+	if err != nil {
+		return nil, fmt.Errorf("GetItem failed: %w", err)
+	}
+
+	return getItem(ctx, svc, keyMap(kp, ks), o)
+}
+
+func getItem(ctx context.Context, svc dynamodbiface.DynamoDBAPI, key map[string]*dynamodb.AttributeValue, o options) (map[string]*dynamodb.AttributeValue, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(o.table),
+		Key:       key,
+	}
+
+	input.ProjectionExpression, input.ExpressionAttributeNames = projection(o.projection)
+	if o.consistent {
+		input.ConsistentRead = aws.Bool(true)
+	}
+
+	input.ReturnConsumedCapacity = o.returnCapacity()
+	if err := o.readLimit.wait(ctx); err != nil {
+		return nil, fmt.Errorf("GetItem canceled: %w", err)
+	}
+
+	start := time.Now()
+	var rerr, err error
+	var res *dynamodb.GetItemOutput
+
+	// Our retriable, backoff-able function.
+	op := func(ctx context.Context) error {
+		var v interface{}
+		v, err = hedged(ctx, o.hedge, func(ctx context.Context) (interface{}, error) {
+			return svc.GetItemWithContext(ctx, input)
+		})
+
+		res, _ = v.(*dynamodb.GetItemOutput)
+		rerr = err
+		return o.retriable(err)
+	}
+
+	tries, err := retryN(ctx, o, "GetItem", op)
+	o.observe(ctx, "GetItem", o.table, start, tries, res, err, rerr)
+	if (err != nil || rerr != nil) && ctx.Err() != nil {
+		return nil, fmt.Errorf("GetItem canceled after %v: %w", time.Since(start), ctx.Err())
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("GetItem failed after %v: %w", time.Since(start), err)
+	}
+
+	if rerr != nil {
+		return nil, fmt.Errorf("GetItem failed: %w", awsErr(rerr))
+	}
+
+	units := capacityUnits(res.ConsumedCapacity)
+	o.readLimit.consume(units)
+	o.reportCapacity(o.table, "GetItem", units, 1)
+
+	if len(res.Item) == 0 {
+		return nil, ErrItemNotFound
+	}
+
+	return res.Item, nil
+}
+
+// GetItem reads the item with the given key through the read pipeline. An
+// item dropped by the pipeline is reported as ErrItemNotFound.
+func (c *Client) GetItem(ctx context.Context, pk, sk string, opts ...Option) (map[string]*dynamodb.AttributeValue, error) {
+	o, err := c.apply(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	kp, ks, err := c.keys(ctx, o, pk, sk)
+	if err != nil {
+		return nil, fmt.Errorf("GetItem failed: %w", err)
+	}
+
+	return c.getItem(ctx, kp, ks, o)
+}
+
+func (c *Client) getItem(ctx context.Context, pk, sk Key, o options) (map[string]*dynamodb.AttributeValue, error) {
+	if o.until != nil {
+		var item map[string]*dynamodb.AttributeValue
+		err := o.poll(ctx, func(ctx context.Context, o options) ([]map[string]*dynamodb.AttributeValue, error) {
+			var err error
+			item, err = c.getItem(ctx, pk, sk, o)
+			switch {
+			case errors.Is(err, ErrItemNotFound):
+				return nil, nil
+			case err != nil:
+				return nil, err
+			}
+
+			return []map[string]*dynamodb.AttributeValue{item}, nil
+		})
+
+		switch {
+		case err != nil:
+			return nil, err
+		case item == nil:
+			return nil, ErrItemNotFound
+		}
+
+		return item, nil
+	}
+
+	o.hotKeys.observe(o.table, keyMap(pk, Key{}))
+	key := keyMap(pk, sk)
+	read := func(ctx context.Context) (map[string]*dynamodb.AttributeValue, error) {
+		return getItem(ctx, c.reader(o), key, o)
+	}
+
+	var item map[string]*dynamodb.AttributeValue
+	var err error
+	if o.cache != nil && !o.consistent {
+		var hit bool
+		item, hit, err = o.cache.get(ctx, o.table, key, read)
+		if hit {
+			o.count(MetricCacheHits, map[string]string{"table": o.table}, 1)
+		} else {
+			o.count(MetricCacheMisses, map[string]string{"table": o.table}, 1)
+		}
+
+		if err == nil && item == nil {
+			err = ErrItemNotFound
+		}
+
+		item = copyItem(item) // the pipeline may modify it
+	} else {
+		item, err = read(ctx)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	item, err = o.readItem(ctx, item)
+	if err != nil {
+		return nil, err
+	}
+
+	if item == nil {
+		return nil, ErrItemNotFound
+	}
+
+	item, err = o.authorizeItem(ctx, o.table, o.checkRead([]map[string]*dynamodb.AttributeValue{item})[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if item == nil {
+		return nil, ErrItemNotFound
+	}
+
+	return item, nil
+}
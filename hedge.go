@@ -0,0 +1,67 @@
+package libdy
+
+import (
+	"context"
+	"time"
+)
+
+// WithHedge enables hedged reads: if a read request has not completed after
+// delay (typically around the p95 latency), an identical request is sent and
+// whichever returns first wins; the other one is cancelled. Only reads are
+// hedged.
+func WithHedge(delay time.Duration) Option {
+	return func(o *options) { o.hedge = delay }
+}
+
+type hedgeResult struct {
+	v   interface{}
+	err error
+}
+
+// hedged runs fn, and again concurrently if the first call hasn't returned
+// after delay. The first successful result is returned and the remaining call
+// is cancelled. If the first call fails before the delay, its error is
+// returned without hedging.
+func hedged(ctx context.Context, delay time.Duration, fn func(context.Context) (interface{}, error)) (interface{}, error) {
+	if delay <= 0 {
+		return fn(ctx)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // cancels the loser, if any
+
+	ch := make(chan hedgeResult, 2)
+	run := func() {
+		v, err := fn(ctx)
+		ch <- hedgeResult{v, err}
+	}
+
+	go run()
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	inflight, hedging := 1, false
+	var first error
+	for {
+		select {
+		case <-t.C:
+			if !hedging {
+				hedging = true
+				inflight++
+				go run()
+			}
+		case r := <-ch:
+			inflight--
+			if r.err == nil {
+				return r.v, nil
+			}
+
+			if first == nil {
+				first = r.err
+			}
+
+			if inflight == 0 {
+				return nil, first
+			}
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// idempotencyLease is the default time an operation may run before another
+// caller may take it over.
+const idempotencyLease = time.Minute
+
+var (
+	ErrInProgress = errors.New("libdy: operation in progress")
+)
+
+// IdempotencyStore makes operations, such as webhook deliveries or
+// payments, run at most once per idempotency key, and replays their result
+// to repeated requests:
+//
+//	op, err := store.Begin(ctx, event.ID)
+//	switch {
+//	case errors.Is(err, libdy.ErrInProgress):
+//		return http.StatusConflict // a concurrent delivery; retry later
+//	case err != nil:
+//		return err
+//	case op.Done:
+//		return replay(op.Result)
+//	}
+//
+//	result, err := process(event)
+//	if err != nil {
+//		op.Abort(ctx) // let a retry run it again
+//		return err
+//	}
+//
+//	return op.Complete(ctx, result)
+//
+// The table has one item per key, keyed by a string partition key "id",
+// and should have Time to Live enabled on ttl.Attr (see EnableTTL), so keys
+// expire after ttl.
+type IdempotencyStore struct {
+	c     *Client
+	opts  []Option
+	ttl   TTL
+	lease time.Duration
+}
+
+// NewIdempotencyStore returns an IdempotencyStore in the table of c (or the
+// one set in opts), whose keys expire per ttl; with no ttl.Attr, they never
+// do. lease is how long an operation may run before it is considered failed,
+// e.g. because its process died, and may be run again; 0 means a minute.
+func NewIdempotencyStore(c *Client, ttl TTL, lease time.Duration, opts ...Option) *IdempotencyStore {
+	if lease <= 0 {
+		lease = idempotencyLease
+	}
+
+	return &IdempotencyStore{c: c, opts: opts, ttl: ttl, lease: lease}
+}
+
+// IdempotentOp is an operation begun with IdempotencyStore.Begin.
+type IdempotentOp struct {
+	Key    string
+	Owner  string // the token of the caller, on the record while it runs the operation
+	Done   bool   // completed before, with Result; it must not be run again
+	Result []byte
+
+	s *IdempotencyStore
+}
+
+// Begin starts the operation key. If it was completed, the returned
+// operation is Done, with its Result, and must not be run again. If it is
+// being run by another caller, within its lease, Begin fails with
+// ErrInProgress. Otherwise, the caller owns the operation under a new Owner
+// token (from the IDGen of the options), and must run it, then call
+// Complete, or Abort if it failed.
+func (s *IdempotencyStore) Begin(ctx context.Context, key string) (*IdempotentOp, error) {
+	o, _ := s.c.apply(s.opts) // a missing table fails at the write
+	now := o.now()
+	op := &IdempotentOp{Key: key, Owner: o.newID(), s: s}
+	item := map[string]*dynamodb.AttributeValue{
+		"id":      {S: aws.String(key)},
+		"status":  {S: aws.String("pending")},
+		"owner":   {S: aws.String(op.Owner)},
+		"started": {N: aws.String(strconv.FormatInt(now.UnixMilli(), 10))},
+	}
+
+	// A new key, a stale pending one, or one expired but not deleted yet.
+	cond := Condition{
+		Label: "idempotency",
+		Expr:  "attribute_not_exists(#i0) OR (#is = :pending AND #it < :stale)",
+		Names: map[string]*string{"#i0": aws.String("id"), "#is": aws.String("status"), "#it": aws.String("started")},
+		Values: map[string]*dynamodb.AttributeValue{
+			":pending": {S: aws.String("pending")},
+			":stale":   {N: aws.String(strconv.FormatInt(now.Add(-s.lease).UnixMilli(), 10))},
+		},
+	}
+
+	if s.ttl.Attr != "" {
+		item[s.ttl.Attr] = ExpiryValue(s.ttl.expiresAt(now))
+		cond.Expr += " OR #ie < :now"
+		cond.Names["#ie"] = aws.String(s.ttl.Attr)
+		cond.Values[":now"] = ExpiryValue(now)
+	}
+
+	err := s.c.PutItem(ctx, item, append(s.opts[:len(s.opts):len(s.opts)], WithCondition(cond))...)
+	if err == nil {
+		return op, nil
+	}
+
+	if !errors.Is(err, ErrConditionFailed) {
+		return nil, fmt.Errorf("Begin failed: %w", err)
+	}
+
+	cur, err := s.c.GetItem(ctx, StringKey("id", key).String(), "", append(s.opts[:len(s.opts):len(s.opts)], WithConsistentRead())...)
+	if errors.Is(err, ErrItemNotFound) {
+		return nil, fmt.Errorf("Begin failed: %s: %w", key, ErrInProgress) // aborted meanwhile; retry
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Begin failed: %w", err)
+	}
+
+	if v := cur["status"]; v != nil && aws.StringValue(v.S) == "done" {
+		op.Owner, op.Done = "", true
+		if v := cur["result"]; v != nil {
+			op.Result = v.B
+		}
+
+		return op, nil
+	}
+
+	return nil, fmt.Errorf("Begin failed: %s: %w", key, ErrInProgress)
+}
+
+// now returns the time on the clock of the store's options.
+func (s *IdempotencyStore) now() time.Time {
+	o, _ := s.c.apply(s.opts) // a missing table fails at the write
+	return o.now()
+}
+
+// owned is the condition that op is still pending, and owned by its caller.
+func (op *IdempotentOp) owned() Condition {
+	return Condition{
+		Label: "idempotency",
+		Expr:  "#is = :pending AND #io = :owner",
+		Names: map[string]*string{"#is": aws.String("status"), "#io": aws.String("owner")},
+		Values: map[string]*dynamodb.AttributeValue{
+			":pending": {S: aws.String("pending")},
+			":owner":   {S: aws.String(op.Owner)},
+		},
+	}
+}
+
+// Complete stores result as the outcome of the operation, for Begin to
+// return from now on. It fails with ErrConditionFailed if the caller no
+// longer owns it: if it was aborted, or taken over by another caller after
+// its lease.
+func (op *IdempotentOp) Complete(ctx context.Context, result []byte) error {
+	s := op.s
+	u := NewUpdate().
+		Set("status", &dynamodb.AttributeValue{S: aws.String("done")}).
+		Remove("owner")
+
+	if result != nil {
+		u.Set("result", &dynamodb.AttributeValue{B: result})
+	}
+
+	if s.ttl.Attr != "" {
+		u.Set(s.ttl.Attr, ExpiryValue(s.ttl.expiresAt(s.now())))
+	}
+
+	opts := append(s.opts[:len(s.opts):len(s.opts)], WithCondition(op.owned()))
+	if _, err := s.c.UpdateItem(ctx, StringKey("id", op.Key).String(), "", u, opts...); err != nil {
+		return fmt.Errorf("Complete failed: %w", err)
+	}
+
+	return nil
+}
+
+// Abort forgets the operation, so it can be begun again, e.g. after a
+// failure worth retrying. Like Complete, it fails with ErrConditionFailed
+// if the caller no longer owns it, leaving the record as is.
+func (op *IdempotentOp) Abort(ctx context.Context) error {
+	s := op.s
+	opts := append(s.opts[:len(s.opts):len(s.opts)], WithCondition(op.owned()))
+	if err := s.c.DeleteItem(ctx, StringKey("id", op.Key).String(), "", opts...); err != nil {
+		return fmt.Errorf("Abort failed: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package libdy_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/flowerinthenight/libdy"
+	"github.com/flowerinthenight/libdy/libdytest"
+)
+
+// counter is an IDGen of "1", "2", and so on.
+func counter() libdy.IDGen {
+	n := 0
+	return libdy.IDGenFunc(func() string {
+		n++
+		return fmt.Sprint(n)
+	})
+}
+
+func TestIdempotency(t *testing.T) {
+	ctx := context.Background()
+	f := libdytest.SetupFake(t, libdy.TableDef{Name: "t", PK: "id"})
+	clock := libdy.NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	c := libdy.New(f, libdy.WithTable("t"), libdy.WithClock(clock), libdy.WithIDGen(counter()))
+	s := libdy.NewIdempotencyStore(c, libdy.TTL{}, time.Minute)
+
+	first, err := s.Begin(ctx, "k")
+	if err != nil || first.Done || first.Owner != "1" {
+		t.Fatalf("Begin = %+v, %v, want owned by 1", first, err)
+	}
+
+	if _, err := s.Begin(ctx, "k"); !errors.Is(err, libdy.ErrInProgress) {
+		t.Fatalf("Begin within the lease: %v, want ErrInProgress", err)
+	}
+
+	// Past its lease, another caller takes it over, and the first one
+	// can no longer complete or abort it.
+	clock.Advance(2 * time.Minute)
+	second, err := s.Begin(ctx, "k")
+	if err != nil || second.Done || second.Owner == first.Owner {
+		t.Fatalf("Begin after the lease = %+v, %v, want a new owner", second, err)
+	}
+
+	if err := first.Complete(ctx, []byte("stale")); !errors.Is(err, libdy.ErrConditionFailed) {
+		t.Errorf("Complete by the previous owner: %v, want ErrConditionFailed", err)
+	}
+
+	if err := first.Abort(ctx); !errors.Is(err, libdy.ErrConditionFailed) {
+		t.Errorf("Abort by the previous owner: %v, want ErrConditionFailed", err)
+	}
+
+	if err := second.Complete(ctx, []byte("ok")); err != nil {
+		t.Fatal(err)
+	}
+
+	op, err := s.Begin(ctx, "k")
+	if err != nil || !op.Done || string(op.Result) != "ok" {
+		t.Fatalf("Begin of a completed key = %+v, %v, want its result", op, err)
+	}
+
+	if err := second.Abort(ctx); !errors.Is(err, libdy.ErrConditionFailed) {
+		t.Errorf("Abort of a completed key: %v, want ErrConditionFailed", err)
+	}
+
+	// An aborted key is begun again right away.
+	op, err = s.Begin(ctx, "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := op.Abort(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if op, err = s.Begin(ctx, "other"); err != nil || op.Done {
+		t.Errorf("Begin after Abort = %+v, %v, want owned", op, err)
+	}
+}
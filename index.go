@@ -0,0 +1,97 @@
+package libdy
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// WithIndex makes Scan, ScanPages, ScanIter, and ParallelScan read the
+// secondary index instead of the table. Only the attributes projected into
+// the index are returned.
+func WithIndex(index string) Option {
+	return func(o *options) { o.index = index }
+}
+
+// WithSegment makes Scan, ScanPages, and ScanIter read only segment (from
+// 0) of a parallel scan in total segments, e.g. to spread a scan across
+// workers. ParallelScan ignores it.
+func WithSegment(segment, total int) Option {
+	return func(o *options) { o.segment, o.segments = segment, total }
+}
+
+// scanInput returns the input to scan the table, or index, of o.
+func (o options) scanInput() *dynamodb.ScanInput {
+	in := &dynamodb.ScanInput{TableName: aws.String(o.table)}
+	if o.index != "" {
+		in.IndexName = aws.String(o.index)
+	}
+
+	if o.segments > 0 {
+		in.Segment = aws.Int64(int64(o.segment))
+		in.TotalSegments = aws.Int64(int64(o.segments))
+	}
+
+	return in
+}
+
+func ScanIndex(svc dynamodbiface.DynamoDBAPI, table, index string, opts ...Option) ([]map[string]*dynamodb.AttributeValue, error) {
+	return ScanIndexWithContext(context.Background(), svc, table, index, opts...)
+}
+
+// ScanIndexWithContext reads all the items in a secondary index of table.
+func ScanIndexWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, index string, opts ...Option) ([]map[string]*dynamodb.AttributeValue, error) {
+	return New(svc, WithTable(table)).ScanItems(ctx, append(opts[:len(opts):len(opts)], WithIndex(index))...)
+}
+
+// QueryLocalIndex reads the items under pk (and, optionally, the sk prefix,
+// or the WithSortKey condition on the index's sort key) from the local
+// secondary index. Unlike global indexes, local ones support
+// WithConsistentRead. The read options of Query apply.
+func (c *Client) QueryLocalIndex(ctx context.Context, index, pk, sk string, opts ...Option) (*Result, error) {
+	o, err := c.apply(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	o.hotKeys.observe(o.table, itemKey(pk, ""))
+	res, err := query(ctx, c.svc, o.table, o.localIndexInput(index, ParseKey(pk), ParseKey(sk)), o)
+	if err != nil {
+		return nil, err
+	}
+
+	return o.finish(ctx, res)
+}
+
+// QueryLocalIndexPages is the page iterator counterpart of QueryLocalIndex.
+func (c *Client) QueryLocalIndexPages(index, pk, sk string, opts ...Option) *Pages {
+	o, err := c.apply(opts)
+	return c.queryPages(o.localIndexInput(index, ParseKey(pk), ParseKey(sk)), o, err)
+}
+
+// localIndexInput is queryInput on a local index.
+func (o options) localIndexInput(index string, pk, sk Key) *dynamodb.QueryInput {
+	in := o.queryInput(pk, sk)
+	in.IndexName = aws.String(index)
+	if o.consistent {
+		in.ConsistentRead = aws.Bool(true)
+	}
+
+	return in
+}
+
+func GetLsiItems(svc dynamodbiface.DynamoDBAPI, table, index, pk, sk string, opts ...Option) ([]map[string]*dynamodb.AttributeValue, error) {
+	return GetLsiItemsWithContext(context.Background(), svc, table, index, pk, sk, opts...)
+}
+
+// GetLsiItemsWithContext is Client.QueryLocalIndex for table.
+func GetLsiItemsWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, index, pk, sk string, opts ...Option) ([]map[string]*dynamodb.AttributeValue, error) {
+	res, err := New(svc, WithTable(table)).QueryLocalIndex(ctx, index, pk, sk, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Items, nil
+}
@@ -0,0 +1,402 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+)
+
+// Defaults of IngestConfig.
+const (
+	ingestMaxReceives = 5
+	ingestWaitTime    = 20 // seconds, the SQS maximum
+	ingestSeenMax     = 10000
+)
+
+// IngestConfig describes an SQS ingestion worker. See Client.Ingest.
+type IngestConfig struct {
+	QueueURL string
+
+	// Map turns a message into the item to write. Returning a nil item drops
+	// the message; an error sends it to the DLQ.
+	Map func(msg *sqs.Message) (map[string]*dynamodb.AttributeValue, error)
+
+	// Key returns the idempotency key of a message. The default is its
+	// MessageDeduplicationId (FIFO queues), or else its MessageId.
+	Key func(msg *sqs.Message) string
+
+	// IdempotencyAttr, if set, stores the idempotency key in each item.
+	IdempotencyAttr string
+
+	// DLQURL is the queue for messages that can't be mapped, or whose write
+	// failed MaxReceives times. Without it, such messages are left to the
+	// queue's own redrive policy.
+	DLQURL      string
+	MaxReceives int // the default is 5
+
+	Workers int // concurrent receive loops; the default is 1
+}
+
+// seenKeys remembers the idempotency keys of recently written messages.
+type seenKeys struct {
+	mu   sync.Mutex
+	m    map[string]bool
+	ring []string
+	next int
+}
+
+func (s *seenKeys) has(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.m[key]
+}
+
+func (s *seenKeys) add(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.m[key] {
+		return
+	}
+
+	if len(s.ring) < ingestSeenMax {
+		s.ring = append(s.ring, key)
+	} else {
+		delete(s.m, s.ring[s.next])
+		s.ring[s.next] = key
+		s.next = (s.next + 1) % ingestSeenMax
+	}
+
+	s.m[key] = true
+}
+
+type ingester struct {
+	c     *Client
+	svc   sqsiface.SQSAPI
+	cfg   IngestConfig
+	keys  []string // the table's key attributes
+	seen  *seenKeys
+	o     options
+	opts  []Option
+	table string
+}
+
+// Ingest consumes messages from an SQS queue, maps them to items with
+// cfg.Map, and writes them to the Client's table with BatchPutItems (so
+// WithWriteCapacity, WithDerived, and WithSchemas apply), until ctx is done.
+// Messages are deleted once their items are written. Redelivered messages
+// whose idempotency key was written recently are deleted without writing
+// them again, and messages in one batch with the same primary key are
+// collapsed, the last one winning. Failed writes are left on the queue for
+// redelivery, up to cfg.MaxReceives. Ingest returns nil when ctx is done, or
+// the error that stopped it.
+//
+//	err := client.Ingest(ctx, sqssvc, libdy.IngestConfig{
+//		QueueURL: queue,
+//		DLQURL:   dlq,
+//		Map:      orderItem,
+//	}, libdy.WithWriteCapacity(wcu))
+func (c *Client) Ingest(ctx context.Context, svc sqsiface.SQSAPI, cfg IngestConfig, opts ...Option) error {
+	o, err := c.apply(opts)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Map == nil {
+		return fmt.Errorf("Ingest failed: no Map")
+	}
+
+	if cfg.MaxReceives <= 0 {
+		cfg.MaxReceives = ingestMaxReceives
+	}
+
+	keys, err := c.keyAttrs(ctx, o)
+	if err != nil {
+		return fmt.Errorf("Ingest failed: %w", err)
+	}
+
+	in := &ingester{
+		c:     c,
+		svc:   svc,
+		cfg:   cfg,
+		keys:  keys,
+		seen:  &seenKeys{m: map[string]bool{}},
+		o:     o,
+		opts:  opts,
+		table: o.table,
+	}
+
+	workers := cfg.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			err := in.run(ctx)
+			if err != nil {
+				cancel()
+			}
+
+			errs <- err
+		}()
+	}
+
+	var ret error
+	for i := 0; i < workers; i++ {
+		if err := <-errs; err != nil && ret == nil {
+			ret = err
+		}
+	}
+
+	return ret
+}
+
+func (in *ingester) run(ctx context.Context) error {
+	for ctx.Err() == nil {
+		msgs, err := in.receive(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("Ingest failed: %w", err)
+		}
+
+		if err := in.process(ctx, msgs); err != nil && ctx.Err() == nil {
+			return fmt.Errorf("Ingest failed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (in *ingester) receive(ctx context.Context) ([]*sqs.Message, error) {
+	input := &sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(in.cfg.QueueURL),
+		MaxNumberOfMessages: aws.Int64(sqsBatchMax),
+		WaitTimeSeconds:     aws.Int64(ingestWaitTime),
+		AttributeNames: []*string{
+			aws.String(sqs.MessageSystemAttributeNameApproximateReceiveCount),
+			aws.String(sqs.MessageSystemAttributeNameMessageDeduplicationId),
+		},
+	}
+
+	var rerr error
+	var res *sqs.ReceiveMessageOutput
+	op := func(ctx context.Context) error {
+		res, rerr = in.svc.ReceiveMessageWithContext(ctx, input)
+		return in.o.retriable(rerr)
+	}
+
+	if err := retry(ctx, in.o, "ReceiveMessage", op); err != nil {
+		return nil, fmt.Errorf("ReceiveMessage failed: %w", err)
+	}
+
+	if rerr != nil {
+		return nil, fmt.Errorf("ReceiveMessage failed: %w", rerr)
+	}
+
+	return res.Messages, nil
+}
+
+// key returns the idempotency key of msg.
+func (in *ingester) key(msg *sqs.Message) string {
+	if in.cfg.Key != nil {
+		return in.cfg.Key(msg)
+	}
+
+	if id := aws.StringValue(msg.Attributes[sqs.MessageSystemAttributeNameMessageDeduplicationId]); id != "" {
+		return id
+	}
+
+	return aws.StringValue(msg.MessageId)
+}
+
+// itemID returns the identity of item by the table's key attributes.
+func (in *ingester) itemID(item map[string]*dynamodb.AttributeValue) string {
+	parts := make([]string, len(in.keys))
+	for i, k := range in.keys {
+		if v, ok := item[k]; ok {
+			parts[i] = partitionValue(v)
+		}
+	}
+
+	return strings.Join(parts, "\x00")
+}
+
+func (in *ingester) process(ctx context.Context, msgs []*sqs.Message) error {
+	var done []*sqs.Message  // to delete
+	var dead []*sqs.Message  // to dead-letter
+	var reasons []string     // of dead
+	byID := map[string]int{} // item index by identity
+	var items []map[string]*dynamodb.AttributeValue
+	var owners [][]*sqs.Message // the messages of each item
+	for _, msg := range msgs {
+		key := in.key(msg)
+		if in.seen.has(key) {
+			done = append(done, msg) // redelivered after its write
+			continue
+		}
+
+		item, err := in.cfg.Map(msg)
+		if err != nil {
+			dead = append(dead, msg)
+			reasons = append(reasons, "map: "+err.Error())
+			continue
+		}
+
+		if item == nil {
+			done = append(done, msg)
+			continue
+		}
+
+		if in.cfg.IdempotencyAttr != "" {
+			item[in.cfg.IdempotencyAttr] = &dynamodb.AttributeValue{S: aws.String(key)}
+		}
+
+		id := in.itemID(item)
+		if i, ok := byID[id]; ok {
+			items[i] = item // last wins, as if written in order
+			owners[i] = append(owners[i], msg)
+			continue
+		}
+
+		byID[id] = len(items)
+		items = append(items, item)
+		owners = append(owners, []*sqs.Message{msg})
+	}
+
+	failed := map[string]bool{} // item identities
+	var werr error
+	if len(items) > 0 {
+		werr = in.c.BatchPutItems(ctx, items, in.opts...)
+		var bwe *BatchWriteError
+		switch {
+		case errors.As(werr, &bwe):
+			for _, f := range bwe.Failures {
+				failed[in.itemID(f.Item())] = true
+			}
+		case werr != nil:
+			for _, item := range items {
+				failed[in.itemID(item)] = true
+			}
+		}
+	}
+
+	written := 0
+	for i, item := range items {
+		if !failed[in.itemID(item)] {
+			written++
+			for _, msg := range owners[i] {
+				in.seen.add(in.key(msg))
+				done = append(done, msg)
+			}
+
+			continue
+		}
+
+		for _, msg := range owners[i] {
+			n, _ := strconv.Atoi(aws.StringValue(msg.Attributes[sqs.MessageSystemAttributeNameApproximateReceiveCount]))
+			if n >= in.cfg.MaxReceives {
+				dead = append(dead, msg)
+				reasons = append(reasons, "write: "+werr.Error())
+			}
+		}
+	}
+
+	if werr != nil {
+		in.o.logf("ingest: %d of %d item(s) not written, left for redelivery: %v", len(failed), len(items), werr)
+	}
+
+	in.o.count(MetricIngestWritten, map[string]string{"table": in.table}, int64(written))
+	if err := in.deadLetter(ctx, dead, reasons); err != nil {
+		return err
+	}
+
+	if in.cfg.DLQURL != "" {
+		done = append(done, dead...)
+	}
+
+	return in.delete(ctx, done)
+}
+
+// deadLetter sends msgs to the DLQ, if any, noting why in an attribute.
+func (in *ingester) deadLetter(ctx context.Context, msgs []*sqs.Message, reasons []string) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	in.o.count(MetricIngestDeadLettered, map[string]string{"table": in.table}, int64(len(msgs)))
+	if in.cfg.DLQURL == "" {
+		for i, msg := range msgs {
+			in.o.logf("ingest: message %s failed, left to the queue's redrive policy: %s", aws.StringValue(msg.MessageId), reasons[i])
+		}
+
+		return nil
+	}
+
+	return sendAll(ctx, "SendMessageBatch", len(msgs), sqsBatchMax, func(ctx context.Context, idx []int) ([]int, error) {
+		input := &sqs.SendMessageBatchInput{QueueUrl: aws.String(in.cfg.DLQURL)}
+		for _, i := range idx {
+			input.Entries = append(input.Entries, &sqs.SendMessageBatchRequestEntry{
+				Id:          aws.String(strconv.Itoa(i)),
+				MessageBody: msgs[i].Body,
+				MessageAttributes: map[string]*sqs.MessageAttributeValue{
+					"libdy-error": {DataType: aws.String("String"), StringValue: aws.String(reasons[i])},
+				},
+			})
+		}
+
+		res, err := in.svc.SendMessageBatchWithContext(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		var failed []int
+		for _, f := range res.Failed {
+			if i, err := strconv.Atoi(aws.StringValue(f.Id)); err == nil {
+				failed = append(failed, i)
+			}
+		}
+
+		return failed, nil
+	})
+}
+
+// delete removes msgs from the queue.
+func (in *ingester) delete(ctx context.Context, msgs []*sqs.Message) error {
+	return sendAll(ctx, "DeleteMessageBatch", len(msgs), sqsBatchMax, func(ctx context.Context, idx []int) ([]int, error) {
+		input := &sqs.DeleteMessageBatchInput{QueueUrl: aws.String(in.cfg.QueueURL)}
+		for _, i := range idx {
+			input.Entries = append(input.Entries, &sqs.DeleteMessageBatchRequestEntry{
+				Id:            aws.String(strconv.Itoa(i)),
+				ReceiptHandle: msgs[i].ReceiptHandle,
+			})
+		}
+
+		res, err := in.svc.DeleteMessageBatchWithContext(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		var failed []int
+		for _, f := range res.Failed {
+			if i, err := strconv.Atoi(aws.StringValue(f.Id)); err == nil {
+				failed = append(failed, i)
+			}
+		}
+
+		return failed, nil
+	})
+}
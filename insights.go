@@ -0,0 +1,285 @@
+package libdy
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// HotKeys counts partition key accesses made through a Client (see
+// WithHotKeys), keeping the approximate top keys per table in bounded memory.
+type HotKeys struct {
+	attr   string
+	size   int
+	mu     sync.Mutex
+	tables map[string]map[string]int64
+}
+
+// NewHotKeys returns a tracker keeping up to size keys per table. attr is the
+// partition key attribute name, needed to count PutItem calls.
+func NewHotKeys(attr string, size int) *HotKeys {
+	if size <= 0 {
+		size = 1000
+	}
+
+	return &HotKeys{attr: attr, size: size, tables: map[string]map[string]int64{}}
+}
+
+// WithHotKeys makes the Client count its partition key accesses in h.
+func WithHotKeys(h *HotKeys) Option {
+	return func(o *options) { o.hotKeys = h }
+}
+
+// KeyCount is a partition key and its access count.
+type KeyCount struct {
+	Key   string
+	Count int64
+}
+
+func keyString(v *dynamodb.AttributeValue) string {
+	switch {
+	case v.S != nil:
+		return *v.S
+	case v.N != nil:
+		return *v.N
+	case v.B != nil:
+		return base64.StdEncoding.EncodeToString(v.B)
+	}
+
+	return ""
+}
+
+// observe counts an access to the partition of item (a key or a full item).
+// A nil tracker does nothing.
+func (h *HotKeys) observe(table string, item map[string]*dynamodb.AttributeValue) {
+	if h == nil {
+		return
+	}
+
+	v, ok := item[h.attr]
+	if !ok && len(item) == 1 {
+		for _, v = range item {
+		}
+	}
+
+	if v == nil {
+		return
+	}
+
+	h.Observe(table, keyString(v))
+}
+
+// Observe counts an access to key in table. When the table is full, the
+// least counted key is replaced, inheriting its count (the space-saving
+// algorithm), so counts are upper bounds.
+func (h *HotKeys) Observe(table, key string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	m, ok := h.tables[table]
+	if !ok {
+		m = map[string]int64{}
+		h.tables[table] = m
+	}
+
+	if _, ok := m[key]; !ok && len(m) >= h.size {
+		var minKey string
+		var min int64 = -1
+		for k, n := range m {
+			if min < 0 || n < min {
+				minKey, min = k, n
+			}
+		}
+
+		delete(m, minKey)
+		m[key] = min
+	}
+
+	m[key]++
+}
+
+// Top returns the n most accessed keys of table, most accessed first.
+func (h *HotKeys) Top(table string, n int) []KeyCount {
+	h.mu.Lock()
+	ret := make([]KeyCount, 0, len(h.tables[table]))
+	for k, c := range h.tables[table] {
+		ret = append(ret, KeyCount{k, c})
+	}
+
+	h.mu.Unlock()
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Count != ret[j].Count {
+			return ret[i].Count > ret[j].Count
+		}
+
+		return ret[i].Key < ret[j].Key
+	})
+
+	if n > 0 && len(ret) > n {
+		ret = ret[:n]
+	}
+
+	return ret
+}
+
+func EnableContributorInsights(svc dynamodbiface.DynamoDBAPI, table, index string) error {
+	return EnableContributorInsightsWithContext(context.Background(), svc, table, index)
+}
+
+// EnableContributorInsightsWithContext turns on CloudWatch Contributor
+// Insights for table, or for its global secondary index if index is set.
+func EnableContributorInsightsWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, index string) error {
+	return updateContributorInsights(ctx, svc, table, index, dynamodb.ContributorInsightsActionEnable)
+}
+
+func DisableContributorInsights(svc dynamodbiface.DynamoDBAPI, table, index string) error {
+	return DisableContributorInsightsWithContext(context.Background(), svc, table, index)
+}
+
+// DisableContributorInsightsWithContext turns off Contributor Insights.
+func DisableContributorInsightsWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, index string) error {
+	return updateContributorInsights(ctx, svc, table, index, dynamodb.ContributorInsightsActionDisable)
+}
+
+func updateContributorInsights(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, index, action string) error {
+	input := &dynamodb.UpdateContributorInsightsInput{
+		TableName:                 aws.String(table),
+		ContributorInsightsAction: aws.String(action),
+	}
+
+	if index != "" {
+		input.IndexName = aws.String(index)
+	}
+
+	if _, err := svc.UpdateContributorInsightsWithContext(ctx, input); err != nil {
+		return fmt.Errorf("UpdateContributorInsights failed: %w", awsErr(err))
+	}
+
+	return nil
+}
+
+// HotKey is one line of a hot key report.
+type HotKey struct {
+	Key      string
+	Insights float64 // accesses per Contributor Insights over the window
+	Local    int64   // accesses observed by this process
+}
+
+func HotKeyReport(svc dynamodbiface.DynamoDBAPI, cw cloudwatchiface.CloudWatchAPI, table, index string, n int, window time.Duration, local *HotKeys) ([]HotKey, error) {
+	return HotKeyReportWithContext(context.Background(), svc, cw, table, index, n, window, local)
+}
+
+// HotKeyReportWithContext merges the top n most accessed partition keys from
+// Contributor Insights over the last window with the top n from local (which
+// may be nil), ordered by Insights then Local accesses. If Contributor
+// Insights is not enabled for the table (or index), the report has the
+// local observations only.
+func HotKeyReportWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, cw cloudwatchiface.CloudWatchAPI, table, index string, n int, window time.Duration, local *HotKeys) ([]HotKey, error) {
+	keys := map[string]*HotKey{}
+	get := func(k string) *HotKey {
+		if _, ok := keys[k]; !ok {
+			keys[k] = &HotKey{Key: k}
+		}
+
+		return keys[k]
+	}
+
+	rule, err := insightsRule(ctx, svc, table, index)
+	if err != nil {
+		return nil, err
+	}
+
+	if rule != "" {
+		end := time.Now()
+		period := int64(window.Seconds())
+		if period < 60 {
+			period = 60
+		}
+
+		res, err := cw.GetInsightRuleReportWithContext(ctx, &cloudwatch.GetInsightRuleReportInput{
+			RuleName:            aws.String(rule),
+			StartTime:           aws.Time(end.Add(-window)),
+			EndTime:             aws.Time(end),
+			Period:              aws.Int64(period),
+			MaxContributorCount: aws.Int64(int64(n)),
+			OrderBy:             aws.String("Sum"),
+		})
+
+		if err != nil {
+			return nil, fmt.Errorf("GetInsightRuleReport failed: %w", err)
+		}
+
+		for _, c := range res.Contributors {
+			get(strings.Join(aws.StringValueSlice(c.Keys), ",")).Insights += aws.Float64Value(c.ApproximateAggregateValue)
+		}
+	}
+
+	if local != nil {
+		name := table
+		if index != "" {
+			name = table + "/" + index
+		}
+
+		for _, kc := range local.Top(name, n) {
+			get(kc.Key).Local += kc.Count
+		}
+	}
+
+	ret := make([]HotKey, 0, len(keys))
+	for _, k := range keys {
+		ret = append(ret, *k)
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Insights != ret[j].Insights {
+			return ret[i].Insights > ret[j].Insights
+		}
+
+		if ret[i].Local != ret[j].Local {
+			return ret[i].Local > ret[j].Local
+		}
+
+		return ret[i].Key < ret[j].Key
+	})
+
+	if n > 0 && len(ret) > n {
+		ret = ret[:n]
+	}
+
+	return ret, nil
+}
+
+// insightsRule returns the name of the "most accessed partition keys"
+// Contributor Insights rule, or "" if Contributor Insights is not enabled.
+func insightsRule(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, index string) (string, error) {
+	input := &dynamodb.DescribeContributorInsightsInput{TableName: aws.String(table)}
+	if index != "" {
+		input.IndexName = aws.String(index)
+	}
+
+	res, err := svc.DescribeContributorInsightsWithContext(ctx, input)
+	if err != nil {
+		return "", fmt.Errorf("DescribeContributorInsights failed: %w", awsErr(err))
+	}
+
+	if aws.StringValue(res.ContributorInsightsStatus) != dynamodb.ContributorInsightsStatusEnabled {
+		return "", nil
+	}
+
+	for _, r := range aws.StringValueSlice(res.ContributorInsightsRuleList) {
+		if strings.Contains(r, "-PKC-") {
+			return r, nil
+		}
+	}
+
+	return "", nil
+}
@@ -0,0 +1,87 @@
+package libdy
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// Items iterates over the items of a Query or Scan one at a time, fetching
+// pages as needed, so only one page (two with WithPrefetch) is held in
+// memory at once.
+//
+//	it := client.QueryIter("pk:1", "")
+//	defer it.Close()
+//	for it.Next(ctx) {
+//		item := it.Item()
+//		...
+//	}
+//
+//	if err := it.Err(); err != nil { ... }
+type Items struct {
+	pages *Pages
+	page  []map[string]*dynamodb.AttributeValue
+	i     int
+	n     int64
+}
+
+// Items returns an item iterator over the remaining pages of p.
+func (p *Pages) Items() *Items {
+	return &Items{pages: p, i: -1}
+}
+
+// Next advances to the next item, returning false when there are no more
+// items or an error occurred (see Err).
+func (it *Items) Next(ctx context.Context) bool {
+	if it.pages.o.capped(it.n) {
+		return false
+	}
+
+	for it.i+1 >= len(it.page) {
+		if !it.pages.Next(ctx) {
+			return false
+		}
+
+		it.page, it.i = it.pages.Page(), -1
+	}
+
+	it.i++
+	it.n++
+	return true
+}
+
+// Item returns the current item.
+func (it *Items) Item() map[string]*dynamodb.AttributeValue { return it.page[it.i] }
+
+func (it *Items) Err() error { return it.pages.Err() }
+
+// Close stops the iteration, cancelling any in-flight prefetch.
+func (it *Items) Close() { it.pages.Close() }
+
+// QueryIter returns an item iterator over the items under pk (and,
+// optionally, the sk prefix).
+func (c *Client) QueryIter(pk, sk string, opts ...Option) *Items {
+	return c.QueryPages(pk, sk, opts...).Items()
+}
+
+// QueryIndexIter returns an item iterator over the items in the index whose
+// key equals value.
+func (c *Client) QueryIndexIter(index, key, value string, opts ...Option) *Items {
+	return c.QueryIndexPages(index, key, value, opts...).Items()
+}
+
+// ScanIter returns an item iterator over all the items in the table.
+func (c *Client) ScanIter(opts ...Option) *Items {
+	return c.ScanPages(opts...).Items()
+}
+
+// GetItemsIter is the streaming counterpart of GetItems.
+func GetItemsIter(svc dynamodbiface.DynamoDBAPI, table, pk, sk string, opts ...Option) *Items {
+	return New(svc, WithTable(table)).QueryIter(pk, sk, opts...)
+}
+
+// ScanItemsIter is the streaming counterpart of ScanItems.
+func ScanItemsIter(svc dynamodbiface.DynamoDBAPI, table string, opts ...Option) *Items {
+	return New(svc, WithTable(table)).ScanIter(opts...)
+}
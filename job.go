@@ -0,0 +1,192 @@
+package libdy
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+var (
+	ErrInvalidJobToken = errors.New("libdy: invalid job token")
+)
+
+// errChunkDone stops a PipeChunk once its budget is spent.
+var errChunkDone = errors.New("chunk budget spent")
+
+// jobState is the progress of a chunked Pipe, carried in its job token.
+type jobState struct {
+	Segments int            `json:"segments"`
+	Cursors  map[int]string `json:"cursors"`
+	Read     int64          `json:"read"`
+	Written  int64          `json:"written"`
+}
+
+func encodeJobToken(s *jobState) (string, error) {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeJobToken(token string) (*jobState, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidJobToken, err)
+	}
+
+	var s jobState
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidJobToken, err)
+	}
+
+	if s.Cursors == nil {
+		s.Cursors = map[int]string{}
+	}
+
+	return &s, nil
+}
+
+// chunkCheckpoints keeps the checkpoints of a PipeChunk in its jobState, and
+// stops the chunk after the first checkpoint past the deadline, if any.
+type chunkCheckpoints struct {
+	mu       sync.Mutex
+	s        *jobState
+	deadline time.Time
+}
+
+func (c *chunkCheckpoints) Load(context.Context) (map[int]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ret := make(map[int]string, len(c.s.Cursors))
+	for k, v := range c.s.Cursors {
+		ret[k] = v
+	}
+
+	return ret, nil
+}
+
+func (c *chunkCheckpoints) Save(_ context.Context, segment int, cursor string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.s.Cursors[segment] = cursor
+	if cursor != "" && !c.deadline.IsZero() && time.Now().After(c.deadline) {
+		return errChunkDone
+	}
+
+	return nil
+}
+
+// PipeChunk runs a Pipe for at most budget, then returns a job token with
+// its progress, to pass to the next PipeChunk call to carry on: long
+// backfills, copies, and truncates (a Pipe to a DeleteSink) can then run as
+// a series of short Lambda invocations, looped over by a Step Functions
+// state machine, instead of one long-lived process. Start with token "";
+// the returned token is "" once the Pipe is complete. The token is an
+// opaque, URL-safe string, with a cursor per segment. On error, the token
+// still holds the progress made, so the job can go on from there.
+//
+//	func handle(ctx context.Context, in struct{ Token string }) (out struct {
+//		Token string
+//		Done  bool
+//	}, err error) {
+//		out.Token, _, err = client.PipeChunk(ctx, cfg, in.Token, 0)
+//		out.Done = out.Token == ""
+//		return out, err
+//	}
+//
+// A budget of 0 or less uses three quarters of the time left until ctx's
+// deadline, e.g. the Lambda timeout, or else runs the Pipe to completion.
+// The chunk stops after the first page that reaches the sink past the
+// budget, so a chunk can run over by about one page per segment. p.Segments
+// must be the same for every chunk of a job, and p.Checkpoints is replaced
+// by the token. The returned PipeStats are the totals of the job so far.
+func (c *Client) PipeChunk(ctx context.Context, p PipeConfig, token string, budget time.Duration, opts ...Option) (string, *PipeStats, error) {
+	if p.Segments < 1 {
+		p.Segments = 1
+	}
+
+	s := &jobState{Segments: p.Segments, Cursors: map[int]string{}}
+	if token != "" {
+		var err error
+		if s, err = decodeJobToken(token); err != nil {
+			return "", nil, fmt.Errorf("PipeChunk failed: %w", err)
+		}
+
+		if s.Segments != p.Segments {
+			return "", nil, fmt.Errorf("PipeChunk failed: %w: %d segment(s), not %d", ErrInvalidJobToken, s.Segments, p.Segments)
+		}
+	}
+
+	cp := &chunkCheckpoints{s: s}
+	if budget > 0 {
+		cp.deadline = time.Now().Add(budget)
+	} else if d, ok := ctx.Deadline(); ok {
+		cp.deadline = time.Now().Add(time.Until(d) * 3 / 4)
+	}
+
+	p.Checkpoints = cp
+	stats, err := c.Pipe(ctx, p, opts...)
+	if stats != nil {
+		s.Read += stats.Read
+		s.Written += stats.Written
+	}
+
+	total := &PipeStats{Read: s.Read, Written: s.Written}
+	if err == nil {
+		return "", total, nil
+	}
+
+	if errors.Is(err, errChunkDone) {
+		err = nil
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	next, terr := encodeJobToken(s)
+	if terr != nil {
+		return token, total, fmt.Errorf("PipeChunk failed: %w", terr)
+	}
+
+	return next, total, err
+}
+
+// DeleteSink deletes the items it receives from the table of c with
+// BatchDeleteItems; opts apply to the deletes, e.g. WithTable or
+// WithWriteCapacity. With a Pipe from the same table, it truncates the
+// table (or deletes one partition, with PipeConfig.PK).
+func DeleteSink(c *Client, opts ...Option) Sink {
+	var mu sync.Mutex
+	var attrs []string // the table's key attributes
+	return SinkFunc(func(ctx context.Context, items []map[string]*dynamodb.AttributeValue) error {
+		mu.Lock()
+		if attrs == nil {
+			o, err := c.apply(opts)
+			if err == nil {
+				attrs, err = c.keyAttrs(ctx, o)
+			}
+
+			if err != nil {
+				mu.Unlock()
+				return err
+			}
+		}
+
+		keys := attrs
+		mu.Unlock()
+
+		del := make([]map[string]*dynamodb.AttributeValue, len(items))
+		for i, item := range items {
+			del[i] = keyOf(item, keys)
+		}
+
+		return c.BatchDeleteItems(ctx, del, opts...)
+	})
+}
@@ -0,0 +1,270 @@
+package libdy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// Intent is a multi-step, non-transactional operation (say, an object
+// written to S3, then an item pointing to it), recorded in a Journal before
+// its first step so it can be completed or rolled back after a crash.
+type Intent struct {
+	ID   string            `json:"id"`   // generated by Journaled if empty
+	Kind string            `json:"kind"` // selects the Recoverer
+	Data map[string]string `json:"data,omitempty"`
+	Time time.Time         `json:"time"` // set by Journaled
+}
+
+// Journal is a write-ahead log of Intents. Begin records an intent, Done
+// removes it once its operation is complete (or rolled back), and Pending
+// returns the intents not done, oldest first. Besides custom ones, there
+// are FileJournal and TableJournal.
+type Journal interface {
+	Begin(ctx context.Context, in Intent) error
+	Done(ctx context.Context, id string) error
+	Pending(ctx context.Context) ([]Intent, error)
+}
+
+// Recoverer completes or rolls back an operation interrupted after its
+// intent was recorded; any of its steps may or may not have happened, so it
+// must be idempotent. Returning nil marks the intent done.
+type Recoverer func(ctx context.Context, in Intent) error
+
+// Journaled runs fn, the steps of the operation described by in, with its
+// intent recorded in j first. The intent is done once fn returns nil; if fn
+// fails (or the process dies), it stays pending for Recover. opts may set
+// the Clock and IDGen stamping the intent (see WithClock and WithIDGen).
+//
+//	err := libdy.Journaled(ctx, j, libdy.Intent{
+//		Kind: "blob",
+//		Data: map[string]string{"key": key, "id": id},
+//	}, func(ctx context.Context) error {
+//		if err := putObject(ctx, key, body); err != nil {
+//			return err
+//		}
+//
+//		return client.PutItem(ctx, item)
+//	})
+func Journaled(ctx context.Context, j Journal, in Intent, fn func(ctx context.Context) error, opts ...Option) error {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if in.ID == "" {
+		in.ID = o.newID()
+	}
+
+	in.Time = o.now().UTC()
+	if err := j.Begin(ctx, in); err != nil {
+		return fmt.Errorf("Journaled failed: begin: %w", err)
+	}
+
+	if err := fn(ctx); err != nil {
+		return err
+	}
+
+	if err := j.Done(ctx, in.ID); err != nil {
+		return fmt.Errorf("Journaled failed: done: %w", err)
+	}
+
+	return nil
+}
+
+// Recover runs the Recoverer for the Kind of each pending intent in j, on
+// startup, oldest first, and marks the recovered intents done. Intents of
+// kinds without a Recoverer are left pending. It returns the number of
+// intents recovered, and the errors of those that failed, joined.
+func Recover(ctx context.Context, j Journal, recoverers map[string]Recoverer) (int, error) {
+	pending, err := j.Pending(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("Recover failed: %w", err)
+	}
+
+	n := 0
+	var errs []error
+	for _, in := range pending {
+		fn, ok := recoverers[in.Kind]
+		if !ok {
+			continue
+		}
+
+		if err := fn(ctx, in); err != nil {
+			errs = append(errs, fmt.Errorf("intent %s (%s): %w", in.ID, in.Kind, err))
+			continue
+		}
+
+		if err := j.Done(ctx, in.ID); err != nil {
+			errs = append(errs, fmt.Errorf("intent %s (%s): done: %w", in.ID, in.Kind, err))
+			continue
+		}
+
+		n++
+	}
+
+	if len(errs) > 0 {
+		return n, fmt.Errorf("Recover failed: %w", errors.Join(errs...))
+	}
+
+	return n, nil
+}
+
+type fileJournal struct {
+	path string
+	mu   sync.Mutex
+}
+
+// FileJournal keeps a Journal in a JSON file at path, which doesn't need to
+// exist yet. It suits a single process; see TableJournal otherwise.
+func FileJournal(path string) Journal {
+	return &fileJournal{path: path}
+}
+
+func (f *fileJournal) load() (map[string]Intent, error) {
+	b, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]Intent{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	m := map[string]Intent{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("invalid journal %s: %w", f.path, err)
+	}
+
+	return m, nil
+}
+
+func (f *fileJournal) save(m map[string]Intent) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	// Write, sync, and rename, so a crash never leaves a truncated file, and
+	// an intent is on disk before its operation starts.
+	tmp := f.path + ".tmp"
+	w, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(b); err != nil {
+		w.Close()
+		return err
+	}
+
+	if err := w.Sync(); err != nil {
+		w.Close()
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, f.path)
+}
+
+func (f *fileJournal) Begin(_ context.Context, in Intent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m, err := f.load()
+	if err != nil {
+		return err
+	}
+
+	m[in.ID] = in
+	return f.save(m)
+}
+
+func (f *fileJournal) Done(_ context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m, err := f.load()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := m[id]; !ok {
+		return nil
+	}
+
+	delete(m, id)
+	return f.save(m)
+}
+
+func (f *fileJournal) Pending(context.Context) ([]Intent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]Intent, 0, len(m))
+	for _, in := range m {
+		ret = append(ret, in)
+	}
+
+	sortIntents(ret)
+	return ret, nil
+}
+
+func sortIntents(s []Intent) {
+	sort.Slice(s, func(i, j int) bool { return s[i].Time.Before(s[j].Time) })
+}
+
+type tableJournal struct {
+	c    *Client
+	opts []Option
+}
+
+// TableJournal keeps a Journal in the table of c (or the one set in opts,
+// e.g. WithTable), with one item per pending intent, keyed by a string
+// partition key "id":
+//
+//	libdy.EnsureTable(svc, libdy.TableDef{Name: "journal", PK: "id"})
+func TableJournal(c *Client, opts ...Option) Journal {
+	return &tableJournal{c: c, opts: opts}
+}
+
+func (t *tableJournal) Begin(ctx context.Context, in Intent) error {
+	item, err := dynamodbattribute.MarshalMap(in)
+	if err != nil {
+		return err
+	}
+
+	return t.c.PutItem(ctx, item, t.opts...)
+}
+
+func (t *tableJournal) Done(ctx context.Context, id string) error {
+	return t.c.DeleteItemByKey(ctx, StringKey("id", id), Key{}, t.opts...)
+}
+
+func (t *tableJournal) Pending(ctx context.Context) ([]Intent, error) {
+	opts := append([]Option{WithConsistentRead(), WithScanAck()}, t.opts...)
+	items, err := t.c.ScanItems(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []Intent
+	if err := dynamodbattribute.UnmarshalListOfMaps(items, &ret); err != nil {
+		return nil, fmt.Errorf("invalid journal: %w", err)
+	}
+
+	sortIntents(ret)
+	return ret, nil
+}
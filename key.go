@@ -0,0 +1,200 @@
+package libdy
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// Key is a key attribute, the structured counterpart of the "name:value"
+// strings taken by GetItems, DeleteItem, and friends. Unlike the string form,
+// the value may contain colons, and needn't be a string. Typed keys are
+// built with StringKey, IntKey, FloatKey, and BytesKey:
+//
+//	items, err := libdy.GetItemsByKey(svc, "events", libdy.IntKey("user", 42), libdy.Key{})
+type Key struct {
+	Name  string
+	Value string
+	Type  string // dynamodb.ScalarAttributeType*; empty means S
+}
+
+// StringKey returns a string (S) key attribute.
+func StringKey(name, v string) Key { return Key{Name: name, Value: v} }
+
+// IntKey returns a number (N) key attribute.
+func IntKey(name string, v int64) Key {
+	return Key{Name: name, Value: strconv.FormatInt(v, 10), Type: dynamodb.ScalarAttributeTypeN}
+}
+
+// FloatKey returns a number (N) key attribute.
+func FloatKey(name string, v float64) Key {
+	return Key{Name: name, Value: strconv.FormatFloat(v, 'f', -1, 64), Type: dynamodb.ScalarAttributeTypeN}
+}
+
+// BytesKey returns a binary (B) key attribute.
+func BytesKey(name string, v []byte) Key {
+	return Key{Name: name, Value: string(v), Type: dynamodb.ScalarAttributeTypeB}
+}
+
+// ParseKey parses the "name:value" string form. The value is everything
+// after the first colon. An empty s gives the zero Key, meaning no key.
+//
+// GetItem, DeleteItem, UpdateItem, Query, and friends also take bare values,
+// without a colon, naming and typing them after the key attributes of the
+// table (see DescribeSchema), so "42" is "id:42" for a number key "id". A
+// value with colons needs its name. Named values are strings, unless typed
+// per WithKeySchema, or per the schema read for a bare value of the same
+// call; use IntKey and friends, or bare values, for other key types.
+func ParseKey(s string) Key {
+	if s == "" {
+		return Key{}
+	}
+
+	name, value, _ := strings.Cut(s, ":")
+	return Key{Name: name, Value: value}
+}
+
+// String returns k in the "name:value" form.
+func (k Key) String() string { return k.Name + ":" + k.Value }
+
+// valueKey returns the Key of the key attribute name with value v.
+func valueKey(name string, v *dynamodb.AttributeValue) Key {
+	switch {
+	case v.N != nil:
+		return Key{Name: name, Value: *v.N, Type: dynamodb.ScalarAttributeTypeN}
+	case v.B != nil:
+		return Key{Name: name, Value: string(v.B), Type: dynamodb.ScalarAttributeTypeB}
+	}
+
+	return Key{Name: name, Value: aws.StringValue(v.S)}
+}
+
+func (k Key) attributeValue() *dynamodb.AttributeValue {
+	switch k.Type {
+	case dynamodb.ScalarAttributeTypeN:
+		return &dynamodb.AttributeValue{N: aws.String(k.Value)}
+	case dynamodb.ScalarAttributeTypeB:
+		return &dynamodb.AttributeValue{B: []byte(k.Value)}
+	}
+
+	return &dynamodb.AttributeValue{S: aws.String(k.Value)}
+}
+
+// tableKeyAttrs returns the key attribute names of table, partition key first.
+func tableKeyAttrs(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string) ([]string, error) {
+	res, err := svc.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+	if err != nil {
+		return nil, fmt.Errorf("DescribeTable failed: %w", awsErr(err))
+	}
+
+	var attrs []string
+	for _, k := range res.Table.KeySchema {
+		if aws.StringValue(k.KeyType) == dynamodb.KeyTypeHash {
+			attrs = append([]string{aws.StringValue(k.AttributeName)}, attrs...)
+		} else {
+			attrs = append(attrs, aws.StringValue(k.AttributeName))
+		}
+	}
+
+	return attrs, nil
+}
+
+// keyOf returns the primary key of item.
+func keyOf(item map[string]*dynamodb.AttributeValue, attrs []string) map[string]*dynamodb.AttributeValue {
+	key := make(map[string]*dynamodb.AttributeValue, len(attrs))
+	for _, a := range attrs {
+		key[a] = item[a]
+	}
+
+	return key
+}
+
+// keyMap returns the primary key for pk and (if set) sk.
+func keyMap(pk, sk Key) map[string]*dynamodb.AttributeValue {
+	key := map[string]*dynamodb.AttributeValue{pk.Name: pk.attributeValue()}
+	if sk.Name != "" {
+		key[sk.Name] = sk.attributeValue()
+	}
+
+	return key
+}
+
+func GetItemsByKey(svc dynamodbiface.DynamoDBAPI, table string, pk, sk Key, limit ...int64) ([]map[string]*dynamodb.AttributeValue, error) {
+	return GetItemsByKeyWithContext(context.Background(), svc, table, pk, sk, limit...)
+}
+
+// GetItemsByKeyWithContext is GetItemsWithContext with structured keys. Pass
+// the zero Key as sk to read the whole partition.
+func GetItemsByKeyWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, pk, sk Key, limit ...int64) ([]map[string]*dynamodb.AttributeValue, error) {
+	res, err := query(ctx, svc, table, queryInput(table, pk, sk, limit...), options{})
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Items, nil
+}
+
+func GetGsiItemsByKey(svc dynamodbiface.DynamoDBAPI, table, index string, key Key) ([]map[string]*dynamodb.AttributeValue, error) {
+	return GetGsiItemsByKeyWithContext(context.Background(), svc, table, index, key)
+}
+
+// GetGsiItemsByKeyWithContext is GetGsiItemsWithContext with a structured key.
+func GetGsiItemsByKeyWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, index string, key Key) ([]map[string]*dynamodb.AttributeValue, error) {
+	res, err := query(ctx, svc, table, indexQueryInput(table, index, key), options{})
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Items, nil
+}
+
+func DeleteItemByKey(svc dynamodbiface.DynamoDBAPI, table string, pk, sk Key, opts ...Option) error {
+	return DeleteItemByKeyWithContext(context.Background(), svc, table, pk, sk, opts...)
+}
+
+// DeleteItemByKeyWithContext is DeleteItemWithContext with structured keys.
+func DeleteItemByKeyWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, pk, sk Key, opts ...Option) error {
+	o := options{table: table}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	_, err := deleteItem(ctx, svc, keyMap(pk, sk), o)
+	return err
+}
+
+// QueryByKey is Query with structured keys.
+func (c *Client) QueryByKey(ctx context.Context, pk, sk Key, opts ...Option) (*Result, error) {
+	o, err := c.apply(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.query(ctx, pk, sk, o)
+}
+
+// QueryIndexByKey is QueryIndex with a structured key.
+func (c *Client) QueryIndexByKey(ctx context.Context, index string, key Key, opts ...Option) (*Result, error) {
+	o, err := c.apply(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.queryIndex(ctx, index, key, o)
+}
+
+// DeleteItemByKey is DeleteItem with structured keys.
+func (c *Client) DeleteItemByKey(ctx context.Context, pk, sk Key, opts ...Option) error {
+	o, err := c.apply(opts)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.deleteItem(ctx, keyMap(pk, sk), o)
+	return err
+}
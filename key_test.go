@@ -0,0 +1,164 @@
+package libdy_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/flowerinthenight/libdy"
+	"github.com/flowerinthenight/libdy/libdytest"
+)
+
+// noDescribe is a service failing DescribeTable, as without the permission.
+type noDescribe struct {
+	dynamodbiface.DynamoDBAPI
+}
+
+func (noDescribe) DescribeTableWithContext(aws.Context, *dynamodb.DescribeTableInput, ...request.Option) (*dynamodb.DescribeTableOutput, error) {
+	return nil, awserr.New("AccessDeniedException", "denied", nil)
+}
+
+// describes is a service counting its DescribeTable calls.
+type describes struct {
+	dynamodbiface.DynamoDBAPI
+	n int
+}
+
+func (d *describes) DescribeTableWithContext(ctx aws.Context, in *dynamodb.DescribeTableInput, opts ...request.Option) (*dynamodb.DescribeTableOutput, error) {
+	d.n++
+	return d.DynamoDBAPI.DescribeTableWithContext(ctx, in, opts...)
+}
+
+func TestStringKeys(t *testing.T) {
+	ctx := context.Background()
+	f := libdytest.SetupFake(t, libdy.TableDef{
+		Name:    "t",
+		PK:      "id:N",
+		SK:      "at",
+		Indexes: []libdy.IndexDef{{Name: "by-n", PK: "n:N"}},
+	})
+
+	item := map[string]*dynamodb.AttributeValue{
+		"id": {N: aws.String("42")},
+		"at": {S: aws.String("2024-01-01")},
+		"n":  {N: aws.String("7")},
+	}
+
+	if err := libdy.PutItemWithContext(ctx, f, "t", item); err != nil {
+		t.Fatal(err)
+	}
+
+	// Bare values are typed per the schema, read once per service, along
+	// with the named ones of the same call.
+	svc := &describes{DynamoDBAPI: f}
+	for _, k := range [][2]string{{"42", "at:2024"}, {"id:42", "2024"}, {"42", ""}} {
+		items, err := libdy.GetItemsWithContext(ctx, svc, "t", k[0], k[1])
+		if err != nil || len(items) != 1 {
+			t.Errorf("GetItems(%q, %q) = %v, %v, want the item", k[0], k[1], items, err)
+		}
+	}
+
+	items, err := libdy.GetGsiItemsWithContext(ctx, svc, "t", "by-n", "n", "7")
+	if err != nil || len(items) != 1 {
+		t.Errorf("GetGsiItems = %v, %v, want the item", items, err)
+	}
+
+	if svc.n != 1 {
+		t.Errorf("%d DescribeTable calls, want 1", svc.n)
+	}
+
+	// Named values alone are strings, without reading the schema, unless
+	// set with WithKeySchema.
+	svc = &describes{DynamoDBAPI: f}
+	if items, _ := libdy.GetItemsWithContext(ctx, svc, "t", "id:42", ""); len(items) != 0 || svc.n != 0 {
+		t.Errorf("GetItems of a named number key: %v after %d DescribeTable calls, want no match and none", items, svc.n)
+	}
+
+	schema := libdy.WithKeySchema(libdy.KeyAttr{Name: "id", Type: "N"}, libdy.KeyAttr{Name: "at", Type: "S"})
+	if err := libdy.DeleteItemWithContext(ctx, svc, "t", "id:42", "at:2024-01-01", schema, libdy.WithMustExist()); err != nil || svc.n != 0 {
+		t.Errorf("DeleteItem with WithKeySchema: %v after %d DescribeTable calls", err, svc.n)
+	}
+
+	// Without the schema, bare values fail, and the others are strings.
+	denied := noDescribe{f}
+	if _, err := libdy.GetItemsWithContext(ctx, denied, "t", "42", ""); err == nil {
+		t.Error("GetItems of a bare value without the schema: want an error")
+	}
+
+	if err := libdy.DeleteItemWithContext(ctx, denied, "t", "42", "x"); err == nil {
+		t.Error("DeleteItem of a bare value without the schema: want an error")
+	}
+
+	if _, err := libdy.GetGsiItemsWithContext(ctx, denied, "t", "by-n", "n", "7"); err == nil {
+		t.Error("GetGsiItems without the schema: want an error")
+	}
+}
+
+func TestBareKeyWrites(t *testing.T) {
+	ctx := context.Background()
+	f := libdytest.SetupFake(t, libdy.TableDef{Name: "t", PK: "id:N", SK: "at"})
+	svc := txFake{f}
+	c := libdy.New(svc, libdy.WithTable("t"))
+	for _, at := range []string{"a", "b", "c"} {
+		item := map[string]*dynamodb.AttributeValue{"id": {N: aws.String("42")}, "at": {S: aws.String(at)}}
+		if err := c.PutItem(ctx, item); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	seven := &dynamodb.AttributeValue{N: aws.String("7")}
+	ops := []libdy.TxOp{libdy.TxUpdate("", "42", "a", libdy.NewUpdate().Set("n", seven)), libdy.TxDelete("", "42", "b")}
+	if err := c.TransactWriteItems(ctx, ops); err != nil {
+		t.Fatal(err)
+	}
+
+	w := c.Writer(libdy.WriterConfig{})
+	if err := w.Delete(ctx, "42", "c"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := c.GetItems(ctx, "42", "")
+	if err != nil || len(items) != 1 || aws.StringValue(items[0]["n"].N) != "7" {
+		t.Fatalf("GetItems = %v, %v, want the updated item alone", items, err)
+	}
+
+	if ok, err := libdy.ExistsWithContext(ctx, svc, "t", "42", "a"); !ok || err != nil {
+		t.Errorf("Exists = %v, %v, want true", ok, err)
+	}
+
+	old, err := libdy.DeleteItemReturningWithContext(ctx, svc, "t", "42", "a")
+	if err != nil || aws.StringValue(old["n"].N) != "7" {
+		t.Errorf("DeleteItemReturning = %v, %v, want the item", old, err)
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want libdy.Key
+	}{
+		{"", libdy.Key{}},
+		{"id:42", libdy.Key{Name: "id", Value: "42"}},
+		{"at:2024-01-01T00:00:00Z", libdy.Key{Name: "at", Value: "2024-01-01T00:00:00Z"}},
+		{"id:", libdy.Key{Name: "id"}},
+		{"42", libdy.Key{Name: "42"}}, // bare; named per the schema by the callers
+	} {
+		got := libdy.ParseKey(tc.in)
+		if got != tc.want {
+			t.Errorf("ParseKey(%q) = %#v, want %#v", tc.in, got, tc.want)
+		}
+
+		if tc.want.Name != "" && strings.Contains(tc.in, ":") && got.String() != tc.in {
+			t.Errorf("ParseKey(%q).String() = %q", tc.in, got.String())
+		}
+	}
+}
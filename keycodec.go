@@ -0,0 +1,144 @@
+package libdy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// KeyPart is a segment of a composite key: an entity type and its ID.
+type KeyPart struct {
+	Type string
+	ID   string
+}
+
+// KeyCodec composes and parses the composite keys of single-table designs,
+// like "USER#123" or "USER#123#ORDER#456": type and ID pairs, joined by a
+// delimiter. Types and IDs can't contain the delimiter.
+//
+//	keys := libdy.KeyCodec{PK: "pk", SK: "sk"}
+//	user, _ := keys.Compose("USER", "123")
+//	res, err := client.QueryUnder(ctx, keys, user, "ORDER") // all ORDER#... under USER#123
+type KeyCodec struct {
+	PK, SK string // the key attribute names of the table
+	Delim  string // the default is "#"
+}
+
+func (k KeyCodec) delim() string {
+	if k.Delim == "" {
+		return "#"
+	}
+
+	return k.Delim
+}
+
+// Compose joins parts, pairs of types and IDs, into a key. A trailing type
+// without an ID gives the prefix of the keys of that type, e.g. "ORDER#".
+func (k KeyCodec) Compose(parts ...string) (string, error) {
+	d := k.delim()
+	var b strings.Builder
+	for i, p := range parts {
+		what := "type"
+		if i%2 == 1 {
+			what = "ID"
+		}
+
+		switch {
+		case p == "":
+			return "", fmt.Errorf("invalid key part %d: empty %s", i, what)
+		case strings.Contains(p, d):
+			return "", fmt.Errorf("invalid key part %d: %s %q contains %q", i, what, p, d)
+		}
+
+		b.WriteString(p)
+		if i%2 == 0 || i < len(parts)-1 {
+			b.WriteString(d)
+		}
+	}
+
+	return b.String(), nil
+}
+
+// Parse splits key into its parts.
+func (k KeyCodec) Parse(key string) ([]KeyPart, error) {
+	segs := strings.Split(key, k.delim())
+	if len(segs)%2 != 0 {
+		return nil, fmt.Errorf("invalid key %q: not type and ID pairs", key)
+	}
+
+	ret := make([]KeyPart, 0, len(segs)/2)
+	for i := 0; i < len(segs); i += 2 {
+		if segs[i] == "" || segs[i+1] == "" {
+			return nil, fmt.Errorf("invalid key %q: empty type or ID", key)
+		}
+
+		ret = append(ret, KeyPart{Type: segs[i], ID: segs[i+1]})
+	}
+
+	return ret, nil
+}
+
+// ID returns the ID of the part of key with type typ, e.g. "456" for
+// "ORDER" in "USER#123#ORDER#456".
+func (k KeyCodec) ID(key, typ string) (string, error) {
+	parts, err := k.Parse(key)
+	if err != nil {
+		return "", err
+	}
+
+	for _, p := range parts {
+		if p.Type == typ {
+			return p.ID, nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid key %q: no %s part", key, typ)
+}
+
+// Under returns the pk and sk arguments of GetItems and Client.Query that
+// read the items of type typ under the partition key parent. They also read
+// the items nested deeper under those, e.g. "ORDER#456#LINE#1".
+func (k KeyCodec) Under(parent, typ string) (pk, sk string, err error) {
+	if _, err := k.Parse(parent); err != nil {
+		return "", "", err
+	}
+
+	prefix, err := k.Compose(typ)
+	if err != nil {
+		return "", "", err
+	}
+
+	if k.PK == "" || k.SK == "" {
+		return "", "", fmt.Errorf("invalid KeyCodec: no PK or SK")
+	}
+
+	return k.PK + ":" + parent, k.SK + ":" + prefix, nil
+}
+
+func GetItemsUnder(svc dynamodbiface.DynamoDBAPI, table string, k KeyCodec, parent, typ string, opts ...Option) ([]map[string]*dynamodb.AttributeValue, error) {
+	return GetItemsUnderWithContext(context.Background(), svc, table, k, parent, typ, opts...)
+}
+
+// GetItemsUnderWithContext is Client.QueryUnder for table.
+func GetItemsUnderWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, k KeyCodec, parent, typ string, opts ...Option) ([]map[string]*dynamodb.AttributeValue, error) {
+	res, err := New(svc, WithTable(table)).QueryUnder(ctx, k, parent, typ, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Items, nil
+}
+
+// QueryUnder reads the items of type typ under the partition key parent
+// (see KeyCodec.Under), with the read options of Query.
+func (c *Client) QueryUnder(ctx context.Context, k KeyCodec, parent, typ string, opts ...Option) (*Result, error) {
+	pk, sk, err := k.Under(parent, typ)
+	if err != nil {
+		return nil, fmt.Errorf("QueryUnder failed: %w", err)
+	}
+
+	return c.Query(ctx, pk, sk, opts...)
+}
@@ -0,0 +1,232 @@
+package libdy
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// keyDistSegments is the segment count of a sampled KeyDistribution scan.
+const keyDistSegments = 1000
+
+// KeyDistributionConfig is the configuration of KeyDistribution.
+type KeyDistributionConfig struct {
+	// Sample is the fraction of the table to read, in whole partitions;
+	// 0 (or 1) reads all of it.
+	Sample float64
+
+	Top       int     // partitions reported in Top; the default is 10
+	HotFactor float64 // times the mean that makes a partition hot; the default is 10
+
+	// Accesses, if set, adds the partition key accesses counted by a
+	// Client (see WithHotKeys), to flag the partitions hot with traffic,
+	// which a scan doesn't see.
+	Accesses *HotKeys
+}
+
+// PartitionStats are the items of one partition key value read by
+// KeyDistribution.
+type PartitionStats struct {
+	Key      string
+	Items    int64
+	Bytes    int64 // per EstimateItemSize
+	Accesses int64 // per KeyDistributionConfig.Accesses
+
+	// Hot tells whether Items, Bytes, or Accesses are over HotFactor times
+	// the mean per partition.
+	Hot bool
+}
+
+// KeyDistributionReport describes how the items of a table spread over its
+// partition key values. The counts are of the items read; divide by Sample
+// to estimate those of the table.
+type KeyDistributionReport struct {
+	Table      string
+	Index      string
+	Sample     float64 // the fraction of the table read
+	Items      int64
+	Bytes      int64
+	Partitions int64 // distinct partition key values
+
+	// ItemSkew and ByteSkew are the largest partition over the mean
+	// partition: 1 is perfectly even.
+	ItemSkew float64
+	ByteSkew float64
+
+	Top []PartitionStats // the largest partitions in bytes, largest first
+	Hot []PartitionStats // the hot partitions, in the same order
+}
+
+func KeyDistribution(svc dynamodbiface.DynamoDBAPI, table string, cfg KeyDistributionConfig, opts ...Option) (*KeyDistributionReport, error) {
+	return KeyDistributionWithContext(context.Background(), svc, table, cfg, opts...)
+}
+
+// KeyDistributionWithContext is Client.KeyDistribution for table.
+func KeyDistributionWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, cfg KeyDistributionConfig, opts ...Option) (*KeyDistributionReport, error) {
+	return New(svc, WithTable(table)).KeyDistribution(ctx, cfg, opts...)
+}
+
+// KeyDistribution scans the table, or the index set with WithIndex, and
+// reports the cardinality and skew of its partition keys, flagging the
+// partitions much larger, or much busier, than the mean. These are the
+// usual cause of the throttling that retries otherwise hide: a partition
+// key value gets no more than 3000 read and 1000 write units per second,
+// however much capacity the table has.
+//
+// With cfg.Sample, a random fraction of the segments of a parallel scan is
+// read; each holds whole partitions, so their stats are exact, while the
+// partitions in no sampled segment are missed. WithConcurrency,
+// WithRateLimit, WithPageSize, and WithFilter apply as to ParallelScanFunc.
+// See HotKeyReport for the most accessed keys per Contributor Insights.
+func (c *Client) KeyDistribution(ctx context.Context, cfg KeyDistributionConfig, opts ...Option) (*KeyDistributionReport, error) {
+	o, err := c.apply(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	schema, err := c.schemas.get(ctx, o, c.svc, o.table)
+	if err != nil {
+		return nil, fmt.Errorf("KeyDistribution failed: %w", err)
+	}
+
+	attr := schema.PK.Name
+	if o.index != "" {
+		x, ok := schema.Index(o.index)
+		if !ok {
+			return nil, fmt.Errorf("KeyDistribution failed: %w: no index %s", ErrInvalidRequest, o.index)
+		}
+
+		attr = x.PK.Name
+	}
+
+	segments, done := 1, map[int]bool{}
+	if cfg.Sample > 0 && cfg.Sample < 1 {
+		segments = keyDistSegments
+		n := int(math.Ceil(cfg.Sample * keyDistSegments))
+		for _, seg := range rand.Perm(segments)[n:] {
+			done[seg] = true
+		}
+	}
+
+	var mu sync.Mutex
+	parts := map[string]*PartitionStats{}
+	err = c.parallelScan(ctx, segments, nil, done, func(_ int, items []map[string]*dynamodb.AttributeValue, _ map[string]*dynamodb.AttributeValue) error {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, item := range items {
+			v := item[attr]
+			if v == nil {
+				continue // filtered out of the projection
+			}
+
+			k := keyString(v)
+			p, ok := parts[k]
+			if !ok {
+				p = &PartitionStats{Key: k}
+				parts[k] = p
+			}
+
+			p.Items++
+			p.Bytes += int64(itemSize(item))
+		}
+
+		return nil
+	}, o)
+
+	if err != nil {
+		return nil, fmt.Errorf("KeyDistribution failed: %w", err)
+	}
+
+	r := &KeyDistributionReport{
+		Table:      o.table,
+		Index:      o.index,
+		Sample:     float64(segments-len(done)) / float64(segments),
+		Partitions: int64(len(parts)),
+	}
+
+	var maxItems, maxBytes int64
+	for _, p := range parts {
+		r.Items += p.Items
+		r.Bytes += p.Bytes
+		maxItems, maxBytes = max(maxItems, p.Items), max(maxBytes, p.Bytes)
+	}
+
+	if r.Partitions == 0 {
+		return r, nil
+	}
+
+	mean := func(total int64) float64 { return float64(total) / float64(r.Partitions) }
+	r.ItemSkew = float64(maxItems) / mean(r.Items)
+	r.ByteSkew = float64(maxBytes) / mean(r.Bytes)
+
+	// The accesses are of all the partitions, not only those sampled.
+	var accesses, meanAccesses float64
+	if cfg.Accesses != nil {
+		name := o.table
+		if o.index != "" {
+			name = o.table + "/" + o.index
+		}
+
+		for _, kc := range cfg.Accesses.Top(name, 0) {
+			p, ok := parts[kc.Key]
+			if !ok && r.Sample < 1 {
+				p = &PartitionStats{Key: kc.Key} // not sampled
+				parts[kc.Key] = p
+			}
+
+			if p != nil {
+				p.Accesses = kc.Count
+			}
+
+			accesses += float64(kc.Count)
+		}
+
+		meanAccesses = accesses / (float64(r.Partitions) / r.Sample)
+	}
+
+	factor := cfg.HotFactor
+	if factor <= 0 {
+		factor = 10
+	}
+
+	all := make([]PartitionStats, 0, len(parts))
+	for _, p := range parts {
+		p.Hot = float64(p.Items) > factor*mean(r.Items) ||
+			float64(p.Bytes) > factor*mean(r.Bytes) ||
+			(accesses > 0 && float64(p.Accesses) > factor*meanAccesses)
+
+		all = append(all, *p)
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Bytes != all[j].Bytes {
+			return all[i].Bytes > all[j].Bytes
+		}
+
+		if all[i].Accesses != all[j].Accesses {
+			return all[i].Accesses > all[j].Accesses
+		}
+
+		return all[i].Key < all[j].Key
+	})
+
+	top := cfg.Top
+	if top <= 0 {
+		top = 10
+	}
+
+	for _, p := range all {
+		if p.Hot {
+			r.Hot = append(r.Hot, p)
+		}
+	}
+
+	r.Top = all[:min(top, len(all))]
+	return r, nil
+}
@@ -0,0 +1,309 @@
+package libdy
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// KeyAttr is a key attribute of a TableSchema.
+type KeyAttr struct {
+	Name string
+	Type string // dynamodb.ScalarAttributeType*
+}
+
+// TableSchema is the key schema of a table, as returned by DescribeSchema.
+type TableSchema struct {
+	Table   string
+	PK, SK  KeyAttr // SK is zero if the table has no sort key
+	Indexes []IndexSchema
+}
+
+// IndexSchema is a secondary index of a TableSchema.
+type IndexSchema struct {
+	Name       string
+	Local      bool // a local secondary index, else global
+	PK, SK     KeyAttr
+	Projection string // dynamodb.ProjectionType*
+}
+
+// Index returns the index name of s, if any.
+func (s *TableSchema) Index(name string) (IndexSchema, bool) {
+	for _, idx := range s.Indexes {
+		if idx.Name == name {
+			return idx, true
+		}
+	}
+
+	return IndexSchema{}, false
+}
+
+// ItemKey returns the primary key with the values pk and (if the table has
+// a sort key) sk, typed per s, e.g. for BatchGetItems:
+//
+//	s, err := c.DescribeSchema(ctx)
+//	...
+//	keys := []map[string]*dynamodb.AttributeValue{s.ItemKey("u1", "42"), s.ItemKey("u2", "7")}
+//	items, err := c.BatchGetItems(ctx, keys)
+func (s *TableSchema) ItemKey(pk, sk string) map[string]*dynamodb.AttributeValue {
+	key := map[string]*dynamodb.AttributeValue{s.PK.Name: s.PK.key(pk).attributeValue()}
+	if s.SK.Name != "" {
+		key[s.SK.Name] = s.SK.key(sk).attributeValue()
+	}
+
+	return key
+}
+
+// keyAttrs returns the names of the key attributes of s, PK first.
+func (s *TableSchema) keyAttrs() []string {
+	if s.SK.Name == "" {
+		return []string{s.PK.Name}
+	}
+
+	return []string{s.PK.Name, s.SK.Name}
+}
+
+func (a KeyAttr) key(v string) Key { return Key{Name: a.Name, Value: v, Type: a.Type} }
+
+// typed returns k with the type of the key attribute of s it names, if any.
+func (s *TableSchema) typed(k Key) Key {
+	for _, a := range []KeyAttr{s.PK, s.SK} {
+		if k.Type == "" && a.Name != "" && k.Name == a.Name {
+			k.Type = a.Type
+		}
+	}
+
+	return k
+}
+
+// indexKey returns the key attribute name of index of the table of o, with
+// value, typed per the index schema.
+func (c *Client) indexKey(ctx context.Context, o options, index, name, value string) (Key, error) {
+	k := Key{Name: name, Value: value}
+	s, err := c.schemas.get(ctx, o, c.svc, o.table)
+	if err != nil {
+		return k, fmt.Errorf("key schema failed: %w", err)
+	}
+
+	x, ok := s.Index(index)
+	if !ok {
+		return k, nil // the request fails on its own
+	}
+
+	return (&TableSchema{PK: x.PK, SK: x.SK}).typed(k), nil
+}
+
+func DescribeSchema(svc dynamodbiface.DynamoDBAPI, table string) (*TableSchema, error) {
+	return DescribeSchemaWithContext(context.Background(), svc, table)
+}
+
+// DescribeSchemaWithContext is Client.DescribeSchema for table.
+func DescribeSchemaWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string) (*TableSchema, error) {
+	return New(svc, WithTable(table)).DescribeSchema(ctx)
+}
+
+// DescribeSchema returns the key attributes and the secondary indexes of
+// the table. It is described once per table and service (svc of New),
+// shared by the Clients and the package-level functions on it, as key
+// schemas don't change; the returned TableSchema is shared, and must not be
+// modified.
+func (c *Client) DescribeSchema(ctx context.Context, opts ...Option) (*TableSchema, error) {
+	o, err := c.apply(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := c.schemas.get(ctx, o, c.svc, o.table)
+	if err != nil {
+		return nil, fmt.Errorf("DescribeSchema failed: %w", err)
+	}
+
+	return s, nil
+}
+
+// schemaCache caches the TableSchema of tables. A nil schemaCache caches
+// nothing.
+type schemaCache struct {
+	mu      sync.Mutex
+	schemas map[string]*TableSchema
+}
+
+func newSchemaCache() *schemaCache {
+	return &schemaCache{schemas: map[string]*TableSchema{}}
+}
+
+// schemaCaches holds the schemaCache of each service, by svc.
+var schemaCaches sync.Map
+
+// schemasOf returns the schemaCache of svc, shared by its Clients. Only
+// pointers are surely comparable, so other services get their own.
+func schemasOf(svc dynamodbiface.DynamoDBAPI) *schemaCache {
+	if svc == nil || reflect.ValueOf(svc).Kind() != reflect.Ptr {
+		return newSchemaCache()
+	}
+
+	if sc, ok := schemaCaches.Load(svc); ok {
+		return sc.(*schemaCache)
+	}
+
+	sc, _ := schemaCaches.LoadOrStore(svc, newSchemaCache())
+	return sc.(*schemaCache)
+}
+
+// get returns the schema of table, described with the retries of o unless
+// cached.
+func (sc *schemaCache) get(ctx context.Context, o options, svc dynamodbiface.DynamoDBAPI, table string) (*TableSchema, error) {
+	if sc != nil {
+		sc.mu.Lock()
+		s, ok := sc.schemas[table]
+		sc.mu.Unlock()
+		if ok {
+			return s, nil
+		}
+	}
+
+	var desc *dynamodb.TableDescription
+	var rerr error
+
+	// Our retriable function.
+	op := func(ctx context.Context) error {
+		desc, rerr = DescribeTableWithContext(ctx, svc, table)
+		return o.retriable(rerr)
+	}
+
+	if err := retry(ctx, o, "DescribeTable", op); err != nil {
+		return nil, err
+	}
+
+	if rerr != nil {
+		return nil, rerr
+	}
+
+	s := tableSchema(table, desc)
+	if sc != nil {
+		sc.mu.Lock()
+		sc.schemas[table] = s
+		sc.mu.Unlock()
+	}
+
+	return s, nil
+}
+
+// tableSchema returns the TableSchema of desc.
+func tableSchema(table string, desc *dynamodb.TableDescription) *TableSchema {
+	types := map[string]string{}
+	for _, d := range desc.AttributeDefinitions {
+		types[aws.StringValue(d.AttributeName)] = aws.StringValue(d.AttributeType)
+	}
+
+	keys := func(ks []*dynamodb.KeySchemaElement) (pk, sk KeyAttr) {
+		for _, k := range ks {
+			a := KeyAttr{Name: aws.StringValue(k.AttributeName), Type: types[aws.StringValue(k.AttributeName)]}
+			if aws.StringValue(k.KeyType) == dynamodb.KeyTypeHash {
+				pk = a
+			} else {
+				sk = a
+			}
+		}
+
+		return pk, sk
+	}
+
+	s := &TableSchema{Table: table}
+	s.PK, s.SK = keys(desc.KeySchema)
+	for _, idx := range desc.GlobalSecondaryIndexes {
+		is := IndexSchema{Name: aws.StringValue(idx.IndexName)}
+		is.PK, is.SK = keys(idx.KeySchema)
+		if idx.Projection != nil {
+			is.Projection = aws.StringValue(idx.Projection.ProjectionType)
+		}
+
+		s.Indexes = append(s.Indexes, is)
+	}
+
+	for _, idx := range desc.LocalSecondaryIndexes {
+		is := IndexSchema{Name: aws.StringValue(idx.IndexName), Local: true}
+		is.PK, is.SK = keys(idx.KeySchema)
+		if idx.Projection != nil {
+			is.Projection = aws.StringValue(idx.Projection.ProjectionType)
+		}
+
+		s.Indexes = append(s.Indexes, is)
+	}
+
+	return s
+}
+
+// bareKey reports whether the key string s is a bare value, without the
+// attribute name.
+func bareKey(s string) bool { return s != "" && !strings.Contains(s, ":") }
+
+// WithKeySchema sets the key attributes of the table, pk and sk (zero if
+// the table has no sort key), so bare key values, and the helpers that need
+// the key attribute names, don't read them with DescribeTable. Set it per
+// table with TableDefaults.
+func WithKeySchema(pk, sk KeyAttr) Option {
+	return func(o *options) { o.keySchema = &TableSchema{PK: pk, SK: sk} }
+}
+
+// keySchema returns the schema of the table of o, with the key attributes
+// set by WithKeySchema, if any, and else per DescribeSchema.
+func (c *Client) keySchema(ctx context.Context, o options) (*TableSchema, error) {
+	if o.keySchema != nil {
+		return o.keySchema, nil
+	}
+
+	return c.schemas.get(ctx, o, c.svc, o.table)
+}
+
+// keys returns the keys for the key strings pk and sk of the table of o.
+// Bare values are named and typed per the key schema (see DescribeSchema,
+// or WithKeySchema), failing without it; "name:value" ones are typed per
+// the same schema if set with WithKeySchema or read for a bare value, and
+// are strings otherwise, without reading it.
+func (c *Client) keys(ctx context.Context, o options, pk, sk string) (Key, Key, error) {
+	kp, ks := ParseKey(pk), ParseKey(sk)
+	s := o.keySchema
+	if s == nil && (bareKey(pk) || bareKey(sk)) {
+		var err error
+		if s, err = c.schemas.get(ctx, o, c.svc, o.table); err != nil {
+			return kp, ks, fmt.Errorf("key schema failed: %w", err)
+		}
+	}
+
+	if s == nil {
+		return kp, ks, nil
+	}
+
+	kp, ks = s.typed(kp), s.typed(ks)
+	if bareKey(pk) {
+		kp = s.PK.key(pk)
+	}
+
+	if bareKey(sk) {
+		if s.SK.Name == "" {
+			return kp, ks, fmt.Errorf("%w: %s has no sort key, got %q", ErrInvalidRequest, o.table, sk)
+		}
+
+		ks = s.SK.key(sk)
+	}
+
+	return kp, ks, nil
+}
+
+// keyAttrs returns the key attribute names of the table of o, PK first, per
+// the cached schema, or WithKeySchema.
+func (c *Client) keyAttrs(ctx context.Context, o options) ([]string, error) {
+	s, err := c.keySchema(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.keyAttrs(), nil
+}
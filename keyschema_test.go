@@ -0,0 +1,72 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// denied is a service failing DescribeTable, as without the permission.
+type denied struct {
+	dynamodbiface.DynamoDBAPI
+}
+
+func (denied) DescribeTableWithContext(aws.Context, *dynamodb.DescribeTableInput, ...request.Option) (*dynamodb.DescribeTableOutput, error) {
+	return nil, awserr.New("AccessDeniedException", "denied", nil)
+}
+
+func TestKeys(t *testing.T) {
+	n := dynamodb.ScalarAttributeTypeN
+	withSK := WithKeySchema(KeyAttr{Name: "id", Type: n}, KeyAttr{Name: "at", Type: "S"})
+	noSK := WithKeySchema(KeyAttr{Name: "id", Type: n}, KeyAttr{})
+	for _, tc := range []struct {
+		name   string
+		schema Option // nil for none
+		pk, sk string
+		wantPK Key
+		wantSK Key
+		err    error // nil for none; errAny for any
+	}{
+		{"bare", withSK, "42", "2024", Key{"id", "42", n}, Key{"at", "2024", "S"}, nil},
+		{"named", withSK, "id:42", "at:2024", Key{"id", "42", n}, Key{"at", "2024", "S"}, nil},
+		{"no sort key", withSK, "42", "", Key{"id", "42", n}, Key{}, nil},
+		{"other name", withSK, "x:1", "", Key{Name: "x", Value: "1"}, Key{}, nil},
+		{"colons", withSK, "id:42", "at:10:30", Key{"id", "42", n}, Key{"at", "10:30", "S"}, nil},
+		{"bare sort key of none", noSK, "42", "x", Key{"id", "42", n}, Key{}, ErrInvalidRequest},
+		{"named without schema", nil, "id:42", "at:2024", Key{Name: "id", Value: "42"}, Key{Name: "at", Value: "2024"}, nil},
+		{"bare without schema", nil, "42", "", Key{}, Key{}, errAny},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := []Option{WithTable("t")}
+			if tc.schema != nil {
+				opts = append(opts, tc.schema)
+			}
+
+			c := New(denied{}, opts...)
+			pk, sk, err := c.keys(context.Background(), c.opts, tc.pk, tc.sk)
+			if tc.err != nil {
+				if err == nil || (tc.err != errAny && !errors.Is(err, tc.err)) {
+					t.Errorf("err = %v, want %v", err, tc.err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if pk != tc.wantPK || sk != tc.wantSK {
+				t.Errorf("keys = %#v, %#v, want %#v, %#v", pk, sk, tc.wantPK, tc.wantSK)
+			}
+		})
+	}
+}
+
+var errAny = errors.New("any error")
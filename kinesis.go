@@ -0,0 +1,182 @@
+package libdy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+)
+
+func EnableKinesisStreaming(svc dynamodbiface.DynamoDBAPI, table, streamARN string) error {
+	return EnableKinesisStreamingWithContext(context.Background(), svc, table, streamARN)
+}
+
+// EnableKinesisStreamingWithContext starts streaming the changes of table
+// to the Kinesis data stream streamARN, for change data capture through
+// Kinesis instead of DynamoDB Streams, e.g. for longer retention or more
+// consumers. The records are decoded with DecodeKinesisRecord. It does
+// nothing if the table already streams to streamARN.
+func EnableKinesisStreamingWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, streamARN string) error {
+	status, err := kinesisStatus(ctx, svc, table, streamARN)
+	if err != nil {
+		return err
+	}
+
+	switch status {
+	case dynamodb.DestinationStatusActive, dynamodb.DestinationStatusEnabling, dynamodb.DestinationStatusUpdating:
+		return nil
+	}
+
+	_, err = svc.EnableKinesisStreamingDestinationWithContext(ctx, &dynamodb.EnableKinesisStreamingDestinationInput{
+		TableName: aws.String(table),
+		StreamArn: aws.String(streamARN),
+	})
+
+	if err != nil {
+		return fmt.Errorf("EnableKinesisStreamingDestination failed: %w", awsErr(err))
+	}
+
+	return nil
+}
+
+func DisableKinesisStreaming(svc dynamodbiface.DynamoDBAPI, table, streamARN string) error {
+	return DisableKinesisStreamingWithContext(context.Background(), svc, table, streamARN)
+}
+
+// DisableKinesisStreamingWithContext stops streaming the changes of table
+// to the Kinesis data stream streamARN. It does nothing if the table doesn't
+// stream to it.
+func DisableKinesisStreamingWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, streamARN string) error {
+	status, err := kinesisStatus(ctx, svc, table, streamARN)
+	if err != nil {
+		return err
+	}
+
+	if status != dynamodb.DestinationStatusActive && status != dynamodb.DestinationStatusUpdating {
+		return nil
+	}
+
+	_, err = svc.DisableKinesisStreamingDestinationWithContext(ctx, &dynamodb.DisableKinesisStreamingDestinationInput{
+		TableName: aws.String(table),
+		StreamArn: aws.String(streamARN),
+	})
+
+	if err != nil {
+		return fmt.Errorf("DisableKinesisStreamingDestination failed: %w", awsErr(err))
+	}
+
+	return nil
+}
+
+// kinesisStatus returns the status of the Kinesis streaming of table to
+// streamARN, or "" if there is none.
+func kinesisStatus(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, streamARN string) (string, error) {
+	res, err := svc.DescribeKinesisStreamingDestinationWithContext(ctx, &dynamodb.DescribeKinesisStreamingDestinationInput{
+		TableName: aws.String(table),
+	})
+
+	if err != nil {
+		return "", fmt.Errorf("DescribeKinesisStreamingDestination failed: %w", awsErr(err))
+	}
+
+	for _, d := range res.KinesisDataStreamDestinations {
+		if aws.StringValue(d.StreamArn) == streamARN {
+			return aws.StringValue(d.DestinationStatus), nil
+		}
+	}
+
+	return "", nil
+}
+
+// kinesisChange is the JSON of a change record in Kinesis Data Streams.
+type kinesisChange struct {
+	EventID      string `json:"eventID"`
+	EventName    string `json:"eventName"`
+	TableName    string `json:"tableName"`
+	UserIdentity *struct {
+		Type        string `json:"type"`
+		PrincipalID string `json:"principalId"`
+	} `json:"userIdentity"`
+	Dynamodb *struct {
+		ApproximateCreationDateTime int64                      `json:"ApproximateCreationDateTime"`
+		Keys                        map[string]json.RawMessage `json:"Keys"`
+		NewImage                    map[string]json.RawMessage `json:"NewImage"`
+		OldImage                    map[string]json.RawMessage `json:"OldImage"`
+	} `json:"dynamodb"`
+}
+
+// DecodeKinesisRecord decodes a change record read from a Kinesis data
+// stream that a table streams to (see EnableKinesisStreaming), e.g. with
+// GetRecords or the KCL. SequenceNumber is that of the Kinesis record, and
+// Table is set, as a stream may carry the changes of several tables.
+//
+// Unlike DynamoDB Streams, Kinesis may deliver a change more than once, or
+// out of order; compare the Time of events of the same item to tell.
+func DecodeKinesisRecord[T any](r *kinesis.Record) (ChangeEvent[T], error) {
+	return decodeKinesisData[T](r.Data, aws.StringValue(r.SequenceNumber))
+}
+
+func decodeKinesisData[T any](data []byte, seq string) (ChangeEvent[T], error) {
+	var c kinesisChange
+	if err := json.Unmarshal(data, &c); err != nil {
+		return ChangeEvent[T]{}, fmt.Errorf("invalid Kinesis record %s: %w", seq, err)
+	}
+
+	if c.Dynamodb == nil {
+		return ChangeEvent[T]{}, fmt.Errorf("invalid Kinesis record %s: no data", seq)
+	}
+
+	r := changeRecord{
+		id:    c.EventID,
+		name:  c.EventName,
+		seq:   seq,
+		table: c.TableName,
+		time:  kinesisTime(c.Dynamodb.ApproximateCreationDateTime),
+		ttl:   c.UserIdentity != nil && c.UserIdentity.Type == "Service" && c.UserIdentity.PrincipalID == ttlPrincipal,
+	}
+
+	var err error
+	for _, img := range []struct {
+		dst *map[string]*dynamodb.AttributeValue
+		src map[string]json.RawMessage
+	}{{&r.keys, c.Dynamodb.Keys}, {&r.old, c.Dynamodb.OldImage}, {&r.new, c.Dynamodb.NewImage}} {
+		if *img.dst, err = kinesisItem(img.src); err != nil {
+			return ChangeEvent[T]{}, fmt.Errorf("invalid Kinesis record %s: %w", seq, err)
+		}
+	}
+
+	return decodeChange[T](r)
+}
+
+func kinesisItem(m map[string]json.RawMessage) (map[string]*dynamodb.AttributeValue, error) {
+	if m == nil {
+		return nil, nil
+	}
+
+	ret := make(map[string]*dynamodb.AttributeValue, len(m))
+	for k, raw := range m {
+		v, err := parseTypedJSON(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+
+		ret[k] = v
+	}
+
+	return ret, nil
+}
+
+// kinesisTime returns the creation time of a Kinesis record, in
+// milliseconds, or microseconds if the table streams with that precision.
+func kinesisTime(n int64) time.Time {
+	if n >= 1e15 { // past 2001 in microseconds, 33658 in milliseconds
+		return time.UnixMicro(n)
+	}
+
+	return time.UnixMilli(n)
+}
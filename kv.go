@@ -0,0 +1,280 @@
+package libdy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Serializer encodes the values of a KV.
+type Serializer interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(b []byte, v interface{}) error
+}
+
+type jsonSerializer struct{}
+
+func (jsonSerializer) Marshal(v interface{}) ([]byte, error)   { return json.Marshal(v) }
+func (jsonSerializer) Unmarshal(b []byte, v interface{}) error { return json.Unmarshal(b, v) }
+
+type gobSerializer struct{}
+
+func (gobSerializer) Marshal(v interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(v); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+func (gobSerializer) Unmarshal(b []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(b)).Decode(v)
+}
+
+var (
+	JSONSerializer Serializer = jsonSerializer{} // the default Serializer
+	GobSerializer  Serializer = gobSerializer{}
+)
+
+// KVSchema maps a KV onto a table. The zero value is a table with a string
+// partition key "key", the values in a binary attribute "value", and
+// expiries in "expires".
+type KVSchema struct {
+	KeyAttr   string
+	ValueAttr string
+	TTLAttr   string // Time to Live should be enabled on it, see EnableTTL
+	Prefix    string // prepended to keys, e.g. to share a table
+}
+
+func (s KVSchema) withDefaults() KVSchema {
+	if s.KeyAttr == "" {
+		s.KeyAttr = "key"
+	}
+
+	if s.ValueAttr == "" {
+		s.ValueAttr = "value"
+	}
+
+	if s.TTLAttr == "" {
+		s.TTLAttr = "expires"
+	}
+
+	return s
+}
+
+// KV is a key-value store in a table, for sessions, caches, and the like:
+//
+//	sessions := libdy.NewKV(libdy.New(svc, libdy.WithTable("sessions")), libdy.KVSchema{Prefix: "session#"})
+//	err := sessions.Set(ctx, id, session, 24*time.Hour)
+//	...
+//	var s Session
+//	err := sessions.Get(ctx, id, &s)
+//	if errors.Is(err, libdy.ErrItemNotFound) {
+//		... // logged out, or expired
+//	}
+//
+// Values are serialized with JSONSerializer, or per WithSerializer, and
+// optionally compressed (see WithCodec). Expired values are no longer
+// returned, even before DynamoDB deletes them.
+type KV struct {
+	c       *Client
+	opts    []Option
+	schema  KVSchema
+	ser     Serializer
+	codec   Codec
+	codecs  map[string]Codec // by name, to decompress
+	minSize int
+}
+
+// NewKV returns a KV in the table of c (or the one set in opts), per
+// schema.
+func NewKV(c *Client, schema KVSchema, opts ...Option) *KV {
+	kv := &KV{
+		c:       c,
+		opts:    opts,
+		schema:  schema.withDefaults(),
+		ser:     JSONSerializer,
+		codecs:  map[string]Codec{},
+		minSize: compressionMinSize,
+	}
+
+	kv.codecs["gzip"] = Gzip(gzip.DefaultCompression)
+	return kv
+}
+
+// WithSerializer sets the Serializer of kv, and returns kv.
+func (kv *KV) WithSerializer(s Serializer) *KV {
+	kv.ser = s
+	return kv
+}
+
+// WithCodec compresses the values of kv of at least minSize bytes (after
+// serialization) with codec, and returns kv. The codec is stored along with
+// the value, so values are read back after switching codecs, or turning
+// compression off, as long as the codec is Gzip or was set once.
+func (kv *KV) WithCodec(codec Codec, minSize int) *KV {
+	kv.codec, kv.minSize = codec, minSize
+	kv.codecs[codec.Name()] = codec
+	return kv
+}
+
+func (kv *KV) key(key string) string {
+	return StringKey(kv.schema.KeyAttr, kv.schema.Prefix+key).String()
+}
+
+func (kv *KV) now() time.Time {
+	o, _ := kv.c.apply(kv.opts) // a missing table fails at the request
+	return o.now()
+}
+
+// item returns the item storing v under key, expiring after ttl, if not 0.
+func (kv *KV) item(key string, v interface{}, ttl time.Duration) (map[string]*dynamodb.AttributeValue, error) {
+	b, err := kv.ser.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("marshal failed: %w", err)
+	}
+
+	item := map[string]*dynamodb.AttributeValue{
+		kv.schema.KeyAttr: {S: aws.String(kv.schema.Prefix + key)},
+	}
+
+	if kv.codec != nil && len(b) >= kv.minSize {
+		z, err := kv.codec.Compress(b)
+		if err != nil {
+			return nil, fmt.Errorf("compress failed: %w", err)
+		}
+
+		if len(z) < len(b) {
+			b = z
+			item["codec"] = &dynamodb.AttributeValue{S: aws.String(kv.codec.Name())}
+		}
+	}
+
+	item[kv.schema.ValueAttr] = &dynamodb.AttributeValue{B: b}
+	if ttl > 0 {
+		item[kv.schema.TTLAttr] = ExpiryValue(kv.now().Add(ttl))
+	}
+
+	return item, nil
+}
+
+// Set stores v under key, replacing any value, to expire after ttl, or
+// never if 0.
+func (kv *KV) Set(ctx context.Context, key string, v interface{}, ttl time.Duration) error {
+	item, err := kv.item(key, v, ttl)
+	if err != nil {
+		return fmt.Errorf("Set failed: %s: %w", key, err)
+	}
+
+	if err := kv.c.PutItem(ctx, item, kv.opts...); err != nil {
+		return fmt.Errorf("Set failed: %w", err)
+	}
+
+	return nil
+}
+
+// Get decodes the value of key into v. It fails with ErrItemNotFound if
+// there is none, or if it expired.
+func (kv *KV) Get(ctx context.Context, key string, v interface{}, opts ...Option) error {
+	item, err := kv.c.GetItem(ctx, kv.key(key), "", append(kv.opts[:len(kv.opts):len(kv.opts)], opts...)...)
+	if err != nil {
+		return fmt.Errorf("Get failed: %w", err)
+	}
+
+	if exp := item[kv.schema.TTLAttr]; exp != nil {
+		sec, _ := strconv.ParseInt(aws.StringValue(exp.N), 10, 64)
+		if !kv.now().Before(time.Unix(sec, 0)) {
+			return fmt.Errorf("Get failed: %s expired: %w", key, ErrItemNotFound)
+		}
+	}
+
+	var b []byte
+	if val := item[kv.schema.ValueAttr]; val != nil {
+		b = val.B
+	}
+
+	if name := item["codec"]; name != nil {
+		codec, ok := kv.codecs[aws.StringValue(name.S)]
+		if !ok {
+			return fmt.Errorf("Get failed: %s: unknown codec %q", key, aws.StringValue(name.S))
+		}
+
+		if b, err = codec.Decompress(b); err != nil {
+			return fmt.Errorf("Get failed: %s: decompress: %w", key, err)
+		}
+	}
+
+	if err := kv.ser.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("Get failed: %s: unmarshal: %w", key, err)
+	}
+
+	return nil
+}
+
+// Delete deletes key, if it exists.
+func (kv *KV) Delete(ctx context.Context, key string) error {
+	if err := kv.c.DeleteItem(ctx, kv.key(key), "", kv.opts...); err != nil {
+		return fmt.Errorf("Delete failed: %w", err)
+	}
+
+	return nil
+}
+
+// GetOrSet decodes the value of key into v, or, if there is none, stores
+// the value returned by fn, to expire after ttl, and decodes it into v. If
+// another caller sets key meanwhile, its value wins, so all callers get the
+// same value.
+func (kv *KV) GetOrSet(ctx context.Context, key string, v interface{}, ttl time.Duration, fn func() (interface{}, error)) error {
+	err := kv.Get(ctx, key, v, WithConsistentRead())
+	if !errors.Is(err, ErrItemNotFound) {
+		return err
+	}
+
+	val, err := fn()
+	if err != nil {
+		return fmt.Errorf("GetOrSet failed: %s: %w", key, err)
+	}
+
+	item, err := kv.item(key, val, ttl)
+	if err != nil {
+		return fmt.Errorf("GetOrSet failed: %s: %w", key, err)
+	}
+
+	// No value, or an expired one not deleted yet.
+	cond := Condition{
+		Label:  "kv",
+		Expr:   "attribute_not_exists(#k0) OR #ke <= :now",
+		Names:  map[string]*string{"#k0": aws.String(kv.schema.KeyAttr), "#ke": aws.String(kv.schema.TTLAttr)},
+		Values: map[string]*dynamodb.AttributeValue{":now": ExpiryValue(kv.now())},
+	}
+
+	err = kv.c.PutItem(ctx, item, append(kv.opts[:len(kv.opts):len(kv.opts)], WithCondition(cond))...)
+	switch {
+	case errors.Is(err, ErrConditionFailed):
+		return kv.Get(ctx, key, v, WithConsistentRead())
+	case err != nil:
+		return fmt.Errorf("GetOrSet failed: %w", err)
+	}
+
+	// Decode what a Get would, e.g. for the same types.
+	b, err := kv.ser.Marshal(val)
+	if err == nil {
+		err = kv.ser.Unmarshal(b, v)
+	}
+
+	if err != nil {
+		return fmt.Errorf("GetOrSet failed: %s: %w", key, err)
+	}
+
+	return nil
+}
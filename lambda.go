@@ -0,0 +1,136 @@
+package libdy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Record is an item to write on behalf of an event record, as part of
+// Client.WriteRecords.
+type Record struct {
+	// ID identifies the event record in a Lambda partial batch response:
+	// the SQS message ID, or the sequence number of a DynamoDB or Kinesis
+	// stream record.
+	ID     string
+	Item   map[string]*dynamodb.AttributeValue
+	Delete bool // delete the item with the key Item, instead of putting Item
+}
+
+// BatchResult lists the event records of a batch that failed, to report
+// them, and only them, for retry in a Lambda partial batch response (see
+// the libdylambda subpackage).
+type BatchResult struct {
+	Failed []string // record IDs, in order
+	Errs   []error  // the error of each failed record
+}
+
+// Fail adds the record id to the failed records, e.g. one that couldn't be
+// decoded.
+func (r *BatchResult) Fail(id string, err error) {
+	for _, f := range r.Failed {
+		if f == id {
+			return
+		}
+	}
+
+	r.Failed = append(r.Failed, id)
+	r.Errs = append(r.Errs, err)
+}
+
+// Err returns the first error of the failed records, or nil.
+func (r *BatchResult) Err() error {
+	if len(r.Errs) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%d record(s) failed, first %s: %w", len(r.Failed), r.Failed[0], r.Errs[0])
+}
+
+// WriteRecords writes the items of records with batch writes (see
+// BatchPutItems), and returns the records whose writes failed, including
+// items rejected by WithSchemas, so the handler can report exactly those:
+//
+//	func handle(ctx context.Context, ev events.SQSEvent) (events.SQSEventResponse, error) {
+//		records := make([]libdy.Record, len(ev.Records))
+//		for i, m := range ev.Records {
+//			records[i] = libdy.Record{ID: m.MessageId, Item: orderItem(m)}
+//		}
+//
+//		return libdylambda.SQSResponse(client.WriteRecords(ctx, records)), nil
+//	}
+//
+// Records with the same ID share their fate. Without a table (WithTable),
+// all records fail.
+func (c *Client) WriteRecords(ctx context.Context, records []Record, opts ...Option) *BatchResult {
+	ret := &BatchResult{}
+	o, err := c.apply(opts)
+	if err != nil {
+		for _, r := range records {
+			ret.Fail(r.ID, err)
+		}
+
+		return ret
+	}
+
+	var reqs []*dynamodb.WriteRequest
+	owners := map[string][]string{} // record IDs by request identity
+	for _, r := range records {
+		var req *dynamodb.WriteRequest
+		if r.Delete {
+			req = &dynamodb.WriteRequest{DeleteRequest: &dynamodb.DeleteRequest{Key: r.Item}}
+		} else {
+			item := o.derive(r.Item)
+			if err := o.schemas.Check(o.table, item); err != nil {
+				ret.Fail(r.ID, err)
+				continue
+			}
+
+			item, err := o.encode(ctx, c.svc, item)
+			if err != nil {
+				ret.Fail(r.ID, err)
+				continue
+			}
+
+			req = &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: item}}
+		}
+
+		id := requestID(req)
+		owners[id] = append(owners[id], r.ID)
+		reqs = append(reqs, req)
+	}
+
+	failed := map[string]error{}
+	var bwe *BatchWriteError
+	if err := batchWrite(ctx, c.svc, o.table, reqs, o); errors.As(err, &bwe) {
+		for _, f := range bwe.Failures {
+			for _, id := range owners[requestID(f.Request)] {
+				failed[id] = f.Err
+			}
+		}
+	}
+
+	// Report in input order.
+	for _, r := range records {
+		if err, ok := failed[r.ID]; ok {
+			ret.Fail(r.ID, err)
+		}
+	}
+
+	return ret
+}
+
+// requestID identifies a write request by its content, which is the same in
+// the UnprocessedItems of a BatchWriteItem response.
+func requestID(r *dynamodb.WriteRequest) string {
+	if r.DeleteRequest != nil {
+		b, _ := json.Marshal(typedJSON(&dynamodb.AttributeValue{M: r.DeleteRequest.Key}))
+		return "delete " + string(b)
+	}
+
+	b, _ := json.Marshal(typedJSON(&dynamodb.AttributeValue{M: r.PutRequest.Item}))
+	return "put " + string(b)
+}
@@ -0,0 +1,175 @@
+package libdy
+
+import (
+	"math"
+	"math/bits"
+	"sync"
+	"time"
+)
+
+// Latency histograms keep 16 linear sub-buckets per power of two of
+// microseconds, so percentiles are within 6.25% of the true value.
+const (
+	histSub     = 16
+	histSubBits = 4
+	histBuckets = histSub + (64-histSubBits)*histSub
+)
+
+type histogram struct {
+	counts [histBuckets]int64
+	total  int64
+	max    uint64
+}
+
+func histBucket(us uint64) int {
+	if us < histSub {
+		return int(us)
+	}
+
+	m := bits.Len64(us) - 1 // us is in [2^m, 2^(m+1))
+	sub := int(us>>(m-histSubBits)) - histSub
+	return histSub + (m-histSubBits)*histSub + sub
+}
+
+// histUpper returns the largest value in bucket i.
+func histUpper(i int) uint64 {
+	if i < histSub {
+		return uint64(i)
+	}
+
+	m := (i-histSub)/histSub + histSubBits
+	sub := uint64((i-histSub)%histSub + histSub)
+	return (sub+1)<<(m-histSubBits) - 1
+}
+
+func (h *histogram) record(us uint64) {
+	h.counts[histBucket(us)]++
+	h.total++
+	if us > h.max {
+		h.max = us
+	}
+}
+
+func (h *histogram) merge(o *histogram) {
+	for i, n := range o.counts {
+		h.counts[i] += n
+	}
+
+	h.total += o.total
+	if o.max > h.max {
+		h.max = o.max
+	}
+}
+
+func (h *histogram) percentile(p float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+
+	rank := int64(math.Ceil(p / 100 * float64(h.total)))
+	if rank < 1 {
+		rank = 1
+	}
+
+	var seen int64
+	for i, n := range h.counts {
+		seen += n
+		if seen >= rank {
+			us := histUpper(i)
+			if us > h.max {
+				us = h.max
+			}
+
+			return time.Duration(us) * time.Microsecond
+		}
+	}
+
+	return time.Duration(h.max) * time.Microsecond
+}
+
+// Latencies keeps in-process latency histograms per table and operation
+// (see WithLatencies), for SLO checks without external metrics plumbing:
+//
+//	lat := libdy.NewLatencies()
+//	client := libdy.New(svc, libdy.WithTable("users"), libdy.WithLatencies(lat))
+//	...
+//	if lat.Percentile("users", "GetItem", 99) > 50*time.Millisecond { ... }
+//
+// Operations are named after the DynamoDB API: GetItem, PutItem, UpdateItem,
+// DeleteItem, Query, Scan, BatchGetItem, and BatchWriteItem. A latency
+// covers one request (one page, for Query and Scan), including its retries.
+type Latencies struct {
+	mu sync.Mutex
+	h  map[[2]string]*histogram
+}
+
+func NewLatencies() *Latencies {
+	return &Latencies{h: map[[2]string]*histogram{}}
+}
+
+// WithLatencies records the latency of every request in l.
+func WithLatencies(l *Latencies) Option {
+	return func(o *options) { o.latencies = l }
+}
+
+// record adds d to the histogram of table and op. A nil Latencies does
+// nothing.
+func (l *Latencies) record(table, op string, d time.Duration) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	h, ok := l.h[[2]string{table, op}]
+	if !ok {
+		h = &histogram{}
+		l.h[[2]string{table, op}] = h
+	}
+
+	h.record(uint64(d / time.Microsecond))
+}
+
+// merged returns the histogram of table and op; an empty op merges all the
+// operations on table. l.mu must be held.
+func (l *Latencies) merged(table, op string) *histogram {
+	if op != "" {
+		if h, ok := l.h[[2]string{table, op}]; ok {
+			return h
+		}
+
+		return &histogram{}
+	}
+
+	ret := &histogram{}
+	for k, h := range l.h {
+		if k[0] == table {
+			ret.merge(h)
+		}
+	}
+
+	return ret
+}
+
+// Percentile returns the pth percentile (0-100) latency of op on table, or of
+// all operations on table if op is empty. It is 0 with no samples.
+func (l *Latencies) Percentile(table, op string, p float64) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.merged(table, op).percentile(p)
+}
+
+// Count returns the number of latencies recorded for op on table, or for all
+// operations on table if op is empty.
+func (l *Latencies) Count(table, op string) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.merged(table, op).total
+}
+
+// Reset discards all recorded latencies, e.g. at the start of an SLO window.
+func (l *Latencies) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.h = map[[2]string]*histogram{}
+}
@@ -0,0 +1,164 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// LeaderElector elects a single leader among the processes running it with
+// the same name, with a Lock as the heartbeat item: the leader is the
+// holder of the lock, and a leader that stops heartbeating is replaced once
+// its lease runs out.
+//
+//	e := client.NewLeaderElector("scheduler", libdy.LockConfig{Lease: 10 * time.Second})
+//	e.OnElected = func(ctx context.Context, token int64) {
+//		runScheduler(ctx) // until ctx is canceled on demotion
+//	}
+//
+//	err := e.Run(ctx)
+type LeaderElector struct {
+	// OnElected is called in a goroutine of its own when this process
+	// becomes the leader, with the fencing token of its term (see Lock).
+	// ctx is canceled when it stops being the leader.
+	OnElected func(ctx context.Context, token int64)
+
+	// OnDemoted is called when this process stops being the leader, after
+	// the ctx of OnElected is canceled, with the reason: ErrLockLost if
+	// another process took over, the heartbeat error if it kept failing,
+	// or the Run context error.
+	OnDemoted func(err error)
+
+	name   string
+	cfg    LockConfig
+	c      *Client
+	opts   []Option
+	leader int32
+}
+
+// NewLeaderElector returns a LeaderElector for the election name, held in
+// the table of c (or the one set in opts) as a Lock; see AcquireLock for
+// cfg, whose Wait is ignored.
+func (c *Client) NewLeaderElector(name string, cfg LockConfig, opts ...Option) *LeaderElector {
+	if cfg.Owner == "" {
+		o, _ := c.apply(opts) // a missing table fails at the first campaign
+		cfg.Owner = defaultOwner(o)
+	}
+
+	if cfg.Lease <= 0 {
+		cfg.Lease = lockLease
+	}
+
+	cfg.Wait = -1
+	return &LeaderElector{name: name, cfg: cfg, c: c, opts: opts}
+}
+
+// IsLeader reports whether this process is the leader.
+func (e *LeaderElector) IsLeader() bool { return atomic.LoadInt32(&e.leader) == 1 }
+
+// Run campaigns for leadership until ctx is done, leading whenever elected,
+// and stepping down, releasing the lock, on return. It returns the context
+// error, or an error of the election table.
+func (e *LeaderElector) Run(ctx context.Context) error {
+	for {
+		lock, err := e.c.AcquireLock(ctx, e.name, e.cfg, e.opts...)
+		if err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("LeaderElector canceled: %w", ctx.Err())
+			}
+
+			return fmt.Errorf("LeaderElector failed: %w", err)
+		}
+
+		err = e.lead(ctx, lock)
+		if ctx.Err() != nil {
+			return fmt.Errorf("LeaderElector canceled: %w", ctx.Err())
+		}
+
+		if !errors.Is(err, ErrLockLost) {
+			// The heartbeats failed; wait out the lease before campaigning
+			// again, as the table is likely unavailable.
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("LeaderElector canceled: %w", ctx.Err())
+			case <-time.After(e.cfg.Lease):
+			}
+		}
+	}
+}
+
+// lead runs the term of lock, heartbeating until it is lost, ctx is done,
+// or the heartbeats fail for two thirds of the lease, before another
+// process may take over. It returns the reason the term ended.
+func (e *LeaderElector) lead(ctx context.Context, lock *Lock) error {
+	lctx, cancel := context.WithCancel(ctx)
+	atomic.StoreInt32(&e.leader, 1)
+	if e.OnElected != nil {
+		go e.OnElected(lctx, lock.Token)
+	}
+
+	t := time.NewTicker(e.cfg.Lease / 3)
+	defer t.Stop()
+	last := time.Now()
+	var err error
+	for err == nil {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			continue
+		case <-t.C:
+		}
+
+		// Bound the heartbeat's retries by the time left to step down.
+		hctx, hcancel := context.WithDeadline(ctx, last.Add(2*e.cfg.Lease/3))
+		herr := lock.Heartbeat(hctx)
+		hcancel()
+		switch {
+		case herr == nil:
+			last = time.Now()
+		case errors.Is(herr, ErrLockLost):
+			err = herr
+		case ctx.Err() != nil:
+			err = ctx.Err()
+		case time.Since(last) >= 2*e.cfg.Lease/3:
+			err = herr
+		}
+	}
+
+	atomic.StoreInt32(&e.leader, 0)
+	cancel()
+	if e.OnDemoted != nil {
+		e.OnDemoted(err)
+	}
+
+	if !errors.Is(err, ErrLockLost) {
+		rctx, rcancel := context.WithTimeout(context.Background(), e.cfg.Lease/3)
+		lock.Release(rctx) // best effort; the lease runs out anyway
+		rcancel()
+	}
+
+	return err
+}
+
+// Leader returns the owner holding the election name, or "" if there is
+// none. A leader that died is reported until its lease runs out and another
+// process takes over.
+func (c *Client) Leader(ctx context.Context, name string, opts ...Option) (string, error) {
+	item, err := c.GetItem(ctx, StringKey("id", name).String(), "", append(opts[:len(opts):len(opts)], WithConsistentRead())...)
+	if errors.Is(err, ErrItemNotFound) {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("Leader failed: %w", err)
+	}
+
+	l := parseLock(item)
+	if l.released {
+		return "", nil
+	}
+
+	return l.owner, nil
+}
@@ -1,21 +1,30 @@
 package libdy
 
 import (
+	"context"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/cenkalti/backoff"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 )
 
-func query(svc *dynamodb.DynamoDB, table string, input *dynamodb.QueryInput) ([]map[string]*dynamodb.AttributeValue, error) {
-	start := time.Now()
-	ret := []map[string]*dynamodb.AttributeValue{}
-	var lastKey map[string]*dynamodb.AttributeValue
+func query(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, input *dynamodb.QueryInput, o options) (*Result, error) {
+	ctx, cancel := o.budgetContext(ctx)
+	defer cancel()
+	ret := &Result{Items: []map[string]*dynamodb.AttributeValue{}}
+	lastKey := o.startKey
 	more := true
+	pages, earlier := 0, 0
+	start := time.Now()
+	o.retries = &ret.Retries
+	defer func() {
+		ret.Pages, ret.Elapsed = pages, time.Since(start)
+		o.reportCapacity(table, "Query", ret.ConsumedCapacity, pages)
+	}()
+
+	meter := o.costMeter()
 
 	// Could be paginated.
 	for more {
@@ -23,249 +32,543 @@ func query(svc *dynamodb.DynamoDB, table string, input *dynamodb.QueryInput) ([]
 			input.ExclusiveStartKey = lastKey
 		}
 
-		var rerr, err error
-		var res *dynamodb.QueryOutput
-
-		// Our retriable, backoff-able function.
-		op := func() error {
-			res, err = svc.Query(input)
-			rerr = err
-			if err != nil {
-				if aerr, ok := err.(awserr.Error); ok {
-					switch aerr.Code() {
-					case dynamodb.ErrCodeProvisionedThroughputExceededException:
-						return err // will cause retry with backoff
-					}
-				}
-			}
-
-			return nil // final err is rerr
+		if l := o.pageLimit(int64(len(ret.Items))); l > 0 {
+			input.Limit = aws.Int64(l)
 		}
 
-		err = backoff.Retry(op, backoff.NewExponentialBackOff())
-		if err != nil {
-			return nil, fmt.Errorf("query failed after %v: %w", time.Since(start), err)
+		o.pageNum = pages + 1
+		res, err := queryPage(ctx, svc, input, o)
+		if err != nil && o.budgetExpired(ctx) {
+			ret.Partial = true
+			ret.LastKey = lastKey
+			return ret, nil
 		}
 
-		if rerr != nil {
-			return nil, fmt.Errorf("query failed: %w", rerr)
+		if err != nil {
+			return nil, err
 		}
 
-		ret = append(ret, res.Items...)
+		pages++
+		ret.Count += aws.Int64Value(res.Count)
+		ret.ScannedCount += aws.Int64Value(res.ScannedCount)
+		ret.ConsumedCapacity += capacityUnits(res.ConsumedCapacity)
+		meter.add(capacityUnits(res.ConsumedCapacity))
+		earlier = len(ret.Items)
+		ret.Items = append(ret.Items, res.Items...)
 		more = false
+		ret.LastKey = res.LastEvaluatedKey
 		if res.LastEvaluatedKey != nil {
 			lastKey = res.LastEvaluatedKey
 			more = true
 		}
 
-		if input.Limit != nil {
-			if int64(len(ret)) >= *input.Limit {
+		if o.maxItems > 0 && int64(len(ret.Items)) >= o.maxItems {
+			ret.Items = ret.Items[:o.maxItems]
+			more = false
+		}
+
+		// Legacy limit: both the page size and a (loose) total cap.
+		if input.Limit != nil && o.pageSize == 0 && o.maxItems == 0 {
+			if int64(len(ret.Items)) >= *input.Limit {
 				more = false
 				lastKey = nil
 			}
 		}
+
+		if more && meter.exceeded() {
+			err := meter.err("Query", table)
+			err.LastKey = lastKey
+			return nil, err
+		}
+	}
+
+	if err := o.snapshot.check(ctx, svc, table, ret, earlier, o); err != nil {
+		return nil, err
 	}
 
 	return ret, nil
 }
 
-func GetItems(svc *dynamodb.DynamoDB, table, pk, sk string, limit ...int64) ([]map[string]*dynamodb.AttributeValue, error) {
-	v1 := strings.Split(pk, ":")
-	v2 := strings.Split(sk, ":")
-	var input *dynamodb.QueryInput
-	if sk != "" {
-		skexpr := fmt.Sprintf("%v = :pk AND begins_with(%v, :sk)", v1[0], v2[0])
-		input = &dynamodb.QueryInput{
-			TableName:              aws.String(table),
-			KeyConditionExpression: aws.String(skexpr),
-			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-				":pk": {S: aws.String(v1[1])},
-				":sk": {S: aws.String(v2[1])},
-			},
-			ScanIndexForward: aws.Bool(false), // descending order
-		}
+// queryPage fetches a single page, retrying throttled requests with backoff.
+func queryPage(ctx context.Context, svc dynamodbiface.DynamoDBAPI, input *dynamodb.QueryInput, o options) (*dynamodb.QueryOutput, error) {
+	if err := o.applyFilter(&input.FilterExpression, &input.ExpressionAttributeNames, &input.ExpressionAttributeValues); err != nil {
+		return nil, err
+	}
 
-		if len(limit) > 0 {
-			input.Limit = aws.Int64(limit[0])
-		}
-	} else {
-		input = &dynamodb.QueryInput{
-			TableName:              aws.String(table),
-			KeyConditionExpression: aws.String(fmt.Sprintf("%v = :pk", v1[0])),
-			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-				":pk": {S: aws.String(v1[1])},
-			},
-			ScanIndexForward: aws.Bool(false), // descending order
-		}
+	o.applyProjection(&input.ProjectionExpression, &input.ExpressionAttributeNames)
+
+	if o.consistent && input.IndexName == nil { // not supported on GSIs
+		input.ConsistentRead = aws.Bool(true)
+	}
+
+	if rc := o.returnCapacity(); rc != nil {
+		input.ReturnConsumedCapacity = rc
+	}
 
-		if len(limit) > 0 {
-			input.Limit = aws.Int64(limit[0])
+	if err := o.readLimit.wait(ctx); err != nil {
+		return nil, fmt.Errorf("query canceled: %w", err)
+	}
+
+	start := time.Now()
+	var rerr, err error
+	var res *dynamodb.QueryOutput
+
+	// Our retriable, backoff-able function.
+	op := func(ctx context.Context) error {
+		var v interface{}
+		v, err = hedged(ctx, o.hedge, func(ctx context.Context) (interface{}, error) {
+			return svc.QueryWithContext(ctx, input)
+		})
+
+		res, _ = v.(*dynamodb.QueryOutput)
+		rerr = err
+		return o.retriable(err)
+	}
+
+	tries, err := retryN(ctx, o, "Query", op)
+	if o.retries != nil && tries > 1 {
+		*o.retries += tries - 1
+	}
+
+	o.observe(ctx, "Query", aws.StringValue(input.TableName), start, tries, res, err, rerr)
+	if (err != nil || rerr != nil) && ctx.Err() != nil {
+		return nil, fmt.Errorf("query canceled after %v: %w", time.Since(start), ctx.Err())
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("query failed after %v: %w", time.Since(start), err)
+	}
+
+	if rerr != nil {
+		return nil, fmt.Errorf("query failed: %w", awsErr(rerr))
+	}
+
+	o.readLimit.consume(capacityUnits(res.ConsumedCapacity))
+	return res, nil
+}
+
+func GetItems(svc dynamodbiface.DynamoDBAPI, table, pk, sk string, limit ...int64) ([]map[string]*dynamodb.AttributeValue, error) {
+	return GetItemsWithContext(context.Background(), svc, table, pk, sk, limit...)
+}
+
+// GetItemsWithContext reads the items under pk, and the sk prefix if set,
+// in descending order. Bare key values are named and typed per the key
+// schema of table (see ParseKey), described once per svc.
+func GetItemsWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, pk, sk string, limit ...int64) ([]map[string]*dynamodb.AttributeValue, error) {
+	o := options{table: table}
+	kp, ks, err := New(svc).keys(ctx, o, pk, sk)
+	if err != nil {
+		return nil, fmt.Errorf("GetItems failed: %w", err)
+	}
+
+	res, err := query(ctx, svc, table, queryInput(table, kp, ks, limit...), o)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Items, nil
+}
+
+// queryInput returns the input to read the items under pk, with the sk
+// prefix if set (or, for number sort keys, equal to sk), in descending order.
+func queryInput(table string, pk, sk Key, limit ...int64) *dynamodb.QueryInput {
+	// Names are aliased, so reserved words like "name" or "status" are fine.
+	expr := "#pk = :pk"
+	names := map[string]*string{"#pk": aws.String(pk.Name)}
+	values := map[string]*dynamodb.AttributeValue{":pk": pk.attributeValue()}
+	if sk.Name != "" {
+		if sk.Type == dynamodb.ScalarAttributeTypeN {
+			expr += " AND #sk = :sk" // no prefixes for numbers
+		} else {
+			expr += " AND begins_with(#sk, :sk)"
 		}
+
+		names["#sk"] = aws.String(sk.Name)
+		values[":sk"] = sk.attributeValue()
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(table),
+		KeyConditionExpression:    aws.String(expr),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		ScanIndexForward:          aws.Bool(false), // descending order
+	}
+
+	if len(limit) > 0 {
+		input.Limit = aws.Int64(limit[0])
 	}
 
-	return query(svc, table, input)
+	return input
+}
+
+func GetGsiItems(svc dynamodbiface.DynamoDBAPI, table, index, key, value string, opts ...Option) ([]map[string]*dynamodb.AttributeValue, error) {
+	return GetGsiItemsWithContext(context.Background(), svc, table, index, key, value, opts...)
 }
 
-func GetGsiItems(svc *dynamodb.DynamoDB, table, index, key, value string) ([]map[string]*dynamodb.AttributeValue, error) {
-	input := dynamodb.QueryInput{
-		TableName:              aws.String(table),
-		IndexName:              aws.String(index),
-		KeyConditionExpression: aws.String(fmt.Sprintf("%v = :v", key)),
-		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":v": {S: aws.String(value)},
-		},
+// GetGsiItemsWithContext reads the items in index whose key equals value,
+// typed per the index schema. opts are the read options of Client.QueryIndex, e.g.
+//
+//	items, err := libdy.GetGsiItemsWithContext(ctx, svc, "orders", "by_customer", "customer", "c1",
+//		libdy.WithSortKey(libdy.SKGreaterOrEqual(libdy.StringKey("created", "2024-01-01"))),
+//		libdy.WithScanIndexForward(false),
+//		libdy.WithLimit(20))
+func GetGsiItemsWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, index, key, value string, opts ...Option) ([]map[string]*dynamodb.AttributeValue, error) {
+	o := options{table: table}
+	for _, opt := range opts {
+		opt(&o)
 	}
 
-	return query(svc, table, &input)
+	k, err := New(svc).indexKey(ctx, o, index, key, value)
+	if err != nil {
+		return nil, fmt.Errorf("GetGsiItems failed: %w", err)
+	}
+
+	res, err := query(ctx, svc, table, o.indexQueryInput(index, k), o)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Items, nil
 }
 
-func ScanItems(svc *dynamodb.DynamoDB, table string, limit ...int64) ([]map[string]*dynamodb.AttributeValue, error) {
-	start := time.Now()
-	ret := []map[string]*dynamodb.AttributeValue{}
-	var lastKey map[string]*dynamodb.AttributeValue
-	more := true
+// indexQueryInput returns the input to read the items in index whose key
+// attribute equals key.
+func indexQueryInput(table, index string, key Key) *dynamodb.QueryInput {
+	return &dynamodb.QueryInput{
+		TableName:                 aws.String(table),
+		IndexName:                 aws.String(index),
+		KeyConditionExpression:    aws.String("#v = :v"),
+		ExpressionAttributeNames:  map[string]*string{"#v": aws.String(key.Name)},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":v": key.attributeValue()},
+	}
+}
+
+func ScanItems(svc dynamodbiface.DynamoDBAPI, table string, limit ...int64) ([]map[string]*dynamodb.AttributeValue, error) {
+	return ScanItemsWithContext(context.Background(), svc, table, limit...)
+}
 
+func ScanItemsWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, limit ...int64) ([]map[string]*dynamodb.AttributeValue, error) {
 	in := dynamodb.ScanInput{TableName: aws.String(table)}
 	if len(limit) > 0 {
 		in.Limit = aws.Int64(limit[0])
 	}
 
+	res, err := scan(ctx, svc, &in, options{})
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Items, nil
+}
+
+func ScanTable(svc dynamodbiface.DynamoDBAPI, table string, opts ...Option) ([]map[string]*dynamodb.AttributeValue, error) {
+	return ScanTableWithContext(context.Background(), svc, table, opts...)
+}
+
+// ScanTableWithContext reads the items of table with the read options of
+// Client.Scan, e.g.
+//
+//	items, err := libdy.ScanTableWithContext(ctx, svc, "orders",
+//		libdy.WithFilterCondition(expression.Name("status").Equal(expression.Value("open"))),
+//		libdy.WithProjection("id", "status"),
+//		libdy.WithIndex("by-status"),
+//		libdy.WithSegment(0, 4))
+func ScanTableWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, opts ...Option) ([]map[string]*dynamodb.AttributeValue, error) {
+	return New(svc, WithTable(table)).ScanItems(ctx, opts...)
+}
+
+func scan(ctx context.Context, svc dynamodbiface.DynamoDBAPI, in *dynamodb.ScanInput, o options) (*Result, error) {
+	ctx, cancel := o.budgetContext(ctx)
+	defer cancel()
+	ret := &Result{Items: []map[string]*dynamodb.AttributeValue{}}
+	lastKey := o.startKey
+	more := true
+	pages, earlier := 0, 0
+	start := time.Now()
+	o.retries = &ret.Retries
+	defer func() {
+		ret.Pages, ret.Elapsed = pages, time.Since(start)
+		o.reportCapacity(aws.StringValue(in.TableName), "Scan", ret.ConsumedCapacity, pages)
+	}()
+
+	meter := o.costMeter()
+
 	// Could be paginated.
 	for more {
 		if lastKey != nil {
 			in.ExclusiveStartKey = lastKey
 		}
 
-		var rerr, err error
-		var res *dynamodb.ScanOutput
-
-		// Our retriable, backoff-able function.
-		op := func() error {
-			res, err = svc.Scan(&in)
-			rerr = err
-			if err != nil {
-				if aerr, ok := err.(awserr.Error); ok {
-					switch aerr.Code() {
-					case dynamodb.ErrCodeProvisionedThroughputExceededException:
-						return err // will cause retry with backoff
-					}
-				}
-			}
-
-			return nil // final err is rerr
+		if l := o.pageLimit(int64(len(ret.Items))); l > 0 {
+			in.Limit = aws.Int64(l)
 		}
 
-		err = backoff.Retry(op, backoff.NewExponentialBackOff())
-		if err != nil {
-			return nil, fmt.Errorf("ScanItems failed after %v: %w", time.Since(start), err)
+		o.pageNum = pages + 1
+		res, err := scanPage(ctx, svc, in, o)
+		if err != nil && o.budgetExpired(ctx) {
+			ret.Partial = true
+			ret.LastKey = lastKey
+			return ret, nil
 		}
 
-		if rerr != nil {
-			return nil, fmt.Errorf("ScanItems failed: %w", rerr)
+		if err != nil {
+			return nil, err
 		}
 
-		ret = append(ret, res.Items...)
+		pages++
+		ret.Count += aws.Int64Value(res.Count)
+		ret.ScannedCount += aws.Int64Value(res.ScannedCount)
+		ret.ConsumedCapacity += capacityUnits(res.ConsumedCapacity)
+		meter.add(capacityUnits(res.ConsumedCapacity))
+		earlier = len(ret.Items)
+		ret.Items = append(ret.Items, res.Items...)
 		more = false
+		ret.LastKey = res.LastEvaluatedKey
 		if res.LastEvaluatedKey != nil {
 			lastKey = res.LastEvaluatedKey
 			more = true
 		}
 
-		if in.Limit != nil {
-			if int64(len(ret)) >= *in.Limit {
+		if o.maxItems > 0 && int64(len(ret.Items)) >= o.maxItems {
+			ret.Items = ret.Items[:o.maxItems]
+			more = false
+		}
+
+		// Legacy limit: both the page size and a (loose) total cap.
+		if in.Limit != nil && o.pageSize == 0 && o.maxItems == 0 {
+			if int64(len(ret.Items)) >= *in.Limit {
 				more = false
 				lastKey = nil
 			}
 		}
+
+		if more && meter.exceeded() {
+			err := meter.err("Scan", aws.StringValue(in.TableName))
+			err.LastKey = lastKey
+			return nil, err
+		}
+	}
+
+	if err := o.snapshot.check(ctx, svc, aws.StringValue(in.TableName), ret, earlier, o); err != nil {
+		return nil, err
 	}
 
 	return ret, nil
 }
 
-func PutItem(svc *dynamodb.DynamoDB, table string, item map[string]*dynamodb.AttributeValue) error {
+// scanPage fetches a single page, retrying throttled requests with backoff.
+func scanPage(ctx context.Context, svc dynamodbiface.DynamoDBAPI, in *dynamodb.ScanInput, o options) (*dynamodb.ScanOutput, error) {
+	if err := o.applyFilter(&in.FilterExpression, &in.ExpressionAttributeNames, &in.ExpressionAttributeValues); err != nil {
+		return nil, err
+	}
+
+	o.applyProjection(&in.ProjectionExpression, &in.ExpressionAttributeNames)
+	if o.consistent && in.IndexName == nil { // not supported on GSIs
+		in.ConsistentRead = aws.Bool(true)
+	}
+
+	in.ReturnConsumedCapacity = o.returnCapacity()
+	if err := o.readLimit.wait(ctx); err != nil {
+		return nil, fmt.Errorf("ScanItems canceled: %w", err)
+	}
+
 	start := time.Now()
 	var rerr, err error
+	var res *dynamodb.ScanOutput
 
-	// Our retriable function.
-	op := func() error {
-		_, err = svc.PutItem(&dynamodb.PutItemInput{
-			TableName: aws.String(table),
-			Item:      item,
+	// Our retriable, backoff-able function.
+	op := func(ctx context.Context) error {
+		var v interface{}
+		v, err = hedged(ctx, o.hedge, func(ctx context.Context) (interface{}, error) {
+			return svc.ScanWithContext(ctx, in)
 		})
 
+		res, _ = v.(*dynamodb.ScanOutput)
 		rerr = err
-		if err != nil {
-			if aerr, ok := err.(awserr.Error); ok {
-				switch aerr.Code() {
-				case dynamodb.ErrCodeProvisionedThroughputExceededException:
-					return err // will cause retry with backoff
-				}
-			}
-		}
+		return o.retriable(err)
+	}
 
-		return nil // final err is rerr
+	tries, err := retryN(ctx, o, "ScanItems", op)
+	if o.retries != nil && tries > 1 {
+		*o.retries += tries - 1
+	}
+
+	o.observe(ctx, "Scan", aws.StringValue(in.TableName), start, tries, res, err, rerr)
+	if (err != nil || rerr != nil) && ctx.Err() != nil {
+		return nil, fmt.Errorf("ScanItems canceled after %v: %w", time.Since(start), ctx.Err())
 	}
 
-	err = backoff.Retry(op, backoff.NewExponentialBackOff())
 	if err != nil {
-		return fmt.Errorf("PutItem failed after %v: %w", time.Since(start), err)
+		return nil, fmt.Errorf("ScanItems failed after %v: %w", time.Since(start), err)
 	}
 
 	if rerr != nil {
-		return fmt.Errorf("PutItem failed: %w", rerr)
+		return nil, fmt.Errorf("ScanItems failed: %w", awsErr(rerr))
+	}
+
+	o.readLimit.consume(capacityUnits(res.ConsumedCapacity))
+	return res, nil
+}
+
+func PutItem(svc dynamodbiface.DynamoDBAPI, table string, item map[string]*dynamodb.AttributeValue, opts ...Option) error {
+	return PutItemWithContext(context.Background(), svc, table, item, opts...)
+}
+
+// PutItemWithContext writes item, replacing any existing item with the same
+// key. With WithCondition, the write only lands if the condition holds, and
+// fails with ErrConditionFailed otherwise.
+func PutItemWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, item map[string]*dynamodb.AttributeValue, opts ...Option) error {
+	o := options{table: table}
+	for _, opt := range opts {
+		opt(&o)
 	}
 
-	return nil
+	_, err := putItem(ctx, svc, item, o)
+	return err
 }
 
-func DeleteItem(svc *dynamodb.DynamoDB, table, pk, sk string) error {
-	v1 := strings.Split(pk, ":")
-	v2 := strings.Split(sk, ":")
+// putItem writes item, returning the attributes per o.returnValues.
+func putItem(ctx context.Context, svc dynamodbiface.DynamoDBAPI, item map[string]*dynamodb.AttributeValue, o options) (map[string]*dynamodb.AttributeValue, error) {
+	item = o.derive(item)
+	if err := o.schemas.Check(o.table, item); err != nil {
+		return nil, fmt.Errorf("PutItem failed: %w", err)
+	}
+
+	item, err := o.encode(ctx, svc, item)
+	if err != nil {
+		return nil, fmt.Errorf("PutItem failed: %w", err)
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName:              aws.String(o.table),
+		Item:                   item,
+		ReturnConsumedCapacity: o.returnCapacity(),
+	}
+
+	input.ConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues = o.conditionInput()
+	input.ReturnValues = o.returnOld()
+	if o.oldOnFail {
+		input.ReturnValuesOnConditionCheckFailure = aws.String(dynamodb.ReturnValuesOnConditionCheckFailureAllOld)
+	}
+
+	if err := o.writeLimit.wait(ctx); err != nil {
+		return nil, fmt.Errorf("PutItem canceled: %w", err)
+	}
+
 	start := time.Now()
-	var input *dynamodb.DeleteItemInput
-	if sk == "" {
-		input = &dynamodb.DeleteItemInput{
-			TableName: aws.String(table),
-			Key: map[string]*dynamodb.AttributeValue{
-				v1[0]: {S: aws.String(v1[1])},
-			},
-		}
-	} else {
-		input = &dynamodb.DeleteItemInput{
-			TableName: aws.String(table),
-			Key: map[string]*dynamodb.AttributeValue{
-				v1[0]: {S: aws.String(v1[1])},
-				v2[0]: {S: aws.String(v2[1])},
-			},
-		}
+	var rerr error
+	var res *dynamodb.PutItemOutput
+
+	// Our retriable function.
+	op := func(ctx context.Context) error {
+		res, err = svc.PutItemWithContext(ctx, input)
+		rerr = err
+		return o.retriable(err)
+	}
+
+	tries, err := retryN(ctx, o, "PutItem", op)
+	o.observe(ctx, "PutItem", o.table, start, tries, res, err, rerr)
+	o.conditionFailed(rerr)
+	if (err != nil || rerr != nil) && ctx.Err() != nil {
+		return nil, fmt.Errorf("PutItem canceled after %v: %w", time.Since(start), ctx.Err())
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("PutItem failed after %v: %w", time.Since(start), err)
+	}
+
+	if rerr != nil {
+		return nil, fmt.Errorf("PutItem failed: %w", awsErr(rerr))
+	}
+
+	units := capacityUnits(res.ConsumedCapacity)
+	o.writeLimit.consume(units)
+	o.reportCapacity(o.table, "PutItem", units, 1)
+	return o.decode(ctx, res.Attributes)
+}
+
+func DeleteItem(svc dynamodbiface.DynamoDBAPI, table, pk, sk string, opts ...Option) error {
+	return DeleteItemWithContext(context.Background(), svc, table, pk, sk, opts...)
+}
+
+// DeleteItemWithContext deletes the item with the given key. With
+// WithCondition, the delete only happens if the condition holds, and fails
+// with ErrConditionFailed otherwise. A missing item is no error, unless
+// WithMustExist is set.
+func DeleteItemWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, pk, sk string, opts ...Option) error {
+	o := options{table: table}
+	for _, opt := range opts {
+		opt(&o)
 	}
 
+	kp, ks, err := New(svc).keys(ctx, o, pk, sk)
+	if err != nil {
+		return fmt.Errorf("DeleteItem failed: %w", err)
+	}
+
+	_, err = deleteItem(ctx, svc, keyMap(kp, ks), o)
+	return err
+}
+
+// itemKey returns the primary key for "name:value" pk and (optional) sk.
+func itemKey(pk, sk string) map[string]*dynamodb.AttributeValue {
+	return keyMap(ParseKey(pk), ParseKey(sk))
+}
+
+// deleteItem deletes the item with key, returning the attributes per
+// o.returnValues.
+func deleteItem(ctx context.Context, svc dynamodbiface.DynamoDBAPI, key map[string]*dynamodb.AttributeValue, o options) (map[string]*dynamodb.AttributeValue, error) {
+	input := &dynamodb.DeleteItemInput{
+		TableName:              aws.String(o.table),
+		Key:                    key,
+		ReturnConsumedCapacity: o.returnCapacity(),
+	}
+
+	o.condition = o.existsCondition(key)
+	if o.mustExist {
+		input.ReturnValuesOnConditionCheckFailure = aws.String(dynamodb.ReturnValuesOnConditionCheckFailureAllOld)
+	}
+
+	input.ConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues = o.conditionInput()
+	input.ReturnValues = o.returnOld()
+	if err := o.writeLimit.wait(ctx); err != nil {
+		return nil, fmt.Errorf("DeleteItem canceled: %w", err)
+	}
+
+	start := time.Now()
 	var rerr error
+	var res *dynamodb.DeleteItemOutput
 
 	// Our retriable function.
-	op := func() error {
-		_, err := svc.DeleteItem(input)
+	op := func(ctx context.Context) error {
+		var err error
+		res, err = svc.DeleteItemWithContext(ctx, input)
 		rerr = err
-		if err != nil {
-			if aerr, ok := err.(awserr.Error); ok {
-				switch aerr.Code() {
-				case dynamodb.ErrCodeProvisionedThroughputExceededException:
-					return err // will cause retry with backoff
-				}
-			}
-		}
+		return o.retriable(err)
+	}
 
-		return nil // final err is rerr
+	tries, err := retryN(ctx, o, "DeleteItem", op)
+	o.observe(ctx, "DeleteItem", o.table, start, tries, res, err, rerr)
+	o.conditionFailed(rerr)
+	if (err != nil || rerr != nil) && ctx.Err() != nil {
+		return nil, fmt.Errorf("DeleteItem canceled after %v: %w", time.Since(start), ctx.Err())
 	}
 
-	err := backoff.Retry(op, backoff.NewExponentialBackOff())
 	if err != nil {
-		return fmt.Errorf("DeleteItem failed after %v: %w", time.Since(start), err)
+		return nil, fmt.Errorf("DeleteItem failed after %v: %w", time.Since(start), err)
+	}
+
+	if o.missing(rerr) {
+		return nil, fmt.Errorf("DeleteItem failed: %w", ErrItemNotFound)
 	}
 
 	if rerr != nil {
-		return fmt.Errorf("DeleteItem failed: %w", rerr)
+		return nil, fmt.Errorf("DeleteItem failed: %w", awsErr(rerr))
 	}
 
-	return nil
+	units := capacityUnits(res.ConsumedCapacity)
+	o.writeLimit.consume(units)
+	o.reportCapacity(o.table, "DeleteItem", units, 1)
+	return o.decode(ctx, res.Attributes)
 }
@@ -0,0 +1,83 @@
+package libdy
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestQueryInput(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		pk, sk Key
+		limit  []int64
+		expr   string
+		names  map[string]string
+	}{
+		{"pk", StringKey("name", "a"), Key{}, nil, "#pk = :pk", map[string]string{"#pk": "name"}},
+		{"prefix", StringKey("name", "a"), StringKey("status", "ok"), nil,
+			"#pk = :pk AND begins_with(#sk, :sk)", map[string]string{"#pk": "name", "#sk": "status"}},
+		{"number", IntKey("id", 1), IntKey("at", 2), []int64{5},
+			"#pk = :pk AND #sk = :sk", map[string]string{"#pk": "id", "#sk": "at"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			in := queryInput("t", tc.pk, tc.sk, tc.limit...)
+			if got := aws.StringValue(in.KeyConditionExpression); got != tc.expr {
+				t.Errorf("KeyConditionExpression = %q, want %q", got, tc.expr)
+			}
+
+			names := aws.StringValueMap(in.ExpressionAttributeNames)
+			if len(names) != len(tc.names) {
+				t.Errorf("names = %v, want %v", names, tc.names)
+			}
+
+			for k, v := range tc.names {
+				if names[k] != v {
+					t.Errorf("names = %v, want %v", names, tc.names)
+				}
+			}
+
+			if got := in.ExpressionAttributeValues[":pk"]; !equalValue(got, tc.pk.attributeValue()) {
+				t.Errorf(":pk = %v, want %v", got, tc.pk.attributeValue())
+			}
+
+			if tc.sk.Name != "" && !equalValue(in.ExpressionAttributeValues[":sk"], tc.sk.attributeValue()) {
+				t.Errorf(":sk = %v, want %v", in.ExpressionAttributeValues[":sk"], tc.sk.attributeValue())
+			}
+
+			if aws.BoolValue(in.ScanIndexForward) {
+				t.Error("ScanIndexForward, want descending")
+			}
+
+			if (in.Limit != nil) != (len(tc.limit) > 0) || (in.Limit != nil && *in.Limit != tc.limit[0]) {
+				t.Errorf("Limit = %v, want %v", in.Limit, tc.limit)
+			}
+		})
+	}
+}
+
+func TestPageLimit(t *testing.T) {
+	for _, tc := range []struct {
+		pageSize, maxItems, read int64
+		want                     int64
+	}{
+		{0, 0, 0, 0},
+		{10, 0, 30, 10},
+		{0, 25, 0, 25},
+		{0, 25, 20, 5},
+		{10, 25, 0, 10},
+		{10, 25, 20, 5},
+		{10, 25, 25, 0},
+	} {
+		o := options{pageSize: tc.pageSize, maxItems: tc.maxItems}
+		if got := o.pageLimit(tc.read); got != tc.want {
+			t.Errorf("pageLimit(%d) of page size %d, max %d = %d, want %d", tc.read, tc.pageSize, tc.maxItems, got, tc.want)
+		}
+	}
+}
+
+// equalValue compares the scalars of a and b.
+func equalValue(a, b *dynamodb.AttributeValue) bool {
+	return a != nil && b != nil && aws.StringValue(a.S) == aws.StringValue(b.S) && aws.StringValue(a.N) == aws.StringValue(b.N)
+}
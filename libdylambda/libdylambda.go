@@ -0,0 +1,152 @@
+// Package libdylambda adapts libdy to AWS Lambda events: it decodes
+// DynamoDB Streams and Kinesis events into libdy's ChangeEvent, and builds
+// the partial batch responses of a libdy.BatchResult, so only the
+// applications importing it depend on aws-lambda-go.
+package libdylambda
+
+import (
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/flowerinthenight/libdy"
+)
+
+// DecodeEvent decodes the records of a DynamoDB Streams event received by a
+// Lambda function, in order, so stream-processing Lambdas share the
+// ChangeEvent model with Streams API readers:
+//
+//	func handle(ctx context.Context, ev events.DynamoDBEvent) error {
+//		changes, err := libdylambda.DecodeEvent[Order](ev)
+//		...
+//	}
+func DecodeEvent[T any](ev events.DynamoDBEvent) ([]libdy.ChangeEvent[T], error) {
+	ret := make([]libdy.ChangeEvent[T], len(ev.Records))
+	for i, r := range ev.Records {
+		var err error
+		if ret[i], err = DecodeRecord[T](r); err != nil {
+			return nil, err
+		}
+	}
+
+	return ret, nil
+}
+
+// DecodeRecord decodes one record of a Lambda DynamoDB Streams event, as
+// libdy.DecodeStreamRecord does.
+func DecodeRecord[T any](r events.DynamoDBEventRecord) (libdy.ChangeEvent[T], error) {
+	rec := &dynamodbstreams.Record{
+		EventID:   aws.String(r.EventID),
+		EventName: aws.String(r.EventName),
+		Dynamodb: &dynamodbstreams.StreamRecord{
+			Keys:           item(r.Change.Keys),
+			OldImage:       item(r.Change.OldImage),
+			NewImage:       item(r.Change.NewImage),
+			SequenceNumber: aws.String(r.Change.SequenceNumber),
+		},
+	}
+
+	if t := r.Change.ApproximateCreationDateTime.Time; !t.IsZero() {
+		rec.Dynamodb.ApproximateCreationDateTime = aws.Time(t)
+	}
+
+	if id := r.UserIdentity; id != nil {
+		rec.UserIdentity = &dynamodbstreams.Identity{PrincipalId: aws.String(id.PrincipalID), Type: aws.String(id.Type)}
+	}
+
+	return libdy.DecodeStreamRecord[T](rec)
+}
+
+// DecodeKinesisEvent decodes the records of a Kinesis event received by a
+// Lambda function, in order, as libdy.DecodeKinesisRecord does.
+func DecodeKinesisEvent[T any](ev events.KinesisEvent) ([]libdy.ChangeEvent[T], error) {
+	ret := make([]libdy.ChangeEvent[T], len(ev.Records))
+	for i, r := range ev.Records {
+		var err error
+		rec := &kinesis.Record{Data: r.Kinesis.Data, SequenceNumber: aws.String(r.Kinesis.SequenceNumber)}
+		if ret[i], err = libdy.DecodeKinesisRecord[T](rec); err != nil {
+			return nil, err
+		}
+	}
+
+	return ret, nil
+}
+
+func item(m map[string]events.DynamoDBAttributeValue) map[string]*dynamodb.AttributeValue {
+	if m == nil {
+		return nil
+	}
+
+	ret := make(map[string]*dynamodb.AttributeValue, len(m))
+	for k, v := range m {
+		ret[k] = value(v)
+	}
+
+	return ret
+}
+
+// value converts a Lambda event attribute value to the SDK's.
+func value(v events.DynamoDBAttributeValue) *dynamodb.AttributeValue {
+	switch v.DataType() {
+	case events.DataTypeString:
+		return &dynamodb.AttributeValue{S: aws.String(v.String())}
+	case events.DataTypeNumber:
+		return &dynamodb.AttributeValue{N: aws.String(v.Number())}
+	case events.DataTypeBinary:
+		return &dynamodb.AttributeValue{B: v.Binary()}
+	case events.DataTypeBoolean:
+		return &dynamodb.AttributeValue{BOOL: aws.Bool(v.Boolean())}
+	case events.DataTypeStringSet:
+		return &dynamodb.AttributeValue{SS: aws.StringSlice(v.StringSet())}
+	case events.DataTypeNumberSet:
+		return &dynamodb.AttributeValue{NS: aws.StringSlice(v.NumberSet())}
+	case events.DataTypeBinarySet:
+		return &dynamodb.AttributeValue{BS: v.BinarySet()}
+	case events.DataTypeList:
+		l := v.List()
+		ret := make([]*dynamodb.AttributeValue, len(l))
+		for i, e := range l {
+			ret[i] = value(e)
+		}
+
+		return &dynamodb.AttributeValue{L: ret}
+	case events.DataTypeMap:
+		return &dynamodb.AttributeValue{M: item(v.Map())}
+	}
+
+	return &dynamodb.AttributeValue{NULL: aws.Bool(true)}
+}
+
+// SQSResponse returns the partial batch response of an SQS-triggered Lambda
+// for the failed records of r (see libdy.Client.WriteRecords).
+func SQSResponse(r *libdy.BatchResult) events.SQSEventResponse {
+	ret := events.SQSEventResponse{BatchItemFailures: []events.SQSBatchItemFailure{}}
+	for _, id := range r.Failed {
+		ret.BatchItemFailures = append(ret.BatchItemFailures, events.SQSBatchItemFailure{ItemIdentifier: id})
+	}
+
+	return ret
+}
+
+// DynamoDBResponse returns the partial batch response of a Lambda triggered
+// by a DynamoDB stream for the failed records of r.
+func DynamoDBResponse(r *libdy.BatchResult) events.DynamoDBEventResponse {
+	ret := events.DynamoDBEventResponse{BatchItemFailures: []events.DynamoDBBatchItemFailure{}}
+	for _, id := range r.Failed {
+		ret.BatchItemFailures = append(ret.BatchItemFailures, events.DynamoDBBatchItemFailure{ItemIdentifier: id})
+	}
+
+	return ret
+}
+
+// KinesisResponse returns the partial batch response of a Lambda triggered
+// by a Kinesis stream for the failed records of r.
+func KinesisResponse(r *libdy.BatchResult) events.KinesisEventResponse {
+	ret := events.KinesisEventResponse{BatchItemFailures: []events.KinesisBatchItemFailure{}}
+	for _, id := range r.Failed {
+		ret.BatchItemFailures = append(ret.BatchItemFailures, events.KinesisBatchItemFailure{ItemIdentifier: id})
+	}
+
+	return ret
+}
@@ -0,0 +1,93 @@
+package libdylambda
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/flowerinthenight/libdy"
+)
+
+type order struct {
+	ID    string   `json:"id"`
+	Total int      `json:"total"`
+	Tags  []string `json:"tags"`
+}
+
+func TestDecodeEvent(t *testing.T) {
+	var ev events.DynamoDBEvent
+	err := json.Unmarshal([]byte(`{"Records": [
+		{"eventID": "1", "eventName": "INSERT", "dynamodb": {
+			"Keys": {"id": {"S": "o1"}},
+			"NewImage": {"id": {"S": "o1"}, "total": {"N": "42"}, "tags": {"SS": ["a", "b"]}},
+			"SequenceNumber": "100"}},
+		{"eventID": "2", "eventName": "REMOVE", "dynamodb": {
+			"Keys": {"id": {"S": "o2"}},
+			"OldImage": {"id": {"S": "o2"}, "total": {"N": "7"}},
+			"SequenceNumber": "200"},
+		 "userIdentity": {"type": "Service", "principalId": "dynamodb.amazonaws.com"}}
+	]}`), &ev)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := DecodeEvent[order](ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changes) != 2 {
+		t.Fatalf("%d changes, want 2", len(changes))
+	}
+
+	got := fmt.Sprintf("%s %s %+v %s %v", changes[0].ID, changes[0].Type, *changes[0].New, changes[0].SequenceNumber, changes[0].TTL)
+	if want := "1 INSERT {ID:o1 Total:42 Tags:[a b]} 100 false"; got != want {
+		t.Errorf("insert = %s, want %s", got, want)
+	}
+
+	got = fmt.Sprintf("%s %s %+v %v %v", changes[1].ID, changes[1].Type, *changes[1].Old, changes[1].New, changes[1].TTL)
+	if want := "2 REMOVE {ID:o2 Total:7 Tags:[]} <nil> true"; got != want {
+		t.Errorf("remove = %s, want %s", got, want)
+	}
+}
+
+func TestDecodeKinesisEvent(t *testing.T) {
+	data := `{"eventID": "e1", "eventName": "MODIFY", "tableName": "orders", "dynamodb": {
+		"ApproximateCreationDateTime": 1700000000000,
+		"Keys": {"id": {"S": "o1"}},
+		"NewImage": {"id": {"S": "o1"}, "total": {"N": "43"}}}}`
+
+	ev := events.KinesisEvent{Records: []events.KinesisEventRecord{{Kinesis: events.KinesisRecord{Data: []byte(data), SequenceNumber: "9"}}}}
+	changes, err := DecodeKinesisEvent[order](ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changes) != 1 || changes[0].Table != "orders" || changes[0].SequenceNumber != "9" || changes[0].New.Total != 43 {
+		t.Errorf("changes %+v, want the MODIFY of orders o1", changes)
+	}
+}
+
+func TestResponses(t *testing.T) {
+	r := &libdy.BatchResult{}
+	r.Fail("m1", errors.New("bad"))
+	r.Fail("m3", errors.New("bad"))
+	r.Fail("m1", errors.New("again"))
+
+	b, err := json.Marshal([]interface{}{SQSResponse(r), DynamoDBResponse(r), KinesisResponse(r)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	one := `{"batchItemFailures":[{"itemIdentifier":"m1"},{"itemIdentifier":"m3"}]}`
+	if want := "[" + one + "," + one + "," + one + "]"; string(b) != want {
+		t.Errorf("responses %s, want %s", b, want)
+	}
+
+	if b, _ := json.Marshal(SQSResponse(&libdy.BatchResult{})); string(b) != `{"batchItemFailures":[]}` {
+		t.Errorf("response of no failures %s", b)
+	}
+}
@@ -0,0 +1,53 @@
+// Package libdyotel records OpenTelemetry spans of the DynamoDB requests of
+// libdy Clients, fed by their WithOnOperation hooks, so only the
+// applications importing it depend on OpenTelemetry.
+package libdyotel
+
+import (
+	"github.com/flowerinthenight/libdy"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/flowerinthenight/libdy"
+
+// WithTracerProvider records an OpenTelemetry span for every DynamoDB
+// request (see libdy.WithOnOperation for the operations covered), as a
+// child of the span in the call's context. Spans carry the table, the
+// operation, the page number (for Query and Scan), the number of attempts,
+// and the consumed capacity. Without this option, libdy creates no spans.
+//
+//	client := libdy.New(svc, libdyotel.WithTracerProvider(otel.GetTracerProvider()))
+func WithTracerProvider(tp trace.TracerProvider) libdy.Option {
+	tracer := tp.Tracer(tracerName)
+	return libdy.WithOnOperation(func(op libdy.Operation) { span(tracer, op) })
+}
+
+// span records op as a span of tracer.
+func span(tracer trace.Tracer, op libdy.Operation) {
+	attrs := []attribute.KeyValue{
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", op.Name),
+		attribute.StringSlice("aws.dynamodb.table_names", []string{op.Table}),
+		attribute.Int("libdy.attempts", op.Attempts),
+		attribute.Float64("aws.dynamodb.consumed_capacity", op.ConsumedCapacity),
+	}
+
+	if op.Page > 0 {
+		attrs = append(attrs, attribute.Int("libdy.page", op.Page))
+	}
+
+	_, s := tracer.Start(op.Context(), "DynamoDB."+op.Name,
+		trace.WithTimestamp(op.Start),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+
+	if op.Err != nil {
+		s.RecordError(op.Err)
+		s.SetStatus(codes.Error, op.Err.Error())
+	}
+
+	s.End(trace.WithTimestamp(op.Start.Add(op.Duration)))
+}
@@ -0,0 +1,71 @@
+package libdyotel
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/flowerinthenight/libdy"
+	"github.com/flowerinthenight/libdy/libdytest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// recorder is a TracerProvider recording the name, parent, and attributes
+// of the spans started.
+type recorder struct {
+	noop.TracerProvider
+	spans []string
+}
+
+func (r *recorder) Tracer(string, ...trace.TracerOption) trace.Tracer { return tracer{r: r} }
+
+type tracer struct {
+	noop.Tracer
+	r *recorder
+}
+
+func (t tracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	s := name + " " + trace.SpanContextFromContext(ctx).TraceID().String()
+	cfg := trace.NewSpanStartConfig(opts...)
+	for _, a := range cfg.Attributes() {
+		if a.Key == "libdy.page" {
+			s += fmt.Sprint(" page ", a.Value.AsInt64())
+		}
+	}
+
+	t.r.spans = append(t.r.spans, s)
+	return t.Tracer.Start(ctx, name, opts...)
+}
+
+func TestWithTracerProvider(t *testing.T) {
+	f := libdytest.SetupFake(t, libdy.TableDef{Name: "t", PK: "pk", SK: "sk"})
+	tp := &recorder{}
+	c := libdy.New(f, libdy.WithTable("t"), WithTracerProvider(tp))
+	parent := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{1}})
+	ctx := trace.ContextWithSpanContext(context.Background(), parent)
+	for _, sk := range []string{"1", "2"} {
+		item := map[string]*dynamodb.AttributeValue{"pk": {S: aws.String("a")}, "sk": {S: aws.String(sk)}}
+		if err := c.PutItem(ctx, item); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := c.Query(ctx, "pk:a", "", libdy.WithPageSize(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	id := parent.TraceID().String()
+	want := fmt.Sprint([]string{
+		"DynamoDB.PutItem " + id,
+		"DynamoDB.PutItem " + id,
+		"DynamoDB.Query " + id + " page 1",
+		"DynamoDB.Query " + id + " page 2",
+	})
+
+	if got := fmt.Sprint(tp.spans); got != want {
+		t.Errorf("spans %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,112 @@
+// Package libdyprom exports Prometheus metrics of libdy Clients, fed by
+// their WithOnOperation and WithOnRetry hooks, so only the applications
+// importing it depend on the Prometheus client.
+package libdyprom
+
+import (
+	"github.com/flowerinthenight/libdy"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector exports per-table, per-operation metrics of the Clients
+// reporting to it:
+//
+//	libdy_requests_total{table,op,status}            requests, status "ok" or "error"
+//	libdy_retries_total{table,op}                    retried attempts
+//	libdy_throttles_total{table,op}                  throttled attempts
+//	libdy_request_duration_seconds{table,op}         request latency, with retries
+//	libdy_items_total{table,op}                      items returned by reads
+//	libdy_consumed_capacity_units_total{table,op}    capacity units consumed
+//
+// Register it with the application's registry, and set its hooks:
+//
+//	prom := libdyprom.NewCollector()
+//	prometheus.MustRegister(prom)
+//	client := libdy.New(svc, libdy.WithOnOperation(prom.Operation), libdy.WithOnRetry(prom.Retry))
+type Collector struct {
+	requests  *prometheus.CounterVec
+	retries   *prometheus.CounterVec
+	throttles *prometheus.CounterVec
+	latency   *prometheus.HistogramVec
+	items     *prometheus.CounterVec
+	capacity  *prometheus.CounterVec
+}
+
+// NewCollector returns a Collector with no metrics yet.
+func NewCollector() *Collector {
+	labels := []string{"table", "op"}
+	return &Collector{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "libdy_requests_total",
+			Help: "DynamoDB requests, by final status.",
+		}, append(labels, "status")),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "libdy_retries_total",
+			Help: "Retried DynamoDB request attempts.",
+		}, labels),
+		throttles: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "libdy_throttles_total",
+			Help: "Throttled DynamoDB request attempts.",
+		}, labels),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "libdy_request_duration_seconds",
+			Help:    "DynamoDB request latency, including retries.",
+			Buckets: prometheus.ExponentialBuckets(0.002, 2, 14), // 2ms to ~16s
+		}, labels),
+		items: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "libdy_items_total",
+			Help: "Items returned by DynamoDB reads.",
+		}, labels),
+		capacity: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "libdy_consumed_capacity_units_total",
+			Help: "Capacity units consumed by DynamoDB requests.",
+		}, labels),
+	}
+}
+
+func (p *Collector) Describe(ch chan<- *prometheus.Desc) {
+	p.requests.Describe(ch)
+	p.retries.Describe(ch)
+	p.throttles.Describe(ch)
+	p.latency.Describe(ch)
+	p.items.Describe(ch)
+	p.capacity.Describe(ch)
+}
+
+func (p *Collector) Collect(ch chan<- prometheus.Metric) {
+	p.requests.Collect(ch)
+	p.retries.Collect(ch)
+	p.throttles.Collect(ch)
+	p.latency.Collect(ch)
+	p.items.Collect(ch)
+	p.capacity.Collect(ch)
+}
+
+// Operation records op, as a WithOnOperation hook.
+func (p *Collector) Operation(op libdy.Operation) {
+	status := "ok"
+	if op.Err != nil {
+		status = "error"
+		if libdy.IsThrottle(op.Err) {
+			p.throttles.WithLabelValues(op.Table, op.Name).Inc()
+		}
+	}
+
+	p.requests.WithLabelValues(op.Table, op.Name, status).Inc()
+	p.latency.WithLabelValues(op.Table, op.Name).Observe(op.Duration.Seconds())
+	if op.Items > 0 {
+		p.items.WithLabelValues(op.Table, op.Name).Add(float64(op.Items))
+	}
+
+	if op.ConsumedCapacity > 0 {
+		p.capacity.WithLabelValues(op.Table, op.Name).Add(op.ConsumedCapacity)
+	}
+}
+
+// Retry records r, as a WithOnRetry hook.
+func (p *Collector) Retry(r libdy.RetryAttempt) {
+	p.retries.WithLabelValues(r.Table, r.Name).Inc()
+	if libdy.IsThrottle(r.Err) {
+		p.throttles.WithLabelValues(r.Table, r.Name).Inc()
+	}
+}
@@ -0,0 +1,72 @@
+package libdyprom
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/flowerinthenight/libdy"
+	"github.com/flowerinthenight/libdy/libdytest"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCollector(t *testing.T) {
+	ctx := context.Background()
+	f := libdytest.SetupFake(t, libdy.TableDef{Name: "t", PK: "id"})
+	prom := NewCollector()
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(prom)
+
+	var ops int
+	c := libdy.New(f, libdy.WithTable("t"),
+		libdy.WithOnOperation(prom.Operation),
+		libdy.WithOnRetry(prom.Retry),
+		libdy.WithOnOperation(func(libdy.Operation) { ops++ }), // alongside
+	)
+
+	if err := c.PutItem(ctx, map[string]*dynamodb.AttributeValue{"id": {S: aws.String("a")}}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"id:a", "id:b"} {
+		c.GetItem(ctx, id, "")
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]float64{}
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			name := mf.GetName()
+			for _, l := range m.GetLabel() {
+				name += "," + l.GetValue()
+			}
+
+			switch {
+			case m.Counter != nil:
+				got[name] = m.GetCounter().GetValue()
+			case m.Histogram != nil:
+				got[name] = float64(m.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+
+	for name, want := range map[string]float64{
+		"libdy_requests_total,PutItem,ok,t":        1,
+		"libdy_requests_total,GetItem,ok,t":        2,
+		"libdy_items_total,GetItem,t":              1,
+		"libdy_request_duration_seconds,GetItem,t": 2,
+	} {
+		if got[name] != want {
+			t.Errorf("%s = %v, want %v (all: %v)", name, got[name], want, got)
+		}
+	}
+
+	if ops != 3 {
+		t.Errorf("%d operations seen by the other hook, want 3", ops)
+	}
+}
@@ -0,0 +1,933 @@
+package libdytest
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+type item = map[string]*dynamodb.AttributeValue
+
+// exprEnv resolves the placeholders of an expression.
+type exprEnv struct {
+	names  map[string]*string
+	values map[string]*dynamodb.AttributeValue
+}
+
+// lexer splits an expression into tokens: names, placeholders, numbers, and
+// operators.
+type lexer struct {
+	toks []string
+	pos  int
+}
+
+func lex(s string) (*lexer, error) {
+	var toks []string
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '#' || c == ':' || c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c):
+			j := i + 1
+			for j < len(s) && (s[j] == '_' || unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+
+			toks = append(toks, s[i:j])
+			i = j
+		case strings.HasPrefix(s[i:], "<>"), strings.HasPrefix(s[i:], "<="), strings.HasPrefix(s[i:], ">="):
+			toks = append(toks, s[i:i+2])
+			i += 2
+		case strings.ContainsRune("()[],.=<>+-", c):
+			toks = append(toks, s[i:i+1])
+			i++
+		default:
+			return nil, fmt.Errorf("invalid character %q in %q", c, s)
+		}
+	}
+
+	return &lexer{toks: toks}, nil
+}
+
+func (l *lexer) peek() string {
+	if l.pos < len(l.toks) {
+		return l.toks[l.pos]
+	}
+
+	return ""
+}
+
+// keyword reports whether the next token is kw, case-insensitively, and
+// consumes it if so.
+func (l *lexer) keyword(kw string) bool {
+	if strings.EqualFold(l.peek(), kw) {
+		l.pos++
+		return true
+	}
+
+	return false
+}
+
+func (l *lexer) next() string {
+	t := l.peek()
+	l.pos++
+	return t
+}
+
+func (l *lexer) expect(t string) error {
+	if got := l.next(); got != t {
+		return fmt.Errorf("expected %q, got %q", t, got)
+	}
+
+	return nil
+}
+
+// pathElem is a map key, or a list index if name is "".
+type pathElem struct {
+	name  string
+	index int
+}
+
+type path []pathElem
+
+func (l *lexer) path(env exprEnv) (path, error) {
+	var p path
+	for {
+		t := l.next()
+		name := t
+		if strings.HasPrefix(t, "#") {
+			n, ok := env.names[t]
+			if !ok {
+				return nil, fmt.Errorf("undefined name %s", t)
+			}
+
+			name = aws.StringValue(n)
+		} else if t == "" || strings.HasPrefix(t, ":") || !isIdent(t) {
+			return nil, fmt.Errorf("invalid path element %q", t)
+		}
+
+		p = append(p, pathElem{name: name})
+		for l.peek() == "[" {
+			l.next()
+			i, err := strconv.Atoi(l.next())
+			if err != nil {
+				return nil, fmt.Errorf("invalid list index: %w", err)
+			}
+
+			if err := l.expect("]"); err != nil {
+				return nil, err
+			}
+
+			p = append(p, pathElem{index: i})
+		}
+
+		if l.peek() != "." {
+			return p, nil
+		}
+
+		l.next()
+	}
+}
+
+func isIdent(t string) bool {
+	r := rune(t[0])
+	return r == '_' || unicode.IsLetter(r)
+}
+
+// get returns the value at p in it, or nil.
+func (p path) get(it item) *dynamodb.AttributeValue {
+	v := &dynamodb.AttributeValue{M: it}
+	for _, e := range p {
+		switch {
+		case v == nil:
+			return nil
+		case e.name != "":
+			v = v.M[e.name]
+		case e.index < len(v.L):
+			v = v.L[e.index]
+		default:
+			return nil
+		}
+	}
+
+	return v
+}
+
+// set sets the value at p in it to v, or removes it if v is nil.
+func (p path) set(it item, v *dynamodb.AttributeValue) error {
+	v = cloneValue(v) // not to share the values of the request
+	parent := p[:len(p)-1].get(it)
+	if len(p) == 1 {
+		parent = &dynamodb.AttributeValue{M: it}
+	}
+
+	last := p[len(p)-1]
+	switch {
+	case parent == nil:
+		return fmt.Errorf("document path not found")
+	case last.name != "" && parent.M != nil:
+		if v == nil {
+			delete(parent.M, last.name)
+		} else {
+			parent.M[last.name] = v
+		}
+	case last.name == "" && parent.L != nil:
+		switch {
+		case v == nil && last.index < len(parent.L):
+			parent.L = append(parent.L[:last.index], parent.L[last.index+1:]...)
+		case v == nil:
+		case last.index < len(parent.L):
+			parent.L[last.index] = v
+		default:
+			parent.L = append(parent.L, v)
+		}
+	default:
+		return fmt.Errorf("document path does not match the item")
+	}
+
+	return nil
+}
+
+// operand is a path, a value, or size(path).
+type operand struct {
+	path  path
+	value *dynamodb.AttributeValue
+	size  bool
+}
+
+func (l *lexer) operand(env exprEnv) (operand, error) {
+	t := l.peek()
+	switch {
+	case strings.HasPrefix(t, ":"):
+		l.next()
+		v, ok := env.values[t]
+		if !ok {
+			return operand{}, fmt.Errorf("undefined value %s", t)
+		}
+
+		return operand{value: v}, nil
+	case strings.EqualFold(t, "size"):
+		l.next()
+		if err := l.expect("("); err != nil {
+			return operand{}, err
+		}
+
+		p, err := l.path(env)
+		if err != nil {
+			return operand{}, err
+		}
+
+		return operand{path: p, size: true}, l.expect(")")
+	}
+
+	p, err := l.path(env)
+	return operand{path: p}, err
+}
+
+func (o operand) eval(it item) *dynamodb.AttributeValue {
+	if o.value != nil {
+		return o.value
+	}
+
+	v := o.path.get(it)
+	if !o.size || v == nil {
+		return v
+	}
+
+	n := 0
+	switch {
+	case v.S != nil:
+		n = len(*v.S)
+	case v.B != nil:
+		n = len(v.B)
+	case v.L != nil:
+		n = len(v.L)
+	case v.M != nil:
+		n = len(v.M)
+	case v.SS != nil:
+		n = len(v.SS)
+	case v.NS != nil:
+		n = len(v.NS)
+	case v.BS != nil:
+		n = len(v.BS)
+	}
+
+	return &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(n))}
+}
+
+// cond is a parsed condition.
+type cond func(it item) bool
+
+// parseCond parses the condition expression s; "" always holds.
+func parseCond(s string, env exprEnv) (cond, error) {
+	if s == "" {
+		return func(item) bool { return true }, nil
+	}
+
+	l, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := l.or(env)
+	if err == nil && l.pos < len(l.toks) {
+		err = fmt.Errorf("unexpected %q", l.peek())
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", s, err)
+	}
+
+	return c, nil
+}
+
+func (l *lexer) or(env exprEnv) (cond, error) {
+	a, err := l.and(env)
+	for err == nil && l.keyword("OR") {
+		var b cond
+		if b, err = l.and(env); err == nil {
+			a = func(a, b cond) cond { return func(it item) bool { return a(it) || b(it) } }(a, b)
+		}
+	}
+
+	return a, err
+}
+
+func (l *lexer) and(env exprEnv) (cond, error) {
+	a, err := l.not(env)
+	for err == nil && l.keyword("AND") {
+		var b cond
+		if b, err = l.not(env); err == nil {
+			a = func(a, b cond) cond { return func(it item) bool { return a(it) && b(it) } }(a, b)
+		}
+	}
+
+	return a, err
+}
+
+func (l *lexer) not(env exprEnv) (cond, error) {
+	if l.keyword("NOT") {
+		c, err := l.not(env)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(it item) bool { return !c(it) }, nil
+	}
+
+	return l.primary(env)
+}
+
+func (l *lexer) primary(env exprEnv) (cond, error) {
+	if l.peek() == "(" {
+		l.next()
+		c, err := l.or(env)
+		if err != nil {
+			return nil, err
+		}
+
+		return c, l.expect(")")
+	}
+
+	switch fn := strings.ToLower(l.peek()); fn {
+	case "attribute_exists", "attribute_not_exists", "attribute_type", "begins_with", "contains":
+		l.next()
+		return l.function(fn, env)
+	}
+
+	a, err := l.operand(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case l.keyword("BETWEEN"):
+		lo, err := l.operand(env)
+		if err != nil {
+			return nil, err
+		}
+
+		if !l.keyword("AND") {
+			return nil, fmt.Errorf("expected AND in BETWEEN")
+		}
+
+		hi, err := l.operand(env)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(it item) bool {
+			c1, ok1 := compare(a.eval(it), lo.eval(it))
+			c2, ok2 := compare(a.eval(it), hi.eval(it))
+			return ok1 && ok2 && c1 >= 0 && c2 <= 0
+		}, nil
+	case l.keyword("IN"):
+		if err := l.expect("("); err != nil {
+			return nil, err
+		}
+
+		var set []operand
+		for {
+			o, err := l.operand(env)
+			if err != nil {
+				return nil, err
+			}
+
+			set = append(set, o)
+			if l.peek() != "," {
+				break
+			}
+
+			l.next()
+		}
+
+		return func(it item) bool {
+			for _, o := range set {
+				if equal(a.eval(it), o.eval(it)) {
+					return true
+				}
+			}
+
+			return false
+		}, l.expect(")")
+	}
+
+	op := l.next()
+	b, err := l.operand(env)
+	if err != nil {
+		return nil, err
+	}
+
+	return comparison(op, a, b)
+}
+
+func comparison(op string, a, b operand) (cond, error) {
+	switch op {
+	case "=":
+		return func(it item) bool { return equal(a.eval(it), b.eval(it)) }, nil
+	case "<>":
+		return func(it item) bool {
+			x, y := a.eval(it), b.eval(it)
+			return x != nil && y != nil && !equal(x, y)
+		}, nil
+	}
+
+	want := map[string]func(int) bool{
+		"<":  func(c int) bool { return c < 0 },
+		"<=": func(c int) bool { return c <= 0 },
+		">":  func(c int) bool { return c > 0 },
+		">=": func(c int) bool { return c >= 0 },
+	}[op]
+
+	if want == nil {
+		return nil, fmt.Errorf("invalid comparator %q", op)
+	}
+
+	return func(it item) bool {
+		c, ok := compare(a.eval(it), b.eval(it))
+		return ok && want(c)
+	}, nil
+}
+
+func (l *lexer) function(fn string, env exprEnv) (cond, error) {
+	if err := l.expect("("); err != nil {
+		return nil, err
+	}
+
+	p, err := l.path(env)
+	if err != nil {
+		return nil, err
+	}
+
+	var arg operand
+	if fn != "attribute_exists" && fn != "attribute_not_exists" {
+		if err := l.expect(","); err != nil {
+			return nil, err
+		}
+
+		if arg, err = l.operand(env); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := l.expect(")"); err != nil {
+		return nil, err
+	}
+
+	switch fn {
+	case "attribute_exists":
+		return func(it item) bool { return p.get(it) != nil }, nil
+	case "attribute_not_exists":
+		return func(it item) bool { return p.get(it) == nil }, nil
+	case "attribute_type":
+		return func(it item) bool {
+			v, t := p.get(it), arg.eval(it)
+			return v != nil && t != nil && typeOf(v) == aws.StringValue(t.S)
+		}, nil
+	case "begins_with":
+		return func(it item) bool {
+			v, pre := p.get(it), arg.eval(it)
+			switch {
+			case v == nil || pre == nil:
+				return false
+			case v.S != nil && pre.S != nil:
+				return strings.HasPrefix(*v.S, *pre.S)
+			case v.B != nil && pre.B != nil:
+				return bytes.HasPrefix(v.B, pre.B)
+			}
+
+			return false
+		}, nil
+	}
+
+	return func(it item) bool { // contains
+		v, x := p.get(it), arg.eval(it)
+		switch {
+		case v == nil || x == nil:
+			return false
+		case v.S != nil && x.S != nil:
+			return strings.Contains(*v.S, *x.S)
+		case v.B != nil && x.B != nil:
+			return bytes.Contains(v.B, x.B)
+		}
+
+		for _, e := range elements(v) {
+			if equal(e, x) {
+				return true
+			}
+		}
+
+		return false
+	}, nil
+}
+
+// elements returns the elements of a set or list.
+func elements(v *dynamodb.AttributeValue) []*dynamodb.AttributeValue {
+	var ret []*dynamodb.AttributeValue
+	for _, s := range v.SS {
+		ret = append(ret, &dynamodb.AttributeValue{S: s})
+	}
+
+	for _, n := range v.NS {
+		ret = append(ret, &dynamodb.AttributeValue{N: n})
+	}
+
+	for _, b := range v.BS {
+		ret = append(ret, &dynamodb.AttributeValue{B: b})
+	}
+
+	return append(ret, v.L...)
+}
+
+func typeOf(v *dynamodb.AttributeValue) string {
+	switch {
+	case v.S != nil:
+		return "S"
+	case v.N != nil:
+		return "N"
+	case v.B != nil:
+		return "B"
+	case v.BOOL != nil:
+		return "BOOL"
+	case v.NULL != nil:
+		return "NULL"
+	case v.M != nil:
+		return "M"
+	case v.L != nil:
+		return "L"
+	case v.SS != nil:
+		return "SS"
+	case v.NS != nil:
+		return "NS"
+	case v.BS != nil:
+		return "BS"
+	}
+
+	return ""
+}
+
+func number(s *string) *big.Rat {
+	r, ok := new(big.Rat).SetString(aws.StringValue(s))
+	if !ok {
+		return new(big.Rat)
+	}
+
+	return r
+}
+
+// compare orders scalars of the same type: numbers, strings, or binary.
+func compare(a, b *dynamodb.AttributeValue) (int, bool) {
+	switch {
+	case a == nil || b == nil:
+		return 0, false
+	case a.N != nil && b.N != nil:
+		return number(a.N).Cmp(number(b.N)), true
+	case a.S != nil && b.S != nil:
+		return strings.Compare(*a.S, *b.S), true
+	case a.B != nil && b.B != nil:
+		return bytes.Compare(a.B, b.B), true
+	}
+
+	return 0, false
+}
+
+func equal(a, b *dynamodb.AttributeValue) bool {
+	if c, ok := compare(a, b); ok {
+		return c == 0
+	}
+
+	return a != nil && b != nil && reflect.DeepEqual(a, b)
+}
+
+// update is a parsed update expression.
+type update func(it item) error
+
+func parseUpdate(s string, env exprEnv) (update, error) {
+	l, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var actions []update
+	for l.pos < len(l.toks) {
+		clause := strings.ToUpper(l.next())
+		for {
+			p, err := l.path(env)
+			if err != nil {
+				return nil, fmt.Errorf("invalid update %q: %w", s, err)
+			}
+
+			var a update
+			switch clause {
+			case "SET":
+				a, err = l.set(p, env)
+			case "REMOVE":
+				a = func(it item) error { return p.set(it, nil) }
+			case "ADD", "DELETE":
+				var o operand
+				if o, err = l.operand(env); err == nil {
+					a = addDelete(clause == "ADD", p, o)
+				}
+			default:
+				err = fmt.Errorf("invalid clause %q", clause)
+			}
+
+			if err != nil {
+				return nil, fmt.Errorf("invalid update %q: %w", s, err)
+			}
+
+			actions = append(actions, a)
+			if l.peek() != "," {
+				break
+			}
+
+			l.next()
+		}
+	}
+
+	return func(it item) error {
+		for _, a := range actions {
+			if err := a(it); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, nil
+}
+
+// value is the right-hand side of a SET action.
+type value func(it item) (*dynamodb.AttributeValue, error)
+
+func (l *lexer) set(p path, env exprEnv) (update, error) {
+	if err := l.expect("="); err != nil {
+		return nil, err
+	}
+
+	a, err := l.setOperand(env)
+	if err != nil {
+		return nil, err
+	}
+
+	v := a
+	if op := l.peek(); op == "+" || op == "-" {
+		l.next()
+		b, err := l.setOperand(env)
+		if err != nil {
+			return nil, err
+		}
+
+		v = func(it item) (*dynamodb.AttributeValue, error) {
+			x, err := a(it)
+			if err != nil {
+				return nil, err
+			}
+
+			y, err := b(it)
+			if err != nil {
+				return nil, err
+			}
+
+			if x == nil || y == nil || x.N == nil || y.N == nil {
+				return nil, fmt.Errorf("an operand of %s is not a number", op)
+			}
+
+			r := new(big.Rat)
+			if op == "+" {
+				r.Add(number(x.N), number(y.N))
+			} else {
+				r.Sub(number(x.N), number(y.N))
+			}
+
+			return &dynamodb.AttributeValue{N: aws.String(formatNumber(r))}, nil
+		}
+	}
+
+	return func(it item) error {
+		x, err := v(it)
+		if err != nil {
+			return err
+		}
+
+		if x == nil {
+			return fmt.Errorf("the value of a SET is missing")
+		}
+
+		return p.set(it, x)
+	}, nil
+}
+
+func formatNumber(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+
+	return strings.TrimRight(r.FloatString(38), "0")
+}
+
+func (l *lexer) setOperand(env exprEnv) (value, error) {
+	switch fn := strings.ToLower(l.peek()); fn {
+	case "if_not_exists", "list_append":
+		l.next()
+		if err := l.expect("("); err != nil {
+			return nil, err
+		}
+
+		a, err := l.setOperand(env)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := l.expect(","); err != nil {
+			return nil, err
+		}
+
+		b, err := l.setOperand(env)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := l.expect(")"); err != nil {
+			return nil, err
+		}
+
+		if fn == "if_not_exists" {
+			return func(it item) (*dynamodb.AttributeValue, error) {
+				if v, err := a(it); err != nil || v != nil {
+					return v, err
+				}
+
+				return b(it)
+			}, nil
+		}
+
+		return func(it item) (*dynamodb.AttributeValue, error) {
+			x, err := a(it)
+			if err != nil {
+				return nil, err
+			}
+
+			y, err := b(it)
+			if err != nil {
+				return nil, err
+			}
+
+			if x == nil || y == nil || x.L == nil || y.L == nil {
+				return nil, fmt.Errorf("an operand of list_append is not a list")
+			}
+
+			return &dynamodb.AttributeValue{L: append(append([]*dynamodb.AttributeValue{}, x.L...), y.L...)}, nil
+		}, nil
+	}
+
+	o, err := l.operand(env)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(it item) (*dynamodb.AttributeValue, error) { return o.eval(it), nil }, nil
+}
+
+// addDelete is an ADD (numbers and sets) or DELETE (sets) action.
+func addDelete(add bool, p path, o operand) update {
+	return func(it item) error {
+		cur, v := p.get(it), o.eval(it)
+		switch {
+		case v == nil:
+			return fmt.Errorf("the value of an ADD or DELETE is missing")
+		case add && v.N != nil:
+			n := new(big.Rat)
+			if cur != nil {
+				if cur.N == nil {
+					return fmt.Errorf("ADD to a non-number")
+				}
+
+				n = number(cur.N)
+			}
+
+			return p.set(it, &dynamodb.AttributeValue{N: aws.String(formatNumber(n.Add(n, number(v.N))))})
+		case v.SS == nil && v.NS == nil && v.BS == nil:
+			return fmt.Errorf("ADD and DELETE take a number or a set")
+		case cur == nil && !add:
+			return nil
+		case cur == nil:
+			return p.set(it, v)
+		case typeOf(cur) != typeOf(v):
+			return fmt.Errorf("ADD or DELETE of a set of another type")
+		}
+
+		var ret []*dynamodb.AttributeValue
+		for _, e := range elements(cur) {
+			in := false
+			for _, x := range elements(v) {
+				in = in || equal(e, x)
+			}
+
+			if add || !in {
+				ret = append(ret, e)
+			}
+		}
+
+		if add {
+			for _, x := range elements(v) {
+				in := false
+				for _, e := range elements(cur) {
+					in = in || equal(e, x)
+				}
+
+				if !in {
+					ret = append(ret, x)
+				}
+			}
+		}
+
+		if len(ret) == 0 {
+			return p.set(it, nil) // sets can't be empty
+		}
+
+		s := &dynamodb.AttributeValue{}
+		for _, e := range ret {
+			switch {
+			case e.S != nil:
+				s.SS = append(s.SS, e.S)
+			case e.N != nil:
+				s.NS = append(s.NS, e.N)
+			default:
+				s.BS = append(s.BS, e.B)
+			}
+		}
+
+		return p.set(it, s)
+	}
+}
+
+// project returns the attributes of it at the paths of the projection
+// expression s, or a copy of it if s is "".
+func project(it item, s string, env exprEnv) (item, error) {
+	if s == "" {
+		return clone(it), nil
+	}
+
+	l, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := item{}
+	for {
+		p, err := l.path(env)
+		if err != nil {
+			return nil, fmt.Errorf("invalid projection %q: %w", s, err)
+		}
+
+		// Top-level attributes, with nested paths kept whole.
+		if v := it[p[0].name]; v != nil && p[0].name != "" {
+			ret[p[0].name] = clone(item{"": v})[""]
+		}
+
+		if l.peek() != "," {
+			break
+		}
+
+		l.next()
+	}
+
+	return ret, nil
+}
+
+// clone deep copies it.
+func clone(it item) item {
+	if it == nil {
+		return nil
+	}
+
+	ret := make(item, len(it))
+	for k, v := range it {
+		ret[k] = cloneValue(v)
+	}
+
+	return ret
+}
+
+func cloneValue(v *dynamodb.AttributeValue) *dynamodb.AttributeValue {
+	if v == nil {
+		return nil
+	}
+
+	c := *v
+	if v.M != nil {
+		c.M = clone(v.M)
+	}
+
+	if v.L != nil {
+		c.L = make([]*dynamodb.AttributeValue, len(v.L))
+		for i, e := range v.L {
+			c.L[i] = cloneValue(e)
+		}
+	}
+
+	c.SS = append([]*string(nil), v.SS...)
+	c.NS = append([]*string(nil), v.NS...)
+	c.BS = append([][]byte(nil), v.BS...)
+	if v.SS == nil {
+		c.SS = nil
+	}
+
+	if v.NS == nil {
+		c.NS = nil
+	}
+
+	if v.BS == nil {
+		c.BS = nil
+	}
+
+	return &c
+}
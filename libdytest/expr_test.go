@@ -0,0 +1,183 @@
+package libdytest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func s(v string) *dynamodb.AttributeValue { return &dynamodb.AttributeValue{S: aws.String(v)} }
+func n(v string) *dynamodb.AttributeValue { return &dynamodb.AttributeValue{N: aws.String(v)} }
+
+func testItem() item {
+	return item{
+		"id":    s("u1"),
+		"name":  s("alice"),
+		"age":   n("30"),
+		"tags":  {SS: aws.StringSlice([]string{"a", "b"})},
+		"addr":  {M: item{"city": s("Tokyo")}},
+		"list":  {L: []*dynamodb.AttributeValue{n("1"), n("2")}},
+		"score": n("1.5"),
+	}
+}
+
+var testEnv = exprEnv{
+	names: map[string]*string{"#n": aws.String("name"), "#s": aws.String("status")},
+	values: map[string]*dynamodb.AttributeValue{
+		":alice": s("alice"),
+		":al":    s("al"),
+		":bob":   s("bob"),
+		":20":    n("20"),
+		":30":    n("30"),
+		":40":    n("40"),
+		":1":     n("1"),
+		":a":     s("a"),
+		":S":     s("S"),
+		":tokyo": s("Tokyo"),
+		":tags":  {SS: aws.StringSlice([]string{"b", "c"})},
+		":l":     {L: []*dynamodb.AttributeValue{n("3")}},
+	},
+}
+
+func TestCond(t *testing.T) {
+	for _, tc := range []struct {
+		expr string
+		want bool
+	}{
+		{"", true},
+		{"#n = :alice", true},
+		{"#n <> :alice", false},
+		{"#n <> :bob", true},
+		{"age BETWEEN :20 AND :40", true},
+		{"age BETWEEN :30 AND :30", true},
+		{"age BETWEEN :20 AND :20", false},
+		{"#n BETWEEN :20 AND :40", false}, // not comparable
+		{"age < :40 AND age >= :30", true},
+		{"age > :30 OR #n = :alice", true},
+		{"NOT age > :20", false},
+		{"(age > :40 OR age < :20) AND #n = :alice", false},
+		{"begins_with(#n, :al)", true},
+		{"begins_with(#n, :bob)", false},
+		{"begins_with(missing, :al)", false},
+		{"attribute_exists(age)", true},
+		{"attribute_exists(missing)", false},
+		{"attribute_not_exists(missing)", true},
+		{"attribute_not_exists(#s)", true},
+		{"attribute_not_exists(age)", false},
+		{"attribute_type(#n, :S)", true},
+		{"attribute_type(age, :S)", false},
+		{"contains(tags, :a)", true},
+		{"contains(#n, :al)", true},
+		{"contains(tags, :bob)", false},
+		{"addr.city = :tokyo", true},
+		{"list[1] = :1", false},
+		{"list[0] = :1", true},
+		{"#n IN (:bob, :alice)", true},
+		{"#n IN (:bob)", false},
+		{"size(tags) = :1", false},
+		{"size(list) > :1", true},
+	} {
+		c, err := parseCond(tc.expr, testEnv)
+		if err != nil {
+			t.Errorf("parseCond(%q): %v", tc.expr, err)
+			continue
+		}
+
+		if got := c(testItem()); got != tc.want {
+			t.Errorf("%q = %v, want %v", tc.expr, got, tc.want)
+		}
+	}
+}
+
+func TestCondInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"age",
+		"age ==",
+		"age BETWEEN :20 :40",
+		"begins_with(#n)",
+		"#missing = :alice",
+		"age = :missing",
+		"(age = :30",
+		"age = :30 age",
+	} {
+		if _, err := parseCond(expr, testEnv); err == nil {
+			t.Errorf("parseCond(%q): want an error", expr)
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	for _, tc := range []struct {
+		expr string
+		attr string
+		want *dynamodb.AttributeValue // nil if removed
+	}{
+		{"SET #n = :bob", "name", s("bob")},
+		{"SET age = age + :1", "age", n("31")},
+		{"SET age = age - :1", "age", n("29")},
+		{"SET score = score + :1", "score", n("2.5")},
+		{"SET fresh = if_not_exists(fresh, :20)", "fresh", n("20")},
+		{"SET age = if_not_exists(age, :20)", "age", n("30")},
+		{"SET list = list_append(list, :l)", "list", &dynamodb.AttributeValue{L: []*dynamodb.AttributeValue{n("1"), n("2"), n("3")}}},
+		{"SET addr.city = :bob", "addr", &dynamodb.AttributeValue{M: item{"city": s("bob")}}},
+		{"SET list[0] = :40", "list", &dynamodb.AttributeValue{L: []*dynamodb.AttributeValue{n("40"), n("2")}}},
+		{"REMOVE age", "age", nil},
+		{"REMOVE age, #n", "name", nil},
+		{"ADD age :1", "age", n("31")},
+		{"ADD counter :1", "counter", n("1")},
+		{"ADD tags :tags", "tags", &dynamodb.AttributeValue{SS: aws.StringSlice([]string{"a", "b", "c"})}},
+		{"DELETE tags :tags", "tags", &dynamodb.AttributeValue{SS: aws.StringSlice([]string{"a"})}},
+		{"SET #n = :bob REMOVE age", "age", nil},
+		{"SET #n = :bob ADD age :1", "name", s("bob")},
+	} {
+		u, err := parseUpdate(tc.expr, testEnv)
+		if err != nil {
+			t.Errorf("parseUpdate(%q): %v", tc.expr, err)
+			continue
+		}
+
+		it := testItem()
+		if err := u(it); err != nil {
+			t.Errorf("%q: %v", tc.expr, err)
+			continue
+		}
+
+		if got := it[tc.attr]; !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q: %s = %v, want %v", tc.expr, tc.attr, got, tc.want)
+		}
+	}
+}
+
+func TestUpdateInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"SET #n",
+		"SET #n = :missing",
+		"UPSERT #n = :bob",
+		"ADD #n :1", // not a number
+		"ADD tags :1",
+		"SET #n = #n + :1",
+	} {
+		u, err := parseUpdate(expr, testEnv)
+		if err == nil {
+			err = u(testItem())
+		}
+
+		if err == nil {
+			t.Errorf("%q: want an error", expr)
+		}
+	}
+}
+
+func TestProject(t *testing.T) {
+	got, err := project(testItem(), "#n, addr.city, missing", testEnv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := item{"name": s("alice"), "addr": {M: item{"city": s("Tokyo")}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("project = %v, want %v", got, want)
+	}
+}
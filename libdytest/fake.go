@@ -0,0 +1,782 @@
+package libdytest
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/flowerinthenight/libdy"
+)
+
+// Fake is an in-memory dynamodbiface.DynamoDBAPI for unit tests, when
+// DynamoDB Local is too slow or unavailable. It implements tables (create,
+// describe, delete, list), items (get, put, update, delete, batches), and
+// Query and Scan, on tables and global secondary indexes, with condition,
+// key condition, filter, update, and projection expressions:
+//
+//	fake := libdytest.NewFake()
+//	if err := fake.CreateTables(ctx, libdy.TableDef{Name: "orders", PK: "id"}); err != nil {
+//		t.Fatal(err)
+//	}
+//
+//	client := fake.Client(libdy.WithTable("orders"))
+//
+// It is deterministic: tables are ACTIVE once created, and Query and Scan
+// return items in key order, regardless of the order they were written.
+// It doesn't implement capacity, size limits, or transactions; other
+// operations panic.
+type Fake struct {
+	dynamodbiface.DynamoDBAPI // nil
+
+	mu     sync.Mutex
+	tables map[string]*fakeTable
+}
+
+type fakeTable struct {
+	desc    *dynamodb.TableDescription
+	key     fakeKey
+	indexes map[string]fakeKey
+	items   map[string]item // by key
+}
+
+// fakeKey is the key schema of a table or index.
+type fakeKey struct{ pk, sk string }
+
+// attrs returns the attribute names of k.
+func (k fakeKey) attrs() []string {
+	if k.sk == "" {
+		return []string{k.pk}
+	}
+
+	return []string{k.pk, k.sk}
+}
+
+func newFakeKey(ks []*dynamodb.KeySchemaElement) fakeKey {
+	var k fakeKey
+	for _, e := range ks {
+		if aws.StringValue(e.KeyType) == dynamodb.KeyTypeHash {
+			k.pk = aws.StringValue(e.AttributeName)
+		} else {
+			k.sk = aws.StringValue(e.AttributeName)
+		}
+	}
+
+	return k
+}
+
+// NewFake returns a Fake without tables.
+func NewFake() *Fake {
+	return &Fake{tables: map[string]*fakeTable{}}
+}
+
+// SetupFake returns a Fake with the tables of defs, failing t on error.
+func SetupFake(t testing.TB, defs ...libdy.TableDef) *Fake {
+	t.Helper()
+	f := NewFake()
+	if err := f.CreateTables(context.Background(), defs...); err != nil {
+		t.Fatal(err)
+	}
+
+	return f
+}
+
+// Client returns a libdy.Client on f.
+func (f *Fake) Client(opts ...libdy.Option) *libdy.Client {
+	return libdy.New(f, opts...)
+}
+
+// CreateTables creates the tables of defs, replacing any existing ones, so
+// they start empty.
+func (f *Fake) CreateTables(ctx context.Context, defs ...libdy.TableDef) error {
+	for _, def := range defs {
+		f.mu.Lock()
+		delete(f.tables, def.Name)
+		f.mu.Unlock()
+		if _, err := libdy.EnsureTableWithContext(ctx, f, def); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LoadFixtures writes the items of the files at paths to table, as
+// Local.LoadFixtures does.
+func (f *Fake) LoadFixtures(ctx context.Context, table string, paths ...string) (int64, error) {
+	return (&Local{Svc: f}).LoadFixtures(ctx, table, paths...)
+}
+
+// Load loads the fixtures at paths into table, failing t on error.
+func (f *Fake) Load(t testing.TB, table string, paths ...string) {
+	t.Helper()
+	if _, err := f.LoadFixtures(context.Background(), table, paths...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Items returns the items of table, in key order, e.g. to check the state
+// of a test.
+func (f *Fake) Items(table string) []map[string]*dynamodb.AttributeValue {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, ok := f.tables[table]
+	if !ok {
+		return nil
+	}
+
+	var ret []map[string]*dynamodb.AttributeValue
+	for _, it := range t.sorted(t.key, fakeKey{}) {
+		ret = append(ret, clone(it))
+	}
+
+	return ret
+}
+
+func validation(format string, args ...interface{}) error {
+	return awserr.New("ValidationException", fmt.Sprintf(format, args...), nil)
+}
+
+func notFound(table string) error {
+	return &dynamodb.ResourceNotFoundException{Message_: aws.String("Requested resource not found: Table: " + table + " not found")}
+}
+
+// table returns the table named name; f.mu must be held.
+func (f *Fake) table(name *string) (*fakeTable, error) {
+	t, ok := f.tables[aws.StringValue(name)]
+	if !ok {
+		return nil, notFound(aws.StringValue(name))
+	}
+
+	return t, nil
+}
+
+// keyOf returns the storage key of it, which must have the key attributes
+// of t, and only them if exact.
+func (t *fakeTable) keyOf(it item, exact bool) (string, error) {
+	if exact && len(it) != len(t.key.attrs()) {
+		return "", validation("The provided key element does not match the schema")
+	}
+
+	var b strings.Builder
+	for _, name := range t.key.attrs() {
+		v := it[name]
+		if v == nil {
+			return "", validation("One of the required keys was not given a value: %s", name)
+		}
+
+		if typ := t.attrType(name); typeOf(v) != typ {
+			return "", validation("Type mismatch for key %s expected: %s actual: %s", name, typ, typeOf(v))
+		}
+
+		b.WriteString(encode(v))
+		b.WriteByte(0)
+	}
+
+	return b.String(), nil
+}
+
+func (t *fakeTable) attrType(name string) string {
+	for _, def := range t.desc.AttributeDefinitions {
+		if aws.StringValue(def.AttributeName) == name {
+			return aws.StringValue(def.AttributeType)
+		}
+	}
+
+	return ""
+}
+
+// encode returns a string identifying the scalar v.
+func encode(v *dynamodb.AttributeValue) string {
+	switch {
+	case v.S != nil:
+		return "S" + *v.S
+	case v.N != nil:
+		return "N" + number(v.N).RatString()
+	}
+
+	return "B" + string(v.B)
+}
+
+// sorted returns the items of t with the attributes of index, if any, in
+// the order of index then of the table key.
+func (t *fakeTable) sorted(index, table fakeKey) []item {
+	var ret []item
+	for _, it := range t.items {
+		if it[index.pk] != nil && (index.sk == "" || it[index.sk] != nil) {
+			ret = append(ret, it)
+		}
+	}
+
+	order := append(index.attrs(), table.attrs()...)
+	sort.Slice(ret, func(i, j int) bool { return compareItems(ret[i], ret[j], order) < 0 })
+	return ret
+}
+
+// compareItems orders items by the attributes in order.
+func compareItems(a, b item, order []string) int {
+	for _, name := range order {
+		if name == "" || a[name] == nil && b[name] == nil {
+			continue
+		}
+
+		if a[name] == nil || b[name] == nil {
+			if a[name] == nil {
+				return -1
+			}
+
+			return 1
+		}
+
+		if c, ok := compare(a[name], b[name]); ok && c != 0 {
+			return c
+		} else if !ok {
+			if c := strings.Compare(encode(a[name]), encode(b[name])); c != 0 {
+				return c
+			}
+		}
+	}
+
+	return 0
+}
+
+// index returns the key of the index named name, or of the table if "".
+func (t *fakeTable) index(name *string) (fakeKey, error) {
+	if aws.StringValue(name) == "" {
+		return t.key, nil
+	}
+
+	k, ok := t.indexes[*name]
+	if !ok {
+		return fakeKey{}, validation("The table does not have the specified index: %s", *name)
+	}
+
+	return k, nil
+}
+
+// check evaluates the condition expression s on it, nil if missing.
+func check(it item, s *string, env exprEnv, onFail *string) error {
+	c, err := parseCond(aws.StringValue(s), env)
+	if err != nil {
+		return validation("%v", err)
+	}
+
+	if c(it) {
+		return nil
+	}
+
+	e := &dynamodb.ConditionalCheckFailedException{Message_: aws.String("The conditional request failed")}
+	if aws.StringValue(onFail) == dynamodb.ReturnValuesOnConditionCheckFailureAllOld {
+		e.Item = clone(it)
+	}
+
+	return e
+}
+
+func (f *Fake) CreateTableWithContext(ctx aws.Context, in *dynamodb.CreateTableInput, opts ...request.Option) (*dynamodb.CreateTableOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := aws.StringValue(in.TableName)
+	if _, ok := f.tables[name]; ok {
+		return nil, &dynamodb.ResourceInUseException{Message_: aws.String("Table already exists: " + name)}
+	}
+
+	t := &fakeTable{
+		desc: &dynamodb.TableDescription{
+			TableName:            aws.String(name),
+			TableArn:             aws.String("arn:aws:dynamodb:us-east-1:000000000000:table/" + name),
+			TableStatus:          aws.String(dynamodb.TableStatusActive),
+			CreationDateTime:     aws.Time(time.Now()),
+			KeySchema:            in.KeySchema,
+			AttributeDefinitions: in.AttributeDefinitions,
+			BillingModeSummary:   &dynamodb.BillingModeSummary{BillingMode: in.BillingMode},
+		},
+		key:     newFakeKey(in.KeySchema),
+		indexes: map[string]fakeKey{},
+		items:   map[string]item{},
+	}
+
+	if pt := in.ProvisionedThroughput; pt != nil {
+		t.desc.ProvisionedThroughput = &dynamodb.ProvisionedThroughputDescription{
+			ReadCapacityUnits:  pt.ReadCapacityUnits,
+			WriteCapacityUnits: pt.WriteCapacityUnits,
+		}
+	}
+
+	for _, gsi := range in.GlobalSecondaryIndexes {
+		t.indexes[aws.StringValue(gsi.IndexName)] = newFakeKey(gsi.KeySchema)
+		t.desc.GlobalSecondaryIndexes = append(t.desc.GlobalSecondaryIndexes, &dynamodb.GlobalSecondaryIndexDescription{
+			IndexName:   gsi.IndexName,
+			IndexStatus: aws.String(dynamodb.IndexStatusActive),
+			KeySchema:   gsi.KeySchema,
+			Projection:  gsi.Projection,
+		})
+	}
+
+	f.tables[name] = t
+	return &dynamodb.CreateTableOutput{TableDescription: t.describe()}, nil
+}
+
+// describe returns the description of t, with its current item count.
+func (t *fakeTable) describe() *dynamodb.TableDescription {
+	d := *t.desc
+	d.ItemCount = aws.Int64(int64(len(t.items)))
+	return &d
+}
+
+func (f *Fake) DescribeTableWithContext(ctx aws.Context, in *dynamodb.DescribeTableInput, opts ...request.Option) (*dynamodb.DescribeTableOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, err := f.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dynamodb.DescribeTableOutput{Table: t.describe()}, nil
+}
+
+func (f *Fake) DeleteTableWithContext(ctx aws.Context, in *dynamodb.DeleteTableInput, opts ...request.Option) (*dynamodb.DeleteTableOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, err := f.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	delete(f.tables, aws.StringValue(in.TableName))
+	d := t.describe()
+	d.TableStatus = aws.String(dynamodb.TableStatusDeleting)
+	return &dynamodb.DeleteTableOutput{TableDescription: d}, nil
+}
+
+func (f *Fake) ListTablesWithContext(ctx aws.Context, in *dynamodb.ListTablesInput, opts ...request.Option) (*dynamodb.ListTablesOutput, error) {
+	f.mu.Lock()
+	names := make([]string, 0, len(f.tables))
+	for name := range f.tables {
+		if name > aws.StringValue(in.ExclusiveStartTableName) {
+			names = append(names, name)
+		}
+	}
+
+	f.mu.Unlock()
+	sort.Strings(names)
+	out := &dynamodb.ListTablesOutput{}
+	if n := int(aws.Int64Value(in.Limit)); n > 0 && n < len(names) {
+		names = names[:n]
+		out.LastEvaluatedTableName = aws.String(names[n-1])
+	}
+
+	out.TableNames = aws.StringSlice(names)
+	return out, nil
+}
+
+func (f *Fake) ListTablesPagesWithContext(ctx aws.Context, in *dynamodb.ListTablesInput, fn func(*dynamodb.ListTablesOutput, bool) bool, opts ...request.Option) error {
+	out, err := f.ListTablesWithContext(ctx, &dynamodb.ListTablesInput{})
+	if err != nil {
+		return err
+	}
+
+	fn(out, true)
+	return nil
+}
+
+// Tables are ACTIVE once created, and gone once deleted.
+
+func (f *Fake) WaitUntilTableExistsWithContext(ctx aws.Context, in *dynamodb.DescribeTableInput, opts ...request.WaiterOption) error {
+	_, err := f.DescribeTableWithContext(ctx, in)
+	return err
+}
+
+func (f *Fake) WaitUntilTableNotExistsWithContext(ctx aws.Context, in *dynamodb.DescribeTableInput, opts ...request.WaiterOption) error {
+	return nil
+}
+
+func (f *Fake) GetItemWithContext(ctx aws.Context, in *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, err := f.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := t.keyOf(in.Key, true)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &dynamodb.GetItemOutput{}
+	if it, ok := t.items[k]; ok {
+		env := exprEnv{names: in.ExpressionAttributeNames}
+		if out.Item, err = project(it, aws.StringValue(in.ProjectionExpression), env); err != nil {
+			return nil, validation("%v", err)
+		}
+	}
+
+	return out, nil
+}
+
+func (f *Fake) PutItemWithContext(ctx aws.Context, in *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, err := f.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := t.keyOf(in.Item, false)
+	if err != nil {
+		return nil, err
+	}
+
+	old := t.items[k]
+	env := exprEnv{names: in.ExpressionAttributeNames, values: in.ExpressionAttributeValues}
+	if err := check(old, in.ConditionExpression, env, in.ReturnValuesOnConditionCheckFailure); err != nil {
+		return nil, err
+	}
+
+	t.items[k] = clone(in.Item)
+	out := &dynamodb.PutItemOutput{}
+	if aws.StringValue(in.ReturnValues) == dynamodb.ReturnValueAllOld {
+		out.Attributes = clone(old)
+	}
+
+	return out, nil
+}
+
+func (f *Fake) DeleteItemWithContext(ctx aws.Context, in *dynamodb.DeleteItemInput, opts ...request.Option) (*dynamodb.DeleteItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, err := f.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := t.keyOf(in.Key, true)
+	if err != nil {
+		return nil, err
+	}
+
+	old := t.items[k]
+	env := exprEnv{names: in.ExpressionAttributeNames, values: in.ExpressionAttributeValues}
+	if err := check(old, in.ConditionExpression, env, in.ReturnValuesOnConditionCheckFailure); err != nil {
+		return nil, err
+	}
+
+	delete(t.items, k)
+	out := &dynamodb.DeleteItemOutput{}
+	if aws.StringValue(in.ReturnValues) == dynamodb.ReturnValueAllOld {
+		out.Attributes = clone(old)
+	}
+
+	return out, nil
+}
+
+func (f *Fake) UpdateItemWithContext(ctx aws.Context, in *dynamodb.UpdateItemInput, opts ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, err := f.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := t.keyOf(in.Key, true)
+	if err != nil {
+		return nil, err
+	}
+
+	old := t.items[k]
+	env := exprEnv{names: in.ExpressionAttributeNames, values: in.ExpressionAttributeValues}
+	if err := check(old, in.ConditionExpression, env, in.ReturnValuesOnConditionCheckFailure); err != nil {
+		return nil, err
+	}
+
+	it := clone(old)
+	if it == nil {
+		it = clone(in.Key)
+	}
+
+	if s := aws.StringValue(in.UpdateExpression); s != "" {
+		u, err := parseUpdate(s, env)
+		if err != nil {
+			return nil, validation("%v", err)
+		}
+
+		if err := u(it); err != nil {
+			return nil, validation("%v", err)
+		}
+	}
+
+	if nk, err := t.keyOf(it, false); err != nil || nk != k {
+		return nil, validation("Cannot update attribute %s. This attribute is part of the key", strings.Join(t.key.attrs(), ", "))
+	}
+
+	t.items[k] = it
+	out := &dynamodb.UpdateItemOutput{}
+	switch aws.StringValue(in.ReturnValues) {
+	case dynamodb.ReturnValueAllOld:
+		out.Attributes = clone(old)
+	case dynamodb.ReturnValueAllNew:
+		out.Attributes = clone(it)
+	case dynamodb.ReturnValueUpdatedOld:
+		out.Attributes = changed(old, it)
+	case dynamodb.ReturnValueUpdatedNew:
+		out.Attributes = changed(it, old)
+	}
+
+	return out, nil
+}
+
+// changed returns the top-level attributes of a that differ in b.
+func changed(a, b item) item {
+	ret := item{}
+	for name, v := range a {
+		if !equal(v, b[name]) {
+			ret[name] = cloneValue(v)
+		}
+	}
+
+	return ret
+}
+
+// page returns a page of items, all in order, after start if not nil, and
+// the last evaluated key of the page, with the attributes of keys, if more
+// follow.
+func page(all []item, start item, keys []string, cmp func(a, b item) int, limit int64) ([]item, item) {
+	i := 0
+	if start != nil {
+		i = sort.Search(len(all), func(i int) bool { return cmp(all[i], start) > 0 })
+	}
+
+	all = all[i:]
+	if limit <= 0 || int(limit) >= len(all) {
+		return all, nil
+	}
+
+	last := item{}
+	for _, name := range keys {
+		if name != "" {
+			last[name] = cloneValue(all[limit-1][name])
+		}
+	}
+
+	return all[:limit], last
+}
+
+// read filters and projects a page of items.
+func read(items []item, filter, proj, sel *string, env exprEnv) ([]map[string]*dynamodb.AttributeValue, int64, error) {
+	c, err := parseCond(aws.StringValue(filter), env)
+	if err != nil {
+		return nil, 0, validation("%v", err)
+	}
+
+	var ret []map[string]*dynamodb.AttributeValue
+	var n int64
+	for _, it := range items {
+		if !c(it) {
+			continue
+		}
+
+		n++
+		if aws.StringValue(sel) == dynamodb.SelectCount {
+			continue
+		}
+
+		p, err := project(it, aws.StringValue(proj), env)
+		if err != nil {
+			return nil, 0, validation("%v", err)
+		}
+
+		ret = append(ret, p)
+	}
+
+	return ret, n, nil
+}
+
+func (f *Fake) QueryWithContext(ctx aws.Context, in *dynamodb.QueryInput, opts ...request.Option) (*dynamodb.QueryOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, err := f.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := t.index(in.IndexName)
+	if err != nil {
+		return nil, err
+	}
+
+	env := exprEnv{names: in.ExpressionAttributeNames, values: in.ExpressionAttributeValues}
+	kc, err := parseCond(aws.StringValue(in.KeyConditionExpression), env)
+	if err != nil || aws.StringValue(in.KeyConditionExpression) == "" {
+		return nil, validation("invalid KeyConditionExpression: %v", err)
+	}
+
+	var matched []item
+	for _, it := range t.sorted(index, t.key) {
+		if kc(it) {
+			matched = append(matched, it)
+		}
+	}
+
+	// Within a partition, by sort key, then by table key for indexes.
+	order := append([]string{index.sk}, t.key.attrs()...)
+	cmp := func(a, b item) int { return compareItems(a, b, order) }
+	if !aws.BoolValue(in.ScanIndexForward) && in.ScanIndexForward != nil {
+		for i, j := 0, len(matched)-1; i < j; i, j = i+1, j-1 {
+			matched[i], matched[j] = matched[j], matched[i]
+		}
+
+		cmp = func(a, b item) int { return -compareItems(a, b, order) }
+	}
+
+	keys := append(index.attrs(), t.key.attrs()...)
+	items, last := page(matched, in.ExclusiveStartKey, keys, cmp, aws.Int64Value(in.Limit))
+	ret, n, err := read(items, in.FilterExpression, in.ProjectionExpression, in.Select, env)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dynamodb.QueryOutput{
+		Items:            ret,
+		Count:            aws.Int64(n),
+		ScannedCount:     aws.Int64(int64(len(items))),
+		LastEvaluatedKey: last,
+	}, nil
+}
+
+func (f *Fake) ScanWithContext(ctx aws.Context, in *dynamodb.ScanInput, opts ...request.Option) (*dynamodb.ScanOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, err := f.table(in.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := t.index(in.IndexName)
+	if err != nil {
+		return nil, err
+	}
+
+	all := t.sorted(index, t.key)
+	if total := aws.Int64Value(in.TotalSegments); total > 0 {
+		var seg []item
+		for _, it := range all {
+			h := fnv.New32a()
+			h.Write([]byte(encode(it[index.pk])))
+			if int64(h.Sum32())%total == aws.Int64Value(in.Segment) {
+				seg = append(seg, it)
+			}
+		}
+
+		all = seg
+	}
+
+	keys := append(index.attrs(), t.key.attrs()...)
+	cmp := func(a, b item) int { return compareItems(a, b, keys) }
+	items, last := page(all, in.ExclusiveStartKey, keys, cmp, aws.Int64Value(in.Limit))
+	env := exprEnv{names: in.ExpressionAttributeNames, values: in.ExpressionAttributeValues}
+	ret, n, err := read(items, in.FilterExpression, in.ProjectionExpression, in.Select, env)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dynamodb.ScanOutput{
+		Items:            ret,
+		Count:            aws.Int64(n),
+		ScannedCount:     aws.Int64(int64(len(items))),
+		LastEvaluatedKey: last,
+	}, nil
+}
+
+func (f *Fake) BatchGetItemWithContext(ctx aws.Context, in *dynamodb.BatchGetItemInput, opts ...request.Option) (*dynamodb.BatchGetItemOutput, error) {
+	out := &dynamodb.BatchGetItemOutput{Responses: map[string][]map[string]*dynamodb.AttributeValue{}}
+	for table, ka := range in.RequestItems {
+		for _, key := range ka.Keys {
+			res, err := f.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+				TableName:                aws.String(table),
+				Key:                      key,
+				ProjectionExpression:     ka.ProjectionExpression,
+				ExpressionAttributeNames: ka.ExpressionAttributeNames,
+			})
+
+			if err != nil {
+				return nil, err
+			}
+
+			if res.Item != nil {
+				out.Responses[table] = append(out.Responses[table], res.Item)
+			}
+		}
+	}
+
+	return out, nil
+}
+
+func (f *Fake) BatchWriteItemWithContext(ctx aws.Context, in *dynamodb.BatchWriteItemInput, opts ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
+	if err := f.checkBatchWrite(in); err != nil {
+		return nil, err
+	}
+
+	for table, reqs := range in.RequestItems {
+		for _, req := range reqs {
+			var err error
+			switch {
+			case req.PutRequest != nil:
+				_, err = f.PutItemWithContext(ctx, &dynamodb.PutItemInput{TableName: aws.String(table), Item: req.PutRequest.Item})
+			case req.DeleteRequest != nil:
+				_, err = f.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{TableName: aws.String(table), Key: req.DeleteRequest.Key})
+			}
+
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &dynamodb.BatchWriteItemOutput{UnprocessedItems: map[string][]*dynamodb.WriteRequest{}}, nil
+}
+
+// checkBatchWrite fails the whole batch, before any write, if a key doesn't
+// match its table, or an item is written twice.
+func (f *Fake) checkBatchWrite(in *dynamodb.BatchWriteItemInput) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for table, reqs := range in.RequestItems {
+		t, err := f.table(aws.String(table))
+		if err != nil {
+			return err
+		}
+
+		seen := map[string]bool{}
+		for _, req := range reqs {
+			var k string
+			switch {
+			case req.PutRequest != nil:
+				k, err = t.keyOf(req.PutRequest.Item, false)
+			case req.DeleteRequest != nil:
+				k, err = t.keyOf(req.DeleteRequest.Key, true)
+			}
+
+			if err != nil {
+				return err
+			}
+
+			if seen[k] {
+				return validation("Provided list of item keys contains duplicates")
+			}
+
+			seen[k] = true
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,220 @@
+package libdytest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/flowerinthenight/libdy"
+)
+
+// seed returns a Fake with table "t" keyed by pk and sk:N, holding the items
+// pk "a" and "b", sk 1 to 5 each, and "g" (the key of index by-g) set to
+// "even" or "odd".
+func seed(t *testing.T) *Fake {
+	f := SetupFake(t, libdy.TableDef{
+		Name:    "t",
+		PK:      "pk",
+		SK:      "sk:N",
+		Indexes: []libdy.IndexDef{{Name: "by-g", PK: "g", SK: "sk:N"}},
+	})
+
+	for _, pk := range []string{"a", "b"} {
+		for i := 1; i <= 5; i++ {
+			g := map[bool]string{true: "even", false: "odd"}[i%2 == 0]
+			_, err := f.PutItemWithContext(context.Background(), &dynamodb.PutItemInput{
+				TableName: aws.String("t"),
+				Item:      item{"pk": s(pk), "sk": n(fmt.Sprint(i)), "g": s(g)},
+			})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	return f
+}
+
+// keysOf returns the pk and sk of items as "pk/sk".
+func keysOf(items []item) []string {
+	ret := make([]string, len(items))
+	for i, it := range items {
+		ret[i] = aws.StringValue(it["pk"].S) + "/" + aws.StringValue(it["sk"].N)
+	}
+
+	return ret
+}
+
+func TestQueryPaging(t *testing.T) {
+	f := seed(t)
+	for _, tc := range []struct {
+		name    string
+		index   string
+		expr    string
+		filter  string
+		forward bool
+		limit   int64
+		want    string
+		pages   int
+	}{
+		{"forward", "", "pk = :a", "", true, 2, "[a/1 a/2 a/3 a/4 a/5]", 3},
+		{"backward", "", "pk = :a", "", false, 2, "[a/5 a/4 a/3 a/2 a/1]", 3},
+		{"exact pages", "", "pk = :a", "", true, 5, "[a/1 a/2 a/3 a/4 a/5]", 1},
+		{"no limit", "", "pk = :b", "", true, 0, "[b/1 b/2 b/3 b/4 b/5]", 1},
+		{"sort key", "", "pk = :a AND sk BETWEEN :2 AND :4", "", true, 2, "[a/2 a/3 a/4]", 2},
+		{"filter", "", "pk = :a", "sk <> :2", true, 2, "[a/1 a/3 a/4 a/5]", 3},
+		{"index", "by-g", "g = :even", "", true, 1, "[a/2 b/2 a/4 b/4]", 4},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			in := &dynamodb.QueryInput{
+				TableName:              aws.String("t"),
+				KeyConditionExpression: aws.String(tc.expr),
+				ScanIndexForward:       aws.Bool(tc.forward),
+				ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+					":a": s("a"), ":b": s("b"), ":2": n("2"), ":4": n("4"), ":even": s("even"),
+				},
+			}
+
+			if tc.index != "" {
+				in.IndexName = aws.String(tc.index)
+			}
+
+			if tc.filter != "" {
+				in.FilterExpression = aws.String(tc.filter)
+			}
+
+			if tc.limit > 0 {
+				in.Limit = aws.Int64(tc.limit)
+			}
+
+			var got []item
+			pages := 0
+			for {
+				pages++
+				res, err := f.QueryWithContext(context.Background(), in)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if n := int64(len(res.Items)); aws.Int64Value(res.Count) != n || (tc.limit > 0 && aws.Int64Value(res.ScannedCount) > tc.limit) {
+					t.Errorf("page %d: Count %d, ScannedCount %d, %d items", pages, aws.Int64Value(res.Count), aws.Int64Value(res.ScannedCount), n)
+				}
+
+				got = append(got, res.Items...)
+				if res.LastEvaluatedKey == nil {
+					break
+				}
+
+				in.ExclusiveStartKey = res.LastEvaluatedKey
+			}
+
+			if s := fmt.Sprint(keysOf(got)); s != tc.want || pages != tc.pages {
+				t.Errorf("got %s in %d pages, want %s in %d", s, pages, tc.want, tc.pages)
+			}
+		})
+	}
+}
+
+func TestScanPaging(t *testing.T) {
+	f := seed(t)
+	in := &dynamodb.ScanInput{
+		TableName:                 aws.String("t"),
+		Limit:                     aws.Int64(3),
+		FilterExpression:          aws.String("g = :odd"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":odd": s("odd")},
+	}
+
+	var got []item
+	var scanned int64
+	for {
+		res, err := f.ScanWithContext(context.Background(), in)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got = append(got, res.Items...)
+		scanned += aws.Int64Value(res.ScannedCount)
+		if res.LastEvaluatedKey == nil {
+			break
+		}
+
+		in.ExclusiveStartKey = res.LastEvaluatedKey
+	}
+
+	if len(got) != 6 || scanned != 10 {
+		t.Errorf("%d items of %d scanned, want 6 of 10: %v", len(got), scanned, keysOf(got))
+	}
+}
+
+func TestConditionalWrites(t *testing.T) {
+	ctx := context.Background()
+	f := seed(t)
+	key := item{"pk": s("a"), "sk": n("1")}
+	for _, tc := range []struct {
+		cond string
+		ok   bool
+	}{
+		{"attribute_not_exists(pk)", false},
+		{"attribute_exists(pk)", true},
+		{"g = :odd", true},
+		{"g = :even", false},
+	} {
+		_, err := f.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 aws.String("t"),
+			Key:                       key,
+			UpdateExpression:          aws.String("ADD hits :one"),
+			ConditionExpression:       aws.String(tc.cond),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":one": n("1"), ":odd": s("odd"), ":even": s("even")},
+		})
+
+		if failed := libdy.IsConditionalCheckFailed(err); failed == tc.ok || (err != nil && !failed) {
+			t.Errorf("%q: %v", tc.cond, err)
+		}
+	}
+
+	res, err := f.GetItemWithContext(ctx, &dynamodb.GetItemInput{TableName: aws.String("t"), Key: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := aws.StringValue(res.Item["hits"].N); got != "2" {
+		t.Errorf("hits = %s, want 2", got)
+	}
+
+	_, err = f.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String("t"),
+		Item:                item{"pk": s("a"), "sk": n("1")},
+		ConditionExpression: aws.String("attribute_not_exists(pk)"),
+	})
+
+	if !libdy.IsConditionalCheckFailed(err) {
+		t.Errorf("create-only put of an existing item: %v", err)
+	}
+}
+
+func TestBatchWriteDuplicates(t *testing.T) {
+	ctx := context.Background()
+	f := seed(t)
+	put := func(pk, sk string) *dynamodb.WriteRequest {
+		return &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: item{"pk": s(pk), "sk": n(sk)}}}
+	}
+
+	_, err := f.BatchWriteItemWithContext(ctx, &dynamodb.BatchWriteItemInput{
+		RequestItems: map[string][]*dynamodb.WriteRequest{"t": {
+			put("c", "1"),
+			put("a", "1"),
+			{DeleteRequest: &dynamodb.DeleteRequest{Key: item{"pk": s("a"), "sk": n("1")}}},
+		}},
+	})
+
+	if libdy.ErrorCode(err) != "ValidationException" {
+		t.Fatalf("batch writing an item twice: %v, want ValidationException", err)
+	}
+
+	if items := f.Items("t"); len(items) != 10 {
+		t.Errorf("%d items after a rejected batch, want 10", len(items))
+	}
+}
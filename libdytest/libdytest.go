@@ -0,0 +1,224 @@
+// Package libdytest runs integration tests of code using libdy against
+// DynamoDB Local (https://hub.docker.com/r/amazon/dynamodb-local), without
+// AWS credentials: it connects to a running instance, or starts one with
+// Docker, creates the tables of the tests, and loads their fixtures.
+//
+//	func TestOrders(t *testing.T) {
+//		local := libdytest.Setup(t, libdy.TableDef{Name: "orders", PK: "id"})
+//		local.Load(t, "orders", "testdata/orders.jsonl")
+//		client := local.Client(libdy.WithTable("orders"))
+//		...
+//	}
+//
+// Set LIBDY_DYNAMODB_LOCAL to the endpoint of an instance, e.g.
+// "http://localhost:8000" in CI with a service container; otherwise Setup
+// starts a container, or skips the test if Docker isn't available.
+//
+// For unit tests, Fake is an in-memory implementation of the common subset
+// of the API, without DynamoDB Local.
+package libdytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/flowerinthenight/libdy"
+)
+
+const (
+	// EnvEndpoint names the environment variable with the endpoint of a
+	// running DynamoDB Local.
+	EnvEndpoint = "LIBDY_DYNAMODB_LOCAL"
+
+	// Image is the Docker image Start runs.
+	Image = "amazon/dynamodb-local"
+
+	startTimeout = time.Minute
+)
+
+// Local is a DynamoDB Local instance.
+type Local struct {
+	Endpoint string
+	Svc      dynamodbiface.DynamoDBAPI
+
+	container string // ID, if started by Start
+}
+
+// Start connects to the DynamoDB Local at the endpoint in EnvEndpoint, or
+// starts one in Docker, in memory, and waits until it's up. Close stops it.
+func Start(ctx context.Context) (*Local, error) {
+	l := &Local{Endpoint: os.Getenv(EnvEndpoint)}
+	if l.Endpoint == "" {
+		if err := l.run(ctx); err != nil {
+			return nil, fmt.Errorf("Start failed: %w", err)
+		}
+	}
+
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Endpoint:    aws.String(l.Endpoint),
+		Credentials: credentials.NewStaticCredentials("local", "local", ""),
+	})
+
+	if err != nil {
+		l.Close()
+		return nil, fmt.Errorf("Start failed: %w", err)
+	}
+
+	l.Svc = dynamodb.New(sess)
+	if err := l.wait(ctx); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("Start failed: %w", err)
+	}
+
+	return l, nil
+}
+
+// run starts a container, on a free port of the loopback interface.
+func (l *Local) run(ctx context.Context) error {
+	out, err := exec.CommandContext(ctx, "docker", "run", "-d", "--rm", "-p", "127.0.0.1::8000",
+		Image, "-jar", "DynamoDBLocal.jar", "-inMemory", "-sharedDb").Output()
+	if err != nil {
+		return fmt.Errorf("docker run: %w", err)
+	}
+
+	l.container = strings.TrimSpace(string(out))
+	out, err = exec.CommandContext(ctx, "docker", "port", l.container, "8000/tcp").Output()
+	if err != nil {
+		l.Close()
+		return fmt.Errorf("docker port: %w", err)
+	}
+
+	addr, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	l.Endpoint = "http://" + addr
+	return nil
+}
+
+// wait waits until the instance serves requests.
+func (l *Local) wait(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, startTimeout)
+	defer cancel()
+	for {
+		_, err := l.Svc.ListTablesWithContext(ctx, &dynamodb.ListTablesInput{})
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s not up: %w", l.Endpoint, err)
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+}
+
+// Close stops the container started by Start, if any.
+func (l *Local) Close() error {
+	if l.container == "" {
+		return nil
+	}
+
+	err := exec.Command("docker", "rm", "-f", l.container).Run()
+	l.container = ""
+	return err
+}
+
+// Client returns a libdy.Client on l.
+func (l *Local) Client(opts ...libdy.Option) *libdy.Client {
+	return libdy.New(l.Svc, opts...)
+}
+
+// CreateTables creates the tables of defs, replacing any existing ones, so
+// they start empty.
+func (l *Local) CreateTables(ctx context.Context, defs ...libdy.TableDef) error {
+	for _, def := range defs {
+		if err := l.DeleteTables(ctx, def.Name); err != nil {
+			return err
+		}
+
+		if _, err := libdy.EnsureTableWithContext(ctx, l.Svc, def); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteTables deletes tables; missing ones are skipped.
+func (l *Local) DeleteTables(ctx context.Context, tables ...string) error {
+	for _, table := range tables {
+		err := libdy.DeleteTableWithContext(ctx, l.Svc, table, libdy.WithForceDelete())
+		switch {
+		case errors.Is(err, libdy.ErrTableNotFound):
+			continue
+		case err != nil:
+			return err
+		}
+
+		if err := libdy.WaitForTableDeletedWithContext(ctx, l.Svc, table); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LoadFixtures writes the items of the files at paths, JSON or CSV (see
+// libdy.FileSource), to table, and returns their number.
+func (l *Local) LoadFixtures(ctx context.Context, table string, paths ...string) (int64, error) {
+	var n int64
+	for _, path := range paths {
+		m, err := libdy.ImportItemsWithContext(ctx, l.Svc, libdy.FileSource(path), table)
+		n += m
+		if err != nil {
+			return n, fmt.Errorf("LoadFixtures failed: %s: %w", path, err)
+		}
+	}
+
+	return n, nil
+}
+
+// Setup starts a Local for t (see Start), skipping t if there is none, and
+// creates the tables of defs, deleted, with the Local stopped, when t ends.
+func Setup(t testing.TB, defs ...libdy.TableDef) *Local {
+	t.Helper()
+	ctx := context.Background()
+	l, err := Start(ctx)
+	if err != nil {
+		t.Skipf("no DynamoDB Local: %v", err)
+	}
+
+	t.Cleanup(func() {
+		for _, def := range defs {
+			l.DeleteTables(ctx, def.Name)
+		}
+
+		l.Close()
+	})
+
+	if err := l.CreateTables(ctx, defs...); err != nil {
+		t.Fatal(err)
+	}
+
+	return l
+}
+
+// Load loads the fixtures at paths into table (see LoadFixtures), failing
+// t on error.
+func (l *Local) Load(t testing.TB, table string, paths ...string) {
+	t.Helper()
+	if _, err := l.LoadFixtures(context.Background(), table, paths...); err != nil {
+		t.Fatal(err)
+	}
+}
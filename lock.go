@@ -0,0 +1,316 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// lockLease is the default lease duration of a Lock.
+const lockLease = 20 * time.Second
+
+var (
+	ErrLockHeld = errors.New("libdy: lock held by another owner")
+	ErrLockLost = errors.New("libdy: lock lost")
+)
+
+// LockConfig configures AcquireLock.
+type LockConfig struct {
+	Owner string        // unique per holder; the default is the host, PID, and a random suffix
+	Lease time.Duration // how long the lock outlives its last heartbeat; the default is 20s
+
+	// Wait is how long to wait for a lock held by another owner before
+	// failing with ErrLockHeld; 0 means not at all, and a negative Wait
+	// means until ctx is done. Poll is how often to check; the default is
+	// a quarter of the lease.
+	Wait time.Duration
+	Poll time.Duration
+
+	// TTL, if set, sets the Time to Live attribute of the lock item with
+	// each write, so the items of locks no longer used are cleaned up. As
+	// a deleted item restarts the fencing token, make it much longer than
+	// any lock is held.
+	TTL TTL
+}
+
+// Lock is a distributed lock held in a DynamoDB table, in the pattern of the
+// DynamoDB lock client: each heartbeat writes a new record version number
+// (RVN), and a lock whose RVN didn't change for its lease is stale, and is
+// taken over. Staleness is timed by the waiter's clock only, so clock skew
+// between holders doesn't matter.
+//
+// Each acquisition increments the lock's fencing Token. A holder that stalls
+// past its lease (a GC pause, a partition) may still believe it holds the
+// lock, so writes it guards should carry the token, and be rejected if a
+// newer one was seen, e.g. with FenceCondition.
+//
+// The table has one item per lock, keyed by a string partition key "id":
+//
+//	libdy.EnsureTable(svc, libdy.TableDef{Name: "locks", PK: "id"})
+//	lock, err := client.AcquireLock(ctx, "reindex", libdy.LockConfig{Wait: -1})
+//	if err != nil {
+//		return err
+//	}
+//
+//	defer lock.Release(ctx)
+type Lock struct {
+	Name  string
+	Owner string
+	Token int64 // the fencing token
+
+	mu    sync.Mutex
+	rvn   string
+	lease time.Duration
+	ttl   TTL
+	c     *Client
+	opts  []Option
+	o     options // of opts, for the clock and IDs
+}
+
+// defaultOwner returns a name for this process: the host name, PID, and a
+// random suffix, from the IDGen of o.
+func defaultOwner(o options) string {
+	host, _ := os.Hostname()
+	id := o.newID()
+	if len(id) > 8 {
+		id = id[:8]
+	}
+
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), id)
+}
+
+func AcquireLock(svc dynamodbiface.DynamoDBAPI, table, name string, cfg LockConfig, opts ...Option) (*Lock, error) {
+	return AcquireLockWithContext(context.Background(), svc, table, name, cfg, opts...)
+}
+
+// AcquireLockWithContext is Client.AcquireLock for table.
+func AcquireLockWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, name string, cfg LockConfig, opts ...Option) (*Lock, error) {
+	return New(svc, WithTable(table)).AcquireLock(ctx, name, cfg, opts...)
+}
+
+// AcquireLock acquires the lock name in the table (see Lock), waiting per
+// cfg.Wait if another owner holds it, and taking it over once stale, or
+// right away if cfg.Owner holds it, e.g. from before a restart. The holder
+// must call Heartbeat (or KeepAlive) within each lease, and Release when
+// done.
+func (c *Client) AcquireLock(ctx context.Context, name string, cfg LockConfig, opts ...Option) (*Lock, error) {
+	o, err := c.apply(opts)
+	if err != nil {
+		return nil, fmt.Errorf("AcquireLock failed: %w", err)
+	}
+
+	if cfg.Owner == "" {
+		cfg.Owner = defaultOwner(o)
+	}
+
+	if cfg.Lease <= 0 {
+		cfg.Lease = lockLease
+	}
+
+	if cfg.Poll <= 0 {
+		cfg.Poll = cfg.Lease / 4
+	}
+
+	l := &Lock{Name: name, Owner: cfg.Owner, lease: cfg.Lease, ttl: cfg.TTL, c: c, opts: opts, o: o}
+	start := o.now()
+	var seen string      // the RVN of the holder
+	var seenAt time.Time // when seen changed
+	var lease time.Duration
+	for {
+		item, err := c.GetItem(ctx, StringKey("id", name).String(), "", append(opts[:len(opts):len(opts)], WithConsistentRead())...)
+		if err != nil && !errors.Is(err, ErrItemNotFound) {
+			return nil, fmt.Errorf("AcquireLock failed: %w", err)
+		}
+
+		cur := parseLock(item)
+		var cond Condition
+		switch {
+		case item == nil || cur.released:
+			cond = Condition{
+				Label:  "lock",
+				Expr:   "attribute_not_exists(#lr) OR #lr = :t",
+				Names:  map[string]*string{"#lr": aws.String("released")},
+				Values: map[string]*dynamodb.AttributeValue{":t": {BOOL: aws.Bool(true)}},
+			}
+		case cur.owner == cfg.Owner:
+			cond = l.rvnCondition(cur.rvn) // ours, e.g. before a restart
+		case cur.rvn != seen:
+			seen, seenAt, lease = cur.rvn, o.now(), cur.lease
+		case o.now().Sub(seenAt) >= lease:
+			cond = l.rvnCondition(seen) // stale; take it over
+		}
+
+		if cond.Expr != "" {
+			err := l.write(ctx, NewUpdate().
+				Set("owner", &dynamodb.AttributeValue{S: aws.String(l.Owner)}).
+				Set("released", &dynamodb.AttributeValue{BOOL: aws.Bool(false)}).
+				Increment("token", 1), cond)
+
+			if err == nil {
+				return l, nil
+			}
+
+			if !errors.Is(err, ErrLockLost) {
+				return nil, fmt.Errorf("AcquireLock failed: %w", err)
+			}
+
+			seen = "" // raced with another owner; look again
+			continue
+		}
+
+		if cfg.Wait >= 0 && o.now().Sub(start) >= cfg.Wait {
+			return nil, fmt.Errorf("AcquireLock failed: %s held by %s: %w", name, cur.owner, ErrLockHeld)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("AcquireLock canceled: %w", ctx.Err())
+		case <-o.after(cfg.Poll):
+		}
+	}
+}
+
+// lockItem is the item of a lock.
+type lockItem struct {
+	owner    string
+	rvn      string
+	lease    time.Duration
+	released bool
+}
+
+func parseLock(item map[string]*dynamodb.AttributeValue) lockItem {
+	var ret lockItem
+	if v := item["owner"]; v != nil {
+		ret.owner = aws.StringValue(v.S)
+	}
+
+	if v := item["rvn"]; v != nil {
+		ret.rvn = aws.StringValue(v.S)
+	}
+
+	if v := item["lease"]; v != nil {
+		ms, _ := strconv.ParseInt(aws.StringValue(v.N), 10, 64)
+		ret.lease = time.Duration(ms) * time.Millisecond
+	}
+
+	if v := item["released"]; v != nil {
+		ret.released = aws.BoolValue(v.BOOL)
+	}
+
+	return ret
+}
+
+// rvnCondition holds if the lock's RVN is still rvn.
+func (l *Lock) rvnCondition(rvn string) Condition {
+	return Condition{
+		Label:  "lock",
+		Expr:   "#lv = :lv",
+		Names:  map[string]*string{"#lv": aws.String("rvn")},
+		Values: map[string]*dynamodb.AttributeValue{":lv": {S: aws.String(rvn)}},
+	}
+}
+
+// write applies u to the lock item with a new RVN and the lease, if cond
+// holds, or fails with ErrLockLost.
+func (l *Lock) write(ctx context.Context, u *Update, cond Condition) error {
+	rvn := l.o.newID()
+	u.Set("rvn", &dynamodb.AttributeValue{S: aws.String(rvn)}).
+		Set("lease", &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(l.lease.Milliseconds(), 10))})
+
+	if l.ttl.Attr != "" {
+		u.Set(l.ttl.Attr, ExpiryValue(l.ttl.expiresAt(l.o.now())))
+	}
+
+	opts := append(l.opts[:len(l.opts):len(l.opts)], WithCondition(cond), WithReturnValues(dynamodb.ReturnValueAllNew))
+	item, err := l.c.UpdateItem(ctx, StringKey("id", l.Name).String(), "", u, opts...)
+	if errors.Is(err, ErrConditionFailed) {
+		return fmt.Errorf("%s: %w", l.Name, ErrLockLost)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	l.rvn = rvn
+	if v := item["token"]; v != nil {
+		l.Token, _ = strconv.ParseInt(aws.StringValue(v.N), 10, 64)
+	}
+
+	return nil
+}
+
+// Heartbeat extends the lock by another lease. It fails with ErrLockLost if
+// the lock was taken over, after which the holder must stop relying on it.
+func (l *Lock) Heartbeat(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.write(ctx, NewUpdate(), l.rvnCondition(l.rvn)); err != nil {
+		return fmt.Errorf("Heartbeat failed: %w", err)
+	}
+
+	return nil
+}
+
+// KeepAlive calls Heartbeat every third of the lease, on the clock of the
+// lock's options, until ctx is done. The returned channel receives the
+// error of a failed heartbeat, e.g. ErrLockLost, and is closed when
+// KeepAlive stops.
+func (l *Lock) KeepAlive(ctx context.Context) <-chan error {
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-l.o.after(l.lease / 3):
+			}
+
+			if err := l.Heartbeat(ctx); err != nil {
+				if ctx.Err() == nil {
+					errc <- err
+				}
+
+				return
+			}
+		}
+	}()
+
+	return errc
+}
+
+// Release releases the lock, so the next owner needn't wait for the lease.
+// It fails with ErrLockLost if the lock was taken over.
+func (l *Lock) Release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	u := NewUpdate().Set("released", &dynamodb.AttributeValue{BOOL: aws.Bool(true)})
+	if err := l.write(ctx, u, l.rvnCondition(l.rvn)); err != nil {
+		return fmt.Errorf("ReleaseLock failed: %w", err)
+	}
+
+	return nil
+}
+
+// FenceCondition holds if the item's attr holds no fencing token newer than
+// token, for writes guarded by a Lock: write the token to attr along with
+// the item, so writes of a holder that was taken over are rejected.
+//
+//	item["fence"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(lock.Token, 10))}
+//	err := client.PutItem(ctx, item, libdy.WithCondition(libdy.FenceCondition("fence", lock.Token)))
+func FenceCondition(attr string, token int64) Condition {
+	return Condition{
+		Label:  "fence",
+		Expr:   "attribute_not_exists(#c0) OR #c0 <= :c0",
+		Names:  map[string]*string{"#c0": aws.String(attr)},
+		Values: map[string]*dynamodb.AttributeValue{":c0": {N: aws.String(strconv.FormatInt(token, 10))}},
+	}
+}
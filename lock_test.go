@@ -0,0 +1,132 @@
+package libdy_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/flowerinthenight/libdy"
+	"github.com/flowerinthenight/libdy/libdytest"
+)
+
+func TestLock(t *testing.T) {
+	ctx := context.Background()
+	f := libdytest.SetupFake(t, libdy.TableDef{Name: "t", PK: "id"}, libdy.TableDef{Name: "data", PK: "id"})
+	clock := libdy.NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	c := libdy.New(f, libdy.WithTable("t"), libdy.WithClock(clock), libdy.WithIDGen(counter()))
+	a, err := c.AcquireLock(ctx, "job", libdy.LockConfig{Owner: "a", Lease: time.Minute})
+	if err != nil || a.Token != 1 {
+		t.Fatalf("AcquireLock = %+v, %v, want token 1", a, err)
+	}
+
+	if _, err := c.AcquireLock(ctx, "job", libdy.LockConfig{Owner: "b"}); !errors.Is(err, libdy.ErrLockHeld) {
+		t.Fatalf("AcquireLock of a held lock: %v, want ErrLockHeld", err)
+	}
+
+	// b takes the lock over once a misses its heartbeats for a lease.
+	type acquired struct {
+		l   *libdy.Lock
+		err error
+	}
+
+	done := make(chan acquired, 1)
+	go func() {
+		l, err := c.AcquireLock(ctx, "job", libdy.LockConfig{Owner: "b", Wait: -1, Poll: time.Second})
+		done <- acquired{l, err}
+	}()
+
+	var b acquired
+	for deadline := time.Now().Add(5 * time.Second); b.l == nil && b.err == nil; {
+		select {
+		case b = <-done:
+		case <-time.After(time.Millisecond):
+			if time.Now().After(deadline) {
+				t.Fatal("no takeover of a stale lock")
+			}
+
+			clock.Advance(time.Second)
+		}
+	}
+
+	if b.err != nil || b.l.Token != 2 {
+		t.Fatalf("AcquireLock of a stale lock = %+v, %v, want token 2", b.l, b.err)
+	}
+
+	if err := a.Heartbeat(ctx); !errors.Is(err, libdy.ErrLockLost) {
+		t.Errorf("Heartbeat of a lost lock: %v, want ErrLockLost", err)
+	}
+
+	if err := a.Release(ctx); !errors.Is(err, libdy.ErrLockLost) {
+		t.Errorf("Release of a lost lock: %v, want ErrLockLost", err)
+	}
+
+	// Writes fenced by the token of a are rejected once b wrote.
+	write := func(l *libdy.Lock) error {
+		item := map[string]*dynamodb.AttributeValue{"id": {S: aws.String("x")}, "fence": {N: aws.String(fmt.Sprint(l.Token))}}
+		return c.PutItem(ctx, item, libdy.WithTable("data"), libdy.WithCondition(libdy.FenceCondition("fence", l.Token)))
+	}
+
+	if err := write(b.l); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := write(a); !errors.Is(err, libdy.ErrConditionFailed) {
+		t.Errorf("write fenced by a stale token: %v, want ErrConditionFailed", err)
+	}
+
+	// The same owner, e.g. after a restart, gets it right away.
+	again, err := c.AcquireLock(ctx, "job", libdy.LockConfig{Owner: "b"})
+	if err != nil || again.Token != 3 {
+		t.Fatalf("AcquireLock by the holder = %+v, %v, want token 3", again, err)
+	}
+
+	if err := again.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if a, err = c.AcquireLock(ctx, "job", libdy.LockConfig{Owner: "a"}); err != nil || a.Token != 4 {
+		t.Errorf("AcquireLock of a released lock = %+v, %v, want token 4", a, err)
+	}
+}
+
+func TestLockKeepAlive(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	f := libdytest.SetupFake(t, libdy.TableDef{Name: "t", PK: "id"})
+	clock := libdy.NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	c := libdy.New(f, libdy.WithTable("t"), libdy.WithClock(clock), libdy.WithIDGen(counter()))
+	l, err := c.AcquireLock(ctx, "job", libdy.LockConfig{Owner: "a", Lease: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rvn := func() string {
+		item, err := c.GetItem(ctx, "id:job", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return aws.StringValue(item["rvn"].S)
+	}
+
+	first := rvn()
+	errc := l.KeepAlive(ctx)
+
+	// Heartbeats follow the manual clock, not the system one.
+	for deadline := time.Now().Add(5 * time.Second); rvn() == first; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("no heartbeat after advancing past a third of the lease")
+		}
+
+		clock.Advance(20 * time.Minute)
+	}
+
+	cancel()
+	if err, ok := <-errc; ok {
+		t.Errorf("KeepAlive: %v", err)
+	}
+}
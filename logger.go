@@ -0,0 +1,19 @@
+package libdy
+
+// Logger receives the Client's diagnostic messages, such as retries. It is
+// satisfied by *log.Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// WithLogger sets the logger for diagnostic messages. The default is to log
+// nothing.
+func WithLogger(l Logger) Option {
+	return func(o *options) { o.logger = l }
+}
+
+func (o options) logf(format string, v ...interface{}) {
+	if o.logger != nil {
+		o.logger.Printf("libdy: "+format, v...)
+	}
+}
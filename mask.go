@@ -0,0 +1,255 @@
+package libdy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// MaskFunc returns the masked form of an attribute value.
+type MaskFunc func(v *dynamodb.AttributeValue) *dynamodb.AttributeValue
+
+// MaskProfile masks production data for non-production copies: each entry
+// maps an attribute name pattern to its MaskFunc. Like RedactPolicy,
+// patterns use path.Match syntax and are case-insensitive, and nested map
+// attributes are matched both by name and by dotted path. An exact name
+// takes precedence over patterns, which are tried in sorted order.
+//
+//	profile := libdy.MaskProfile{
+//		"email":   libdy.MaskEmail(salt),
+//		"*name":   libdy.MaskName(salt),
+//		"*token*": libdy.MaskNull,
+//		"ssn":     libdy.MaskHash(salt),
+//	}
+//
+//	stats, err := prod.CopyTable(ctx, libdy.CopyConfig{Dest: staging}, libdy.WithMask(profile))
+//
+// The built-in MaskFuncs are deterministic for a salt, so a value is masked
+// the same way in every item and table, and joins on masked attributes
+// still hold; keep the salt secret, as hashes of guessable values can be
+// reversed by trying them.
+type MaskProfile map[string]MaskFunc
+
+// WithMask makes Pipe, CopyTable, and ExportTable mask each item read per
+// p, before it is transformed or written anywhere, so the data never leaves
+// unmasked.
+func WithMask(p MaskProfile) Option {
+	return func(o *options) { o.mask = p }
+}
+
+// Mask returns a copy of item with the matching attributes masked. The
+// input item is not modified.
+func (p MaskProfile) Mask(item map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	if len(p) == 0 || item == nil {
+		return item
+	}
+
+	patterns := make([]string, 0, len(p))
+	lower := make(map[string]MaskFunc, len(p))
+	for k, fn := range p {
+		k = strings.ToLower(k)
+		patterns = append(patterns, k)
+		lower[k] = fn
+	}
+
+	sort.Strings(patterns)
+	match := func(names ...string) MaskFunc {
+		for _, name := range names {
+			if fn, ok := lower[strings.ToLower(name)]; ok {
+				return fn
+			}
+		}
+
+		for _, pat := range patterns {
+			for _, name := range names {
+				if ok, _ := path.Match(pat, strings.ToLower(name)); ok {
+					return lower[pat]
+				}
+			}
+		}
+
+		return nil
+	}
+
+	var maskMap func(prefix string, m map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue
+	maskMap = func(prefix string, m map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+		ret := make(map[string]*dynamodb.AttributeValue, len(m))
+		for k, v := range m {
+			full := k
+			if prefix != "" {
+				full = prefix + "." + k
+			}
+
+			if fn := match(k, full); fn != nil {
+				ret[k] = fn(v)
+			} else if v != nil && v.M != nil {
+				ret[k] = &dynamodb.AttributeValue{M: maskMap(full, v.M)}
+			} else {
+				ret[k] = v
+			}
+		}
+
+		return ret
+	}
+
+	return maskMap("", item)
+}
+
+// Transform returns the profile as a read pipeline stage.
+func (p MaskProfile) Transform() Transform {
+	return func(item map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+		return p.Mask(item), nil
+	}
+}
+
+// MaskNull replaces the value with NULL, e.g. for tokens and secrets.
+func MaskNull(*dynamodb.AttributeValue) *dynamodb.AttributeValue {
+	return &dynamodb.AttributeValue{NULL: aws.Bool(true)}
+}
+
+// MaskHash replaces the value with a keyed hash of it under salt: strings
+// with 32 hex digits, numbers with a number, binaries with 16 bytes, and
+// the elements of sets, lists, and maps each in turn. Other values become
+// NULL.
+func MaskHash(salt string) MaskFunc {
+	return maskEach(func(v *dynamodb.AttributeValue) *dynamodb.AttributeValue {
+		switch {
+		case v.S != nil:
+			return &dynamodb.AttributeValue{S: aws.String(hex.EncodeToString(maskSum(salt, "S", *v.S)[:16]))}
+		case v.N != nil:
+			n := binary.BigEndian.Uint64(maskSum(salt, "N", *v.N)) >> 11 // exact as a float64, too
+			return &dynamodb.AttributeValue{N: aws.String(strconv.FormatUint(n, 10))}
+		case v.B != nil:
+			return &dynamodb.AttributeValue{B: maskSum(salt, "B", string(v.B))[:16]}
+		}
+
+		return &dynamodb.AttributeValue{NULL: aws.Bool(true)}
+	})
+}
+
+// MaskEmail replaces an email address with a hash of it under salt at
+// example.com, which is still a valid, unique address that can't be
+// delivered to. Other values are masked as with MaskHash.
+func MaskEmail(salt string) MaskFunc {
+	hash := MaskHash(salt)
+	return maskEach(func(v *dynamodb.AttributeValue) *dynamodb.AttributeValue {
+		if v.S == nil {
+			return hash(v)
+		}
+
+		local := hex.EncodeToString(maskSum(salt, "email", strings.ToLower(*v.S))[:8])
+		return &dynamodb.AttributeValue{S: aws.String(local + "@example.com")}
+	})
+}
+
+var (
+	maskFirstNames = []string{
+		"Alex", "Blake", "Casey", "Dana", "Elliot", "Frankie", "Gray", "Harper",
+		"Indy", "Jordan", "Kai", "Lee", "Morgan", "Noel", "Oakley", "Parker",
+		"Quinn", "Reese", "Sam", "Taylor", "Uma", "Val", "Wren", "Yael",
+	}
+
+	maskLastNames = []string{
+		"Abbott", "Brooks", "Carter", "Dalton", "Ellis", "Foster", "Garcia", "Hayes",
+		"Ingram", "Jensen", "Keller", "Lambert", "Moreno", "Nolan", "Owens", "Price",
+		"Quinlan", "Rivera", "Santos", "Tanaka", "Upton", "Vance", "Walsh", "Young",
+	}
+)
+
+// MaskName replaces a name with a made-up "First Last" one, picked by a
+// hash of it under salt, so the same name is always replaced the same way.
+// Other values are masked as with MaskHash.
+func MaskName(salt string) MaskFunc {
+	hash := MaskHash(salt)
+	return maskEach(func(v *dynamodb.AttributeValue) *dynamodb.AttributeValue {
+		if v.S == nil {
+			return hash(v)
+		}
+
+		sum := maskSum(salt, "name", *v.S)
+		first := maskFirstNames[binary.BigEndian.Uint32(sum)%uint32(len(maskFirstNames))]
+		last := maskLastNames[binary.BigEndian.Uint32(sum[4:])%uint32(len(maskLastNames))]
+		return &dynamodb.AttributeValue{S: aws.String(first + " " + last)}
+	})
+}
+
+// maskSum returns the HMAC-SHA256 of kind and s under salt.
+func maskSum(salt, kind, s string) []byte {
+	h := hmac.New(sha256.New, []byte(salt))
+	h.Write([]byte(kind + "\x00" + s))
+	return h.Sum(nil)
+}
+
+// maskEach returns a MaskFunc applying fn to scalars, and to the elements of
+// sets, lists, and maps. Sets stay sets, so masked elements that collide
+// are merged.
+func maskEach(fn MaskFunc) MaskFunc {
+	var each MaskFunc
+	each = func(v *dynamodb.AttributeValue) *dynamodb.AttributeValue {
+		if v == nil || v.NULL != nil {
+			return v
+		}
+
+		set := func(vals []*string, wrap func(s *string) *dynamodb.AttributeValue, get func(*dynamodb.AttributeValue) *string) []*string {
+			seen := map[string]bool{}
+			var ret []*string
+			for _, s := range vals {
+				m := get(fn(wrap(s)))
+				if m != nil && !seen[*m] {
+					seen[*m] = true
+					ret = append(ret, m)
+				}
+			}
+
+			return ret
+		}
+
+		switch {
+		case v.SS != nil:
+			return &dynamodb.AttributeValue{SS: set(v.SS,
+				func(s *string) *dynamodb.AttributeValue { return &dynamodb.AttributeValue{S: s} },
+				func(m *dynamodb.AttributeValue) *string { return m.S })}
+		case v.NS != nil:
+			return &dynamodb.AttributeValue{NS: set(v.NS,
+				func(s *string) *dynamodb.AttributeValue { return &dynamodb.AttributeValue{N: s} },
+				func(m *dynamodb.AttributeValue) *string { return m.N })}
+		case v.BS != nil:
+			seen := map[string]bool{}
+			var ret [][]byte
+			for _, b := range v.BS {
+				if m := fn(&dynamodb.AttributeValue{B: b}).B; m != nil && !seen[string(m)] {
+					seen[string(m)] = true
+					ret = append(ret, m)
+				}
+			}
+
+			return &dynamodb.AttributeValue{BS: ret}
+		case v.L != nil:
+			ret := make([]*dynamodb.AttributeValue, len(v.L))
+			for i, e := range v.L {
+				ret[i] = each(e)
+			}
+
+			return &dynamodb.AttributeValue{L: ret}
+		case v.M != nil:
+			ret := make(map[string]*dynamodb.AttributeValue, len(v.M))
+			for k, e := range v.M {
+				ret[k] = each(e)
+			}
+
+			return &dynamodb.AttributeValue{M: ret}
+		}
+
+		return fn(v)
+	}
+
+	return each
+}
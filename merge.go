@@ -0,0 +1,225 @@
+package libdy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// MergeRule is how MergeUpdate combines a patched attribute with the stored
+// one. Attributes not stored yet take the patch value under any rule.
+type MergeRule int
+
+const (
+	MergeOverwrite MergeRule = iota // the patch value replaces the stored one
+	MergeKeep                       // the stored value is kept
+	MergeMax                        // the larger of the two (N, S, or B)
+	MergeMin                        // the smaller of the two (N, S, or B)
+	MergeSum                        // the sum of the two (N)
+	MergeUnion                      // the union of the two (SS, NS, BS, or L)
+)
+
+// MergePolicy is the MergeRule of each attribute, by name. Attributes not
+// listed are overwritten.
+type MergePolicy map[string]MergeRule
+
+// Merge is the MergeFunc of p: each attribute of new is combined with that
+// of old per its rule, and the other attributes of old are kept.
+func (p MergePolicy) Merge(old, new map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+	ret := make(map[string]*dynamodb.AttributeValue, len(old)+len(new))
+	for k, v := range old {
+		ret[k] = v
+	}
+
+	for k, v := range new {
+		if old[k] == nil {
+			ret[k] = v
+			continue
+		}
+
+		merged, err := mergeValue(p[k], old[k], v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+
+		ret[k] = merged
+	}
+
+	return ret, nil
+}
+
+func MergeUpdate(svc dynamodbiface.DynamoDBAPI, table, pk, sk string, patch map[string]*dynamodb.AttributeValue, policy MergePolicy, opts ...Option) (map[string]*dynamodb.AttributeValue, error) {
+	return MergeUpdateWithContext(context.Background(), svc, table, pk, sk, patch, policy, opts...)
+}
+
+// MergeUpdateWithContext is Client.MergeUpdate for table.
+func MergeUpdateWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, pk, sk string, patch map[string]*dynamodb.AttributeValue, policy MergePolicy, opts ...Option) (map[string]*dynamodb.AttributeValue, error) {
+	return New(svc, WithTable(table)).MergeUpdate(ctx, pk, sk, patch, policy, opts...)
+}
+
+// MergeUpdate applies patch, a set of attributes, to the item with key pk and
+// sk, combining each with the stored attribute per policy, e.g. to keep the
+// highest score, count views, and collect tags from concurrent writers:
+//
+//	item, err := client.MergeUpdate(ctx, "id:page1", "", patch, libdy.MergePolicy{
+//		"best":  libdy.MergeMax,
+//		"views": libdy.MergeSum,
+//		"tags":  libdy.MergeUnion,
+//	})
+//
+// It is an Upsert with policy.Merge, so the read-merge-write is retried if
+// the item changed meanwhile, and the item is created from patch (and the
+// key) if it doesn't exist. It returns the item stored.
+func (c *Client) MergeUpdate(ctx context.Context, pk, sk string, patch map[string]*dynamodb.AttributeValue, policy MergePolicy, opts ...Option) (map[string]*dynamodb.AttributeValue, error) {
+	item := make(map[string]*dynamodb.AttributeValue, len(patch)+2)
+	for k, v := range patch {
+		item[k] = v
+	}
+
+	for k, v := range keyMap(ParseKey(pk), ParseKey(sk)) {
+		item[k] = v
+	}
+
+	ret, err := c.Upsert(ctx, pk, sk, item, policy.Merge, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("MergeUpdate failed: %w", err)
+	}
+
+	return ret, nil
+}
+
+// mergeValue combines cur, the stored value, and patch, both set, per rule.
+func mergeValue(rule MergeRule, cur, patch *dynamodb.AttributeValue) (*dynamodb.AttributeValue, error) {
+	switch rule {
+	case MergeOverwrite:
+		return patch, nil
+	case MergeKeep:
+		return cur, nil
+	case MergeMax, MergeMin:
+		cmp, err := compareValues(cur, patch)
+		if err != nil {
+			return nil, err
+		}
+
+		if (rule == MergeMax) == (cmp >= 0) {
+			return cur, nil
+		}
+
+		return patch, nil
+	case MergeSum:
+		a, aok := new(big.Rat).SetString(aws.StringValue(cur.N))
+		b, bok := new(big.Rat).SetString(aws.StringValue(patch.N))
+		if cur.N == nil || patch.N == nil || !aok || !bok {
+			return nil, fmt.Errorf("cannot sum %s and %s", attrType(cur), attrType(patch))
+		}
+
+		return &dynamodb.AttributeValue{N: aws.String(formatRat(a.Add(a, b)))}, nil
+	case MergeUnion:
+		return unionValues(cur, patch)
+	}
+
+	return nil, fmt.Errorf("unknown merge rule %d", rule)
+}
+
+// compareValues compares two N, S, or B values of the same type.
+func compareValues(a, b *dynamodb.AttributeValue) (int, error) {
+	switch {
+	case a.N != nil && b.N != nil:
+		x, xok := new(big.Rat).SetString(*a.N)
+		y, yok := new(big.Rat).SetString(*b.N)
+		if !xok || !yok {
+			return 0, fmt.Errorf("invalid number %s or %s", *a.N, *b.N)
+		}
+
+		return x.Cmp(y), nil
+	case a.S != nil && b.S != nil:
+		return strings.Compare(*a.S, *b.S), nil
+	case a.B != nil && b.B != nil:
+		return bytes.Compare(a.B, b.B), nil
+	}
+
+	return 0, fmt.Errorf("cannot compare %s and %s", attrType(a), attrType(b))
+}
+
+// unionValues returns the union of two sets, or the elements of list a and
+// those of b not in a, of the same type.
+func unionValues(a, b *dynamodb.AttributeValue) (*dynamodb.AttributeValue, error) {
+	union := func(x, y []*string) []*string {
+		seen := make(map[string]bool, len(x))
+		ret := append([]*string{}, x...)
+		for _, v := range x {
+			seen[aws.StringValue(v)] = true
+		}
+
+		for _, v := range y {
+			if !seen[aws.StringValue(v)] {
+				seen[aws.StringValue(v)] = true
+				ret = append(ret, v)
+			}
+		}
+
+		return ret
+	}
+
+	switch {
+	case a.SS != nil && b.SS != nil:
+		return &dynamodb.AttributeValue{SS: union(a.SS, b.SS)}, nil
+	case a.NS != nil && b.NS != nil:
+		return &dynamodb.AttributeValue{NS: union(a.NS, b.NS)}, nil
+	case a.BS != nil && b.BS != nil:
+		ret := append([][]byte{}, a.BS...)
+		for _, v := range b.BS {
+			dup := false
+			for _, w := range ret {
+				dup = dup || bytes.Equal(v, w)
+			}
+
+			if !dup {
+				ret = append(ret, v)
+			}
+		}
+
+		return &dynamodb.AttributeValue{BS: ret}, nil
+	case a.L != nil && b.L != nil:
+		ret := append([]*dynamodb.AttributeValue{}, a.L...)
+		for _, v := range b.L {
+			dup := false
+			for _, w := range ret {
+				dup = dup || reflect.DeepEqual(v, w)
+			}
+
+			if !dup {
+				ret = append(ret, v)
+			}
+		}
+
+		return &dynamodb.AttributeValue{L: ret}, nil
+	}
+
+	return nil, fmt.Errorf("cannot union %s and %s", attrType(a), attrType(b))
+}
+
+// formatRat formats r, the sum of decimal numbers, exactly.
+func formatRat(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+
+	// The denominator divides a power of ten; find the smallest.
+	ten := big.NewInt(10)
+	p := big.NewInt(10)
+	digits := 1
+	for new(big.Int).Mod(p, r.Denom()).Sign() != 0 && digits < 200 {
+		p.Mul(p, ten)
+		digits++
+	}
+
+	return strings.TrimRight(r.FloatString(digits), "0")
+}
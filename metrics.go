@@ -0,0 +1,175 @@
+package libdy
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metric names reported through the Metrics hook.
+const (
+	// MetricConditionFailed counts conditional writes rejected by DynamoDB.
+	// Labels: table, condition.
+	MetricConditionFailed = "libdy_condition_failed_total"
+
+	// MetricRetryStorm counts the retry storms detected by a RetryStorm.
+	// Labels: table.
+	MetricRetryStorm = "libdy_retry_storm_total"
+
+	// MetricIngestWritten counts the items written by Ingest. Labels: table.
+	MetricIngestWritten = "libdy_ingest_written_total"
+
+	// MetricIngestDeadLettered counts the messages Ingest gave up on. Labels:
+	// table.
+	MetricIngestDeadLettered = "libdy_ingest_dead_lettered_total"
+
+	// MetricCacheHits counts the GetItem reads served from an ItemCache.
+	// Labels: table.
+	MetricCacheHits = "libdy_cache_hits_total"
+
+	// MetricCacheMisses counts the GetItem reads an ItemCache passed on to
+	// DynamoDB. Labels: table.
+	MetricCacheMisses = "libdy_cache_misses_total"
+)
+
+// Metrics receives libdy's counters. Implementations must be safe for
+// concurrent use.
+type Metrics interface {
+	Count(name string, labels map[string]string, n int64)
+}
+
+// WithMetrics sets the hook that receives the Client's counters.
+func WithMetrics(m Metrics) Option {
+	return func(o *options) { o.metrics = m }
+}
+
+// count reports n to the metrics hook, if any.
+func (o options) count(name string, labels map[string]string, n int64) {
+	if o.metrics != nil {
+		o.metrics.Count(name, labels, n)
+	}
+}
+
+// metricKey returns a stable key for name and labels, like
+// `name{a="1",b="2"}`.
+func metricKey(name string, labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+
+		b.WriteString(k + "=\"" + labels[k] + "\"")
+	}
+
+	b.WriteByte('}')
+	return b.String()
+}
+
+// Counters is an in-process Metrics implementation, for services without
+// external metrics plumbing.
+type Counters struct {
+	mu sync.Mutex
+	m  map[string]int64
+}
+
+func (c *Counters) Count(name string, labels map[string]string, n int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.m == nil {
+		c.m = map[string]int64{}
+	}
+
+	c.m[metricKey(name, labels)] += n
+}
+
+// Get returns the current value of the named counter with exactly labels.
+func (c *Counters) Get(name string, labels map[string]string) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.m[metricKey(name, labels)]
+}
+
+// Snapshot returns a copy of all counters, keyed like `name{a="1",b="2"}`.
+func (c *Counters) Snapshot() map[string]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ret := make(map[string]int64, len(c.m))
+	for k, v := range c.m {
+		ret[k] = v
+	}
+
+	return ret
+}
+
+// Alert is a Metrics hook that calls Fn whenever a counter named Name (per
+// label set) reaches Threshold within Window, then starts counting afresh.
+// Everything is forwarded to Next, if set.
+//
+//	alert := &libdy.Alert{
+//		Name:      libdy.MetricConditionFailed,
+//		Threshold: 100,
+//		Window:    time.Minute,
+//		Fn:        func(labels map[string]string, n int64) { ... },
+//		Next:      promHook,
+//	}
+type Alert struct {
+	Name      string
+	Threshold int64
+	Window    time.Duration
+	Fn        func(labels map[string]string, n int64)
+	Next      Metrics
+
+	mu      sync.Mutex
+	windows map[string]*alertWindow
+}
+
+type alertWindow struct {
+	start time.Time
+	n     int64
+}
+
+func (a *Alert) Count(name string, labels map[string]string, n int64) {
+	if a.Next != nil {
+		a.Next.Count(name, labels, n)
+	}
+
+	if name != a.Name || a.Fn == nil {
+		return
+	}
+
+	key := metricKey(name, labels)
+	now := time.Now()
+	a.mu.Lock()
+	if a.windows == nil {
+		a.windows = map[string]*alertWindow{}
+	}
+
+	w, ok := a.windows[key]
+	if !ok || (a.Window > 0 && now.Sub(w.start) > a.Window) {
+		w = &alertWindow{start: now}
+		a.windows[key] = w
+	}
+
+	w.n += n
+	fire := w.n >= a.Threshold
+	total := w.n
+	if fire {
+		delete(a.windows, key)
+	}
+
+	a.mu.Unlock()
+
+	if fire {
+		a.Fn(labels, total)
+	}
+}
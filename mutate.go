@@ -0,0 +1,186 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+var (
+	ErrMutateContention = errors.New("libdy: mutate lost to concurrent writes")
+)
+
+// MutateFunc returns the item to store given current, the stored item, or
+// nil if there is none. Returning nil deletes the item (or, if there is
+// none, leaves it so). It may modify the current map, but not the values in
+// it, and may be called more than once per Mutate.
+type MutateFunc func(current map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error)
+
+// WithConflictTries sets the rounds of read, modify, and conditional write
+// of Mutate and Upsert before they give up on an item that keeps changing;
+// the default is 10.
+func WithConflictTries(n int) Option {
+	return func(o *options) { o.tries = n }
+}
+
+func (o options) conflictTries() int {
+	if o.tries > 0 {
+		return o.tries
+	}
+
+	return upsertMaxTries
+}
+
+func Mutate(svc dynamodbiface.DynamoDBAPI, table, pk, sk string, fn MutateFunc, opts ...Option) (map[string]*dynamodb.AttributeValue, error) {
+	return MutateWithContext(context.Background(), svc, table, pk, sk, fn, opts...)
+}
+
+// MutateWithContext is Client.Mutate for table.
+func MutateWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, pk, sk string, fn MutateFunc, opts ...Option) (map[string]*dynamodb.AttributeValue, error) {
+	return New(svc, WithTable(table)).Mutate(ctx, pk, sk, fn, opts...)
+}
+
+// Mutate reads the item with the key pk and sk, applies fn, and writes the
+// result back on the condition that the item didn't change meanwhile,
+// starting over with the new item if it did, so no concurrent write is
+// lost:
+//
+//	item, err := client.Mutate(ctx, "id:cart1", "", func(cur map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+//		if cur == nil {
+//			return nil, errNoCart
+//		}
+//
+//		cur["items"] = addItem(cur["items"], sku)
+//		return cur, nil
+//	})
+//
+// Only the attributes that changed are written, or with WithCompression,
+// WithEncryption, or WithOffload, the whole item, encoded. It returns the
+// item stored, nil if deleted, and fails with ErrMutateContention if the
+// item kept changing (see WithConflictTries), or with the error of fn, as
+// is. The key attributes must not change. WithCondition is ignored.
+//
+// fn gets the item decoded by those codecs, but not through the read
+// pipeline, nor WithAuthorizer, which may drop or change attributes that
+// would then be written back.
+//
+// The condition compares the attributes read, and those fn adds, so another
+// attribute added meanwhile by another writer is not a conflict (and is
+// lost, if the whole item is written); keep a version attribute (see
+// UpdateItemVersioned) in items where that matters.
+func (c *Client) Mutate(ctx context.Context, pk, sk string, fn MutateFunc, opts ...Option) (map[string]*dynamodb.AttributeValue, error) {
+	o, err := c.apply(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	kp, ks, err := c.keys(ctx, o, pk, sk)
+	if err != nil {
+		return nil, fmt.Errorf("Mutate failed: %w", err)
+	}
+
+	key := keyMap(kp, ks)
+	keyAttrs := []string{kp.Name}
+	if ks.Name != "" {
+		keyAttrs = append(keyAttrs, ks.Name)
+	}
+
+	tries := o.conflictTries()
+	for i := 0; i < tries; i++ {
+		stored, cur, err := c.readStored(ctx, kp, ks, o)
+		if err != nil {
+			return nil, fmt.Errorf("Mutate failed: %w", err)
+		}
+
+		next, err := fn(copyItem(cur))
+		if err != nil {
+			return nil, err
+		}
+
+		if next != nil {
+			for _, k := range keyAttrs {
+				if next[k] == nil {
+					next[k] = key[k]
+				} else if !reflect.DeepEqual(next[k], key[k]) {
+					return nil, fmt.Errorf("Mutate failed: %w: key attribute %s changed", ErrInvalidRequest, k)
+				}
+			}
+		}
+
+		write := o // the caller's condition doesn't apply; each write sets its own
+		switch {
+		case cur == nil && next == nil:
+			return nil, nil
+		case cur == nil:
+			cond := IfNotExists(keyAttrs[0])
+			write.condition = &cond
+			_, err = c.putItem(ctx, next, write)
+		case next == nil:
+			cond := unchanged("mutate", stored, nil, keyAttrs)
+			write.condition = &cond
+			_, err = c.deleteItem(ctx, key, write)
+		default:
+			var wrote bool
+			if wrote, err = c.writeBack(ctx, kp, ks, stored, cur, next, keyAttrs, "mutate", write); err == nil && !wrote {
+				return cur, nil // nothing to change
+			}
+		}
+
+		switch {
+		case err == nil:
+			return next, nil
+		case !errors.Is(err, ErrConditionFailed):
+			return nil, fmt.Errorf("Mutate failed: %w", err)
+		}
+	}
+
+	return nil, fmt.Errorf("Mutate failed after %d tries: %w", tries, ErrMutateContention)
+}
+
+// readStored reads the item with the key pk and sk as stored, for the
+// condition of writing it back, and as decoded by the codecs of o
+// (WithOffload, WithEncryption, and WithCompression). Both are nil if there
+// is none.
+func (c *Client) readStored(ctx context.Context, pk, sk Key, o options) (map[string]*dynamodb.AttributeValue, map[string]*dynamodb.AttributeValue, error) {
+	o.consistent = true
+	stored, err := getItem(ctx, c.reader(o), keyMap(pk, sk), o)
+	switch {
+	case errors.Is(err, ErrItemNotFound):
+		return nil, nil, nil
+	case err != nil:
+		return nil, nil, err
+	}
+
+	item, err := o.decode(ctx, stored)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return stored, item, nil
+}
+
+// writeBack replaces the item stored, as read by readStored, and decoded as
+// cur, with next, on the condition, labeled label, that it is still stored:
+// with an update of the attributes that changed or, if o encodes items, a
+// put of the whole item, encoded. It reports whether anything changed.
+func (c *Client) writeBack(ctx context.Context, pk, sk Key, stored, cur, next map[string]*dynamodb.AttributeValue, keyAttrs []string, label string, o options) (bool, error) {
+	u := compareAndSet(cur, next, keyAttrs)
+	if u == nil {
+		return false, nil
+	}
+
+	cond := unchanged(label, stored, next, keyAttrs)
+	o.condition = &cond
+	var err error
+	if o.compression != nil || o.encryption != nil || o.offload != nil {
+		_, err = c.putItem(ctx, next, o)
+	} else {
+		_, err = c.updateItem(ctx, pk, sk, u, o)
+	}
+
+	return true, err
+}
@@ -0,0 +1,107 @@
+package libdy_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/flowerinthenight/libdy"
+	"github.com/flowerinthenight/libdy/libdytest"
+)
+
+func increment(cur map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+	if cur == nil {
+		cur = map[string]*dynamodb.AttributeValue{}
+	}
+
+	n := 0
+	if v := cur["n"]; v != nil {
+		n, _ = strconv.Atoi(aws.StringValue(v.N))
+	}
+
+	cur["n"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(n + 1))}
+	return cur, nil
+}
+
+func TestMutate(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name string
+		opts []libdy.Option
+	}{
+		{"plain", nil},
+		{"compressed", []libdy.Option{libdy.WithCompression(libdy.NewCompression(libdy.Gzip(0), "doc").WithMinSize(1))}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := libdytest.SetupFake(t, libdy.TableDef{Name: "t", PK: "id"})
+			c := libdy.New(f, append([]libdy.Option{libdy.WithTable("t")}, tc.opts...)...)
+			doc := &dynamodb.AttributeValue{S: aws.String(strings.Repeat("x", 100))}
+			if err := c.PutItem(ctx, map[string]*dynamodb.AttributeValue{"id": {S: aws.String("a")}, "doc": doc}); err != nil {
+				t.Fatal(err)
+			}
+
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if _, err := c.Mutate(ctx, "id:a", "", increment, libdy.WithConflictTries(100)); err != nil {
+						t.Error(err)
+					}
+				}()
+			}
+
+			wg.Wait()
+			item, err := c.GetItem(ctx, "id:a", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := aws.StringValue(item["n"].N); got != "10" {
+				t.Errorf("n = %s, want 10", got)
+			}
+
+			if got := aws.StringValue(item["doc"].S); got != aws.StringValue(doc.S) {
+				t.Errorf("doc = %q, want it unchanged", got)
+			}
+
+			merged, err := c.Upsert(ctx, "id:a", "", map[string]*dynamodb.AttributeValue{"id": {S: aws.String("a")}, "m": {S: aws.String("v")}}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if merged["doc"] == nil || merged["n"] == nil || merged["m"] == nil {
+				t.Errorf("Upsert = %v, want doc, n, and m", merged)
+			}
+
+			deleted, err := c.Mutate(ctx, "id:a", "", func(map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+				return nil, nil
+			})
+
+			if err != nil || deleted != nil {
+				t.Fatalf("Mutate delete = %v, %v", deleted, err)
+			}
+
+			if _, err := c.GetItem(ctx, "id:a", ""); !errors.Is(err, libdy.ErrItemNotFound) {
+				t.Errorf("GetItem after delete: %v, want ErrItemNotFound", err)
+			}
+		})
+	}
+}
+
+func TestMutateKeyChange(t *testing.T) {
+	f := libdytest.SetupFake(t, libdy.TableDef{Name: "t", PK: "id"})
+	c := libdy.New(f, libdy.WithTable("t"))
+	_, err := c.Mutate(context.Background(), "id:a", "", func(map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+		return map[string]*dynamodb.AttributeValue{"id": {S: aws.String("b")}}, nil
+	})
+
+	if !errors.Is(err, libdy.ErrInvalidRequest) {
+		t.Errorf("Mutate = %v, want ErrInvalidRequest", err)
+	}
+}
@@ -0,0 +1,162 @@
+package libdy
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Operation describes a finished DynamoDB request (one page, for Query and
+// Scan), including its retries. See WithOnOperation.
+type Operation struct {
+	Name             string // the DynamoDB API, e.g. GetItem or Query
+	Table            string
+	Page             int // of a Query or Scan, from 1; 0 for other requests
+	Attempts         int
+	Start            time.Time
+	Duration         time.Duration
+	ConsumedCapacity float64 // capacity units
+	Items            int     // items returned by reads
+	Err              error   // the final error, if any
+
+	ctx context.Context
+}
+
+// Context returns the context of the call making the request, e.g. to
+// record a span as a child of the call's (see the libdyotel subpackage).
+func (op Operation) Context() context.Context {
+	if op.ctx == nil {
+		return context.Background()
+	}
+
+	return op.ctx
+}
+
+// RetryAttempt describes a failed attempt that is about to be retried. See
+// WithOnRetry.
+type RetryAttempt struct {
+	Name    string // the operation, e.g. GetItem or Query
+	Table   string
+	Attempt int           // the attempt that failed, from 1
+	Wait    time.Duration // the backoff before the next attempt
+	Err     error
+}
+
+// WithOnOperation calls fn after every GetItem, PutItem, UpdateItem,
+// DeleteItem, Query, Scan, BatchGetItem, and BatchWriteItem request, for
+// metrics and tracing (see the libdyprom subpackage). fn runs on the
+// request's goroutine, so it must be quick. Hooks add up: those of a call
+// run after those of the Client, in order.
+func WithOnOperation(fn func(Operation)) Option {
+	return func(o *options) {
+		prev := o.onOp
+		if prev == nil {
+			o.onOp = fn
+			return
+		}
+
+		o.onOp = func(op Operation) {
+			prev(op)
+			fn(op)
+		}
+	}
+}
+
+// WithOnRetry calls fn for every retried attempt of any operation, including
+// throttling, instead of retrying silently. Retries are also logged to the
+// Logger (see WithLogger). Like WithOnOperation hooks, these add up.
+func WithOnRetry(fn func(RetryAttempt)) Option {
+	return func(o *options) {
+		prev := o.onRetry
+		if prev == nil {
+			o.onRetry = fn
+			return
+		}
+
+		o.onRetry = func(r RetryAttempt) {
+			prev(r)
+			fn(r)
+		}
+	}
+}
+
+// observe reports a request on table that started at start and took
+// attempts, with its output res, the retry error err, and the request error
+// rerr: to Latencies, and to the WithOnOperation hooks.
+func (o options) observe(ctx context.Context, name, table string, start time.Time, attempts int, res interface{}, err, rerr error) {
+	d := time.Since(start)
+	o.latencies.record(table, name, d)
+	if o.onOp == nil {
+		return
+	}
+
+	if err == nil {
+		err = rerr
+	}
+
+	op := Operation{
+		Name:     name,
+		Table:    table,
+		Page:     o.pageNum,
+		Attempts: attempts,
+		Start:    start,
+		Duration: d,
+		Err:      err,
+		ctx:      ctx,
+	}
+
+	op.ConsumedCapacity, op.Items = outputStats(res)
+	o.onOp(op)
+}
+
+// outputStats returns the capacity units consumed by the request with output
+// res, or 0 if unknown, and the number of items it returned.
+func outputStats(res interface{}) (float64, int) {
+	switch v := res.(type) {
+	case *dynamodb.GetItemOutput:
+		if v != nil {
+			n := 0
+			if v.Item != nil {
+				n = 1
+			}
+
+			return capacityUnits(v.ConsumedCapacity), n
+		}
+	case *dynamodb.PutItemOutput:
+		if v != nil {
+			return capacityUnits(v.ConsumedCapacity), 0
+		}
+	case *dynamodb.UpdateItemOutput:
+		if v != nil {
+			return capacityUnits(v.ConsumedCapacity), 0
+		}
+	case *dynamodb.DeleteItemOutput:
+		if v != nil {
+			return capacityUnits(v.ConsumedCapacity), 0
+		}
+	case *dynamodb.QueryOutput:
+		if v != nil {
+			return capacityUnits(v.ConsumedCapacity), len(v.Items)
+		}
+	case *dynamodb.ScanOutput:
+		if v != nil {
+			return capacityUnits(v.ConsumedCapacity), len(v.Items)
+		}
+	case *dynamodb.BatchGetItemOutput:
+		if v != nil {
+			n := 0
+			for _, items := range v.Responses {
+				n += len(items)
+			}
+
+			return capacityUnits(v.ConsumedCapacity...), n
+		}
+	case *dynamodb.BatchWriteItemOutput:
+		if v != nil {
+			return capacityUnits(v.ConsumedCapacity...), 0
+		}
+	}
+
+	return 0, 0
+}
@@ -0,0 +1,304 @@
+package libdy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+const (
+	offloadThreshold = 350 << 10  // leaves room under the 400KB item limit
+	offloadPointer   = "libdy_s3" // the default pointer attribute
+	offloadScheme    = "s3://"
+)
+
+// Offload stores the large attributes of items in S3, so items over the
+// 400KB limit of DynamoDB can still be written: while an item is over the
+// threshold, its largest attribute is moved to an S3 object, and a pointer
+// attribute maps the attributes moved to their objects. Reads fetch them
+// back.
+//
+//	off := libdy.NewOffload(s3Svc, "app-blobs", "items/")
+//	client := libdy.New(svc, libdy.WithTable("docs"), libdy.WithOffload(off))
+//
+// Key attributes are never moved. Objects are not deleted when their item
+// is overwritten or deleted; expire them with an S3 lifecycle rule longer
+// than your reads can take. Projections must include the pointer attribute
+// for reads to fetch the attributes back.
+type Offload struct {
+	svc       s3iface.S3API
+	bucket    string
+	prefix    string
+	threshold int
+	pointer   string
+
+	mu       sync.Mutex
+	keyAttrs map[string][]string // key attribute names by table
+}
+
+func NewOffload(svc s3iface.S3API, bucket, prefix string) *Offload {
+	return &Offload{
+		svc:       svc,
+		bucket:    bucket,
+		prefix:    prefix,
+		threshold: offloadThreshold,
+		pointer:   offloadPointer,
+		keyAttrs:  map[string][]string{},
+	}
+}
+
+// WithThreshold sets the item size in bytes above which f moves attributes
+// to S3, 350KB by default, and returns f.
+func (f *Offload) WithThreshold(n int) *Offload {
+	f.threshold = n
+	return f
+}
+
+// WithPointerAttr sets the pointer attribute of f, "libdy_s3" by default,
+// and returns f.
+func (f *Offload) WithPointerAttr(attr string) *Offload {
+	f.pointer = attr
+	return f
+}
+
+// WithOffload moves the large attributes of the items written by PutItem
+// and the other puts listed by WithEncryption to S3 per f, after any encryption, and fetches them
+// back in the items read, before any decryption and the read pipeline.
+func WithOffload(f *Offload) Option {
+	return func(o *options) { o.offload = f }
+}
+
+// tableKeys returns the key attributes of table, which f never moves.
+func (f *Offload) tableKeys(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string) ([]string, error) {
+	f.mu.Lock()
+	keys, ok := f.keyAttrs[table]
+	f.mu.Unlock()
+	if ok {
+		return keys, nil
+	}
+
+	keys, err := tableKeyAttrs(ctx, svc, table)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	f.keyAttrs[table] = keys
+	f.mu.Unlock()
+	return keys, nil
+}
+
+// offload returns item with its largest attributes moved to S3 until it's
+// under the threshold. item itself is not modified.
+func (f *Offload) offload(ctx context.Context, svc dynamodbiface.DynamoDBAPI, o options, item map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+	size := itemSize(item)
+	if size <= f.threshold {
+		return item, nil
+	}
+
+	keys, err := f.tableKeys(ctx, svc, o.table)
+	if err != nil {
+		return nil, err
+	}
+
+	// Largest first; by name among equals, to be deterministic.
+	names := make([]string, 0, len(item))
+	for name := range item {
+		if !slices.Contains(keys, name) && name != f.pointer {
+			names = append(names, name)
+		}
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		si, sj := attrSize(item[names[i]]), attrSize(item[names[j]])
+		if si != sj {
+			return si > sj
+		}
+
+		return names[i] < names[j]
+	})
+
+	ret := copyItem(item)
+	pointers := map[string]*dynamodb.AttributeValue{}
+	for _, name := range names {
+		if size <= f.threshold {
+			break
+		}
+
+		b, err := json.Marshal(typedJSON(item[name]))
+		if err != nil {
+			return nil, err
+		}
+
+		key := fmt.Sprintf("%s%s/%s", f.prefix, o.table, o.newID())
+		if err := f.put(ctx, key, b); err != nil {
+			return nil, err
+		}
+
+		delete(ret, name)
+		pointers[name] = &dynamodb.AttributeValue{S: aws.String(offloadScheme + f.bucket + "/" + key)}
+		size -= len(name) + attrSize(item[name])
+	}
+
+	ret[f.pointer] = &dynamodb.AttributeValue{M: pointers}
+	size += itemSize(map[string]*dynamodb.AttributeValue{f.pointer: ret[f.pointer]})
+	if size > f.threshold {
+		return nil, fmt.Errorf("item still %d bytes with all attributes offloaded", size)
+	}
+
+	return ret, nil
+}
+
+func (f *Offload) put(ctx context.Context, key string, b []byte) error {
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(f.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(b),
+		ContentType: aws.String("application/json"),
+	}
+
+	var rerr error
+	op := func(ctx context.Context) error {
+		_, rerr = f.svc.PutObjectWithContext(ctx, input)
+		return (options{}).retriable(rerr)
+	}
+
+	if err := retry(ctx, options{}, "PutObject", op); err != nil {
+		return fmt.Errorf("PutObject failed: %w", err)
+	}
+
+	if rerr != nil {
+		return fmt.Errorf("PutObject failed: %w", rerr)
+	}
+
+	return nil
+}
+
+func (f *Offload) get(ctx context.Context, url string) ([]byte, error) {
+	bucket, key, ok := strings.Cut(strings.TrimPrefix(url, offloadScheme), "/")
+	if !ok || !strings.HasPrefix(url, offloadScheme) {
+		return nil, fmt.Errorf("invalid pointer %q", url)
+	}
+
+	input := &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)}
+	var rerr error
+	var res *s3.GetObjectOutput
+	op := func(ctx context.Context) error {
+		res, rerr = f.svc.GetObjectWithContext(ctx, input)
+		return (options{}).retriable(rerr)
+	}
+
+	if err := retry(ctx, options{}, "GetObject", op); err != nil {
+		return nil, fmt.Errorf("GetObject failed: %w", err)
+	}
+
+	if rerr != nil {
+		return nil, fmt.Errorf("GetObject failed: %w", rerr)
+	}
+
+	defer res.Body.Close()
+	return io.ReadAll(res.Body)
+}
+
+// rehydrate fetches the attributes of item moved to S3 back, with ctx.
+func (f *Offload) rehydrate(ctx context.Context, item map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+	ptr, ok := item[f.pointer]
+	if !ok || ptr.M == nil {
+		return item, nil
+	}
+
+	ret := copyItem(item)
+	delete(ret, f.pointer)
+	for name, url := range ptr.M {
+		b, err := f.get(ctx, aws.StringValue(url.S))
+		if err != nil {
+			return nil, fmt.Errorf("rehydrate failed: %s: %w", name, err)
+		}
+
+		if ret[name], err = parseTypedJSON(b); err != nil {
+			return nil, fmt.Errorf("rehydrate failed: %s: %w", name, err)
+		}
+	}
+
+	return ret, nil
+}
+
+// encode returns item as stored: compressed per WithCompression, encrypted
+// per WithEncryption, then offloaded per WithOffload, and checked per
+// WithSizeCheck. item itself is not modified.
+func (o options) encode(ctx context.Context, svc dynamodbiface.DynamoDBAPI, item map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+	if o.compression != nil {
+		var err error
+		if item, err = o.compression.compress(item); err != nil {
+			return nil, fmt.Errorf("compress failed: %w", err)
+		}
+	}
+
+	item, err := o.encrypt(ctx, svc, item)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.offload != nil {
+		if item, err = o.offload.offload(ctx, svc, o, item); err != nil {
+			return nil, fmt.Errorf("offload failed: %w", err)
+		}
+	}
+
+	if err := o.sizeCheck.check(ctx, svc, o.table, item); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// encodeAll is encode for a batch of items.
+func (o options) encodeAll(ctx context.Context, svc dynamodbiface.DynamoDBAPI, items []map[string]*dynamodb.AttributeValue) ([]map[string]*dynamodb.AttributeValue, error) {
+	if o.compression == nil && o.encryption == nil && o.offload == nil && o.sizeCheck == nil {
+		return items, nil
+	}
+
+	ret := make([]map[string]*dynamodb.AttributeValue, len(items))
+	for i, item := range items {
+		var err error
+		if ret[i], err = o.encode(ctx, svc, item); err != nil {
+			return nil, err
+		}
+	}
+
+	return ret, nil
+}
+
+// decode returns item as read: offloaded attributes fetched back, then
+// decrypted and decompressed. It runs before the read pipeline (see read),
+// and alone on the items not read through it.
+func (o options) decode(ctx context.Context, item map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+	if item == nil {
+		return nil, nil
+	}
+
+	var err error
+	if o.offload != nil {
+		if item, err = o.offload.rehydrate(ctx, item); err != nil {
+			return nil, err
+		}
+	}
+
+	if item, err = o.decrypt(ctx, item); err != nil || o.compression == nil {
+		return item, err
+	}
+
+	return o.compression.decompress(item)
+}
@@ -0,0 +1,87 @@
+package libdy_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/flowerinthenight/libdy"
+	"github.com/flowerinthenight/libdy/libdytest"
+)
+
+type ctxKey struct{}
+
+// fakeS3 stores objects in memory, and records the ctxKey value of the
+// contexts of the gets.
+type fakeS3 struct {
+	s3iface.S3API
+	mu      sync.Mutex
+	objects map[string][]byte
+	gets    []interface{}
+}
+
+func (f *fakeS3) PutObjectWithContext(_ aws.Context, in *s3.PutObjectInput, _ ...request.Option) (*s3.PutObjectOutput, error) {
+	b, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects[aws.StringValue(in.Key)] = b
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakeS3) GetObjectWithContext(ctx aws.Context, in *s3.GetObjectInput, _ ...request.Option) (*s3.GetObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.gets = append(f.gets, ctx.Value(ctxKey{}))
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(f.objects[aws.StringValue(in.Key)]))}, nil
+}
+
+func TestOffload(t *testing.T) {
+	f := libdytest.SetupFake(t, libdy.TableDef{Name: "t", PK: "id"})
+	s := &fakeS3{objects: map[string][]byte{}}
+	c := libdy.New(f, libdy.WithTable("t"), libdy.WithOffload(libdy.NewOffload(s, "bucket", "items/").WithThreshold(100)))
+	doc := strings.Repeat("x", 200)
+	item := map[string]*dynamodb.AttributeValue{"id": {S: aws.String("a")}, "doc": {S: aws.String(doc)}}
+	if err := c.PutItem(context.Background(), item); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.objects) != 1 {
+		t.Fatalf("%d objects, want 1", len(s.objects))
+	}
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "get")
+	got, err := c.GetItem(ctx, "id:a", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if aws.StringValue(got["doc"].S) != doc {
+		t.Errorf("doc not rehydrated: %v", got)
+	}
+
+	ctx = context.WithValue(context.Background(), ctxKey{}, "query")
+	res, err := c.Query(ctx, "id:a", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Items) != 1 || aws.StringValue(res.Items[0]["doc"].S) != doc {
+		t.Errorf("Query = %v, want the item rehydrated", res.Items)
+	}
+
+	if want := []interface{}{"get", "query"}; len(s.gets) != 2 || s.gets[0] != want[0] || s.gets[1] != want[1] {
+		t.Errorf("GetObject contexts = %v, want %v", s.gets, want)
+	}
+}
@@ -0,0 +1,90 @@
+package libdy
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+var (
+	// ErrOpDenied matches (with errors.Is) an *OpDeniedError.
+	ErrOpDenied = errors.New("libdy: operation denied by policy")
+)
+
+// OpDeniedError is returned when an OpPolicy rejects a request.
+type OpDeniedError struct {
+	Env    string
+	Op     string
+	Reason string
+}
+
+func (e *OpDeniedError) Error() string {
+	return fmt.Sprintf("%s denied in %q: %s", e.Op, e.Env, e.Reason)
+}
+
+func (e *OpDeniedError) Is(target error) bool { return target == ErrOpDenied }
+
+// OpPolicy restricts the DynamoDB operations an environment may run. Ops are
+// API operation names such as "DeleteTable" or "Scan".
+//
+//	prod := libdy.OpPolicy{
+//		Env:               "prod",
+//		Deny:              []string{"DeleteTable", "DeleteBackup"},
+//		RequireScanFilter: true,
+//	}
+type OpPolicy struct {
+	Env               string
+	Allow             []string // if set, only these operations may run
+	Deny              []string // operations that may never run
+	RequireScanFilter bool     // reject Scans without a FilterExpression
+}
+
+// check returns an *OpDeniedError if the policy rejects op with params.
+func (p *OpPolicy) check(op string, params interface{}) error {
+	deny := func(reason string) error { return &OpDeniedError{Env: p.Env, Op: op, Reason: reason} }
+	for _, d := range p.Deny {
+		if d == op {
+			return deny("denied operation")
+		}
+	}
+
+	if p.Allow != nil {
+		allowed := false
+		for _, a := range p.Allow {
+			allowed = allowed || a == op
+		}
+
+		if !allowed {
+			return deny("operation not in allowlist")
+		}
+	}
+
+	if in, ok := params.(*dynamodb.ScanInput); ok && p.RequireScanFilter && aws.StringValue(in.FilterExpression) == "" {
+		return deny("scan without filter")
+	}
+
+	return nil
+}
+
+// WithOpPolicy makes Open enforce p on the service handle. See
+// EnforceOpPolicy.
+func WithOpPolicy(p OpPolicy) Option {
+	return func(o *options) { o.svc.opPolicy = &p }
+}
+
+// EnforceOpPolicy makes svc reject the requests p doesn't allow with an
+// *OpDeniedError, before they are sent. This covers every call made through
+// svc, by libdy or otherwise.
+func EnforceOpPolicy(svc *dynamodb.DynamoDB, p OpPolicy) {
+	svc.Handlers.Validate.PushBackNamed(request.NamedHandler{
+		Name: "libdy.OpPolicy",
+		Fn: func(r *request.Request) {
+			if err := p.check(r.Operation.Name, r.Params); err != nil {
+				r.Error = err
+			}
+		},
+	})
+}
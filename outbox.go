@@ -0,0 +1,270 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// outboxLease is the default time a claimed event has to be published
+// before another poller may claim it.
+const outboxLease = time.Minute
+
+// OutboxEvent is an event stored in an Outbox along with the write it
+// describes.
+type OutboxEvent struct {
+	ID       string // unique; generated (see WithIDGen) if empty
+	Type     string // e.g. "order.created"
+	Payload  []byte
+	Created  time.Time // set when written
+	Attempts int       // times claimed by Poll, including this one
+}
+
+// Outbox implements the transactional outbox pattern: events are written
+// in the same transaction as the items they describe, so neither exists
+// without the other, then published, e.g. to SNS or a queue, by pollers:
+//
+//	outbox := libdy.NewOutbox(libdy.New(svc, libdy.WithTable("outbox")), 0)
+//	err := orders.WriteWithOutbox(ctx, order, outbox, libdy.OutboxEvent{Type: "order.created", Payload: b})
+//	...
+//	go outbox.Run(ctx, time.Second, func(ctx context.Context, ev libdy.OutboxEvent) error {
+//		return publish(ctx, ev)
+//	})
+//
+// Publishing is at least once: an event whose publisher dies, or fails,
+// before Done is claimed again once its lease expires. The table has one
+// item per event, keyed by a string partition key "id"; Poll scans it, so
+// it should only hold the events not published yet.
+type Outbox struct {
+	c     *Client
+	opts  []Option
+	lease time.Duration
+}
+
+// NewOutbox returns an Outbox in the table of c (or the one set in opts).
+// lease is how long a claimed event has to be published before it is
+// considered failed and may be claimed again; 0 means a minute.
+func NewOutbox(c *Client, lease time.Duration, opts ...Option) *Outbox {
+	if lease <= 0 {
+		lease = outboxLease
+	}
+
+	return &Outbox{c: c, opts: opts, lease: lease}
+}
+
+// options returns the options of the outbox table.
+func (ob *Outbox) options() (options, error) {
+	return ob.c.apply(ob.opts)
+}
+
+// txOp returns the put of ev, new, in the transaction of a write.
+func (ob *Outbox) txOp(o options, ev OutboxEvent) TxOp {
+	if ev.ID == "" {
+		ev.ID = o.newID()
+	}
+
+	item := map[string]*dynamodb.AttributeValue{
+		"id":      {S: aws.String(ev.ID)},
+		"type":    {S: aws.String(ev.Type)},
+		"created": {N: aws.String(strconv.FormatInt(o.now().UnixMilli(), 10))},
+	}
+
+	if ev.Payload != nil {
+		item["payload"] = &dynamodb.AttributeValue{B: ev.Payload}
+	}
+
+	return TxPut(o.table, item, Condition{
+		Label: "outbox",
+		Expr:  "attribute_not_exists(#o0)",
+		Names: map[string]*string{"#o0": aws.String("id")},
+	})
+}
+
+// Transact applies ops and writes events to ob in one transaction (see
+// TransactWriteItems), at most 100 operations and events together.
+func (ob *Outbox) Transact(ctx context.Context, ops []TxOp, events ...OutboxEvent) error {
+	o, err := ob.options()
+	if err != nil {
+		return fmt.Errorf("Transact failed: %w", err)
+	}
+
+	ops = ops[:len(ops):len(ops)]
+	for _, ev := range events {
+		ops = append(ops, ob.txOp(o, ev))
+	}
+
+	if err := TransactWriteItemsWithContext(ctx, ob.c.svc, ops, ob.opts...); err != nil {
+		return fmt.Errorf("Transact failed: %w", err)
+	}
+
+	return nil
+}
+
+// WriteWithOutbox puts item in the table of c, and event in outbox, in one
+// transaction. The put is conditional with WithCondition, in which case the
+// error wraps a *TxCanceledError if it doesn't hold, and neither is written.
+func (c *Client) WriteWithOutbox(ctx context.Context, item map[string]*dynamodb.AttributeValue, outbox *Outbox, event OutboxEvent, opts ...Option) error {
+	o, err := c.apply(opts)
+	if err != nil {
+		return err
+	}
+
+	var conds []Condition
+	if o.condition != nil {
+		conds = append(conds, *o.condition)
+	}
+
+	op := TxPut(o.table, item, conds...)
+	defer o.cache.invalidateTx([]TxOp{op})
+	if err := outbox.Transact(ctx, []TxOp{op}, event); err != nil {
+		return fmt.Errorf("WriteWithOutbox failed: %w", err)
+	}
+
+	return nil
+}
+
+// claimable is the condition that an event exists and isn't claimed, or
+// its lease has expired, at now.
+func claimable(now time.Time) Condition {
+	return Condition{
+		Label: "outbox",
+		Expr:  "attribute_exists(#o0) AND (attribute_not_exists(#ou) OR #ou < :now)",
+		Names: map[string]*string{"#o0": aws.String("id"), "#ou": aws.String("until")},
+		Values: map[string]*dynamodb.AttributeValue{
+			":now": {N: aws.String(strconv.FormatInt(now.UnixMilli(), 10))},
+		},
+	}
+}
+
+// Poll claims up to n pending events, oldest first, for the lease of ob.
+// The caller must publish them, then call Done. Events claimed by another
+// poller meanwhile are skipped, so concurrent pollers don't get the same
+// events.
+func (ob *Outbox) Poll(ctx context.Context, n int) ([]OutboxEvent, error) {
+	o, err := ob.options()
+	if err != nil {
+		return nil, fmt.Errorf("Poll failed: %w", err)
+	}
+
+	now := o.now()
+	pending := claimable(now)
+	opts := append(ob.opts[:len(ob.opts):len(ob.opts)], WithConsistentRead(), WithScanAck(), WithFilter(pending))
+	items, err := ob.c.ScanItems(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("Poll failed: %w", err)
+	}
+
+	events := make([]OutboxEvent, 0, len(items))
+	for _, item := range items {
+		events = append(events, outboxEvent(item))
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Created.Before(events[j].Created) })
+	var ret []OutboxEvent
+	for _, ev := range events {
+		if len(ret) >= n {
+			break
+		}
+
+		u := NewUpdate().
+			Set("until", &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(now.Add(ob.lease).UnixMilli(), 10))}).
+			Increment("attempts", 1)
+
+		opts := append(ob.opts[:len(ob.opts):len(ob.opts)], WithCondition(pending), WithReturnValues(dynamodb.ReturnValueAllNew))
+		item, err := ob.c.UpdateItem(ctx, StringKey("id", ev.ID).String(), "", u, opts...)
+		switch {
+		case errors.Is(err, ErrConditionFailed):
+			continue // claimed, or done, meanwhile
+		case err != nil:
+			return ret, fmt.Errorf("Poll failed: %w", err)
+		}
+
+		ret = append(ret, outboxEvent(item))
+	}
+
+	return ret, nil
+}
+
+// outboxEvent decodes the item of an event.
+func outboxEvent(item map[string]*dynamodb.AttributeValue) OutboxEvent {
+	ev := OutboxEvent{}
+	if v := item["id"]; v != nil {
+		ev.ID = aws.StringValue(v.S)
+	}
+
+	if v := item["type"]; v != nil {
+		ev.Type = aws.StringValue(v.S)
+	}
+
+	if v := item["payload"]; v != nil {
+		ev.Payload = v.B
+	}
+
+	if v := item["created"]; v != nil {
+		ms, _ := strconv.ParseInt(aws.StringValue(v.N), 10, 64)
+		ev.Created = time.UnixMilli(ms)
+	}
+
+	if v := item["attempts"]; v != nil {
+		ev.Attempts, _ = strconv.Atoi(aws.StringValue(v.N))
+	}
+
+	return ev
+}
+
+// Done marks the events ids as published, deleting them from the outbox.
+func (ob *Outbox) Done(ctx context.Context, ids ...string) error {
+	for _, id := range ids {
+		if err := ob.c.DeleteItem(ctx, StringKey("id", id).String(), "", ob.opts...); err != nil {
+			return fmt.Errorf("Done failed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Run polls ob every interval, or right away after a full batch, and
+// publishes the events claimed with publish, marking those published as
+// Done, until ctx is done. Failed events are logged (see WithLogger), and
+// claimed again once their lease expires.
+func (ob *Outbox) Run(ctx context.Context, interval time.Duration, publish func(ctx context.Context, ev OutboxEvent) error) error {
+	const batch = 25
+	o, err := ob.options()
+	if err != nil {
+		return fmt.Errorf("Run failed: %w", err)
+	}
+
+	for {
+		events, err := ob.Poll(ctx, batch)
+		if err != nil && ctx.Err() == nil {
+			o.logf("outbox: %v", err)
+		}
+
+		for _, ev := range events {
+			if err := publish(ctx, ev); err != nil {
+				o.logf("outbox: publishing event %s failed (attempt %d): %v", ev.ID, ev.Attempts, err)
+				continue
+			}
+
+			if err := ob.Done(ctx, ev.ID); err != nil {
+				o.logf("outbox: %v", err)
+			}
+		}
+
+		if len(events) == batch {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-o.after(interval):
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package libdy
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// WithPrefetch makes page iterators fetch the next page in the background
+// while the caller is still consuming the current one.
+func WithPrefetch() Option {
+	return func(o *options) { o.prefetch = true }
+}
+
+type pageResult struct {
+	items []map[string]*dynamodb.AttributeValue
+	next  map[string]*dynamodb.AttributeValue
+	units float64
+	err   error
+}
+
+// pageFunc fetches the page at start; limit, if > 0, overrides the Limit.
+type pageFunc func(ctx context.Context, start map[string]*dynamodb.AttributeValue, limit int64) pageResult
+
+// Pages iterates over the pages of a Query or Scan, one request at a time.
+//
+//	p := client.QueryPages("pk:1", "", libdy.WithPrefetch())
+//	defer p.Close()
+//	for p.Next(ctx) {
+//		for _, item := range p.Page() { ... }
+//	}
+//
+//	if err := p.Err(); err != nil { ... }
+type Pages struct {
+	op       string
+	fetch    pageFunc
+	o        options
+	meter    *costMeter
+	page     []map[string]*dynamodb.AttributeValue
+	next     map[string]*dynamodb.AttributeValue
+	count    int64
+	done     bool
+	err      error
+	pending  chan pageResult
+	cancel   context.CancelFunc
+	prefetch bool
+}
+
+func newPages(op string, o options, err error, fetch pageFunc) *Pages {
+	return &Pages{
+		op:       op,
+		fetch:    fetch,
+		o:        o,
+		meter:    o.costMeter(),
+		next:     o.startKey,
+		err:      err,
+		prefetch: o.prefetch,
+	}
+}
+
+func (p *Pages) get(ctx context.Context) pageResult {
+	r := p.fetch(ctx, p.next, p.o.pageLimit(p.count))
+	if r.err != nil {
+		return r
+	}
+
+	r.items, r.err = p.o.read(ctx, r.items)
+	if r.err == nil {
+		r.items, r.err = p.o.authorize(ctx, p.o.table, p.o.checkRead(r.items))
+	}
+
+	return r
+}
+
+// Next fetches the next page, returning false when there are no more pages
+// or an error occurred (see Err).
+func (p *Pages) Next(ctx context.Context) bool {
+	if p.err != nil || p.done {
+		return false
+	}
+
+	var r pageResult
+	if p.pending != nil {
+		select {
+		case r = <-p.pending:
+		case <-ctx.Done():
+			r.err = ctx.Err()
+		}
+
+		p.pending = nil
+	} else {
+		r = p.get(ctx)
+	}
+
+	if p.cancel != nil {
+		p.cancel() // release the finished prefetch
+		p.cancel = nil
+	}
+
+	if r.err != nil {
+		p.err = r.err
+		return false
+	}
+
+	if max := p.o.maxItems; max > 0 && p.count+int64(len(r.items)) > max {
+		r.items = r.items[:max-p.count]
+	}
+
+	p.page, p.next = r.items, r.next
+	p.count += int64(len(r.items))
+	p.meter.add(r.units)
+	if p.next == nil || p.o.capped(p.count) {
+		p.done = true
+	}
+
+	if !p.done && p.meter.exceeded() {
+		// Deliver this page, and fail the next Next.
+		err := p.meter.err(p.op, p.o.table)
+		err.LastKey = p.next
+		p.err = err
+		return true
+	}
+
+	if p.prefetch && !p.done {
+		pctx, cancel := context.WithCancel(ctx)
+		p.cancel = cancel
+		p.pending = make(chan pageResult, 1)
+		go func(ch chan pageResult) { ch <- p.get(pctx) }(p.pending)
+	}
+
+	return true
+}
+
+// Page returns the items of the current page.
+func (p *Pages) Page() []map[string]*dynamodb.AttributeValue { return p.page }
+
+// LastKey returns the cursor to resume after the current page, or nil if
+// this was the last page.
+func (p *Pages) LastKey() map[string]*dynamodb.AttributeValue { return p.next }
+
+func (p *Pages) Err() error { return p.err }
+
+// Close cancels any in-flight prefetch. It is safe to call more than once.
+func (p *Pages) Close() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+
+	p.done = true
+}
+
+func (c *Client) queryPages(input *dynamodb.QueryInput, o options, err error) *Pages {
+	return newPages("Query", o, err, func(ctx context.Context, start map[string]*dynamodb.AttributeValue, limit int64) pageResult {
+		in := *input
+		in.ExclusiveStartKey = start
+		if limit > 0 {
+			in.Limit = aws.Int64(limit)
+		}
+
+		res, err := queryPage(ctx, c.reader(o), &in, o)
+		if err != nil {
+			return pageResult{err: err}
+		}
+
+		o.reportCapacity(o.table, "Query", capacityUnits(res.ConsumedCapacity), 1)
+
+		return pageResult{items: res.Items, next: res.LastEvaluatedKey, units: capacityUnits(res.ConsumedCapacity)}
+	})
+}
+
+// QueryPages returns an iterator over the pages of items under pk (and,
+// optionally, the sk prefix).
+func (c *Client) QueryPages(pk, sk string, opts ...Option) *Pages {
+	o, err := c.apply(opts)
+	var kp, ks Key
+	if err == nil {
+		// Pages take their context per call; the schema is described once.
+		kp, ks, err = c.keys(context.Background(), o, pk, sk)
+	}
+
+	return c.queryPages(o.queryInput(kp, ks), o, err)
+}
+
+// QueryIndexPages returns an iterator over the pages of items in the index
+// whose key equals value.
+func (c *Client) QueryIndexPages(index, key, value string, opts ...Option) *Pages {
+	o, err := c.apply(opts)
+	k := Key{Name: key, Value: value}
+	if err == nil {
+		k, err = c.indexKey(context.Background(), o, index, key, value)
+	}
+
+	return c.queryPages(o.indexQueryInput(index, k), o, err)
+}
+
+// ScanPages returns an iterator over the pages of all items in the table.
+func (c *Client) ScanPages(opts ...Option) *Pages {
+	o, err := c.apply(opts)
+	input := o.scanInput()
+	if o.limit > 0 {
+		input.Limit = aws.Int64(o.limit)
+	}
+
+	return newPages("Scan", o, err, func(ctx context.Context, start map[string]*dynamodb.AttributeValue, limit int64) pageResult {
+		in := *input
+		in.ExclusiveStartKey = start
+		if limit > 0 {
+			in.Limit = aws.Int64(limit)
+		}
+
+		if start == nil {
+			if err := o.checkScan(ctx, c.svc); err != nil {
+				return pageResult{err: err}
+			}
+		}
+
+		res, err := scanPage(ctx, c.reader(o), &in, o)
+		if err != nil {
+			return pageResult{err: err}
+		}
+
+		o.reportCapacity(o.table, "Scan", capacityUnits(res.ConsumedCapacity), 1)
+
+		return pageResult{items: res.Items, next: res.LastEvaluatedKey, units: capacityUnits(res.ConsumedCapacity)}
+	})
+}
@@ -0,0 +1,183 @@
+package libdy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+func ParallelScan(svc dynamodbiface.DynamoDBAPI, table string, segments int, opts ...Option) ([]map[string]*dynamodb.AttributeValue, error) {
+	return ParallelScanWithContext(context.Background(), svc, table, segments, opts...)
+}
+
+// ParallelScanWithContext is Client.ParallelScan for table.
+func ParallelScanWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, segments int, opts ...Option) ([]map[string]*dynamodb.AttributeValue, error) {
+	return New(svc, WithTable(table)).ParallelScan(ctx, segments, opts...)
+}
+
+// ParallelScan reads all the items in the table like Scan, but as segments
+// segments scanned concurrently. Items are merged in no particular order.
+// See ParallelScanFunc for streaming large tables.
+func (c *Client) ParallelScan(ctx context.Context, segments int, opts ...Option) ([]map[string]*dynamodb.AttributeValue, error) {
+	var mu sync.Mutex
+	items := []map[string]*dynamodb.AttributeValue{}
+	err := c.ParallelScanFunc(ctx, segments, func(_ int, page []map[string]*dynamodb.AttributeValue) error {
+		mu.Lock()
+		defer mu.Unlock()
+		items = append(items, page...)
+		return nil
+	}, opts...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// ParallelScanFunc scans the table as segments segments (Segment and
+// TotalSegments of the Scan API), calling fn with each page as it arrives.
+// Segments are scanned by WithConcurrency workers, and WithRateLimit caps
+// the page requests of all workers combined. WithPageSize sets the page
+// size; WithFilter, WithProjection, and WithPipeline apply to every page.
+//
+// fn is called from multiple goroutines at once. The first error, from a
+// request or from fn, stops the scan and is returned.
+func (c *Client) ParallelScanFunc(ctx context.Context, segments int, fn func(segment int, items []map[string]*dynamodb.AttributeValue) error, opts ...Option) error {
+	o, err := c.apply(opts)
+	if err != nil {
+		return err
+	}
+
+	return c.parallelScan(ctx, segments, nil, nil, func(seg int, items []map[string]*dynamodb.AttributeValue, _ map[string]*dynamodb.AttributeValue) error {
+		return fn(seg, items)
+	}, o)
+}
+
+// segmentFunc receives a page of a segment; next is the page's
+// LastEvaluatedKey, nil after the last page.
+type segmentFunc func(seg int, items []map[string]*dynamodb.AttributeValue, next map[string]*dynamodb.AttributeValue) error
+
+// parallelScan is ParallelScanFunc, resuming each segment from its key in
+// starts, if any, and skipping the segments in done.
+func (c *Client) parallelScan(ctx context.Context, segments int, starts map[int]map[string]*dynamodb.AttributeValue, done map[int]bool, fn segmentFunc, o options) error {
+	if segments < 1 {
+		return fmt.Errorf("ParallelScan failed: invalid segment count %d", segments)
+	}
+
+	if err := o.checkScan(ctx, c.svc); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var tick <-chan time.Time
+	if o.rate > 0 {
+		t := time.NewTicker(time.Duration(float64(time.Second) / o.rate))
+		defer t.Stop()
+		tick = t.C
+	}
+
+	var once sync.Once
+	var ferr error
+	fail := func(err error) {
+		once.Do(func() {
+			ferr = err
+			cancel()
+		})
+	}
+
+	var wg sync.WaitGroup
+	next := make(chan int)
+	for w := 0; w < o.concurrent() && w < segments; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for seg := range next {
+				if err := c.scanSegment(ctx, seg, segments, starts[seg], tick, fn, o); err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+
+loop:
+	for seg := 0; seg < segments; seg++ {
+		if done[seg] {
+			continue
+		}
+
+		select {
+		case next <- seg:
+		case <-ctx.Done():
+			break loop
+		}
+	}
+
+	close(next)
+	wg.Wait()
+	if ferr != nil {
+		return ferr
+	}
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("ParallelScan canceled: %w", err)
+	}
+
+	return nil
+}
+
+// scanSegment reads one segment of a parallel scan, page by page, from start.
+func (c *Client) scanSegment(ctx context.Context, seg, segments int, start map[string]*dynamodb.AttributeValue, tick <-chan time.Time, fn segmentFunc, o options) error {
+	in := o.scanInput()
+	in.Segment = aws.Int64(int64(seg))
+	in.TotalSegments = aws.Int64(int64(segments))
+	in.ExclusiveStartKey = start
+	if o.pageSize > 0 {
+		in.Limit = aws.Int64(o.pageSize)
+	}
+
+	for {
+		if tick != nil {
+			select {
+			case <-ctx.Done():
+				return nil // reported by the caller
+			case <-tick:
+			}
+		}
+
+		res, err := scanPage(ctx, c.reader(o), in, o)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return err
+		}
+
+		o.reportCapacity(o.table, "Scan", capacityUnits(res.ConsumedCapacity), 1)
+		items, err := o.read(ctx, res.Items)
+		if err != nil {
+			return err
+		}
+
+		if items, err = o.authorize(ctx, o.table, o.checkRead(items)); err != nil {
+			return err
+		}
+
+		if err := fn(seg, items, res.LastEvaluatedKey); err != nil {
+			return err
+		}
+
+		if res.LastEvaluatedKey == nil {
+			return nil
+		}
+
+		in.ExclusiveStartKey = res.LastEvaluatedKey
+	}
+}
@@ -0,0 +1,260 @@
+package libdy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// BatchExecuteStatement accepts at most 25 statements per call.
+const batchStatementMax = 25
+
+// Statement is a PartiQL statement with its ? parameters, which can be Go
+// values (marshaled with dynamodbattribute) or *dynamodb.AttributeValue.
+type Statement struct {
+	Stmt   string
+	Params []interface{}
+}
+
+// StatementResult is the outcome of one statement of a batch.
+type StatementResult struct {
+	Item map[string]*dynamodb.AttributeValue // for SELECTs
+	Err  error
+}
+
+// params marshals the parameters of s.
+func (s Statement) params() ([]*dynamodb.AttributeValue, error) {
+	if len(s.Params) == 0 {
+		return nil, nil
+	}
+
+	ret := make([]*dynamodb.AttributeValue, len(s.Params))
+	for i, p := range s.Params {
+		if av, ok := p.(*dynamodb.AttributeValue); ok {
+			ret[i] = av
+			continue
+		}
+
+		av, err := dynamodbattribute.Marshal(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid parameter %d: %w", i+1, err)
+		}
+
+		ret[i] = av
+	}
+
+	return ret, nil
+}
+
+func ExecuteStatement(svc dynamodbiface.DynamoDBAPI, stmt string, params ...interface{}) ([]map[string]*dynamodb.AttributeValue, error) {
+	return ExecuteStatementWithContext(context.Background(), svc, stmt, params...)
+}
+
+// ExecuteStatementWithContext runs a PartiQL statement, reading all the
+// pages of a SELECT:
+//
+//	items, err := libdy.ExecuteStatementWithContext(ctx, svc,
+//		`SELECT * FROM "orders" WHERE pk = ? AND amount > ?`, "user#1", 100)
+func ExecuteStatementWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, stmt string, params ...interface{}) ([]map[string]*dynamodb.AttributeValue, error) {
+	return New(svc).Execute(ctx, Statement{Stmt: stmt, Params: params})
+}
+
+// Execute runs a PartiQL statement, following NextToken through all the
+// pages of a SELECT. WithConsistentRead, WithPageSize (items evaluated per
+// request), and WithMaxItems apply. Statements name their tables, so
+// WithTable isn't needed.
+func (c *Client) Execute(ctx context.Context, s Statement, opts ...Option) ([]map[string]*dynamodb.AttributeValue, error) {
+	o := c.opts
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	ret := []map[string]*dynamodb.AttributeValue{}
+	token := ""
+	for {
+		if l := o.pageLimit(int64(len(ret))); l > 0 {
+			o.pageSize = l
+		}
+
+		items, next, err := c.executePage(ctx, s, token, o)
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, items...)
+		if o.maxItems > 0 && int64(len(ret)) >= o.maxItems {
+			return ret[:o.maxItems], nil
+		}
+
+		if next == "" {
+			return ret, nil
+		}
+
+		token = next
+	}
+}
+
+// ExecutePage runs one request of a PartiQL SELECT from token ("" for the
+// first page), returning the items and the token of the next page, "" after
+// the last one.
+func (c *Client) ExecutePage(ctx context.Context, s Statement, token string, opts ...Option) ([]map[string]*dynamodb.AttributeValue, string, error) {
+	o := c.opts
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return c.executePage(ctx, s, token, o)
+}
+
+func (c *Client) executePage(ctx context.Context, s Statement, token string, o options) ([]map[string]*dynamodb.AttributeValue, string, error) {
+	params, err := s.params()
+	if err != nil {
+		return nil, "", fmt.Errorf("ExecuteStatement failed: %w", err)
+	}
+
+	input := &dynamodb.ExecuteStatementInput{
+		Statement:              aws.String(s.Stmt),
+		Parameters:             params,
+		ReturnConsumedCapacity: o.returnCapacity(),
+	}
+
+	if token != "" {
+		input.NextToken = aws.String(token)
+	}
+
+	if o.pageSize > 0 {
+		input.Limit = aws.Int64(o.pageSize)
+	}
+
+	if o.consistent {
+		input.ConsistentRead = aws.Bool(true)
+	}
+
+	start := time.Now()
+	var rerr error
+	var res *dynamodb.ExecuteStatementOutput
+
+	// Our retriable function.
+	op := func(ctx context.Context) error {
+		res, rerr = c.svc.ExecuteStatementWithContext(ctx, input)
+		return o.retriable(rerr)
+	}
+
+	err = retry(ctx, o, "ExecuteStatement", op)
+	if (err != nil || rerr != nil) && ctx.Err() != nil {
+		return nil, "", fmt.Errorf("ExecuteStatement canceled after %v: %w", time.Since(start), ctx.Err())
+	}
+
+	if err != nil {
+		return nil, "", fmt.Errorf("ExecuteStatement failed after %v: %w", time.Since(start), err)
+	}
+
+	if rerr != nil {
+		return nil, "", fmt.Errorf("ExecuteStatement failed: %w", awsErr(rerr))
+	}
+
+	if res.ConsumedCapacity != nil {
+		o.reportCapacity(aws.StringValue(res.ConsumedCapacity.TableName), "ExecuteStatement", capacityUnits(res.ConsumedCapacity), 1)
+	}
+
+	items, err := o.authorize(ctx, o.table, res.Items)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return items, aws.StringValue(res.NextToken), nil
+}
+
+func BatchExecuteStatement(svc dynamodbiface.DynamoDBAPI, stmts []Statement) ([]StatementResult, error) {
+	return BatchExecuteStatementWithContext(context.Background(), svc, stmts)
+}
+
+// BatchExecuteStatementWithContext is Client.BatchExecute.
+func BatchExecuteStatementWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, stmts []Statement) ([]StatementResult, error) {
+	return New(svc).BatchExecute(ctx, stmts)
+}
+
+// BatchExecute runs PartiQL statements (all reads or all writes) in batches
+// of 25. Results are in statement order; statements that failed on their
+// own have Err set, while the returned error is for whole requests.
+func (c *Client) BatchExecute(ctx context.Context, stmts []Statement, opts ...Option) ([]StatementResult, error) {
+	o := c.opts
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	ret := make([]StatementResult, 0, len(stmts))
+	for i := 0; i < len(stmts); i += batchStatementMax {
+		end := i + batchStatementMax
+		if end > len(stmts) {
+			end = len(stmts)
+		}
+
+		res, err := c.batchExecuteChunk(ctx, stmts[i:end], o)
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, res...)
+	}
+
+	return ret, nil
+}
+
+func (c *Client) batchExecuteChunk(ctx context.Context, stmts []Statement, o options) ([]StatementResult, error) {
+	input := &dynamodb.BatchExecuteStatementInput{ReturnConsumedCapacity: o.returnCapacity()}
+	for i, s := range stmts {
+		params, err := s.params()
+		if err != nil {
+			return nil, fmt.Errorf("BatchExecuteStatement failed: statement %d: %w", i, err)
+		}
+
+		input.Statements = append(input.Statements, &dynamodb.BatchStatementRequest{
+			Statement:      aws.String(s.Stmt),
+			Parameters:     params,
+			ConsistentRead: aws.Bool(o.consistent),
+		})
+	}
+
+	start := time.Now()
+	var rerr error
+	var res *dynamodb.BatchExecuteStatementOutput
+
+	// Our retriable function.
+	op := func(ctx context.Context) error {
+		res, rerr = c.svc.BatchExecuteStatementWithContext(ctx, input)
+		return o.retriable(rerr)
+	}
+
+	err := retry(ctx, o, "BatchExecuteStatement", op)
+	if (err != nil || rerr != nil) && ctx.Err() != nil {
+		return nil, fmt.Errorf("BatchExecuteStatement canceled after %v: %w", time.Since(start), ctx.Err())
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("BatchExecuteStatement failed after %v: %w", time.Since(start), err)
+	}
+
+	if rerr != nil {
+		return nil, fmt.Errorf("BatchExecuteStatement failed: %w", awsErr(rerr))
+	}
+
+	for _, cc := range res.ConsumedCapacity {
+		o.reportCapacity(aws.StringValue(cc.TableName), "BatchExecuteStatement", capacityUnits(cc), 1)
+	}
+
+	ret := make([]StatementResult, len(res.Responses))
+	for i, r := range res.Responses {
+		ret[i].Item = r.Item
+		if r.Error != nil {
+			ret[i].Err = fmt.Errorf("%s: %s", aws.StringValue(r.Error.Code), aws.StringValue(r.Error.Message))
+		}
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,91 @@
+package libdy
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// partitionLimiter bounds the number of concurrent operations per key.
+type partitionLimiter struct {
+	attr  string
+	n     int
+	mu    sync.Mutex
+	slots map[string]*partitionSlot
+}
+
+type partitionSlot struct {
+	sem  chan struct{}
+	refs int
+}
+
+// WithPartitionLimit bounds the number of concurrent writes (PutItem,
+// DeleteItem, UpdateItem) to the same partition key to n within the process;
+// n = 1 serializes them. attr is the table's partition key attribute name.
+// Set it on the Client: each WithPartitionLimit has its own limiter, shared
+// by everything it is applied to.
+func WithPartitionLimit(attr string, n int) Option {
+	l := &partitionLimiter{attr: attr, n: n, slots: map[string]*partitionSlot{}}
+	return func(o *options) { o.partitions = l }
+}
+
+// acquire blocks until a slot for the partition of item is free, and returns
+// the func to release it. A nil limiter, or an item without the partition
+// key, doesn't block.
+func (l *partitionLimiter) acquire(ctx context.Context, table string, item map[string]*dynamodb.AttributeValue) (func(), error) {
+	if l == nil || l.n <= 0 {
+		return func() {}, nil
+	}
+
+	v, ok := item[l.attr]
+	if !ok {
+		return func() {}, nil
+	}
+
+	key := table + "\x00" + partitionValue(v)
+	l.mu.Lock()
+	s, ok := l.slots[key]
+	if !ok {
+		s = &partitionSlot{sem: make(chan struct{}, l.n)}
+		l.slots[key] = s
+	}
+
+	s.refs++
+	l.mu.Unlock()
+
+	unref := func() {
+		l.mu.Lock()
+		s.refs--
+		if s.refs == 0 {
+			delete(l.slots, key)
+		}
+
+		l.mu.Unlock()
+	}
+
+	select {
+	case s.sem <- struct{}{}:
+	case <-ctx.Done():
+		unref()
+		return nil, ctx.Err()
+	}
+
+	return func() {
+		<-s.sem
+		unref()
+	}, nil
+}
+
+func partitionValue(v *dynamodb.AttributeValue) string {
+	switch {
+	case v.S != nil:
+		return "S" + *v.S
+	case v.N != nil:
+		return "N" + *v.N
+	case v.B != nil:
+		return "B" + string(v.B)
+	}
+
+	return v.String()
+}
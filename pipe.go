@@ -0,0 +1,287 @@
+package libdy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Sink receives the items of a Pipe, one page at a time. Write may be
+// called concurrently for different scan segments. Besides SinkFunc, there
+// are TableSink, WriterSink, S3Sink, KinesisSink, and SQSSink.
+type Sink interface {
+	Write(ctx context.Context, items []map[string]*dynamodb.AttributeValue) error
+}
+
+// SinkFunc adapts a function to a Sink.
+type SinkFunc func(ctx context.Context, items []map[string]*dynamodb.AttributeValue) error
+
+func (f SinkFunc) Write(ctx context.Context, items []map[string]*dynamodb.AttributeValue) error {
+	return f(ctx, items)
+}
+
+// TableSink writes the items to the table of c with BatchPutItems; opts
+// apply to the writes, e.g. WithTable or WithWriteCapacity.
+func TableSink(c *Client, opts ...Option) Sink {
+	return SinkFunc(func(ctx context.Context, items []map[string]*dynamodb.AttributeValue) error {
+		return c.BatchPutItems(ctx, items, opts...)
+	})
+}
+
+// WriterSink writes the items to w as JSON lines, one object per item.
+func WriterSink(w io.Writer) Sink {
+	var mu sync.Mutex
+	return SinkFunc(func(_ context.Context, items []map[string]*dynamodb.AttributeValue) error {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, item := range items {
+			b, err := itemJSON(item)
+			if err != nil {
+				return err
+			}
+
+			if _, err := w.Write(append(b, '\n')); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Checkpoints persists the progress of a Pipe or ScanWithCheckpoint, as a
+// cursor (see EncodeCursor) per scan segment; a query is segment 0. Save is
+// called after pages are processed, with "" once the segment is complete.
+// Load returns the saved cursors: segments without one start from the
+// beginning, and segments saved as "" are skipped. Save may be called
+// concurrently.
+type Checkpoints interface {
+	Load(ctx context.Context) (map[int]string, error)
+	Save(ctx context.Context, segment int, cursor string) error
+}
+
+type fileCheckpoints struct {
+	path string
+	mu   sync.Mutex
+	m    map[int]string
+}
+
+// FileCheckpoints keeps checkpoints in a JSON file at path, which doesn't
+// need to exist yet.
+func FileCheckpoints(path string) Checkpoints {
+	return &fileCheckpoints{path: path, m: map[int]string{}}
+}
+
+func (f *fileCheckpoints) Load(context.Context) (map[int]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[int]string{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	m := map[int]string{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("invalid checkpoints %s: %w", f.path, err)
+	}
+
+	f.m = m
+	ret := make(map[int]string, len(m))
+	for k, v := range m {
+		ret[k] = v
+	}
+
+	return ret, nil
+}
+
+func (f *fileCheckpoints) Save(_ context.Context, segment int, cursor string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.m[segment] = cursor
+	b, err := json.Marshal(f.m)
+	if err != nil {
+		return err
+	}
+
+	// Write and rename, so a crash never leaves a truncated file.
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, f.path)
+}
+
+// PipeConfig describes a Pipe from the Client's table.
+type PipeConfig struct {
+	// PK (and, optionally, the SK prefix) selects the items to read with a
+	// query. If PK is empty, the whole table is scanned.
+	PK, SK string
+
+	// Segments is the number of parallel scan segments; the default is 1.
+	Segments int
+
+	// Transform maps each item read to the item written. Returning a nil
+	// item drops it; a nil Transform copies items as is. Like Sink.Write, it
+	// may be called concurrently for different segments.
+	Transform func(item map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error)
+
+	Sink        Sink
+	Checkpoints Checkpoints // optional, to resume an interrupted Pipe
+}
+
+// PipeStats counts the items a Pipe moved.
+type PipeStats struct {
+	Read    int64
+	Written int64
+}
+
+func (s *PipeStats) snapshot() (read, written int64) {
+	return atomic.LoadInt64(&s.Read), atomic.LoadInt64(&s.Written)
+}
+
+// Pipe reads items from the Client's table (a query or a parallel scan, per
+// p), transforms them, and writes them to p.Sink, page by page: a general
+// ETL primitive.
+//
+//	stats, err := src.Pipe(ctx, libdy.PipeConfig{
+//		Segments:    8,
+//		Transform:   migrate,
+//		Sink:        libdy.TableSink(dst, libdy.WithWriteCapacity(wcu)),
+//		Checkpoints: libdy.FileCheckpoints("/var/tmp/migrate.json"),
+//	}, libdy.WithConcurrency(8), libdy.WithReadCapacity(rcu))
+//
+// opts are read options: WithConcurrency sets the number of segments read
+// at once, and WithRateLimit or WithReadCapacity pace the reads. With
+// Checkpoints, a Pipe run again after a failure resumes after the last page
+// that reached the sink, so a page may be written twice but none is lost.
+// With WithEvents, a Pipe reports BackfillProgress and MigrationComplete.
+// With WithMask, items are masked before p.Transform.
+func (c *Client) Pipe(ctx context.Context, p PipeConfig, opts ...Option) (*PipeStats, error) {
+	o, err := c.apply(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.Sink == nil {
+		return nil, fmt.Errorf("Pipe failed: no sink")
+	}
+
+	cursors := map[int]string{}
+	if p.Checkpoints != nil {
+		if cursors, err = p.Checkpoints.Load(ctx); err != nil {
+			return nil, fmt.Errorf("Pipe failed: %w", err)
+		}
+	}
+
+	starts := map[int]map[string]*dynamodb.AttributeValue{}
+	for seg, cursor := range cursors {
+		if cursor == "" {
+			continue
+		}
+
+		if starts[seg], err = DecodeCursor(cursor); err != nil {
+			return nil, fmt.Errorf("Pipe failed: segment %d: %w", seg, err)
+		}
+	}
+
+	stats := &PipeStats{}
+	progress := o.events.progressFunc(o, o.table, stats)
+	page := func(seg int, items []map[string]*dynamodb.AttributeValue, next map[string]*dynamodb.AttributeValue) error {
+		atomic.AddInt64(&stats.Read, int64(len(items)))
+		out := make([]map[string]*dynamodb.AttributeValue, 0, len(items))
+		for _, item := range items {
+			item = o.mask.Mask(item)
+			if p.Transform != nil {
+				var err error
+				if item, err = p.Transform(item); err != nil {
+					return fmt.Errorf("Pipe failed: transform: %w", err)
+				}
+			}
+
+			if item != nil {
+				out = append(out, item)
+			}
+		}
+
+		if len(out) > 0 {
+			if err := p.Sink.Write(ctx, out); err != nil {
+				return fmt.Errorf("Pipe failed: sink: %w", err)
+			}
+		}
+
+		atomic.AddInt64(&stats.Written, int64(len(out)))
+		progress()
+		if p.Checkpoints == nil {
+			return nil
+		}
+
+		cursor, err := EncodeCursor(next)
+		if err != nil {
+			return err
+		}
+
+		if err := p.Checkpoints.Save(ctx, seg, cursor); err != nil {
+			return fmt.Errorf("Pipe failed: checkpoint: %w", err)
+		}
+
+		return nil
+	}
+
+	if p.PK != "" {
+		if cursor, ok := cursors[0]; ok && cursor == "" {
+			return stats, nil // done in a previous run
+		}
+
+		err = c.pipeQuery(ctx, p, starts[0], page, o)
+	} else {
+		segments := p.Segments
+		if segments < 1 {
+			segments = 1
+		}
+
+		done := map[int]bool{}
+		for seg, cursor := range cursors {
+			done[seg] = cursor == "" // in a previous run
+		}
+
+		err = c.parallelScan(ctx, segments, starts, done, page, o)
+	}
+
+	if err == nil {
+		o.events.emit(o, Event{
+			Type:    EventMigrationComplete,
+			Table:   o.table,
+			Message: fmt.Sprintf("pipe from %s complete: %d item(s) read, %d written", o.table, stats.Read, stats.Written),
+			Read:    stats.Read,
+			Written: stats.Written,
+		})
+	}
+
+	return stats, err
+}
+
+// pipeQuery is Pipe for a query source.
+func (c *Client) pipeQuery(ctx context.Context, p PipeConfig, start map[string]*dynamodb.AttributeValue, page segmentFunc, o options) error {
+	o.startKey = start
+	pages := c.queryPages(o.queryInput(ParseKey(p.PK), ParseKey(p.SK)), o, nil)
+	defer pages.Close()
+	for pages.Next(ctx) {
+		if err := page(0, pages.Page(), pages.LastKey()); err != nil {
+			return err
+		}
+	}
+
+	return pages.Err()
+}
@@ -0,0 +1,99 @@
+package libdy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Transform is a single post-processing stage applied to every item read from
+// a table. Returning a nil item drops it from the results.
+type Transform func(item map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error)
+
+// Pipeline is an ordered list of read transformations, typically arranged as
+// decrypt -> decompress -> unmarshal/convert -> redact.
+type Pipeline []Transform
+
+func NewPipeline(stages ...Transform) Pipeline {
+	return Pipeline(nil).Then(stages...)
+}
+
+// Then returns a new pipeline with the stages appended; p is not modified.
+func (p Pipeline) Then(stages ...Transform) Pipeline {
+	ret := make(Pipeline, 0, len(p)+len(stages))
+	ret = append(ret, p...)
+	for _, s := range stages {
+		if s != nil {
+			ret = append(ret, s)
+		}
+	}
+
+	return ret
+}
+
+func (p Pipeline) Apply(items []map[string]*dynamodb.AttributeValue) ([]map[string]*dynamodb.AttributeValue, error) {
+	if len(p) == 0 {
+		return items, nil
+	}
+
+	ret := make([]map[string]*dynamodb.AttributeValue, 0, len(items))
+	for i, item := range items {
+		v, err := p.ApplyItem(item)
+		if err != nil {
+			return nil, fmt.Errorf("pipeline failed on item %d: %w", i, err)
+		}
+
+		if v != nil {
+			ret = append(ret, v)
+		}
+	}
+
+	return ret, nil
+}
+
+// ApplyItem runs a single item through all stages. A nil return with a nil
+// error means one of the stages dropped the item.
+func (p Pipeline) ApplyItem(item map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+	var err error
+	for i, s := range p {
+		item, err = s(item)
+		if err != nil {
+			return nil, fmt.Errorf("stage %d: %w", i, err)
+		}
+
+		if item == nil {
+			return nil, nil
+		}
+	}
+
+	return item, nil
+}
+
+// read runs items through the codecs of o (see decode), then its read
+// pipeline, with the context of the read.
+func (o options) read(ctx context.Context, items []map[string]*dynamodb.AttributeValue) ([]map[string]*dynamodb.AttributeValue, error) {
+	if o.compression == nil && o.encryption == nil && o.offload == nil {
+		return o.pipeline.Apply(items)
+	}
+
+	decoded := make([]map[string]*dynamodb.AttributeValue, len(items))
+	for i, item := range items {
+		var err error
+		if decoded[i], err = o.decode(ctx, item); err != nil {
+			return nil, fmt.Errorf("pipeline failed on item %d: %w", i, err)
+		}
+	}
+
+	return o.pipeline.Apply(decoded)
+}
+
+// readItem is read for a single item.
+func (o options) readItem(ctx context.Context, item map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+	item, err := o.decode(ctx, item)
+	if err != nil {
+		return nil, err
+	}
+
+	return o.pipeline.ApplyItem(item)
+}
@@ -0,0 +1,111 @@
+package libdy
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// described is a service describing every table as keyed by "id".
+type described struct {
+	dynamodbiface.DynamoDBAPI
+}
+
+func (described) DescribeTableWithContext(aws.Context, *dynamodb.DescribeTableInput, ...request.Option) (*dynamodb.DescribeTableOutput, error) {
+	return &dynamodb.DescribeTableOutput{Table: &dynamodb.TableDescription{
+		KeySchema: []*dynamodb.KeySchemaElement{{AttributeName: aws.String("id"), KeyType: aws.String(dynamodb.KeyTypeHash)}},
+	}}, nil
+}
+
+func TestEncodeDecode(t *testing.T) {
+	block, err := aes.NewCipher(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compress := WithCompression(NewCompression(Gzip(1), "doc").WithMinSize(0))
+	encrypt := WithEncryption(NewEncryption(AEADKeys(aead), "doc", "ssn"))
+	for _, tc := range []struct {
+		name    string
+		opts    []Option
+		encoded []string // the attributes not stored as they are
+	}{
+		{"none", nil, nil},
+		{"compression", []Option{compress}, []string{"doc"}},
+		{"encryption", []Option{encrypt}, []string{"doc", "ssn"}},
+		{"both", []Option{compress, encrypt}, []string{"doc", "ssn"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			item := func() map[string]*dynamodb.AttributeValue {
+				return map[string]*dynamodb.AttributeValue{
+					"id":  {S: aws.String("a")},
+					"doc": {S: aws.String(strings.Repeat("text ", 100))},
+					"ssn": {S: aws.String("123")},
+					"n":   {N: aws.String("1")},
+				}
+			}
+
+			o, err := New(nil, append([]Option{WithTable("t")}, tc.opts...)...).apply(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := context.Background()
+			in := item()
+			stored, err := o.encode(ctx, described{}, in)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(in, item()) {
+				t.Error("encode modified its item")
+			}
+
+			for name, v := range item() {
+				encoded := false
+				for _, attr := range tc.encoded {
+					encoded = encoded || attr == name
+				}
+
+				if reflect.DeepEqual(stored[name], v) == encoded {
+					t.Errorf("%s stored as %v, want it encoded: %v", name, stored[name], encoded)
+				}
+			}
+
+			got, err := o.decode(ctx, stored)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, item()) {
+				t.Errorf("decoded %v, want %v", got, item())
+			}
+
+			// The read pipeline sees the decoded items.
+			o.pipeline = NewPipeline(func(item map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+				if !reflect.DeepEqual(item, in) {
+					t.Errorf("pipeline got %v, want the decoded item", item)
+				}
+
+				return item, nil
+			})
+
+			if _, err := o.read(ctx, []map[string]*dynamodb.AttributeValue{stored}); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
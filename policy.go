@@ -0,0 +1,189 @@
+package libdy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+var (
+	ErrPolicyNotFound = errors.New("libdy: no resource policy")
+)
+
+// Action sets for the common grants.
+var (
+	ReadActions = []string{
+		"dynamodb:GetItem",
+		"dynamodb:BatchGetItem",
+		"dynamodb:Query",
+		"dynamodb:Scan",
+		"dynamodb:ConditionCheckItem",
+		"dynamodb:DescribeTable",
+	}
+
+	WriteActions = []string{
+		"dynamodb:PutItem",
+		"dynamodb:UpdateItem",
+		"dynamodb:DeleteItem",
+		"dynamodb:BatchWriteItem",
+	}
+)
+
+// Policy is a resource-based policy document for a table.
+//
+//	p := libdy.NewPolicy(tableARN).
+//		AllowRead("arn:aws:iam::111122223333:role/reader").
+//		AllowReadWrite("arn:aws:iam::111122223333:role/app")
+//
+//	_, err := libdy.PutTablePolicy(svc, "mytable", p.String())
+type Policy struct {
+	Version   string            `json:"Version"`
+	Statement []PolicyStatement `json:"Statement"`
+	arn       string
+}
+
+type PolicyStatement struct {
+	Sid       string              `json:"Sid,omitempty"`
+	Effect    string              `json:"Effect"`
+	Principal map[string][]string `json:"Principal"`
+	Action    []string            `json:"Action"`
+	Resource  []string            `json:"Resource"`
+}
+
+// NewPolicy returns an empty policy for the table with the given ARN.
+func NewPolicy(tableARN string) *Policy {
+	return &Policy{Version: "2012-10-17", arn: tableARN}
+}
+
+// Allow grants actions on the table and its indexes to the IAM principals
+// (account, role, or user ARNs).
+func (p *Policy) Allow(sid string, actions []string, principals ...string) *Policy {
+	return p.statement("Allow", sid, actions, principals)
+}
+
+// Deny denies actions on the table and its indexes to the IAM principals.
+func (p *Policy) Deny(sid string, actions []string, principals ...string) *Policy {
+	return p.statement("Deny", sid, actions, principals)
+}
+
+// AllowRead grants ReadActions to the principals.
+func (p *Policy) AllowRead(principals ...string) *Policy {
+	return p.Allow(fmt.Sprintf("Read%d", len(p.Statement)), ReadActions, principals...)
+}
+
+// AllowWrite grants WriteActions to the principals.
+func (p *Policy) AllowWrite(principals ...string) *Policy {
+	return p.Allow(fmt.Sprintf("Write%d", len(p.Statement)), WriteActions, principals...)
+}
+
+// AllowReadWrite grants ReadActions and WriteActions to the principals.
+func (p *Policy) AllowReadWrite(principals ...string) *Policy {
+	actions := append(append([]string{}, ReadActions...), WriteActions...)
+	return p.Allow(fmt.Sprintf("ReadWrite%d", len(p.Statement)), actions, principals...)
+}
+
+func (p *Policy) statement(effect, sid string, actions, principals []string) *Policy {
+	p.Statement = append(p.Statement, PolicyStatement{
+		Sid:       sid,
+		Effect:    effect,
+		Principal: map[string][]string{"AWS": principals},
+		Action:    actions,
+		Resource:  []string{p.arn, p.arn + "/index/*"},
+	})
+
+	return p
+}
+
+// String returns the policy as JSON.
+func (p *Policy) String() string {
+	b, _ := json.Marshal(p) // can't fail for these types
+	return string(b)
+}
+
+// tableARN returns table if it's already an ARN, or looks it up.
+func tableARN(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string) (string, error) {
+	if strings.HasPrefix(table, "arn:") {
+		return table, nil
+	}
+
+	res, err := svc.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+	if err != nil {
+		return "", fmt.Errorf("DescribeTable failed: %w", awsErr(err))
+	}
+
+	return aws.StringValue(res.Table.TableArn), nil
+}
+
+func PutTablePolicy(svc dynamodbiface.DynamoDBAPI, table, policy string) (string, error) {
+	return PutTablePolicyWithContext(context.Background(), svc, table, policy)
+}
+
+// PutTablePolicyWithContext attaches the JSON policy to table (a name or an
+// ARN), replacing any existing one, and returns the new revision ID.
+func PutTablePolicyWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, policy string) (string, error) {
+	arn, err := tableARN(ctx, svc, table)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := svc.PutResourcePolicyWithContext(ctx, &dynamodb.PutResourcePolicyInput{
+		ResourceArn: aws.String(arn),
+		Policy:      aws.String(policy),
+	})
+
+	if err != nil {
+		return "", fmt.Errorf("PutResourcePolicy failed: %w", awsErr(err))
+	}
+
+	return aws.StringValue(res.RevisionId), nil
+}
+
+func GetTablePolicy(svc dynamodbiface.DynamoDBAPI, table string) (string, string, error) {
+	return GetTablePolicyWithContext(context.Background(), svc, table)
+}
+
+// GetTablePolicyWithContext returns the JSON policy of table and its
+// revision ID, or ErrPolicyNotFound if it has none.
+func GetTablePolicyWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string) (string, string, error) {
+	arn, err := tableARN(ctx, svc, table)
+	if err != nil {
+		return "", "", err
+	}
+
+	res, err := svc.GetResourcePolicyWithContext(ctx, &dynamodb.GetResourcePolicyInput{ResourceArn: aws.String(arn)})
+	if ErrorCode(err) == dynamodb.ErrCodePolicyNotFoundException {
+		return "", "", ErrPolicyNotFound
+	}
+
+	if err != nil {
+		return "", "", fmt.Errorf("GetResourcePolicy failed: %w", awsErr(err))
+	}
+
+	return aws.StringValue(res.Policy), aws.StringValue(res.RevisionId), nil
+}
+
+func DeleteTablePolicy(svc dynamodbiface.DynamoDBAPI, table string) error {
+	return DeleteTablePolicyWithContext(context.Background(), svc, table)
+}
+
+// DeleteTablePolicyWithContext removes the policy of table. Deleting a
+// missing policy is not an error.
+func DeleteTablePolicyWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string) error {
+	arn, err := tableARN(ctx, svc, table)
+	if err != nil {
+		return err
+	}
+
+	_, err = svc.DeleteResourcePolicyWithContext(ctx, &dynamodb.DeleteResourcePolicyInput{ResourceArn: aws.String(arn)})
+	if err != nil && ErrorCode(err) != dynamodb.ErrCodePolicyNotFoundException {
+		return fmt.Errorf("DeleteResourcePolicy failed: %w", awsErr(err))
+	}
+
+	return nil
+}
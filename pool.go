@@ -0,0 +1,108 @@
+package libdy
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sync"
+)
+
+var (
+	ErrNoRoute = errors.New("libdy: no route for key")
+)
+
+type route struct {
+	pattern string
+	opts    []Option
+}
+
+// Pool maps tenants (or tables, or any other routing key) to their own
+// Clients, each with its own credentials, region and defaults. Clients are
+// opened lazily on first use and reused afterwards.
+type Pool struct {
+	mu      sync.Mutex
+	base    []Option
+	routes  []route
+	clients map[string]*Client
+}
+
+// NewPool creates a Pool whose routes all start from the base options.
+func NewPool(base ...Option) *Pool {
+	return &Pool{
+		base:    base,
+		clients: make(map[string]*Client),
+	}
+}
+
+// Route maps pattern to opts, applied on top of the Pool's base options.
+// Patterns use path.Match syntax; exact matches take precedence, otherwise
+// routes are tried in registration order. Use "*" for a catch-all route.
+func (p *Pool) Route(pattern string, opts ...Option) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid route pattern %q: %w", pattern, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, r := range p.routes {
+		if r.pattern == pattern {
+			p.routes[i].opts = opts
+			delete(p.clients, pattern)
+			return nil
+		}
+	}
+
+	p.routes = append(p.routes, route{pattern: pattern, opts: opts})
+	return nil
+}
+
+// Add registers an already-built Client for pattern.
+func (p *Pool) Add(pattern string, c *Client) error {
+	if err := p.Route(pattern); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clients[pattern] = c
+	return nil
+}
+
+func (p *Pool) match(key string) (route, bool) {
+	for _, r := range p.routes {
+		if r.pattern == key {
+			return r, true
+		}
+	}
+
+	for _, r := range p.routes {
+		if ok, _ := path.Match(r.pattern, key); ok {
+			return r, true
+		}
+	}
+
+	return route{}, false
+}
+
+// Client resolves key to its Client, opening it if needed.
+func (p *Pool) Client(key string) (*Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	r, ok := p.match(key)
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrNoRoute, key)
+	}
+
+	if c, ok := p.clients[r.pattern]; ok {
+		return c, nil
+	}
+
+	opts := append(append([]Option{}, p.base...), r.opts...)
+	c, err := Open(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("open client for %v failed: %w", key, err)
+	}
+
+	p.clients[r.pattern] = c
+	return c, nil
+}
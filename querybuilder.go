@@ -0,0 +1,285 @@
+package libdy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+)
+
+// QueryBuilder builds a query fluently, with values of any scalar Go type
+// and the names aliased, and runs it as Client.Query does, with its
+// retries, pagination, and read options.
+//
+//	res, err := libdy.NewQuery("orders").
+//		PK("customer", "c1").
+//		SKBetween("2024-01-01", "2024-02-01").
+//		Filter(expression.Name("status").Equal(expression.Value("open"))).
+//		Limit(50).
+//		Desc().
+//		Run(ctx, svc)
+//
+// Results are in ascending sort key order unless Desc is set. Errors in the
+// chain, e.g. a value that can't be a key, are returned by Run.
+type QueryBuilder struct {
+	table  string
+	index  string
+	pk     Key
+	skName string
+	sk     *SortKeyCondition // without values
+	skVals []interface{}
+	filter *expression.ConditionBuilder
+	desc   bool
+	opts   []Option
+	err    error
+}
+
+// NewQuery returns a QueryBuilder on table, or on the Client's table if ""
+// and run with Client.RunQuery.
+func NewQuery(table string) *QueryBuilder {
+	return &QueryBuilder{table: table}
+}
+
+func (q *QueryBuilder) setErr(err error) *QueryBuilder {
+	if q.err == nil {
+		q.err = err
+	}
+
+	return q
+}
+
+// PK sets the partition key condition: name equals v.
+func (q *QueryBuilder) PK(name string, v interface{}) *QueryBuilder {
+	k, err := repoAttr{name: name}.key(v)
+	if err != nil {
+		return q.setErr(err)
+	}
+
+	q.pk = k
+	return q
+}
+
+// SK names the sort key for the SK conditions. Without it, Run looks it up
+// with DescribeTable.
+func (q *QueryBuilder) SK(name string) *QueryBuilder {
+	q.skName = name
+	return q
+}
+
+func (q *QueryBuilder) skCond(op string, vals ...interface{}) *QueryBuilder {
+	q.sk, q.skVals = &SortKeyCondition{op: op}, vals
+	return q
+}
+
+func (q *QueryBuilder) SKEquals(v interface{}) *QueryBuilder         { return q.skCond("=", v) }
+func (q *QueryBuilder) SKLess(v interface{}) *QueryBuilder           { return q.skCond("<", v) }
+func (q *QueryBuilder) SKLessOrEqual(v interface{}) *QueryBuilder    { return q.skCond("<=", v) }
+func (q *QueryBuilder) SKGreater(v interface{}) *QueryBuilder        { return q.skCond(">", v) }
+func (q *QueryBuilder) SKGreaterOrEqual(v interface{}) *QueryBuilder { return q.skCond(">=", v) }
+
+// SKBetween holds for sort keys from lo to hi, inclusive.
+func (q *QueryBuilder) SKBetween(lo, hi interface{}) *QueryBuilder {
+	return q.skCond("BETWEEN", lo, hi)
+}
+
+// SKBeginsWith holds for sort keys starting with prefix.
+func (q *QueryBuilder) SKBeginsWith(prefix string) *QueryBuilder {
+	return q.skCond("begins_with", prefix)
+}
+
+// Filter adds cond, made with the SDK's expression package, to the filter
+// of the query, and'ed with the ones added before.
+func (q *QueryBuilder) Filter(cond expression.ConditionBuilder) *QueryBuilder {
+	if q.filter != nil {
+		cond = q.filter.And(cond)
+	}
+
+	q.filter = &cond
+	return q
+}
+
+// Index queries the global or local secondary index index.
+func (q *QueryBuilder) Index(index string) *QueryBuilder {
+	q.index = index
+	return q
+}
+
+// Limit caps the number of items returned, as WithMaxItems does.
+func (q *QueryBuilder) Limit(n int64) *QueryBuilder {
+	return q.With(WithMaxItems(n))
+}
+
+// Desc returns the items in descending sort key order.
+func (q *QueryBuilder) Desc() *QueryBuilder {
+	q.desc = true
+	return q
+}
+
+// Project limits the attributes returned to attrs.
+func (q *QueryBuilder) Project(attrs ...string) *QueryBuilder {
+	return q.With(WithProjection(attrs...))
+}
+
+// Consistent makes the query strongly consistent; not on global indexes.
+func (q *QueryBuilder) Consistent() *QueryBuilder {
+	return q.With(WithConsistentRead())
+}
+
+// With adds read options, e.g. WithStartKey or WithPageSize.
+func (q *QueryBuilder) With(opts ...Option) *QueryBuilder {
+	q.opts = append(q.opts, opts...)
+	return q
+}
+
+// options returns the options of q, resolving the sort key name with svc if
+// needed. table is the table to query if q has none.
+func (q *QueryBuilder) options(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string) ([]Option, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+
+	if q.table != "" {
+		table = q.table
+	}
+
+	switch {
+	case table == "":
+		return nil, fmt.Errorf("no table")
+	case q.pk.Name == "":
+		return nil, fmt.Errorf("no partition key condition")
+	}
+
+	opts := []Option{WithTable(table), WithScanIndexForward(!q.desc)}
+	if q.filter != nil {
+		opts = append(opts, WithFilterCondition(*q.filter))
+	}
+
+	if q.sk != nil {
+		name := q.skName
+		if name == "" {
+			var err error
+			if name, err = sortKeyName(ctx, svc, table, q.index); err != nil {
+				return nil, err
+			}
+		}
+
+		c := SortKeyCondition{op: q.sk.op}
+		for _, v := range q.skVals {
+			k, err := repoAttr{name: name}.key(v)
+			if err != nil {
+				return nil, err
+			}
+
+			c.values = append(c.values, k)
+		}
+
+		opts = append(opts, WithSortKey(c))
+	}
+
+	return append(opts, q.opts...), nil
+}
+
+// sortKeyName returns the sort key attribute of table, or of its index.
+func sortKeyName(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, index string) (string, error) {
+	t, err := DescribeTableWithContext(ctx, svc, table)
+	if err != nil {
+		return "", err
+	}
+
+	schema := t.KeySchema
+	if index != "" {
+		schema = nil
+		for _, x := range t.GlobalSecondaryIndexes {
+			if aws.StringValue(x.IndexName) == index {
+				schema = x.KeySchema
+			}
+		}
+
+		for _, x := range t.LocalSecondaryIndexes {
+			if aws.StringValue(x.IndexName) == index {
+				schema = x.KeySchema
+			}
+		}
+	}
+
+	for _, k := range schema {
+		if aws.StringValue(k.KeyType) == dynamodb.KeyTypeRange {
+			return aws.StringValue(k.AttributeName), nil
+		}
+	}
+
+	return "", fmt.Errorf("no sort key in %s %s", table, index)
+}
+
+// input returns the QueryInput of q per o, without the filter and
+// projection, which queryPage adds.
+func (q *QueryBuilder) input(o options) *dynamodb.QueryInput {
+	if q.index != "" {
+		return o.indexQueryInput(q.index, q.pk)
+	}
+
+	return o.queryInput(q.pk, Key{})
+}
+
+// Input returns the QueryInput of the first page of q, e.g. to inspect it.
+func (q *QueryBuilder) Input(ctx context.Context, svc dynamodbiface.DynamoDBAPI) (*dynamodb.QueryInput, error) {
+	opts, err := q.options(ctx, svc, "")
+	if err != nil {
+		return nil, fmt.Errorf("Query failed: %w", err)
+	}
+
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	in := q.input(o)
+	if l := o.pageLimit(0); l > 0 {
+		in.Limit = aws.Int64(l)
+	}
+
+	if err := o.applyFilter(&in.FilterExpression, &in.ExpressionAttributeNames, &in.ExpressionAttributeValues); err != nil {
+		return nil, fmt.Errorf("Query failed: %w", err)
+	}
+
+	o.applyProjection(&in.ProjectionExpression, &in.ExpressionAttributeNames)
+	return in, nil
+}
+
+// Run runs q with svc.
+func (q *QueryBuilder) Run(ctx context.Context, svc dynamodbiface.DynamoDBAPI) (*Result, error) {
+	opts, err := q.options(ctx, svc, "")
+	if err != nil {
+		return nil, fmt.Errorf("Query failed: %w", err)
+	}
+
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return query(ctx, svc, o.table, q.input(o), o)
+}
+
+// RunQuery runs q as Run does, with the Client's options, then those of q,
+// then opts, and the read pipeline of Query.
+func (c *Client) RunQuery(ctx context.Context, q *QueryBuilder, opts ...Option) (*Result, error) {
+	qopts, err := q.options(ctx, c.svc, c.opts.aliases.Resolve(c.opts.table))
+	if err != nil {
+		return nil, fmt.Errorf("Query failed: %w", err)
+	}
+
+	o, err := c.apply(append(qopts, opts...))
+	if err != nil {
+		return nil, err
+	}
+
+	if q.index != "" {
+		return c.queryIndex(ctx, q.index, q.pk, o)
+	}
+
+	return c.query(ctx, q.pk, Key{}, o)
+}
@@ -0,0 +1,115 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// QuerySpec is one query of QueryMany: the items under PK (and the SK
+// prefix), as GetItems reads them, or, with Index set, the items of Index
+// whose Key equals Value, as GetGsiItems does. Opts apply on top of those
+// of QueryMany.
+type QuerySpec struct {
+	PK, SK            string
+	Index, Key, Value string
+	Opts              []Option
+}
+
+func QueryMany(svc dynamodbiface.DynamoDBAPI, table string, queries []QuerySpec, opts ...Option) ([][]map[string]*dynamodb.AttributeValue, error) {
+	return QueryManyWithContext(context.Background(), svc, table, queries, opts...)
+}
+
+// QueryManyWithContext is Client.QueryMany for table.
+func QueryManyWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, queries []QuerySpec, opts ...Option) ([][]map[string]*dynamodb.AttributeValue, error) {
+	return New(svc, WithTable(table)).QueryMany(ctx, queries, opts...)
+}
+
+// QueryMany runs queries concurrently, by WithConcurrency workers, e.g. to
+// read many partitions at once, and returns their items in the order of
+// queries, each in the order of its query. Failed queries don't stop the
+// others: their items are nil, and the returned error joins their errors
+// (see errors.Join). MergeSorted merges the results into one list.
+func (c *Client) QueryMany(ctx context.Context, queries []QuerySpec, opts ...Option) ([][]map[string]*dynamodb.AttributeValue, error) {
+	o, err := c.apply(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([][]map[string]*dynamodb.AttributeValue, len(queries))
+	errs := make([]error, len(queries))
+	var wg sync.WaitGroup
+	next := make(chan int)
+	for w := 0; w < o.concurrent() && w < len(queries); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				q := queries[i]
+				qopts := append(opts[:len(opts):len(opts)], q.Opts...)
+				var err error
+				if q.Index != "" {
+					ret[i], err = c.GetGsiItems(ctx, q.Index, q.Key, q.Value, qopts...)
+				} else {
+					ret[i], err = c.GetItems(ctx, q.PK, q.SK, qopts...)
+				}
+
+				if err != nil {
+					ret[i], errs[i] = nil, fmt.Errorf("query %d: %w", i, err)
+				}
+			}
+		}()
+	}
+
+	for i := range queries {
+		next <- i
+	}
+
+	close(next)
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return ret, fmt.Errorf("QueryMany failed: %w", err)
+	}
+
+	return ret, nil
+}
+
+// MergeSorted merges the results of queries, e.g. of QueryMany, into one
+// list sorted by the sort key attr, ascending if forward, else descending
+// (the default order of libdy queries). Items with equal sort keys keep
+// their order; items without attr come last, and values of different types
+// are grouped by type.
+func MergeSorted(results [][]map[string]*dynamodb.AttributeValue, attr string, forward bool) []map[string]*dynamodb.AttributeValue {
+	var ret []map[string]*dynamodb.AttributeValue
+	for _, items := range results {
+		ret = append(ret, items...)
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		a, b := ret[i][attr], ret[j][attr]
+		switch {
+		case a == nil:
+			return false
+		case b == nil:
+			return true
+		}
+
+		c, err := compareValues(a, b)
+		if err != nil {
+			return attrType(a) < attrType(b)
+		}
+
+		if forward {
+			return c < 0
+		}
+
+		return c > 0
+	})
+
+	return ret
+}
@@ -0,0 +1,247 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// queueVisibility is the default time a received message stays invisible
+// to other receivers.
+const queueVisibility = 30 * time.Second
+
+// Message is a message of a Queue.
+type Message struct {
+	ID       string // ordered by enqueue time
+	Body     []byte
+	Enqueued time.Time
+	Receipt  string // of the last receive, for Ack and ChangeVisibility
+	Receives int    // times received, including this one
+}
+
+// Queue is a small job queue in a DynamoDB table, for low volumes where SQS
+// isn't available, with the semantics of an SQS standard queue: a received
+// message is invisible to other receivers for the visibility timeout, and
+// is received again after it unless acknowledged, so processing is at least
+// once. Messages are received in the order they were enqueued, oldest
+// first, within a queue.
+//
+//	jobs := libdy.NewQueue(libdy.New(svc, libdy.WithTable("queues")), "jobs", time.Minute,
+//		libdy.TTL{Attr: "expires", In: 14 * 24 * time.Hour})
+//
+//	msgs, err := jobs.Dequeue(ctx, 10)
+//	for _, m := range msgs {
+//		if err := run(m.Body); err == nil {
+//			jobs.Ack(ctx, m)
+//		}
+//	}
+//
+// The table holds any number of queues, one per partition, with a string
+// partition key "queue" and a string sort key "id":
+//
+//	libdy.EnsureTable(svc, libdy.TableDef{Name: "queues", PK: "queue", SK: "id"})
+//
+// Messages never acknowledged, e.g. because they always fail, expire per
+// the TTL of the Queue, and are no longer received, if Time to Live is
+// enabled on its attribute (see EnableTTL).
+type Queue struct {
+	c          *Client
+	opts       []Option
+	name       string
+	visibility time.Duration
+	ttl        TTL
+}
+
+// NewQueue returns the Queue name in the table of c (or the one set in
+// opts), whose messages expire per ttl; with no ttl.Attr, they never do.
+// visibility is the visibility timeout; 0 means 30 seconds.
+func NewQueue(c *Client, name string, visibility time.Duration, ttl TTL, opts ...Option) *Queue {
+	if visibility <= 0 {
+		visibility = queueVisibility
+	}
+
+	return &Queue{c: c, opts: opts, name: name, visibility: visibility, ttl: ttl}
+}
+
+// with returns the options of q, followed by opts.
+func (q *Queue) with(opts ...Option) []Option {
+	return append(q.opts[:len(q.opts):len(q.opts)], opts...)
+}
+
+func (q *Queue) now() time.Time {
+	o, _ := q.c.apply(q.opts) // a missing table fails at the request
+	return o.now()
+}
+
+func millis(t time.Time) *dynamodb.AttributeValue {
+	return &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(t.UnixMilli(), 10))}
+}
+
+// Enqueue adds a message with body to q, and returns its ID.
+func (q *Queue) Enqueue(ctx context.Context, body []byte) (string, error) {
+	o, err := q.c.apply(q.opts)
+	if err != nil {
+		return "", fmt.Errorf("Enqueue failed: %w", err)
+	}
+
+	now := o.now()
+	id := fmt.Sprintf("%020d-%s", now.UnixNano(), o.newID())
+	item := map[string]*dynamodb.AttributeValue{
+		"queue":    {S: aws.String(q.name)},
+		"id":       {S: aws.String(id)},
+		"body":     {B: body},
+		"enqueued": millis(now),
+		"visible":  millis(now),
+	}
+
+	if body == nil {
+		delete(item, "body")
+	}
+
+	if q.ttl.Attr != "" {
+		item[q.ttl.Attr] = ExpiryValue(q.ttl.expiresAt(now))
+	}
+
+	if err := q.c.PutItem(ctx, item, q.opts...); err != nil {
+		return "", fmt.Errorf("Enqueue failed: %w", err)
+	}
+
+	return id, nil
+}
+
+// receivable is the condition that a message is visible and not expired
+// at now.
+func (q *Queue) receivable(now time.Time) Condition {
+	c := Condition{
+		Label:  "queue",
+		Expr:   "#qv <= :now",
+		Names:  map[string]*string{"#qv": aws.String("visible")},
+		Values: map[string]*dynamodb.AttributeValue{":now": millis(now)},
+	}
+
+	if q.ttl.Attr != "" {
+		c.Expr += " AND (attribute_not_exists(#qe) OR #qe > :exp)"
+		c.Names["#qe"] = aws.String(q.ttl.Attr)
+		c.Values[":exp"] = ExpiryValue(now)
+	}
+
+	return c
+}
+
+// Dequeue receives up to n visible messages, oldest first, hiding them from
+// other receivers for the visibility timeout. The caller must Ack them once
+// processed. It returns no messages if there are none visible.
+func (q *Queue) Dequeue(ctx context.Context, n int) ([]Message, error) {
+	now := q.now()
+	visible := q.receivable(now)
+	pk := StringKey("queue", q.name).String()
+	var ret []Message
+	cursor := ""
+	for {
+		opts := q.with(WithScanIndexForward(true), WithConsistentRead(), WithFilter(visible), WithLimit(int64(max(n, 25))))
+		items, next, err := q.c.QueryPage(ctx, pk, "", cursor, opts...)
+		if err != nil {
+			return ret, fmt.Errorf("Dequeue failed: %w", err)
+		}
+
+		for _, item := range items {
+			if len(ret) >= n {
+				return ret, nil
+			}
+
+			m, err := q.receive(ctx, item, now, visible)
+			switch {
+			case errors.Is(err, ErrConditionFailed):
+				continue // received by another receiver meanwhile
+			case err != nil:
+				return ret, fmt.Errorf("Dequeue failed: %w", err)
+			}
+
+			ret = append(ret, m)
+		}
+
+		if cursor = next; cursor == "" || len(ret) >= n {
+			return ret, nil
+		}
+	}
+}
+
+// receive claims the message item, if still visible.
+func (q *Queue) receive(ctx context.Context, item map[string]*dynamodb.AttributeValue, now time.Time, visible Condition) (Message, error) {
+	o, err := q.c.apply(q.opts)
+	if err != nil {
+		return Message{}, err
+	}
+
+	u := NewUpdate().
+		Set("visible", millis(now.Add(q.visibility))).
+		Set("receipt", &dynamodb.AttributeValue{S: aws.String(o.newID())}).
+		Increment("receives", 1)
+
+	id := aws.StringValue(item["id"].S)
+	opts := q.with(WithCondition(visible), WithReturnValues(dynamodb.ReturnValueAllNew))
+	item, err = q.c.UpdateItem(ctx, StringKey("queue", q.name).String(), StringKey("id", id).String(), u, opts...)
+	if err != nil {
+		return Message{}, err
+	}
+
+	m := Message{ID: id}
+	if v := item["body"]; v != nil {
+		m.Body = v.B
+	}
+
+	if v := item["enqueued"]; v != nil {
+		ms, _ := strconv.ParseInt(aws.StringValue(v.N), 10, 64)
+		m.Enqueued = time.UnixMilli(ms)
+	}
+
+	if v := item["receipt"]; v != nil {
+		m.Receipt = aws.StringValue(v.S)
+	}
+
+	if v := item["receives"]; v != nil {
+		m.Receives, _ = strconv.Atoi(aws.StringValue(v.N))
+	}
+
+	return m, nil
+}
+
+// received is the condition that m wasn't received again since.
+func received(m Message) Condition {
+	return Condition{
+		Label:  "queue",
+		Expr:   "#qr = :receipt",
+		Names:  map[string]*string{"#qr": aws.String("receipt")},
+		Values: map[string]*dynamodb.AttributeValue{":receipt": {S: aws.String(m.Receipt)}},
+	}
+}
+
+// Ack deletes the received message m from q, once processed. It fails with
+// ErrConditionFailed if m was received again, after its visibility timeout,
+// or already acknowledged.
+func (q *Queue) Ack(ctx context.Context, m Message) error {
+	err := q.c.DeleteItem(ctx, StringKey("queue", q.name).String(), StringKey("id", m.ID).String(), q.with(WithCondition(received(m)))...)
+	if err != nil {
+		return fmt.Errorf("Ack failed: %w", err)
+	}
+
+	return nil
+}
+
+// ChangeVisibility makes the received message m visible again in d, e.g.
+// to extend the time to process it, or, with 0, to release it for another
+// receiver right away. It fails like Ack if m was received again.
+func (q *Queue) ChangeVisibility(ctx context.Context, m Message, d time.Duration) error {
+	u := NewUpdate().Set("visible", millis(q.now().Add(d)))
+	_, err := q.c.UpdateItem(ctx, StringKey("queue", q.name).String(), StringKey("id", m.ID).String(), u, q.with(WithCondition(received(m)))...)
+	if err != nil {
+		return fmt.Errorf("ChangeVisibility failed: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package libdy_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/flowerinthenight/libdy"
+	"github.com/flowerinthenight/libdy/libdytest"
+)
+
+func TestQueue(t *testing.T) {
+	ctx := context.Background()
+	f := libdytest.SetupFake(t, libdy.TableDef{Name: "t", PK: "queue", SK: "id"})
+	clock := libdy.NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	c := libdy.New(f, libdy.WithTable("t"), libdy.WithClock(clock), libdy.WithIDGen(counter()))
+	q := libdy.NewQueue(c, "jobs", time.Minute, libdy.TTL{Attr: "expires", In: time.Hour})
+	for _, body := range []string{"a", "b", "c"} {
+		if _, err := q.Enqueue(ctx, []byte(body)); err != nil {
+			t.Fatal(err)
+		}
+
+		clock.Advance(time.Millisecond)
+	}
+
+	// bodies dequeues up to n messages, and returns their bodies and
+	// receive counts.
+	bodies := func(n int) ([]libdy.Message, string) {
+		msgs, err := q.Dequeue(ctx, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var ret []string
+		for _, m := range msgs {
+			ret = append(ret, fmt.Sprint(string(m.Body), m.Receives))
+		}
+
+		return msgs, fmt.Sprint(ret)
+	}
+
+	first, got := bodies(2)
+	if got != "[a1 b1]" {
+		t.Fatalf("Dequeue = %s, want the oldest two", got)
+	}
+
+	if _, got := bodies(10); got != "[c1]" {
+		t.Fatalf("Dequeue = %s, want the one still visible", got)
+	}
+
+	if err := q.Ack(ctx, first[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unacknowledged, a is received again after its visibility timeout,
+	// and its previous receive can no longer acknowledge it.
+	clock.Advance(2 * time.Minute)
+	again, got := bodies(1)
+	if got != "[a2]" {
+		t.Fatalf("Dequeue after the visibility timeout = %s, want a again", got)
+	}
+
+	if err := q.Ack(ctx, first[0]); !errors.Is(err, libdy.ErrConditionFailed) {
+		t.Errorf("Ack of a previous receive: %v, want ErrConditionFailed", err)
+	}
+
+	if err := q.ChangeVisibility(ctx, again[0], 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, got := bodies(10); got != "[a3 c2]" {
+		t.Errorf("Dequeue after ChangeVisibility = %s, want a and c", got)
+	}
+
+	// Past the TTL, no message is received.
+	clock.Advance(2 * time.Hour)
+	if _, got := bodies(10); got != "[]" {
+		t.Errorf("Dequeue of expired messages = %s, want none", got)
+	}
+}
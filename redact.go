@@ -0,0 +1,142 @@
+package libdy
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+const redacted = "[REDACTED]"
+
+// RedactPolicy hides attribute values whose names match any of its patterns.
+// Patterns use path.Match syntax and are case-insensitive. Nested map
+// attributes are matched both by name and by dotted path (e.g. "addr.zip").
+type RedactPolicy struct {
+	patterns []string
+}
+
+func NewRedactPolicy(patterns ...string) *RedactPolicy {
+	p := &RedactPolicy{}
+	for _, v := range patterns {
+		p.patterns = append(p.patterns, strings.ToLower(v))
+	}
+
+	return p
+}
+
+func (p *RedactPolicy) Match(name string) bool {
+	if p == nil {
+		return false
+	}
+
+	name = strings.ToLower(name)
+	for _, pat := range p.patterns {
+		if ok, _ := path.Match(pat, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Redact returns a copy of item with matching attributes replaced by a
+// placeholder string. The input item is not modified.
+func (p *RedactPolicy) Redact(item map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	if p == nil || item == nil {
+		return item
+	}
+
+	return p.redactMap("", item)
+}
+
+func (p *RedactPolicy) redactMap(prefix string, m map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	ret := make(map[string]*dynamodb.AttributeValue, len(m))
+	for k, v := range m {
+		full := k
+		if prefix != "" {
+			full = prefix + "." + k
+		}
+
+		switch {
+		case p.Match(k) || p.Match(full):
+			ret[k] = &dynamodb.AttributeValue{S: aws.String(redacted)}
+		case v != nil && v.M != nil:
+			ret[k] = &dynamodb.AttributeValue{M: p.redactMap(full, v.M)}
+		default:
+			ret[k] = v
+		}
+	}
+
+	return ret
+}
+
+// Transform returns the policy as a read pipeline stage.
+func (p *RedactPolicy) Transform() Transform {
+	return func(item map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+		return p.Redact(item), nil
+	}
+}
+
+// String returns a compact, log-safe representation of item with sorted keys.
+func (p *RedactPolicy) String(item map[string]*dynamodb.AttributeValue) string {
+	return formatMap(p.Redact(item))
+}
+
+func formatMap(m map[string]*dynamodb.AttributeValue) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString("{")
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+
+		fmt.Fprintf(&b, "%v: %v", k, formatValue(m[k]))
+	}
+
+	b.WriteString("}")
+	return b.String()
+}
+
+func formatValue(v *dynamodb.AttributeValue) string {
+	switch {
+	case v == nil:
+		return "<nil>"
+	case v.S != nil:
+		return fmt.Sprintf("%q", *v.S)
+	case v.N != nil:
+		return *v.N
+	case v.BOOL != nil:
+		return fmt.Sprintf("%v", *v.BOOL)
+	case v.NULL != nil:
+		return "null"
+	case v.B != nil:
+		return fmt.Sprintf("<%d bytes>", len(v.B))
+	case v.M != nil:
+		return formatMap(v.M)
+	case v.L != nil:
+		s := make([]string, len(v.L))
+		for i, e := range v.L {
+			s[i] = formatValue(e)
+		}
+
+		return "[" + strings.Join(s, ", ") + "]"
+	case v.SS != nil:
+		return fmt.Sprintf("%q", aws.StringValueSlice(v.SS))
+	case v.NS != nil:
+		return fmt.Sprintf("%v", aws.StringValueSlice(v.NS))
+	case v.BS != nil:
+		return fmt.Sprintf("<%d binary values>", len(v.BS))
+	}
+
+	return "{}"
+}
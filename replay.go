@@ -0,0 +1,547 @@
+package libdy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// Defaults of ReplayConfig.
+const (
+	replayConcurrency = 64
+	replayPayload     = "payload"
+)
+
+// TraceRecord is a recorded operation: what it did, and to which keys, but
+// not the data. Traces are JSON lines of TraceRecords, with the keys in
+// DynamoDB JSON, as written by a TraceRecorder and read by Replay.
+type TraceRecord struct {
+	At    time.Duration                         `json:"at"`    // since the start of the trace
+	Op    string                                `json:"op"`    // the API, e.g. GetItem or BatchWriteItem
+	Table string                                `json:"table"` // the recorded table
+	Index string                                `json:"index,omitempty"`
+	Keys  []map[string]*dynamodb.AttributeValue `json:"keys,omitempty"`  // for Query, the partition key
+	Sizes []int                                 `json:"sizes,omitempty"` // bytes written per key; 0 is a delete in BatchWriteItem
+	Items int64                                 `json:"items,omitempty"` // items read by Query and Scan
+}
+
+// traceRecord is TraceRecord with the keys in DynamoDB JSON.
+type traceRecord struct {
+	At    time.Duration                `json:"at"`
+	Op    string                       `json:"op"`
+	Table string                       `json:"table"`
+	Index string                       `json:"index,omitempty"`
+	Keys  []map[string]json.RawMessage `json:"keys,omitempty"`
+	Sizes []int                        `json:"sizes,omitempty"`
+	Items int64                        `json:"items,omitempty"`
+}
+
+func (rec TraceRecord) MarshalJSON() ([]byte, error) {
+	t := traceRecord{At: rec.At, Op: rec.Op, Table: rec.Table, Index: rec.Index, Sizes: rec.Sizes, Items: rec.Items}
+	for _, key := range rec.Keys {
+		m := make(map[string]json.RawMessage, len(key))
+		for name, v := range key {
+			b, err := json.Marshal(typedJSON(v))
+			if err != nil {
+				return nil, err
+			}
+
+			m[name] = b
+		}
+
+		t.Keys = append(t.Keys, m)
+	}
+
+	return json.Marshal(t)
+}
+
+func (rec *TraceRecord) UnmarshalJSON(b []byte) error {
+	var t traceRecord
+	if err := json.Unmarshal(b, &t); err != nil {
+		return err
+	}
+
+	*rec = TraceRecord{At: t.At, Op: t.Op, Table: t.Table, Index: t.Index, Sizes: t.Sizes, Items: t.Items}
+	for _, m := range t.Keys {
+		key := make(map[string]*dynamodb.AttributeValue, len(m))
+		for name, raw := range m {
+			v, err := parseTypedJSON(raw)
+			if err != nil {
+				return fmt.Errorf("key attribute %s: %w", name, err)
+			}
+
+			key[name] = v
+		}
+
+		rec.Keys = append(rec.Keys, key)
+	}
+
+	return nil
+}
+
+// TraceRecorder is a dynamodbiface.DynamoDBAPI recording the item reads and
+// writes going through it (GetItem, PutItem, UpdateItem, DeleteItem, Query,
+// Scan, BatchGetItem, and BatchWriteItem, with context) as a trace for
+// Replay. Use it as the svc of a Client:
+//
+//	f, _ := os.Create("trace.jsonl")
+//	rec := libdy.NewTraceRecorder(svc, f, libdy.WithMask(libdy.MaskProfile{"*": libdy.MaskHash(salt)}))
+//	client := libdy.New(rec, libdy.WithTable("orders"))
+//
+// With WithMask, keys are masked before they are written; a deterministic
+// MaskFunc such as MaskHash keeps the key distribution, and hot keys, of the
+// traffic. Failed requests are recorded too, as they consumed capacity.
+type TraceRecorder struct {
+	dynamodbiface.DynamoDBAPI
+	o     options
+	mu    sync.Mutex
+	enc   *json.Encoder
+	start time.Time
+	keys  map[string][]string // key attribute names by table
+	err   error               // the first write error
+}
+
+func NewTraceRecorder(svc dynamodbiface.DynamoDBAPI, w io.Writer, opts ...Option) *TraceRecorder {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return &TraceRecorder{DynamoDBAPI: svc, o: o, enc: json.NewEncoder(w), start: o.now(), keys: map[string][]string{}}
+}
+
+// Err returns the error that stopped the recording, if any.
+func (r *TraceRecorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// keyAttrs returns the key attribute names of table, to tell the key of
+// items written.
+func (r *TraceRecorder) keyAttrs(ctx context.Context, table string) []string {
+	r.mu.Lock()
+	keys, ok := r.keys[table]
+	r.mu.Unlock()
+	if ok {
+		return keys
+	}
+
+	keys, err := tableKeyAttrs(ctx, r.DynamoDBAPI, table)
+	if err != nil {
+		return nil // try again next time
+	}
+
+	r.mu.Lock()
+	r.keys[table] = keys
+	r.mu.Unlock()
+	return keys
+}
+
+func (r *TraceRecorder) record(at time.Time, rec TraceRecord) {
+	keys := make([]map[string]*dynamodb.AttributeValue, len(rec.Keys))
+	for i, key := range rec.Keys {
+		keys[i] = r.o.mask.Mask(key)
+	}
+
+	rec.Keys = keys
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+
+	rec.At = at.Sub(r.start)
+	if err := r.enc.Encode(rec); err != nil {
+		r.err = fmt.Errorf("TraceRecorder failed: %w", err)
+	}
+}
+
+func (r *TraceRecorder) GetItemWithContext(ctx aws.Context, in *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	at := r.o.now()
+	r.record(at, TraceRecord{
+		Op:    "GetItem",
+		Table: aws.StringValue(in.TableName),
+		Keys:  []map[string]*dynamodb.AttributeValue{in.Key},
+	})
+
+	return r.DynamoDBAPI.GetItemWithContext(ctx, in, opts...)
+}
+
+func (r *TraceRecorder) PutItemWithContext(ctx aws.Context, in *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	at := r.o.now()
+	out, err := r.DynamoDBAPI.PutItemWithContext(ctx, in, opts...)
+	r.record(at, TraceRecord{
+		Op:    "PutItem",
+		Table: aws.StringValue(in.TableName),
+		Keys:  []map[string]*dynamodb.AttributeValue{keyOf(in.Item, r.keyAttrs(ctx, aws.StringValue(in.TableName)))},
+		Sizes: []int{itemSize(in.Item)},
+	})
+
+	return out, err
+}
+
+func (r *TraceRecorder) UpdateItemWithContext(ctx aws.Context, in *dynamodb.UpdateItemInput, opts ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	at := r.o.now()
+	// The size written is about that of the key and the values set.
+	r.record(at, TraceRecord{
+		Op:    "UpdateItem",
+		Table: aws.StringValue(in.TableName),
+		Keys:  []map[string]*dynamodb.AttributeValue{in.Key},
+		Sizes: []int{itemSize(in.Key) + itemSize(in.ExpressionAttributeValues)},
+	})
+
+	return r.DynamoDBAPI.UpdateItemWithContext(ctx, in, opts...)
+}
+
+func (r *TraceRecorder) DeleteItemWithContext(ctx aws.Context, in *dynamodb.DeleteItemInput, opts ...request.Option) (*dynamodb.DeleteItemOutput, error) {
+	at := r.o.now()
+	r.record(at, TraceRecord{
+		Op:    "DeleteItem",
+		Table: aws.StringValue(in.TableName),
+		Keys:  []map[string]*dynamodb.AttributeValue{in.Key},
+	})
+
+	return r.DynamoDBAPI.DeleteItemWithContext(ctx, in, opts...)
+}
+
+func (r *TraceRecorder) QueryWithContext(ctx aws.Context, in *dynamodb.QueryInput, opts ...request.Option) (*dynamodb.QueryOutput, error) {
+	at := r.o.now()
+	out, err := r.DynamoDBAPI.QueryWithContext(ctx, in, opts...)
+	rec := TraceRecord{
+		Op:    "Query",
+		Table: aws.StringValue(in.TableName),
+		Index: aws.StringValue(in.IndexName),
+	}
+
+	if pk := queryPartitionKey(in); pk != nil {
+		rec.Keys = []map[string]*dynamodb.AttributeValue{pk}
+	}
+
+	if out != nil {
+		rec.Items = aws.Int64Value(out.Count)
+	}
+
+	r.record(at, rec)
+	return out, err
+}
+
+func (r *TraceRecorder) ScanWithContext(ctx aws.Context, in *dynamodb.ScanInput, opts ...request.Option) (*dynamodb.ScanOutput, error) {
+	at := r.o.now()
+	out, err := r.DynamoDBAPI.ScanWithContext(ctx, in, opts...)
+	rec := TraceRecord{
+		Op:    "Scan",
+		Table: aws.StringValue(in.TableName),
+		Index: aws.StringValue(in.IndexName),
+	}
+
+	if out != nil {
+		rec.Items = aws.Int64Value(out.Count)
+	}
+
+	r.record(at, rec)
+	return out, err
+}
+
+func (r *TraceRecorder) BatchGetItemWithContext(ctx aws.Context, in *dynamodb.BatchGetItemInput, opts ...request.Option) (*dynamodb.BatchGetItemOutput, error) {
+	at := r.o.now()
+	for table, ka := range in.RequestItems {
+		r.record(at, TraceRecord{Op: "BatchGetItem", Table: table, Keys: ka.Keys})
+	}
+
+	return r.DynamoDBAPI.BatchGetItemWithContext(ctx, in, opts...)
+}
+
+func (r *TraceRecorder) BatchWriteItemWithContext(ctx aws.Context, in *dynamodb.BatchWriteItemInput, opts ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
+	at := r.o.now()
+	out, err := r.DynamoDBAPI.BatchWriteItemWithContext(ctx, in, opts...)
+	for table, reqs := range in.RequestItems {
+		keys := r.keyAttrs(ctx, table)
+		rec := TraceRecord{Op: "BatchWriteItem", Table: table}
+		for _, req := range reqs {
+			switch {
+			case req.PutRequest != nil:
+				rec.Keys = append(rec.Keys, keyOf(req.PutRequest.Item, keys))
+				rec.Sizes = append(rec.Sizes, itemSize(req.PutRequest.Item))
+			case req.DeleteRequest != nil:
+				rec.Keys = append(rec.Keys, req.DeleteRequest.Key)
+				rec.Sizes = append(rec.Sizes, 0)
+			}
+		}
+
+		r.record(at, rec)
+	}
+
+	return out, err
+}
+
+// queryCondition matches the partition key condition of a query.
+var queryCondition = regexp.MustCompile(`^\s*\(?\s*([#\w.]+)\s*=\s*(:\w+)\s*\)?\s*$`)
+
+// queryPartitionKey returns the partition key of a query, from the first
+// equality of its key condition, or nil if there's none.
+func queryPartitionKey(in *dynamodb.QueryInput) map[string]*dynamodb.AttributeValue {
+	expr := aws.StringValue(in.KeyConditionExpression)
+	if i := strings.Index(strings.ToUpper(expr), " AND "); i >= 0 {
+		expr = expr[:i]
+	}
+
+	m := queryCondition.FindStringSubmatch(expr)
+	if m == nil || in.ExpressionAttributeValues[m[2]] == nil {
+		return nil
+	}
+
+	name := m[1]
+	if strings.HasPrefix(name, "#") {
+		name = aws.StringValue(in.ExpressionAttributeNames[name])
+	}
+
+	return map[string]*dynamodb.AttributeValue{name: in.ExpressionAttributeValues[m[2]]}
+}
+
+// ReplayConfig configures a Replay.
+type ReplayConfig struct {
+	Speed       float64 // how much faster than recorded to replay, e.g. 2 or 0.5; the default is 1
+	Concurrency int     // operations in flight at most; the default is 64
+
+	// Tables maps the recorded tables to the ones to replay them against;
+	// records of other tables are skipped. If nil, every record is
+	// replayed against the Client's table.
+	Tables map[string]string
+
+	// Payload is the attribute holding the filler data written by replayed
+	// writes, sized as recorded; the default is "payload".
+	Payload string
+
+	// OnError, if set, is called with each operation that failed. It may be
+	// called concurrently.
+	OnError func(rec TraceRecord, err error)
+}
+
+// ReplayStats summarizes a Replay.
+type ReplayStats struct {
+	Ops       int64         // operations replayed
+	Errors    int64         // operations that failed
+	Throttled int64         // failed operations that were throttled
+	Skipped   int64         // records of other tables, or of unknown operations
+	MaxLag    time.Duration // how late the latest operation started; high when the replay can't keep up
+	Duration  time.Duration
+}
+
+// Replay replays the trace read from r, as written by a TraceRecorder,
+// against the Client's table (see cfg.Tables), through the Client, to
+// validate capacity settings, indexes, and options under realistic traffic:
+// each operation starts at its recorded time divided by cfg.Speed, with the
+// recorded keys. Reads read as much as recorded; writes write items of the
+// recorded size, with filler data in cfg.Payload. opts apply to every
+// operation, e.g. WithWriteCapacity or WithRetryPolicy; with WithClock, the
+// schedule follows the Clock.
+//
+//	f, _ := os.Open("trace.jsonl")
+//	stats, err := test.Replay(ctx, f, libdy.ReplayConfig{Speed: 2})
+//	log.Printf("%d ops, %d throttled, lag %v", stats.Ops, stats.Throttled, stats.MaxLag)
+//
+// Failed operations are counted, not returned; Replay fails on a bad trace,
+// or when ctx is done, returning the summary so far.
+func (c *Client) Replay(ctx context.Context, r io.Reader, cfg ReplayConfig, opts ...Option) (*ReplayStats, error) {
+	o, err := c.apply(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Speed <= 0 {
+		cfg.Speed = 1
+	}
+
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = replayConcurrency
+	}
+
+	if cfg.Payload == "" {
+		cfg.Payload = replayPayload
+	}
+
+	start, began := o.now(), time.Now()
+	stats := &ReplayStats{}
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, cfg.Concurrency)
+	err = func() error {
+		dec := json.NewDecoder(r)
+		for n := 1; ; n++ {
+			var rec TraceRecord
+			if err := dec.Decode(&rec); err != nil {
+				if errors.Is(err, io.EOF) {
+					return nil
+				}
+
+				return fmt.Errorf("trace record %d: %w", n, err)
+			}
+
+			ro := o
+			if cfg.Tables != nil {
+				ro.table = cfg.Tables[rec.Table]
+			}
+
+			if ro.table == "" || !replayable(rec.Op) {
+				stats.Skipped++
+				continue
+			}
+
+			at := start.Add(time.Duration(float64(rec.At) / cfg.Speed))
+			if wait := at.Sub(o.now()); wait > 0 {
+				select {
+				case <-o.after(wait):
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+
+			if lag := o.now().Sub(at); lag > stats.MaxLag {
+				stats.MaxLag = lag
+			}
+
+			stats.Ops++
+			wg.Add(1)
+			go func() {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+
+				err := c.replay(ctx, rec, cfg, ro)
+				if err == nil || errors.Is(err, ErrItemNotFound) {
+					return
+				}
+
+				atomic.AddInt64(&stats.Errors, 1)
+				if IsThrottle(err) {
+					atomic.AddInt64(&stats.Throttled, 1)
+				}
+
+				if cfg.OnError != nil {
+					cfg.OnError(rec, err)
+				}
+			}()
+		}
+	}()
+
+	wg.Wait()
+	stats.Duration = time.Since(began)
+	if err != nil {
+		return stats, fmt.Errorf("Replay failed: %w", err)
+	}
+
+	return stats, nil
+}
+
+func replayable(op string) bool {
+	switch op {
+	case "GetItem", "PutItem", "UpdateItem", "DeleteItem", "Query", "Scan", "BatchGetItem", "BatchWriteItem":
+		return true
+	}
+
+	return false
+}
+
+// replay performs the operation of rec on o.table.
+func (c *Client) replay(ctx context.Context, rec TraceRecord, cfg ReplayConfig, o options) error {
+	key := func(i int) map[string]*dynamodb.AttributeValue {
+		if i < len(rec.Keys) {
+			return rec.Keys[i]
+		}
+
+		return nil
+	}
+
+	size := func(i int) int {
+		if i < len(rec.Sizes) {
+			return rec.Sizes[i]
+		}
+
+		return 0
+	}
+
+	// filler returns the item with key i, padded to its recorded size.
+	filler := func(i int) map[string]*dynamodb.AttributeValue {
+		item := copyItem(key(i))
+		pad := size(i) - itemSize(item) - len(cfg.Payload)
+		if pad > 0 {
+			item[cfg.Payload] = &dynamodb.AttributeValue{B: make([]byte, pad)}
+		}
+
+		return item
+	}
+
+	limit := rec.Items
+	if limit < 1 {
+		limit = 1
+	}
+
+	var err error
+	switch rec.Op {
+	case "GetItem":
+		_, err = getItem(ctx, c.reader(o), key(0), o)
+	case "PutItem":
+		_, err = c.putItem(ctx, filler(0), o)
+	case "UpdateItem":
+		pad := size(0) - itemSize(key(0)) - len(cfg.Payload)
+		if pad < 1 {
+			pad = 1
+		}
+
+		_, err = updateItem(ctx, c.svc, key(0), NewUpdate().Set(cfg.Payload, &dynamodb.AttributeValue{B: make([]byte, pad)}), o)
+	case "DeleteItem":
+		_, err = c.deleteItem(ctx, key(0), o)
+	case "Query":
+		var pk Key
+		for name, v := range key(0) {
+			pk = valueKey(name, v)
+		}
+
+		o.limit = limit
+		if rec.Index != "" {
+			_, err = c.queryIndex(ctx, rec.Index, pk, o)
+		} else {
+			_, err = c.query(ctx, pk, Key{}, o)
+		}
+	case "Scan":
+		o.index = rec.Index
+		in := o.scanInput()
+		in.Limit = aws.Int64(limit)
+		_, err = scanPage(ctx, c.reader(o), in, o)
+	case "BatchGetItem":
+		_, err = batchGet(ctx, c.reader(o), o.table, rec.Keys, o)
+	case "BatchWriteItem":
+		reqs := make([]*dynamodb.WriteRequest, len(rec.Keys))
+		for i := range rec.Keys {
+			if size(i) == 0 {
+				reqs[i] = &dynamodb.WriteRequest{DeleteRequest: &dynamodb.DeleteRequest{Key: key(i)}}
+			} else {
+				reqs[i] = &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: filler(i)}}
+			}
+		}
+
+		err = batchWrite(ctx, c.svc, o.table, reqs, o)
+	}
+
+	return err
+}
@@ -0,0 +1,170 @@
+package libdy
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// replicaPoll is how often the replica waiter checks the status.
+const replicaPoll = 5 * time.Second
+
+// ReplicaStatus is the status of a replica of a global table.
+type ReplicaStatus struct {
+	Region      string
+	Status      string // dynamodb.ReplicaStatus*
+	Description string // why, for statuses other than ACTIVE, if known
+	Progress    int    // percent, while CREATING
+}
+
+func replicaStatuses(t *dynamodb.TableDescription) []ReplicaStatus {
+	ret := make([]ReplicaStatus, 0, len(t.Replicas))
+	for _, r := range t.Replicas {
+		progress, _ := strconv.Atoi(aws.StringValue(r.ReplicaStatusPercentProgress))
+		ret = append(ret, ReplicaStatus{
+			Region:      aws.StringValue(r.RegionName),
+			Status:      aws.StringValue(r.ReplicaStatus),
+			Description: aws.StringValue(r.ReplicaStatusDescription),
+			Progress:    progress,
+		})
+	}
+
+	return ret
+}
+
+func Replicas(svc dynamodbiface.DynamoDBAPI, table string) ([]ReplicaStatus, error) {
+	return ReplicasWithContext(context.Background(), svc, table)
+}
+
+// ReplicasWithContext returns the replicas of the global table table, one
+// per region, its own region included; none if it isn't a global table.
+func ReplicasWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string) ([]ReplicaStatus, error) {
+	t, err := DescribeTableWithContext(ctx, svc, table)
+	if err != nil {
+		return nil, err
+	}
+
+	return replicaStatuses(t), nil
+}
+
+func AddReplica(svc dynamodbiface.DynamoDBAPI, table, region string) error {
+	return AddReplicaWithContext(context.Background(), svc, table, region)
+}
+
+// AddReplicaWithContext adds a replica of table in region, making it a
+// global table (version 2019.11.21) if it isn't one yet. The table must
+// have streams with new and old images enabled. It doesn't wait for the
+// replica to be ACTIVE; see WaitForReplicasActiveWithContext.
+func AddReplicaWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, region string) error {
+	_, err := updateTable(ctx, svc, "AddReplica", &dynamodb.UpdateTableInput{
+		TableName: aws.String(table),
+		ReplicaUpdates: []*dynamodb.ReplicationGroupUpdate{{
+			Create: &dynamodb.CreateReplicationGroupMemberAction{RegionName: aws.String(region)},
+		}},
+	})
+
+	return err
+}
+
+func RemoveReplica(svc dynamodbiface.DynamoDBAPI, table, region string) error {
+	return RemoveReplicaWithContext(context.Background(), svc, table, region)
+}
+
+// RemoveReplicaWithContext deletes the replica of table in region, with its
+// data there. It doesn't wait for the replica to be gone.
+func RemoveReplicaWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, region string) error {
+	_, err := updateTable(ctx, svc, "RemoveReplica", &dynamodb.UpdateTableInput{
+		TableName: aws.String(table),
+		ReplicaUpdates: []*dynamodb.ReplicationGroupUpdate{{
+			Delete: &dynamodb.DeleteReplicationGroupMemberAction{RegionName: aws.String(region)},
+		}},
+	})
+
+	return err
+}
+
+func UpdateReplicas(svc dynamodbiface.DynamoDBAPI, table string, updates ...*dynamodb.ReplicationGroupUpdate) error {
+	return UpdateReplicasWithContext(context.Background(), svc, table, updates...)
+}
+
+// UpdateReplicasWithContext applies updates, replicas to create, update
+// (e.g. their KMS key or provisioned throughput), or delete, to table. As
+// DynamoDB takes one replica update per UpdateTable call, they are applied
+// in order, each after the replicas of the previous one are ACTIVE.
+//
+//	err := libdy.UpdateReplicasWithContext(ctx, svc, "orders",
+//		&dynamodb.ReplicationGroupUpdate{Create: &dynamodb.CreateReplicationGroupMemberAction{RegionName: aws.String("eu-west-1")}},
+//		&dynamodb.ReplicationGroupUpdate{Delete: &dynamodb.DeleteReplicationGroupMemberAction{RegionName: aws.String("us-west-2")}},
+//	)
+func UpdateReplicasWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, updates ...*dynamodb.ReplicationGroupUpdate) error {
+	for i, u := range updates {
+		if i > 0 {
+			if err := WaitForReplicasActiveWithContext(ctx, svc, table); err != nil {
+				return fmt.Errorf("UpdateReplicas failed: %w", err)
+			}
+		}
+
+		_, err := updateTable(ctx, svc, "UpdateReplicas", &dynamodb.UpdateTableInput{
+			TableName:      aws.String(table),
+			ReplicaUpdates: []*dynamodb.ReplicationGroupUpdate{u},
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func WaitForReplicasActive(svc dynamodbiface.DynamoDBAPI, table string, regions ...string) error {
+	return WaitForReplicasActiveWithContext(context.Background(), svc, table, regions...)
+}
+
+// WaitForReplicasActiveWithContext waits until table and its replicas in
+// regions, or all of them if none are given, are ACTIVE, and replicas being
+// deleted are gone, or until ctx is done. A replica that failed to create,
+// or lost access to its KMS key, fails.
+func WaitForReplicasActiveWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, regions ...string) error {
+	t := time.NewTicker(replicaPoll)
+	defer t.Stop()
+	for {
+		desc, err := DescribeTableWithContext(ctx, svc, table)
+		if err != nil {
+			return err
+		}
+
+		done := aws.StringValue(desc.TableStatus) == dynamodb.TableStatusActive
+		found := 0
+		for _, r := range replicaStatuses(desc) {
+			if len(regions) > 0 && !slices.Contains(regions, r.Region) {
+				continue
+			}
+
+			found++
+			switch r.Status {
+			case dynamodb.ReplicaStatusActive:
+			case dynamodb.ReplicaStatusCreationFailed, dynamodb.ReplicaStatusInaccessibleEncryptionCredentials:
+				return fmt.Errorf("WaitForReplicasActive failed: replica in %s is %s: %s", r.Region, r.Status, r.Description)
+			default:
+				done = false
+			}
+		}
+
+		if done && found >= len(regions) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("WaitForReplicasActive canceled: %w", ctx.Err())
+		case <-t.C:
+		}
+	}
+}
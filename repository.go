@@ -0,0 +1,372 @@
+package libdy
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// repoAttr is a key attribute of a Repository: its name and scalar type.
+type repoAttr struct {
+	name string
+	typ  string // dynamodb.ScalarAttributeType*
+}
+
+// def returns a in the "name:type" form of TableDef and IndexDef.
+func (a repoAttr) def() string {
+	if a.typ == dynamodb.ScalarAttributeTypeS {
+		return a.name
+	}
+
+	return a.name + ":" + a.typ
+}
+
+type repoIndex struct {
+	pk, sk repoAttr
+}
+
+// Repository is a typed data access layer for the items of type T, with the
+// table, key schema, and global secondary indexes declared by the `dyn`
+// struct tags of T:
+//
+//	type Order struct {
+//		_        struct{} `dyn:"table=orders"`
+//		Customer string   `dynamodbav:"customer" dyn:"pk,gsi1sk"`
+//		ID       string   `dynamodbav:"id" dyn:"sk"`
+//		Status   string   `dynamodbav:"status" dyn:"gsi1pk"`
+//		Total    int64    `dynamodbav:"total"`
+//	}
+//
+//	orders, err := libdy.NewRepository[Order](client)
+//	open, err := orders.QueryByIndex(ctx, "gsi1", "open")
+//
+// The pk and sk tags mark the partition and sort key of the table; <x>pk and
+// <x>sk those of the index x. A field may play several roles, separated by
+// commas. The table tag names the table, which is otherwise the Client's.
+// Attribute names follow the dynamodbav tags, and key types the field
+// types: numbers are N, []byte is B, and everything else S.
+//
+// Items are encoded and decoded as with Put and UnmarshalItems, so the
+// Client's converters and decoding options apply.
+type Repository[T any] struct {
+	c       *Client
+	table   string
+	pk, sk  repoAttr
+	indexes map[string]repoIndex
+}
+
+func NewRepository[T any](c *Client) (*Repository[T], error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("NewRepository failed: %v is not a struct", t)
+	}
+
+	r := &Repository[T]{c: c, indexes: map[string]repoIndex{}}
+	for _, f := range reflect.VisibleFields(t) {
+		tag := f.Tag.Get("dyn")
+		if tag == "" {
+			continue
+		}
+
+		attr, _ := fieldAttr(f)
+		a := repoAttr{name: attr, typ: scalarType(f.Type)}
+		for _, role := range strings.Split(tag, ",") {
+			role = strings.TrimSpace(role)
+			var x repoIndex
+			index := strings.TrimSuffix(strings.TrimSuffix(role, "pk"), "sk")
+			if index != "" {
+				x = r.indexes[index]
+			}
+
+			var key *repoAttr
+			switch {
+			case strings.HasPrefix(role, "table="):
+				r.table = strings.TrimPrefix(role, "table=")
+				continue
+			case role == "pk":
+				key = &r.pk
+			case role == "sk":
+				key = &r.sk
+			case index != role && strings.HasSuffix(role, "pk"):
+				key = &x.pk
+			case index != role && strings.HasSuffix(role, "sk"):
+				key = &x.sk
+			default:
+				return nil, fmt.Errorf("NewRepository failed: %s: unknown dyn tag %q", f.Name, role)
+			}
+
+			if !f.IsExported() {
+				return nil, fmt.Errorf("NewRepository failed: %s: unexported key field", f.Name)
+			}
+
+			if key.name != "" {
+				return nil, fmt.Errorf("NewRepository failed: %s: duplicate dyn tag %q", f.Name, role)
+			}
+
+			*key = a
+			if index != "" {
+				r.indexes[index] = x
+			}
+		}
+	}
+
+	if r.pk.name == "" {
+		return nil, fmt.Errorf("NewRepository failed: %v has no dyn:\"pk\" field", t)
+	}
+
+	for name, x := range r.indexes {
+		if x.pk.name == "" {
+			return nil, fmt.Errorf("NewRepository failed: index %s has no partition key", name)
+		}
+	}
+
+	return r, nil
+}
+
+// scalarType returns the key attribute type of values of t.
+func scalarType(t reflect.Type) string {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return dynamodb.ScalarAttributeTypeN
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return dynamodb.ScalarAttributeTypeB
+		}
+	}
+
+	return dynamodb.ScalarAttributeTypeS
+}
+
+// Table returns the table of the Repository, or "" for the Client's.
+func (r *Repository[T]) Table() string { return r.table }
+
+// Entity returns the EntityDef of T, named after its type, with the indexes
+// declared by its tags, for SyncIndexes.
+func (r *Repository[T]) Entity() EntityDef {
+	e := EntityDef{Name: reflect.TypeOf((*T)(nil)).Elem().Name()}
+	for name, x := range r.indexes {
+		def := IndexDef{Name: name, PK: x.pk.def()}
+		if x.sk.name != "" {
+			def.SK = x.sk.def()
+		}
+
+		e.Indexes = append(e.Indexes, def)
+	}
+
+	sort.Slice(e.Indexes, func(i, j int) bool { return e.Indexes[i].Name < e.Indexes[j].Name })
+	return e
+}
+
+// options returns opts after the table of r, so they may override it.
+func (r *Repository[T]) options(opts []Option) []Option {
+	if r.table == "" {
+		return opts
+	}
+
+	return append([]Option{WithTable(r.table)}, opts...)
+}
+
+// key returns the Key of attribute a with value v.
+func (a repoAttr) key(v interface{}) (Key, error) {
+	if a.name == "" {
+		return Key{}, nil
+	}
+
+	av, err := dynamodbattribute.Marshal(v)
+	if err != nil {
+		return Key{}, fmt.Errorf("key %s: %w", a.name, err)
+	}
+
+	if av.S == nil && av.N == nil && av.B == nil {
+		return Key{}, fmt.Errorf("key %s: %T is not a string, number, or binary", a.name, v)
+	}
+
+	return valueKey(a.name, av), nil
+}
+
+// keys returns the table key with the values pk and sk.
+func (r *Repository[T]) keys(pk, sk interface{}) (Key, Key, error) {
+	pkey, err := r.pk.key(pk)
+	if err != nil {
+		return Key{}, Key{}, err
+	}
+
+	skey, err := r.sk.key(sk)
+	return pkey, skey, err
+}
+
+// Get reads the item with the key pk and sk (ignored if T has no sort key)
+// as Client.GetItem does, failing with ErrItemNotFound if there is none.
+func (r *Repository[T]) Get(ctx context.Context, pk, sk interface{}, opts ...Option) (T, error) {
+	var ret T
+	o, err := r.c.apply(r.options(opts))
+	if err != nil {
+		return ret, err
+	}
+
+	pkey, skey, err := r.keys(pk, sk)
+	if err != nil {
+		return ret, fmt.Errorf("Get failed: %w", err)
+	}
+
+	item, err := r.c.getItem(ctx, pkey, skey, o)
+	if err != nil {
+		return ret, err
+	}
+
+	err = o.unmarshal(item, &ret)
+	return ret, err
+}
+
+// Put writes v as Client.PutItem does.
+func (r *Repository[T]) Put(ctx context.Context, v T, opts ...Option) error {
+	return Put(ctx, r.c, v, r.options(opts)...)
+}
+
+// Update applies u to the item with the key pk and sk as Client.UpdateItem
+// does, and returns the item as updated; set WithReturnValues to return
+// something else.
+func (r *Repository[T]) Update(ctx context.Context, pk, sk interface{}, u *Update, opts ...Option) (T, error) {
+	var ret T
+	o, err := r.c.apply(r.options(append([]Option{WithReturnValues(dynamodb.ReturnValueAllNew)}, opts...)))
+	if err != nil {
+		return ret, err
+	}
+
+	pkey, skey, err := r.keys(pk, sk)
+	if err != nil {
+		return ret, fmt.Errorf("Update failed: %w", err)
+	}
+
+	item, err := r.c.updateItem(ctx, pkey, skey, u, o)
+	if err != nil || len(item) == 0 {
+		return ret, err
+	}
+
+	err = o.unmarshal(item, &ret)
+	return ret, err
+}
+
+// UpdateFromStruct updates the item of old, the value read, to new, as
+// UpdateFromStruct does, and returns the item as updated, or new if nothing
+// changed.
+func (r *Repository[T]) UpdateFromStruct(ctx context.Context, old, new T, opts ...Option) (T, error) {
+	var ret T
+	o, err := r.c.apply(r.options(append([]Option{WithReturnValues(dynamodb.ReturnValueAllNew)}, opts...)))
+	if err != nil {
+		return ret, err
+	}
+
+	oldItem, err := o.marshal(old)
+	if err != nil {
+		return ret, fmt.Errorf("UpdateFromStruct failed: %w", err)
+	}
+
+	newItem, err := o.marshal(new)
+	if err != nil {
+		return ret, fmt.Errorf("UpdateFromStruct failed: %w", err)
+	}
+
+	var pkey, skey Key
+	if v := newItem[r.pk.name]; v != nil {
+		pkey = valueKey(r.pk.name, v)
+	}
+
+	if v := newItem[r.sk.name]; r.sk.name != "" && v != nil {
+		skey = valueKey(r.sk.name, v)
+	}
+
+	if pkey.Name == "" || (r.sk.name != "") != (skey.Name != "") {
+		return ret, fmt.Errorf("UpdateFromStruct failed: %w: no key", ErrInvalidRequest)
+	}
+
+	item, err := r.c.updateDiff(ctx, pkey, skey, oldItem, newItem, o)
+	if err != nil {
+		return ret, fmt.Errorf("UpdateFromStruct failed: %w", err)
+	}
+
+	if item == nil {
+		return new, nil
+	}
+
+	err = o.unmarshal(item, &ret)
+	return ret, err
+}
+
+// Delete deletes the item with the key pk and sk as Client.DeleteItem does.
+func (r *Repository[T]) Delete(ctx context.Context, pk, sk interface{}, opts ...Option) error {
+	o, err := r.c.apply(r.options(opts))
+	if err != nil {
+		return err
+	}
+
+	pkey, skey, err := r.keys(pk, sk)
+	if err != nil {
+		return fmt.Errorf("Delete failed: %w", err)
+	}
+
+	_, err = r.c.deleteItem(ctx, keyMap(pkey, skey), o)
+	return err
+}
+
+// QueryByPK reads the items under the partition key pk, as Client.Query
+// does, with its read options, e.g. WithSortKey and WithLimit.
+func (r *Repository[T]) QueryByPK(ctx context.Context, pk interface{}, opts ...Option) ([]T, error) {
+	o, err := r.c.apply(r.options(opts))
+	if err != nil {
+		return nil, err
+	}
+
+	pkey, err := r.pk.key(pk)
+	if err != nil {
+		return nil, fmt.Errorf("QueryByPK failed: %w", err)
+	}
+
+	res, err := r.c.query(ctx, pkey, Key{}, o)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []T
+	err = o.unmarshalItems(res.Items, &ret)
+	return ret, err
+}
+
+// QueryByIndex reads the items whose partition key in the index declared
+// by the tags of T is pk, as Client.QueryIndex does, with its read options.
+func (r *Repository[T]) QueryByIndex(ctx context.Context, index string, pk interface{}, opts ...Option) ([]T, error) {
+	x, ok := r.indexes[index]
+	if !ok {
+		return nil, fmt.Errorf("QueryByIndex failed: no index %s in the dyn tags", index)
+	}
+
+	o, err := r.c.apply(r.options(opts))
+	if err != nil {
+		return nil, err
+	}
+
+	pkey, err := x.pk.key(pk)
+	if err != nil {
+		return nil, fmt.Errorf("QueryByIndex failed: %w", err)
+	}
+
+	res, err := r.c.queryIndex(ctx, index, pkey, o)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []T
+	err = o.unmarshalItems(res.Items, &ret)
+	return ret, err
+}
@@ -0,0 +1,99 @@
+package libdy
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Result is the outcome of a paginated read.
+type Result struct {
+	Items []map[string]*dynamodb.AttributeValue
+
+	// LastKey is the continuation cursor: pass it back with WithStartKey to
+	// resume the read. It is nil when there are no more items.
+	LastKey map[string]*dynamodb.AttributeValue
+
+	// Partial is true when the read stopped early because its latency budget
+	// (see WithBudget) expired. Items holds whatever pages arrived in time.
+	Partial bool
+
+	// ConsumedCapacity is the capacity units consumed by all the pages, when
+	// requested (see WithConsumedCapacity and WithReadCapacity).
+	ConsumedCapacity float64
+
+	// Changed holds the keys of the items of earlier pages that changed
+	// before the last page was read (see WithSnapshot).
+	Changed []map[string]*dynamodb.AttributeValue
+
+	// Count and ScannedCount are the sums of those DynamoDB returned for the
+	// pages: the items that matched the filter (see WithFilter), and those
+	// read to find them, which cost the capacity. A ScannedCount much larger
+	// than Count means a filter doing the work of a key condition or index.
+	Count        int64
+	ScannedCount int64
+
+	Pages   int           // requests that returned a page
+	Retries int           // requests retried, e.g. when throttled
+	Elapsed time.Duration // from the first request to the last page
+}
+
+// WithBudget bounds the total time spent on a paginated read. When the budget
+// expires, the read stops and returns the pages fetched so far, flagged as
+// Partial, instead of blocking until all pages are in.
+func WithBudget(d time.Duration) Option {
+	return func(o *options) { o.budget = d }
+}
+
+// WithStartKey resumes a read from a cursor returned in Result.LastKey.
+func WithStartKey(key map[string]*dynamodb.AttributeValue) Option {
+	return func(o *options) { o.startKey = key }
+}
+
+// WithPageSize sets the number of items DynamoDB evaluates per request,
+// without capping the total.
+func WithPageSize(n int64) Option {
+	return func(o *options) { o.pageSize = n }
+}
+
+// WithMaxItems caps the total number of items a read returns. Pages are
+// sized so that DynamoDB stops exactly at the cap, so LastKey resumes right
+// after the last returned item.
+//
+// Unlike WithLimit, which is both the page size and a loose total cap (the
+// last page is not truncated), WithMaxItems and WithPageSize can be used
+// separately or together.
+func WithMaxItems(n int64) Option {
+	return func(o *options) { o.maxItems = n }
+}
+
+// pageLimit returns the Limit for the next request, given n items read so
+// far, or 0 to leave it as is.
+func (o options) pageLimit(n int64) int64 {
+	size := o.pageSize
+	if o.maxItems > 0 {
+		if rem := o.maxItems - n; size == 0 || rem < size {
+			size = rem
+		}
+	}
+
+	return size
+}
+
+// capped reports whether n items reach the total cap of a read.
+func (o options) capped(n int64) bool {
+	return (o.limit > 0 && n >= o.limit) || (o.maxItems > 0 && n >= o.maxItems)
+}
+
+func (o options) budgetContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.budget <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, o.budget)
+}
+
+func (o options) budgetExpired(ctx context.Context) bool {
+	return o.budget > 0 && ctx.Err() == context.DeadlineExceeded
+}
@@ -0,0 +1,81 @@
+package libdy_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/flowerinthenight/libdy"
+	"github.com/flowerinthenight/libdy/libdytest"
+)
+
+// queries is a Fake recording the Limit of each Query.
+type queries struct {
+	*libdytest.Fake
+	limits []int64
+}
+
+func (q *queries) QueryWithContext(ctx aws.Context, in *dynamodb.QueryInput, opts ...request.Option) (*dynamodb.QueryOutput, error) {
+	q.limits = append(q.limits, aws.Int64Value(in.Limit))
+	return q.Fake.QueryWithContext(ctx, in, opts...)
+}
+
+func TestQueryPageSize(t *testing.T) {
+	ctx := context.Background()
+	f := libdytest.SetupFake(t, libdy.TableDef{Name: "t", PK: "id", SK: "n:N"})
+	for i := 1; i <= 10; i++ {
+		item := map[string]*dynamodb.AttributeValue{"id": {S: aws.String("a")}, "n": {N: aws.String(fmt.Sprint(i))}}
+		if err := libdy.PutItemWithContext(ctx, f, "t", item); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		opts   []libdy.Option
+		items  string // the n of each item, in order
+		limits string
+		more   bool // whether LastKey is set
+	}{
+		{"page size", []libdy.Option{libdy.WithPageSize(4)}, "[10 9 8 7 6 5 4 3 2 1]", "[4 4 4]", false},
+		{"max items", []libdy.Option{libdy.WithMaxItems(3)}, "[10 9 8]", "[3]", true},
+		{"both", []libdy.Option{libdy.WithPageSize(2), libdy.WithMaxItems(5)}, "[10 9 8 7 6]", "[2 2 1]", true},
+		{"max items past the end", []libdy.Option{libdy.WithPageSize(4), libdy.WithMaxItems(20)}, "[10 9 8 7 6 5 4 3 2 1]", "[4 4 4]", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			q := &queries{Fake: f}
+			res, err := libdy.New(q, libdy.WithTable("t")).Query(ctx, "a", "", tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, item := range res.Items {
+				got = append(got, aws.StringValue(item["n"].N))
+			}
+
+			if fmt.Sprint(got) != tc.items || fmt.Sprint(q.limits) != tc.limits || (res.LastKey != nil) != tc.more {
+				t.Errorf("items %v in pages of %v, LastKey %v; want %s in pages of %s", got, q.limits, res.LastKey, tc.items, tc.limits)
+			}
+		})
+	}
+
+	// LastKey resumes right after the last item returned.
+	c := libdy.New(f, libdy.WithTable("t"))
+	res, err := c.Query(ctx, "a", "", libdy.WithMaxItems(4), libdy.WithPageSize(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next, err := c.Query(ctx, "a", "", libdy.WithMaxItems(4), libdy.WithStartKey(res.LastKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(next.Items) != 4 || aws.StringValue(next.Items[0]["n"].N) != "6" {
+		t.Errorf("resumed at %v, want n 6", next.Items)
+	}
+}
@@ -0,0 +1,233 @@
+package libdy
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/cenkalti/backoff"
+)
+
+// RetryState describes a failed attempt of a retried operation, for a
+// Retrier to decide on the next.
+type RetryState struct {
+	Attempt int           // the attempt that failed, from 1
+	Elapsed time.Duration // since the first attempt
+	Prev    time.Duration // the previous wait, 0 after the first attempt
+	Err     error         // nil for unprocessed batch items
+}
+
+// Retrier is a retry strategy, replacing the exponential backoff of
+// RetryPolicy (see WithRetrier). Next returns the wait before the next
+// attempt, or false to give up. It is called concurrently by operations, so
+// any state shared across them must be synchronized; waits past the context
+// deadline still give up early, as ErrRetriesTruncated.
+//
+// Besides RetryFunc, there are ConstantBackoff, DecorrelatedJitter, and
+// AdaptiveRetrier.
+type Retrier interface {
+	Next(s RetryState) (time.Duration, bool)
+}
+
+// RetryFunc adapts a function to a Retrier.
+type RetryFunc func(s RetryState) (time.Duration, bool)
+
+func (f RetryFunc) Next(s RetryState) (time.Duration, bool) { return f(s) }
+
+// RetryGate is implemented by a Retrier that also paces the attempts
+// themselves, e.g. client-side throttling: Before is called before every
+// attempt, retries or not, and After with its error, nil if it succeeded
+// or isn't retryable.
+type RetryGate interface {
+	Before(ctx context.Context) error
+	After(err error)
+}
+
+// WithRetrier retries operations per r instead of the RetryPolicy.
+//
+//	client := libdy.New(svc, libdy.WithRetrier(libdy.DecorrelatedJitter(50*time.Millisecond, 5*time.Second, 8)))
+func WithRetrier(r Retrier) Option {
+	return func(o *options) { o.retrier = r }
+}
+
+// ConstantBackoff waits wait between attempts, giving up after maxRetries
+// retries.
+func ConstantBackoff(wait time.Duration, maxRetries int) Retrier {
+	return RetryFunc(func(s RetryState) (time.Duration, bool) {
+		return wait, s.Attempt <= maxRetries
+	})
+}
+
+// DecorrelatedJitter waits a random time between base and three times the
+// previous wait, capped at maxWait, giving up after maxRetries retries: the
+// "decorrelated jitter" of the AWS Architecture Blog, which spreads out
+// clients retrying together better than exponential backoff does.
+func DecorrelatedJitter(base, maxWait time.Duration, maxRetries int) Retrier {
+	return RetryFunc(func(s RetryState) (time.Duration, bool) {
+		if s.Attempt > maxRetries {
+			return 0, false
+		}
+
+		hi := 3 * s.Prev
+		if hi <= base {
+			return base, true
+		}
+
+		return min(maxWait, base+time.Duration(rand.Int63n(int64(hi-base)))), true
+	})
+}
+
+// Retry quota costs, as in the SDKs' standard retry mode.
+const (
+	retryQuotaMax = 500
+	retryCost     = 5
+	retryTimeout  = 10 // cost of retrying a timeout
+)
+
+// AdaptiveRetrier is the equivalent of the SDKs' adaptive retry mode, to
+// share among the clients of a table or account:
+//
+//   - Retries draw from a token bucket refilled by successes, so a failing
+//     service isn't flooded with retries: once the bucket is empty, failed
+//     attempts aren't retried until requests succeed again.
+//   - After a throttling error, requests are rate limited client-side, at
+//     70% of the rate they were sent at, and the rate grows back by 10% per
+//     second while requests succeed.
+//
+// The waits between retries are those of the Retrier it wraps.
+type AdaptiveRetrier struct {
+	r Retrier
+
+	mu      sync.Mutex
+	quota   int
+	rate    float64 // requests per second; 0 until throttled
+	tokens  float64
+	last    time.Time // of the last refill
+	grown   time.Time // of the last increase of rate
+	sent    int       // requests since sentAt
+	sentAt  time.Time
+	measure float64 // requests per second sent, measured once per second
+}
+
+// NewAdaptiveRetrier returns an AdaptiveRetrier waiting per r between
+// retries, DecorrelatedJitter(50ms, 20s, 3) if nil.
+func NewAdaptiveRetrier(r Retrier) *AdaptiveRetrier {
+	if r == nil {
+		r = DecorrelatedJitter(50*time.Millisecond, 20*time.Second, 3)
+	}
+
+	now := time.Now()
+	return &AdaptiveRetrier{r: r, quota: retryQuotaMax, last: now, grown: now, sentAt: now}
+}
+
+func (a *AdaptiveRetrier) Next(s RetryState) (time.Duration, bool) {
+	cost := retryCost
+	switch ErrorCode(s.Err) {
+	case request.ErrCodeResponseTimeout, request.ErrCodeRequestError:
+		cost = retryTimeout
+	}
+
+	a.mu.Lock()
+	if a.quota < cost {
+		a.mu.Unlock()
+		return 0, false
+	}
+
+	a.quota -= cost
+	a.mu.Unlock()
+	return a.r.Next(s)
+}
+
+// Before waits for the client-side rate limit, if throttled.
+func (a *AdaptiveRetrier) Before(ctx context.Context) error {
+	for {
+		a.mu.Lock()
+		now := time.Now()
+		if d := now.Sub(a.sentAt); d >= time.Second {
+			a.measure, a.sent, a.sentAt = float64(a.sent)/d.Seconds(), 0, now
+		}
+
+		if a.rate == 0 {
+			a.sent++
+			a.mu.Unlock()
+			return nil
+		}
+
+		a.tokens = min(a.rate, a.tokens+now.Sub(a.last).Seconds()*a.rate)
+		a.last = now
+		if a.tokens >= 1 {
+			a.tokens--
+			a.sent++
+			a.mu.Unlock()
+			return nil
+		}
+
+		wait := time.Duration((1 - a.tokens) / a.rate * float64(time.Second))
+		a.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// After refills the retry quota on success, and adapts the rate limit.
+func (a *AdaptiveRetrier) After(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := time.Now()
+	if IsThrottle(err) {
+		rate := a.measure
+		if d := now.Sub(a.sentAt); rate == 0 && d > 0 {
+			rate = float64(a.sent) / d.Seconds() // in the first second
+		}
+
+		if a.rate > 0 {
+			rate = min(rate, a.rate)
+		}
+
+		a.rate, a.grown = max(1, 0.7*rate), now
+		a.tokens = min(a.tokens, a.rate)
+		return
+	}
+
+	if err != nil {
+		return
+	}
+
+	a.quota = min(retryQuotaMax, a.quota+1)
+	if a.rate > 0 {
+		a.rate *= 1 + 0.1*now.Sub(a.grown).Seconds()
+		a.grown = now
+		if a.measure > 0 && a.rate > 2*a.measure {
+			a.rate = 0 // no longer throttled
+		}
+	}
+}
+
+// retrierBackOff is a backoff.BackOff asking a Retrier.
+type retrierBackOff struct {
+	r       Retrier
+	start   time.Time
+	attempt int
+	prev    time.Duration
+	err     error // of the last attempt
+}
+
+func (b *retrierBackOff) NextBackOff() time.Duration {
+	b.attempt++
+	next, ok := b.r.Next(RetryState{Attempt: b.attempt, Elapsed: time.Since(b.start), Prev: b.prev, Err: b.err})
+	if !ok {
+		return backoff.Stop
+	}
+
+	b.prev = next
+	return next
+}
+
+func (b *retrierBackOff) Reset() {
+	b.start, b.attempt, b.prev, b.err = time.Now(), 0, 0, nil
+}
@@ -0,0 +1,316 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cenkalti/backoff"
+)
+
+var (
+	// ErrRetriesExhausted matches (with errors.Is) errors from operations that
+	// kept failing until the backoff policy gave up.
+	ErrRetriesExhausted = errors.New("libdy: retries exhausted")
+
+	// ErrRetriesTruncated matches errors from operations whose retries were
+	// cut short because the next backoff would have slept past the context
+	// deadline.
+	ErrRetriesTruncated = errors.New("libdy: retries truncated by deadline")
+)
+
+// RetryError is returned (wrapped) when a retriable operation still failed
+// after retrying.
+type RetryError struct {
+	Err       error // the last error
+	Attempts  int
+	Truncated bool // true if stopped early by the context deadline
+}
+
+func (e *RetryError) Error() string {
+	if e.Truncated {
+		return fmt.Sprintf("retries truncated by deadline after %d attempt(s): %v", e.Attempts, e.Err)
+	}
+
+	return fmt.Sprintf("retries exhausted after %d attempt(s): %v", e.Attempts, e.Err)
+}
+
+func (e *RetryError) Unwrap() error { return e.Err }
+
+func (e *RetryError) Is(target error) bool {
+	switch target {
+	case ErrRetriesExhausted:
+		return !e.Truncated
+	case ErrRetriesTruncated:
+		return e.Truncated
+	}
+
+	return false
+}
+
+// deadlineBackOff stops (instead of sleeping) when the next backoff would end
+// past the context deadline, and remembers that it did so.
+type deadlineBackOff struct {
+	backoff.BackOff
+	ctx       context.Context
+	truncated bool
+}
+
+func (b *deadlineBackOff) NextBackOff() time.Duration {
+	next := b.BackOff.NextBackOff()
+	if next == backoff.Stop {
+		return next
+	}
+
+	if deadline, ok := b.ctx.Deadline(); ok && time.Until(deadline) < next {
+		b.truncated = true
+		return backoff.Stop
+	}
+
+	return next
+}
+
+// RetryPolicy configures the exponential backoff of retried operations. See
+// WithRetrier for other strategies.
+// Zero fields keep the defaults: 500ms initial interval, multiplier 1.5, 60s
+// max interval, 15m max elapsed time, no max retries, and 0.5 jitter.
+type RetryPolicy struct {
+	InitialInterval time.Duration
+	Multiplier      float64
+	MaxInterval     time.Duration
+	MaxElapsedTime  time.Duration
+	MaxRetries      uint64  // 0 means bounded by MaxElapsedTime only
+	Jitter          float64 // randomization factor; negative means none
+}
+
+// WithRetryPolicy sets the backoff policy for retried operations.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(o *options) { o.retry = &p }
+}
+
+// backOff returns a fresh backoff for p, which may be nil, timed by clock,
+// which may be nil too.
+func (p *RetryPolicy) backOff(clock Clock) backoff.BackOff {
+	b := backoff.NewExponentialBackOff()
+	if clock != nil {
+		b.Clock = clock
+		b.Reset()
+	}
+
+	if p == nil {
+		return b
+	}
+
+	if p.InitialInterval > 0 {
+		b.InitialInterval = p.InitialInterval
+	}
+
+	if p.Multiplier > 0 {
+		b.Multiplier = p.Multiplier
+	}
+
+	if p.MaxInterval > 0 {
+		b.MaxInterval = p.MaxInterval
+	}
+
+	if p.MaxElapsedTime > 0 {
+		b.MaxElapsedTime = p.MaxElapsedTime
+	}
+
+	switch {
+	case p.Jitter < 0:
+		b.RandomizationFactor = 0
+	case p.Jitter > 0:
+		b.RandomizationFactor = p.Jitter
+	}
+
+	b.Reset()
+	if p.MaxRetries > 0 {
+		return backoff.WithMaxRetries(b, p.MaxRetries)
+	}
+
+	return b
+}
+
+// deadline returns the backoff for p, timed by clock, and bounded by the
+// deadline of ctx.
+func (p *RetryPolicy) deadline(ctx context.Context, clock Clock) *deadlineBackOff {
+	return &deadlineBackOff{BackOff: p.backOff(clock), ctx: ctx}
+}
+
+// backOff returns the backoff of an operation per o: its Retrier, if set,
+// or else its RetryPolicy, bounded by the deadline of ctx.
+func (o options) backOff(ctx context.Context) *deadlineBackOff {
+	if o.retrier == nil {
+		return o.retry.deadline(ctx, o.clock)
+	}
+
+	b := &retrierBackOff{r: o.retrier}
+	b.Reset()
+	return &deadlineBackOff{BackOff: b, ctx: ctx}
+}
+
+// retry runs op with exponential backoff (per o.retry) until it returns nil,
+// a permanent error, or the backoff gives up. Context cancellation aborts the
+// sleeps. Retries are logged to o.logger as name, and attempts hold off while
+// o.storm pauses the table. op is called with the context of the attempt,
+// bounded per WithTimeout.
+func retry(ctx context.Context, o options, name string, op func(ctx context.Context) error) error {
+	_, err := retryN(ctx, o, name, op)
+	return err
+}
+
+// retryN is retry, also returning the number of attempts.
+func retryN(ctx context.Context, o options, name string, op func(ctx context.Context) error) (int, error) {
+	if o.opTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.opTimeout)
+		defer cancel()
+	}
+
+	b := o.backOff(ctx)
+	gate, _ := o.retrier.(RetryGate)
+	attempts := 0
+	err := retryNotify(ctx, o, func() error {
+		if err := o.storm.wait(ctx, o.table); err != nil {
+			return backoff.Permanent(err)
+		}
+
+		probe, err := o.breaker.allow(o.table)
+		if err != nil {
+			return backoff.Permanent(err)
+		}
+
+		if gate != nil {
+			if err := gate.Before(ctx); err != nil {
+				o.breaker.release(o.table, probe)
+				return backoff.Permanent(err)
+			}
+		}
+
+		attempts++
+		err = o.attempt(ctx, op)
+		if gate != nil {
+			gate.After(err)
+		}
+
+		if opened, failures := o.breaker.record(o.table, probe, err); opened {
+			msg := fmt.Sprintf("circuit breaker open on table %s after %d failures", o.table, failures)
+			o.logf("%s", msg)
+			o.events.emit(o, Event{Type: EventCircuitOpen, Table: o.table, Message: msg, Pause: o.breaker.cooldown})
+		}
+
+		return err
+	}, b, func(err error, next time.Duration) {
+		o.logf("%s attempt %d failed, retrying in %v: %v", name, attempts, next, err)
+		r := RetryAttempt{Name: name, Table: o.table, Attempt: attempts, Wait: next, Err: err}
+		if o.onRetry != nil {
+			o.onRetry(r)
+		}
+
+		if d := o.storm.retried(o.table); d > 0 {
+			o.logf("retry storm on table %q, pausing it for %v", o.table, d)
+			o.count(MetricRetryStorm, map[string]string{"table": o.table}, 1)
+			msg := fmt.Sprintf("retry storm on table %s, paused for %v", o.table, d)
+			o.events.emit(o, Event{Type: EventThrottleStorm, Table: o.table, Message: msg, Pause: d})
+			if d >= o.storm.pause<<maxStormLevel {
+				o.events.emit(o, Event{Type: EventCircuitOpen, Table: o.table, Message: msg + " (max)", Pause: d})
+			}
+		}
+	})
+
+	switch {
+	case err == nil:
+		return attempts, nil
+	case errors.Is(err, ErrCircuitOpen):
+		return attempts, err // not retried
+	}
+
+	return attempts, &RetryError{Err: awsErr(err), Attempts: attempts, Truncated: b.truncated}
+}
+
+// WithTimeout bounds each request to DynamoDB to call, and each operation,
+// with all its retries and backoffs, to total, independently of the context
+// of the caller, so a single slow request, e.g. for a page of a query, can't
+// stall the whole call. A request timing out is retried, within total.
+// Either may be zero for no bound.
+//
+//	res, err := client.Query(ctx, pk, "", libdy.WithTimeout(2*time.Second, 10*time.Second))
+func WithTimeout(call, total time.Duration) Option {
+	return func(o *options) { o.callTimeout, o.opTimeout = call, total }
+}
+
+// attempt runs op with the context of an attempt, bounded per WithTimeout.
+// An attempt timing out is retried, unless ctx is done too.
+func (o options) attempt(ctx context.Context, op func(ctx context.Context) error) error {
+	if o.callTimeout <= 0 {
+		return op(ctx)
+	}
+
+	actx, cancel := context.WithTimeout(ctx, o.callTimeout)
+	defer cancel()
+	err := op(actx)
+	if actx.Err() != nil && ctx.Err() == nil {
+		return fmt.Errorf("request timed out after %v: %w", o.callTimeout, actx.Err())
+	}
+
+	return err
+}
+
+// retryNotify is backoff.RetryNotify, sleeping on the clock of o.
+func retryNotify(ctx context.Context, o options, op backoff.Operation, b *deadlineBackOff, notify backoff.Notify) error {
+	for {
+		err := op()
+		if err == nil {
+			return nil
+		}
+
+		if permanent, ok := err.(*backoff.PermanentError); ok {
+			return permanent.Err
+		}
+
+		if r, ok := b.BackOff.(*retrierBackOff); ok {
+			r.err = err
+		}
+
+		next := b.NextBackOff()
+		if next == backoff.Stop || ctx.Err() != nil {
+			return err
+		}
+
+		notify(err, next)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-o.after(next):
+		}
+	}
+}
+
+// WithRetryable replaces the classifier deciding which errors are retried
+// with backoff. The default is IsTransient: throttling, internal and
+// unavailable service errors, transaction conflicts, and network timeouts.
+// Context errors are never retried.
+func WithRetryable(fn func(err error) bool) Option {
+	return func(o *options) { o.retryable = fn }
+}
+
+// retriable returns err (causing a retry with backoff) if it is retryable,
+// and nil otherwise; the caller keeps the final error itself.
+func (o options) retriable(err error) error {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return nil
+	}
+
+	is := IsTransient
+	if o.retryable != nil {
+		is = o.retryable
+	}
+
+	if is(err) {
+		return err // will cause retry with backoff
+	}
+
+	return nil // final err is rerr
+}
@@ -0,0 +1,91 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+var outage = awserr.New(dynamodb.ErrCodeInternalServerError, "down", nil)
+
+// fast is a RetryPolicy of quick, exact backoffs, retrying n times.
+func fast(n uint64) Option {
+	return WithRetryPolicy(RetryPolicy{InitialInterval: time.Millisecond, MaxRetries: n, Jitter: -1})
+}
+
+// failing returns an op failing its first n attempts with outage, and
+// counting them all in calls.
+func failing(n int, calls *int) func(context.Context) error {
+	return func(context.Context) error {
+		*calls++
+		if *calls <= n {
+			return outage
+		}
+
+		return nil
+	}
+}
+
+func TestRetry(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		fails    int
+		attempts int
+		want     error
+	}{
+		{"success", 0, 1, nil},
+		{"retried", 2, 3, nil},
+		{"exhausted", 5, 3, ErrRetriesExhausted},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			o, err := New(nil, WithTable("t"), fast(2)).apply(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			calls := 0
+			n, err := retryN(context.Background(), o, "op", failing(tc.fails, &calls))
+			if !errors.Is(err, tc.want) || (err == nil) != (tc.want == nil) {
+				t.Fatalf("err = %v, want %v", err, tc.want)
+			}
+
+			if n != tc.attempts || calls != tc.attempts {
+				t.Errorf("%d attempts, %d calls, want %d", n, calls, tc.attempts)
+			}
+
+			var rerr *RetryError
+			if tc.want != nil && (!errors.As(err, &rerr) || rerr.Attempts != tc.attempts || rerr.Truncated) {
+				t.Errorf("err = %#v, want a RetryError of %d attempts", rerr, tc.attempts)
+			}
+		})
+	}
+}
+
+func TestRetryTruncated(t *testing.T) {
+	o, err := New(nil, WithTable("t"), WithRetryPolicy(RetryPolicy{InitialInterval: time.Hour, Jitter: -1})).apply(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	calls := 0
+	start := time.Now()
+	_, err = retryN(ctx, o, "op", failing(1, &calls))
+	if !errors.Is(err, ErrRetriesTruncated) || errors.Is(err, ErrRetriesExhausted) {
+		t.Fatalf("err = %v, want ErrRetriesTruncated", err)
+	}
+
+	var rerr *RetryError
+	if !errors.As(err, &rerr) || !rerr.Truncated || rerr.Attempts != 1 || calls != 1 {
+		t.Errorf("err = %#v after %d calls, want a truncated RetryError of 1 attempt", rerr, calls)
+	}
+
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("returned after %v, want no sleep", d)
+	}
+}
@@ -0,0 +1,78 @@
+package libdy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+func PutItemReturning(svc dynamodbiface.DynamoDBAPI, table string, item map[string]*dynamodb.AttributeValue, opts ...Option) (map[string]*dynamodb.AttributeValue, error) {
+	return PutItemReturningWithContext(context.Background(), svc, table, item, opts...)
+}
+
+// PutItemReturningWithContext is Client.PutItemReturning for table.
+func PutItemReturningWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, item map[string]*dynamodb.AttributeValue, opts ...Option) (map[string]*dynamodb.AttributeValue, error) {
+	o := options{table: table}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	o.returnValues = dynamodb.ReturnValueAllOld
+	return putItem(ctx, svc, item, o)
+}
+
+// PutItemReturning is PutItem, also returning the item it replaced, or nil
+// if there was none.
+func (c *Client) PutItemReturning(ctx context.Context, item map[string]*dynamodb.AttributeValue, opts ...Option) (map[string]*dynamodb.AttributeValue, error) {
+	o, err := c.apply(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	o.returnValues = dynamodb.ReturnValueAllOld
+	return c.putItem(ctx, item, o)
+}
+
+func DeleteItemReturning(svc dynamodbiface.DynamoDBAPI, table, pk, sk string, opts ...Option) (map[string]*dynamodb.AttributeValue, error) {
+	return DeleteItemReturningWithContext(context.Background(), svc, table, pk, sk, opts...)
+}
+
+// DeleteItemReturningWithContext is Client.DeleteItemReturning for table.
+func DeleteItemReturningWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, pk, sk string, opts ...Option) (map[string]*dynamodb.AttributeValue, error) {
+	o := options{table: table}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	kp, ks, err := New(svc).keys(ctx, o, pk, sk)
+	if err != nil {
+		return nil, fmt.Errorf("DeleteItemReturning failed: %w", err)
+	}
+
+	o.returnValues = dynamodb.ReturnValueAllOld
+	return deleteItem(ctx, svc, keyMap(kp, ks), o)
+}
+
+// DeleteItemReturning is DeleteItem, also returning the item it deleted, or
+// nil if there was none, e.g. for an audit log:
+//
+//	old, err := client.DeleteItemReturning(ctx, "id:42", "")
+//	if err == nil && old != nil {
+//		audit.Log("deleted", old)
+//	}
+func (c *Client) DeleteItemReturning(ctx context.Context, pk, sk string, opts ...Option) (map[string]*dynamodb.AttributeValue, error) {
+	o, err := c.apply(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	kp, ks, err := c.keys(ctx, o, pk, sk)
+	if err != nil {
+		return nil, fmt.Errorf("DeleteItemReturning failed: %w", err)
+	}
+
+	o.returnValues = dynamodb.ReturnValueAllOld
+	return c.deleteItem(ctx, keyMap(kp, ks), o)
+}
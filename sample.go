@@ -0,0 +1,175 @@
+package libdy
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// sampleFullScan is the item count up to which SampleItems reads the whole
+// table rather than probing it.
+const sampleFullScan = 1000
+
+func SampleItems(svc dynamodbiface.DynamoDBAPI, table string, n int, opts ...Option) ([]map[string]*dynamodb.AttributeValue, error) {
+	return SampleItemsWithContext(context.Background(), svc, table, n, opts...)
+}
+
+// SampleItemsWithContext is Client.SampleItems for table.
+func SampleItemsWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, n int, opts ...Option) ([]map[string]*dynamodb.AttributeValue, error) {
+	return New(svc, WithTable(table)).SampleItems(ctx, n, opts...)
+}
+
+// SampleItems returns up to n distinct items picked approximately uniformly
+// at random, for a quick look at the data without a full scan. Each item is
+// the first one after a random exclusive start key, in the hash order of a
+// scan, so it costs a read of about one item; items after larger gaps in
+// the hash space are a little likelier. Tables of up to about a thousand
+// items (per the table's approximate item count) are read whole instead,
+// for an exact sample. WithFilter restricts the sample, at the cost of more
+// probes; WithConsistentRead, WithProjection, and WithPipeline apply too.
+func (c *Client) SampleItems(ctx context.Context, n int, opts ...Option) ([]map[string]*dynamodb.AttributeValue, error) {
+	o, err := c.apply(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.svc.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(o.table)})
+	if err != nil {
+		return nil, fmt.Errorf("DescribeTable failed: %w", awsErr(err))
+	}
+
+	if n < 1 {
+		return []map[string]*dynamodb.AttributeValue{}, nil
+	}
+
+	if aws.Int64Value(res.Table.ItemCount) <= sampleFullScan {
+		return c.sampleAll(ctx, n, opts)
+	}
+
+	types := map[string]string{}
+	for _, d := range res.Table.AttributeDefinitions {
+		types[aws.StringValue(d.AttributeName)] = aws.StringValue(d.AttributeType)
+	}
+
+	var keyAttrs []string
+	for _, k := range res.Table.KeySchema {
+		keyAttrs = append(keyAttrs, aws.StringValue(k.AttributeName))
+	}
+
+	ret := []map[string]*dynamodb.AttributeValue{}
+	seen := map[string]bool{}
+	for tries := 0; len(ret) < n && tries < 4*n; tries++ {
+		start := map[string]*dynamodb.AttributeValue{}
+		for _, a := range keyAttrs {
+			start[a] = randomKeyValue(types[a])
+		}
+
+		in := &dynamodb.ScanInput{
+			TableName:         aws.String(o.table),
+			ExclusiveStartKey: start,
+			Limit:             aws.Int64(1),
+		}
+
+		if o.consistent {
+			in.ConsistentRead = aws.Bool(true)
+		}
+
+		page, err := scanPage(ctx, c.reader(o), in, o)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(page.Items) == 0 && page.LastEvaluatedKey == nil {
+			// Past the last item; wrap around to the first.
+			in.ExclusiveStartKey = nil
+			if page, err = scanPage(ctx, c.reader(o), in, o); err != nil {
+				return nil, err
+			}
+		}
+
+		if len(page.Items) == 0 {
+			continue // filtered out, or an empty table
+		}
+
+		// With Limit 1, the last evaluated key is the item's, even if the
+		// projection leaves it out.
+		item, key := page.Items[0], page.LastEvaluatedKey
+		if key == nil {
+			key = item
+		}
+
+		ids := make([]string, len(keyAttrs))
+		for i, a := range keyAttrs {
+			if v := key[a]; v != nil {
+				ids[i] = keyString(v)
+			}
+		}
+
+		id := strings.Join(ids, "\x00")
+		if seen[id] {
+			continue
+		}
+
+		seen[id] = true
+		if item, err = o.readItem(ctx, item); err != nil {
+			return nil, err
+		}
+
+		if item, err = o.authorizeItem(ctx, o.table, item); err != nil {
+			return nil, err
+		}
+
+		if item != nil {
+			ret = append(ret, item)
+		}
+	}
+
+	return ret, nil
+}
+
+// sampleAll picks n items of a scan of the whole table, with reservoir
+// sampling.
+func (c *Client) sampleAll(ctx context.Context, n int, opts []Option) ([]map[string]*dynamodb.AttributeValue, error) {
+	pages := c.ScanPages(opts...)
+	defer pages.Close()
+	ret := []map[string]*dynamodb.AttributeValue{}
+	i := 0
+	for pages.Next(ctx) {
+		for _, item := range pages.Page() {
+			if len(ret) < n {
+				ret = append(ret, item)
+			} else if j := rand.Intn(i + 1); j < n {
+				ret[j] = item
+			}
+
+			i++
+		}
+	}
+
+	if err := pages.Err(); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// randomKeyValue returns a random key attribute value of type typ (S, N, or
+// B), whose hash is a random point of the table's key space.
+func randomKeyValue(typ string) *dynamodb.AttributeValue {
+	switch typ {
+	case dynamodb.ScalarAttributeTypeN:
+		return &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(rand.Int63(), 10))}
+	case dynamodb.ScalarAttributeTypeB:
+		b := make([]byte, 16)
+		rand.Read(b)
+		return &dynamodb.AttributeValue{B: b}
+	}
+
+	return &dynamodb.AttributeValue{S: aws.String(strconv.FormatUint(rand.Uint64(), 36))}
+}
@@ -0,0 +1,194 @@
+package libdy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling"
+	"github.com/aws/aws-sdk-go/service/applicationautoscaling/applicationautoscalingiface"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+func SetBillingMode(svc dynamodbiface.DynamoDBAPI, table, mode string, rcu, wcu int64) error {
+	return SetBillingModeWithContext(context.Background(), svc, table, mode, rcu, wcu)
+}
+
+// SetBillingModeWithContext switches table to mode, one of
+// dynamodb.BillingModePayPerRequest or dynamodb.BillingModeProvisioned.
+// Provisioned tables get rcu and wcu, and so do their global secondary
+// indexes, after the same quota guard as CreateTableWithContext; they're
+// ignored for on-demand. DynamoDB allows a switch to provisioned once per
+// 24 hours. It doesn't wait for the table to be ACTIVE.
+func SetBillingModeWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, mode string, rcu, wcu int64) error {
+	input := &dynamodb.UpdateTableInput{
+		TableName:   aws.String(table),
+		BillingMode: aws.String(mode),
+	}
+
+	if mode == dynamodb.BillingModeProvisioned {
+		t, err := DescribeTableWithContext(ctx, svc, table)
+		if err != nil {
+			return fmt.Errorf("SetBillingMode failed: %w", err)
+		}
+
+		pt := &dynamodb.ProvisionedThroughput{ReadCapacityUnits: aws.Int64(rcu), WriteCapacityUnits: aws.Int64(wcu)}
+		input.ProvisionedThroughput = pt
+		caps := []capacity{throughput(table, pt)}
+		for _, gsi := range t.GlobalSecondaryIndexes {
+			input.GlobalSecondaryIndexUpdates = append(input.GlobalSecondaryIndexUpdates, &dynamodb.GlobalSecondaryIndexUpdate{
+				Update: &dynamodb.UpdateGlobalSecondaryIndexAction{IndexName: gsi.IndexName, ProvisionedThroughput: pt},
+			})
+
+			caps = append(caps, throughput(aws.StringValue(gsi.IndexName), pt))
+		}
+
+		if err := checkQuota(ctx, svc, caps); err != nil {
+			return fmt.Errorf("SetBillingMode failed: %w", err)
+		}
+	}
+
+	_, err := updateTable(ctx, svc, "SetBillingMode", input)
+	return err
+}
+
+// AutoScaling is the auto scaling of the read or write capacity of a
+// provisioned table or index, tracking a target utilization.
+type AutoScaling struct {
+	Min, Max          int64   // capacity units
+	TargetUtilization float64 // percent of the consumed capacity; the default is 70
+	ScaleInCooldown   time.Duration
+	ScaleOutCooldown  time.Duration
+}
+
+func RegisterAutoScaling(aas applicationautoscalingiface.ApplicationAutoScalingAPI, table, index string, read, write AutoScaling) error {
+	return RegisterAutoScalingWithContext(context.Background(), aas, table, index, read, write)
+}
+
+// RegisterAutoScalingWithContext registers the read and write capacity of
+// table, or of its global secondary index index if not "", as Application
+// Auto Scaling targets, with target tracking policies per read and write.
+// An AutoScaling without Max leaves its capacity as it is. Registering
+// again updates the targets and policies.
+//
+//	as := libdy.AutoScaling{Min: 5, Max: 500}
+//	err := libdy.RegisterAutoScalingWithContext(ctx, aas, "orders", "", as, as)
+//	err = libdy.RegisterAutoScalingWithContext(ctx, aas, "orders", "by-customer", as, as)
+func RegisterAutoScalingWithContext(ctx context.Context, aas applicationautoscalingiface.ApplicationAutoScalingAPI, table, index string, read, write AutoScaling) error {
+	for _, d := range scalingDims(table, index) {
+		as := read
+		if d.write {
+			as = write
+		}
+
+		if as.Max == 0 {
+			continue
+		}
+
+		if err := registerScaling(ctx, aas, d, as); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func DeregisterAutoScaling(aas applicationautoscalingiface.ApplicationAutoScalingAPI, table, index string) error {
+	return DeregisterAutoScalingWithContext(context.Background(), aas, table, index)
+}
+
+// DeregisterAutoScalingWithContext removes the auto scaling of table, or of
+// its global secondary index index if not "", with its policies, e.g.
+// before SetBillingModeWithContext switches it to on-demand. Capacity not
+// registered is skipped.
+func DeregisterAutoScalingWithContext(ctx context.Context, aas applicationautoscalingiface.ApplicationAutoScalingAPI, table, index string) error {
+	for _, d := range scalingDims(table, index) {
+		_, err := aas.DeregisterScalableTargetWithContext(ctx, &applicationautoscaling.DeregisterScalableTargetInput{
+			ServiceNamespace:  aws.String(applicationautoscaling.ServiceNamespaceDynamodb),
+			ResourceId:        aws.String(d.resource),
+			ScalableDimension: aws.String(d.dim),
+		})
+
+		if err != nil && ErrorCode(err) != applicationautoscaling.ErrCodeObjectNotFoundException {
+			return fmt.Errorf("DeregisterScalableTarget failed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// scalingDim is a scalable dimension of a table or index.
+type scalingDim struct {
+	resource string // e.g. "table/orders/index/by-customer"
+	dim      string // applicationautoscaling.ScalableDimension*
+	metric   string // applicationautoscaling.MetricType*
+	write    bool
+}
+
+func scalingDims(table, index string) []scalingDim {
+	if index == "" {
+		resource := "table/" + table
+		return []scalingDim{
+			{resource, applicationautoscaling.ScalableDimensionDynamodbTableReadCapacityUnits, applicationautoscaling.MetricTypeDynamoDbreadCapacityUtilization, false},
+			{resource, applicationautoscaling.ScalableDimensionDynamodbTableWriteCapacityUnits, applicationautoscaling.MetricTypeDynamoDbwriteCapacityUtilization, true},
+		}
+	}
+
+	resource := "table/" + table + "/index/" + index
+	return []scalingDim{
+		{resource, applicationautoscaling.ScalableDimensionDynamodbIndexReadCapacityUnits, applicationautoscaling.MetricTypeDynamoDbreadCapacityUtilization, false},
+		{resource, applicationautoscaling.ScalableDimensionDynamodbIndexWriteCapacityUnits, applicationautoscaling.MetricTypeDynamoDbwriteCapacityUtilization, true},
+	}
+}
+
+func registerScaling(ctx context.Context, aas applicationautoscalingiface.ApplicationAutoScalingAPI, d scalingDim, as AutoScaling) error {
+	if as.Min <= 0 || as.Min > as.Max {
+		return fmt.Errorf("RegisterAutoScaling failed: %s: invalid capacity range %d-%d", d.dim, as.Min, as.Max)
+	}
+
+	_, err := aas.RegisterScalableTargetWithContext(ctx, &applicationautoscaling.RegisterScalableTargetInput{
+		ServiceNamespace:  aws.String(applicationautoscaling.ServiceNamespaceDynamodb),
+		ResourceId:        aws.String(d.resource),
+		ScalableDimension: aws.String(d.dim),
+		MinCapacity:       aws.Int64(as.Min),
+		MaxCapacity:       aws.Int64(as.Max),
+	})
+
+	if err != nil {
+		return fmt.Errorf("RegisterScalableTarget failed: %w", err)
+	}
+
+	target := as.TargetUtilization
+	if target <= 0 {
+		target = 70
+	}
+
+	kind := "read"
+	if d.write {
+		kind = "write"
+	}
+
+	_, err = aas.PutScalingPolicyWithContext(ctx, &applicationautoscaling.PutScalingPolicyInput{
+		PolicyName:        aws.String(fmt.Sprintf("libdy-%s-%s", kind, d.resource)),
+		PolicyType:        aws.String(applicationautoscaling.PolicyTypeTargetTrackingScaling),
+		ServiceNamespace:  aws.String(applicationautoscaling.ServiceNamespaceDynamodb),
+		ResourceId:        aws.String(d.resource),
+		ScalableDimension: aws.String(d.dim),
+		TargetTrackingScalingPolicyConfiguration: &applicationautoscaling.TargetTrackingScalingPolicyConfiguration{
+			TargetValue: aws.Float64(target),
+			PredefinedMetricSpecification: &applicationautoscaling.PredefinedMetricSpecification{
+				PredefinedMetricType: aws.String(d.metric),
+			},
+			ScaleInCooldown:  aws.Int64(int64(as.ScaleInCooldown.Seconds())),
+			ScaleOutCooldown: aws.Int64(int64(as.ScaleOutCooldown.Seconds())),
+		},
+	})
+
+	if err != nil {
+		return fmt.Errorf("PutScalingPolicy failed: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,197 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// CheckpointFuncs are Checkpoints kept by the caller, e.g. in a database
+// or a job scheduler: Load returns the cursors saved with Save. A nil Load
+// starts from the beginning.
+type CheckpointFuncs struct {
+	LoadFunc func(ctx context.Context) (map[int]string, error)
+	SaveFunc func(ctx context.Context, segment int, cursor string) error
+}
+
+func (f CheckpointFuncs) Load(ctx context.Context) (map[int]string, error) {
+	if f.LoadFunc == nil {
+		return map[int]string{}, nil
+	}
+
+	return f.LoadFunc(ctx)
+}
+
+func (f CheckpointFuncs) Save(ctx context.Context, segment int, cursor string) error {
+	return f.SaveFunc(ctx, segment, cursor)
+}
+
+type itemCheckpoints struct {
+	c      *Client
+	pk, sk string
+	opts   []Option
+}
+
+// ItemCheckpoints keeps checkpoints in the control item with the key pk
+// and sk, in the table of c (or the one set in opts), one attribute per
+// segment: segment_0, segment_1, and so on. The item is created by the
+// first Save; delete it to start over. Keep it out of a table being
+// scanned, where the scan would see it.
+func ItemCheckpoints(c *Client, pk, sk string, opts ...Option) Checkpoints {
+	return &itemCheckpoints{c: c, pk: pk, sk: sk, opts: opts}
+}
+
+func (ic *itemCheckpoints) Load(ctx context.Context) (map[int]string, error) {
+	opts := append(ic.opts[:len(ic.opts):len(ic.opts)], WithConsistentRead())
+	item, err := ic.c.GetItem(ctx, ic.pk, ic.sk, opts...)
+	switch {
+	case errors.Is(err, ErrItemNotFound):
+		return map[int]string{}, nil
+	case err != nil:
+		return nil, err
+	}
+
+	m := map[int]string{}
+	for name, v := range item {
+		seg, ok := strings.CutPrefix(name, "segment_")
+		if !ok || v.S == nil {
+			continue
+		}
+
+		n, err := strconv.Atoi(seg)
+		if err != nil {
+			continue
+		}
+
+		m[n] = *v.S
+	}
+
+	return m, nil
+}
+
+func (ic *itemCheckpoints) Save(ctx context.Context, segment int, cursor string) error {
+	u := NewUpdate().Set("segment_"+strconv.Itoa(segment), &dynamodb.AttributeValue{S: aws.String(cursor)})
+	_, err := ic.c.UpdateItem(ctx, ic.pk, ic.sk, u, ic.opts...)
+	return err
+}
+
+// ScanCheckpointConfig is the configuration of ScanWithCheckpoint.
+type ScanCheckpointConfig struct {
+	Segments    int // parallel scan segments; the default is 1
+	Checkpoints Checkpoints
+
+	// Interval is the least time between checkpoints of a segment; 0 saves
+	// one after every page. The last page of a segment is always saved.
+	Interval time.Duration
+}
+
+func ScanWithCheckpoint(svc dynamodbiface.DynamoDBAPI, table string, cfg ScanCheckpointConfig, fn func(segment int, items []map[string]*dynamodb.AttributeValue) error, opts ...Option) error {
+	return ScanWithCheckpointWithContext(context.Background(), svc, table, cfg, fn, opts...)
+}
+
+// ScanWithCheckpointWithContext is Client.ScanWithCheckpoint for table.
+func ScanWithCheckpointWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, cfg ScanCheckpointConfig, fn func(segment int, items []map[string]*dynamodb.AttributeValue) error, opts ...Option) error {
+	return New(svc, WithTable(table)).ScanWithCheckpoint(ctx, cfg, fn, opts...)
+}
+
+// ScanWithCheckpoint scans the table like ParallelScanFunc, saving the
+// progress of each segment to cfg.Checkpoints as it goes (see
+// FileCheckpoints, ItemCheckpoints, and CheckpointFuncs), so a long sweep
+// run again after a crash resumes where it stopped instead of starting
+// over:
+//
+//	err := client.ScanWithCheckpoint(ctx, libdy.ScanCheckpointConfig{
+//		Segments:    8,
+//		Checkpoints: libdy.FileCheckpoints("/var/tmp/sweep.json"),
+//		Interval:    10 * time.Second,
+//	}, sweep, libdy.WithConcurrency(8), libdy.WithScanAck())
+//
+// A page is saved only after fn returns for it, so pages since the last
+// checkpoint are passed to fn again after a crash, but none is skipped.
+// When the scan stops on an error or a canceled ctx, the progress not yet
+// saved is saved before returning. Segments completed in a previous run
+// are skipped, so a finished scan does nothing until its checkpoints are
+// deleted.
+func (c *Client) ScanWithCheckpoint(ctx context.Context, cfg ScanCheckpointConfig, fn func(segment int, items []map[string]*dynamodb.AttributeValue) error, opts ...Option) error {
+	o, err := c.apply(opts)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Checkpoints == nil {
+		return fmt.Errorf("ScanWithCheckpoint failed: no checkpoints")
+	}
+
+	segments := max(cfg.Segments, 1)
+	cursors, err := cfg.Checkpoints.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("ScanWithCheckpoint failed: %w", err)
+	}
+
+	starts := map[int]map[string]*dynamodb.AttributeValue{}
+	done := map[int]bool{}
+	for seg, cursor := range cursors {
+		if cursor == "" {
+			done[seg] = true
+			continue
+		}
+
+		if starts[seg], err = DecodeCursor(cursor); err != nil {
+			return fmt.Errorf("ScanWithCheckpoint failed: segment %d: %w", seg, err)
+		}
+	}
+
+	// Each segment is read by one worker at a time, so these need no lock.
+	saved := make([]time.Time, segments)
+	pending := make([]*string, segments) // not saved yet
+	for i := range saved {
+		saved[i] = o.now()
+	}
+
+	save := func(ctx context.Context, seg int) error {
+		if err := cfg.Checkpoints.Save(ctx, seg, *pending[seg]); err != nil {
+			return fmt.Errorf("ScanWithCheckpoint failed: checkpoint: %w", err)
+		}
+
+		saved[seg], pending[seg] = o.now(), nil
+		return nil
+	}
+
+	err = c.parallelScan(ctx, segments, starts, done, func(seg int, items []map[string]*dynamodb.AttributeValue, next map[string]*dynamodb.AttributeValue) error {
+		if err := fn(seg, items); err != nil {
+			return err
+		}
+
+		cursor, err := EncodeCursor(next)
+		if err != nil {
+			return err
+		}
+
+		pending[seg] = &cursor
+		if next != nil && o.now().Sub(saved[seg]) < cfg.Interval {
+			return nil
+		}
+
+		return save(ctx, seg)
+	}, o)
+
+	// Save the progress of segments stopped midway, even if ctx is done.
+	for seg := range pending {
+		if pending[seg] == nil {
+			continue
+		}
+
+		if serr := save(context.WithoutCancel(ctx), seg); serr != nil && err == nil {
+			err = serr
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,91 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+var (
+	// ErrScanBlocked matches (with errors.Is) a *ScanBlockedError.
+	ErrScanBlocked = errors.New("libdy: full table scan blocked")
+)
+
+// ScanBlockedError is returned when the scan guard (see WithScanGuard)
+// rejects a full table scan.
+type ScanBlockedError struct {
+	Table     string
+	SizeBytes int64
+	Max       int64
+}
+
+func (e *ScanBlockedError) Error() string {
+	return fmt.Sprintf("full scan of %s (%d bytes) exceeds %d bytes; use WithScanAck to proceed", e.Table, e.SizeBytes, e.Max)
+}
+
+func (e *ScanBlockedError) Is(target error) bool { return target == ErrScanBlocked }
+
+// DynamoDB updates table sizes about every six hours.
+const scanGuardTTL = time.Hour
+
+// scanGuard rejects unbounded scans of tables larger than max bytes.
+type scanGuard struct {
+	max   int64
+	mu    sync.Mutex
+	sizes map[string]tableSize
+}
+
+type tableSize struct {
+	bytes int64
+	at    time.Time
+}
+
+// WithScanGuard makes the Client's unbounded scans (Scan, ScanItems,
+// ScanPages, and ScanIter without WithLimit or WithMaxItems) fail with
+// ErrScanBlocked on tables larger than maxBytes, per DescribeTable, unless
+// acknowledged with WithScanAck. Table sizes are cached for an hour.
+func WithScanGuard(maxBytes int64) Option {
+	g := &scanGuard{max: maxBytes, sizes: map[string]tableSize{}}
+	return func(o *options) { o.scanGuard = g }
+}
+
+// WithScanAck acknowledges a full scan of a large table, letting it past
+// WithScanGuard. Pass it per call.
+func WithScanAck() Option {
+	return func(o *options) { o.scanAck = true }
+}
+
+// checkScan applies the scan guard, if any, to a scan with options o.
+func (o options) checkScan(ctx context.Context, svc dynamodbiface.DynamoDBAPI) error {
+	g := o.scanGuard
+	if g == nil || o.scanAck || o.limit > 0 || o.maxItems > 0 {
+		return nil
+	}
+
+	g.mu.Lock()
+	s, ok := g.sizes[o.table]
+	g.mu.Unlock()
+	if !ok || time.Since(s.at) > scanGuardTTL {
+		res, err := svc.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(o.table)})
+		if err != nil {
+			return fmt.Errorf("DescribeTable failed: %w", awsErr(err))
+		}
+
+		s = tableSize{bytes: aws.Int64Value(res.Table.TableSizeBytes), at: time.Now()}
+		g.mu.Lock()
+		g.sizes[o.table] = s
+		g.mu.Unlock()
+	}
+
+	if s.bytes > g.max {
+		return &ScanBlockedError{Table: o.table, SizeBytes: s.bytes, Max: g.max}
+	}
+
+	return nil
+}
@@ -0,0 +1,304 @@
+package libdy
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+var (
+	// ErrSchemaViolation matches (with errors.Is) writes rejected by the
+	// table's schema; see SchemaError.
+	ErrSchemaViolation = errors.New("libdy: schema violation")
+)
+
+// SchemaError lists the schema violations of a rejected write.
+type SchemaError struct {
+	Table      string
+	Violations []string // e.g. `status: "gone" not in [active disabled]`
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("%v: %s: %s", ErrSchemaViolation, e.Table, strings.Join(e.Violations, "; "))
+}
+
+func (e *SchemaError) Is(target error) bool { return target == ErrSchemaViolation }
+
+// AttrSchema constrains one attribute. Type is a DynamoDB type descriptor:
+// "S", "N", "B", "BOOL", "NULL", "M", "L", "SS", "NS", or "BS". Enum, if
+// set, lists the allowed values of a string or number attribute.
+type AttrSchema struct {
+	Type     string
+	Required bool
+	Enum     []string
+}
+
+// Schema maps attribute names to their constraints. Attributes not in the
+// schema are unconstrained.
+type Schema map[string]AttrSchema
+
+// Schemas is a registry of per-table schemas shared by the services using
+// the tables (see WithSchemas). Register them at startup:
+//
+//	schemas := libdy.NewSchemas()
+//	schemas.Register("users", libdy.Schema{
+//		"id":     {Type: "S", Required: true},
+//		"age":    {Type: "N"},
+//		"status": {Type: "S", Required: true, Enum: []string{"active", "disabled"}},
+//	})
+//
+//	client := libdy.New(svc, libdy.WithTable("users"), libdy.WithSchemas(schemas))
+type Schemas struct {
+	mu      sync.RWMutex
+	schemas map[string]Schema
+}
+
+func NewSchemas() *Schemas {
+	return &Schemas{schemas: map[string]Schema{}}
+}
+
+// Register sets the schema of table, replacing any previous one.
+func (r *Schemas) Register(table string, s Schema) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.schemas[table] = s
+}
+
+func (r *Schemas) get(table string) Schema {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.schemas[table]
+}
+
+// WithSchemas enforces the schemas of r. Writes (PutItem, BatchPutItems,
+// and UpdateItem) that violate their table's schema fail locally with a
+// *SchemaError. Items read by the Client that don't match are coerced when
+// that is lossless (such as a number stored as a string) and otherwise
+// returned as is and logged (see WithLogger).
+func WithSchemas(r *Schemas) Option {
+	return func(o *options) { o.schemas = r }
+}
+
+// Check returns a *SchemaError if item violates the schema of table.
+func (r *Schemas) Check(table string, item map[string]*dynamodb.AttributeValue) error {
+	return schemaError(table, r.get(table).violations(item, true))
+}
+
+// violations returns the ways item violates s, including missing required
+// attributes if required is set.
+func (s Schema) violations(item map[string]*dynamodb.AttributeValue, required bool) []string {
+	var ret []string
+	for _, attr := range s.attrs() {
+		v, ok := item[attr]
+		if !ok {
+			if required && s[attr].Required {
+				ret = append(ret, attr+": required")
+			}
+
+			continue
+		}
+
+		ret = append(ret, s[attr].check(attr, v)...)
+	}
+
+	return ret
+}
+
+// checkAll is Check for a batch of items; violations are prefixed with the
+// item index.
+func (r *Schemas) checkAll(table string, items []map[string]*dynamodb.AttributeValue) error {
+	s := r.get(table)
+	if s == nil {
+		return nil
+	}
+
+	var violations []string
+	for i, item := range items {
+		for _, v := range s.violations(item, true) {
+			violations = append(violations, fmt.Sprintf("item %d: %s", i, v))
+		}
+	}
+
+	return schemaError(table, violations)
+}
+
+// checkUpdate returns a *SchemaError if u violates the schema of table.
+// Attributes the update doesn't touch are not checked.
+func (r *Schemas) checkUpdate(table string, u *Update) error {
+	s := r.get(table)
+	if s == nil || u == nil {
+		return nil
+	}
+
+	var violations []string
+	for _, a := range u.set {
+		if as, ok := s[a.attr]; ok {
+			violations = append(violations, as.check(a.attr, a.value)...)
+		}
+	}
+
+	for _, a := range append(u.add[:len(u.add):len(u.add)], u.del...) {
+		as, ok := s[a.attr]
+		switch {
+		case !ok || as.Type == "":
+		case !slices.Contains([]string{"N", "SS", "NS", "BS"}, as.Type):
+			violations = append(violations, fmt.Sprintf("%s: cannot add to or delete from %s", a.attr, as.Type))
+		case attrType(a.value) != as.Type:
+			violations = append(violations, fmt.Sprintf("%s: type %s, want %s", a.attr, attrType(a.value), as.Type))
+		}
+	}
+
+	for _, attr := range u.remove {
+		if s[attr].Required {
+			violations = append(violations, attr+": required, cannot remove")
+		}
+	}
+
+	return schemaError(table, violations)
+}
+
+func schemaError(table string, violations []string) error {
+	if len(violations) == 0 {
+		return nil
+	}
+
+	return &SchemaError{Table: table, Violations: violations}
+}
+
+// attrs returns the attribute names of s, sorted for stable messages.
+func (s Schema) attrs() []string {
+	ret := make([]string, 0, len(s))
+	for attr := range s {
+		ret = append(ret, attr)
+	}
+
+	sort.Strings(ret)
+	return ret
+}
+
+func (as AttrSchema) check(attr string, v *dynamodb.AttributeValue) []string {
+	if t := attrType(v); as.Type != "" && t != as.Type {
+		return []string{fmt.Sprintf("%s: type %s, want %s", attr, t, as.Type)}
+	}
+
+	if len(as.Enum) > 0 {
+		var s string
+		switch {
+		case v.S != nil:
+			s = *v.S
+		case v.N != nil:
+			s = *v.N
+		}
+
+		if !slices.Contains(as.Enum, s) {
+			return []string{fmt.Sprintf("%s: %q not in %v", attr, s, as.Enum)}
+		}
+	}
+
+	return nil
+}
+
+// attrType returns the type descriptor of v.
+func attrType(v *dynamodb.AttributeValue) string {
+	switch {
+	case v.S != nil:
+		return "S"
+	case v.N != nil:
+		return "N"
+	case v.B != nil:
+		return "B"
+	case v.BOOL != nil:
+		return "BOOL"
+	case v.NULL != nil:
+		return "NULL"
+	case v.M != nil:
+		return "M"
+	case v.L != nil:
+		return "L"
+	case v.SS != nil:
+		return "SS"
+	case v.NS != nil:
+		return "NS"
+	case v.BS != nil:
+		return "BS"
+	}
+
+	return ""
+}
+
+// coerce returns item with the attributes that mismatch the schema of table
+// converted where that is lossless, and the type and enum violations left
+// (read items may be projected, so required attributes aren't checked).
+// item itself is not modified.
+func (r *Schemas) coerce(table string, item map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+	s := r.get(table)
+	if s == nil || item == nil {
+		return item, nil
+	}
+
+	ret, copied := item, false
+	for _, attr := range s.attrs() {
+		v, ok := item[attr]
+		if !ok || s[attr].Type == "" || attrType(v) == s[attr].Type {
+			continue
+		}
+
+		if cv := coerceValue(v, s[attr].Type); cv != nil {
+			if !copied {
+				ret, copied = copyItem(item), true
+			}
+
+			ret[attr] = cv
+		}
+	}
+
+	return ret, schemaError(table, s.violations(ret, false))
+}
+
+// coerceValue converts v to type t if that is lossless, or returns nil.
+func coerceValue(v *dynamodb.AttributeValue, t string) *dynamodb.AttributeValue {
+	switch {
+	case t == "N" && v.S != nil:
+		if f, err := strconv.ParseFloat(*v.S, 64); err == nil && !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return &dynamodb.AttributeValue{N: v.S}
+		}
+	case t == "S" && v.N != nil:
+		return &dynamodb.AttributeValue{S: v.N}
+	case t == "BOOL" && v.S != nil:
+		if b, err := strconv.ParseBool(*v.S); err == nil {
+			return &dynamodb.AttributeValue{BOOL: aws.Bool(b)}
+		}
+	}
+
+	return nil
+}
+
+// checkRead coerces the items read from the table of o, logging the
+// mismatches that are left.
+func (o options) checkRead(items []map[string]*dynamodb.AttributeValue) []map[string]*dynamodb.AttributeValue {
+	if o.schemas.get(o.table) == nil {
+		return items
+	}
+
+	for i, item := range items {
+		var err error
+		items[i], err = o.schemas.coerce(o.table, item)
+		if err != nil {
+			o.logf("read item does not match schema: %v", err)
+		}
+	}
+
+	return items
+}
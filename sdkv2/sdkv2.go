@@ -0,0 +1,280 @@
+// Package sdkv2 provides the libdy read/write helpers on top of the AWS SDK
+// for Go v2 (github.com/aws/aws-sdk-go-v2/service/dynamodb).
+package sdkv2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/cenkalti/backoff"
+	"github.com/flowerinthenight/libdy"
+)
+
+// API is the subset of *dynamodb.Client used by this package.
+type API interface {
+	Query(context.Context, *dynamodb.QueryInput, ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	Scan(context.Context, *dynamodb.ScanInput, ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	PutItem(context.Context, *dynamodb.PutItemInput, ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	DeleteItem(context.Context, *dynamodb.DeleteItemInput, ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+}
+
+var _ API = (*dynamodb.Client)(nil)
+
+// Retryable decides which errors are retried with backoff, as
+// libdy.WithRetryable does for libdy; the default is libdy.IsTransient:
+// throttling, internal and unavailable service errors, transaction
+// conflicts, and network timeouts. Set it before use.
+var Retryable = libdy.IsTransient
+
+// retriable returns err (causing a retry with backoff) if Retryable, and
+// nil otherwise.
+func retriable(err error) error {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return nil
+	}
+
+	if Retryable(err) {
+		return err // will cause retry with backoff
+	}
+
+	return nil // final err is rerr
+}
+
+// parseKey parses the "name:value" form of a key, per libdy.ParseKey. There
+// is no schema to name a bare value after, so it needs the name.
+func parseKey(s string) (libdy.Key, error) {
+	if s != "" && !strings.Contains(s, ":") {
+		return libdy.Key{}, fmt.Errorf("%w: key %q is not name:value", libdy.ErrInvalidRequest, s)
+	}
+
+	return libdy.ParseKey(s), nil
+}
+
+func query(ctx context.Context, svc API, input *dynamodb.QueryInput) ([]map[string]types.AttributeValue, error) {
+	start := time.Now()
+	ret := []map[string]types.AttributeValue{}
+	var lastKey map[string]types.AttributeValue
+	more := true
+
+	// Could be paginated.
+	for more {
+		if lastKey != nil {
+			input.ExclusiveStartKey = lastKey
+		}
+
+		var rerr, err error
+		var res *dynamodb.QueryOutput
+
+		// Our retriable, backoff-able function.
+		op := func() error {
+			res, err = svc.Query(ctx, input)
+			rerr = err
+			return retriable(err)
+		}
+
+		err = backoff.Retry(op, backoff.WithContext(backoff.NewExponentialBackOff(), ctx))
+		if err != nil {
+			return nil, fmt.Errorf("query failed after %v: %w", time.Since(start), err)
+		}
+
+		if rerr != nil {
+			return nil, fmt.Errorf("query failed: %w", rerr)
+		}
+
+		ret = append(ret, res.Items...)
+		more = false
+		if res.LastEvaluatedKey != nil {
+			lastKey = res.LastEvaluatedKey
+			more = true
+		}
+
+		if input.Limit != nil {
+			if len(ret) >= int(*input.Limit) {
+				more = false
+				lastKey = nil
+			}
+		}
+	}
+
+	return ret, nil
+}
+
+func GetItems(ctx context.Context, svc API, table, pk, sk string, limit ...int32) ([]map[string]types.AttributeValue, error) {
+	kp, err := parseKey(pk)
+	if err != nil {
+		return nil, fmt.Errorf("GetItems failed: %w", err)
+	}
+
+	ks, err := parseKey(sk)
+	if err != nil {
+		return nil, fmt.Errorf("GetItems failed: %w", err)
+	}
+
+	// Names are aliased, so reserved words like "name" or "status" are fine.
+	input := &dynamodb.QueryInput{
+		TableName:                aws.String(table),
+		KeyConditionExpression:   aws.String("#pk = :pk"),
+		ExpressionAttributeNames: map[string]string{"#pk": kp.Name},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: kp.Value},
+		},
+		ScanIndexForward: aws.Bool(false), // descending order
+	}
+
+	if sk != "" {
+		input.KeyConditionExpression = aws.String("#pk = :pk AND begins_with(#sk, :sk)")
+		input.ExpressionAttributeNames["#sk"] = ks.Name
+		input.ExpressionAttributeValues[":sk"] = &types.AttributeValueMemberS{Value: ks.Value}
+	}
+
+	if len(limit) > 0 {
+		input.Limit = aws.Int32(limit[0])
+	}
+
+	return query(ctx, svc, input)
+}
+
+func GetGsiItems(ctx context.Context, svc API, table, index, key, value string) ([]map[string]types.AttributeValue, error) {
+	input := dynamodb.QueryInput{
+		TableName:                aws.String(table),
+		IndexName:                aws.String(index),
+		KeyConditionExpression:   aws.String("#v = :v"),
+		ExpressionAttributeNames: map[string]string{"#v": key},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":v": &types.AttributeValueMemberS{Value: value},
+		},
+	}
+
+	return query(ctx, svc, &input)
+}
+
+func ScanItems(ctx context.Context, svc API, table string, limit ...int32) ([]map[string]types.AttributeValue, error) {
+	start := time.Now()
+	ret := []map[string]types.AttributeValue{}
+	var lastKey map[string]types.AttributeValue
+	more := true
+
+	in := dynamodb.ScanInput{TableName: aws.String(table)}
+	if len(limit) > 0 {
+		in.Limit = aws.Int32(limit[0])
+	}
+
+	// Could be paginated.
+	for more {
+		if lastKey != nil {
+			in.ExclusiveStartKey = lastKey
+		}
+
+		var rerr, err error
+		var res *dynamodb.ScanOutput
+
+		// Our retriable, backoff-able function.
+		op := func() error {
+			res, err = svc.Scan(ctx, &in)
+			rerr = err
+			return retriable(err)
+		}
+
+		err = backoff.Retry(op, backoff.WithContext(backoff.NewExponentialBackOff(), ctx))
+		if err != nil {
+			return nil, fmt.Errorf("ScanItems failed after %v: %w", time.Since(start), err)
+		}
+
+		if rerr != nil {
+			return nil, fmt.Errorf("ScanItems failed: %w", rerr)
+		}
+
+		ret = append(ret, res.Items...)
+		more = false
+		if res.LastEvaluatedKey != nil {
+			lastKey = res.LastEvaluatedKey
+			more = true
+		}
+
+		if in.Limit != nil {
+			if len(ret) >= int(*in.Limit) {
+				more = false
+				lastKey = nil
+			}
+		}
+	}
+
+	return ret, nil
+}
+
+func PutItem(ctx context.Context, svc API, table string, item map[string]types.AttributeValue) error {
+	start := time.Now()
+	var rerr, err error
+
+	// Our retriable function.
+	op := func() error {
+		_, err = svc.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName: aws.String(table),
+			Item:      item,
+		})
+
+		rerr = err
+		return retriable(err)
+	}
+
+	err = backoff.Retry(op, backoff.WithContext(backoff.NewExponentialBackOff(), ctx))
+	if err != nil {
+		return fmt.Errorf("PutItem failed after %v: %w", time.Since(start), err)
+	}
+
+	if rerr != nil {
+		return fmt.Errorf("PutItem failed: %w", rerr)
+	}
+
+	return nil
+}
+
+func DeleteItem(ctx context.Context, svc API, table, pk, sk string) error {
+	kp, err := parseKey(pk)
+	if err != nil {
+		return fmt.Errorf("DeleteItem failed: %w", err)
+	}
+
+	ks, err := parseKey(sk)
+	if err != nil {
+		return fmt.Errorf("DeleteItem failed: %w", err)
+	}
+
+	start := time.Now()
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(table),
+		Key: map[string]types.AttributeValue{
+			kp.Name: &types.AttributeValueMemberS{Value: kp.Value},
+		},
+	}
+
+	if sk != "" {
+		input.Key[ks.Name] = &types.AttributeValueMemberS{Value: ks.Value}
+	}
+
+	var rerr error
+
+	// Our retriable function.
+	op := func() error {
+		_, err := svc.DeleteItem(ctx, input)
+		rerr = err
+		return retriable(err)
+	}
+
+	err = backoff.Retry(op, backoff.WithContext(backoff.NewExponentialBackOff(), ctx))
+	if err != nil {
+		return fmt.Errorf("DeleteItem failed after %v: %w", time.Since(start), err)
+	}
+
+	if rerr != nil {
+		return fmt.Errorf("DeleteItem failed: %w", rerr)
+	}
+
+	return nil
+}
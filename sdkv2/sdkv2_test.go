@@ -0,0 +1,142 @@
+package sdkv2
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/flowerinthenight/libdy"
+)
+
+// fakeAPI records the requests sent to it, failing the first fails of each
+// with err.
+type fakeAPI struct {
+	API
+	query *dynamodb.QueryInput
+	del   *dynamodb.DeleteItemInput
+	err   error
+	fails int
+	calls int
+}
+
+func (f *fakeAPI) fail() error {
+	f.calls++
+	if f.calls <= f.fails {
+		return f.err
+	}
+
+	return nil
+}
+
+func (f *fakeAPI) Query(_ context.Context, in *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	f.query = in
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+
+	return &dynamodb.QueryOutput{}, nil
+}
+
+func (f *fakeAPI) DeleteItem(_ context.Context, in *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	f.del = in
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func stringValue(v types.AttributeValue) string {
+	s, _ := v.(*types.AttributeValueMemberS)
+	if s == nil {
+		return ""
+	}
+
+	return s.Value
+}
+
+func TestKeys(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		pk, sk   string
+		name, pv string
+		sv       string
+		err      error
+	}{
+		{pk: "id:1", name: "id", pv: "1"},
+		{pk: "id:urn:a:b", sk: "at:2024-01-01T10:00:00Z", name: "id", pv: "urn:a:b", sv: "2024-01-01T10:00:00Z"},
+		{pk: "id:", name: "id"},
+		{pk: "1", err: libdy.ErrInvalidRequest},
+		{pk: "id:1", sk: "x", err: libdy.ErrInvalidRequest},
+	} {
+		f := &fakeAPI{}
+		_, err := GetItems(ctx, f, "t", tc.pk, tc.sk)
+		if !errors.Is(err, tc.err) {
+			t.Fatalf("GetItems(%q, %q) = %v, want %v", tc.pk, tc.sk, err, tc.err)
+		}
+
+		derr := DeleteItem(ctx, f, "t", tc.pk, tc.sk)
+		if !errors.Is(derr, tc.err) {
+			t.Fatalf("DeleteItem(%q, %q) = %v, want %v", tc.pk, tc.sk, derr, tc.err)
+		}
+
+		if tc.err != nil {
+			continue
+		}
+
+		if got := f.query.ExpressionAttributeNames["#pk"]; got != tc.name {
+			t.Errorf("GetItems(%q): name %q, want %q", tc.pk, got, tc.name)
+		}
+
+		if got := stringValue(f.query.ExpressionAttributeValues[":pk"]); got != tc.pv {
+			t.Errorf("GetItems(%q): value %q, want %q", tc.pk, got, tc.pv)
+		}
+
+		if got := stringValue(f.del.Key[tc.name]); got != tc.pv {
+			t.Errorf("DeleteItem(%q): value %q, want %q", tc.pk, got, tc.pv)
+		}
+
+		if tc.sk != "" {
+			if got := stringValue(f.query.ExpressionAttributeValues[":sk"]); got != tc.sv {
+				t.Errorf("GetItems(%q): sort key value %q, want %q", tc.sk, got, tc.sv)
+			}
+		}
+	}
+}
+
+func TestRetriable(t *testing.T) {
+	ctx := context.Background()
+	custom := errors.New("custom")
+	for _, tc := range []struct {
+		name  string
+		err   error
+		retry func(error) bool
+		calls int
+	}{
+		{"throttle", &types.ProvisionedThroughputExceededException{}, nil, 2},
+		{"internal", &types.InternalServerError{}, nil, 2},
+		{"conflict", &types.TransactionConflictException{}, nil, 2},
+		{"not found", &types.ResourceNotFoundException{}, nil, 1},
+		{"custom", custom, func(err error) bool { return errors.Is(err, custom) }, 2},
+		{"custom, not transient", &types.InternalServerError{}, func(err error) bool { return errors.Is(err, custom) }, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.retry != nil {
+				Retryable = tc.retry
+				defer func() { Retryable = libdy.IsTransient }()
+			}
+
+			f := &fakeAPI{err: tc.err, fails: 1}
+			err := DeleteItem(ctx, f, "t", "id:1", "")
+			if f.calls != tc.calls {
+				t.Errorf("%d calls, want %d", f.calls, tc.calls)
+			}
+
+			if (tc.calls == 2) != (err == nil) {
+				t.Errorf("DeleteItem = %v", err)
+			}
+		})
+	}
+}
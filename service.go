@@ -0,0 +1,246 @@
+package libdy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Page sizes of Service lists.
+const (
+	serviceLimit    = 25
+	serviceLimitMax = 1000
+)
+
+// Resource is a table exposed by a Service, through a Client that carries
+// its guardrails (schemas, scan guard, capacity limits, and so on).
+type Resource struct {
+	Client    *Client
+	ReadOnly  bool     // reject puts and deletes
+	Indexes   []string // the GSIs that can be queried
+	AllowScan bool     // allow lists without a pk
+	MaxLimit  int64    // the largest page size; the default is 1000
+	Opts      []Option // for every call on the resource
+}
+
+// Service is an http.Handler exposing Resources as a small JSON API, so
+// internal tools can access tables through libdy instead of with raw AWS
+// credentials. For a resource registered as "users":
+//
+//	GET    /users?pk=id:123&sk=order#&limit=50&cursor=...  query
+//	GET    /users?index=byEmail&key=email&value=a@b.c      index query
+//	GET    /users?cursor=...                               scan (AllowScan)
+//	GET    /users/item?pk=id:123&sk=profile                get
+//	PUT    /users/item                                     put (item as JSON)
+//	DELETE /users/item?pk=id:123&sk=profile                delete
+//
+// Lists return {"items": [...], "cursor": "..."}, where the cursor is "" on
+// the last page. Items are plain JSON, as with WriterSink; a put body is
+// limited to MaxItemSize. Errors are {"error": "..."} with a matching
+// status, e.g. 404 for ErrItemNotFound or 409 for ErrConditionFailed, and a
+// generic message: the errors of the Client, which may tell about the
+// tables and the AWS account, aren't exposed. Authentication is left to the
+// caller's middleware.
+type Service struct {
+	mu        sync.RWMutex
+	resources map[string]Resource
+}
+
+func NewService() *Service {
+	return &Service{resources: map[string]Resource{}}
+}
+
+// Register exposes r under /name.
+func (s *Service) Register(name string, r Resource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resources[name] = r
+}
+
+func (s *Service) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	name, rest, _ := strings.Cut(strings.Trim(req.URL.Path, "/"), "/")
+	s.mu.RLock()
+	r, ok := s.resources[name]
+	s.mu.RUnlock()
+	if !ok || (rest != "" && rest != "item") {
+		serviceError(w, http.StatusNotFound, fmt.Errorf("no resource %q", req.URL.Path))
+		return
+	}
+
+	switch {
+	case rest == "" && req.Method == http.MethodGet:
+		r.list(w, req)
+	case rest == "item" && req.Method == http.MethodGet:
+		r.get(w, req)
+	case rest == "item" && (req.Method == http.MethodPut || req.Method == http.MethodDelete):
+		if r.ReadOnly {
+			serviceError(w, http.StatusForbidden, fmt.Errorf("%s is read-only", name))
+			return
+		}
+
+		if req.Method == http.MethodPut {
+			r.put(w, req)
+		} else {
+			r.delete(w, req)
+		}
+	default:
+		serviceError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s not allowed", req.Method))
+	}
+}
+
+func (r Resource) list(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	limit := int64(serviceLimit)
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 1 {
+			serviceError(w, http.StatusBadRequest, fmt.Errorf("invalid limit %q", v))
+			return
+		}
+
+		limit = n
+	}
+
+	max := r.MaxLimit
+	if max <= 0 {
+		max = serviceLimitMax
+	}
+
+	if limit > max {
+		limit = max
+	}
+
+	cursor := q.Get("cursor")
+	opts := append(r.Opts[:len(r.Opts):len(r.Opts)], WithLimit(limit))
+	var items []map[string]*dynamodb.AttributeValue
+	var next string
+	var err error
+	switch {
+	case q.Get("index") != "":
+		index := q.Get("index")
+		allowed := false
+		for _, i := range r.Indexes {
+			allowed = allowed || i == index
+		}
+
+		if !allowed {
+			serviceError(w, http.StatusForbidden, fmt.Errorf("index %q not allowed", index))
+			return
+		}
+
+		items, next, err = r.Client.QueryIndexPage(req.Context(), index, q.Get("key"), q.Get("value"), cursor, opts...)
+	case q.Get("pk") != "":
+		items, next, err = r.Client.QueryPage(req.Context(), q.Get("pk"), q.Get("sk"), cursor, opts...)
+	case r.AllowScan:
+		items, next, err = r.Client.ScanPage(req.Context(), cursor, opts...)
+	default:
+		serviceError(w, http.StatusBadRequest, fmt.Errorf("pk or index required"))
+		return
+	}
+
+	if err != nil {
+		serviceFailure(w, err)
+		return
+	}
+
+	out := make([]json.RawMessage, len(items))
+	for i, item := range items {
+		if out[i], err = itemJSON(item); err != nil {
+			serviceFailure(w, err)
+			return
+		}
+	}
+
+	serviceJSON(w, http.StatusOK, map[string]interface{}{"items": out, "cursor": next})
+}
+
+func (r Resource) get(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	item, err := r.Client.GetItem(req.Context(), q.Get("pk"), q.Get("sk"), r.Opts...)
+	if err != nil {
+		serviceFailure(w, err)
+		return
+	}
+
+	b, err := itemJSON(item)
+	if err != nil {
+		serviceFailure(w, err)
+		return
+	}
+
+	serviceJSON(w, http.StatusOK, json.RawMessage(b))
+}
+
+func (r Resource) put(w http.ResponseWriter, req *http.Request) {
+	next := jsonItems(http.MaxBytesReader(w, req.Body, MaxItemSize))
+	item, err := next()
+	var mbe *http.MaxBytesError
+	switch {
+	case errors.As(err, &mbe):
+		serviceError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("item over %d bytes", MaxItemSize))
+		return
+	case err != nil:
+		serviceError(w, http.StatusBadRequest, errors.New("invalid item JSON"))
+		return
+	}
+
+	if err := r.Client.PutItem(req.Context(), item, r.Opts...); err != nil {
+		serviceFailure(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (r Resource) delete(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	if err := r.Client.DeleteItem(req.Context(), q.Get("pk"), q.Get("sk"), r.Opts...); err != nil {
+		serviceFailure(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// errorStatus maps err to an HTTP status.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrItemNotFound), errors.Is(err, ErrTableNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrConditionFailed), errors.Is(err, ErrTransactionConflict):
+		return http.StatusConflict
+	case errors.Is(err, ErrSchemaViolation), errors.Is(err, ErrInvalidRequest), errors.Is(err, ErrInvalidCursor):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrItemTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrScanBlocked), errors.Is(err, ErrOpDenied):
+		return http.StatusForbidden
+	case errors.Is(err, ErrThroughputExceeded):
+		return http.StatusTooManyRequests
+	}
+
+	return http.StatusInternalServerError
+}
+
+func serviceJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func serviceError(w http.ResponseWriter, status int, err error) {
+	serviceJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// serviceFailure reports err of the Client with its status, and the status
+// text only.
+func serviceFailure(w http.ResponseWriter, err error) {
+	status := errorStatus(err)
+	serviceJSON(w, status, map[string]string{"error": http.StatusText(status)})
+}
@@ -0,0 +1,49 @@
+package libdy_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/flowerinthenight/libdy"
+	"github.com/flowerinthenight/libdy/libdytest"
+)
+
+// failing is a Fake failing puts of items "boom" with an internal error.
+type failing struct{ *libdytest.Fake }
+
+func (f failing) PutItemWithContext(ctx aws.Context, in *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	if aws.StringValue(in.Item["id"].S) == "boom" {
+		return nil, errors.New("arn:aws:dynamodb:us-east-1:123456789012:table/t: internal")
+	}
+
+	return f.Fake.PutItemWithContext(ctx, in, opts...)
+}
+
+func TestServiceErrors(t *testing.T) {
+	svc := failing{libdytest.SetupFake(t, libdy.TableDef{Name: "t", PK: "id"})}
+	s := libdy.NewService()
+	s.Register("t", libdy.Resource{Client: libdy.New(svc, libdy.WithTable("t"))})
+	for _, tc := range []struct {
+		method, path, body string
+		status             int
+		want               string
+	}{
+		{"PUT", "/t/item", `{"id": "a"}`, http.StatusNoContent, ""},
+		{"PUT", "/t/item", `{"id": "a", "v": "` + strings.Repeat("x", libdy.MaxItemSize) + `"}`, http.StatusRequestEntityTooLarge, "item over 409600 bytes"},
+		{"PUT", "/t/item", `{"id": `, http.StatusBadRequest, "invalid item JSON"},
+		{"PUT", "/t/item", `{"id": "boom"}`, http.StatusInternalServerError, "Internal Server Error"},
+		{"GET", "/t/item?pk=id:b", "", http.StatusNotFound, "Not Found"},
+	} {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body)))
+		if w.Code != tc.status || (tc.want != "" && w.Body.String() != `{"error":"`+tc.want+`"}`+"\n") {
+			t.Errorf("%s %s %.20s: %d %s, want %d %s", tc.method, tc.path, tc.body, w.Code, w.Body, tc.status, tc.want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package libdy
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Session gives read-your-writes consistency to a logical session, such as
+// an interactive user flow: for window after the Session writes to a
+// partition, its reads of that partition are strongly consistent (and so
+// bypass WithItemCache). Other reads stay eventually consistent.
+//
+//	s := client.Session(5*time.Second, "pk")
+//	_ = s.PutItem(ctx, item)
+//	item, err := s.GetItem(ctx, "pk:1", "sk:a") // sees the put
+type Session struct {
+	c       *Client
+	window  time.Duration
+	attr    string
+	mu      sync.Mutex
+	written map[string]time.Time // by table and partition
+}
+
+// Session returns a new Session over the Client. attr is the partition key
+// attribute name.
+func (c *Client) Session(window time.Duration, attr string) *Session {
+	return &Session{c: c, window: window, attr: attr, written: map[string]time.Time{}}
+}
+
+// partition returns the table and partition of item, and the options of
+// the call with opts, or "" if they don't tell.
+func (s *Session) partition(opts []Option, item map[string]*dynamodb.AttributeValue) (string, options) {
+	o, err := s.c.apply(opts)
+	if err != nil {
+		return "", o
+	}
+
+	v, ok := item[s.attr]
+	if !ok {
+		return "", o
+	}
+
+	return o.table + "\x00" + partitionValue(v), o
+}
+
+// key returns the key for the key strings pk and sk, typed as in the call
+// with opts (see Client.keys), or nil if they can't be, so the call fails.
+func (s *Session) key(ctx context.Context, opts []Option, pk, sk string) map[string]*dynamodb.AttributeValue {
+	o, err := s.c.apply(opts)
+	if err != nil {
+		return nil
+	}
+
+	kp, ks, err := s.c.keys(ctx, o, pk, sk)
+	if err != nil {
+		return nil
+	}
+
+	return keyMap(kp, ks)
+}
+
+// wrote records a write to the partition of item.
+func (s *Session) wrote(opts []Option, item map[string]*dynamodb.AttributeValue) {
+	p, o := s.partition(opts, item)
+	if p == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := o.now()
+	s.written[p] = now
+	for k, at := range s.written {
+		if now.Sub(at) > s.window {
+			delete(s.written, k)
+		}
+	}
+}
+
+// readOpts returns opts, made strongly consistent if the session wrote to
+// the partition of key recently.
+func (s *Session) readOpts(opts []Option, key map[string]*dynamodb.AttributeValue) []Option {
+	p, o := s.partition(opts, key)
+	if p == "" {
+		return opts
+	}
+
+	s.mu.Lock()
+	at, ok := s.written[p]
+	s.mu.Unlock()
+	if ok && o.now().Sub(at) <= s.window {
+		return append(opts[:len(opts):len(opts)], WithConsistentRead())
+	}
+
+	return opts
+}
+
+// PutItem is Client.PutItem, recorded in the session.
+func (s *Session) PutItem(ctx context.Context, item map[string]*dynamodb.AttributeValue, opts ...Option) error {
+	defer s.wrote(opts, item)
+	return s.c.PutItem(ctx, item, opts...)
+}
+
+// DeleteItem is Client.DeleteItem, recorded in the session.
+func (s *Session) DeleteItem(ctx context.Context, pk, sk string, opts ...Option) error {
+	defer s.wrote(opts, s.key(ctx, opts, pk, sk))
+	return s.c.DeleteItem(ctx, pk, sk, opts...)
+}
+
+// UpdateItem is Client.UpdateItem, recorded in the session.
+func (s *Session) UpdateItem(ctx context.Context, pk, sk string, u *Update, opts ...Option) (map[string]*dynamodb.AttributeValue, error) {
+	defer s.wrote(opts, s.key(ctx, opts, pk, sk))
+	return s.c.UpdateItem(ctx, pk, sk, u, opts...)
+}
+
+// GetItem is Client.GetItem, strongly consistent after a session write to
+// the partition.
+func (s *Session) GetItem(ctx context.Context, pk, sk string, opts ...Option) (map[string]*dynamodb.AttributeValue, error) {
+	return s.c.GetItem(ctx, pk, sk, s.readOpts(opts, s.key(ctx, opts, pk, sk))...)
+}
+
+// Query is Client.Query, strongly consistent after a session write to the
+// partition.
+func (s *Session) Query(ctx context.Context, pk, sk string, opts ...Option) (*Result, error) {
+	return s.c.Query(ctx, pk, sk, s.readOpts(opts, s.key(ctx, opts, pk, ""))...)
+}
@@ -0,0 +1,67 @@
+package libdy_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/flowerinthenight/libdy"
+	"github.com/flowerinthenight/libdy/libdytest"
+)
+
+// consistency is a Fake recording the ConsistentRead of its GetItem calls.
+type consistency struct {
+	*libdytest.Fake
+	reads []bool
+}
+
+func (c *consistency) GetItemWithContext(ctx aws.Context, in *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	c.reads = append(c.reads, aws.BoolValue(in.ConsistentRead))
+	return c.Fake.GetItemWithContext(ctx, in, opts...)
+}
+
+func TestSession(t *testing.T) {
+	ctx := context.Background()
+	svc := &consistency{Fake: libdytest.SetupFake(t, libdy.TableDef{Name: "t", PK: "id:N", SK: "at"})}
+	clock := libdy.NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	c := libdy.New(svc, libdy.WithTable("t"), libdy.WithClock(clock))
+	for _, id := range []string{"1", "2"} {
+		item := map[string]*dynamodb.AttributeValue{"id": {N: aws.String(id)}, "at": {S: aws.String("a")}}
+		if err := c.PutItem(ctx, item); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := c.Session(time.Minute, "id")
+	seven := &dynamodb.AttributeValue{N: aws.String("7")}
+	if _, err := s.UpdateItem(ctx, "1", "a", libdy.NewUpdate().Set("n", seven)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The bare and the named key are the same number partition.
+	for _, k := range [][2]string{{"1", "a"}, {"id:1", "at:a"}, {"2", "a"}} {
+		if _, err := s.GetItem(ctx, k[0], k[1], libdy.WithKeySchema(libdy.KeyAttr{Name: "id", Type: "N"}, libdy.KeyAttr{Name: "at", Type: "S"})); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clock.Advance(2 * time.Minute)
+	if _, err := s.GetItem(ctx, "1", "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []bool{true, true, false, false}
+	if len(svc.reads) != len(want) {
+		t.Fatalf("consistent reads %v, want %v", svc.reads, want)
+	}
+
+	for i := range want {
+		if svc.reads[i] != want[i] {
+			t.Errorf("consistent reads %v, want %v", svc.reads, want)
+			break
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package libdy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/kinesis/kinesisiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/cenkalti/backoff"
+)
+
+// Batch limits of the sink services.
+const (
+	kinesisBatchMax = 500
+	sqsBatchMax     = 10
+)
+
+// itemJSON encodes item as plain JSON, as written by WriterSink.
+func itemJSON(item map[string]*dynamodb.AttributeValue) ([]byte, error) {
+	var v map[string]interface{}
+	if err := dynamodbattribute.UnmarshalMap(item, &v); err != nil {
+		return nil, fmt.Errorf("unmarshal failed: %w", err)
+	}
+
+	return json.Marshal(v)
+}
+
+// S3Sink writes each page of items to its own S3 object under prefix, as
+// JSON lines. Objects are named after the time the sink was created and a
+// sequence number, e.g. "exports/20240102T150405Z-000001.jsonl".
+func S3Sink(svc s3iface.S3API, bucket, prefix string) Sink {
+	run := time.Now().UTC().Format("20060102T150405Z")
+	var seq int64
+	return SinkFunc(func(ctx context.Context, items []map[string]*dynamodb.AttributeValue) error {
+		var buf bytes.Buffer
+		for _, item := range items {
+			b, err := itemJSON(item)
+			if err != nil {
+				return err
+			}
+
+			buf.Write(b)
+			buf.WriteByte('\n')
+		}
+
+		key := fmt.Sprintf("%s%s-%06d.jsonl", prefix, run, atomic.AddInt64(&seq, 1))
+		input := &s3.PutObjectInput{
+			Bucket:      aws.String(bucket),
+			Key:         aws.String(key),
+			Body:        bytes.NewReader(buf.Bytes()),
+			ContentType: aws.String("application/x-ndjson"),
+		}
+
+		var rerr error
+		op := func(ctx context.Context) error {
+			_, rerr = svc.PutObjectWithContext(ctx, input)
+			return (options{}).retriable(rerr)
+		}
+
+		if err := retry(ctx, options{}, "PutObject", op); err != nil {
+			return fmt.Errorf("PutObject failed: %w", err)
+		}
+
+		if rerr != nil {
+			return fmt.Errorf("PutObject failed: %w", rerr)
+		}
+
+		return nil
+	})
+}
+
+// KinesisSink writes the items to a Kinesis stream as JSON records,
+// partitioned by the value of the keyAttr attribute (usually the table's
+// partition key), so records of the same item stay in order.
+func KinesisSink(svc kinesisiface.KinesisAPI, stream, keyAttr string) Sink {
+	return SinkFunc(func(ctx context.Context, items []map[string]*dynamodb.AttributeValue) error {
+		records := make([]*kinesis.PutRecordsRequestEntry, len(items))
+		for i, item := range items {
+			v, ok := item[keyAttr]
+			if !ok || keyString(v) == "" {
+				return fmt.Errorf("PutRecords failed: item without %s", keyAttr)
+			}
+
+			b, err := itemJSON(item)
+			if err != nil {
+				return err
+			}
+
+			records[i] = &kinesis.PutRecordsRequestEntry{Data: b, PartitionKey: aws.String(keyString(v))}
+		}
+
+		return sendAll(ctx, "PutRecords", len(records), kinesisBatchMax, func(ctx context.Context, idx []int) ([]int, error) {
+			in := &kinesis.PutRecordsInput{StreamName: aws.String(stream)}
+			for _, i := range idx {
+				in.Records = append(in.Records, records[i])
+			}
+
+			res, err := svc.PutRecordsWithContext(ctx, in)
+			if err != nil {
+				return nil, err
+			}
+
+			var failed []int
+			for j, r := range res.Records {
+				if r.ErrorCode != nil {
+					failed = append(failed, idx[j])
+				}
+			}
+
+			return failed, nil
+		})
+	})
+}
+
+// SQSSink sends the items to an SQS queue, one JSON message per item.
+func SQSSink(svc sqsiface.SQSAPI, queueURL string) Sink {
+	return SinkFunc(func(ctx context.Context, items []map[string]*dynamodb.AttributeValue) error {
+		bodies := make([]string, len(items))
+		for i, item := range items {
+			b, err := itemJSON(item)
+			if err != nil {
+				return err
+			}
+
+			bodies[i] = string(b)
+		}
+
+		return sendAll(ctx, "SendMessageBatch", len(bodies), sqsBatchMax, func(ctx context.Context, idx []int) ([]int, error) {
+			in := &sqs.SendMessageBatchInput{QueueUrl: aws.String(queueURL)}
+			for _, i := range idx {
+				in.Entries = append(in.Entries, &sqs.SendMessageBatchRequestEntry{
+					Id:          aws.String(strconv.Itoa(i)),
+					MessageBody: aws.String(bodies[i]),
+				})
+			}
+
+			res, err := svc.SendMessageBatchWithContext(ctx, in)
+			if err != nil {
+				return nil, err
+			}
+
+			var failed []int
+			for _, f := range res.Failed {
+				if i, err := strconv.Atoi(aws.StringValue(f.Id)); err == nil {
+					failed = append(failed, i)
+				}
+			}
+
+			return failed, nil
+		})
+	})
+}
+
+// sendAll sends n records in batches of up to size with send, which returns
+// the records (by index) that failed individually. Failed records are resent
+// with backoff; failed calls are retried like any other request.
+func sendAll(ctx context.Context, name string, n, size int, send func(ctx context.Context, idx []int) ([]int, error)) error {
+	for start := 0; start < n; start += size {
+		var pending []int
+		for i := start; i < n && i < start+size; i++ {
+			pending = append(pending, i)
+		}
+
+		b := (*RetryPolicy)(nil).deadline(ctx, nil)
+		for len(pending) > 0 {
+			var failed []int
+			var rerr error
+			op := func(ctx context.Context) error {
+				failed, rerr = send(ctx, pending)
+				return (options{}).retriable(rerr)
+			}
+
+			if err := retry(ctx, options{}, name, op); err != nil {
+				return fmt.Errorf("%s failed: %w", name, err)
+			}
+
+			if rerr != nil {
+				return fmt.Errorf("%s failed: %w", name, rerr)
+			}
+
+			pending = failed
+			if len(pending) == 0 {
+				break
+			}
+
+			next := b.NextBackOff()
+			if next == backoff.Stop {
+				return fmt.Errorf("%s failed: %d record(s) not accepted", name, len(pending))
+			}
+
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("%s canceled: %w", name, ctx.Err())
+			case <-time.After(next):
+			}
+		}
+	}
+
+	return nil
+}
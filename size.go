@@ -0,0 +1,175 @@
+package libdy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// Size limits of DynamoDB, in bytes.
+const (
+	MaxItemSize         = 400 << 10
+	MaxPartitionKeySize = 2048
+	MaxSortKeySize      = 1024
+)
+
+// EstimateItemSize returns the size of item as DynamoDB counts it against
+// the item size limit and for capacity: the UTF-8 bytes of the attribute
+// names and values, numbers a byte per two significant digits plus one,
+// and a few bytes of overhead for maps and lists and their elements. It
+// matches the service for all but unusual numbers.
+func EstimateItemSize(item map[string]*dynamodb.AttributeValue) int {
+	return itemSize(item)
+}
+
+func itemSize(item map[string]*dynamodb.AttributeValue) int {
+	n := 0
+	for name, v := range item {
+		n += len(name) + attrSize(v)
+	}
+
+	return n
+}
+
+func attrSize(v *dynamodb.AttributeValue) int {
+	if v == nil {
+		return 0
+	}
+
+	n := len(aws.StringValue(v.S)) + len(v.B)
+	switch {
+	case v.N != nil:
+		n += numberSize(*v.N)
+	case v.BOOL != nil, v.NULL != nil:
+		n++
+	case v.M != nil:
+		n += 3
+		for name, e := range v.M {
+			n += 1 + len(name) + attrSize(e)
+		}
+	case v.L != nil:
+		n += 3
+		for _, e := range v.L {
+			n += 1 + attrSize(e)
+		}
+	}
+
+	for _, s := range v.SS {
+		n += len(aws.StringValue(s))
+	}
+
+	for _, s := range v.NS {
+		n += numberSize(aws.StringValue(s))
+	}
+
+	for _, b := range v.BS {
+		n += len(b)
+	}
+
+	return n
+}
+
+// numberSize returns the size of the number s: a byte per two significant
+// digits, plus one, plus one if negative.
+func numberSize(s string) int {
+	n := 1
+	if strings.HasPrefix(s, "-") {
+		s, n = s[1:], 2
+	}
+
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		s = s[:i]
+	}
+
+	digits := strings.Trim(strings.Replace(s, ".", "", 1), "0")
+	return n + (len(digits)+1)/2
+}
+
+// sizeCheck checks items before writing them, per WithSizeCheck.
+type sizeCheck struct {
+	mu       sync.Mutex
+	keyAttrs map[string][]string // by table
+}
+
+// WithSizeCheck checks the items written by PutItem and BatchPutItems, as
+// stored (see WithCompression and WithOffload), against the limits of
+// DynamoDB before sending them: those over MaxItemSize fail with
+// ErrItemTooLarge, listing their largest attributes, and those with empty
+// or oversized keys with ErrInvalidRequest, without a doomed request. The
+// key schema is described once per table; set it on the Client.
+func WithSizeCheck() Option {
+	s := &sizeCheck{keyAttrs: map[string][]string{}}
+	return func(o *options) { o.sizeCheck = s }
+}
+
+func (s *sizeCheck) tableKeys(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string) ([]string, error) {
+	s.mu.Lock()
+	keys, ok := s.keyAttrs[table]
+	s.mu.Unlock()
+	if ok {
+		return keys, nil
+	}
+
+	keys, err := tableKeyAttrs(ctx, svc, table)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.keyAttrs[table] = keys
+	s.mu.Unlock()
+	return keys, nil
+}
+
+// check checks item, to be written to table. A nil sizeCheck checks nothing.
+func (s *sizeCheck) check(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, item map[string]*dynamodb.AttributeValue) error {
+	if s == nil {
+		return nil
+	}
+
+	if size := itemSize(item); size > MaxItemSize {
+		names := make([]string, 0, len(item))
+		for name := range item {
+			names = append(names, name)
+		}
+
+		sort.Slice(names, func(i, j int) bool { return attrSize(item[names[i]]) > attrSize(item[names[j]]) })
+		var largest []string
+		for _, name := range names[:min(3, len(names))] {
+			largest = append(largest, fmt.Sprintf("%s: %d", name, len(name)+attrSize(item[name])))
+		}
+
+		return fmt.Errorf("%w: %d bytes, over %d (largest attributes %s)", ErrItemTooLarge, size, MaxItemSize, strings.Join(largest, ", "))
+	}
+
+	keys, err := s.tableKeys(ctx, svc, table)
+	if err != nil {
+		return fmt.Errorf("size check failed: %w", err)
+	}
+
+	for i, name := range keys {
+		v, limit, kind := item[name], MaxPartitionKeySize, "partition"
+		if i > 0 {
+			limit, kind = MaxSortKeySize, "sort"
+		}
+
+		if v == nil || v.N != nil {
+			continue // missing keys are reported by DynamoDB
+		}
+
+		switch size := len(aws.StringValue(v.S)) + len(v.B); {
+		case size == 0:
+			return fmt.Errorf("%w: %s key %s is empty", ErrInvalidRequest, kind, name)
+		case size > limit:
+			return fmt.Errorf("%w: %s key %s is %d bytes, over %d", ErrInvalidRequest, kind, name, size, limit)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package libdy
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// SnapshotMode is what WithSnapshot does with the items that changed while
+// a read was paging.
+type SnapshotMode int
+
+const (
+	SnapshotFlag    SnapshotMode = iota // list their keys in Result.Changed
+	SnapshotRefetch                     // also replace them with their current version
+)
+
+type snapshot struct {
+	attr string
+	mode SnapshotMode
+}
+
+// WithSnapshot makes multi-page reads (Query, QueryIndex, Scan, and so on)
+// check, after the last page, whether the items of the earlier pages are
+// still current: attr is an attribute that changes with every write, such
+// as a version number or an updated_at time. The keys of the items whose
+// attr changed, or that were deleted, are listed in Result.Changed; with
+// SnapshotRefetch, they are also read again (or dropped, if deleted). This
+// is best effort: the check costs a consistent BatchGetItem of the keys and
+// attr of the earlier pages, items inserted during the read are not
+// detected, and refetched items are not filtered again. With WithProjection,
+// project attr and the key attributes too.
+func WithSnapshot(attr string, mode SnapshotMode) Option {
+	return func(o *options) { o.snapshot = &snapshot{attr: attr, mode: mode} }
+}
+
+// check checks the first n items of res, those read before the last page,
+// per s. A nil s does nothing.
+func (s *snapshot) check(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, res *Result, n int, o options) error {
+	if s == nil || n == 0 {
+		return nil
+	}
+
+	if n > len(res.Items) {
+		n = len(res.Items)
+	}
+
+	keyAttrs, err := tableKeyAttrs(ctx, svc, table)
+	if err != nil {
+		return fmt.Errorf("snapshot check failed: %w", err)
+	}
+
+	id := func(item map[string]*dynamodb.AttributeValue) string {
+		ids := make([]string, len(keyAttrs))
+		for i, a := range keyAttrs {
+			if v := item[a]; v != nil {
+				ids[i] = keyString(v)
+			}
+		}
+
+		return strings.Join(ids, "\x00")
+	}
+
+	keys := make([]map[string]*dynamodb.AttributeValue, n)
+	for i, item := range res.Items[:n] {
+		keys[i] = keyOf(item, keyAttrs)
+		for _, a := range keyAttrs {
+			if keys[i][a] == nil {
+				return fmt.Errorf("snapshot check failed: item without key attribute %s", a)
+			}
+		}
+	}
+
+	vo := o
+	vo.projection = append(keyAttrs[:len(keyAttrs):len(keyAttrs)], s.attr)
+	vo.consistent = true
+	fresh, err := batchGet(ctx, svc, table, keys, vo)
+	if err != nil {
+		return fmt.Errorf("snapshot check failed: %w", err)
+	}
+
+	current := make(map[string]*dynamodb.AttributeValue, len(fresh))
+	exists := make(map[string]bool, len(fresh))
+	for _, item := range fresh {
+		current[id(item)] = item[s.attr]
+		exists[id(item)] = true
+	}
+
+	var changed []int
+	for i, item := range res.Items[:n] {
+		k := id(item)
+		if !exists[k] || !reflect.DeepEqual(item[s.attr], current[k]) {
+			changed = append(changed, i)
+			res.Changed = append(res.Changed, keys[i])
+		}
+	}
+
+	if s.mode != SnapshotRefetch || len(changed) == 0 {
+		return nil
+	}
+
+	refetch := make([]map[string]*dynamodb.AttributeValue, len(changed))
+	for j, i := range changed {
+		refetch[j] = keys[i]
+	}
+
+	items, err := batchGet(ctx, svc, table, refetch, o)
+	if err != nil {
+		return fmt.Errorf("snapshot refetch failed: %w", err)
+	}
+
+	latest := make(map[string]map[string]*dynamodb.AttributeValue, len(items))
+	for _, item := range items {
+		latest[id(item)] = item
+	}
+
+	for _, i := range changed {
+		res.Items[i] = latest[id(keys[i])] // nil if deleted
+	}
+
+	kept := res.Items[:0]
+	for _, item := range res.Items {
+		if item != nil {
+			kept = append(kept, item)
+		}
+	}
+
+	res.Items = kept
+	return nil
+}
@@ -0,0 +1,124 @@
+package libdy
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// SortKeyCondition is a condition on the sort key of a query, built with
+// SKEquals, SKLess, SKLessOrEqual, SKGreater, SKGreaterOrEqual, SKBetween,
+// or SKBeginsWith.
+//
+//	res, err := client.QueryByKey(ctx, libdy.StringKey("pk", "user#1"), libdy.Key{},
+//		libdy.WithSortKey(libdy.SKBetween(
+//			libdy.StringKey("sk", "2024-01"),
+//			libdy.StringKey("sk", "2024-02"))))
+type SortKeyCondition struct {
+	op     string
+	values []Key
+}
+
+func SKEquals(k Key) SortKeyCondition         { return SortKeyCondition{"=", []Key{k}} }
+func SKLess(k Key) SortKeyCondition           { return SortKeyCondition{"<", []Key{k}} }
+func SKLessOrEqual(k Key) SortKeyCondition    { return SortKeyCondition{"<=", []Key{k}} }
+func SKGreater(k Key) SortKeyCondition        { return SortKeyCondition{">", []Key{k}} }
+func SKGreaterOrEqual(k Key) SortKeyCondition { return SortKeyCondition{">=", []Key{k}} }
+
+// SKBetween holds for sort keys from lo to hi, inclusive.
+func SKBetween(lo, hi Key) SortKeyCondition { return SortKeyCondition{"BETWEEN", []Key{lo, hi}} }
+
+// SKBeginsWith holds for sort keys starting with k's value, as with the sk
+// argument of Query.
+func SKBeginsWith(k Key) SortKeyCondition { return SortKeyCondition{"begins_with", []Key{k}} }
+
+// WithSortKey sets the sort key condition of a query, replacing the sk
+// prefix argument. On a QueryIndex, it applies to the index's sort key.
+func WithSortKey(c SortKeyCondition) Option {
+	return func(o *options) { o.sortKey = &c }
+}
+
+// WithScanIndexForward sets the sort key order of a query: ascending if
+// forward, descending otherwise. Query defaults to descending, and QueryIndex
+// to ascending.
+func WithScanIndexForward(forward bool) Option {
+	return func(o *options) { o.forward = &forward }
+}
+
+// apply sets c as the sort key condition of in, keeping the partition key
+// condition.
+func (c *SortKeyCondition) apply(in *dynamodb.QueryInput, pk Key) {
+	var expr string
+	switch c.op {
+	case "BETWEEN":
+		expr = "#sk BETWEEN :sk AND :sk2"
+	case "begins_with":
+		expr = "begins_with(#sk, :sk)"
+	default:
+		expr = "#sk " + c.op + " :sk"
+	}
+
+	in.KeyConditionExpression = aws.String("#pk = :pk AND " + expr)
+	in.ExpressionAttributeNames = map[string]*string{
+		"#pk": aws.String(pk.Name),
+		"#sk": aws.String(c.values[0].Name),
+	}
+
+	in.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+		":pk": pk.attributeValue(),
+		":sk": c.values[0].attributeValue(),
+	}
+
+	if len(c.values) > 1 {
+		in.ExpressionAttributeValues[":sk2"] = c.values[1].attributeValue()
+	}
+}
+
+// queryInput is queryInput with the limit, sort key condition, and order of
+// o.
+func (o options) queryInput(pk, sk Key) *dynamodb.QueryInput {
+	in := queryInput(o.table, pk, sk, o.limits()...)
+	o.applyKeyOptions(in, pk)
+	return in
+}
+
+// indexQueryInput is indexQueryInput with the limit, sort key condition, and
+// order of o.
+func (o options) indexQueryInput(index string, key Key) *dynamodb.QueryInput {
+	in := indexQueryInput(o.table, index, key)
+	if o.limit > 0 {
+		in.Limit = aws.Int64(o.limit)
+	}
+
+	o.applyKeyOptions(in, key)
+	return in
+}
+
+func (o options) applyKeyOptions(in *dynamodb.QueryInput, pk Key) {
+	if o.sortKey != nil {
+		o.sortKey.apply(in, pk)
+	}
+
+	if o.forward != nil {
+		in.ScanIndexForward = aws.Bool(*o.forward)
+	}
+}
+
+func GetItemsWhere(svc dynamodbiface.DynamoDBAPI, table string, pk Key, c SortKeyCondition, limit ...int64) ([]map[string]*dynamodb.AttributeValue, error) {
+	return GetItemsWhereWithContext(context.Background(), svc, table, pk, c, limit...)
+}
+
+// GetItemsWhereWithContext reads the items under pk whose sort key satisfies
+// c, in descending order.
+func GetItemsWhereWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, pk Key, c SortKeyCondition, limit ...int64) ([]map[string]*dynamodb.AttributeValue, error) {
+	in := queryInput(table, pk, Key{}, limit...)
+	c.apply(in, pk)
+	res, err := query(ctx, svc, table, in, options{})
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Items, nil
+}
@@ -0,0 +1,412 @@
+package libdy
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// sourcePageMax is the number of items per page read from files and objects.
+const sourcePageMax = 250
+
+// Source provides the items of an import, one page at a time; Read returns
+// io.EOF after the last page. Besides SourceFunc, there are TableSource,
+// JSONSource, CSVSource, FileSource, and S3Source.
+type Source interface {
+	Read(ctx context.Context) ([]map[string]*dynamodb.AttributeValue, error)
+}
+
+// SourceFunc adapts a function to a Source.
+type SourceFunc func(ctx context.Context) ([]map[string]*dynamodb.AttributeValue, error)
+
+func (f SourceFunc) Read(ctx context.Context) ([]map[string]*dynamodb.AttributeValue, error) {
+	return f(ctx)
+}
+
+// itemFunc returns the next item of a stream, or io.EOF at its end.
+type itemFunc func() (map[string]*dynamodb.AttributeValue, error)
+
+// readPage reads up to sourcePageMax items with next, returning io.EOF if
+// there are none left.
+func readPage(ctx context.Context, next itemFunc) ([]map[string]*dynamodb.AttributeValue, error) {
+	var items []map[string]*dynamodb.AttributeValue
+	for len(items) < sourcePageMax {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		item, err := next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+
+	if len(items) == 0 {
+		return nil, io.EOF
+	}
+
+	return items, nil
+}
+
+// TableSource reads the items of the table of c with a scan; opts apply to
+// the scan, e.g. WithTable, WithFilter, or WithReadCapacity.
+func TableSource(c *Client, opts ...Option) Source {
+	var pages *Pages
+	return SourceFunc(func(ctx context.Context) ([]map[string]*dynamodb.AttributeValue, error) {
+		if pages == nil {
+			pages = c.ScanPages(opts...)
+		}
+
+		if pages.Next(ctx) {
+			return pages.Page(), nil
+		}
+
+		pages.Close()
+		if err := pages.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, io.EOF
+	})
+}
+
+// JSONSource reads items from r as a stream of JSON objects, such as the
+// JSON lines written by WriterSink. Numbers are kept as written.
+func JSONSource(r io.Reader) Source {
+	next := jsonItems(r)
+	return SourceFunc(func(ctx context.Context) ([]map[string]*dynamodb.AttributeValue, error) {
+		return readPage(ctx, next)
+	})
+}
+
+// CSVSource reads items from r as CSV with a header row naming the
+// attributes. Values are strings unless the header gives a type, as in
+// "age:N" or "active:BOOL", or "tags:JSON" for values in DynamoDB JSON (see
+// FormatCSV); empty values are left out of the item.
+func CSVSource(r io.Reader) Source {
+	next := csvItems(r)
+	return SourceFunc(func(ctx context.Context) ([]map[string]*dynamodb.AttributeValue, error) {
+		return readPage(ctx, next)
+	})
+}
+
+// FileSource reads items from the file at path: CSV if its name ends with
+// ".csv" (see CSVSource), JSON otherwise (see JSONSource).
+func FileSource(path string) Source {
+	var f *os.File
+	var next itemFunc
+	return SourceFunc(func(ctx context.Context) ([]map[string]*dynamodb.AttributeValue, error) {
+		if f == nil {
+			var err error
+			if f, err = os.Open(path); err != nil {
+				return nil, err
+			}
+
+			next = fileItems(path, f)
+		}
+
+		items, err := readPage(ctx, next)
+		if err != nil {
+			f.Close()
+			if err != io.EOF {
+				err = fmt.Errorf("%s: %w", path, err)
+			}
+		}
+
+		return items, err
+	})
+}
+
+func fileItems(name string, r io.Reader) itemFunc {
+	if strings.EqualFold(path.Ext(name), ".csv") {
+		return csvItems(r)
+	}
+
+	return jsonItems(r)
+}
+
+func jsonItems(r io.Reader) itemFunc {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	n := 0
+	return func() (map[string]*dynamodb.AttributeValue, error) {
+		var v map[string]interface{}
+		if err := dec.Decode(&v); err != nil {
+			if err == io.EOF {
+				return nil, err
+			}
+
+			return nil, fmt.Errorf("invalid item %d: %w", n+1, err)
+		}
+
+		n++
+		return jsonAV(v).M, nil
+	}
+}
+
+// jsonAV converts a value decoded with UseNumber to an attribute value.
+func jsonAV(v interface{}) *dynamodb.AttributeValue {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]*dynamodb.AttributeValue, len(v))
+		for k, e := range v {
+			m[k] = jsonAV(e)
+		}
+
+		return &dynamodb.AttributeValue{M: m}
+	case []interface{}:
+		l := make([]*dynamodb.AttributeValue, len(v))
+		for i, e := range v {
+			l[i] = jsonAV(e)
+		}
+
+		return &dynamodb.AttributeValue{L: l}
+	case string:
+		return &dynamodb.AttributeValue{S: aws.String(v)}
+	case json.Number:
+		return &dynamodb.AttributeValue{N: aws.String(v.String())}
+	case bool:
+		return &dynamodb.AttributeValue{BOOL: aws.Bool(v)}
+	default:
+		return &dynamodb.AttributeValue{NULL: aws.Bool(true)}
+	}
+}
+
+func csvItems(r io.Reader) itemFunc {
+	cr := csv.NewReader(r)
+	var names, types []string
+	return func() (map[string]*dynamodb.AttributeValue, error) {
+		if names == nil {
+			header, err := cr.Read()
+			if err != nil {
+				if err == io.EOF {
+					return nil, err
+				}
+
+				return nil, fmt.Errorf("invalid header: %w", err)
+			}
+
+			for _, h := range header {
+				name, typ, _ := strings.Cut(h, ":")
+				switch typ {
+				case "", "S", "N", "BOOL", "JSON":
+				default:
+					return nil, fmt.Errorf("invalid header: unsupported type %q of %s", typ, name)
+				}
+
+				names = append(names, name)
+				types = append(types, typ)
+			}
+		}
+
+		rec, err := cr.Read()
+		if err != nil {
+			return nil, err // csv.ParseError has the line
+		}
+
+		line, _ := cr.FieldPos(0)
+		item := map[string]*dynamodb.AttributeValue{}
+		for i, v := range rec {
+			if v == "" {
+				continue
+			}
+
+			switch types[i] {
+			case "N":
+				if _, err := strconv.ParseFloat(v, 64); err != nil {
+					return nil, fmt.Errorf("line %d: %s is not a number: %q", line, names[i], v)
+				}
+
+				item[names[i]] = &dynamodb.AttributeValue{N: aws.String(v)}
+			case "BOOL":
+				b, err := strconv.ParseBool(v)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %s is not a bool: %q", line, names[i], v)
+				}
+
+				item[names[i]] = &dynamodb.AttributeValue{BOOL: aws.Bool(b)}
+			case "JSON":
+				av, err := parseTypedJSON(json.RawMessage(v))
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %s: %w", line, names[i], err)
+				}
+
+				item[names[i]] = av
+			default:
+				item[names[i]] = &dynamodb.AttributeValue{S: aws.String(v)}
+			}
+		}
+
+		return item, nil
+	}
+}
+
+type s3Source struct {
+	svc    s3iface.S3API
+	bucket string
+	prefix string
+	keys   []string
+	token  *string
+	listed bool
+	body   io.ReadCloser
+	key    string
+	next   itemFunc
+}
+
+// S3Source reads the objects under prefix in bucket, in key order, each as
+// CSV or JSON by its extension (see FileSource).
+func S3Source(svc s3iface.S3API, bucket, prefix string) Source {
+	return &s3Source{svc: svc, bucket: bucket, prefix: prefix}
+}
+
+func (s *s3Source) Read(ctx context.Context) ([]map[string]*dynamodb.AttributeValue, error) {
+	for {
+		if s.next != nil {
+			items, err := readPage(ctx, s.next)
+			if err != io.EOF {
+				if err != nil {
+					s.body.Close()
+					return nil, fmt.Errorf("s3://%s/%s: %w", s.bucket, s.key, err)
+				}
+
+				return items, nil
+			}
+
+			s.body.Close()
+			s.next = nil
+		}
+
+		if len(s.keys) == 0 {
+			if s.listed {
+				return nil, io.EOF
+			}
+
+			if err := s.list(ctx); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		s.key, s.keys = s.keys[0], s.keys[1:]
+		if err := s.open(ctx); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// list reads the next page of keys.
+func (s *s3Source) list(ctx context.Context) error {
+	input := &s3.ListObjectsV2Input{
+		Bucket:            aws.String(s.bucket),
+		Prefix:            aws.String(s.prefix),
+		ContinuationToken: s.token,
+	}
+
+	var rerr error
+	var res *s3.ListObjectsV2Output
+	op := func(ctx context.Context) error {
+		res, rerr = s.svc.ListObjectsV2WithContext(ctx, input)
+		return (options{}).retriable(rerr)
+	}
+
+	if err := retry(ctx, options{}, "ListObjectsV2", op); err != nil {
+		return fmt.Errorf("ListObjectsV2 failed: %w", err)
+	}
+
+	if rerr != nil {
+		return fmt.Errorf("ListObjectsV2 failed: %w", rerr)
+	}
+
+	for _, obj := range res.Contents {
+		if key := aws.StringValue(obj.Key); !strings.HasSuffix(key, "/") {
+			s.keys = append(s.keys, key)
+		}
+	}
+
+	s.token = res.NextContinuationToken
+	s.listed = !aws.BoolValue(res.IsTruncated)
+	return nil
+}
+
+// open starts reading the object s.key.
+func (s *s3Source) open(ctx context.Context) error {
+	input := &s3.GetObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(s.key)}
+	var rerr error
+	var res *s3.GetObjectOutput
+	op := func(ctx context.Context) error {
+		res, rerr = s.svc.GetObjectWithContext(ctx, input)
+		return (options{}).retriable(rerr)
+	}
+
+	if err := retry(ctx, options{}, "GetObject", op); err != nil {
+		return fmt.Errorf("GetObject failed: %w", err)
+	}
+
+	if rerr != nil {
+		return fmt.Errorf("GetObject failed: %w", rerr)
+	}
+
+	s.body = res.Body
+	s.next = fileItems(s.key, res.Body)
+	return nil
+}
+
+func ImportItems(svc dynamodbiface.DynamoDBAPI, src Source, table string, opts ...Option) (int64, error) {
+	return ImportItemsWithContext(context.Background(), svc, src, table, opts...)
+}
+
+// ImportItemsWithContext is Client.ImportItems for table.
+func ImportItemsWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, src Source, table string, opts ...Option) (int64, error) {
+	return New(svc, WithTable(table)).ImportItems(ctx, src, opts...)
+}
+
+// ImportItems writes all the items of src to the table with BatchPutItems,
+// page by page, so WithWriteCapacity paces it and WithDerived and
+// WithSchemas apply. It returns the number of items written, which are
+// all the items before the failed page on error.
+//
+//	n, err := client.ImportItems(ctx, libdy.S3Source(s3svc, "backups", "users/"),
+//		libdy.WithWriteCapacity(libdy.NewCapacityLimiter(500)))
+func (c *Client) ImportItems(ctx context.Context, src Source, opts ...Option) (int64, error) {
+	if _, err := c.apply(opts); err != nil {
+		return 0, err
+	}
+
+	var n int64
+	for {
+		items, err := src.Read(ctx)
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		}
+
+		if err != nil {
+			return n, fmt.Errorf("ImportItems failed: %w", err)
+		}
+
+		if err := c.BatchPutItems(ctx, items, opts...); err != nil {
+			return n, fmt.Errorf("ImportItems failed: %w", err)
+		}
+
+		n += int64(len(items))
+	}
+}
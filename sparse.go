@@ -0,0 +1,212 @@
+package libdy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// SparseIndex is a sparse global secondary index: only the items that carry
+// its key attributes are in it, so an index of, say, the open orders is
+// small and cheap to read, however many closed orders there are. Items join
+// the index when the state applies, by setting the key attributes, and
+// leave it by removing them:
+//
+//	open := libdy.SparseIndex{Name: "gsi1", PK: "gsi1pk", SK: "gsi1sk"}
+//
+//	u, err := open.SetIf(libdy.NewUpdate().Set("status", status), isOpen, "OPEN", created)
+//	_, err = client.UpdateItem(ctx, "id:o1", "", u)
+//	...
+//	res, err := client.QuerySparse(ctx, open, "OPEN")
+//
+// The same attributes may serve several indexes, or several states of one
+// (GSI overloading), with the partition key value naming the state.
+type SparseIndex struct {
+	Name   string
+	PK, SK string // the key attributes of the index; SK is optional
+}
+
+// keys returns the index keys with the values pk and sk, and whether the
+// item is in the index with them: DynamoDB rejects empty index keys, so an
+// empty value means out of the index.
+func (x SparseIndex) keys(pk, sk interface{}) (map[string]*dynamodb.AttributeValue, bool, error) {
+	kp, err := repoAttr{name: x.PK}.key(pk)
+	if err != nil {
+		return nil, false, err
+	}
+
+	ks, err := repoAttr{name: x.SK}.key(sk)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if kp.Value == "" || (x.SK != "" && ks.Value == "") {
+		return nil, false, nil
+	}
+
+	return keyMap(kp, ks), true, nil
+}
+
+// attrs returns the key attributes of x.
+func (x SparseIndex) attrs() []string {
+	if x.SK == "" {
+		return []string{x.PK}
+	}
+
+	return []string{x.PK, x.SK}
+}
+
+// Set returns u, setting the index keys of an item to pk and sk (ignored if
+// x has no sort key), which puts the item in the index. An empty value
+// removes them instead.
+func (x SparseIndex) Set(u *Update, pk, sk interface{}) (*Update, error) {
+	return x.SetIf(u, true, pk, sk)
+}
+
+// Clear returns u, removing the index keys of an item, which takes it out of
+// the index.
+func (x SparseIndex) Clear(u *Update) *Update {
+	return u.Remove(x.attrs()...)
+}
+
+// SetIf is Set if in, e.g. if the state of the index applies to the item,
+// and Clear otherwise.
+func (x SparseIndex) SetIf(u *Update, in bool, pk, sk interface{}) (*Update, error) {
+	if !in {
+		return x.Clear(u), nil
+	}
+
+	keys, ok, err := x.keys(pk, sk)
+	if err != nil {
+		return u, fmt.Errorf("index %s: %w", x.Name, err)
+	}
+
+	if !ok {
+		return x.Clear(u), nil
+	}
+
+	return u.SetAll(keys), nil
+}
+
+// Apply is SetIf for an item to put: it sets the index keys of item to pk
+// and sk if in, and deletes them otherwise.
+func (x SparseIndex) Apply(item map[string]*dynamodb.AttributeValue, in bool, pk, sk interface{}) error {
+	keys, ok, err := x.keys(pk, sk)
+	if err != nil && in {
+		return fmt.Errorf("index %s: %w", x.Name, err)
+	}
+
+	for _, attr := range x.attrs() {
+		delete(item, attr)
+	}
+
+	if in && ok {
+		for k, v := range keys {
+			item[k] = v
+		}
+	}
+
+	return nil
+}
+
+func AddToIndex(svc dynamodbiface.DynamoDBAPI, table string, x SparseIndex, pk, sk string, ipk, isk interface{}, opts ...Option) error {
+	return AddToIndexWithContext(context.Background(), svc, table, x, pk, sk, ipk, isk, opts...)
+}
+
+// AddToIndexWithContext is Client.AddToIndex for table.
+func AddToIndexWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, x SparseIndex, pk, sk string, ipk, isk interface{}, opts ...Option) error {
+	return New(svc, WithTable(table)).AddToIndex(ctx, x, pk, sk, ipk, isk, opts...)
+}
+
+// AddToIndex puts the item with the key pk and sk in the sparse index x,
+// with the index keys ipk and isk. Unlike a plain UpdateItem, it fails with
+// ErrConditionFailed if the item doesn't exist, rather than creating one
+// with only the index keys. WithCondition applies as well.
+func (c *Client) AddToIndex(ctx context.Context, x SparseIndex, pk, sk string, ipk, isk interface{}, opts ...Option) error {
+	u, err := x.Set(NewUpdate(), ipk, isk)
+	if err != nil {
+		return fmt.Errorf("AddToIndex failed: %w", err)
+	}
+
+	if err := c.updateExisting(ctx, pk, sk, u, opts); err != nil {
+		return fmt.Errorf("AddToIndex failed: %w", err)
+	}
+
+	return nil
+}
+
+func RemoveFromIndex(svc dynamodbiface.DynamoDBAPI, table string, x SparseIndex, pk, sk string, opts ...Option) error {
+	return RemoveFromIndexWithContext(context.Background(), svc, table, x, pk, sk, opts...)
+}
+
+// RemoveFromIndexWithContext is Client.RemoveFromIndex for table.
+func RemoveFromIndexWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, x SparseIndex, pk, sk string, opts ...Option) error {
+	return New(svc, WithTable(table)).RemoveFromIndex(ctx, x, pk, sk, opts...)
+}
+
+// RemoveFromIndex takes the item with the key pk and sk out of the sparse
+// index x, keeping its other attributes. It fails like AddToIndex if the
+// item doesn't exist.
+func (c *Client) RemoveFromIndex(ctx context.Context, x SparseIndex, pk, sk string, opts ...Option) error {
+	if err := c.updateExisting(ctx, pk, sk, x.Clear(NewUpdate()), opts); err != nil {
+		return fmt.Errorf("RemoveFromIndex failed: %w", err)
+	}
+
+	return nil
+}
+
+// updateExisting applies u to the item with the key pk and sk, if it exists.
+func (c *Client) updateExisting(ctx context.Context, pk, sk string, u *Update, opts []Option) error {
+	o, err := c.apply(opts)
+	if err != nil {
+		return err
+	}
+
+	kp, ks, err := c.keys(ctx, o, pk, sk)
+	if err != nil {
+		return err
+	}
+
+	cond := IfExists(kp.Name)
+	if o.condition != nil {
+		cond = andCondition(*o.condition, cond)
+	}
+
+	o.condition = &cond
+	_, err = c.updateItem(ctx, kp, ks, u, o)
+	return err
+}
+
+func QuerySparse(svc dynamodbiface.DynamoDBAPI, table string, x SparseIndex, value interface{}, opts ...Option) (*Result, error) {
+	return QuerySparseWithContext(context.Background(), svc, table, x, value, opts...)
+}
+
+// QuerySparseWithContext is Client.QuerySparse for table.
+func QuerySparseWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, x SparseIndex, value interface{}, opts ...Option) (*Result, error) {
+	return New(svc, WithTable(table)).QuerySparse(ctx, x, value, opts...)
+}
+
+// QuerySparse reads the items in the sparse index x with the partition key
+// value, e.g. the state they are in, as QueryIndex does, so WithSortKey,
+// WithLimit, and the other read options apply. The value may be of any
+// scalar type. With a nil value, the whole index is scanned instead, as
+// Scan does with WithIndex, which reads only the items in it.
+func (c *Client) QuerySparse(ctx context.Context, x SparseIndex, value interface{}, opts ...Option) (*Result, error) {
+	if value == nil {
+		return c.Scan(ctx, append(opts[:len(opts):len(opts)], WithIndex(x.Name))...)
+	}
+
+	o, err := c.apply(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := repoAttr{name: x.PK}.key(value)
+	if err != nil {
+		return nil, fmt.Errorf("QuerySparse failed: %w", err)
+	}
+
+	return c.queryIndex(ctx, x.Name, key, o)
+}
@@ -0,0 +1,152 @@
+package libdy
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// maxStormLevel caps the escalation of RetryStorm pauses at 32x.
+const maxStormLevel = 5
+
+// RetryStorm detects retry storms: many operations retrying against the same
+// table at once, as during a regional throttling event. Independent
+// per-call backoffs keep hammering the table in that case, so once a storm
+// is detected, every operation of the Clients sharing the RetryStorm holds
+// off the table for a common pause before its next attempt. Storms that
+// recur right after a pause escalate it, doubling it each time up to 32x.
+//
+//	storm := libdy.NewRetryStorm(50, 10*time.Second, time.Second)
+//	client := libdy.New(svc, libdy.WithRetryStorm(storm))
+type RetryStorm struct {
+	threshold int
+	window    time.Duration
+	pause     time.Duration
+	clock     Clock
+	mu        sync.Mutex
+	tables    map[string]*stormState
+}
+
+type stormState struct {
+	retries []time.Time // within the window
+	until   time.Time   // end of the current pause
+	level   int
+}
+
+// NewRetryStorm returns a detector declaring a storm on a table when it sees
+// threshold retries within window, and pausing the table for pause.
+func NewRetryStorm(threshold int, window, pause time.Duration) *RetryStorm {
+	return &RetryStorm{
+		threshold: threshold,
+		window:    window,
+		pause:     pause,
+		tables:    map[string]*stormState{},
+	}
+}
+
+// WithClock sets the time source of the windows and pauses of s,
+// SystemClock by default, and returns s. A RetryStorm is shared by Clients,
+// so it doesn't follow theirs.
+func (s *RetryStorm) WithClock(c Clock) *RetryStorm {
+	s.clock = c
+	return s
+}
+
+func (s *RetryStorm) now() time.Time {
+	if s.clock == nil {
+		return time.Now()
+	}
+
+	return s.clock.Now()
+}
+
+// WithRetryStorm coordinates the retries of the Client through s.
+func WithRetryStorm(s *RetryStorm) Option {
+	return func(o *options) { o.storm = s }
+}
+
+// Storming reports whether table is paused because of a retry storm.
+func (s *RetryStorm) Storming(table string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.tables[table]
+	return ok && s.now().Before(st.until)
+}
+
+// retried records a retry against table, returning the pause if it starts a
+// storm.
+func (s *RetryStorm) retried(table string) time.Duration {
+	if s == nil {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.tables[table]
+	if !ok {
+		st = &stormState{}
+		s.tables[table] = st
+	}
+
+	now := s.now()
+	if now.Before(st.until) {
+		return 0 // already paused
+	}
+
+	i := 0
+	for i < len(st.retries) && now.Sub(st.retries[i]) > s.window {
+		i++
+	}
+
+	st.retries = append(st.retries[i:], now)
+	if len(st.retries) < s.threshold {
+		return 0
+	}
+
+	switch {
+	case !st.until.IsZero() && now.Sub(st.until) <= s.window:
+		if st.level < maxStormLevel {
+			st.level++ // storm right after a pause
+		}
+	default:
+		st.level = 0
+	}
+
+	d := s.pause << st.level
+	st.until = now.Add(d)
+	st.retries = nil
+	return d
+}
+
+// wait blocks while table is paused. A nil RetryStorm never waits.
+func (s *RetryStorm) wait(ctx context.Context, table string) error {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	var d time.Duration
+	if st, ok := s.tables[table]; ok {
+		d = st.until.Sub(s.now())
+	}
+
+	s.mu.Unlock()
+	if d <= 0 {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.after(d):
+		return nil
+	}
+}
+
+func (s *RetryStorm) after(d time.Duration) <-chan time.Time {
+	if s.clock == nil {
+		return time.After(d)
+	}
+
+	return s.clock.After(d)
+}
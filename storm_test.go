@@ -0,0 +1,117 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryStorm(t *testing.T) {
+	clock := NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	s := NewRetryStorm(3, time.Second, time.Minute).WithClock(clock)
+	for i := 0; i < 2; i++ {
+		if d := s.retried("t"); d != 0 {
+			t.Fatalf("retry %d paused for %v", i, d)
+		}
+	}
+
+	if d := s.retried("t"); d != time.Minute {
+		t.Fatalf("pause = %v, want 1m", d)
+	}
+
+	if !s.Storming("t") || s.Storming("u") {
+		t.Fatal("Storming: want t only")
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- s.wait(context.Background(), "t") }()
+	select {
+	case err := <-done:
+		t.Fatalf("wait returned before the pause ended: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	for len(done) == 0 {
+		clock.Advance(time.Minute) // past the pause, whenever wait reads the clock
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Storming("t") {
+		t.Error("Storming after the pause")
+	}
+
+	// A storm right after the pause doubles it.
+	for i := 0; i < 3; i++ {
+		s.retried("t")
+	}
+
+	if !s.Storming("t") {
+		t.Fatal("no storm after the pause")
+	}
+
+	if got, want := s.tables["t"].until.Sub(clock.Now()), 2*time.Minute; got != want {
+		t.Errorf("pause = %v, want %v", got, want)
+	}
+}
+
+func TestRetryStormPause(t *testing.T) {
+	clock := NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	s := NewRetryStorm(2, time.Minute, time.Minute).WithClock(clock)
+	o, err := New(nil, WithTable("t"), WithRetryStorm(s), fast(5)).apply(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The second retry starts a storm, holding the third attempt back until
+	// the pause ends.
+	calls := make(chan int, 10)
+	n := 0
+	op := func(context.Context) error {
+		n++
+		calls <- n
+		if n <= 2 {
+			return outage
+		}
+
+		return nil
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := retryN(context.Background(), o, "op", op)
+		done <- err
+	}()
+
+	<-calls
+	<-calls
+	for !s.Storming("t") {
+		time.Sleep(time.Millisecond)
+	}
+
+	select {
+	case <-calls:
+		t.Fatal("attempted during the pause")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// Operations on the table hold off too, until their context is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := retryN(ctx, o, "op", func(context.Context) error { return nil }); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("during the pause: %v, want the context error", err)
+	}
+
+	for len(done) == 0 {
+		clock.Advance(time.Minute) // past the pause, whenever wait reads the clock
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := <-done; err != nil || n != 3 {
+		t.Errorf("after the pause: %v after %d calls, want success on the third", err, n)
+	}
+}
@@ -0,0 +1,205 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// shardLease is the default lease duration of ShardLeases.
+const shardLease = 30 * time.Second
+
+// errLeaseLost stops the reading of a shard whose lease was taken over.
+var errLeaseLost = errors.New("lease lost")
+
+// ShardLeases keeps the checkpoints of StreamReaders in a control table, and
+// shares the shards of a stream among the workers that read it: a worker
+// reads a shard while it holds its lease, renews it with each checkpoint
+// (after each batch, or every third of the lease when idle), and takes over
+// the shards whose lease expired, e.g. of a worker that stopped, from their
+// last checkpoint. Workers balance the shards among themselves, taking one
+// shard at a time from the busiest worker; its reader stops at its next
+// checkpoint. A batch being processed at a handover may be delivered twice,
+// so the handler must be idempotent.
+//
+// The control table has one item per shard, keyed by the string attributes
+// "stream" (partition key) and "shard" (sort key):
+//
+//	libdy.EnsureTable(svc, libdy.TableDef{Name: "leases", PK: "stream", SK: "shard"})
+//	leases := libdy.NewShardLeases(libdy.New(svc, libdy.WithTable("leases")), "", 0)
+//	r := libdy.NewStreamReader(streams, libdy.StreamConfig{Stream: arn, Leases: leases})
+type ShardLeases struct {
+	c     *Client
+	opts  []Option
+	o     options // of opts, for the clock and IDs
+	owner string
+	lease time.Duration
+}
+
+// NewShardLeases returns ShardLeases in the table of c (or the one set in
+// opts), for the worker owner, which must be unique among the workers; ""
+// defaults to the host name, process ID, and a random suffix. lease is how
+// long a silent worker keeps its shards; 0 means 30s.
+func NewShardLeases(c *Client, owner string, lease time.Duration, opts ...Option) *ShardLeases {
+	o, _ := c.apply(opts) // a missing table fails at the first update
+	if owner == "" {
+		owner = defaultOwner(o)
+	}
+
+	if lease <= 0 {
+		lease = shardLease
+	}
+
+	return &ShardLeases{c: c, opts: opts, o: o, owner: owner, lease: lease}
+}
+
+// Owner returns the name of this worker.
+func (l *ShardLeases) Owner() string { return l.owner }
+
+// leaseItem is the control item of a shard.
+type leaseItem struct {
+	owner string
+	until time.Time
+	seq   string // the checkpoint
+	done  bool   // read to the end
+}
+
+func parseLease(item map[string]*dynamodb.AttributeValue) leaseItem {
+	var ret leaseItem
+	if v := item["owner"]; v != nil {
+		ret.owner = aws.StringValue(v.S)
+	}
+
+	if v := item["until"]; v != nil {
+		ms, _ := strconv.ParseInt(aws.StringValue(v.N), 10, 64)
+		ret.until = time.UnixMilli(ms)
+	}
+
+	if v := item["seq"]; v != nil {
+		ret.seq = aws.StringValue(v.S)
+	}
+
+	if v := item["done"]; v != nil {
+		ret.done = aws.BoolValue(v.BOOL)
+	}
+
+	return ret
+}
+
+// load returns the control items of the shards of stream, by shard ID.
+func (l *ShardLeases) load(ctx context.Context, stream string) (map[string]leaseItem, error) {
+	opts := append([]Option{WithConsistentRead()}, l.opts...)
+	res, err := l.c.QueryByKey(ctx, StringKey("stream", stream), Key{}, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("ShardLeases load failed: %w", err)
+	}
+
+	ret := make(map[string]leaseItem, len(res.Items))
+	for _, item := range res.Items {
+		if v := item["shard"]; v != nil {
+			ret[aws.StringValue(v.S)] = parseLease(item)
+		}
+	}
+
+	return ret, nil
+}
+
+// update updates the control item of the shard if cond holds, returning the
+// item after the update, or errLeaseLost if cond fails.
+func (l *ShardLeases) update(ctx context.Context, stream, shard string, u *Update, cond Condition) (leaseItem, error) {
+	o, err := l.c.apply(append(l.opts[:len(l.opts):len(l.opts)], WithCondition(cond), WithReturnValues(dynamodb.ReturnValueAllNew)))
+	if err != nil {
+		return leaseItem{}, err
+	}
+
+	key := keyMap(StringKey("stream", stream), StringKey("shard", shard))
+	item, err := updateItem(ctx, l.c.svc, key, u, o)
+	if errors.Is(err, ErrConditionFailed) {
+		return leaseItem{}, errLeaseLost
+	}
+
+	if err != nil {
+		return leaseItem{}, fmt.Errorf("ShardLeases update failed: %w", err)
+	}
+
+	return parseLease(item), nil
+}
+
+// acquire takes the lease of the shard if it is free or expired, or, if
+// from is set, if from holds it. It returns errLeaseLost if it can't.
+func (l *ShardLeases) acquire(ctx context.Context, stream, shard, from string) (leaseItem, error) {
+	now := l.o.now()
+	cond := Condition{
+		Label: "lease",
+		Expr:  "(attribute_not_exists(#ld) OR #ld = :f) AND (attribute_not_exists(#lo) OR #lo = :me OR #lu < :now)",
+		Names: map[string]*string{"#ld": aws.String("done"), "#lo": aws.String("owner"), "#lu": aws.String("until")},
+		Values: map[string]*dynamodb.AttributeValue{
+			":f":   {BOOL: aws.Bool(false)},
+			":me":  {S: aws.String(l.owner)},
+			":now": {N: aws.String(strconv.FormatInt(now.UnixMilli(), 10))},
+		},
+	}
+
+	if from != "" {
+		cond.Expr = "(attribute_not_exists(#ld) OR #ld = :f) AND #lo = :from"
+		delete(cond.Values, ":me")
+		delete(cond.Values, ":now")
+		delete(cond.Names, "#lu")
+		cond.Values[":from"] = &dynamodb.AttributeValue{S: aws.String(from)}
+	}
+
+	return l.update(ctx, stream, shard, l.renewal(now), cond)
+}
+
+// renewal returns the update extending the lease from now.
+func (l *ShardLeases) renewal(now time.Time) *Update {
+	return NewUpdate().
+		Set("owner", &dynamodb.AttributeValue{S: aws.String(l.owner)}).
+		Set("until", &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(now.Add(l.lease).UnixMilli(), 10))})
+}
+
+// owned is the condition that this worker holds the lease.
+func (l *ShardLeases) owned() Condition {
+	return Condition{
+		Label:  "lease",
+		Expr:   "#lo = :me",
+		Names:  map[string]*string{"#lo": aws.String("owner")},
+		Values: map[string]*dynamodb.AttributeValue{":me": {S: aws.String(l.owner)}},
+	}
+}
+
+// checkpoint saves seq, if set, as the position of the shard, and renews
+// the lease. It returns errLeaseLost if another worker took the shard.
+func (l *ShardLeases) checkpoint(ctx context.Context, stream, shard, seq string) error {
+	u := l.renewal(l.o.now())
+	if seq != "" {
+		u.Set("seq", &dynamodb.AttributeValue{S: aws.String(seq)})
+	}
+
+	_, err := l.update(ctx, stream, shard, u, l.owned())
+	return err
+}
+
+// finish marks the shard read to the end.
+func (l *ShardLeases) finish(ctx context.Context, stream, shard, seq string) error {
+	u := NewUpdate().Set("done", &dynamodb.AttributeValue{BOOL: aws.Bool(true)})
+	if seq != "" {
+		u.Set("seq", &dynamodb.AttributeValue{S: aws.String(seq)})
+	}
+
+	_, err := l.update(ctx, stream, shard, u, l.owned())
+	return err
+}
+
+// release gives up the lease of the shard, so another worker can take it
+// over right away.
+func (l *ShardLeases) release(ctx context.Context, stream, shard string) error {
+	u := NewUpdate().Set("until", &dynamodb.AttributeValue{N: aws.String("0")})
+	_, err := l.update(ctx, stream, shard, u, l.owned())
+	return err
+}
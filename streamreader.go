@@ -0,0 +1,648 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams/dynamodbstreamsiface"
+)
+
+// Defaults of StreamConfig.
+const (
+	streamPoll     = time.Second
+	streamDiscover = 10 * time.Second
+)
+
+var (
+	ErrNoStream      = errors.New("libdy: table has no stream")
+	ErrStreamTrimmed = errors.New("libdy: stream records trimmed before they were read")
+)
+
+// StreamConfig describes where a StreamReader starts reading.
+type StreamConfig struct {
+	Stream string // the stream ARN; see LatestStreamARN
+
+	// Start is the position of the shards open when the reader starts:
+	// dynamodbstreams.ShardIteratorTypeTrimHorizon (the default, the oldest
+	// record kept, about 24 hours), ShardIteratorTypeLatest (only new
+	// records), or ShardIteratorTypeAtSequenceNumber and
+	// ShardIteratorTypeAfterSequenceNumber, from the sequence numbers in
+	// Sequences. Each shard keeps the position it was found with, however
+	// late it is claimed. Shards without a sequence number, and shards
+	// created later by splits, are read from the oldest record.
+	Start     string
+	Sequences map[string]string // shard ID to sequence number
+
+	// Trimmed is called when records of a shard past its position were
+	// trimmed away (older than about 24 hours) before they were read. If it
+	// returns nil, the shard continues from the oldest record kept. If it is
+	// nil, the reader fails with ErrStreamTrimmed.
+	Trimmed func(ctx context.Context, shard string) error
+
+	Limit    int64         // records per GetRecords; the default is 1000
+	Poll     time.Duration // wait after reading no records; the default is 1s
+	Discover time.Duration // how often to list new shards; the default is 10s
+
+	// Leases, if set, keeps a checkpoint per shard, and shares the shards
+	// with the other workers using the same ShardLeases table. A shard with
+	// a checkpoint resumes after it, regardless of Start.
+	Leases *ShardLeases
+}
+
+// StreamHandler receives the records read from a shard, in order. It is
+// called concurrently for different shards. An error stops the reader.
+type StreamHandler func(ctx context.Context, shard string, records []*dynamodbstreams.Record) error
+
+// StreamReader reads all the shards of a DynamoDB stream, following shard
+// splits: a child shard is read only once its parent is read to the end, so
+// the changes to an item are delivered in order.
+type StreamReader struct {
+	svc   dynamodbstreamsiface.DynamoDBStreamsAPI
+	cfg   StreamConfig
+	clock Clock
+}
+
+func NewStreamReader(svc dynamodbstreamsiface.DynamoDBStreamsAPI, cfg StreamConfig) *StreamReader {
+	if cfg.Start == "" {
+		cfg.Start = dynamodbstreams.ShardIteratorTypeTrimHorizon
+	}
+
+	if cfg.Limit <= 0 {
+		cfg.Limit = 1000
+	}
+
+	if cfg.Poll <= 0 {
+		cfg.Poll = streamPoll
+	}
+
+	if cfg.Discover <= 0 {
+		cfg.Discover = streamDiscover
+	}
+
+	return &StreamReader{svc: svc, cfg: cfg}
+}
+
+// WithClock sets the time source of the polls, discoveries, and lease
+// renewals of r, and returns r. By default, r uses the clock of the options
+// of cfg.Leases, if any, else SystemClock.
+func (r *StreamReader) WithClock(c Clock) *StreamReader {
+	r.clock = c
+	return r
+}
+
+// options returns the options timing r (see WithClock).
+func (r *StreamReader) options() options {
+	var o options
+	if r.cfg.Leases != nil {
+		o = r.cfg.Leases.o
+	}
+
+	if r.clock != nil {
+		o.clock = r.clock
+	}
+
+	return o
+}
+
+func LatestStreamARN(svc dynamodbiface.DynamoDBAPI, table string) (string, error) {
+	return LatestStreamARNWithContext(context.Background(), svc, table)
+}
+
+// LatestStreamARNWithContext returns the ARN of the current stream of table,
+// or ErrNoStream if streams are not enabled on it.
+func LatestStreamARNWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string) (string, error) {
+	res, err := svc.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+	if err != nil {
+		return "", fmt.Errorf("DescribeTable failed: %w", awsErr(err))
+	}
+
+	spec := res.Table.StreamSpecification
+	if spec == nil || !aws.BoolValue(spec.StreamEnabled) || res.Table.LatestStreamArn == nil {
+		return "", fmt.Errorf("LatestStreamARN failed: %s: %w", table, ErrNoStream)
+	}
+
+	return aws.StringValue(res.Table.LatestStreamArn), nil
+}
+
+// shardState is the progress of a StreamReader on a shard.
+type shardState struct {
+	shard    *dynamodbstreams.Shard
+	typ, seq string // the position without a checkpoint
+	started  bool
+	done     bool
+}
+
+// shardEnd reports that a StreamReader stopped reading a shard: it was read
+// to the end, or its lease was lost.
+type shardEnd struct {
+	id   string
+	lost bool
+}
+
+// Run reads the stream, calling fn with each batch of records, until ctx is
+// done, fn fails, or the stream is disabled and read to the end, when it
+// returns nil.
+//
+//	arn, err := libdy.LatestStreamARNWithContext(ctx, svc, "orders")
+//	r := libdy.NewStreamReader(streams, libdy.StreamConfig{Stream: arn})
+//	err = r.Run(ctx, func(ctx context.Context, shard string, records []*dynamodbstreams.Record) error {
+//		...
+//	})
+func (r *StreamReader) Run(ctx context.Context, fn StreamHandler) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	shards := map[string]*shardState{}
+	endCh := make(chan shardEnd)
+	errCh := make(chan error, 1)
+	start := func(id, typ, seq string) {
+		shards[id].started = true
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := r.readShard(ctx, id, typ, seq, fn)
+			if err != nil && !errors.Is(err, errLeaseLost) {
+				if r.cfg.Leases != nil {
+					// Let another worker take over right away.
+					rctx, rcancel := context.WithTimeout(context.Background(), 5*time.Second)
+					r.cfg.Leases.release(rctx, r.cfg.Stream, id)
+					rcancel()
+				}
+
+				select {
+				case errCh <- err:
+				default:
+				}
+
+				cancel()
+				return
+			}
+
+			select {
+			case endCh <- shardEnd{id: id, lost: err != nil}:
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	first := true
+	o := r.options()
+	for {
+		discover := o.after(r.cfg.Discover)
+		all, open, err := r.describe(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, sh := range all {
+			id := aws.StringValue(sh.ShardId)
+			if _, ok := shards[id]; ok {
+				continue
+			}
+
+			s := &shardState{shard: sh}
+			s.typ, s.seq = r.position(id, first)
+			shards[id] = s
+
+			// With LATEST, closed shards are history.
+			if first && r.cfg.Start == dynamodbstreams.ShardIteratorTypeLatest &&
+				sh.SequenceNumberRange != nil && sh.SequenceNumberRange.EndingSequenceNumber != nil {
+				s.done = true
+			}
+		}
+
+		if first {
+			r.skipAncestors(shards)
+		}
+
+		var leases map[string]leaseItem
+		if r.cfg.Leases != nil {
+			if leases, err = r.cfg.Leases.load(ctx, r.cfg.Stream); err != nil {
+				return err
+			}
+
+			for id, l := range leases {
+				if s, ok := shards[id]; ok && l.done {
+					s.done = true // by any worker
+				}
+			}
+		}
+
+		for {
+			finished := !open
+			var ready []string
+			running, unfinished := 0, 0
+			for id, s := range shards {
+				if s.done {
+					continue
+				}
+
+				finished = false
+				unfinished++
+				if s.started {
+					running++
+					continue
+				}
+
+				parent, ok := shards[aws.StringValue(s.shard.ParentShardId)]
+				if ok && !parent.done {
+					continue // read the parent first
+				}
+
+				ready = append(ready, id)
+			}
+
+			if finished {
+				return nil
+			}
+
+			if r.cfg.Leases == nil {
+				for _, id := range ready {
+					start(id, shards[id].typ, shards[id].seq)
+				}
+			} else {
+				claimed, err := r.claim(ctx, ready, leases, running, unfinished)
+				if err != nil {
+					return err
+				}
+
+				for id, l := range claimed {
+					typ, seq := shards[id].typ, shards[id].seq
+					if l.seq != "" {
+						typ, seq = dynamodbstreams.ShardIteratorTypeAfterSequenceNumber, l.seq
+					}
+
+					start(id, typ, seq)
+				}
+			}
+
+			first = false
+			select {
+			case <-ctx.Done():
+				select {
+				case err := <-errCh:
+					return err
+				default:
+				}
+
+				return fmt.Errorf("StreamReader canceled: %w", ctx.Err())
+			case end := <-endCh:
+				if end.lost {
+					shards[end.id].started = false
+					break
+				}
+
+				shards[end.id].done = true
+				continue // start the children
+			case <-discover:
+			}
+
+			break
+		}
+	}
+}
+
+// claim takes the leases of the ready shards (see ShardLeases) up to this
+// worker's share of the unfinished shards, and returns them with their
+// checkpoints. Under its share, it also takes one shard from the busiest
+// worker. leases are the control items as last loaded; running is the
+// number of shards this worker reads.
+func (r *StreamReader) claim(ctx context.Context, ready []string, leases map[string]leaseItem, running, unfinished int) (map[string]leaseItem, error) {
+	l := r.cfg.Leases
+	now := r.options().now()
+	held := map[string]int{l.owner: 0}
+	for _, li := range leases {
+		if !li.done && li.owner != "" && li.until.After(now) {
+			held[li.owner]++
+		}
+	}
+
+	share := (unfinished + len(held) - 1) / len(held)
+	ret := map[string]leaseItem{}
+	take := func(id, from string) error {
+		li, err := l.acquire(ctx, r.cfg.Stream, id, from)
+		if errors.Is(err, errLeaseLost) {
+			return nil // another worker was faster
+		}
+
+		if err != nil {
+			return err
+		}
+
+		ret[id] = li
+		running++
+		return nil
+	}
+
+	for _, id := range ready {
+		if running >= share {
+			break
+		}
+
+		li := leases[id]
+		if li.owner != "" && li.owner != l.owner && li.until.After(now) {
+			continue // held by another worker
+		}
+
+		if err := take(id, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	if running >= share {
+		return ret, nil
+	}
+
+	busiest := ""
+	for owner, n := range held {
+		if owner != l.owner && n > share && n > held[busiest] {
+			busiest = owner
+		}
+	}
+
+	for _, id := range ready {
+		if _, ok := ret[id]; !ok && busiest != "" && leases[id].owner == busiest {
+			return ret, take(id, busiest)
+		}
+	}
+
+	return ret, nil
+}
+
+// describe returns all the shards of the stream, and whether the stream is
+// still enabled.
+func (r *StreamReader) describe(ctx context.Context) ([]*dynamodbstreams.Shard, bool, error) {
+	input := &dynamodbstreams.DescribeStreamInput{StreamArn: aws.String(r.cfg.Stream)}
+	var ret []*dynamodbstreams.Shard
+	for {
+		var res *dynamodbstreams.DescribeStreamOutput
+		err := controlCall(ctx, "DescribeStream", func() error {
+			var err error
+			res, err = r.svc.DescribeStreamWithContext(ctx, input)
+			return err
+		}, controlRetriable)
+
+		if err != nil {
+			return nil, false, err
+		}
+
+		d := res.StreamDescription
+		ret = append(ret, d.Shards...)
+		if d.LastEvaluatedShardId == nil {
+			return ret, aws.StringValue(d.StreamStatus) != dynamodbstreams.StreamStatusDisabled, nil
+		}
+
+		input.ExclusiveStartShardId = d.LastEvaluatedShardId
+	}
+}
+
+// skipAncestors marks the ancestors of the shards in Sequences as done, as
+// their records precede the sequence numbers.
+func (r *StreamReader) skipAncestors(shards map[string]*shardState) {
+	if r.cfg.Start != dynamodbstreams.ShardIteratorTypeAtSequenceNumber &&
+		r.cfg.Start != dynamodbstreams.ShardIteratorTypeAfterSequenceNumber {
+		return
+	}
+
+	for id := range r.cfg.Sequences {
+		s, ok := shards[id]
+		for ok {
+			if s, ok = shards[aws.StringValue(s.shard.ParentShardId)]; ok {
+				s.done = true
+			}
+		}
+	}
+}
+
+// position returns where to start reading the shard, found on the first
+// discovery or a later one.
+func (r *StreamReader) position(id string, first bool) (string, string) {
+	switch r.cfg.Start {
+	case dynamodbstreams.ShardIteratorTypeLatest:
+		if first {
+			return r.cfg.Start, ""
+		}
+	case dynamodbstreams.ShardIteratorTypeAtSequenceNumber, dynamodbstreams.ShardIteratorTypeAfterSequenceNumber:
+		if seq, ok := r.cfg.Sequences[id]; ok && seq != "" {
+			return r.cfg.Start, seq
+		}
+	}
+
+	return dynamodbstreams.ShardIteratorTypeTrimHorizon, ""
+}
+
+// readShard reads the shard from the position to its end. With Leases, it
+// checkpoints after each batch, and returns errLeaseLost if another worker
+// took the shard over.
+func (r *StreamReader) readShard(ctx context.Context, id, typ, seq string, fn StreamHandler) error {
+	var it *string
+	read := false
+
+	// trim reports that the position is past the retention period, and
+	// moves it to the oldest record kept.
+	trim := func() error {
+		if err := r.trimmed(ctx, id); err != nil {
+			return err
+		}
+
+		var err error
+		typ, seq, read = dynamodbstreams.ShardIteratorTypeTrimHorizon, "", false
+		it, err = r.iterator(ctx, id, typ, seq)
+		return err
+	}
+
+	seek := func() error {
+		var err error
+		it, err = r.iterator(ctx, id, typ, seq)
+		if ErrorCode(err) == dynamodbstreams.ErrCodeTrimmedDataAccessException {
+			return trim()
+		}
+
+		return err
+	}
+
+	if err := seek(); err != nil {
+		return err
+	}
+
+	l := r.cfg.Leases
+	o := r.options()
+	renewed := o.now()
+	for it != nil {
+		var res *dynamodbstreams.GetRecordsOutput
+		err := controlCall(ctx, "GetRecords", func() error {
+			var err error
+			res, err = r.svc.GetRecordsWithContext(ctx, &dynamodbstreams.GetRecordsInput{
+				ShardIterator: it,
+				Limit:         aws.Int64(r.cfg.Limit),
+			})
+
+			return err
+		}, controlRetriable)
+
+		switch ErrorCode(err) {
+		case dynamodbstreams.ErrCodeExpiredIteratorException:
+			// Iterators expire after 15 minutes; continue from the last record.
+			if read {
+				typ = dynamodbstreams.ShardIteratorTypeAfterSequenceNumber
+			}
+
+			if err := seek(); err != nil {
+				return err
+			}
+
+			continue
+		case dynamodbstreams.ErrCodeTrimmedDataAccessException:
+			if err := trim(); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err != nil {
+			return fmt.Errorf("StreamReader failed: shard %s: %w", id, err)
+		}
+
+		if len(res.Records) > 0 {
+			if err := fn(ctx, id, res.Records); err != nil {
+				return fmt.Errorf("StreamReader failed: shard %s: %w", id, err)
+			}
+
+			if d := res.Records[len(res.Records)-1].Dynamodb; d != nil {
+				seq, read = aws.StringValue(d.SequenceNumber), true
+			}
+		}
+
+		it = res.NextShardIterator
+		if l != nil && it != nil && (len(res.Records) > 0 || o.now().Sub(renewed) > l.lease/3) {
+			var cp string
+			if read {
+				cp = seq
+			}
+
+			if err := l.checkpoint(ctx, r.cfg.Stream, id, cp); err != nil {
+				return err
+			}
+
+			renewed = o.now()
+		}
+
+		if it != nil && len(res.Records) == 0 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("StreamReader canceled: %w", ctx.Err())
+			case <-o.after(r.cfg.Poll):
+			}
+		}
+	}
+
+	if l != nil {
+		if !read {
+			seq = ""
+		}
+
+		return l.finish(ctx, r.cfg.Stream, id, seq)
+	}
+
+	return nil // closed, and read to the end
+}
+
+func (r *StreamReader) iterator(ctx context.Context, id, typ, seq string) (*string, error) {
+	input := &dynamodbstreams.GetShardIteratorInput{
+		StreamArn:         aws.String(r.cfg.Stream),
+		ShardId:           aws.String(id),
+		ShardIteratorType: aws.String(typ),
+	}
+
+	if seq != "" {
+		input.SequenceNumber = aws.String(seq)
+	}
+
+	var res *dynamodbstreams.GetShardIteratorOutput
+	err := controlCall(ctx, "GetShardIterator", func() error {
+		var err error
+		res, err = r.svc.GetShardIteratorWithContext(ctx, input)
+		return err
+	}, controlRetriable)
+
+	if ErrorCode(err) == dynamodbstreams.ErrCodeResourceNotFoundException {
+		return nil, nil // trimmed away; nothing left to read
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("StreamReader failed: shard %s: %w", id, err)
+	}
+
+	return res.ShardIterator, nil
+}
+
+// trimmed reports that records of the shard were trimmed before they were
+// read (see StreamConfig.Trimmed).
+func (r *StreamReader) trimmed(ctx context.Context, id string) error {
+	if r.cfg.Trimmed == nil {
+		return fmt.Errorf("StreamReader failed: shard %s: %w", id, ErrStreamTrimmed)
+	}
+
+	if err := r.cfg.Trimmed(ctx, id); err != nil {
+		return fmt.Errorf("StreamReader failed: shard %s: %w", id, err)
+	}
+
+	return nil
+}
+
+// ReadStream runs r, calling fn with each INSERT, MODIFY, and REMOVE event,
+// its images unmarshaled into T. Events of a shard are delivered in order;
+// fn is called concurrently for different shards. To resume later, use
+// StreamConfig.Leases, or keep the last Shard and SequenceNumber seen per
+// shard for StreamConfig.Sequences, with ShardIteratorTypeAfterSequenceNumber.
+func ReadStream[T any](ctx context.Context, r *StreamReader, fn func(ctx context.Context, ev ChangeEvent[T]) error) error {
+	return r.Run(ctx, func(ctx context.Context, shard string, records []*dynamodbstreams.Record) error {
+		for _, rec := range records {
+			ev, err := DecodeStreamRecord[T](rec)
+			if err != nil {
+				return err
+			}
+
+			ev.Shard = shard
+			if err := fn(ctx, ev); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// StreamEvents runs r in the background, sending its events to the returned
+// channel, with buffer slots, which is closed when r stops. The reason it
+// stopped is then sent to the error channel: nil, a read error, or the
+// context error.
+func StreamEvents[T any](ctx context.Context, r *StreamReader, buffer int) (<-chan ChangeEvent[T], <-chan error) {
+	events := make(chan ChangeEvent[T], buffer)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		defer close(events)
+		errc <- ReadStream(ctx, r, func(ctx context.Context, ev ChangeEvent[T]) error {
+			select {
+			case events <- ev:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+
+	return events, errc
+}
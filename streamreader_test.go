@@ -0,0 +1,286 @@
+package libdy_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams/dynamodbstreamsiface"
+	"github.com/flowerinthenight/libdy"
+	"github.com/flowerinthenight/libdy/libdytest"
+)
+
+// fakeShard is a shard of a fakeStream. Its records are sequence numbers;
+// the ones before trimmed are past the retention period.
+type fakeShard struct {
+	id, parent string
+	records    []string
+	trimmed    int
+	closed     bool
+}
+
+// fakeStream is a DynamoDB stream of fakeShards. Its iterators are the
+// shard ID and the index of the next record.
+type fakeStream struct {
+	dynamodbstreamsiface.DynamoDBStreamsAPI
+	mu       sync.Mutex
+	shards   []*fakeShard
+	disabled bool
+}
+
+func (f *fakeStream) shard(id string) *fakeShard {
+	for _, s := range f.shards {
+		if s.id == id {
+			return s
+		}
+	}
+
+	return nil
+}
+
+// add appends records to the shard.
+func (f *fakeStream) add(id string, seqs ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := f.shard(id)
+	s.records = append(s.records, seqs...)
+}
+
+func (f *fakeStream) DescribeStreamWithContext(ctx aws.Context, in *dynamodbstreams.DescribeStreamInput, opts ...request.Option) (*dynamodbstreams.DescribeStreamOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d := &dynamodbstreams.StreamDescription{StreamArn: in.StreamArn, StreamStatus: aws.String(dynamodbstreams.StreamStatusEnabled)}
+	if f.disabled {
+		d.StreamStatus = aws.String(dynamodbstreams.StreamStatusDisabled)
+	}
+
+	for _, s := range f.shards {
+		sh := &dynamodbstreams.Shard{ShardId: aws.String(s.id), SequenceNumberRange: &dynamodbstreams.SequenceNumberRange{}}
+		if s.parent != "" {
+			sh.ParentShardId = aws.String(s.parent)
+		}
+
+		if s.closed {
+			sh.SequenceNumberRange.EndingSequenceNumber = aws.String(s.records[len(s.records)-1])
+		}
+
+		d.Shards = append(d.Shards, sh)
+	}
+
+	return &dynamodbstreams.DescribeStreamOutput{StreamDescription: d}, nil
+}
+
+func (f *fakeStream) GetShardIteratorWithContext(ctx aws.Context, in *dynamodbstreams.GetShardIteratorInput, opts ...request.Option) (*dynamodbstreams.GetShardIteratorOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := f.shard(aws.StringValue(in.ShardId))
+	if s == nil {
+		return nil, awserr.New(dynamodbstreams.ErrCodeResourceNotFoundException, "no shard", nil)
+	}
+
+	var i int
+	switch aws.StringValue(in.ShardIteratorType) {
+	case dynamodbstreams.ShardIteratorTypeTrimHorizon:
+		i = s.trimmed
+	case dynamodbstreams.ShardIteratorTypeLatest:
+		i = len(s.records)
+	default:
+		i = -1
+		for j, seq := range s.records {
+			if seq == aws.StringValue(in.SequenceNumber) {
+				i = j
+			}
+		}
+
+		if i < s.trimmed {
+			return nil, awserr.New(dynamodbstreams.ErrCodeTrimmedDataAccessException, "trimmed", nil)
+		}
+
+		if aws.StringValue(in.ShardIteratorType) == dynamodbstreams.ShardIteratorTypeAfterSequenceNumber {
+			i++
+		}
+	}
+
+	return &dynamodbstreams.GetShardIteratorOutput{ShardIterator: aws.String(fmt.Sprint(s.id, "/", i))}, nil
+}
+
+func (f *fakeStream) GetRecordsWithContext(ctx aws.Context, in *dynamodbstreams.GetRecordsInput, opts ...request.Option) (*dynamodbstreams.GetRecordsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id, n, _ := strings.Cut(aws.StringValue(in.ShardIterator), "/")
+	s := f.shard(id)
+	i, _ := strconv.Atoi(n)
+	if i < s.trimmed {
+		return nil, awserr.New(dynamodbstreams.ErrCodeTrimmedDataAccessException, "trimmed", nil)
+	}
+
+	ret := &dynamodbstreams.GetRecordsOutput{}
+	for ; i < len(s.records) && len(ret.Records) < int(aws.Int64Value(in.Limit)); i++ {
+		ret.Records = append(ret.Records, &dynamodbstreams.Record{
+			Dynamodb: &dynamodbstreams.StreamRecord{SequenceNumber: aws.String(s.records[i])},
+		})
+	}
+
+	if !s.closed || i < len(s.records) {
+		ret.NextShardIterator = aws.String(fmt.Sprint(s.id, "/", i))
+	}
+
+	return ret, nil
+}
+
+// read is a StreamHandler collecting the sequence numbers read per shard.
+type read struct {
+	mu   sync.Mutex
+	seqs map[string][]string
+}
+
+func (r *read) handle(ctx context.Context, shard string, records []*dynamodbstreams.Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.seqs == nil {
+		r.seqs = map[string][]string{}
+	}
+
+	for _, rec := range records {
+		r.seqs[shard] = append(r.seqs[shard], aws.StringValue(rec.Dynamodb.SequenceNumber))
+	}
+
+	return nil
+}
+
+func (r *read) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return fmt.Sprint(r.seqs)
+}
+
+func TestStreamReaderLatest(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	f := libdytest.SetupFake(t, libdy.TableDef{Name: "leases", PK: "stream", SK: "shard"})
+	clock := libdy.NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	c := libdy.New(f, libdy.WithTable("leases"), libdy.WithClock(clock))
+
+	// Another worker holds s2 for a minute.
+	err := c.PutItem(ctx, map[string]*dynamodb.AttributeValue{
+		"stream": {S: aws.String("arn")},
+		"shard":  {S: aws.String("s2")},
+		"owner":  {S: aws.String("other")},
+		"until":  {N: aws.String(fmt.Sprint(clock.Now().Add(time.Minute).UnixMilli()))},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := &fakeStream{shards: []*fakeShard{
+		{id: "s1", records: []string{"1", "2"}},
+		{id: "s2", records: []string{"3", "4"}},
+	}}
+
+	r := libdy.NewStreamReader(svc, libdy.StreamConfig{
+		Stream: "arn",
+		Start:  dynamodbstreams.ShardIteratorTypeLatest,
+		Leases: libdy.NewShardLeases(c, "me", 30*time.Second),
+	})
+
+	var h read
+	done := make(chan error, 1)
+	go func() { done <- r.Run(ctx, h.handle) }()
+
+	// until advances the clock until cond holds.
+	until := func(what string, cond func() bool) {
+		for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("no %s; read %v", what, &h)
+			}
+
+			clock.Advance(5 * time.Second)
+		}
+	}
+
+	until("takeover of s2", func() bool {
+		item, err := c.GetItem(ctx, "stream:arn", "shard:s2")
+		return err == nil && aws.StringValue(item["owner"].S) == "me"
+	})
+
+	// s2, claimed after the first discovery, also starts at LATEST.
+	svc.add("s1", "5")
+	svc.add("s2", "6")
+	until("new records", func() bool { return h.String() == "map[s1:[5] s2:[6]]" })
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run: %v, want canceled", err)
+	}
+}
+
+func TestStreamReaderTrimmed(t *testing.T) {
+	ctx := context.Background()
+	stream := func() *fakeStream {
+		return &fakeStream{disabled: true, shards: []*fakeShard{
+			{id: "s1", records: []string{"1", "2", "3", "4"}, trimmed: 2, closed: true},
+		}}
+	}
+
+	cfg := libdy.StreamConfig{
+		Stream:    "arn",
+		Start:     dynamodbstreams.ShardIteratorTypeAfterSequenceNumber,
+		Sequences: map[string]string{"s1": "1"},
+	}
+
+	var h read
+	err := libdy.NewStreamReader(stream(), cfg).Run(ctx, h.handle)
+	if !errors.Is(err, libdy.ErrStreamTrimmed) || h.String() != "map[]" {
+		t.Fatalf("Run past the retention: %v, read %v, want ErrStreamTrimmed", err, &h)
+	}
+
+	var trimmed []string
+	cfg.Trimmed = func(ctx context.Context, shard string) error {
+		trimmed = append(trimmed, shard)
+		return nil
+	}
+
+	if err := libdy.NewStreamReader(stream(), cfg).Run(ctx, h.handle); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := fmt.Sprint(trimmed, " ", &h); got != "[s1] map[s1:[3 4]]" {
+		t.Errorf("trimmed and read %s, want [s1] map[s1:[3 4]]", got)
+	}
+}
+
+func TestStreamReaderLineage(t *testing.T) {
+	svc := &fakeStream{disabled: true, shards: []*fakeShard{
+		{id: "s3", parent: "s2", records: []string{"5"}, closed: true},
+		{id: "s2", parent: "s1", records: []string{"3", "4"}, closed: true},
+		{id: "s1", records: []string{"1", "2"}, closed: true},
+	}}
+
+	var mu sync.Mutex
+	var order []string
+	r := libdy.NewStreamReader(svc, libdy.StreamConfig{Stream: "arn", Limit: 1})
+	err := r.Run(context.Background(), func(ctx context.Context, shard string, records []*dynamodbstreams.Record) error {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, rec := range records {
+			order = append(order, aws.StringValue(rec.Dynamodb.SequenceNumber))
+		}
+
+		return nil
+	})
+
+	// Each child is read once its parent is read to the end.
+	if err != nil || fmt.Sprint(order) != "[1 2 3 4 5]" {
+		t.Errorf("Run = %v, read %v, want the shards in lineage order", err, order)
+	}
+}
@@ -0,0 +1,106 @@
+package libdy
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+)
+
+// ChangeType is the kind of change of a stream record.
+type ChangeType string
+
+const (
+	ChangeInsert ChangeType = "INSERT"
+	ChangeModify ChangeType = "MODIFY"
+	ChangeRemove ChangeType = "REMOVE"
+)
+
+// ttlPrincipal is the identity of deletions made by Time to Live.
+const ttlPrincipal = "dynamodb.amazonaws.com"
+
+// ChangeEvent is a DynamoDB Streams record with its item images unmarshaled
+// into T. It is the same whether the record came from the Streams API (see
+// DecodeStreamRecord), from a Lambda event (see the libdylambda
+// subpackage), or from Kinesis Data Streams (see DecodeKinesisRecord).
+type ChangeEvent[T any] struct {
+	ID             string
+	Type           ChangeType
+	Keys           map[string]*dynamodb.AttributeValue
+	Old            *T // nil unless the stream view type includes old images
+	New            *T // nil unless the stream view type includes new images
+	SequenceNumber string
+	Time           time.Time // approximate creation time
+	TTL            bool      // a deletion by Time to Live
+	Shard          string    // the shard read by ReadStream; empty otherwise
+	Table          string    // set for Kinesis records, see DecodeKinesisRecord
+}
+
+// DecodeStreamRecord decodes a record read with the DynamoDB Streams API.
+func DecodeStreamRecord[T any](r *dynamodbstreams.Record) (ChangeEvent[T], error) {
+	if r.Dynamodb == nil {
+		return ChangeEvent[T]{}, fmt.Errorf("invalid stream record %s: no data", aws.StringValue(r.EventID))
+	}
+
+	ttl := r.UserIdentity != nil &&
+		aws.StringValue(r.UserIdentity.Type) == "Service" &&
+		aws.StringValue(r.UserIdentity.PrincipalId) == ttlPrincipal
+
+	return decodeChange[T](changeRecord{
+		id:   aws.StringValue(r.EventID),
+		name: aws.StringValue(r.EventName),
+		keys: r.Dynamodb.Keys,
+		old:  r.Dynamodb.OldImage,
+		new:  r.Dynamodb.NewImage,
+		seq:  aws.StringValue(r.Dynamodb.SequenceNumber),
+		time: aws.TimeValue(r.Dynamodb.ApproximateCreationDateTime),
+		ttl:  ttl,
+	})
+}
+
+// changeRecord is a stream record in the terms shared by its sources.
+type changeRecord struct {
+	id, name, seq string
+	table         string
+	keys, old     map[string]*dynamodb.AttributeValue
+	new           map[string]*dynamodb.AttributeValue
+	time          time.Time
+	ttl           bool
+}
+
+func decodeChange[T any](r changeRecord) (ChangeEvent[T], error) {
+	ret := ChangeEvent[T]{
+		ID:             r.id,
+		Type:           ChangeType(r.name),
+		Keys:           r.keys,
+		SequenceNumber: r.seq,
+		Time:           r.time,
+		TTL:            r.ttl,
+		Table:          r.table,
+	}
+
+	switch ret.Type {
+	case ChangeInsert, ChangeModify, ChangeRemove:
+	default:
+		return ret, fmt.Errorf("invalid stream record %s: unknown event %q", r.id, r.name)
+	}
+
+	if r.old != nil {
+		ret.Old = new(T)
+		if err := dynamodbattribute.UnmarshalMap(r.old, ret.Old); err != nil {
+			return ret, fmt.Errorf("invalid stream record %s: old image: %w", r.id, err)
+		}
+	}
+
+	if r.new != nil {
+		ret.New = new(T)
+		if err := dynamodbattribute.UnmarshalMap(r.new, ret.New); err != nil {
+			return ret, fmt.Errorf("invalid stream record %s: new image: %w", r.id, err)
+		}
+	}
+
+	return ret, nil
+}
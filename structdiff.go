@@ -0,0 +1,120 @@
+package libdy
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// DiffUpdate returns the Update that turns the item old into new: a SET of
+// each attribute added or changed, and a REMOVE of each attribute gone, or
+// NULL, in new. The attributes in skip, such as the key, are left out. The
+// Update is empty if nothing changed.
+func DiffUpdate(old, new map[string]*dynamodb.AttributeValue, skip ...string) *Update {
+	names := make([]string, 0, len(old)+len(new))
+	for name := range new {
+		names = append(names, name)
+	}
+
+	for name := range old {
+		if _, ok := new[name]; !ok {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	u := NewUpdate()
+	for _, name := range names {
+		if contains(skip, name) || reflect.DeepEqual(old[name], new[name]) {
+			continue
+		}
+
+		switch v := new[name]; {
+		case v != nil && (v.NULL == nil || !*v.NULL):
+			u.Set(name, v)
+		case old[name] != nil:
+			u.Remove(name)
+		}
+	}
+
+	return u
+}
+
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// updateDiff updates the item with the key pk and sk from the item old to
+// new, per DiffUpdate. It makes no request, and returns nil, if nothing
+// changed.
+func (c *Client) updateDiff(ctx context.Context, pk, sk Key, old, new map[string]*dynamodb.AttributeValue, o options) (map[string]*dynamodb.AttributeValue, error) {
+	keys := []string{pk.Name}
+	if sk.Name != "" {
+		keys = append(keys, sk.Name)
+	}
+
+	for _, name := range keys {
+		if !reflect.DeepEqual(old[name], new[name]) {
+			return nil, fmt.Errorf("%w: key attribute %s changed", ErrInvalidRequest, name)
+		}
+	}
+
+	u := DiffUpdate(old, new, keys...)
+	if u.empty() {
+		return nil, nil
+	}
+
+	return c.updateItem(ctx, pk, sk, u, o)
+}
+
+// UpdateFromStruct updates the item with the key pk and sk from old, the
+// value read, to new, setting only the attributes that changed and removing
+// those that became nil, as Client.UpdateItem does. The update is smaller
+// than a put of new, and keeps the other attributes as they are, e.g. those
+// updated meanwhile by other writers:
+//
+//	order, err := libdy.Get[Order](ctx, client, "id:o1", "")
+//	...
+//	changed := order
+//	changed.Status = "shipped"
+//	_, err = libdy.UpdateFromStruct(ctx, client, "id:o1", "", order, changed)
+//
+// Both values are marshaled as Put does. The key attributes must not
+// change. If nothing changed, it makes no request and returns nil.
+func UpdateFromStruct[T any](ctx context.Context, c *Client, pk, sk string, old, new T, opts ...Option) (map[string]*dynamodb.AttributeValue, error) {
+	o, err := c.apply(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	kp, ks, err := c.keys(ctx, o, pk, sk)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateFromStruct failed: %w", err)
+	}
+
+	oldItem, err := o.marshal(old)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateFromStruct failed: %w", err)
+	}
+
+	newItem, err := o.marshal(new)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateFromStruct failed: %w", err)
+	}
+
+	ret, err := c.updateDiff(ctx, kp, ks, oldItem, newItem, o)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateFromStruct failed: %w", err)
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,122 @@
+package libdy
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// WithDryRun makes SyncReferenceData and SyncIndexes compute their changes
+// without applying them.
+func WithDryRun() Option {
+	return func(o *options) { o.dryRun = true }
+}
+
+// SyncPlan lists the changes SyncReferenceData made (or, with WithDryRun,
+// would make).
+type SyncPlan struct {
+	Puts    []map[string]*dynamodb.AttributeValue // new or changed items
+	Deletes []map[string]*dynamodb.AttributeValue // keys of items not desired
+}
+
+func SyncReferenceData(svc dynamodbiface.DynamoDBAPI, table string, desired []map[string]*dynamodb.AttributeValue, opts ...Option) (*SyncPlan, error) {
+	return SyncReferenceDataWithContext(context.Background(), svc, table, desired, opts...)
+}
+
+// SyncReferenceDataWithContext makes the contents of table equal to desired,
+// a small lookup dataset managed as code: it scans the table, then puts the
+// desired items that are missing or different, and deletes the items that
+// are not desired, leaving identical items alone. Writes are batched.
+//
+// Use WithDryRun to only compute the plan. On partial failure, the plan is
+// returned along with a *BatchWriteError listing the writes that failed.
+func SyncReferenceDataWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, desired []map[string]*dynamodb.AttributeValue, opts ...Option) (*SyncPlan, error) {
+	o := options{table: table}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	keyAttrs, err := tableKeyAttrs(ctx, svc, table)
+	if err != nil {
+		return nil, err
+	}
+
+	id := func(item map[string]*dynamodb.AttributeValue) string {
+		ids := make([]string, len(keyAttrs))
+		for i, a := range keyAttrs {
+			if v, ok := item[a]; ok {
+				ids[i] = v.String()
+			}
+		}
+
+		return strings.Join(ids, "\x00")
+	}
+
+	so := o
+	so.startKey, so.limit, so.pageSize, so.maxItems, so.filter, so.projection = nil, 0, 0, 0, nil, nil
+	current, err := scan(ctx, svc, &dynamodb.ScanInput{TableName: aws.String(table)}, so)
+	if err != nil {
+		return nil, err
+	}
+
+	// Compare the items as read, not as stored.
+	existing := map[string]map[string]*dynamodb.AttributeValue{}
+	for _, item := range current.Items {
+		if item, err = o.decode(ctx, item); err != nil {
+			return nil, err
+		}
+
+		existing[id(item)] = item
+	}
+
+	plan := &SyncPlan{}
+	seen := map[string]bool{}
+	for _, item := range desired {
+		for _, a := range keyAttrs {
+			if _, ok := item[a]; !ok {
+				return nil, fmt.Errorf("desired item is missing key attribute %q", a)
+			}
+		}
+
+		k := id(item)
+		if seen[k] {
+			return nil, fmt.Errorf("duplicate desired item key %q", k)
+		}
+
+		seen[k] = true
+		if old, ok := existing[k]; !ok || !reflect.DeepEqual(old, item) {
+			plan.Puts = append(plan.Puts, item)
+		}
+
+		delete(existing, k)
+	}
+
+	for _, item := range current.Items {
+		if _, ok := existing[id(item)]; !ok {
+			continue
+		}
+
+		plan.Deletes = append(plan.Deletes, keyOf(item, keyAttrs))
+	}
+
+	if o.dryRun {
+		return plan, nil
+	}
+
+	puts, err := o.encodeAll(ctx, svc, plan.Puts)
+	if err != nil {
+		return plan, err
+	}
+
+	reqs := append(putRequests(puts), deleteRequests(plan.Deletes)...)
+	if err := batchWrite(ctx, svc, table, reqs, o); err != nil {
+		return plan, err
+	}
+
+	return plan, nil
+}
@@ -0,0 +1,337 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+var (
+	// ErrQuotaExceeded matches (with errors.Is) a *QuotaError.
+	ErrQuotaExceeded = errors.New("libdy: quota exceeded")
+)
+
+// QuotaError is returned (wrapped) when a table or index operation would
+// exceed an account or table capacity limit.
+type QuotaError struct {
+	Limit     string // e.g. "AccountMaxReadCapacityUnits"
+	Resource  string // table or index name
+	Requested int64
+	Used      int64
+	Max       int64
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%s: %s needs %d, %d in use, limit %d", e.Limit, e.Resource, e.Requested, e.Used, e.Max)
+}
+
+func (e *QuotaError) Is(target error) bool { return target == ErrQuotaExceeded }
+
+// capacity is the provisioned throughput of a table or index.
+type capacity struct {
+	name     string
+	rcu, wcu int64
+}
+
+func throughput(name string, pt *dynamodb.ProvisionedThroughput) capacity {
+	if pt == nil {
+		return capacity{name: name}
+	}
+
+	return capacity{name, aws.Int64Value(pt.ReadCapacityUnits), aws.Int64Value(pt.WriteCapacityUnits)}
+}
+
+// usedCapacity sums the provisioned throughput of all tables (and their
+// indexes) in the account and region. On-demand tables don't count.
+func usedCapacity(ctx context.Context, svc dynamodbiface.DynamoDBAPI) (rcu, wcu int64, err error) {
+	var names []*string
+	err = svc.ListTablesPagesWithContext(ctx, &dynamodb.ListTablesInput{}, func(page *dynamodb.ListTablesOutput, last bool) bool {
+		names = append(names, page.TableNames...)
+		return true
+	})
+
+	if err != nil {
+		return 0, 0, fmt.Errorf("ListTables failed: %w", awsErr(err))
+	}
+
+	for _, name := range names {
+		res, err := svc.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{TableName: name})
+		if err != nil {
+			return 0, 0, fmt.Errorf("DescribeTable failed: %w", awsErr(err))
+		}
+
+		t := res.Table
+		if t.BillingModeSummary != nil && aws.StringValue(t.BillingModeSummary.BillingMode) == dynamodb.BillingModePayPerRequest {
+			continue
+		}
+
+		if t.ProvisionedThroughput != nil {
+			rcu += aws.Int64Value(t.ProvisionedThroughput.ReadCapacityUnits)
+			wcu += aws.Int64Value(t.ProvisionedThroughput.WriteCapacityUnits)
+		}
+
+		for _, gsi := range t.GlobalSecondaryIndexes {
+			if gsi.ProvisionedThroughput != nil {
+				rcu += aws.Int64Value(gsi.ProvisionedThroughput.ReadCapacityUnits)
+				wcu += aws.Int64Value(gsi.ProvisionedThroughput.WriteCapacityUnits)
+			}
+		}
+	}
+
+	return rcu, wcu, nil
+}
+
+// checkQuota fails with a *QuotaError if adding caps would exceed the
+// per-table or account capacity limits from DescribeLimits.
+func checkQuota(ctx context.Context, svc dynamodbiface.DynamoDBAPI, caps []capacity) error {
+	var rcu, wcu int64
+	for _, c := range caps {
+		rcu += c.rcu
+		wcu += c.wcu
+	}
+
+	if rcu == 0 && wcu == 0 {
+		return nil
+	}
+
+	limits, err := svc.DescribeLimitsWithContext(ctx, &dynamodb.DescribeLimitsInput{})
+	if err != nil {
+		return fmt.Errorf("DescribeLimits failed: %w", awsErr(err))
+	}
+
+	for _, c := range caps {
+		if max := aws.Int64Value(limits.TableMaxReadCapacityUnits); max > 0 && c.rcu > max {
+			return &QuotaError{Limit: "TableMaxReadCapacityUnits", Resource: c.name, Requested: c.rcu, Max: max}
+		}
+
+		if max := aws.Int64Value(limits.TableMaxWriteCapacityUnits); max > 0 && c.wcu > max {
+			return &QuotaError{Limit: "TableMaxWriteCapacityUnits", Resource: c.name, Requested: c.wcu, Max: max}
+		}
+	}
+
+	usedR, usedW, err := usedCapacity(ctx, svc)
+	if err != nil {
+		return err
+	}
+
+	name := caps[0].name
+	if max := aws.Int64Value(limits.AccountMaxReadCapacityUnits); max > 0 && usedR+rcu > max {
+		return &QuotaError{Limit: "AccountMaxReadCapacityUnits", Resource: name, Requested: rcu, Used: usedR, Max: max}
+	}
+
+	if max := aws.Int64Value(limits.AccountMaxWriteCapacityUnits); max > 0 && usedW+wcu > max {
+		return &QuotaError{Limit: "AccountMaxWriteCapacityUnits", Resource: name, Requested: wcu, Used: usedW, Max: max}
+	}
+
+	return nil
+}
+
+// controlRetriable retries control plane calls rejected because too many
+// table operations are already in progress.
+func controlRetriable(err error) error {
+	if ErrorCode(err) == dynamodb.ErrCodeLimitExceededException {
+		return err // will cause retry with backoff
+	}
+
+	return nil // final err is rerr
+}
+
+func CreateTable(svc dynamodbiface.DynamoDBAPI, input *dynamodb.CreateTableInput) (*dynamodb.TableDescription, error) {
+	return CreateTableWithContext(context.Background(), svc, input)
+}
+
+// CreateTableWithContext creates a table. For provisioned tables, it first
+// checks the requested capacity (table plus indexes) against the account
+// limits and fails fast with ErrQuotaExceeded instead of partway through.
+// Calls rejected because too many table operations are in progress are
+// retried with backoff. It doesn't wait for the table to be ACTIVE; see
+// WaitForTableActiveWithContext, or EnsureTableWithContext.
+func CreateTableWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, input *dynamodb.CreateTableInput) (*dynamodb.TableDescription, error) {
+	if aws.StringValue(input.BillingMode) != dynamodb.BillingModePayPerRequest {
+		caps := []capacity{throughput(aws.StringValue(input.TableName), input.ProvisionedThroughput)}
+		for _, gsi := range input.GlobalSecondaryIndexes {
+			caps = append(caps, throughput(aws.StringValue(gsi.IndexName), gsi.ProvisionedThroughput))
+		}
+
+		if err := checkQuota(ctx, svc, caps); err != nil {
+			return nil, fmt.Errorf("CreateTable failed: %w", err)
+		}
+	}
+
+	start := time.Now()
+	var rerr, err error
+	var res *dynamodb.CreateTableOutput
+
+	// Our retriable function.
+	op := func(ctx context.Context) error {
+		res, err = svc.CreateTableWithContext(ctx, input)
+		rerr = err
+		return controlRetriable(err)
+	}
+
+	err = retry(ctx, options{}, "CreateTable", op)
+	if (err != nil || rerr != nil) && ctx.Err() != nil {
+		return nil, fmt.Errorf("CreateTable canceled after %v: %w", time.Since(start), ctx.Err())
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("CreateTable failed after %v: %w", time.Since(start), err)
+	}
+
+	if rerr != nil {
+		return nil, fmt.Errorf("CreateTable failed: %w", awsErr(rerr))
+	}
+
+	return res.TableDescription, nil
+}
+
+func CreateIndex(svc dynamodbiface.DynamoDBAPI, table string, gsi *dynamodb.CreateGlobalSecondaryIndexAction, attrs ...*dynamodb.AttributeDefinition) (*dynamodb.TableDescription, error) {
+	return CreateIndexWithContext(context.Background(), svc, table, gsi, attrs...)
+}
+
+// CreateIndexWithContext adds a global secondary index to table, with the
+// same quota guard as CreateTableWithContext. attrs defines the index key
+// attributes not already defined by the table.
+func CreateIndexWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, gsi *dynamodb.CreateGlobalSecondaryIndexAction, attrs ...*dynamodb.AttributeDefinition) (*dynamodb.TableDescription, error) {
+	if err := checkQuota(ctx, svc, []capacity{throughput(aws.StringValue(gsi.IndexName), gsi.ProvisionedThroughput)}); err != nil {
+		return nil, fmt.Errorf("CreateIndex failed: %w", err)
+	}
+
+	return updateTable(ctx, svc, "CreateIndex", &dynamodb.UpdateTableInput{
+		TableName:                   aws.String(table),
+		AttributeDefinitions:        attrs,
+		GlobalSecondaryIndexUpdates: []*dynamodb.GlobalSecondaryIndexUpdate{{Create: gsi}},
+	})
+}
+
+// updateTable runs UpdateTable, retrying while too many table operations are
+// in progress. name is the operation name for errors.
+func updateTable(ctx context.Context, svc dynamodbiface.DynamoDBAPI, name string, input *dynamodb.UpdateTableInput) (*dynamodb.TableDescription, error) {
+	start := time.Now()
+	var rerr, err error
+	var res *dynamodb.UpdateTableOutput
+
+	// Our retriable function.
+	op := func(ctx context.Context) error {
+		res, err = svc.UpdateTableWithContext(ctx, input)
+		rerr = err
+		return controlRetriable(err)
+	}
+
+	err = retry(ctx, options{}, name, op)
+	if (err != nil || rerr != nil) && ctx.Err() != nil {
+		return nil, fmt.Errorf("%s canceled after %v: %w", name, time.Since(start), ctx.Err())
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s failed after %v: %w", name, time.Since(start), err)
+	}
+
+	if rerr != nil {
+		return nil, fmt.Errorf("%s failed: %w", name, rerr)
+	}
+
+	return res.TableDescription, nil
+}
+
+var (
+	ErrDeletionProtected = errors.New("libdy: table has deletion protection enabled")
+)
+
+// WithForceDelete lets DeleteTable turn off deletion protection first.
+func WithForceDelete() Option {
+	return func(o *options) { o.forceDelete = true }
+}
+
+func EnableDeletionProtection(svc dynamodbiface.DynamoDBAPI, table string) error {
+	return EnableDeletionProtectionWithContext(context.Background(), svc, table)
+}
+
+// EnableDeletionProtectionWithContext protects table from deletion.
+func EnableDeletionProtectionWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string) error {
+	return setDeletionProtection(ctx, svc, table, true)
+}
+
+func DisableDeletionProtection(svc dynamodbiface.DynamoDBAPI, table string) error {
+	return DisableDeletionProtectionWithContext(context.Background(), svc, table)
+}
+
+// DisableDeletionProtectionWithContext allows table to be deleted again.
+func DisableDeletionProtectionWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string) error {
+	return setDeletionProtection(ctx, svc, table, false)
+}
+
+func setDeletionProtection(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, on bool) error {
+	_, err := updateTable(ctx, svc, "SetDeletionProtection", &dynamodb.UpdateTableInput{
+		TableName:                 aws.String(table),
+		DeletionProtectionEnabled: aws.Bool(on),
+	})
+
+	return err
+}
+
+func DeleteTable(svc dynamodbiface.DynamoDBAPI, table string, opts ...Option) error {
+	return DeleteTableWithContext(context.Background(), svc, table, opts...)
+}
+
+// DeleteTableWithContext deletes table. Tables with deletion protection are
+// refused with ErrDeletionProtected, unless WithForceDelete is given, in
+// which case the protection is turned off first. It doesn't wait for the
+// table to be gone; see WaitForTableDeletedWithContext.
+func DeleteTableWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, opts ...Option) error {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	res, err := svc.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+	if err != nil {
+		return fmt.Errorf("DescribeTable failed: %w", awsErr(err))
+	}
+
+	if aws.BoolValue(res.Table.DeletionProtectionEnabled) {
+		if !o.forceDelete {
+			return fmt.Errorf("DeleteTable %s refused: %w", table, ErrDeletionProtected)
+		}
+
+		if err := setDeletionProtection(ctx, svc, table, false); err != nil {
+			return err
+		}
+
+		// The table can't be deleted while UPDATING.
+		err = svc.WaitUntilTableExistsWithContext(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+		if err != nil {
+			return fmt.Errorf("WaitUntilTableExists failed: %w", awsErr(err))
+		}
+	}
+
+	start := time.Now()
+	var rerr error
+
+	// Our retriable function.
+	op := func(ctx context.Context) error {
+		_, err := svc.DeleteTableWithContext(ctx, &dynamodb.DeleteTableInput{TableName: aws.String(table)})
+		rerr = err
+		return controlRetriable(err)
+	}
+
+	err = retry(ctx, o, "DeleteTable", op)
+	if (err != nil || rerr != nil) && ctx.Err() != nil {
+		return fmt.Errorf("DeleteTable canceled after %v: %w", time.Since(start), ctx.Err())
+	}
+
+	if err != nil {
+		return fmt.Errorf("DeleteTable failed after %v: %w", time.Since(start), err)
+	}
+
+	if rerr != nil {
+		return fmt.Errorf("DeleteTable failed: %w", awsErr(rerr))
+	}
+
+	return nil
+}
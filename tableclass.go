@@ -0,0 +1,164 @@
+package libdy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// TableClassPricing holds the prices used to compare table classes. Only the
+// ratios matter for the recommendation.
+type TableClassPricing struct {
+	StandardGBMonth    float64 // storage, per GB-month
+	StandardReadUnits  float64 // per million read request units
+	StandardWriteUnits float64 // per million write request units
+	IAGBMonth          float64
+	IAReadUnits        float64
+	IAWriteUnits       float64
+}
+
+// DefaultTableClassPricing is on-demand pricing in us-east-1.
+var DefaultTableClassPricing = TableClassPricing{
+	StandardGBMonth:    0.25,
+	StandardReadUnits:  0.25,
+	StandardWriteUnits: 1.25,
+	IAGBMonth:          0.10,
+	IAReadUnits:        0.31,
+	IAWriteUnits:       1.56,
+}
+
+// TableClassReport compares the estimated monthly cost of a table under
+// both table classes, based on its current size and observed traffic.
+type TableClassReport struct {
+	Table        string
+	Class        string  // current class
+	SizeBytes    int64   // table plus global secondary indexes
+	ReadUnits    float64 // consumed per month, extrapolated from the window
+	WriteUnits   float64 // likewise
+	StandardCost float64
+	IACost       float64
+	Recommended  string
+}
+
+func SetTableClass(svc dynamodbiface.DynamoDBAPI, table, class string) error {
+	return SetTableClassWithContext(context.Background(), svc, table, class)
+}
+
+// SetTableClassWithContext switches table to class, one of
+// dynamodb.TableClassStandard or dynamodb.TableClassStandardInfrequentAccess.
+func SetTableClassWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, class string) error {
+	_, err := updateTable(ctx, svc, "SetTableClass", &dynamodb.UpdateTableInput{
+		TableName:  aws.String(table),
+		TableClass: aws.String(class),
+	})
+
+	return err
+}
+
+func TableClass(svc dynamodbiface.DynamoDBAPI, table string) (string, error) {
+	return TableClassWithContext(context.Background(), svc, table)
+}
+
+// TableClassWithContext returns the class of table.
+func TableClassWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string) (string, error) {
+	res, err := svc.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+	if err != nil {
+		return "", fmt.Errorf("DescribeTable failed: %w", awsErr(err))
+	}
+
+	return tableClass(res.Table), nil
+}
+
+func tableClass(t *dynamodb.TableDescription) string {
+	if t.TableClassSummary == nil || t.TableClassSummary.TableClass == nil {
+		return dynamodb.TableClassStandard // never changed
+	}
+
+	return aws.StringValue(t.TableClassSummary.TableClass)
+}
+
+func RecommendTableClass(svc dynamodbiface.DynamoDBAPI, cw cloudwatchiface.CloudWatchAPI, table string, window time.Duration) (*TableClassReport, error) {
+	return RecommendTableClassWithContext(context.Background(), svc, cw, table, window)
+}
+
+// RecommendTableClassWithContext reports which table class would be cheaper
+// for table, using its size from DescribeTable and its consumed capacity
+// over the last window (at least a day) from CloudWatch, priced with
+// DefaultTableClassPricing. Standard-IA pays off when storage dominates the
+// bill. Index traffic isn't included, so treat close calls with care.
+func RecommendTableClassWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, cw cloudwatchiface.CloudWatchAPI, table string, window time.Duration) (*TableClassReport, error) {
+	if window < 24*time.Hour {
+		window = 24 * time.Hour
+	}
+
+	res, err := svc.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+	if err != nil {
+		return nil, fmt.Errorf("DescribeTable failed: %w", awsErr(err))
+	}
+
+	size := aws.Int64Value(res.Table.TableSizeBytes)
+	for _, gsi := range res.Table.GlobalSecondaryIndexes {
+		size += aws.Int64Value(gsi.IndexSizeBytes)
+	}
+
+	reads, err := consumedSum(ctx, cw, table, "ConsumedReadCapacityUnits", window)
+	if err != nil {
+		return nil, err
+	}
+
+	writes, err := consumedSum(ctx, cw, table, "ConsumedWriteCapacityUnits", window)
+	if err != nil {
+		return nil, err
+	}
+
+	month := float64(30*24*time.Hour) / float64(window)
+	p := DefaultTableClassPricing
+	r := &TableClassReport{
+		Table:      table,
+		Class:      tableClass(res.Table),
+		SizeBytes:  size,
+		ReadUnits:  reads * month,
+		WriteUnits: writes * month,
+	}
+
+	gb := float64(size) / (1 << 30)
+	r.StandardCost = gb*p.StandardGBMonth + r.ReadUnits/1e6*p.StandardReadUnits + r.WriteUnits/1e6*p.StandardWriteUnits
+	r.IACost = gb*p.IAGBMonth + r.ReadUnits/1e6*p.IAReadUnits + r.WriteUnits/1e6*p.IAWriteUnits
+	r.Recommended = dynamodb.TableClassStandard
+	if r.IACost < r.StandardCost {
+		r.Recommended = dynamodb.TableClassStandardInfrequentAccess
+	}
+
+	return r, nil
+}
+
+// consumedSum returns the total of a table's capacity metric over window.
+func consumedSum(ctx context.Context, cw cloudwatchiface.CloudWatchAPI, table, metric string, window time.Duration) (float64, error) {
+	end := time.Now()
+	res, err := cw.GetMetricStatisticsWithContext(ctx, &cloudwatch.GetMetricStatisticsInput{
+		Namespace:  aws.String("AWS/DynamoDB"),
+		MetricName: aws.String(metric),
+		Dimensions: []*cloudwatch.Dimension{{Name: aws.String("TableName"), Value: aws.String(table)}},
+		StartTime:  aws.Time(end.Add(-window)),
+		EndTime:    aws.Time(end),
+		Period:     aws.Int64(int64((24 * time.Hour).Seconds())),
+		Statistics: []*string{aws.String(cloudwatch.StatisticSum)},
+	})
+
+	if err != nil {
+		return 0, fmt.Errorf("GetMetricStatistics failed: %w", err)
+	}
+
+	var sum float64
+	for _, dp := range res.Datapoints {
+		sum += aws.Float64Value(dp.Sum)
+	}
+
+	return sum, nil
+}
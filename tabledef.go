@@ -0,0 +1,232 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// TableDef is a small table definition for EnsureTable. Key attributes are
+// given as "name" for strings, or "name:N" and "name:B" for numbers and
+// binary.
+//
+//	libdy.TableDef{
+//		Name: "orders",
+//		PK:   "id",
+//		SK:   "created:N",
+//		Indexes: []libdy.IndexDef{
+//			{Name: "by-customer", PK: "customer", SK: "created:N"},
+//		},
+//	}
+type TableDef struct {
+	Name    string
+	PK, SK  string // SK is optional
+	Indexes []IndexDef
+
+	// BillingMode is dynamodb.BillingModePayPerRequest (the default) or
+	// dynamodb.BillingModeProvisioned, with RCU and WCU for the table.
+	BillingMode string
+	RCU, WCU    int64
+}
+
+// IndexDef is a global secondary index of a TableDef, projecting all
+// attributes. RCU and WCU are for provisioned tables only.
+type IndexDef struct {
+	Name     string
+	PK, SK   string // SK is optional
+	RCU, WCU int64
+}
+
+// keyDef parses the "name" or "name:TYPE" form of a key attribute.
+func keyDef(s string) (string, string) {
+	name, typ, ok := strings.Cut(s, ":")
+	if !ok {
+		typ = dynamodb.ScalarAttributeTypeS
+	}
+
+	return name, typ
+}
+
+// keySchema returns the key schema for pk and sk, adding their attribute
+// definitions to attrs.
+func keySchema(pk, sk string, attrs map[string]string) []*dynamodb.KeySchemaElement {
+	name, typ := keyDef(pk)
+	attrs[name] = typ
+	ks := []*dynamodb.KeySchemaElement{{AttributeName: aws.String(name), KeyType: aws.String(dynamodb.KeyTypeHash)}}
+	if sk != "" {
+		name, typ := keyDef(sk)
+		attrs[name] = typ
+		ks = append(ks, &dynamodb.KeySchemaElement{AttributeName: aws.String(name), KeyType: aws.String(dynamodb.KeyTypeRange)})
+	}
+
+	return ks
+}
+
+// input returns the CreateTableInput for d.
+func (d TableDef) input() (*dynamodb.CreateTableInput, error) {
+	if d.Name == "" || d.PK == "" {
+		return nil, fmt.Errorf("invalid table definition: no name or PK")
+	}
+
+	mode := d.BillingMode
+	if mode == "" {
+		mode = dynamodb.BillingModePayPerRequest
+	}
+
+	provisioned := mode == dynamodb.BillingModeProvisioned
+	throughput := func(rcu, wcu int64) *dynamodb.ProvisionedThroughput {
+		if !provisioned {
+			return nil
+		}
+
+		return &dynamodb.ProvisionedThroughput{ReadCapacityUnits: aws.Int64(rcu), WriteCapacityUnits: aws.Int64(wcu)}
+	}
+
+	attrs := map[string]string{}
+	input := &dynamodb.CreateTableInput{
+		TableName:             aws.String(d.Name),
+		BillingMode:           aws.String(mode),
+		KeySchema:             keySchema(d.PK, d.SK, attrs),
+		ProvisionedThroughput: throughput(d.RCU, d.WCU),
+	}
+
+	for _, x := range d.Indexes {
+		if x.Name == "" || x.PK == "" {
+			return nil, fmt.Errorf("invalid table definition: index without name or PK")
+		}
+
+		input.GlobalSecondaryIndexes = append(input.GlobalSecondaryIndexes, &dynamodb.GlobalSecondaryIndex{
+			IndexName:             aws.String(x.Name),
+			KeySchema:             keySchema(x.PK, x.SK, attrs),
+			Projection:            &dynamodb.Projection{ProjectionType: aws.String(dynamodb.ProjectionTypeAll)},
+			ProvisionedThroughput: throughput(x.RCU, x.WCU),
+		})
+	}
+
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		input.AttributeDefinitions = append(input.AttributeDefinitions, &dynamodb.AttributeDefinition{
+			AttributeName: aws.String(name),
+			AttributeType: aws.String(attrs[name]),
+		})
+	}
+
+	return input, nil
+}
+
+func DescribeTable(svc dynamodbiface.DynamoDBAPI, table string) (*dynamodb.TableDescription, error) {
+	return DescribeTableWithContext(context.Background(), svc, table)
+}
+
+// DescribeTableWithContext returns the description of table. A missing
+// table fails with ErrTableNotFound.
+func DescribeTableWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string) (*dynamodb.TableDescription, error) {
+	res, err := svc.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+	if err != nil {
+		return nil, fmt.Errorf("DescribeTable failed: %w", awsErr(err))
+	}
+
+	return res.Table, nil
+}
+
+func WaitForTableActive(svc dynamodbiface.DynamoDBAPI, table string) error {
+	return WaitForTableActiveWithContext(context.Background(), svc, table)
+}
+
+// WaitForTableActiveWithContext waits until table exists and is ACTIVE, with
+// the SDK's TableExists waiter, or until ctx is done.
+func WaitForTableActiveWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string) error {
+	err := svc.WaitUntilTableExistsWithContext(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+	if err != nil {
+		return fmt.Errorf("WaitForTableActive failed: %w", awsErr(err))
+	}
+
+	return nil
+}
+
+func WaitForTableDeleted(svc dynamodbiface.DynamoDBAPI, table string) error {
+	return WaitForTableDeletedWithContext(context.Background(), svc, table)
+}
+
+// WaitForTableDeletedWithContext waits until table no longer exists, with
+// the SDK's TableNotExists waiter, or until ctx is done.
+func WaitForTableDeletedWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string) error {
+	err := svc.WaitUntilTableNotExistsWithContext(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+	if err != nil {
+		return fmt.Errorf("WaitForTableDeleted failed: %w", awsErr(err))
+	}
+
+	return nil
+}
+
+func EnsureTable(svc dynamodbiface.DynamoDBAPI, def TableDef) (*dynamodb.TableDescription, error) {
+	return EnsureTableWithContext(context.Background(), svc, def)
+}
+
+// EnsureTableWithContext creates the table of def (see CreateTableWithContext)
+// unless it exists, then waits until it's ACTIVE. An existing table must have
+// the key schema of def, or EnsureTable fails; its indexes and billing mode
+// are left as they are.
+func EnsureTableWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, def TableDef) (*dynamodb.TableDescription, error) {
+	input, err := def.input()
+	if err != nil {
+		return nil, fmt.Errorf("EnsureTable failed: %w", err)
+	}
+
+	t, err := DescribeTableWithContext(ctx, svc, def.Name)
+	switch {
+	case errors.Is(err, ErrTableNotFound):
+		if _, err := CreateTableWithContext(ctx, svc, input); err != nil {
+			// Lost a race with another creator?
+			if ErrorCode(err) != dynamodb.ErrCodeResourceInUseException {
+				return nil, fmt.Errorf("EnsureTable failed: %w", err)
+			}
+		}
+	case err != nil:
+		return nil, fmt.Errorf("EnsureTable failed: %w", err)
+	default:
+		if !sameKeySchema(t.KeySchema, input.KeySchema) {
+			return nil, fmt.Errorf("EnsureTable failed: table %s exists with another key schema", def.Name)
+		}
+
+		if aws.StringValue(t.TableStatus) == dynamodb.TableStatusActive {
+			return t, nil
+		}
+	}
+
+	if err := WaitForTableActiveWithContext(ctx, svc, def.Name); err != nil {
+		return nil, fmt.Errorf("EnsureTable failed: %w", err)
+	}
+
+	if t, err = DescribeTableWithContext(ctx, svc, def.Name); err != nil {
+		return nil, fmt.Errorf("EnsureTable failed: %w", err)
+	}
+
+	return t, nil
+}
+
+func sameKeySchema(a, b []*dynamodb.KeySchemaElement) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if aws.StringValue(a[i].AttributeName) != aws.StringValue(b[i].AttributeName) ||
+			aws.StringValue(a[i].KeyType) != aws.StringValue(b[i].KeyType) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,58 @@
+package libdy
+
+import "sync"
+
+// TableDefaults is a registry of per-table default options, for a Client
+// used with several tables (see WithTableDefaults), so each call site needs
+// only the table. Register them at startup:
+//
+//	defaults := libdy.NewTableDefaults()
+//	defaults.Register("orders",
+//		libdy.WithKeySchema(libdy.KeyAttr{Name: "id", Type: "S"}, libdy.KeyAttr{}),
+//		libdy.WithLimit(100),
+//		libdy.WithRetryPolicy(libdy.RetryPolicy{MaxRetries: 8}),
+//		libdy.WithWriteCapacity(libdy.NewCapacityLimiter(500)))
+//
+//	defaults.Register("events",
+//		libdy.WithIndex("by-time"),
+//		libdy.WithReadCapacity(libdy.NewCapacityLimiter(200)))
+//
+//	client := libdy.New(svc, libdy.WithTableDefaults(defaults))
+//	res, err := client.Query(ctx, "o1", "", libdy.WithTable("orders"))
+//
+// The options of a table apply over those of the Client, and under those
+// of the call. Tables are named as in WithTable, before any alias (see
+// Aliases) is resolved. A WithTable among the defaults is ignored.
+type TableDefaults struct {
+	mu   sync.RWMutex
+	opts map[string][]Option
+}
+
+func NewTableDefaults() *TableDefaults {
+	return &TableDefaults{opts: map[string][]Option{}}
+}
+
+// Register sets the default options of table, replacing any previous ones.
+func (r *TableDefaults) Register(table string, opts ...Option) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.opts[table] = append(opts[:len(opts):len(opts)], WithTable(table))
+}
+
+// get returns the options of table, or nil if it has none. A nil
+// TableDefaults has none.
+func (r *TableDefaults) get(table string) []Option {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.opts[table]
+}
+
+// WithTableDefaults applies the default options of each table registered
+// in r to the calls on it.
+func WithTableDefaults(r *TableDefaults) Option {
+	return func(o *options) { o.defaults = r }
+}
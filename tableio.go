@@ -0,0 +1,374 @@
+package libdy
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// ExportFormat is the file format of ExportTable and ImportTable.
+type ExportFormat string
+
+const (
+	// FormatJSONL is JSON lines of items in DynamoDB JSON, the typed form
+	// of the DynamoDB API, e.g. {"id":{"S":"a"},"n":{"N":"1"}}.
+	FormatJSONL ExportFormat = "jsonl"
+
+	// FormatCSV is CSV with a header row naming the attributes, as read by
+	// CSVSource. Attributes that are always strings, numbers, or booleans
+	// are written as is, with their type in the header, as in "age:N";
+	// others are written in DynamoDB JSON, with the type "JSON".
+	FormatCSV ExportFormat = "csv"
+)
+
+func ExportTable(svc dynamodbiface.DynamoDBAPI, table string, w io.Writer, format ExportFormat, opts ...Option) (int64, error) {
+	return ExportTableWithContext(context.Background(), svc, table, w, format, opts...)
+}
+
+// ExportTableWithContext is Client.ExportTable for table.
+func ExportTableWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, w io.Writer, format ExportFormat, opts ...Option) (int64, error) {
+	return New(svc, WithTable(table)).ExportTable(ctx, w, format, opts...)
+}
+
+// ExportTable writes all the items of the table to w in format, keeping
+// their DynamoDB types, for backups, test fixtures, and moving data outside
+// AWS; see ImportTable for the way back. opts apply to the scan, e.g.
+// WithFilter or WithReadCapacity, and WithMask masks the items written. It
+// returns the number of items written. FormatCSV needs all the attribute
+// names first, so the items are spooled to a temporary file.
+func (c *Client) ExportTable(ctx context.Context, w io.Writer, format ExportFormat, opts ...Option) (int64, error) {
+	switch format {
+	case FormatJSONL:
+		bw := bufio.NewWriter(w)
+		n, err := c.exportJSONL(ctx, bw, nil, opts)
+		if err != nil {
+			return n, err
+		}
+
+		if err := bw.Flush(); err != nil {
+			return n, fmt.Errorf("ExportTable failed: %w", err)
+		}
+
+		return n, nil
+	case FormatCSV:
+		return c.exportCSV(ctx, w, opts)
+	}
+
+	return 0, fmt.Errorf("ExportTable failed: unknown format %q", format)
+}
+
+// exportJSONL writes the items to w as DynamoDB JSON lines, calling seen,
+// if set, for each item.
+func (c *Client) exportJSONL(ctx context.Context, w io.Writer, seen func(map[string]*dynamodb.AttributeValue), opts []Option) (int64, error) {
+	o, err := c.apply(opts)
+	if err != nil {
+		return 0, err
+	}
+
+	pages := c.ScanPages(opts...)
+	defer pages.Close()
+	var n int64
+	for pages.Next(ctx) {
+		for _, item := range pages.Page() {
+			item = o.mask.Mask(item)
+			b, err := json.Marshal(typedJSON(&dynamodb.AttributeValue{M: item})["M"])
+			if err != nil {
+				return n, fmt.Errorf("ExportTable failed: %w", err)
+			}
+
+			if _, err := w.Write(append(b, '\n')); err != nil {
+				return n, fmt.Errorf("ExportTable failed: %w", err)
+			}
+
+			if seen != nil {
+				seen(item)
+			}
+
+			n++
+		}
+	}
+
+	if err := pages.Err(); err != nil {
+		return n, fmt.Errorf("ExportTable failed: %w", err)
+	}
+
+	return n, nil
+}
+
+func (c *Client) exportCSV(ctx context.Context, w io.Writer, opts []Option) (int64, error) {
+	spool, err := os.CreateTemp("", "libdy-export-*.jsonl")
+	if err != nil {
+		return 0, fmt.Errorf("ExportTable failed: %w", err)
+	}
+
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	// The column type of each attribute: S, N, or BOOL while all its values
+	// are, JSON otherwise.
+	types := map[string]string{}
+	bw := bufio.NewWriter(spool)
+	n, err := c.exportJSONL(ctx, bw, func(item map[string]*dynamodb.AttributeValue) {
+		for k, v := range item {
+			typ := "JSON"
+			switch {
+			case v.S != nil && *v.S != "": // "" would be read as no value
+				typ = "S"
+			case v.N != nil:
+				typ = "N"
+			case v.BOOL != nil:
+				typ = "BOOL"
+			}
+
+			if t, ok := types[k]; ok && t != typ {
+				typ = "JSON"
+			}
+
+			types[k] = typ
+		}
+	}, opts)
+
+	if err != nil {
+		return n, err
+	}
+
+	if err := bw.Flush(); err != nil {
+		return 0, fmt.Errorf("ExportTable failed: %w", err)
+	}
+
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("ExportTable failed: %w", err)
+	}
+
+	names := make([]string, 0, len(types))
+	for k := range types {
+		names = append(names, k)
+	}
+
+	sort.Strings(names)
+	header := make([]string, len(names))
+	for i, name := range names {
+		header[i] = name
+		if types[name] != "S" {
+			header[i] += ":" + types[name]
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return 0, fmt.Errorf("ExportTable failed: %w", err)
+	}
+
+	next := typedJSONItems(spool)
+	row := make([]string, len(names))
+	for i := int64(0); i < n; i++ {
+		item, err := next()
+		if err != nil {
+			return i, fmt.Errorf("ExportTable failed: spool: %w", err)
+		}
+
+		for j, name := range names {
+			row[j] = ""
+			v, ok := item[name]
+			if !ok {
+				continue
+			}
+
+			switch types[name] {
+			case "S":
+				row[j] = aws.StringValue(v.S)
+			case "N":
+				row[j] = aws.StringValue(v.N)
+			case "BOOL":
+				row[j] = strconv.FormatBool(aws.BoolValue(v.BOOL))
+			default:
+				b, err := json.Marshal(typedJSON(v))
+				if err != nil {
+					return i, fmt.Errorf("ExportTable failed: %w", err)
+				}
+
+				row[j] = string(b)
+			}
+		}
+
+		if err := cw.Write(row); err != nil {
+			return i, fmt.Errorf("ExportTable failed: %w", err)
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return n, fmt.Errorf("ExportTable failed: %w", err)
+	}
+
+	return n, nil
+}
+
+func ImportTable(svc dynamodbiface.DynamoDBAPI, table string, r io.Reader, format ExportFormat, opts ...Option) (int64, error) {
+	return ImportTableWithContext(context.Background(), svc, table, r, format, opts...)
+}
+
+// ImportTableWithContext is Client.ImportTable for table.
+func ImportTableWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, r io.Reader, format ExportFormat, opts ...Option) (int64, error) {
+	return New(svc, WithTable(table)).ImportTable(ctx, r, format, opts...)
+}
+
+// ImportTable writes the items read from r in format, as written by
+// ExportTable, to the table with ImportItems, and returns their number.
+func (c *Client) ImportTable(ctx context.Context, r io.Reader, format ExportFormat, opts ...Option) (int64, error) {
+	var next itemFunc
+	switch format {
+	case FormatJSONL:
+		next = typedJSONItems(r)
+	case FormatCSV:
+		next = csvItems(r)
+	default:
+		return 0, fmt.Errorf("ImportTable failed: unknown format %q", format)
+	}
+
+	return c.ImportItems(ctx, SourceFunc(func(ctx context.Context) ([]map[string]*dynamodb.AttributeValue, error) {
+		return readPage(ctx, next)
+	}), opts...)
+}
+
+func typedJSONItems(r io.Reader) itemFunc {
+	dec := json.NewDecoder(r)
+	n := 0
+	return func() (map[string]*dynamodb.AttributeValue, error) {
+		var m map[string]json.RawMessage
+		if err := dec.Decode(&m); err != nil {
+			if err == io.EOF {
+				return nil, err
+			}
+
+			return nil, fmt.Errorf("invalid item %d: %w", n+1, err)
+		}
+
+		n++
+		item := make(map[string]*dynamodb.AttributeValue, len(m))
+		for k, raw := range m {
+			v, err := parseTypedJSON(raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid item %d: %s: %w", n, k, err)
+			}
+
+			item[k] = v
+		}
+
+		return item, nil
+	}
+}
+
+// typedJSON returns v in DynamoDB JSON, for json.Marshal. Binary values are
+// base64, as in the DynamoDB API.
+func typedJSON(v *dynamodb.AttributeValue) map[string]interface{} {
+	switch {
+	case v.S != nil:
+		return map[string]interface{}{"S": *v.S}
+	case v.N != nil:
+		return map[string]interface{}{"N": *v.N}
+	case v.B != nil:
+		return map[string]interface{}{"B": v.B}
+	case v.BOOL != nil:
+		return map[string]interface{}{"BOOL": *v.BOOL}
+	case v.SS != nil:
+		return map[string]interface{}{"SS": aws.StringValueSlice(v.SS)}
+	case v.NS != nil:
+		return map[string]interface{}{"NS": aws.StringValueSlice(v.NS)}
+	case v.BS != nil:
+		return map[string]interface{}{"BS": v.BS}
+	case v.M != nil:
+		m := make(map[string]interface{}, len(v.M))
+		for k, e := range v.M {
+			m[k] = typedJSON(e)
+		}
+
+		return map[string]interface{}{"M": m}
+	case v.L != nil:
+		l := make([]interface{}, len(v.L))
+		for i, e := range v.L {
+			l[i] = typedJSON(e)
+		}
+
+		return map[string]interface{}{"L": l}
+	}
+
+	return map[string]interface{}{"NULL": true}
+}
+
+// parseTypedJSON is the inverse of typedJSON.
+func parseTypedJSON(raw json.RawMessage) (*dynamodb.AttributeValue, error) {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, err
+	}
+
+	if len(m) != 1 {
+		return nil, fmt.Errorf("not a typed value: %s", raw)
+	}
+
+	v := &dynamodb.AttributeValue{}
+	for typ, data := range m {
+		var err error
+		switch typ {
+		case "S":
+			err = json.Unmarshal(data, &v.S)
+		case "N":
+			err = json.Unmarshal(data, &v.N)
+		case "B":
+			err = json.Unmarshal(data, &v.B)
+		case "BOOL":
+			err = json.Unmarshal(data, &v.BOOL)
+		case "NULL":
+			err = json.Unmarshal(data, &v.NULL)
+		case "SS":
+			err = json.Unmarshal(data, &v.SS)
+		case "NS":
+			err = json.Unmarshal(data, &v.NS)
+		case "BS":
+			err = json.Unmarshal(data, &v.BS)
+		case "M":
+			var mm map[string]json.RawMessage
+			if err = json.Unmarshal(data, &mm); err != nil {
+				break
+			}
+
+			v.M = make(map[string]*dynamodb.AttributeValue, len(mm))
+			for k, e := range mm {
+				if v.M[k], err = parseTypedJSON(e); err != nil {
+					break
+				}
+			}
+		case "L":
+			var l []json.RawMessage
+			if err = json.Unmarshal(data, &l); err != nil {
+				break
+			}
+
+			v.L = make([]*dynamodb.AttributeValue, len(l))
+			for i, e := range l {
+				if v.L[i], err = parseTypedJSON(e); err != nil {
+					break
+				}
+			}
+		default:
+			return nil, fmt.Errorf("unknown type %q", typ)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value: %w", typ, err)
+		}
+	}
+
+	return v, nil
+}
@@ -0,0 +1,472 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// Cancellation reason codes, see TxReason.
+const (
+	TxReasonNone                   = "None"
+	TxReasonConditionalCheckFailed = "ConditionalCheckFailed"
+	TxReasonTransactionConflict    = "TransactionConflict"
+	TxReasonThrottling             = "ThrottlingError"
+	TxReasonProvisionedThroughput  = "ProvisionedThroughputExceeded"
+)
+
+// TxOp is one operation of a write transaction. Use TxPut, TxUpdate,
+// TxDelete, and TxConditionCheck to build them.
+type TxOp struct {
+	item   *dynamodb.TransactWriteItem
+	err    error
+	pk, sk string // the key strings, resolved by TransactWriteItems
+}
+
+// TxPut puts item, optionally only if c holds. Like PutItem, the write
+// encodes item per the options of table (see WithCompression,
+// WithEncryption, and WithOffload).
+func TxPut(table string, item map[string]*dynamodb.AttributeValue, c ...Condition) TxOp {
+	put := &dynamodb.Put{TableName: aws.String(table), Item: item}
+	if len(c) > 0 {
+		put.ConditionExpression, put.ExpressionAttributeNames, put.ExpressionAttributeValues = options{condition: &c[0]}.conditionInput()
+	}
+
+	return TxOp{item: &dynamodb.TransactWriteItem{Put: put}}
+}
+
+// TxUpdate applies u to the item with the given key, optionally only if c
+// holds. pk and sk are as in UpdateItem (see ParseKey), typed per the key
+// schema of table when the transaction is written.
+func TxUpdate(table, pk, sk string, u *Update, c ...Condition) TxOp {
+	expr, names, values, err := u.expression()
+	if err != nil {
+		return TxOp{err: err}
+	}
+
+	update := &dynamodb.Update{
+		TableName:                 aws.String(table),
+		UpdateExpression:          aws.String(expr),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	}
+
+	if len(c) > 0 {
+		var cnames map[string]*string
+		var cvalues map[string]*dynamodb.AttributeValue
+		update.ConditionExpression, cnames, cvalues = options{condition: &c[0]}.conditionInput()
+		for k, v := range cnames {
+			update.ExpressionAttributeNames[k] = v
+		}
+
+		if len(cvalues) > 0 && update.ExpressionAttributeValues == nil {
+			update.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{}
+		}
+
+		for k, v := range cvalues {
+			update.ExpressionAttributeValues[k] = v
+		}
+	}
+
+	return TxOp{item: &dynamodb.TransactWriteItem{Update: update}, pk: pk, sk: sk}
+}
+
+// TxDelete deletes the item with the given key (see TxUpdate), optionally
+// only if c holds.
+func TxDelete(table, pk, sk string, c ...Condition) TxOp {
+	del := &dynamodb.Delete{TableName: aws.String(table)}
+	if len(c) > 0 {
+		del.ConditionExpression, del.ExpressionAttributeNames, del.ExpressionAttributeValues = options{condition: &c[0]}.conditionInput()
+	}
+
+	return TxOp{item: &dynamodb.TransactWriteItem{Delete: del}, pk: pk, sk: sk}
+}
+
+// TxConditionCheck fails the transaction unless c holds for the item with
+// the given key (see TxUpdate), without writing it.
+func TxConditionCheck(table, pk, sk string, c Condition) TxOp {
+	check := &dynamodb.ConditionCheck{TableName: aws.String(table)}
+	check.ConditionExpression, check.ExpressionAttributeNames, check.ExpressionAttributeValues = options{condition: &c}.conditionInput()
+	return TxOp{item: &dynamodb.TransactWriteItem{ConditionCheck: check}, pk: pk, sk: sk}
+}
+
+// TxGet reads the item with the given key, optionally only the attrs. As in
+// GetItemsByKey, pass the zero Key as sk if the table has no sort key.
+func TxGet(table string, pk, sk Key, attrs ...string) *dynamodb.TransactGetItem {
+	get := &dynamodb.Get{TableName: aws.String(table), Key: keyMap(pk, sk)}
+	get.ProjectionExpression, get.ExpressionAttributeNames = projection(attrs)
+	return &dynamodb.TransactGetItem{Get: get}
+}
+
+// TxReason is why one operation caused its transaction to be canceled.
+type TxReason struct {
+	Index   int // of the operation in the transaction
+	Code    string
+	Message string
+	Item    map[string]*dynamodb.AttributeValue // with ReturnValuesOnConditionCheckFailure
+}
+
+func (r *TxReason) Error() string {
+	return fmt.Sprintf("operation %d: %s: %s", r.Index, r.Code, r.Message)
+}
+
+// TxCanceledError is returned (wrapped) when DynamoDB cancels a transaction.
+// It unwraps to the reasons, so errors.As(err, &reason) finds the first one.
+type TxCanceledError struct {
+	Reasons []*TxReason // only the operations that caused the cancellation
+	Err     error       // the TransactionCanceledException
+}
+
+func (e *TxCanceledError) Error() string {
+	s := make([]string, len(e.Reasons))
+	for i, r := range e.Reasons {
+		s[i] = r.Error()
+	}
+
+	return "transaction canceled: " + strings.Join(s, "; ")
+}
+
+func (e *TxCanceledError) Unwrap() []error {
+	ret := []error{e.Err}
+	for _, r := range e.Reasons {
+		ret = append(ret, r)
+	}
+
+	return ret
+}
+
+// Is makes errors.Is(err, ErrTransactionCanceled) hold.
+func (e *TxCanceledError) Is(target error) bool { return target == ErrTransactionCanceled }
+
+// Has reports whether any operation was canceled with code.
+func (e *TxCanceledError) Has(code string) bool {
+	for _, r := range e.Reasons {
+		if r.Code == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// txCanceled decodes the cancellation reasons in err, if any.
+func txCanceled(err error) error {
+	var tce *dynamodb.TransactionCanceledException
+	if !errors.As(err, &tce) {
+		return err
+	}
+
+	ret := &TxCanceledError{Err: err}
+	for i, r := range tce.CancellationReasons {
+		code := aws.StringValue(r.Code)
+		if code == "" || code == TxReasonNone {
+			continue
+		}
+
+		ret.Reasons = append(ret.Reasons, &TxReason{
+			Index:   i,
+			Code:    code,
+			Message: aws.StringValue(r.Message),
+			Item:    r.Item,
+		})
+	}
+
+	return ret
+}
+
+// txRetriable is like retriable, but also retries transactions canceled only
+// by conflicts or throttling.
+func (o options) txRetriable(err error) error {
+	var tce *TxCanceledError
+	if !errors.As(err, &tce) {
+		return o.retriable(err)
+	}
+
+	if len(tce.Reasons) == 0 {
+		return nil
+	}
+
+	for _, r := range tce.Reasons {
+		switch r.Code {
+		case TxReasonTransactionConflict, TxReasonThrottling, TxReasonProvisionedThroughput:
+		default:
+			return nil
+		}
+	}
+
+	return err
+}
+
+func TransactWriteItems(svc dynamodbiface.DynamoDBAPI, ops []TxOp, opts ...Option) error {
+	return TransactWriteItemsWithContext(context.Background(), svc, ops, opts...)
+}
+
+// TransactWriteItemsWithContext applies ops (at most 100) atomically,
+// retrying conflicts and throttling with backoff. If DynamoDB cancels the
+// transaction, the returned error wraps a *TxCanceledError.
+func TransactWriteItemsWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, ops []TxOp, opts ...Option) error {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	ops, err := prepare(ctx, New(svc), ops, o.forTable)
+	if err != nil {
+		return fmt.Errorf("TransactWriteItems failed: %w", err)
+	}
+
+	return transactWrite(ctx, svc, ops, o)
+}
+
+// prepare returns ops as written: with their keys typed per the key schema
+// (see Client.keys), and the items of their puts as stored (see encode),
+// per the options of their tables, as returned by of. ops are not modified.
+func prepare(ctx context.Context, c *Client, ops []TxOp, of func(table string) (options, error)) ([]TxOp, error) {
+	ret := make([]TxOp, len(ops))
+	for i, op := range ops {
+		ret[i] = op
+		if op.err != nil || op.item == nil {
+			continue
+		}
+
+		o, err := of(op.table())
+		if err != nil {
+			return nil, err
+		}
+
+		w := *op.item
+		switch {
+		case w.Put != nil:
+			put := *w.Put
+			if put.Item, err = o.encode(ctx, c.svc, put.Item); err != nil {
+				return nil, fmt.Errorf("operation %d: %w", i, err)
+			}
+
+			w.Put = &put
+		case op.pk != "":
+			kp, ks, err := c.keys(ctx, o, op.pk, op.sk)
+			if err != nil {
+				return nil, fmt.Errorf("operation %d: %w", i, err)
+			}
+
+			switch key := keyMap(kp, ks); {
+			case w.Update != nil:
+				update := *w.Update
+				update.Key, w.Update = key, &update
+			case w.Delete != nil:
+				del := *w.Delete
+				del.Key, w.Delete = key, &del
+			case w.ConditionCheck != nil:
+				check := *w.ConditionCheck
+				check.Key, w.ConditionCheck = key, &check
+			}
+		}
+
+		ret[i] = TxOp{item: &w}
+	}
+
+	return ret, nil
+}
+
+// forTable returns o for table.
+func (o options) forTable(table string) (options, error) {
+	o.table = table
+	return o, nil
+}
+
+// tableOptions returns a function returning the options of the calls of c
+// with opts on a table: those of the Client, of the table (see
+// WithTableDefaults), and opts.
+func (c *Client) tableOptions(opts []Option) func(table string) (options, error) {
+	return func(table string) (options, error) {
+		return c.apply(append(opts[:len(opts):len(opts)], WithTable(table)))
+	}
+}
+
+func transactWrite(ctx context.Context, svc dynamodbiface.DynamoDBAPI, ops []TxOp, o options) error {
+	items := make([]*dynamodb.TransactWriteItem, len(ops))
+	for i, op := range ops {
+		if op.err != nil {
+			return fmt.Errorf("TransactWriteItems operation %d: %w", i, op.err)
+		}
+
+		items[i] = op.item
+	}
+
+	start := time.Now()
+	var rerr error
+
+	// Our retriable function.
+	op := func(ctx context.Context) error {
+		// A fresh input per attempt, so each gets its own idempotency token.
+		_, err := svc.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: items,
+		})
+
+		rerr = txCanceled(err)
+		return o.txRetriable(rerr)
+	}
+
+	err := retry(ctx, o, "TransactWriteItems", op)
+	if (err != nil || rerr != nil) && ctx.Err() != nil {
+		return fmt.Errorf("TransactWriteItems canceled after %v: %w", time.Since(start), ctx.Err())
+	}
+
+	if err != nil {
+		return fmt.Errorf("TransactWriteItems failed after %v: %w", time.Since(start), err)
+	}
+
+	if rerr != nil {
+		return fmt.Errorf("TransactWriteItems failed: %w", awsErr(rerr))
+	}
+
+	return nil
+}
+
+func TransactGetItems(svc dynamodbiface.DynamoDBAPI, gets []*dynamodb.TransactGetItem, opts ...Option) ([]map[string]*dynamodb.AttributeValue, error) {
+	return TransactGetItemsWithContext(context.Background(), svc, gets, opts...)
+}
+
+// TransactGetItemsWithContext reads the items (at most 100) in one
+// consistent snapshot. The result is in the order of gets, with nil for
+// items that don't exist.
+func TransactGetItemsWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, gets []*dynamodb.TransactGetItem, opts ...Option) ([]map[string]*dynamodb.AttributeValue, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return transactGet(ctx, svc, gets, o)
+}
+
+func transactGet(ctx context.Context, svc dynamodbiface.DynamoDBAPI, gets []*dynamodb.TransactGetItem, o options) ([]map[string]*dynamodb.AttributeValue, error) {
+	start := time.Now()
+	var rerr error
+	var res *dynamodb.TransactGetItemsOutput
+
+	// Our retriable function.
+	op := func(ctx context.Context) error {
+		var err error
+		res, err = svc.TransactGetItemsWithContext(ctx, &dynamodb.TransactGetItemsInput{
+			TransactItems: gets,
+		})
+
+		rerr = txCanceled(err)
+		return o.txRetriable(rerr)
+	}
+
+	err := retry(ctx, o, "TransactGetItems", op)
+	if (err != nil || rerr != nil) && ctx.Err() != nil {
+		return nil, fmt.Errorf("TransactGetItems canceled after %v: %w", time.Since(start), ctx.Err())
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("TransactGetItems failed after %v: %w", time.Since(start), err)
+	}
+
+	if rerr != nil {
+		return nil, fmt.Errorf("TransactGetItems failed: %w", awsErr(rerr))
+	}
+
+	ret := make([]map[string]*dynamodb.AttributeValue, len(gets))
+	for i, r := range res.Responses {
+		if i < len(ret) && r != nil {
+			ret[i] = r.Item
+		}
+	}
+
+	return ret, nil
+}
+
+// TransactWriteItems applies ops atomically. Operations built with an empty
+// table name use the Client's table.
+func (c *Client) TransactWriteItems(ctx context.Context, ops []TxOp, opts ...Option) error {
+	o, err := c.apply(opts)
+	if err != nil {
+		return err
+	}
+
+	for _, op := range ops {
+		op.setTable(o.table)
+	}
+
+	if ops, err = prepare(ctx, c, ops, c.tableOptions(opts)); err != nil {
+		return fmt.Errorf("TransactWriteItems failed: %w", err)
+	}
+
+	defer o.cache.invalidateTx(ops)
+
+	return transactWrite(ctx, c.svc, ops, o)
+}
+
+// TransactGetItems reads items in one consistent snapshot. Gets built with
+// an empty table name use the Client's table.
+func (c *Client) TransactGetItems(ctx context.Context, gets []*dynamodb.TransactGetItem, opts ...Option) ([]map[string]*dynamodb.AttributeValue, error) {
+	o, err := c.apply(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, g := range gets {
+		if g.Get != nil && aws.StringValue(g.Get.TableName) == "" {
+			g.Get.TableName = aws.String(o.table)
+		}
+	}
+
+	items, err := transactGet(ctx, c.svc, gets, o)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, item := range items {
+		if gets[i].Get == nil {
+			continue
+		}
+
+		if items[i], err = o.authorizeItem(ctx, aws.StringValue(gets[i].Get.TableName), item); err != nil {
+			return nil, err
+		}
+	}
+
+	return items, nil
+}
+
+// setTable sets the table of op if it has none.
+func (op TxOp) setTable(table string) {
+	if name := op.tableName(); name != nil && aws.StringValue(*name) == "" {
+		*name = aws.String(table)
+	}
+}
+
+// table returns the table of op.
+func (op TxOp) table() string {
+	if name := op.tableName(); name != nil {
+		return aws.StringValue(*name)
+	}
+
+	return ""
+}
+
+// tableName returns the table name field of op, or nil if it has none.
+func (op TxOp) tableName() **string {
+	switch item := op.item; {
+	case item == nil:
+		return nil
+	case item.Put != nil:
+		return &item.Put.TableName
+	case item.Update != nil:
+		return &item.Update.TableName
+	case item.Delete != nil:
+		return &item.Delete.TableName
+	case item.ConditionCheck != nil:
+		return &item.ConditionCheck.TableName
+	}
+
+	return nil
+}
@@ -0,0 +1,173 @@
+package libdy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// TransactWriteItems accepts at most 100 operations per call.
+const transactWriteMax = 100
+
+// WithConcurrency bounds the number of concurrent requests of a multi-request
+// operation such as TransactWriteAll or ParallelScan. The default is 4.
+func WithConcurrency(n int) Option {
+	return func(o *options) { o.concurrency = n }
+}
+
+func (o options) concurrent() int {
+	if o.concurrency > 0 {
+		return o.concurrency
+	}
+
+	return 4
+}
+
+// TxFailure is a transaction of TransactWriteAll that did not land.
+type TxFailure struct {
+	Ops []int // indexes of the operations in the transaction
+	Err error
+}
+
+// TxBatchError is returned by TransactWriteAll when some of the transactions
+// failed. Operations not listed in Failures landed.
+type TxBatchError struct {
+	Total    int // number of transactions
+	Failures []TxFailure
+}
+
+func (e *TxBatchError) Error() string {
+	return fmt.Sprintf("%d of %d transactions failed, first: %v", len(e.Failures), e.Total, e.Failures[0].Err)
+}
+
+func (e *TxBatchError) Unwrap() error { return e.Failures[0].Err }
+
+// txGroups packs the operations into transactions of at most 100, keeping
+// operations with the same group (by index) in the same transaction. A nil
+// group puts every operation in its own group.
+func txGroups(n int, group func(i int) string) ([][]int, error) {
+	var order []string
+	groups := map[string][]int{}
+	for i := 0; i < n; i++ {
+		g := fmt.Sprint(i)
+		if group != nil {
+			g = group(i)
+		}
+
+		if _, ok := groups[g]; !ok {
+			order = append(order, g)
+		}
+
+		groups[g] = append(groups[g], i)
+	}
+
+	var txs [][]int
+	var cur []int
+	for _, g := range order {
+		idx := groups[g]
+		if len(idx) > transactWriteMax {
+			return nil, fmt.Errorf("group %q has %d operations, more than %d", g, len(idx), transactWriteMax)
+		}
+
+		if len(cur)+len(idx) > transactWriteMax {
+			txs = append(txs, cur)
+			cur = nil
+		}
+
+		cur = append(cur, idx...)
+	}
+
+	if len(cur) > 0 {
+		txs = append(txs, cur)
+	}
+
+	return txs, nil
+}
+
+func TransactWriteAll(svc dynamodbiface.DynamoDBAPI, ops []TxOp, group func(i int) string, opts ...Option) error {
+	return TransactWriteAllWithContext(context.Background(), svc, ops, group, opts...)
+}
+
+// TransactWriteAllWithContext applies any number of ops as a series of
+// transactions of at most 100 operations, run concurrently (see
+// WithConcurrency). Operations for which group returns the same key always
+// share a transaction; group may be nil. Atomicity holds only within a
+// transaction. On failure, the returned error is a *TxBatchError.
+func TransactWriteAllWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, ops []TxOp, group func(i int) string, opts ...Option) error {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	ops, err := prepare(ctx, New(svc), ops, o.forTable)
+	if err != nil {
+		return fmt.Errorf("TransactWriteAll failed: %w", err)
+	}
+
+	return transactWriteAll(ctx, svc, ops, group, o)
+}
+
+func transactWriteAll(ctx context.Context, svc dynamodbiface.DynamoDBAPI, ops []TxOp, group func(i int) string, o options) error {
+	txs, err := txGroups(len(ops), group)
+	if err != nil {
+		return fmt.Errorf("TransactWriteAll failed: %w", err)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var failed []TxFailure
+	sem := make(chan struct{}, o.concurrent())
+	for _, tx := range txs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(idx []int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			txops := make([]TxOp, len(idx))
+			for i, j := range idx {
+				txops[i] = ops[j]
+			}
+
+			if err := transactWrite(ctx, svc, txops, o); err != nil {
+				mu.Lock()
+				failed = append(failed, TxFailure{Ops: idx, Err: err})
+				mu.Unlock()
+			}
+		}(tx)
+	}
+
+	wg.Wait()
+	if len(failed) > 0 {
+		sort.Slice(failed, func(i, j int) bool { return failed[i].Ops[0] < failed[j].Ops[0] })
+		return &TxBatchError{Total: len(txs), Failures: failed}
+	}
+
+	return nil
+}
+
+// TransactWriteAll is TransactWriteAllWithContext with the Client's table
+// for operations built with an empty table name.
+func (c *Client) TransactWriteAll(ctx context.Context, ops []TxOp, group func(i int) string, opts ...Option) error {
+	o, err := c.apply(opts)
+	if err != nil {
+		return err
+	}
+
+	for _, op := range ops {
+		op.setTable(o.table)
+	}
+
+	if ops, err = prepare(ctx, c, ops, c.tableOptions(opts)); err != nil {
+		return fmt.Errorf("TransactWriteAll failed: %w", err)
+	}
+
+	defer o.cache.invalidateTx(ops)
+
+	return transactWriteAll(ctx, c.svc, ops, group, o)
+}
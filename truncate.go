@@ -0,0 +1,71 @@
+package libdy
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// WithProgress sets fn to be called with the number of items done so far
+// by TruncateTable, after each page. It may be called concurrently.
+func WithProgress(fn func(n int64)) Option {
+	return func(o *options) { o.progress = fn }
+}
+
+func TruncateTable(svc dynamodbiface.DynamoDBAPI, table string, opts ...Option) (int64, error) {
+	return TruncateTableWithContext(context.Background(), svc, table, opts...)
+}
+
+// TruncateTableWithContext deletes all items of table, keeping the table
+// itself, its indexes, and settings, and returns the number of items
+// deleted. It scans the keys only, in WithConcurrency segments at once (4 by
+// default), and batch deletes each page as it arrives; WithWriteCapacity
+// keeps the deletes under a budget of capacity units, and WithRateLimit
+// paces the scan. Items written meanwhile may survive.
+//
+//	wcu := libdy.NewCapacityLimiter(500)
+//	n, err := libdy.TruncateTableWithContext(ctx, svc, "orders-staging",
+//		libdy.WithConcurrency(8),
+//		libdy.WithWriteCapacity(wcu),
+//		libdy.WithProgress(func(n int64) { log.Printf("%d item(s) deleted", n) }))
+func TruncateTableWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, opts ...Option) (int64, error) {
+	c := New(svc, WithTable(table))
+	o, err := c.apply(opts)
+	if err != nil {
+		return 0, err
+	}
+
+	s, err := c.DescribeSchema(ctx, opts...)
+	if err != nil {
+		return 0, fmt.Errorf("TruncateTable failed: %w", err)
+	}
+
+	// The keys only, and the whole table by design.
+	opts = append(opts[:len(opts):len(opts)], WithProjection(s.keyAttrs()...), WithScanAck())
+	var deleted int64
+	err = c.ParallelScanFunc(ctx, o.concurrent(), func(_ int, keys []map[string]*dynamodb.AttributeValue) error {
+		if len(keys) == 0 {
+			return nil
+		}
+
+		if err := c.BatchDeleteItems(ctx, keys, opts...); err != nil {
+			return err
+		}
+
+		n := atomic.AddInt64(&deleted, int64(len(keys)))
+		if o.progress != nil {
+			o.progress(n)
+		}
+
+		return nil
+	}, opts...)
+
+	if err != nil {
+		return atomic.LoadInt64(&deleted), fmt.Errorf("TruncateTable failed: %w", err)
+	}
+
+	return deleted, nil
+}
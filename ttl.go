@@ -0,0 +1,157 @@
+package libdy
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+func EnableTTL(svc dynamodbiface.DynamoDBAPI, table, attr string) error {
+	return EnableTTLWithContext(context.Background(), svc, table, attr)
+}
+
+// EnableTTLWithContext turns on Time to Live for table, with the expiry time
+// in attr (see TTL). It does nothing if TTL is already on with attr.
+func EnableTTLWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, attr string) error {
+	res, err := svc.DescribeTimeToLiveWithContext(ctx, &dynamodb.DescribeTimeToLiveInput{TableName: aws.String(table)})
+	if err != nil {
+		return fmt.Errorf("DescribeTimeToLive failed: %w", awsErr(err))
+	}
+
+	if d := res.TimeToLiveDescription; d != nil && aws.StringValue(d.AttributeName) == attr {
+		switch aws.StringValue(d.TimeToLiveStatus) {
+		case dynamodb.TimeToLiveStatusEnabled, dynamodb.TimeToLiveStatusEnabling:
+			return nil
+		}
+	}
+
+	_, err = svc.UpdateTimeToLiveWithContext(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(table),
+		TimeToLiveSpecification: &dynamodb.TimeToLiveSpecification{
+			AttributeName: aws.String(attr),
+			Enabled:       aws.Bool(true),
+		},
+	})
+
+	if err != nil {
+		return fmt.Errorf("UpdateTimeToLive failed: %w", awsErr(err))
+	}
+
+	return nil
+}
+
+// TTL is the expiry of an item: the table's TTL attribute (see EnableTTL),
+// and either the time the item expires at, or how long it lives from now.
+// Jitter delays the expiry of each item by a random time up to Jitter, so
+// items written together don't all expire in the same second, making a
+// burst of deletions on the table, its stream, and whatever archives it.
+//
+//	err := client.PutItemWithTTL(ctx, session, libdy.TTL{Attr: "expires", In: 30 * time.Minute})
+type TTL struct {
+	Attr   string
+	At     time.Time
+	In     time.Duration // used if At is zero
+	Jitter time.Duration
+}
+
+// Expires returns the expiry time of t, jittered per call, from now on the
+// system clock. The writes of a Client take theirs from its Clock.
+func (t TTL) Expires() time.Time {
+	return t.expiresAt(time.Now())
+}
+
+// expiresAt is Expires as of now.
+func (t TTL) expiresAt(now time.Time) time.Time {
+	at := t.At
+	if at.IsZero() {
+		at = now.Add(t.In)
+	}
+
+	if t.Jitter > 0 {
+		at = at.Add(time.Duration(rand.Int63n(int64(t.Jitter))))
+	}
+
+	return at
+}
+
+// ExpiryValue returns the TTL attribute value for expiry time t: a number
+// of seconds since the Unix epoch.
+func ExpiryValue(t time.Time) *dynamodb.AttributeValue {
+	return &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(t.Unix(), 10))}
+}
+
+// withTTL returns a copy of item with its TTL attribute set, as of now.
+func withTTL(item map[string]*dynamodb.AttributeValue, ttl TTL, now time.Time) (map[string]*dynamodb.AttributeValue, error) {
+	if ttl.Attr == "" {
+		return nil, fmt.Errorf("invalid TTL: no attribute")
+	}
+
+	ret := make(map[string]*dynamodb.AttributeValue, len(item)+1)
+	for k, v := range item {
+		ret[k] = v
+	}
+
+	ret[ttl.Attr] = ExpiryValue(ttl.expiresAt(now))
+	return ret, nil
+}
+
+func PutItemWithTTL(svc dynamodbiface.DynamoDBAPI, table string, item map[string]*dynamodb.AttributeValue, ttl TTL, opts ...Option) error {
+	return PutItemWithTTLWithContext(context.Background(), svc, table, item, ttl, opts...)
+}
+
+// PutItemWithTTLWithContext is PutItemWithContext for an item that expires
+// per ttl, which sets its TTL attribute. item itself is left as is.
+func PutItemWithTTLWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, item map[string]*dynamodb.AttributeValue, ttl TTL, opts ...Option) error {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	item, err := withTTL(item, ttl, o.now())
+	if err != nil {
+		return fmt.Errorf("PutItem failed: %w", err)
+	}
+
+	return PutItemWithContext(ctx, svc, table, item, opts...)
+}
+
+// PutItemWithTTL is PutItem for an item that expires per ttl, which sets its
+// TTL attribute. item itself is left as is.
+func (c *Client) PutItemWithTTL(ctx context.Context, item map[string]*dynamodb.AttributeValue, ttl TTL, opts ...Option) error {
+	o, err := c.apply(opts)
+	if err != nil {
+		return err
+	}
+
+	item, err = withTTL(item, ttl, o.now())
+	if err != nil {
+		return fmt.Errorf("PutItem failed: %w", err)
+	}
+
+	return c.PutItem(ctx, item, opts...)
+}
+
+// WithTTL sets the TTL attribute of each item written by PutItem,
+// BatchPutItems, and the writers built on them (ImportItems, TableSink, and
+// so on) per ttl, unless the item has it already, so bulk writes get
+// jittered expiries without stamping each item:
+//
+//	client.BatchPutItems(ctx, items, libdy.WithTTL(libdy.TTL{
+//		Attr:   "expires",
+//		In:     30 * 24 * time.Hour,
+//		Jitter: 6 * time.Hour,
+//	}))
+//
+// It is a WithDerived attribute, so an UpdateItem also extends the expiry,
+// unless it sets the attribute itself.
+func WithTTL(ttl TTL) Option {
+	return func(o *options) {
+		o.derived = append(o.derived[:len(o.derived):len(o.derived)], derived{attr: ttl.Attr, ttl: &ttl})
+	}
+}
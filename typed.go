@@ -0,0 +1,112 @@
+package libdy
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// The typed helpers below marshal and unmarshal T with dynamodbattribute,
+// so struct fields map to attributes by their `dynamodbav` tags, and the
+// items are decoded as with UnmarshalItems, honoring its options:
+//
+//	type User struct {
+//		ID   string `dynamodbav:"id"`
+//		Name string `dynamodbav:"name,omitempty"`
+//	}
+//
+//	users, err := libdy.Query[User](ctx, client, "id:123", "")
+
+func unmarshalItems[T any](c *Client, opts []Option, items []map[string]*dynamodb.AttributeValue) ([]T, error) {
+	ret := make([]T, 0, len(items))
+	if err := c.decoding(opts).unmarshalItems(items, &ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// decoding returns the options of c with the defaults of the table, per
+// WithTableDefaults, and opts, for decoding.
+func (c *Client) decoding(opts []Option) options {
+	o := c.opts
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if defaults := o.defaults.get(o.table); defaults != nil {
+		o = c.opts
+		for _, opt := range append(defaults[:len(defaults):len(defaults)], opts...) {
+			opt(&o)
+		}
+	}
+
+	return o
+}
+
+// Query is Client.Query with the items unmarshaled into []T.
+func Query[T any](ctx context.Context, c *Client, pk, sk string, opts ...Option) ([]T, error) {
+	res, err := c.Query(ctx, pk, sk, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return unmarshalItems[T](c, opts, res.Items)
+}
+
+// QueryIndex is Client.QueryIndex with the items unmarshaled into []T.
+func QueryIndex[T any](ctx context.Context, c *Client, index, key, value string, opts ...Option) ([]T, error) {
+	res, err := c.QueryIndex(ctx, index, key, value, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return unmarshalItems[T](c, opts, res.Items)
+}
+
+// Scan is Client.Scan with the items unmarshaled into []T.
+func Scan[T any](ctx context.Context, c *Client, opts ...Option) ([]T, error) {
+	res, err := c.Scan(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return unmarshalItems[T](c, opts, res.Items)
+}
+
+// Get is Client.GetItem with the item unmarshaled into T.
+func Get[T any](ctx context.Context, c *Client, pk, sk string, opts ...Option) (T, error) {
+	var ret T
+	item, err := c.GetItem(ctx, pk, sk, opts...)
+	if err != nil {
+		return ret, err
+	}
+
+	err = c.decoding(opts).unmarshal(item, &ret)
+	return ret, err
+}
+
+// Put marshals v into an item and writes it with Client.PutItem.
+func Put[T any](ctx context.Context, c *Client, v T, opts ...Option) error {
+	item, err := c.decoding(opts).marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return c.PutItem(ctx, item, opts...)
+}
+
+// marshal encodes v into an item, with the converters of o, if any, and
+// its empty values per WithEmptyValues.
+func (o options) marshal(v interface{}) (map[string]*dynamodb.AttributeValue, error) {
+	item, err := o.converters.marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := o.empty.apply(v, item, o.converters); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
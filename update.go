@@ -0,0 +1,291 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+var (
+	ErrEmptyUpdate = errors.New("libdy: empty update")
+)
+
+type updateAction struct {
+	attr  string
+	value *dynamodb.AttributeValue
+}
+
+// Update builds an UpdateExpression. Attribute names are aliased
+// automatically, so reserved words are fine.
+//
+//	u := libdy.NewUpdate().
+//		Set("status", &dynamodb.AttributeValue{S: aws.String("done")}).
+//		Increment("count", 1).
+//		Remove("lock")
+type Update struct {
+	set    []updateAction
+	remove []string
+	add    []updateAction
+	del    []updateAction
+}
+
+func NewUpdate() *Update { return &Update{} }
+
+// Set sets attr to v.
+func (u *Update) Set(attr string, v *dynamodb.AttributeValue) *Update {
+	u.set = append(u.set, updateAction{attr, v})
+	return u
+}
+
+// SetAll sets every attribute in m, in name order.
+func (u *Update) SetAll(m map[string]*dynamodb.AttributeValue) *Update {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	for _, k := range keys {
+		u.Set(k, m[k])
+	}
+
+	return u
+}
+
+// Remove deletes attrs from the item.
+func (u *Update) Remove(attrs ...string) *Update {
+	u.remove = append(u.remove, attrs...)
+	return u
+}
+
+// Add adds v to a number attribute, or adds the elements of set v to a set
+// attribute. Missing attributes are treated as zero or the empty set.
+func (u *Update) Add(attr string, v *dynamodb.AttributeValue) *Update {
+	u.add = append(u.add, updateAction{attr, v})
+	return u
+}
+
+// Increment adds n (which may be negative) to a number attribute.
+func (u *Update) Increment(attr string, n int64) *Update {
+	return u.Add(attr, &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(n, 10))})
+}
+
+// Delete removes the elements of set v from a set attribute.
+func (u *Update) Delete(attr string, v *dynamodb.AttributeValue) *Update {
+	u.del = append(u.del, updateAction{attr, v})
+	return u
+}
+
+func (u *Update) empty() bool {
+	return u == nil || len(u.set)+len(u.remove)+len(u.add)+len(u.del) == 0
+}
+
+// expression returns the UpdateExpression with its names and values.
+func (u *Update) expression() (string, map[string]*string, map[string]*dynamodb.AttributeValue, error) {
+	if u.empty() {
+		return "", nil, nil, ErrEmptyUpdate
+	}
+
+	names := map[string]*string{}
+	values := map[string]*dynamodb.AttributeValue{}
+	name := func(attr string) string {
+		alias := fmt.Sprintf("#u%d", len(names))
+		names[alias] = aws.String(attr)
+		return alias
+	}
+
+	value := func(v *dynamodb.AttributeValue) string {
+		alias := fmt.Sprintf(":u%d", len(values))
+		values[alias] = v
+		return alias
+	}
+
+	var clauses []string
+	clause := func(verb, sep string, actions []updateAction) {
+		if len(actions) == 0 {
+			return
+		}
+
+		parts := make([]string, len(actions))
+		for i, a := range actions {
+			parts[i] = name(a.attr) + sep + value(a.value)
+		}
+
+		clauses = append(clauses, verb+" "+strings.Join(parts, ", "))
+	}
+
+	clause("SET", " = ", u.set)
+	clause("ADD", " ", u.add)
+	clause("DELETE", " ", u.del)
+	if len(u.remove) > 0 {
+		parts := make([]string, len(u.remove))
+		for i, attr := range u.remove {
+			parts[i] = name(attr)
+		}
+
+		clauses = append(clauses, "REMOVE "+strings.Join(parts, ", "))
+	}
+
+	if len(values) == 0 {
+		values = nil
+	}
+
+	return strings.Join(clauses, " "), names, values, nil
+}
+
+// WithReturnValues sets which attributes UpdateItem returns, one of the
+// dynamodb.ReturnValue* constants: dynamodb.ReturnValueAllNew for the item
+// after the update, dynamodb.ReturnValueAllOld for the item before it, or
+// dynamodb.ReturnValueUpdatedNew for the updated attributes only. For the
+// old item of a put or delete, see PutItemReturning and DeleteItemReturning.
+func WithReturnValues(rv string) Option {
+	return func(o *options) { o.returnValues = rv }
+}
+
+// returnOld returns the ReturnValues of a put or delete, ignoring those
+// only valid for updates, e.g. set Client-wide.
+func (o options) returnOld() *string {
+	switch o.returnValues {
+	case dynamodb.ReturnValueAllOld, dynamodb.ReturnValueNone:
+		return aws.String(o.returnValues)
+	}
+
+	return nil
+}
+
+func UpdateItem(svc dynamodbiface.DynamoDBAPI, table, pk, sk string, u *Update, opts ...Option) (map[string]*dynamodb.AttributeValue, error) {
+	return UpdateItemWithContext(context.Background(), svc, table, pk, sk, u, opts...)
+}
+
+// UpdateItemWithContext applies u to the item with the given key, creating
+// the item if it doesn't exist. The returned attributes are empty unless
+// WithReturnValues is set. With WithCondition, fails with ErrConditionFailed
+// if the condition doesn't hold.
+func UpdateItemWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, pk, sk string, u *Update, opts ...Option) (map[string]*dynamodb.AttributeValue, error) {
+	o := options{table: table}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	kp, ks, err := New(svc).keys(ctx, o, pk, sk)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateItem failed: %w", err)
+	}
+
+	return updateItem(ctx, svc, keyMap(kp, ks), u, o)
+}
+
+func updateItem(ctx context.Context, svc dynamodbiface.DynamoDBAPI, key map[string]*dynamodb.AttributeValue, u *Update, o options) (map[string]*dynamodb.AttributeValue, error) {
+	u = o.deriveUpdate(u)
+	if err := o.schemas.checkUpdate(o.table, u); err != nil {
+		return nil, fmt.Errorf("UpdateItem failed: %w", err)
+	}
+
+	if err := o.encryption.checkUpdate(u); err != nil {
+		return nil, fmt.Errorf("UpdateItem failed: %w", err)
+	}
+
+	expr, names, values, err := u.expression()
+	if err != nil {
+		return nil, err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(o.table),
+		Key:                       key,
+		UpdateExpression:          aws.String(expr),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	}
+
+	cond, cnames, cvalues := o.conditionInput()
+	if cond != nil {
+		input.ConditionExpression = cond
+		for k, v := range cnames {
+			input.ExpressionAttributeNames[k] = v
+		}
+
+		if len(cvalues) > 0 && input.ExpressionAttributeValues == nil {
+			input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{}
+		}
+
+		for k, v := range cvalues {
+			input.ExpressionAttributeValues[k] = v
+		}
+	}
+
+	if o.returnValues != "" {
+		input.ReturnValues = aws.String(o.returnValues)
+	}
+
+	input.ReturnConsumedCapacity = o.returnCapacity()
+	if err := o.writeLimit.wait(ctx); err != nil {
+		return nil, fmt.Errorf("UpdateItem canceled: %w", err)
+	}
+
+	start := time.Now()
+	var rerr error
+	var res *dynamodb.UpdateItemOutput
+
+	// Our retriable function.
+	op := func(ctx context.Context) error {
+		res, err = svc.UpdateItemWithContext(ctx, input)
+		rerr = err
+		return o.retriable(err)
+	}
+
+	tries, err := retryN(ctx, o, "UpdateItem", op)
+	o.observe(ctx, "UpdateItem", o.table, start, tries, res, err, rerr)
+	o.conditionFailed(rerr)
+	if (err != nil || rerr != nil) && ctx.Err() != nil {
+		return nil, fmt.Errorf("UpdateItem canceled after %v: %w", time.Since(start), ctx.Err())
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("UpdateItem failed after %v: %w", time.Since(start), err)
+	}
+
+	if rerr != nil {
+		return nil, fmt.Errorf("UpdateItem failed: %w", awsErr(rerr))
+	}
+
+	units := capacityUnits(res.ConsumedCapacity)
+	o.writeLimit.consume(units)
+	o.reportCapacity(o.table, "UpdateItem", units, 1)
+	return o.decode(ctx, res.Attributes)
+}
+
+func (c *Client) UpdateItem(ctx context.Context, pk, sk string, u *Update, opts ...Option) (map[string]*dynamodb.AttributeValue, error) {
+	o, err := c.apply(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	kp, ks, err := c.keys(ctx, o, pk, sk)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateItem failed: %w", err)
+	}
+
+	return c.updateItem(ctx, kp, ks, u, o)
+}
+
+func (c *Client) updateItem(ctx context.Context, pk, sk Key, u *Update, o options) (map[string]*dynamodb.AttributeValue, error) {
+	key := keyMap(pk, sk)
+	release, err := o.partitions.acquire(ctx, o.table, key)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateItem canceled: %w", err)
+	}
+
+	defer release()
+	o.hotKeys.observe(o.table, keyMap(pk, Key{}))
+	defer o.cache.invalidate(o.table, key)
+	return updateItem(ctx, c.svc, key, u, o)
+}
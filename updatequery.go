@@ -0,0 +1,127 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// WithRateLimit paces the requests of bulk operations to at most n per
+// second: per item for UpdateByQuery, per page for ParallelScan.
+func WithRateLimit(n float64) Option {
+	return func(o *options) { o.rate = n }
+}
+
+// UpdateFailure is an item UpdateByQuery failed to update.
+type UpdateFailure struct {
+	Key map[string]*dynamodb.AttributeValue
+	Err error
+}
+
+// UpdateByQueryReport is the per-item outcome of UpdateByQuery.
+type UpdateByQueryReport struct {
+	Matched int                                   // items read
+	Updated int                                   // items updated
+	Skipped []map[string]*dynamodb.AttributeValue // keys of items that no longer matched
+	Failed  []UpdateFailure
+}
+
+func UpdateByQuery(svc dynamodbiface.DynamoDBAPI, table, pk, sk string, u *Update, opts ...Option) (*UpdateByQueryReport, error) {
+	return UpdateByQueryWithContext(context.Background(), svc, table, pk, sk, u, opts...)
+}
+
+// UpdateByQueryWithContext is Client.UpdateByQuery for table.
+func UpdateByQueryWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, pk, sk string, u *Update, opts ...Option) (*UpdateByQueryReport, error) {
+	return New(svc, WithTable(table)).UpdateByQuery(ctx, pk, sk, u, opts...)
+}
+
+// UpdateByQuery applies u to every item under pk (and, optionally, the sk
+// prefix, or the WithSortKey condition) that matches the WithFilter filter,
+// the missing "UPDATE ... WHERE". Items are read page by page and updated
+// one at a time, paced by WithRateLimit.
+//
+// Each update is conditional on the item still existing and still matching
+// the filter, so items changed or deleted since they were read are skipped
+// rather than clobbered or recreated. The returned error is for the query
+// itself; per-item outcomes are in the report.
+func (c *Client) UpdateByQuery(ctx context.Context, pk, sk string, u *Update, opts ...Option) (*UpdateByQueryReport, error) {
+	o, err := c.apply(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs, err := c.keyAttrs(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+
+	cond := Condition{
+		Label:  "update_by_query",
+		Expr:   "attribute_exists(#ubq)",
+		Names:  map[string]*string{"#ubq": aws.String(attrs[0])},
+		Values: map[string]*dynamodb.AttributeValue{},
+	}
+
+	if o.filter != nil {
+		cond.Expr += " AND (" + o.filter.Expr + ")"
+		for k, v := range o.filter.Names {
+			cond.Names[k] = v
+		}
+
+		for k, v := range o.filter.Values {
+			cond.Values[k] = v
+		}
+	}
+
+	uo := o
+	uo.condition = &cond
+	var tick *time.Ticker
+	if o.rate > 0 {
+		tick = time.NewTicker(time.Duration(float64(time.Second) / o.rate))
+		defer tick.Stop()
+	}
+
+	report := &UpdateByQueryReport{}
+	if len(o.projection) > 0 { // we need the keys
+		proj := append([]string{}, o.projection...)
+		for _, a := range attrs {
+			if !slices.Contains(proj, a) {
+				proj = append(proj, a)
+			}
+		}
+
+		opts = append(opts[:len(opts):len(opts)], WithProjection(proj...))
+	}
+
+	it := c.QueryIter(pk, sk, opts...)
+	defer it.Close()
+	for it.Next(ctx) {
+		report.Matched++
+		if tick != nil {
+			select {
+			case <-ctx.Done():
+				return report, ctx.Err()
+			case <-tick.C:
+			}
+		}
+
+		key := keyOf(it.Item(), attrs)
+		_, err := updateItem(ctx, c.svc, key, u, uo)
+		switch {
+		case err == nil:
+			uo.cache.invalidate(o.table, key)
+			report.Updated++
+		case errors.Is(err, ErrConditionFailed):
+			report.Skipped = append(report.Skipped, key)
+		default:
+			report.Failed = append(report.Failed, UpdateFailure{Key: key, Err: err})
+		}
+	}
+
+	return report, it.Err()
+}
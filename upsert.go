@@ -0,0 +1,210 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// upsertMaxTries bounds the read-merge-write rounds of an Upsert or a
+// Mutate that keeps losing to concurrent writers, per WithConflictTries.
+const upsertMaxTries = 10
+
+var (
+	ErrUpsertContention = errors.New("libdy: upsert lost to concurrent writes")
+)
+
+// MergeFunc returns the item to store given old, the existing item, and
+// new, the item being upserted. It must not modify old, and may be called
+// more than once per Upsert.
+type MergeFunc func(old, new map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error)
+
+// MergeReplace is the default MergeFunc: the attributes of new replace those
+// of old, and the other attributes of old are kept.
+func MergeReplace(old, new map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+	ret := make(map[string]*dynamodb.AttributeValue, len(old)+len(new))
+	for k, v := range old {
+		ret[k] = v
+	}
+
+	for k, v := range new {
+		ret[k] = v
+	}
+
+	return ret, nil
+}
+
+func Upsert(svc dynamodbiface.DynamoDBAPI, table, pk, sk string, item map[string]*dynamodb.AttributeValue, merge MergeFunc, opts ...Option) (map[string]*dynamodb.AttributeValue, error) {
+	return UpsertWithContext(context.Background(), svc, table, pk, sk, item, merge, opts...)
+}
+
+// UpsertWithContext is Client.Upsert for table.
+func UpsertWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, pk, sk string, item map[string]*dynamodb.AttributeValue, merge MergeFunc, opts ...Option) (map[string]*dynamodb.AttributeValue, error) {
+	return New(svc, WithTable(table)).Upsert(ctx, pk, sk, item, merge, opts...)
+}
+
+// Upsert inserts item, the item with key pk and sk, or if it exists, merges
+// it into the existing one with merge (MergeReplace if nil), without losing
+// concurrent writes: it first tries a create-only put, and when that fails
+// because the item exists, reads the item and writes the merge with an
+// update conditioned on the item being unchanged, reading again if it was.
+// It returns the item stored, and fails with ErrUpsertContention if the
+// item kept changing (see WithConflictTries). WithCondition is ignored.
+//
+// merge gets the existing item as Mutate passes it, decoded but not through
+// the read pipeline.
+func (c *Client) Upsert(ctx context.Context, pk, sk string, item map[string]*dynamodb.AttributeValue, merge MergeFunc, opts ...Option) (map[string]*dynamodb.AttributeValue, error) {
+	if merge == nil {
+		merge = MergeReplace
+	}
+
+	o, err := c.apply(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	kp, ks, err := c.keys(ctx, o, pk, sk)
+	if err != nil {
+		return nil, fmt.Errorf("Upsert failed: %w", err)
+	}
+
+	keyAttrs := []string{kp.Name}
+	if ks.Name != "" {
+		keyAttrs = append(keyAttrs, ks.Name)
+	}
+
+	tries := o.conflictTries()
+	for i := 0; i < tries; i++ {
+		err := c.PutItem(ctx, item, append(opts[:len(opts):len(opts)], WithCondition(IfNotExists(keyAttrs[0])))...)
+		if err == nil {
+			return item, nil
+		}
+
+		if !errors.Is(err, ErrConditionFailed) {
+			return nil, fmt.Errorf("Upsert failed: %w", err)
+		}
+
+		for ; i < tries; i++ {
+			stored, old, err := c.readStored(ctx, kp, ks, o)
+			if err != nil {
+				return nil, fmt.Errorf("Upsert failed: %w", err)
+			}
+
+			if stored == nil {
+				break // deleted since; create it again
+			}
+
+			merged, err := merge(old, item)
+			if err != nil {
+				return nil, fmt.Errorf("Upsert failed: merge: %w", err)
+			}
+
+			wrote, err := c.writeBack(ctx, kp, ks, stored, old, merged, keyAttrs, "upsert", o)
+			switch {
+			case err == nil && !wrote:
+				return old, nil // nothing to change
+			case err == nil:
+				return merged, nil
+			}
+
+			if !errors.Is(err, ErrConditionFailed) {
+				return nil, fmt.Errorf("Upsert failed: %w", err)
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("Upsert failed after %d tries: %w", tries, ErrUpsertContention)
+}
+
+// compareAndSet returns the update turning old into merged, or nil if there
+// is no change. Key attributes are not updated.
+func compareAndSet(old, merged map[string]*dynamodb.AttributeValue, keyAttrs []string) *Update {
+	isKey := map[string]bool{}
+	for _, k := range keyAttrs {
+		isKey[k] = true
+	}
+
+	set := map[string]*dynamodb.AttributeValue{}
+	for k, v := range merged {
+		if !isKey[k] && !reflect.DeepEqual(old[k], v) {
+			set[k] = v
+		}
+	}
+
+	var remove []string
+	for k := range old {
+		if _, ok := merged[k]; !ok && !isKey[k] {
+			remove = append(remove, k)
+		}
+	}
+
+	if len(set) == 0 && len(remove) == 0 {
+		return nil
+	}
+
+	sort.Strings(remove)
+	u := NewUpdate().SetAll(set)
+	if len(remove) > 0 {
+		u.Remove(remove...)
+	}
+
+	return u
+}
+
+// unchanged returns the condition, labeled label, that the item is still
+// old, as stored, encoded by the codecs of the Client if any, and that the
+// attributes of next that old lacks were not added meanwhile. Key
+// attributes are not compared.
+func unchanged(label string, old, next map[string]*dynamodb.AttributeValue, keyAttrs []string) Condition {
+	isKey := map[string]bool{}
+	for _, k := range keyAttrs {
+		isKey[k] = true
+	}
+
+	var attrs, added []string
+	for k := range old {
+		if !isKey[k] {
+			attrs = append(attrs, k)
+		}
+	}
+
+	for k := range next {
+		if _, ok := old[k]; !ok && !isKey[k] {
+			added = append(added, k)
+		}
+	}
+
+	sort.Strings(attrs)
+	sort.Strings(added)
+	cond := Condition{
+		Label:  label,
+		Expr:   "attribute_exists(#ck)",
+		Names:  map[string]*string{"#ck": aws.String(keyAttrs[0])},
+		Values: map[string]*dynamodb.AttributeValue{},
+	}
+
+	exprs := []string{cond.Expr}
+	for i, k := range attrs {
+		n, v := "#c"+strconv.Itoa(i), ":c"+strconv.Itoa(i) // apart from the update's #u, :u
+		cond.Names[n] = aws.String(k)
+		cond.Values[v] = old[k]
+		exprs = append(exprs, n+" = "+v)
+	}
+
+	for i, k := range added {
+		n := "#a" + strconv.Itoa(i)
+		cond.Names[n] = aws.String(k)
+		exprs = append(exprs, "attribute_not_exists("+n+")")
+	}
+
+	cond.Expr = strings.Join(exprs, " AND ")
+	return cond
+}
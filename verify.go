@@ -0,0 +1,126 @@
+package libdy
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// ExportLookup fetches the exported copy of the item with the given key from
+// the export destination (S3, a warehouse, ...). It returns nil if the item
+// is not there.
+type ExportLookup func(ctx context.Context, key map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error)
+
+// VerifyReport is the outcome of VerifyExport.
+type VerifyReport struct {
+	Sampled    int
+	Missing    []map[string]*dynamodb.AttributeValue // keys not found in the export
+	Mismatched []map[string]*dynamodb.AttributeValue // keys whose exported values differ
+}
+
+// DivergenceRate returns the fraction of sampled items that are missing or
+// different in the export.
+func (r *VerifyReport) DivergenceRate() float64 {
+	if r.Sampled == 0 {
+		return 0
+	}
+
+	return float64(len(r.Missing)+len(r.Mismatched)) / float64(r.Sampled)
+}
+
+func VerifyExport(svc dynamodbiface.DynamoDBAPI, table string, n int, lookup ExportLookup, opts ...Option) (*VerifyReport, error) {
+	return VerifyExportWithContext(context.Background(), svc, table, n, lookup, opts...)
+}
+
+// VerifyExportWithContext picks up to n random items from table and checks,
+// through lookup, that each is present in the exported data with the same
+// attribute values. Items written after the export show up as divergent, so
+// compare against a fresh export or expect a small baseline rate.
+//
+// Items are sampled by scanning random segments, sized from the approximate
+// item count so that each segment holds about one item.
+func VerifyExportWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, n int, lookup ExportLookup, opts ...Option) (*VerifyReport, error) {
+	o := options{table: table}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	res, err := svc.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+	if err != nil {
+		return nil, fmt.Errorf("DescribeTable failed: %w", awsErr(err))
+	}
+
+	var keyAttrs []string
+	for _, k := range res.Table.KeySchema {
+		keyAttrs = append(keyAttrs, aws.StringValue(k.AttributeName))
+	}
+
+	segments := aws.Int64Value(res.Table.ItemCount)
+	switch {
+	case segments < 1:
+		segments = 1
+	case segments > 1000000: // the Scan maximum
+		segments = 1000000
+	}
+
+	report := &VerifyReport{}
+	seen := map[string]bool{}
+	for tries := 0; report.Sampled < n && tries < 4*n; tries++ {
+		in := &dynamodb.ScanInput{
+			TableName:     aws.String(table),
+			Segment:       aws.Int64(rand.Int63n(segments)),
+			TotalSegments: aws.Int64(segments),
+		}
+
+		if o.consistent {
+			in.ConsistentRead = aws.Bool(true)
+		}
+
+		so := o
+		so.maxItems = 1
+		page, err := scan(ctx, svc, in, so)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(page.Items) == 0 {
+			continue
+		}
+
+		item := page.Items[0]
+		key := map[string]*dynamodb.AttributeValue{}
+		var ids []string
+		for _, a := range keyAttrs {
+			key[a] = item[a]
+			ids = append(ids, keyString(item[a]))
+		}
+
+		id := strings.Join(ids, "\x00")
+		if seen[id] {
+			continue
+		}
+
+		seen[id] = true
+
+		exported, err := lookup(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("export lookup failed: %w", err)
+		}
+
+		report.Sampled++
+		switch {
+		case exported == nil:
+			report.Missing = append(report.Missing, key)
+		case !reflect.DeepEqual(item, exported):
+			report.Mismatched = append(report.Mismatched, key)
+		}
+	}
+
+	return report, nil
+}
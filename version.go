@@ -0,0 +1,143 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+var (
+	ErrVersionConflict = errors.New("libdy: version conflict")
+)
+
+// VersionCondition holds if the item's number attribute attr is expected,
+// or, if expected is 0, if the item has no attr (e.g. a new item).
+func VersionCondition(attr string, expected int64) Condition {
+	if expected == 0 {
+		return Condition{
+			Label: "version",
+			Expr:  "attribute_not_exists(#v0)",
+			Names: map[string]*string{"#v0": aws.String(attr)},
+		}
+	}
+
+	return Condition{
+		Label:  "version",
+		Expr:   "#v0 = :v0",
+		Names:  map[string]*string{"#v0": aws.String(attr)},
+		Values: map[string]*dynamodb.AttributeValue{":v0": {N: aws.String(strconv.FormatInt(expected, 10))}},
+	}
+}
+
+// versionOpts returns opts with the VersionCondition of attr and expected,
+// and'ed with any condition set in opts.
+func (c *Client) versionOpts(attr string, expected int64, opts []Option) ([]Option, error) {
+	o, err := c.apply(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	cond := VersionCondition(attr, expected)
+	if o.condition != nil {
+		cond = andCondition(*o.condition, cond)
+	}
+
+	return append(opts[:len(opts):len(opts)], WithCondition(cond)), nil
+}
+
+func PutItemVersioned(svc dynamodbiface.DynamoDBAPI, table string, item map[string]*dynamodb.AttributeValue, attr string, opts ...Option) (int64, error) {
+	return PutItemVersionedWithContext(context.Background(), svc, table, item, attr, opts...)
+}
+
+// PutItemVersionedWithContext is Client.PutItemVersioned for table.
+func PutItemVersionedWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table string, item map[string]*dynamodb.AttributeValue, attr string, opts ...Option) (int64, error) {
+	return New(svc, WithTable(table)).PutItemVersioned(ctx, item, attr, opts...)
+}
+
+// PutItemVersioned writes item with optimistic locking on its version, the
+// number attribute attr: item must carry the version it was read at (none
+// for a new item), and is written with the next version if the stored item
+// is still at that version, or fails with ErrVersionConflict if another
+// writer got there first; read the item again and retry. It returns the new
+// version. item itself is not modified.
+//
+//	item, err := client.GetItem(ctx, "id:1", "", libdy.WithConsistentRead())
+//	// ... modify item ...
+//	v, err := client.PutItemVersioned(ctx, item, "version")
+//	if errors.Is(err, libdy.ErrVersionConflict) {
+//		// reload and try again
+//	}
+func (c *Client) PutItemVersioned(ctx context.Context, item map[string]*dynamodb.AttributeValue, attr string, opts ...Option) (int64, error) {
+	var expected int64
+	if v := item[attr]; v != nil {
+		var err error
+		if expected, err = strconv.ParseInt(aws.StringValue(v.N), 10, 64); err != nil {
+			return 0, fmt.Errorf("PutItemVersioned failed: invalid version %s: %w", attr, err)
+		}
+	}
+
+	opts, err := c.versionOpts(attr, expected, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	next := make(map[string]*dynamodb.AttributeValue, len(item)+1)
+	for k, v := range item {
+		next[k] = v
+	}
+
+	next[attr] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(expected+1, 10))}
+	err = c.PutItem(ctx, next, opts...)
+	if errors.Is(err, ErrConditionFailed) {
+		return 0, fmt.Errorf("PutItemVersioned failed: %s not at version %d: %w", attr, expected, ErrVersionConflict)
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("PutItemVersioned failed: %w", err)
+	}
+
+	return expected + 1, nil
+}
+
+func UpdateItemVersioned(svc dynamodbiface.DynamoDBAPI, table, pk, sk, attr string, expected int64, u *Update, opts ...Option) (map[string]*dynamodb.AttributeValue, error) {
+	return UpdateItemVersionedWithContext(context.Background(), svc, table, pk, sk, attr, expected, u, opts...)
+}
+
+// UpdateItemVersionedWithContext is Client.UpdateItemVersioned for table.
+func UpdateItemVersionedWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, pk, sk, attr string, expected int64, u *Update, opts ...Option) (map[string]*dynamodb.AttributeValue, error) {
+	return New(svc, WithTable(table)).UpdateItemVersioned(ctx, pk, sk, attr, expected, u, opts...)
+}
+
+// UpdateItemVersioned applies u to the item with key pk and sk if its
+// version, the number attribute attr, is still expected (0 for a new item,
+// without attr), incrementing the version along, or fails with
+// ErrVersionConflict. The new version is expected+1; the returned
+// attributes are as with UpdateItem.
+func (c *Client) UpdateItemVersioned(ctx context.Context, pk, sk, attr string, expected int64, u *Update, opts ...Option) (map[string]*dynamodb.AttributeValue, error) {
+	opts, err := c.versionOpts(attr, expected, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	v := NewUpdate() // u plus the increment; u itself is not modified
+	if u != nil {
+		*v = *u
+		v.add = v.add[:len(v.add):len(v.add)]
+	}
+
+	ret, err := c.UpdateItem(ctx, pk, sk, v.Increment(attr, 1), opts...)
+	if errors.Is(err, ErrConditionFailed) {
+		return nil, fmt.Errorf("UpdateItemVersioned failed: %s not at version %d: %w", attr, expected, ErrVersionConflict)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("UpdateItemVersioned failed: %w", err)
+	}
+
+	return ret, nil
+}
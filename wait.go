@@ -0,0 +1,140 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+var (
+	// ErrWaitTimeout matches reads whose WithReadUntil condition, or
+	// WaitForItem predicate, didn't hold before the timeout.
+	ErrWaitTimeout = errors.New("libdy: wait timed out")
+)
+
+// The delays between the reads of WithReadUntil, doubling from the first to
+// the last.
+const (
+	readUntilFirstDelay = 50 * time.Millisecond
+	readUntilMaxDelay   = time.Second
+)
+
+type readUntil struct {
+	cond    func(items []map[string]*dynamodb.AttributeValue) bool
+	timeout time.Duration
+}
+
+// WithReadUntil makes GetItem, Query, and QueryIndex (and the helpers on
+// them, such as Get and Repository.QueryByIndex) read again, backing off
+// from 50ms to 1s, until cond holds for the items read, for reads after a
+// write that may not show it yet, e.g. on a global secondary index:
+//
+//	res, err := client.QueryIndex(ctx, "by-email", "email", email, libdy.WithReadUntil(
+//		func(items []map[string]*dynamodb.AttributeValue) bool { return len(items) > 0 },
+//		5*time.Second))
+//
+// GetItem passes cond one item, or none if there is none. The read fails
+// with ErrWaitTimeout if cond doesn't hold within timeout (0 waits as long
+// as ctx allows). The item cache is bypassed.
+func WithReadUntil(cond func(items []map[string]*dynamodb.AttributeValue) bool, timeout time.Duration) Option {
+	return func(o *options) { o.until = &readUntil{cond: cond, timeout: timeout} }
+}
+
+// poll calls read, with o minus WithReadUntil, until the items it returns
+// satisfy the WithReadUntil condition.
+func (o options) poll(ctx context.Context, read func(ctx context.Context, o options) ([]map[string]*dynamodb.AttributeValue, error)) error {
+	u := o.until
+	o.until, o.cache = nil, nil
+	parent := ctx
+	if u.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, u.timeout)
+		defer cancel()
+	}
+
+	timedOut := func() error {
+		if err := parent.Err(); err != nil {
+			return err
+		}
+
+		return fmt.Errorf("read condition not met in %v: %w", u.timeout, ErrWaitTimeout)
+	}
+
+	for delay := readUntilFirstDelay; ; delay = min(2*delay, readUntilMaxDelay) {
+		items, err := read(ctx, o)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return timedOut()
+		case err != nil:
+			return err
+		case u.cond(items):
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return timedOut()
+		case <-o.after(delay):
+		}
+	}
+}
+
+// pollResult is poll for a read returning a Result, and returns the last.
+func pollResult(ctx context.Context, o options, read func(ctx context.Context, o options) (*Result, error)) (*Result, error) {
+	var res *Result
+	err := o.poll(ctx, func(ctx context.Context, o options) ([]map[string]*dynamodb.AttributeValue, error) {
+		var err error
+		if res, err = read(ctx, o); err != nil {
+			return nil, err
+		}
+
+		return res.Items, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func WaitForItem(svc dynamodbiface.DynamoDBAPI, table, pk, sk string, pred func(item map[string]*dynamodb.AttributeValue) bool, timeout time.Duration, opts ...Option) (map[string]*dynamodb.AttributeValue, error) {
+	return WaitForItemWithContext(context.Background(), svc, table, pk, sk, pred, timeout, opts...)
+}
+
+// WaitForItemWithContext is Client.WaitForItem for table.
+func WaitForItemWithContext(ctx context.Context, svc dynamodbiface.DynamoDBAPI, table, pk, sk string, pred func(item map[string]*dynamodb.AttributeValue) bool, timeout time.Duration, opts ...Option) (map[string]*dynamodb.AttributeValue, error) {
+	return New(svc, WithTable(table)).WaitForItem(ctx, pk, sk, pred, timeout, opts...)
+}
+
+// WaitForItem reads the item with the key pk and sk, as GetItem does with
+// WithReadUntil, until pred holds for it, and returns it. pred gets a nil
+// item while there is none, so it can wait for a delete as well; a nil pred
+// waits for the item to exist. It fails with ErrWaitTimeout if pred doesn't
+// hold within timeout (0 waits as long as ctx allows).
+func (c *Client) WaitForItem(ctx context.Context, pk, sk string, pred func(item map[string]*dynamodb.AttributeValue) bool, timeout time.Duration, opts ...Option) (map[string]*dynamodb.AttributeValue, error) {
+	if pred == nil {
+		pred = func(item map[string]*dynamodb.AttributeValue) bool { return item != nil }
+	}
+
+	item, err := c.GetItem(ctx, pk, sk, append(opts[:len(opts):len(opts)], WithReadUntil(func(items []map[string]*dynamodb.AttributeValue) bool {
+		if len(items) == 0 {
+			return pred(nil)
+		}
+
+		return pred(items[0])
+	}, timeout))...)
+
+	switch {
+	case errors.Is(err, ErrItemNotFound):
+		return nil, nil // as awaited
+	case err != nil:
+		return nil, fmt.Errorf("WaitForItem failed: %w", err)
+	}
+
+	return item, nil
+}
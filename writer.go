@@ -0,0 +1,329 @@
+package libdy
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Defaults of WriterConfig.
+const (
+	writerInterval    = 100 * time.Millisecond
+	writerConcurrency = 4
+	writerBuffer      = 1000
+)
+
+var (
+	ErrWriterClosed = errors.New("libdy: writer closed")
+)
+
+// WriterConfig configures a Writer.
+type WriterConfig struct {
+	Interval    time.Duration // how long a partial batch waits for more writes; the default is 100ms
+	Concurrency int           // concurrent BatchWriteItem calls; the default is 4
+	Buffer      int           // items buffered before Put and Delete block; the default is 1000
+
+	// OnError, if set, is called with the writes that failed for good, as
+	// they fail. They are also returned by the next Flush or Close.
+	OnError func(failed []WriteFailure)
+}
+
+// Writer buffers writes and writes them in the background as batches of 25
+// BatchWriteItem requests, for high-throughput ingestion: a batch is
+// written once full, or after cfg.Interval, with up to cfg.Concurrency
+// batches in flight, and unprocessed items resubmitted with backoff. Put and
+// Delete return once the write is buffered; Flush and Close wait for the
+// writes to land and report the ones that failed.
+//
+//	w := client.Writer(libdy.WriterConfig{Concurrency: 8})
+//	for _, item := range items {
+//		if err := w.Put(ctx, item); err != nil {
+//			return err
+//		}
+//	}
+//
+//	return w.Close(ctx)
+//
+// Batches may land in any order; writes to the same item within a batch
+// collapse to the last one, as DynamoDB rejects batches that write an item
+// twice.
+type Writer struct {
+	c        *Client
+	o        options
+	err      error // of the options
+	cfg      WriterConfig
+	mu       sync.Mutex
+	buf      []*dynamodb.WriteRequest
+	failed   []WriteFailure
+	total    int // writes since the last Flush
+	closed   bool
+	sendMu   sync.RWMutex // held to send on batches, or to close it
+	batches  chan []*dynamodb.WriteRequest
+	inflight sync.WaitGroup // of the buffered writes
+	workers  sync.WaitGroup
+	keysOnce sync.Once
+	keyAttrs []string
+	stop     chan struct{}
+	written  func(n int64) // called with the writes that landed, per batch
+}
+
+// Writer returns a Writer to the Client's table. opts apply to the writes,
+// e.g. WithWriteCapacity or WithRetryPolicy. Close it when done.
+func (c *Client) Writer(cfg WriterConfig, opts ...Option) *Writer {
+	return c.newWriter(cfg, nil, opts)
+}
+
+func (c *Client) newWriter(cfg WriterConfig, written func(n int64), opts []Option) *Writer {
+	if cfg.Interval <= 0 {
+		cfg.Interval = writerInterval
+	}
+
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = writerConcurrency
+	}
+
+	if cfg.Buffer < batchWriteMax {
+		cfg.Buffer = writerBuffer
+	}
+
+	o, err := c.apply(opts)
+	w := &Writer{
+		c:       c,
+		o:       o,
+		err:     err,
+		cfg:     cfg,
+		batches: make(chan []*dynamodb.WriteRequest, cfg.Buffer/batchWriteMax),
+		stop:    make(chan struct{}),
+		written: written,
+	}
+
+	if err != nil {
+		w.closed = true
+		return w
+	}
+
+	for i := 0; i < cfg.Concurrency; i++ {
+		w.workers.Add(1)
+		go w.work()
+	}
+
+	go w.tick()
+	return w
+}
+
+// Put buffers a write of item, encoded as by PutItem (see WithCompression,
+// WithEncryption, and WithOffload). It blocks while the buffer is full,
+// until ctx is done.
+func (w *Writer) Put(ctx context.Context, item map[string]*dynamodb.AttributeValue) error {
+	if w.err != nil {
+		return w.err
+	}
+
+	stored, err := w.o.encode(ctx, w.c.svc, item)
+	if err != nil {
+		return err
+	}
+
+	return w.add(ctx, &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: stored}})
+}
+
+// Delete buffers a delete of the item with the given key, as in DeleteItem.
+// It blocks while the buffer is full, until ctx is done.
+func (w *Writer) Delete(ctx context.Context, pk, sk string) error {
+	if w.err != nil {
+		return w.err
+	}
+
+	kp, ks, err := w.c.keys(ctx, w.o, pk, sk)
+	if err != nil {
+		return err
+	}
+
+	return w.add(ctx, &dynamodb.WriteRequest{DeleteRequest: &dynamodb.DeleteRequest{Key: keyMap(kp, ks)}})
+}
+
+func (w *Writer) add(ctx context.Context, req *dynamodb.WriteRequest) error {
+	if w.err != nil {
+		return w.err
+	}
+
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return ErrWriterClosed
+	}
+
+	w.inflight.Add(1)
+	w.total++
+	w.buf = append(w.buf, req)
+	var batch []*dynamodb.WriteRequest
+	if len(w.buf) >= batchWriteMax {
+		batch, w.buf = w.buf, nil
+	}
+
+	w.mu.Unlock()
+	return w.send(ctx, batch)
+}
+
+// send queues batch, if any, for the workers.
+func (w *Writer) send(ctx context.Context, batch []*dynamodb.WriteRequest) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	w.sendMu.RLock()
+	defer w.sendMu.RUnlock()
+	select {
+	case w.batches <- batch:
+		return nil
+	case <-ctx.Done():
+		// Not written; count the batch failed, including the caller's write.
+		w.fail(writeFailures(batch, ctx.Err()))
+		for range batch {
+			w.inflight.Done()
+		}
+
+		return ctx.Err()
+	}
+}
+
+// cut queues the partial batch, if any.
+func (w *Writer) cut(ctx context.Context) error {
+	w.mu.Lock()
+	batch := w.buf
+	w.buf = nil
+	w.mu.Unlock()
+	return w.send(ctx, batch)
+}
+
+// tick queues partial batches every cfg.Interval.
+func (w *Writer) tick() {
+	t := time.NewTicker(w.cfg.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-t.C:
+			w.cut(context.Background())
+		}
+	}
+}
+
+func (w *Writer) work() {
+	defer w.workers.Done()
+	for batch := range w.batches {
+		reqs := w.collapse(batch)
+		failed := batchWriteChunk(context.Background(), w.c.svc, w.o.table, reqs, w.o)
+		w.fail(failed)
+		if w.written != nil {
+			w.written(int64(len(batch) - len(failed)))
+		}
+
+		for range batch {
+			w.inflight.Done()
+		}
+	}
+}
+
+// collapse returns batch with only the last write to each item.
+func (w *Writer) collapse(batch []*dynamodb.WriteRequest) []*dynamodb.WriteRequest {
+	w.keysOnce.Do(func() {
+		w.keyAttrs, _ = w.c.keyAttrs(context.Background(), w.o) // without them, don't collapse
+	})
+
+	return collapse(batch, w.keyAttrs)
+}
+
+// writeItem returns the item of a put, or the key of a delete.
+func writeItem(req *dynamodb.WriteRequest) map[string]*dynamodb.AttributeValue {
+	if req.PutRequest != nil {
+		return req.PutRequest.Item
+	}
+
+	return req.DeleteRequest.Key
+}
+
+func (w *Writer) fail(failed []WriteFailure) {
+	if len(failed) == 0 {
+		return
+	}
+
+	w.mu.Lock()
+	w.failed = append(w.failed, failed...)
+	w.mu.Unlock()
+	if w.cfg.OnError != nil {
+		w.cfg.OnError(failed)
+	}
+}
+
+// Flush writes the buffered writes now, and waits until they, and any
+// written meanwhile, have landed or failed, or until ctx is done. It
+// returns a *BatchWriteError with the writes that failed since the last
+// Flush.
+func (w *Writer) Flush(ctx context.Context) error {
+	if w.err != nil {
+		return w.err
+	}
+
+	if err := w.cut(ctx); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		w.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	failed, total := w.failed, w.total
+	w.failed, w.total = nil, 0
+	if len(failed) > 0 {
+		return &BatchWriteError{Total: total, Failures: failed}
+	}
+
+	return nil
+}
+
+// Close flushes the Writer (see Flush) and stops it. Writes after Close
+// fail with ErrWriterClosed. If ctx is done first, Close stops waiting, but
+// the buffered writes are still written in the background.
+func (w *Writer) Close(ctx context.Context) error {
+	if w.err != nil {
+		return w.err
+	}
+
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+
+	w.closed = true
+	w.mu.Unlock()
+	err := w.Flush(ctx)
+	close(w.stop)
+	go func() {
+		w.sendMu.Lock()
+		close(w.batches)
+		w.sendMu.Unlock()
+	}()
+
+	if ctx.Err() != nil {
+		return err
+	}
+
+	w.workers.Wait()
+	return err
+}
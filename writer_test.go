@@ -0,0 +1,75 @@
+package libdy_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/flowerinthenight/libdy"
+	"github.com/flowerinthenight/libdy/libdytest"
+)
+
+func TestWriter(t *testing.T) {
+	ctx := context.Background()
+	f := libdytest.SetupFake(t, libdy.TableDef{Name: "t", PK: "id"})
+	c := libdy.New(f, libdy.WithTable("t"))
+	var reported int
+	w := c.Writer(libdy.WriterConfig{Interval: time.Hour, OnError: func(failed []libdy.WriteFailure) { reported += len(failed) }})
+	put := func(id, v string) {
+		item := map[string]*dynamodb.AttributeValue{"id": {S: aws.String(id)}, "v": {S: aws.String(v)}}
+		if err := w.Put(ctx, item); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Writes to the same item in a batch collapse to the last one.
+	put("x", "1")
+	put("x", "2")
+	put("y", "1")
+	if err := w.Delete(ctx, "id:y", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := c.GetItem(ctx, "id:x", "")
+	if err != nil || aws.StringValue(item["v"].S) != "2" {
+		t.Errorf("x = %v, %v, want the last put", item, err)
+	}
+
+	if _, err := c.GetItem(ctx, "id:y", ""); !errors.Is(err, libdy.ErrItemNotFound) {
+		t.Errorf("y: %v, want deleted", err)
+	}
+
+	// Failed writes are reported by the next Flush, and to OnError.
+	if err := w.Put(ctx, map[string]*dynamodb.AttributeValue{"id": {N: aws.String("1")}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var bwe *libdy.BatchWriteError
+	if err := w.Flush(ctx); !errors.As(err, &bwe) || bwe.Total != 1 || len(bwe.Failures) != 1 || reported != 1 {
+		t.Fatalf("Flush of a bad write: %v (%d reported), want 1 of 1 failed", err, reported)
+	}
+
+	for i := 0; i < 60; i++ {
+		put(fmt.Sprint("n", i), "1")
+	}
+
+	if err := w.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(f.Items("t")); n != 61 {
+		t.Errorf("%d items after Close, want 61", n)
+	}
+
+	if err := w.Put(ctx, map[string]*dynamodb.AttributeValue{"id": {S: aws.String("z")}}); !errors.Is(err, libdy.ErrWriterClosed) {
+		t.Errorf("Put after Close: %v, want ErrWriterClosed", err)
+	}
+}